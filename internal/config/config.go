@@ -27,12 +27,18 @@ type Config struct {
 	Credits        CreditsConfig        `mapstructure:"credits"`
 	VerifyCode     VerifyCodeConfig     `mapstructure:"verifyCode"`
 	Commission     CommissionConfig     `mapstructure:"commission"`
+	MarkdownCache  MarkdownCacheConfig  `mapstructure:"markdownCache"`
+	Publish        PublishConfig        `mapstructure:"publish"`
+	Export         ExportConfig         `mapstructure:"export"`
+	ProjectVersion ProjectVersionConfig `mapstructure:"projectVersion"`
+	Share          ShareConfig          `mapstructure:"share"`
 	Themes         map[string]string    `mapstructure:"themes"`
 }
 
 type ServerConfig struct {
-	Port int    `mapstructure:"port"`
-	Mode string `mapstructure:"mode"`
+	Port           int      `mapstructure:"port"`
+	Mode           string   `mapstructure:"mode"`
+	TrustedProxies []string `mapstructure:"trustedProxies"` // 可信反向代理的IP或网段，仅信任来自这些地址的 X-Forwarded-For，为空时直接使用连接地址
 }
 
 type DatabaseConfig struct {
@@ -78,8 +84,11 @@ type RedisConfig struct {
 }
 
 type JWTConfig struct {
-	Secret string        `mapstructure:"secret"`
-	Expire time.Duration `mapstructure:"expire"`
+	Secret        string        `mapstructure:"secret"`
+	AccessExpire  time.Duration `mapstructure:"accessExpire"`  // access token有效期，客户端使用refresh token续期，默认2h
+	RefreshExpire time.Duration `mapstructure:"refreshExpire"` // refresh token有效期，每次刷新后重新计算，默认720h
+	Expire        time.Duration `mapstructure:"expire"`        // 已废弃的旧配置项，未配置 accessExpire 时作为 access token 有效期
+	EnableDebug   bool          `mapstructure:"enableDebug"`   // 是否开放 /debug/jwt 调试接口，生产环境应关闭
 }
 
 type LogConfig struct {
@@ -120,20 +129,24 @@ type DoubaoModelsConfig struct {
 
 // 微信支付配置
 type WxPayConfig struct {
-	AppID          string `mapstructure:"appID"`
-	MchID          string `mapstructure:"mchID"`
-	SerialNo       string `mapstructure:"serialNo"`
-	PrivateKeyPath string `mapstructure:"privateKeyPath"`
-	APIV3Key       string `mapstructure:"apiV3Key"`
-	NotifyURL      string `mapstructure:"notifyURL"`
+	AppID            string `mapstructure:"appID"`
+	MchID            string `mapstructure:"mchID"`
+	SerialNo         string `mapstructure:"serialNo"`
+	PrivateKeyPath   string `mapstructure:"privateKeyPath"`
+	APIV3Key         string `mapstructure:"apiV3Key"`
+	NotifyURL        string `mapstructure:"notifyURL"`
+	BaseURL          string `mapstructure:"baseURL"`          // 接口地址，默认 https://api.mch.weixin.qq.com，可指向本地模拟服务
+	PlatformCertPath string `mapstructure:"platformCertPath"` // 平台证书路径（可选，未配置时通过 /v3/certificates 自动下载）
 }
 
 // 支付宝配置
 type AlipayConfig struct {
 	AppID      string `mapstructure:"appID"`
-	PublicKey  string `mapstructure:"publicKey"`
-	PrivateKey string `mapstructure:"privateKey"`
+	PublicKey  string `mapstructure:"publicKey"`  // 支付宝公钥
+	PrivateKey string `mapstructure:"privateKey"` // 应用私钥
 	NotifyURL  string `mapstructure:"notifyURL"`
+	ReturnURL  string `mapstructure:"returnURL"`  // H5支付完成后跳转地址
+	GatewayURL string `mapstructure:"gatewayURL"` // 网关地址，默认 https://openapi.alipay.com/gateway.do，可指向沙箱或本地模拟服务
 }
 
 // 微信小程序配置
@@ -151,14 +164,19 @@ type WechatGzhConfig struct {
 
 // 微信三方平台配置
 type WechatPlatformConfig struct {
-	Token          string `mapstructure:"token"`
-	EncodingAESKey string `mapstructure:"encodingAESKey"`
-	AppID          string `mapstructure:"appID"`
-	AppSecret      string `mapstructure:"appSecret"`
+	Token           string `mapstructure:"token"`
+	EncodingAESKey  string `mapstructure:"encodingAESKey"`
+	AppID           string `mapstructure:"appID"`
+	AppSecret       string `mapstructure:"appSecret"`
+	APIBaseURL      string `mapstructure:"apiBaseURL"`      // 接口地址，默认 https://api.weixin.qq.com，可指向本地模拟服务
+	AuthRedirectURL string `mapstructure:"authRedirectURL"` // 公众号授权完成后微信回跳的地址（指向 /api/v1/wechat/platform/auth-callback）
+	AuthResultURL   string `mapstructure:"authResultURL"`   // 授权回调处理完成后跳转的前端页面（可选），附带 status 参数
 }
 
 // 短信配置
 type SMSConfig struct {
+	Driver     string `mapstructure:"driver"` // tencent / memory，memory 仅用于开发测试，需显式配置
+	Region     string `mapstructure:"region"`
 	SecretID   string `mapstructure:"secretID"`
 	SecretKey  string `mapstructure:"secretKey"`
 	SDKAppID   string `mapstructure:"sdkAppID"`
@@ -182,6 +200,9 @@ type EmailConfig struct {
 	SMTPServer string `mapstructure:"smtpServer"`
 	SMTPPort   int    `mapstructure:"smtpPort"`
 	SenderName string `mapstructure:"senderName"`
+	Security   string `mapstructure:"security"`   // ssl / starttls / none，为空时465端口使用ssl，其余端口按服务器能力尝试starttls
+	MaxRetries int    `mapstructure:"maxRetries"` // 发送失败重试次数
+	Workers    int    `mapstructure:"workers"`    // 发送队列并发数
 }
 
 // BP文档配置
@@ -204,8 +225,13 @@ type CreditsConfig struct {
 
 // 验证码配置
 type VerifyCodeConfig struct {
-	Expire         int `mapstructure:"expire"`
-	ResendInterval int `mapstructure:"resendInterval"`
+	Expire           int `mapstructure:"expire"`           // 验证码有效期（秒）
+	ResendInterval   int `mapstructure:"resendInterval"`   // 重发间隔（秒）
+	Length           int `mapstructure:"length"`           // 验证码位数
+	MaxAttempts      int `mapstructure:"maxAttempts"`      // 单个验证码最多校验次数
+	TargetDailyLimit int `mapstructure:"targetDailyLimit"` // 单个手机号/邮箱每日发送上限
+	IPDailyLimit     int `mapstructure:"ipDailyLimit"`     // 单个IP每日发送上限
+	VerifiedExpire   int `mapstructure:"verifiedExpire"`   // 校验通过后的登录凭证有效期（秒）
 }
 
 // 佣金配置
@@ -213,6 +239,44 @@ type CommissionConfig struct {
 	Rate float64 `mapstructure:"rate"` // 佣金比例（10% = 0.10）
 }
 
+// Markdown 渲染缓存配置，未配置的项使用默认值
+type MarkdownCacheConfig struct {
+	ConfigEntries  int   `mapstructure:"configEntries"`  // 主题配置缓存条数
+	ConfigTTL      int   `mapstructure:"configTTL"`      // 主题配置缓存有效期（秒），多实例部署时其他实例在此时间后读到新配置
+	RenderEntries  int   `mapstructure:"renderEntries"`  // 渲染结果缓存条数
+	RenderMaxBytes int64 `mapstructure:"renderMaxBytes"` // 渲染结果缓存总字节数上限
+	RenderTTL      int   `mapstructure:"renderTTL"`      // 渲染结果缓存有效期（秒）
+	RedisEnabled   bool  `mapstructure:"redisEnabled"`   // 是否启用 Redis 二级缓存，多实例共享渲染结果
+	RedisTTL       int   `mapstructure:"redisTTL"`       // Redis 二级缓存有效期（秒）
+}
+
+// 多平台文章发布配置
+type PublishConfig struct {
+	MaxAttempts    int               `mapstructure:"maxAttempts"`    // 单个平台的最大发布尝试次数
+	Endpoints      map[string]string `mapstructure:"endpoints"`      // 各平台接口地址（zhihu/toutiao/juejin/csdn/jianshu），可指向本地模拟服务
+	CredentialsKey string            `mapstructure:"credentialsKey"` // 平台凭据（cookie/access_token）加密密钥，未配置时无法绑定平台账号
+}
+
+// 短图文服务端导出配置
+type ExportConfig struct {
+	Workers         int    `mapstructure:"workers"`         // 同时渲染的导出任务数
+	MaxFrames       int    `mapstructure:"maxFrames"`       // 单次导出的最大画板数
+	RendererCommand string `mapstructure:"rendererCommand"` // SVG 转 PNG 命令，默认 rsvg-convert，不可用时使用内置渲染器
+	StorageDir      string `mapstructure:"storageDir"`      // 本地存储目录，未配置 OSS 时导出文件保存在此
+	PublicBaseURL   string `mapstructure:"publicBaseURL"`   // 本地存储文件的访问地址前缀，如 https://api.example.com/files
+}
+
+type ProjectVersionConfig struct {
+	KeepLatest    int `mapstructure:"keepLatest"`    // 每个工程保留的最近版本数
+	KeepDailyDays int `mapstructure:"keepDailyDays"` // 最近多少天内每天保留最后一个版本
+	PruneInterval int `mapstructure:"pruneInterval"` // 历史版本清理间隔（分钟）
+}
+
+type ShareConfig struct {
+	Secret  string `mapstructure:"secret"`  // 分享链接签名密钥，为空时使用 JWT 密钥
+	BaseURL string `mapstructure:"baseURL"` // 分享页地址前缀，如 https://example.com/share/，用于生成完整链接
+}
+
 var AppConfig *Config
 
 // LoadConfig 加载配置文件
@@ -54,6 +54,18 @@ func HandleError(c *gin.Context, err error) {
 	}
 }
 
+// HandleErrorWithData 处理业务错误，并在响应中附带错误详情（如逐项的校验结果）
+func HandleErrorWithData(c *gin.Context, err *BusinessError, data interface{}) {
+	repository.Warnf("Business error: %s", err.Msg)
+	c.JSON(http.StatusBadRequest, models.NewResponse(err.Code, err.Msg, data))
+}
+
+// HandleConflict 返回 409 冲突响应，并附带服务端当前数据供客户端合并
+func HandleConflict(c *gin.Context, msg string, data interface{}) {
+	repository.Warnf("Conflict: %s", msg)
+	c.JSON(http.StatusConflict, models.NewResponse(http.StatusConflict, msg, data))
+}
+
 // Success 返回成功响应
 func Success(c *gin.Context, msg string, data interface{}) {
 	c.JSON(http.StatusOK, models.SuccessResponse(msg, data))
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrInvalidIfMatch If-Match 请求头不是有效的版本号
+var ErrInvalidIfMatch = errors.New("If-Match 请求头格式错误")
+
+// IfMatchVersion 解析 If-Match 请求头中的编辑版本号
+// 未携带或为 * 时返回 nil，表示不做并发检测
+func IfMatchVersion(c *gin.Context) (*int, error) {
+	value := strings.TrimSpace(c.GetHeader("If-Match"))
+	if value == "" || value == "*" {
+		return nil, nil
+	}
+	value = strings.TrimPrefix(value, "W/")
+	version, err := strconv.Atoi(strings.Trim(value, `"`))
+	if err != nil || version < 0 {
+		return nil, ErrInvalidIfMatch
+	}
+	return &version, nil
+}
+
+// SetVersionETag 在响应头中返回当前编辑版本号，客户端下次保存时通过 If-Match 带回
+func SetVersionETag(c *gin.Context, version int) {
+	c.Header("ETag", `"`+strconv.Itoa(version)+`"`)
+}
@@ -6,6 +6,7 @@ import (
 
 	"01agent_server/internal/models"
 	"01agent_server/internal/repository"
+	"01agent_server/internal/service"
 	utils "01agent_server/internal/tools"
 
 	"github.com/gin-gonic/gin"
@@ -60,6 +61,11 @@ func JWTAuth() gin.HandlerFunc {
 			// fmt.Printf("Using Subject as UserID: '%s'\n", userID)
 		}
 
+		// 校验会话状态，已登出或被踢下线的设备即使token未过期也不能继续使用
+		if !checkSession(c, tokenString, userID) {
+			return
+		}
+
 		c.Set("userID", userID)
 		c.Set("username", claims.Username)
 		// fmt.Printf("Set userID to context: '%s'\n", userID)
@@ -82,9 +88,13 @@ func JWTOptional() gin.HandlerFunc {
 					// 解析token
 					claims, err := utils.ParseToken(token)
 					if err == nil {
-						// 将用户信息存储到上下文中
-						c.Set("userID", claims.UserID)
-						c.Set("username", claims.Username)
+						// 会话已失效时按未登录处理
+						if state, err := service.NewSessionService().Validate(token, claims.UserID); err == nil {
+							// 将用户信息存储到上下文中
+							c.Set("userID", claims.UserID)
+							c.Set("username", claims.Username)
+							c.Set("sessionID", state.SessionID)
+						}
 					}
 				}
 			}
@@ -107,6 +117,35 @@ func GetCurrentUserID(c *gin.Context) (string, bool) {
 	return userIDStr, true
 }
 
+// GetCurrentSessionID 从上下文中获取当前会话ID
+func GetCurrentSessionID(c *gin.Context) (int, bool) {
+	sessionID, exists := c.Get("sessionID")
+	if !exists {
+		return 0, false
+	}
+
+	sessionIDInt, ok := sessionID.(int)
+	return sessionIDInt, ok
+}
+
+// checkSession 校验token对应的会话并将会话ID存入上下文，校验失败时中止请求
+func checkSession(c *gin.Context, tokenString, userID string) bool {
+	state, err := service.NewSessionService().Validate(tokenString, userID)
+	if err != nil {
+		if err == service.ErrSessionRevoked {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse(401, err.Error()))
+		} else {
+			repository.Errorf("Validate session failed: %v", err)
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse(500, "会话校验失败"))
+		}
+		c.Abort()
+		return false
+	}
+
+	c.Set("sessionID", state.SessionID)
+	return true
+}
+
 // GetCurrentUsername 从上下文中获取当前用户名
 func GetCurrentUsername(c *gin.Context) (string, bool) {
 	username, exists := c.Get("username")
@@ -152,6 +191,10 @@ func AdminAuth() gin.HandlerFunc {
 			userID = claims.Subject
 		}
 
+		if !checkSession(c, tokenString, userID) {
+			return
+		}
+
 		// 查询用户信息，验证是否为管理员
 		var user models.User
 		if err := repository.DB.Where("user_id = ?", userID).First(&user).Error; err != nil {
@@ -20,21 +20,26 @@ const (
 
 // ArticleEditTask 文章编辑任务模型
 type ArticleEditTask struct {
-	ID            string           `json:"id" gorm:"primaryKey;column:id;type:char(36)" description:"编辑任务ID"`
-	ArticleTaskID *string          `json:"article_task_id" gorm:"column:article_task_id;type:char(36);index" description:"关联文章任务ID"`
-	UserID        string           `json:"user_id" gorm:"column:user_id;type:varchar(50);not null;index" description:"关联用户ID"`
-	Title         string           `json:"title" gorm:"column:title;type:varchar(255);not null" description:"文章标题"`
-	Theme         string           `json:"theme" gorm:"column:theme;type:varchar(100);default:'default'" description:"文章主题"`
-	SceneType     ArticleSceneType `json:"scene_type" gorm:"column:scene_type;type:varchar(20);default:'other';index" description:"场景类型"`
-	Params        *string          `json:"params" gorm:"column:params;type:json" description:"编辑参数"`
-	Content       string           `json:"content" gorm:"column:content;type:longtext;not null" description:"文章内容"`
-	SectionHTML   *string          `json:"section_html" gorm:"column:section_html;type:longtext" description:"文章内容的HTML格式（用于预览）"`
-	Status        string           `json:"status" gorm:"column:status;type:varchar(20);not null;default:'editing'" description:"编辑状态(editing编辑中/pending待发布/published已发布)"`
-	IsPublic      bool             `json:"is_public" gorm:"column:is_public;default:false" description:"是否公开"`
-	Tags          *string          `json:"tags" gorm:"column:tags;type:json" description:"分类标签"`
-	PublishedAt   *time.Time       `json:"published_at" gorm:"column:published_at" description:"发布时间"`
-	CreatedAt     time.Time        `json:"created_at" gorm:"column:created_at;autoCreateTime" description:"创建时间"`
-	UpdatedAt     time.Time        `json:"updated_at" gorm:"column:updated_at;autoUpdateTime" description:"更新时间"`
+	ID               string           `json:"id" gorm:"primaryKey;column:id;type:char(36)" description:"编辑任务ID"`
+	ArticleTaskID    *string          `json:"article_task_id" gorm:"column:article_task_id;type:char(36);index" description:"关联文章任务ID"`
+	UserID           string           `json:"user_id" gorm:"column:user_id;type:varchar(50);not null;index" description:"关联用户ID"`
+	Title            string           `json:"title" gorm:"column:title;type:varchar(255);not null" description:"文章标题"`
+	Theme            string           `json:"theme" gorm:"column:theme;type:varchar(100);default:'default'" description:"文章主题"`
+	SceneType        ArticleSceneType `json:"scene_type" gorm:"column:scene_type;type:varchar(20);default:'other';index" description:"场景类型"`
+	Params           *string          `json:"params" gorm:"column:params;type:json" description:"编辑参数"`
+	Content          string           `json:"content" gorm:"column:content;type:longtext;not null" description:"文章内容"`
+	SectionHTML      *string          `json:"section_html" gorm:"column:section_html;type:longtext" description:"文章内容的HTML格式（用于预览）"`
+	Status           string           `json:"status" gorm:"column:status;type:varchar(20);not null;default:'editing'" description:"编辑状态(editing编辑中/pending待发布/published已发布)"`
+	IsPublic         bool             `json:"is_public" gorm:"column:is_public;default:false" description:"是否公开"`
+	Tags             *string          `json:"tags" gorm:"column:tags;type:json" description:"分类标签"`
+	PublishedAt      *time.Time       `json:"published_at" gorm:"column:published_at" description:"发布时间"`
+	PublishError     *string          `json:"publish_error" gorm:"column:publish_error;type:text" description:"最近一次发布失败的原因"`
+	WechatMediaID    *string          `json:"wechat_media_id" gorm:"column:wechat_media_id;type:varchar(128)" description:"公众号草稿media_id"`
+	WechatPublishID  *string          `json:"wechat_publish_id" gorm:"column:wechat_publish_id;type:varchar(64)" description:"公众号发布任务publish_id"`
+	WechatArticleURL *string          `json:"wechat_article_url" gorm:"column:wechat_article_url;type:varchar(500)" description:"公众号文章链接"`
+	EditVersion      int              `json:"edit_version" gorm:"column:edit_version;not null;default:0" description:"编辑版本号，每次保存递增，用于并发修改检测"`
+	CreatedAt        time.Time        `json:"created_at" gorm:"column:created_at;autoCreateTime" description:"创建时间"`
+	UpdatedAt        time.Time        `json:"updated_at" gorm:"column:updated_at;autoUpdateTime" description:"更新时间"`
 
 	// 关联关系
 	User        *User        `json:"user,omitempty" gorm:"-"`
@@ -60,6 +65,89 @@ type ArticlePublishConfig struct {
 
 // 注意：ArticleTask, ArticleTopic, TaskUsage, TaskErrorLog, TotalUsageStats 已在 agent_search.go 中定义
 
+// ArticleEditRevision 编辑任务的历史版本，只追加不修改
+type ArticleEditRevision struct {
+	ID           int       `json:"id" gorm:"primaryKey;column:id" description:"版本记录ID"`
+	EditTaskID   string    `json:"edit_task_id" gorm:"column:edit_task_id;type:char(36);not null;uniqueIndex:uk_edit_task_revision" description:"关联编辑任务ID"`
+	UserID       string    `json:"user_id" gorm:"column:user_id;type:varchar(50);not null;index" description:"关联用户ID"`
+	Revision     int       `json:"revision" gorm:"column:revision;not null;uniqueIndex:uk_edit_task_revision" description:"版本号，从1递增"`
+	Title        string    `json:"title" gorm:"column:title;type:varchar(255);not null" description:"文章标题"`
+	Theme        string    `json:"theme" gorm:"column:theme;type:varchar(100)" description:"文章主题"`
+	Content      string    `json:"content,omitempty" gorm:"column:content;type:longtext;not null" description:"文章内容"`
+	Params       *string   `json:"params,omitempty" gorm:"column:params;type:json" description:"编辑参数"`
+	Source       string    `json:"source" gorm:"column:source;type:varchar(20);not null;default:'save'" description:"版本来源(save保存前快照/restore恢复前快照)"`
+	RestoredFrom *int      `json:"restored_from" gorm:"column:restored_from" description:"恢复操作的目标版本号"`
+	CreatedAt    time.Time `json:"created_at" gorm:"column:created_at;autoCreateTime" description:"创建时间"`
+}
+
+// 版本来源
+const (
+	EditRevisionSourceSave    = "save"
+	EditRevisionSourceRestore = "restore"
+)
+
+// ArticlePlatformPublish 编辑任务在各平台的发布记录，每个平台独立发布与重试
+type ArticlePlatformPublish struct {
+	ID          int              `json:"id" gorm:"primaryKey;column:id" description:"发布记录ID"`
+	EditTaskID  string           `json:"edit_task_id" gorm:"column:edit_task_id;type:char(36);not null;uniqueIndex:uk_edit_task_platform" description:"关联编辑任务ID"`
+	UserID      string           `json:"user_id" gorm:"column:user_id;type:varchar(50);not null;index" description:"关联用户ID"`
+	Platform    ArticleSceneType `json:"platform" gorm:"column:platform;type:varchar(20);not null;uniqueIndex:uk_edit_task_platform" description:"发布平台"`
+	Mode        string           `json:"mode" gorm:"column:mode;type:varchar(20);not null;default:'publish'" description:"发布方式(draft仅存草稿/publish直接发布)"`
+	Status      string           `json:"status" gorm:"column:status;type:varchar(20);not null;default:'pending';index:idx_platform_publish_status_next" description:"发布状态"`
+	Attempts    int              `json:"attempts" gorm:"column:attempts;not null;default:0" description:"已尝试次数"`
+	LastError   *string          `json:"last_error" gorm:"column:last_error;type:text" description:"最近一次失败原因"`
+	NextRunAt   time.Time        `json:"next_run_at" gorm:"column:next_run_at;not null;index:idx_platform_publish_status_next" description:"下次尝试时间"`
+	LockedUntil *time.Time       `json:"locked_until" gorm:"column:locked_until" description:"处理锁过期时间"`
+	ExternalID  *string          `json:"external_id" gorm:"column:external_id;type:varchar(128)" description:"平台侧文章或草稿ID"`
+	ArticleURL  *string          `json:"article_url" gorm:"column:article_url;type:varchar(500)" description:"平台侧文章链接"`
+	Warnings    *string          `json:"warnings" gorm:"column:warnings;type:json" description:"内容转换警告（标签截断、图片转存失败等）"`
+	PublishedAt *time.Time       `json:"published_at" gorm:"column:published_at" description:"发布完成时间"`
+	CreatedAt   time.Time        `json:"created_at" gorm:"column:created_at;autoCreateTime" description:"创建时间"`
+	UpdatedAt   time.Time        `json:"updated_at" gorm:"column:updated_at;autoUpdateTime" description:"更新时间"`
+}
+
+// 平台发布方式
+const (
+	PlatformPublishModeDraft   = "draft"
+	PlatformPublishModePublish = "publish"
+)
+
+// 平台发布状态
+const (
+	PlatformPublishStatusPending    = "pending"    // 等待发布（含等待重试）
+	PlatformPublishStatusPublishing = "publishing" // 发布中
+	PlatformPublishStatusDraft      = "draft"      // 已保存为平台草稿
+	PlatformPublishStatusPublished  = "published"  // 已发布
+	PlatformPublishStatusFailed     = "failed"     // 发布失败（不再自动重试）
+)
+
+// ArticleScheduledPublish 编辑任务的定时发布，到期后由调度器同步到公众号并按需发布
+type ArticleScheduledPublish struct {
+	ID          int        `json:"id" gorm:"primaryKey;column:id" description:"定时发布ID"`
+	EditTaskID  string     `json:"edit_task_id" gorm:"column:edit_task_id;type:char(36);not null;index" description:"关联编辑任务ID"`
+	UserID      string     `json:"user_id" gorm:"column:user_id;type:varchar(50);not null;index" description:"关联用户ID"`
+	TopicID     *string    `json:"topic_id" gorm:"column:topic_id;type:char(36);index" description:"关联选题计划ID"`
+	Mode        string     `json:"mode" gorm:"column:mode;type:varchar(20);not null;default:'publish'" description:"发布方式(draft仅同步草稿箱/publish直接发布)"`
+	ScheduledAt time.Time  `json:"scheduled_at" gorm:"column:scheduled_at;not null" description:"计划发布时间"`
+	Status      string     `json:"status" gorm:"column:status;type:varchar(20);not null;default:'scheduled';index:idx_scheduled_publish_status_next" description:"定时发布状态"`
+	Attempts    int        `json:"attempts" gorm:"column:attempts;not null;default:0" description:"已尝试次数"`
+	LastError   *string    `json:"last_error" gorm:"column:last_error;type:text" description:"最近一次失败原因"`
+	NextRunAt   time.Time  `json:"next_run_at" gorm:"column:next_run_at;not null;index:idx_scheduled_publish_status_next" description:"下次执行时间"`
+	LockedUntil *time.Time `json:"locked_until" gorm:"column:locked_until" description:"处理锁过期时间"`
+	FinishedAt  *time.Time `json:"finished_at" gorm:"column:finished_at" description:"完成时间"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"column:created_at;autoCreateTime" description:"创建时间"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"column:updated_at;autoUpdateTime" description:"更新时间"`
+}
+
+// 定时发布状态
+const (
+	ScheduledPublishStatusScheduled = "scheduled" // 等待执行（含等待重试）
+	ScheduledPublishStatusRunning   = "running"   // 执行中
+	ScheduledPublishStatusSucceeded = "succeeded" // 执行成功
+	ScheduledPublishStatusFailed    = "failed"    // 执行失败（不再自动重试）
+	ScheduledPublishStatusCanceled  = "canceled"  // 已取消
+)
+
 // ArticleEditStatus 文章编辑状态常量
 const (
 	ArticleEditStatusEditing   = "editing"   // 编辑中
@@ -77,6 +165,18 @@ func (ArticlePublishConfig) TableName() string {
 	return "article_publish_configs"
 }
 
+func (ArticleEditRevision) TableName() string {
+	return "article_edit_revisions"
+}
+
+func (ArticlePlatformPublish) TableName() string {
+	return "article_platform_publishes"
+}
+
+func (ArticleScheduledPublish) TableName() string {
+	return "article_scheduled_publishes"
+}
+
 // 注意：ArticleTask, ArticleTopic, TaskUsage, TaskErrorLog, TotalUsageStats 的 TableName 方法已在 agent_search.go 中定义
 
 // 注意：ArticleTask 和 ArticleTopic 的响应结构和 ToResponse 方法应在 agent_search.go 中定义
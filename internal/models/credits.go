@@ -133,6 +133,7 @@ type UserTimedCredits struct {
 	OriginalCredits int       `json:"original_credits" gorm:"column:original_credits;default:0" description:"原始积分"`
 	SourceType      string    `json:"source_type" gorm:"column:source_type;type:varchar(50);not null;index" description:"来源类型：invite/package/activity/register/other"`
 	SourceDesc      *string   `json:"source_desc" gorm:"column:source_desc;type:varchar(255)" description:"来源描述"`
+	TradeID         *int      `json:"trade_id" gorm:"column:trade_id;index" description:"关联交易（积分套餐购买时记录，用于退款扣回）"`
 	ExpireAt        time.Time `json:"expire_at" gorm:"column:expire_at;not null;index" description:"过期时间"`
 	CreatedAt       time.Time `json:"created_at" gorm:"column:created_at" description:"创建时间"`
 	UpdatedAt       time.Time `json:"updated_at" gorm:"column:updated_at" description:"更新时间"`
@@ -215,3 +216,37 @@ func (cr *CreditRecord) ToResponse() CreditRecordResponse {
 		CreatedAt:   cr.CreatedAt.Format("2006-01-02 15:04:05"),
 	}
 }
+
+// CreditReservationStatus 积分预扣状态
+type CreditReservationStatus string
+
+const (
+	CreditReservationHeld     CreditReservationStatus = "held"     // 冻结中
+	CreditReservationCaptured CreditReservationStatus = "captured" // 已结算
+	CreditReservationReleased CreditReservationStatus = "released" // 已释放
+	CreditReservationExpired  CreditReservationStatus = "expired"  // 已过期
+)
+
+// CreditReservation 积分预扣模型
+// 长耗时任务（文章生成、数字人合成、声音训练等）开始前冻结积分，任务完成后按实际用量结算，失败则释放
+type CreditReservation struct {
+	ID              int                     `json:"id" gorm:"primaryKey;column:id" description:"预扣ID"`
+	UserID          string                  `json:"user_id" gorm:"column:user_id;type:varchar(50);not null;index:idx_credit_reservation_user_status" description:"关联用户"`
+	ServiceCode     string                  `json:"service_code" gorm:"column:service_code;type:varchar(64);not null" description:"服务代号"`
+	Units           float64                 `json:"units" gorm:"column:units" description:"预估计费数量"`
+	Credits         int                     `json:"credits" gorm:"column:credits;not null" description:"冻结积分数"`
+	Status          CreditReservationStatus `json:"status" gorm:"column:status;type:varchar(20);not null;default:held;index:idx_credit_reservation_user_status" description:"状态"`
+	BizType         *string                 `json:"biz_type" gorm:"column:biz_type;type:varchar(32)" description:"业务类型"`
+	BizID           *string                 `json:"biz_id" gorm:"column:biz_id;type:varchar(64);index" description:"业务ID"`
+	ActualUnits     *float64                `json:"actual_units" gorm:"column:actual_units" description:"实际计费数量"`
+	CapturedCredits *int                    `json:"captured_credits" gorm:"column:captured_credits" description:"实际扣除积分"`
+	CreditRecordID  *int                    `json:"credit_record_id" gorm:"column:credit_record_id" description:"关联积分记录"`
+	ExpireAt        time.Time               `json:"expire_at" gorm:"column:expire_at;not null;index" description:"冻结过期时间"`
+	SettledAt       *time.Time              `json:"settled_at" gorm:"column:settled_at" description:"结算/释放时间"`
+	CreatedAt       time.Time               `json:"created_at" gorm:"column:created_at" description:"创建时间"`
+	UpdatedAt       time.Time               `json:"updated_at" gorm:"column:updated_at" description:"更新时间"`
+}
+
+func (CreditReservation) TableName() string {
+	return "credit_reservations"
+}
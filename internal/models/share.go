@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// ShareResourceType 分享链接指向的资源类型
+type ShareResourceType string
+
+const (
+	ShareResourceShortPostProject  ShareResourceType = "short_post_project"  // 短图文工程
+	ShareResourceShortPostTemplate ShareResourceType = "short_post_template" // 短图文工程模板
+	ShareResourceArticleEdit       ShareResourceType = "article_edit"        // 文章编辑任务
+	ShareResourceUserTemplate      ShareResourceType = "user_template"       // 用户样式模板
+)
+
+// ShareLink 资源的只读分享链接，链接中携带签名，可设置访问密码与有效期
+type ShareLink struct {
+	ID           string            `json:"id" gorm:"primaryKey;column:id;type:char(36)" description:"分享链接ID"`
+	UserID       string            `json:"user_id" gorm:"column:user_id;type:varchar(50);not null;index" description:"创建用户ID"`
+	ResourceType ShareResourceType `json:"resource_type" gorm:"column:resource_type;type:varchar(30);not null;index:idx_share_resource" description:"资源类型"`
+	ResourceID   string            `json:"resource_id" gorm:"column:resource_id;type:varchar(50);not null;index:idx_share_resource" description:"资源ID"`
+	Code         string            `json:"code" gorm:"column:code;type:varchar(20);not null;uniqueIndex" description:"分享码"`
+	PasswordHash *string           `json:"-" gorm:"column:password_hash;type:varchar(100)" description:"访问密码哈希，为空表示无需密码"`
+	ExpiresAt    *time.Time        `json:"expires_at" gorm:"column:expires_at" description:"过期时间，为空表示永久有效"`
+	ViewCount    int               `json:"view_count" gorm:"column:view_count;not null;default:0" description:"访问次数"`
+	LastViewedAt *time.Time        `json:"last_viewed_at" gorm:"column:last_viewed_at" description:"最后访问时间"`
+	RevokedAt    *time.Time        `json:"revoked_at" gorm:"column:revoked_at" description:"撤销时间"`
+	CreatedAt    time.Time         `json:"created_at" gorm:"column:created_at;autoCreateTime" description:"创建时间"`
+	UpdatedAt    time.Time         `json:"updated_at" gorm:"column:updated_at;autoUpdateTime" description:"更新时间"`
+}
+
+func (ShareLink) TableName() string {
+	return "share_links"
+}
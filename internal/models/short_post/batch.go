@@ -0,0 +1,68 @@
+package short_post
+
+import "time"
+
+// BatchJobStatus 批量生成任务状态
+type BatchJobStatus string
+
+const (
+	BatchJobStatusPending   BatchJobStatus = "pending"   // 等待生成
+	BatchJobStatusRunning   BatchJobStatus = "running"   // 生成中
+	BatchJobStatusSucceeded BatchJobStatus = "succeeded" // 全部成功
+	BatchJobStatusPartial   BatchJobStatus = "partial"   // 部分行失败
+	BatchJobStatusFailed    BatchJobStatus = "failed"    // 全部失败
+)
+
+// BatchRowStatus 批量生成任务中单行的状态
+type BatchRowStatus string
+
+const (
+	BatchRowStatusPending   BatchRowStatus = "pending"
+	BatchRowStatusSucceeded BatchRowStatus = "succeeded"
+	BatchRowStatusFailed    BatchRowStatus = "failed"
+)
+
+// ShortPostBatchJob 批量文案生成短图文任务，每一行数据生成一个工程和文案
+type ShortPostBatchJob struct {
+	ID                string         `json:"id" gorm:"primaryKey;column:id;type:char(36)" description:"任务ID"`
+	UserID            string         `json:"user_id" gorm:"column:user_id;type:varchar(50);not null;index" description:"关联用户ID"`
+	Name              string         `json:"name" gorm:"column:name;type:varchar(200);not null" description:"任务名称"`
+	TemplateID        *string        `json:"template_id" gorm:"column:template_id;type:char(36)" description:"使用的模板ID"`
+	TemplateProjectID *string        `json:"template_project_id" gorm:"column:template_project_id;type:char(36)" description:"作为模板使用的工程ID"`
+	SourceFormat      string         `json:"source_format" gorm:"column:source_format;type:varchar(10);not null" description:"数据来源格式（csv/xlsx/json）"`
+	Columns           *string        `json:"columns" gorm:"column:columns;type:json" description:"数据列名（按原始顺序）"`
+	Mapping           *string        `json:"mapping" gorm:"column:mapping;type:json" description:"列名到占位符的映射"`
+	Status            BatchJobStatus `json:"status" gorm:"column:status;type:varchar(20);not null;default:'pending';index:idx_batch_job_status" description:"任务状态"`
+	TotalRows         int            `json:"total_rows" gorm:"column:total_rows;not null;default:0" description:"总行数"`
+	SucceededRows     int            `json:"succeeded_rows" gorm:"column:succeeded_rows;not null;default:0" description:"成功行数"`
+	FailedRows        int            `json:"failed_rows" gorm:"column:failed_rows;not null;default:0" description:"失败行数"`
+	Error             *string        `json:"error" gorm:"column:error;type:text" description:"任务失败原因"`
+	Attempts          int            `json:"attempts" gorm:"column:attempts;not null;default:0" description:"已执行次数"`
+	LockedUntil       *time.Time     `json:"-" gorm:"column:locked_until;index:idx_batch_job_status" description:"执行锁过期时间"`
+	StartedAt         *time.Time     `json:"started_at" gorm:"column:started_at" description:"开始时间"`
+	FinishedAt        *time.Time     `json:"finished_at" gorm:"column:finished_at" description:"完成时间"`
+	CreatedAt         time.Time      `json:"created_at" gorm:"column:created_at;autoCreateTime;index" description:"创建时间"`
+	UpdatedAt         time.Time      `json:"updated_at" gorm:"column:updated_at;autoUpdateTime" description:"更新时间"`
+}
+
+// ShortPostBatchRow 批量生成任务中的一行数据及其生成结果
+type ShortPostBatchRow struct {
+	ID            uint           `json:"id" gorm:"primaryKey;autoIncrement;column:id" description:"ID"`
+	JobID         string         `json:"job_id" gorm:"column:job_id;type:char(36);not null;uniqueIndex:uk_batch_row" description:"任务ID"`
+	RowIndex      int            `json:"row_index" gorm:"column:row_index;not null;uniqueIndex:uk_batch_row" description:"行号（从 1 开始，不含表头）"`
+	Data          string         `json:"data" gorm:"column:data;type:json;not null" description:"行数据（列名到值）"`
+	Status        BatchRowStatus `json:"status" gorm:"column:status;type:varchar(20);not null;default:'pending'" description:"行状态"`
+	ProjectID     *string        `json:"project_id" gorm:"column:project_id;type:char(36)" description:"生成的工程ID"`
+	UnfilledSlots *string        `json:"unfilled_slots" gorm:"column:unfilled_slots;type:json" description:"没有内容填充的占位符"`
+	Error         *string        `json:"error" gorm:"column:error;type:text" description:"失败原因"`
+	CreatedAt     time.Time      `json:"created_at" gorm:"column:created_at;autoCreateTime" description:"创建时间"`
+	UpdatedAt     time.Time      `json:"updated_at" gorm:"column:updated_at;autoUpdateTime" description:"更新时间"`
+}
+
+func (ShortPostBatchJob) TableName() string {
+	return "short_post_batch_jobs"
+}
+
+func (ShortPostBatchRow) TableName() string {
+	return "short_post_batch_rows"
+}
@@ -64,11 +64,21 @@ type ShortPostProject struct {
 	CreatedAt   time.Time     `json:"created_at" gorm:"column:created_at;autoCreateTime;index" description:"创建时间"`
 	UpdatedAt   time.Time     `json:"updated_at" gorm:"column:updated_at;autoUpdateTime;index" description:"更新时间"`
 	SavedAt     *time.Time    `json:"saved_at" gorm:"column:saved_at" description:"最后保存时间"`
+	EditVersion int           `json:"edit_version" gorm:"column:edit_version;not null;default:0" description:"编辑版本号，每次保存内容递增，用于并发修改检测"`
 
 	// 关联关系
 	User *models.User `json:"user,omitempty" gorm:"-"`
 }
 
+// ContentSource 工程内容版本来源
+const (
+	ContentSourceSave     = "save"     // 编辑器保存
+	ContentSourceSnapshot = "snapshot" // 命名快照后继续编辑的副本
+	ContentSourceRestore  = "restore"  // 恢复历史版本
+	ContentSourceBranch   = "branch"   // 从其他工程的历史版本分支
+	ContentSourceTemplate = "template" // 使用模板创建
+)
+
 // ShortPostProjectContent 短图文工程内容模型（子表 - 存储JSON数据）
 type ShortPostProjectContent struct {
 	ID           string    `json:"id" gorm:"primaryKey;column:id;type:char(36)" description:"内容ID"`
@@ -79,6 +89,9 @@ type ShortPostProjectContent struct {
 	Metadata     *string   `json:"metadata" gorm:"column:metadata;type:json" description:"工程元数据"`
 	Version      int       `json:"version" gorm:"column:version;default:1" description:"内容版本号"`
 	IsLatest     bool      `json:"is_latest" gorm:"column:is_latest;default:true;index" description:"是否是最新版本"`
+	Label        *string   `json:"label" gorm:"column:label;type:varchar(100)" description:"版本名称，命名快照不会被自动清理"`
+	Source       string    `json:"source" gorm:"column:source;type:varchar(20);default:'save'" description:"版本来源"`
+	RestoredFrom *int      `json:"restored_from" gorm:"column:restored_from" description:"恢复或分支来源的版本号"`
 	CreatedAt    time.Time `json:"created_at" gorm:"column:created_at;autoCreateTime;index" description:"创建时间"`
 	UpdatedAt    time.Time `json:"updated_at" gorm:"column:updated_at;autoUpdateTime" description:"更新时间"`
 
@@ -117,6 +130,40 @@ type ShortPostExportRecord struct {
 	User *models.User `json:"user,omitempty" gorm:"-"`
 }
 
+// ExportJobStatus 服务端导出任务状态
+type ExportJobStatus string
+
+const (
+	ExportJobStatusPending   ExportJobStatus = "pending"   // 等待渲染
+	ExportJobStatusRunning   ExportJobStatus = "running"   // 渲染中
+	ExportJobStatusSucceeded ExportJobStatus = "succeeded" // 已完成
+	ExportJobStatusFailed    ExportJobStatus = "failed"    // 失败
+)
+
+// ShortPostExportJob 短图文服务端导出任务，完成后回填关联导出记录的文件地址与大小
+type ShortPostExportJob struct {
+	ID             string          `json:"id" gorm:"primaryKey;column:id;type:char(36)" description:"任务ID"`
+	UserID         string          `json:"user_id" gorm:"column:user_id;type:varchar(50);not null;index" description:"关联用户ID"`
+	ProjectID      string          `json:"project_id" gorm:"column:project_id;type:char(36);not null;index" description:"关联工程ID"`
+	ContentID      string          `json:"content_id" gorm:"column:content_id;type:char(36);not null" description:"导出的内容ID"`
+	ContentVersion int             `json:"content_version" gorm:"column:content_version" description:"导出的内容版本号"`
+	ExportRecordID string          `json:"export_record_id" gorm:"column:export_record_id;type:char(36);not null;index" description:"关联导出记录ID"`
+	ExportFormat   ExportFormat    `json:"export_format" gorm:"column:export_format;type:varchar(20);not null" description:"导出格式"`
+	Options        *string         `json:"options" gorm:"column:options;type:json" description:"导出参数"`
+	Status         ExportJobStatus `json:"status" gorm:"column:status;type:varchar(20);not null;default:'pending';index:idx_export_job_status" description:"任务状态"`
+	Progress       int             `json:"progress" gorm:"column:progress;not null;default:0" description:"进度（0-100）"`
+	TotalFrames    int             `json:"total_frames" gorm:"column:total_frames;not null;default:0" description:"需要渲染的画板数"`
+	RenderedFrames int             `json:"rendered_frames" gorm:"column:rendered_frames;not null;default:0" description:"已渲染的画板数"`
+	Warnings       *string         `json:"warnings" gorm:"column:warnings;type:json" description:"渲染警告（如无法加载的图片）"`
+	Error          *string         `json:"error" gorm:"column:error;type:text" description:"失败原因"`
+	Attempts       int             `json:"attempts" gorm:"column:attempts;not null;default:0" description:"已尝试次数"`
+	LockedUntil    *time.Time      `json:"-" gorm:"column:locked_until;index:idx_export_job_status" description:"执行锁过期时间，也用于重试等待"`
+	StartedAt      *time.Time      `json:"started_at" gorm:"column:started_at" description:"开始渲染时间"`
+	FinishedAt     *time.Time      `json:"finished_at" gorm:"column:finished_at" description:"完成时间"`
+	CreatedAt      time.Time       `json:"created_at" gorm:"column:created_at;autoCreateTime;index" description:"创建时间"`
+	UpdatedAt      time.Time       `json:"updated_at" gorm:"column:updated_at;autoUpdateTime" description:"更新时间"`
+}
+
 // 表名设置
 func (ShortPostProject) TableName() string {
 	return "short_post_projects"
@@ -133,3 +180,7 @@ func (ShortPostProjectCopywriting) TableName() string {
 func (ShortPostExportRecord) TableName() string {
 	return "short_post_export_records"
 }
+
+func (ShortPostExportJob) TableName() string {
+	return "short_post_export_jobs"
+}
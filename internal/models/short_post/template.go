@@ -0,0 +1,66 @@
+package short_post
+
+import "time"
+
+// TemplateVisibility 工程模板可见范围
+type TemplateVisibility string
+
+const (
+	TemplateVisibilityPrivate TemplateVisibility = "private" // 仅自己可见
+	TemplateVisibilityTeam    TemplateVisibility = "team"    // 自己和指定成员可见
+	TemplateVisibilityPublic  TemplateVisibility = "public"  // 审核通过后所有用户可见
+)
+
+// TemplateReviewStatus 公开模板审核状态
+type TemplateReviewStatus string
+
+const (
+	TemplateReviewNone     TemplateReviewStatus = "none"     // 非公开模板，无需审核
+	TemplateReviewPending  TemplateReviewStatus = "pending"  // 等待管理员审核
+	TemplateReviewApproved TemplateReviewStatus = "approved" // 审核通过
+	TemplateReviewRejected TemplateReviewStatus = "rejected" // 审核不通过
+)
+
+// ShortPostTemplate 短图文工程模板，保存发布时工程内容的快照
+// 内容中的 {{title}}、{{image_1}} 等占位符在使用模板时由文案替换
+type ShortPostTemplate struct {
+	ID              string               `json:"id" gorm:"primaryKey;column:id;type:char(36)" description:"模板ID"`
+	UserID          string               `json:"user_id" gorm:"column:user_id;type:varchar(50);not null;index" description:"发布用户ID"`
+	SourceProjectID string               `json:"source_project_id" gorm:"column:source_project_id;type:char(36);index" description:"来源工程ID"`
+	Name            string               `json:"name" gorm:"column:name;type:varchar(200);not null" description:"模板名称"`
+	Description     *string              `json:"description" gorm:"column:description;type:text" description:"模板描述"`
+	ProjectType     ProjectType          `json:"project_type" gorm:"column:project_type;type:varchar(20);not null;index" description:"工程类型"`
+	Tags            *string              `json:"tags" gorm:"column:tags;type:json" description:"标签列表"`
+	Thumbnail       *string              `json:"thumbnail" gorm:"column:thumbnail;type:varchar(500)" description:"缩略图URL"`
+	CanvasConfig    *string              `json:"canvas_config" gorm:"column:canvas_config;type:json" description:"画板配置"`
+	FramesData      *string              `json:"frames_data" gorm:"column:frames_data;type:json" description:"Frame节点数据列表"`
+	ElementsData    *string              `json:"elements_data" gorm:"column:elements_data;type:json" description:"画板元素数据"`
+	Metadata        *string              `json:"metadata" gorm:"column:metadata;type:json" description:"工程元数据"`
+	Slots           *string              `json:"slots" gorm:"column:slots;type:json" description:"内容中的占位符列表"`
+	FrameCount      int                  `json:"frame_count" gorm:"column:frame_count;default:0" description:"Frame节点数量"`
+	Visibility      TemplateVisibility   `json:"visibility" gorm:"column:visibility;type:varchar(20);not null;default:'private';index:idx_template_visibility" description:"可见范围"`
+	ReviewStatus    TemplateReviewStatus `json:"review_status" gorm:"column:review_status;type:varchar(20);not null;default:'none';index:idx_template_visibility" description:"公开审核状态"`
+	ReviewNote      *string              `json:"review_note" gorm:"column:review_note;type:varchar(500)" description:"审核意见"`
+	ReviewedBy      *string              `json:"reviewed_by" gorm:"column:reviewed_by;type:varchar(50)" description:"审核管理员ID"`
+	ReviewedAt      *time.Time           `json:"reviewed_at" gorm:"column:reviewed_at" description:"审核时间"`
+	UseCount        int                  `json:"use_count" gorm:"column:use_count;default:0" description:"使用次数"`
+	LastUsedAt      *time.Time           `json:"last_used_at" gorm:"column:last_used_at" description:"最后使用时间"`
+	CreatedAt       time.Time            `json:"created_at" gorm:"column:created_at;autoCreateTime;index" description:"创建时间"`
+	UpdatedAt       time.Time            `json:"updated_at" gorm:"column:updated_at;autoUpdateTime" description:"更新时间"`
+}
+
+// ShortPostTemplateMember 团队模板的成员，成员可以浏览和使用模板
+type ShortPostTemplateMember struct {
+	ID         uint      `json:"id" gorm:"primaryKey;autoIncrement;column:id" description:"ID"`
+	TemplateID string    `json:"template_id" gorm:"column:template_id;type:char(36);not null;uniqueIndex:uk_template_member" description:"模板ID"`
+	UserID     string    `json:"user_id" gorm:"column:user_id;type:varchar(50);not null;uniqueIndex:uk_template_member;index" description:"成员用户ID"`
+	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at;autoCreateTime" description:"加入时间"`
+}
+
+func (ShortPostTemplate) TableName() string {
+	return "short_post_templates"
+}
+
+func (ShortPostTemplateMember) TableName() string {
+	return "short_post_template_members"
+}
@@ -76,11 +76,76 @@ type Trade struct {
 	Metadata       *string    `json:"metadata" gorm:"column:metadata;type:json" description:"元数据，用于存储特定业务数据"`
 	CreatedAt      time.Time  `json:"created_at" gorm:"column:created_at" description:"创建时间"`
 	PaidAt         *time.Time `json:"paid_at" gorm:"column:paid_at" description:"支付时间"`
+	RefundedAmount float64    `json:"refunded_amount" gorm:"column:refunded_amount;type:decimal(10,2);not null;default:0" description:"累计退款金额"`
+	Version        int        `json:"version" gorm:"column:version;not null;default:0" description:"乐观锁版本号，每次状态变更递增"`
+	FulfilledAt    *time.Time `json:"fulfilled_at" gorm:"column:fulfilled_at" description:"权益发放时间，为空表示尚未履约"`
 
 	// 关联关系
 	User User `json:"user,omitempty" gorm:"foreignKey:UserID;references:UserID"`
 }
 
+// TradeRefundStatus 退款状态枚举
+type TradeRefundStatus string
+
+const (
+	TradeRefundStatusPending    TradeRefundStatus = "pending"    // 已创建，正在向渠道申请
+	TradeRefundStatusProcessing TradeRefundStatus = "processing" // 渠道已受理，退款处理中
+	TradeRefundStatusSuccess    TradeRefundStatus = "success"    // 退款成功
+	TradeRefundStatusFailed     TradeRefundStatus = "failed"     // 渠道拒绝退款，未做任何变更
+)
+
+// TradeRefund 交易退款记录
+// 同时作为退款审计记录：保存操作人、原因以及权益、积分、佣金的回收明细
+type TradeRefund struct {
+	ID               int        `json:"id" gorm:"primaryKey;column:id" description:"退款ID"`
+	RefundNo         string     `json:"refund_no" gorm:"column:refund_no;type:varchar(64);not null;uniqueIndex" description:"商户退款单号"`
+	TradeID          int        `json:"trade_id" gorm:"column:trade_id;not null;index" description:"关联交易"`
+	TradeNo          string     `json:"trade_no" gorm:"column:trade_no;type:varchar(64);not null" description:"交易流水号"`
+	UserID           string     `json:"user_id" gorm:"column:user_id;type:varchar(50);not null;index" description:"关联用户"`
+	Amount           float64    `json:"amount" gorm:"column:amount;type:decimal(10,2);not null" description:"退款金额"`
+	IsFullRefund     bool       `json:"is_full_refund" gorm:"column:is_full_refund;not null;default:false" description:"是否退完订单全部金额"`
+	Offline          bool       `json:"offline" gorm:"column:offline;not null;default:false" description:"线下退款，不调用支付渠道"`
+	Reason           string     `json:"reason" gorm:"column:reason;type:varchar(256)" description:"退款原因"`
+	Status           string     `json:"status" gorm:"column:status;type:varchar(20);not null;default:'pending';index" description:"退款状态"`
+	ProviderRefundID *string    `json:"provider_refund_id" gorm:"column:provider_refund_id;type:varchar(64)" description:"渠道退款单号"`
+	ErrorMessage     *string    `json:"error_message" gorm:"column:error_message;type:text" description:"失败原因"`
+	Reversal         *string    `json:"reversal" gorm:"column:reversal;type:json" description:"权益回收明细"`
+	ReversedAt       *time.Time `json:"reversed_at" gorm:"column:reversed_at" description:"权益回收完成时间"`
+	OperatorID       string     `json:"operator_id" gorm:"column:operator_id;type:varchar(50)" description:"操作人"`
+	RefundedAt       *time.Time `json:"refunded_at" gorm:"column:refunded_at" description:"退款完成时间"`
+	CreatedAt        time.Time  `json:"created_at" gorm:"column:created_at" description:"创建时间"`
+	UpdatedAt        time.Time  `json:"updated_at" gorm:"column:updated_at" description:"更新时间"`
+}
+
+// PaymentEventStatus 支付事件处理状态枚举
+type PaymentEventStatus string
+
+const (
+	PaymentEventStatusPending    PaymentEventStatus = "pending"    // 待处理
+	PaymentEventStatusProcessing PaymentEventStatus = "processing" // 处理中
+	PaymentEventStatusDone       PaymentEventStatus = "done"       // 已处理
+	PaymentEventStatusDead       PaymentEventStatus = "dead"       // 超过最大重试次数，需人工介入
+)
+
+// PaymentEvent 支付事件发件箱
+// 与订单状态变更在同一事务中写入，由后台任务异步投递（如支付成功后发放权益）
+type PaymentEvent struct {
+	ID          int        `json:"id" gorm:"primaryKey;column:id" description:"事件ID"`
+	EventType   string     `json:"event_type" gorm:"column:event_type;type:varchar(64);not null;index" description:"事件类型"`
+	DedupKey    string     `json:"dedup_key" gorm:"column:dedup_key;type:varchar(128);not null;uniqueIndex" description:"幂等键，同一键只写入一次"`
+	TradeID     int        `json:"trade_id" gorm:"column:trade_id;not null;index" description:"关联交易"`
+	TradeNo     string     `json:"trade_no" gorm:"column:trade_no;type:varchar(64);not null" description:"交易流水号"`
+	Payload     *string    `json:"payload" gorm:"column:payload;type:json" description:"事件数据"`
+	Status      string     `json:"status" gorm:"column:status;type:varchar(20);not null;default:'pending';index:idx_payment_event_status_next" description:"处理状态"`
+	Attempts    int        `json:"attempts" gorm:"column:attempts;not null;default:0" description:"已投递次数"`
+	LastError   *string    `json:"last_error" gorm:"column:last_error;type:text" description:"最近一次失败原因"`
+	NextRunAt   time.Time  `json:"next_run_at" gorm:"column:next_run_at;not null;index:idx_payment_event_status_next" description:"下次投递时间"`
+	LockedUntil *time.Time `json:"locked_until" gorm:"column:locked_until" description:"处理锁过期时间"`
+	ProcessedAt *time.Time `json:"processed_at" gorm:"column:processed_at" description:"处理完成时间"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"column:created_at" description:"创建时间"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"column:updated_at" description:"更新时间"`
+}
+
 // BPOrder BP订单模型
 type BPOrder struct {
 	ID             int       `json:"id" gorm:"primaryKey;column:id" description:"BP订单ID"`
@@ -143,6 +208,14 @@ func (Trade) TableName() string {
 	return "trades"
 }
 
+func (TradeRefund) TableName() string {
+	return "trade_refunds"
+}
+
+func (PaymentEvent) TableName() string {
+	return "payment_events"
+}
+
 func (BPOrder) TableName() string {
 	return "bp_orders"
 }
@@ -32,16 +32,23 @@ type User struct {
 }
 
 // UserSession 用户会话模型
+// 每个会话对应一台登录设备；refresh token 只保存摘要，同一会话内轮换的 refresh token 属于同一个 family
 type UserSession struct {
-	ID             int       `json:"id" gorm:"primaryKey;column:id"`
-	UserID         string    `json:"user_id" gorm:"column:user_id;type:varchar(50)"`
-	Token          *string   `json:"token" gorm:"column:token;type:longtext"`
-	LoginType      string    `json:"login_type" gorm:"column:login_type;type:varchar(20);default:'web'"`
-	IPAddress      string    `json:"ip_address" gorm:"column:ip_address;type:varchar(45)"`
-	DeviceID       *string   `json:"device_id" gorm:"column:device_id;type:varchar(100)"`
-	Status         int16     `json:"status" gorm:"column:status;default:1"`
-	LastActiveTime time.Time `json:"last_active_time" gorm:"column:last_active_time"`
-	CreatedAt      time.Time `json:"created_at" gorm:"column:created_at"`
+	ID                   int        `json:"id" gorm:"primaryKey;column:id"`
+	UserID               string     `json:"user_id" gorm:"column:user_id;type:varchar(50)"`
+	Token                *string    `json:"token" gorm:"column:token;type:longtext"`
+	TokenHash            *string    `json:"-" gorm:"column:token_hash;type:varchar(64);index" description:"token的SHA-256摘要，用于认证时查找会话"`
+	LoginType            string     `json:"login_type" gorm:"column:login_type;type:varchar(20);default:'web'"`
+	IPAddress            string     `json:"ip_address" gorm:"column:ip_address;type:varchar(45)"`
+	DeviceID             *string    `json:"device_id" gorm:"column:device_id;type:varchar(100)"`
+	Status               int16      `json:"status" gorm:"column:status;default:1"`
+	LastActiveTime       time.Time  `json:"last_active_time" gorm:"column:last_active_time"`
+	CreatedAt            time.Time  `json:"created_at" gorm:"column:created_at"`
+	RefreshFamily        *string    `json:"-" gorm:"column:refresh_family;type:varchar(32);uniqueIndex" description:"refresh token家族标识"`
+	RefreshTokenHash     *string    `json:"-" gorm:"column:refresh_token_hash;type:varchar(64)" description:"当前refresh token的SHA-256摘要"`
+	PrevRefreshTokenHash *string    `json:"-" gorm:"column:prev_refresh_token_hash;type:varchar(64)" description:"上一个refresh token的摘要，用于区分并发刷新与重放"`
+	RefreshRotatedAt     *time.Time `json:"-" gorm:"column:refresh_rotated_at" description:"refresh token最近轮换时间"`
+	RefreshExpiresAt     *time.Time `json:"refresh_expires_at,omitempty" gorm:"column:refresh_expires_at" description:"refresh token过期时间"`
 }
 
 // UserParameters 用户参数模型
@@ -158,6 +165,7 @@ type UserAuthorization struct {
 	AppID            *string   `json:"appid" gorm:"column:appid;type:varchar(100)" description:"应用ID（如微信appid）"`
 	OpenID           string    `json:"openid" gorm:"column:openid;type:varchar(200);not null" description:"用户在平台的唯一标识"`
 	PlatformNickname *string   `json:"platform_nickname" gorm:"column:platform_nickname;type:varchar(100)" description:"平台昵称"`
+	Credentials      *string   `json:"-" gorm:"column:credentials;type:text" description:"平台凭据(JSON: cookie/access_token/options，使用 publish.credentialsKey 加密保存)，用于文章发布"`
 	Status           string    `json:"status" gorm:"column:status;type:varchar(20);not null;default:'enabled';index" description:"授权状态(enabled/disabled)"`
 	CreatedAt        time.Time `json:"created_at" gorm:"column:created_at;autoCreateTime" description:"创建时间"`
 	UpdatedAt        time.Time `json:"updated_at" gorm:"column:updated_at;autoUpdateTime" description:"更新时间"`
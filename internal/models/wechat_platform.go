@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// WechatAuthorizer 通过第三方平台授权的公众号
+// 第三方平台凭 authorizer_refresh_token 换取公众号的接口调用凭据，刷新令牌在每次换取后可能轮换
+type WechatAuthorizer struct {
+	AuthorizerAppID string     `json:"authorizer_appid" gorm:"primaryKey;column:authorizer_appid;type:varchar(50)" description:"公众号appid"`
+	UserID          string     `json:"user_id" gorm:"column:user_id;type:varchar(50);not null;index" description:"授权的用户ID"`
+	RefreshToken    string     `json:"-" gorm:"column:refresh_token;type:varchar(512);not null" description:"authorizer_refresh_token"`
+	FuncInfo        *string    `json:"func_info" gorm:"column:func_info;type:json" description:"授权的权限集"`
+	Status          string     `json:"status" gorm:"column:status;type:varchar(20);not null;default:'authorized';index" description:"授权状态(authorized/unauthorized)"`
+	AuthorizedAt    time.Time  `json:"authorized_at" gorm:"column:authorized_at" description:"授权时间"`
+	UnauthorizedAt  *time.Time `json:"unauthorized_at" gorm:"column:unauthorized_at" description:"取消授权时间"`
+	CreatedAt       time.Time  `json:"created_at" gorm:"column:created_at;autoCreateTime" description:"创建时间"`
+	UpdatedAt       time.Time  `json:"updated_at" gorm:"column:updated_at;autoUpdateTime" description:"更新时间"`
+}
+
+// 公众号授权状态
+const (
+	WechatAuthorizerStatusAuthorized   = "authorized"
+	WechatAuthorizerStatusUnauthorized = "unauthorized"
+)
+
+func (WechatAuthorizer) TableName() string {
+	return "wechat_authorizers"
+}
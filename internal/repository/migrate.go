@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"01agent_server/internal/models"
+	"01agent_server/internal/models/short_post"
 )
 
 // AutoMigrate 自动迁移数据库表
@@ -45,6 +46,8 @@ func AutoMigrate() error {
 		&models.BPOrder{},
 		&models.Production{},
 		&models.UserProduction{},
+		&models.PaymentEvent{},
+		&models.TradeRefund{},
 		// 积分相关
 		&models.CreditProduct{},
 		&models.CreditRechargeOrder{},
@@ -53,9 +56,14 @@ func AutoMigrate() error {
 		&models.UserDailyBenefit{},
 		&models.UserMonthlyBenefit{},
 		&models.UserTimedCredits{},
+		&models.CreditReservation{},
 		// 文章相关
 		&models.ArticleEditTask{},
 		&models.ArticlePublishConfig{},
+		&models.ArticleEditRevision{},
+		&models.ArticlePlatformPublish{},
+		&models.ArticleScheduledPublish{},
+		&models.WechatAuthorizer{},
 		&models.ArticleTask{},
 		&models.ArticleTopic{},
 		&models.TaskErrorLog{},
@@ -74,6 +82,15 @@ func AutoMigrate() error {
 		&models.ChatRecord{},
 		&models.Reservation{},
 		&models.MarketingActivityPlan{},
+		&models.ShareLink{},
+		// 短图文相关
+		&short_post.ShortPostProject{},
+		&short_post.ShortPostProjectContent{},
+		&short_post.ShortPostExportJob{},
+		&short_post.ShortPostTemplate{},
+		&short_post.ShortPostTemplateMember{},
+		&short_post.ShortPostBatchJob{},
+		&short_post.ShortPostBatchRow{},
 		// 其他模型（如果有的话，继续添加）
 	)
 }
@@ -2,6 +2,8 @@ package repository
 
 import (
 	"01agent_server/internal/models"
+	"crypto/sha256"
+	"encoding/hex"
 	"time"
 
 	"gorm.io/gorm"
@@ -18,8 +20,18 @@ func NewUserSessionRepository() *UserSessionRepository {
 	}
 }
 
+// HashToken 计算token的SHA-256摘要（十六进制）
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 // Create 创建会话
 func (r *UserSessionRepository) Create(session *models.UserSession) error {
+	if session.Token != nil && *session.Token != "" && session.TokenHash == nil {
+		hash := HashToken(*session.Token)
+		session.TokenHash = &hash
+	}
 	return r.db.Create(session).Error
 }
 
@@ -40,6 +52,90 @@ func (r *UserSessionRepository) GetByToken(token string) (*models.UserSession, e
 	return &session, nil
 }
 
+// GetByIDAndUserID 根据ID获取用户的会话
+func (r *UserSessionRepository) GetByIDAndUserID(sessionID int, userID string) (*models.UserSession, error) {
+	var session models.UserSession
+	err := r.db.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetByTokenHash 根据token摘要获取会话（包含已停用的会话）
+func (r *UserSessionRepository) GetByTokenHash(tokenHash string) (*models.UserSession, error) {
+	var session models.UserSession
+	err := r.db.Where("token_hash = ?", tokenHash).Order("id DESC").First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// BackfillTokenHash 为历史会话补齐token摘要，返回补齐后的活跃会话
+// 摘要字段上线前创建的会话只存有原始token
+func (r *UserSessionRepository) BackfillTokenHash(token string) (*models.UserSession, error) {
+	var session models.UserSession
+	err := r.db.Where("token = ? AND status = ? AND token_hash IS NULL", token, 1).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	hash := HashToken(token)
+	if err := r.db.Model(&models.UserSession{}).
+		Where("token = ? AND token_hash IS NULL", token).
+		Update("token_hash", hash).Error; err != nil {
+		return nil, err
+	}
+	session.TokenHash = &hash
+	return &session, nil
+}
+
+// GetByRefreshFamily 根据refresh token家族标识获取会话（包含已停用的会话）
+func (r *UserSessionRepository) GetByRefreshFamily(family string) (*models.UserSession, error) {
+	var session models.UserSession
+	err := r.db.Where("refresh_family = ?", family).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// UpdateFields 更新会话字段
+func (r *UserSessionRepository) UpdateFields(sessionID int, fields map[string]interface{}) error {
+	return r.db.Model(&models.UserSession{}).
+		Where("id = ?", sessionID).
+		Updates(fields).Error
+}
+
+// RotateRefreshToken 以当前refresh token摘要为条件更新会话，返回是否更新成功
+// 并发刷新时只有一个请求能成功轮换
+func (r *UserSessionRepository) RotateRefreshToken(sessionID int, refreshTokenHash string, fields map[string]interface{}) (bool, error) {
+	result := r.db.Model(&models.UserSession{}).
+		Where("id = ? AND status = ? AND refresh_token_hash = ?", sessionID, 1, refreshTokenHash).
+		Updates(fields)
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetTokenHashesByUserID 获取用户所有会话的token摘要
+func (r *UserSessionRepository) GetTokenHashesByUserID(userID string) ([]string, error) {
+	var hashes []string
+	err := r.db.Model(&models.UserSession{}).
+		Where("user_id = ? AND token_hash IS NOT NULL", userID).
+		Pluck("token_hash", &hashes).Error
+	return hashes, err
+}
+
+// DeactivateByID 停用用户的指定会话，返回是否有会话被停用
+func (r *UserSessionRepository) DeactivateByID(userID string, sessionID int) (bool, error) {
+	result := r.db.Model(&models.UserSession{}).
+		Where("id = ? AND user_id = ? AND status = ?", sessionID, userID, 1).
+		Updates(map[string]interface{}{
+			"status": 0,
+			"token":  nil,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
 // DeactivateByToken 根据令牌停用会话
 func (r *UserSessionRepository) DeactivateByToken(token string) error {
 	return r.db.Model(&models.UserSession{}).
@@ -128,6 +224,36 @@ func (r *UserSessionRepository) UpdateLastActiveTime(sessionID int) error {
 		Update("last_active_time", time.Now()).Error
 }
 
+// GetLatestActiveSessionByDevice 获取用户在指定设备上最新的活跃会话（用于同设备复用token）
+func (r *UserSessionRepository) GetLatestActiveSessionByDevice(userID string, deviceID string) (*models.UserSession, error) {
+	var session models.UserSession
+	err := r.db.Where("user_id = ? AND device_id = ? AND status = ? AND token IS NOT NULL AND token != ''", userID, deviceID, 1).
+		Order("created_at DESC").
+		First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// HasSessionFromDeviceOrIP 用户是否有过来自指定设备或IP的会话（含已下线的会话）
+func (r *UserSessionRepository) HasSessionFromDeviceOrIP(userID, deviceID, ipAddress string) (bool, error) {
+	query := r.db.Model(&models.UserSession{}).Where("user_id = ?", userID)
+	switch {
+	case deviceID != "" && ipAddress != "":
+		query = query.Where("device_id = ? OR ip_address = ?", deviceID, ipAddress)
+	case deviceID != "":
+		query = query.Where("device_id = ?", deviceID)
+	case ipAddress != "":
+		query = query.Where("ip_address = ?", ipAddress)
+	default:
+		return false, nil
+	}
+	var count int64
+	err := query.Count(&count).Error
+	return count > 0, err
+}
+
 // GetLatestActiveSession 获取用户最新的活跃会话（用于复用token）
 func (r *UserSessionRepository) GetLatestActiveSession(userID string) (*models.UserSession, error) {
 	var session models.UserSession
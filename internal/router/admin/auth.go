@@ -8,6 +8,7 @@ import (
 	"01agent_server/internal/middleware"
 	"01agent_server/internal/models"
 	"01agent_server/internal/repository"
+	"01agent_server/internal/service"
 	"01agent_server/internal/tools"
 
 	"github.com/gin-gonic/gin"
@@ -196,77 +197,26 @@ func (h *AdminHandler) AdminLogin(c *gin.Context) {
 		"updated_at": user.UpdatedAt.Format(time.RFC3339),
 	}
 
-	// 获取客户端IP
-	ipAddress := c.ClientIP()
-
-	// 查找是否有已登录可用的session
-	sessionRepo := repository.NewUserSessionRepository()
-	activeSessions, err := sessionRepo.GetActiveSessionsByUserID(user.UserID)
-	if err != nil {
-		repository.Errorf("Failed to get active sessions: %v", err)
+	// 创建会话并签发 access token 与 refresh token，会话创建失败视为登录失败
+	usernameStr := tools.GetStringValue(user.Username)
+	if usernameStr == "" {
+		usernameStr = user.UserID
 	}
-
-	var token string
-	var existingSession *models.UserSession
-
-	// 查找最新的活跃会话（按创建时间降序，取第一个）
-	if len(activeSessions) > 0 {
-		existingSession = &activeSessions[0]
-		if existingSession.Token != nil {
-			// 验证token是否过期
-			expired, err := tools.IsTokenExpired(*existingSession.Token)
-			if err != nil {
-				repository.Errorf("Failed to check token expiry: %v", err)
-				// 如果检查失败，视为过期，创建新token
-				expired = true
-			}
-
-			if !expired {
-				// 如果token未过期，复用token，更新活跃时间
-				existingSession.LastActiveTime = time.Now()
-				if err := sessionRepo.UpdateLastActiveTime(existingSession.ID); err != nil {
-					repository.Errorf("Failed to update session: %v", err)
-				} else {
-					token = *existingSession.Token
-				}
-			} else {
-				// 如果token已过期，使旧session失效
-				repository.Infof("Token expired, deactivating old session and creating new one")
-				if err := sessionRepo.DeactivateByToken(*existingSession.Token); err != nil {
-					repository.Errorf("Failed to deactivate old session: %v", err)
-				}
-			}
-		}
+	sessionSvc := service.NewSessionService()
+	tokens, err := sessionSvc.CreateSession(&models.UserSession{
+		UserID:    user.UserID,
+		LoginType: "web",
+		IPAddress: c.ClientIP(),
+	}, usernameStr)
+	if err != nil {
+		repository.Errorf("Failed to create session: %v", err)
+		middleware.HandleError(c, middleware.NewBusinessError(500, "创建登录会话失败"))
+		return
 	}
 
-	// 如果没有可用的session或token已过期，创建新的session
-	if token == "" {
-		// 生成token
-		usernameStr := tools.GetStringValue(user.Username)
-		if usernameStr == "" {
-			usernameStr = user.UserID
-		}
-		token, err = tools.GenerateToken(user.UserID, usernameStr)
-		if err != nil {
-			repository.Errorf("Failed to generate token: %v", err)
-			middleware.HandleError(c, middleware.NewBusinessError(500, "生成token失败"))
-			return
-		}
-
-		// 创建新的session
-		session := &models.UserSession{
-			UserID:         user.UserID,
-			Token:          tools.StringPtr(token),
-			LoginType:      "web",
-			IPAddress:      ipAddress,
-			Status:         1,
-			LastActiveTime: time.Now(),
-		}
-
-		if err := sessionRepo.Create(session); err != nil {
-			repository.Errorf("Failed to create session: %v", err)
-			// 即使创建session失败，也返回token（不影响登录）
-		}
+	// 按用户等级保留在线会话数量
+	if _, err := sessionSvc.CleanupSessionsKeepRecent(user.UserID, service.GetMaxSessions(user)); err != nil {
+		repository.Warnf("Failed to cleanup sessions for user %s: %v", user.UserID, err)
 	}
 
 	// 更新最后登录时间
@@ -277,8 +227,11 @@ func (h *AdminHandler) AdminLogin(c *gin.Context) {
 
 	// 返回响应
 	response := gin.H{
-		"token": token,
-		"user":  userInfo,
+		"token":              tokens.AccessToken,
+		"refresh_token":      tokens.RefreshToken,
+		"expires_in":         tokens.ExpiresIn,
+		"refresh_expires_in": tokens.RefreshExpiresIn,
+		"user":               userInfo,
 	}
 
 	middleware.Success(c, "登录成功", response)
@@ -264,3 +264,42 @@ func (h *CacheHandler) CreateCache(c *gin.Context) {
 		"created":    response.Created,
 	})
 }
+
+// GetMarkdownCacheStats 获取Markdown渲染缓存统计
+// @Summary 获取Markdown渲染缓存统计
+// @Description 获取本实例主题配置缓存、渲染结果缓存与Redis二级缓存的容量和命中、未命中、淘汰次数
+// @Tags admin-cache
+// @Accept json
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/admin/cache/markdown/stats [get]
+func (h *CacheHandler) GetMarkdownCacheStats(c *gin.Context) {
+	middleware.Success(c, "获取Markdown缓存统计成功", h.cacheService.GetMarkdownCacheStats())
+}
+
+// ClearMarkdownCache 清除Markdown渲染缓存
+// @Summary 清除Markdown渲染缓存
+// @Description 清空本实例的主题配置与渲染结果缓存，include_redis为true时同时清除Redis中的渲染结果
+// @Tags admin-cache
+// @Accept json
+// @Produce json
+// @Param body body cache.ClearMarkdownCacheRequest false "清除Markdown缓存请求"
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/admin/cache/markdown/clear [post]
+func (h *CacheHandler) ClearMarkdownCache(c *gin.Context) {
+	var req cache.ClearMarkdownCacheRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			middleware.HandleError(c, middleware.NewBusinessError(400, "参数错误: "+err.Error()))
+			return
+		}
+	}
+
+	response, err := h.cacheService.ClearMarkdownCache(&req)
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(500, err.Error()))
+		return
+	}
+
+	middleware.Success(c, "清除Markdown缓存成功", response)
+}
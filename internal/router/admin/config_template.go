@@ -8,6 +8,7 @@ import (
 	"01agent_server/internal/middleware"
 	"01agent_server/internal/models"
 	"01agent_server/internal/repository"
+	"01agent_server/internal/tools"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -228,6 +229,11 @@ func (h *AdminHandler) SaveConfigTemplate(c *gin.Context) {
 		template.TemplateType = models.ConfigTemplateTypeCustom
 	}
 
+	if result := tools.ValidateEmbeddedThemeConfig(req.ConfigData); result != nil && !result.Valid {
+		middleware.HandleErrorWithData(c, middleware.NewBusinessError(400, "主题配置校验失败: "+result.Summary()), result)
+		return
+	}
+
 	if req.ConfigData != nil {
 		configDataBytes, err := json.Marshal(req.ConfigData)
 		if err != nil {
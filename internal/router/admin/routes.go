@@ -285,6 +285,11 @@ func SetupAdminRoutes(r *gin.Engine) {
 		tradeGroup.PUT("/:id", adminHandler.UpdateTrade)
 		tradeGroup.DELETE("/:id", adminHandler.DeleteTrade)
 		tradeGroup.POST("/repair-incomplete", adminHandler.RepairIncompleteTrades)
+		tradeGroup.POST("/reconcile-trades", adminHandler.ReconcileTrades)
+		tradeGroup.POST("/reconcile", adminHandler.ReconcileStaleTrades)
+		tradeGroup.POST("/:id/refund", adminHandler.RefundTrade)
+		tradeGroup.GET("/:id/refunds", adminHandler.GetTradeRefunds)
+		tradeGroup.POST("/refunds/:refund_id/retry", adminHandler.RetryTradeRefund)
 		tradeGroup.GET("/user-overview/:user_id", adminHandler.GetUserTradeOverview)
 	}
 	// Trade V2 列表查询接口（需要管理员权限）
@@ -640,6 +645,8 @@ func SetupAdminRoutes(r *gin.Engine) {
 		cacheGroup.POST("/clear", cacheHandler.ClearCache)
 		cacheGroup.PUT("/update", cacheHandler.UpdateCache)
 		cacheGroup.DELETE("/delete", cacheHandler.DeleteCache)
+		cacheGroup.GET("/markdown/stats", cacheHandler.GetMarkdownCacheStats)
+		cacheGroup.POST("/markdown/clear", cacheHandler.ClearMarkdownCache)
 	}
 
 	// 配置模板管理接口（需要管理员权限）
@@ -163,9 +163,14 @@ func (h *AdminHandler) CreatePublicTemplate(c *gin.Context) {
 		}
 	}
 
-	// 处理template_data
+	// 处理template_data，保存前按主题 Schema 校验并迁移到当前版本
 	if req.TemplateData != nil {
-		if templateDataJSON, err := json.Marshal(req.TemplateData); err == nil {
+		templateData, result := tools.NormalizeThemeConfig(req.TemplateData)
+		if !result.Valid {
+			middleware.HandleErrorWithData(c, middleware.NewBusinessError(400, "主题配置校验失败: "+result.Summary()), result)
+			return
+		}
+		if templateDataJSON, err := json.Marshal(templateData); err == nil {
 			templateDataStr := string(templateDataJSON)
 			template.TemplateData = &templateDataStr
 		}
@@ -182,14 +187,11 @@ func (h *AdminHandler) CreatePublicTemplate(c *gin.Context) {
 		middleware.HandleError(c, middleware.NewBusinessError(500, "创建失败: "+err.Error()))
 		return
 	}
+	// 之前按该ID渲染时缓存的是默认配置
+	tools.InvalidateThemeCache(template.TemplateID)
 
-	// 生成缩略图HTML
-	sectionHTML := ""
-	thumbnailContent := tools.GetThumbnailContent()
-	processor := tools.NewUnifiedMarkdownProcessor()
-	if processedHTML, err := processor.ProcessMarkdown(thumbnailContent, template.TemplateID); err == nil {
-		sectionHTML = processedHTML
-	}
+	// 生成并保存缩略图HTML
+	sectionHTML := savePublicTemplateSectionHTML(&template)
 
 	nameEn := tools.GetStringValue(template.NameEn)
 	if nameEn == "" {
@@ -730,8 +732,13 @@ func (h *AdminHandler) UpdatePublicTemplate(c *gin.Context) {
 	}
 
 	if req.TemplateData != nil {
+		templateData, result := tools.NormalizeThemeConfig(req.TemplateData)
+		if !result.Valid {
+			middleware.HandleErrorWithData(c, middleware.NewBusinessError(400, "主题配置校验失败: "+result.Summary()), result)
+			return
+		}
 		// 将template_data转换为JSON字符串
-		if templateDataJSON, err := json.Marshal(req.TemplateData); err == nil {
+		if templateDataJSON, err := json.Marshal(templateData); err == nil {
 			templateDataStr := string(templateDataJSON)
 			updates["template_data"] = &templateDataStr
 			// 如果更新了模板数据，清除section_html以便重新生成
@@ -755,6 +762,7 @@ func (h *AdminHandler) UpdatePublicTemplate(c *gin.Context) {
 		middleware.HandleError(c, middleware.NewBusinessError(500, "更新失败: "+err.Error()))
 		return
 	}
+	tools.InvalidateThemeCache(templateID)
 
 	// 重新查询以获取最新数据
 	if err := repository.DB.Where("template_id = ?", templateID).First(&template).Error; err != nil {
@@ -762,14 +770,10 @@ func (h *AdminHandler) UpdatePublicTemplate(c *gin.Context) {
 		return
 	}
 
-	// 生成新的section_html
+	// 模板数据更新后section_html已被清空，重新生成并保存
 	sectionHTML := tools.GetStringValue(template.SectionHTML)
 	if sectionHTML == "" {
-		thumbnailContent := tools.GetThumbnailContent()
-		processor := tools.NewUnifiedMarkdownProcessor()
-		if processedHTML, err := processor.ProcessMarkdown(thumbnailContent, template.TemplateID); err == nil {
-			sectionHTML = processedHTML
-		}
+		sectionHTML = savePublicTemplateSectionHTML(&template)
 	}
 
 	middleware.Success(c, "模板更新成功", gin.H{
@@ -812,6 +816,7 @@ func (h *AdminHandler) DeletePublicTemplate(c *gin.Context) {
 		middleware.HandleError(c, middleware.NewBusinessError(500, "删除失败: "+err.Error()))
 		return
 	}
+	tools.InvalidateThemeCache(templateID)
 
 	middleware.Success(c, "模板已删除", gin.H{
 		"id":          templateID,
@@ -820,3 +825,17 @@ func (h *AdminHandler) DeletePublicTemplate(c *gin.Context) {
 		"deleted_at":  time.Now().Format("2006-01-02T15:04:05Z07:00"),
 	})
 }
+
+// savePublicTemplateSectionHTML 渲染缩略图内容并保存为模板的section_html
+// 保存失败时仍返回生成的HTML，列表接口会在字段为空时重新生成
+func savePublicTemplateSectionHTML(template *models.PublicTemplate) string {
+	sectionHTML, err := tools.NewUnifiedMarkdownProcessor().ProcessMarkdown(tools.GetThumbnailContent(), template.TemplateID)
+	if err != nil {
+		return ""
+	}
+	if err := repository.DB.Model(template).Update("section_html", sectionHTML).Error; err != nil {
+		repository.Warnf("Save section_html for template %s failed: %v", template.TemplateID, err)
+	}
+	template.SectionHTML = &sectionHTML
+	return sectionHTML
+}
@@ -8,8 +8,7 @@ import (
 	"01agent_server/internal/middleware"
 	"01agent_server/internal/models"
 	"01agent_server/internal/repository"
-	"01agent_server/internal/service"
-	"01agent_server/internal/tools"
+	"01agent_server/internal/service/payment"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -493,9 +492,6 @@ func (h *AdminHandler) UpdateTrade(c *gin.Context) {
 	if req.PaymentChannel != nil {
 		updates["payment_channel"] = *req.PaymentChannel
 	}
-	if req.PaymentStatus != nil {
-		updates["payment_status"] = *req.PaymentStatus
-	}
 	if req.Title != nil {
 		updates["title"] = *req.Title
 	}
@@ -506,15 +502,52 @@ func (h *AdminHandler) UpdateTrade(c *gin.Context) {
 		}
 	}
 
-	if len(updates) == 0 {
+	statusChanged := req.PaymentStatus != nil && *req.PaymentStatus != trade.PaymentStatus
+	if len(updates) == 0 && !statusChanged {
 		middleware.HandleError(c, middleware.NewBusinessError(400, "至少需要提供一个要更新的字段"))
 		return
 	}
 
-	if err := repository.DB.Model(&trade).Updates(updates).Error; err != nil {
+	// 支付状态必须按状态机流转，置为成功时与支付回调共用同一履约流程
+	if statusChanged {
+		to := models.PaymentStatus(*req.PaymentStatus)
+		if !payment.CanTransition(models.PaymentStatus(trade.PaymentStatus), to) {
+			middleware.HandleError(c, middleware.NewBusinessError(400, fmt.Sprintf("订单状态不能从 %s 变更为 %s", trade.PaymentStatus, to)))
+			return
+		}
+	}
+
+	// 字段更新与状态变更在同一事务中完成，均以订单版本号作为乐观锁条件
+	machine := payment.NewTradeStateMachine()
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := machine.Update(tx, &trade, updates); err != nil {
+				return err
+			}
+		}
+		if !statusChanged {
+			return nil
+		}
+		to := models.PaymentStatus(*req.PaymentStatus)
+		var fields map[string]interface{}
+		if to == models.PaymentStatusSuccess {
+			fields = map[string]interface{}{"paid_at": time.Now()}
+		}
+		return machine.Transition(tx, &trade, to, fields)
+	})
+	if err != nil {
+		if err == payment.ErrTradeConcurrentUpdate {
+			middleware.HandleError(c, middleware.NewBusinessError(409, err.Error()))
+			return
+		}
 		middleware.HandleError(c, middleware.NewBusinessError(500, "更新失败: "+err.Error()))
 		return
 	}
+	if statusChanged && models.PaymentStatus(*req.PaymentStatus) == models.PaymentStatusSuccess {
+		// 置为成功时已写入履约事件，立即投递以便管理员马上看到权益发放结果
+		repository.Warnf("Trade %s marked as paid by admin", trade.TradeNo)
+		payment.GetOutbox().DispatchTrade(trade.ID)
+	}
 
 	// 重新加载
 	repository.DB.First(&trade, id)
@@ -558,9 +591,14 @@ func (h *AdminHandler) DeleteTrade(c *gin.Context) {
 }
 
 // RepairIncompleteTrades 修复未完成订单
+// 逐个向支付渠道查询订单，渠道确认已支付的订单走与回调相同的状态流转与履约流程；
+// force 为 true 时渠道未确认（或不支持查询）的订单也会强制置为成功；
+// 旧版接口总是强制补单，未传 force 时默认为 true 以保持兼容，传 false 时只处理渠道确认已支付的订单
+// 返回本次实际发放了权益的订单及权益变更，需要每个订单对账结果时使用 ReconcileTrades
 func (h *AdminHandler) RepairIncompleteTrades(c *gin.Context) {
 	var req struct {
 		TradeNos []string `json:"trade_nos" binding:"required"`
+		Force    *bool    `json:"force"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -568,96 +606,58 @@ func (h *AdminHandler) RepairIncompleteTrades(c *gin.Context) {
 		return
 	}
 
-	fixList := make([]gin.H, 0)
-	benefitService := service.NewBenefitService()
-	processedUserIDs := make(map[string]bool) // 记录已处理的用户ID，避免重复清理缓存
-
-	// 处理每个 trade_no
-	for _, tradeNo := range req.TradeNos {
-		var trade models.Trade
-		if err := repository.DB.Where("trade_no = ?", tradeNo).First(&trade).Error; err != nil {
-			continue
-		}
-
-		// 加载用户信息
-		loadUserInfo(&trade)
-
-		// 如果已经是成功状态，跳过
-		if trade.PaymentStatus == "success" {
-			// 即使已经是成功状态，也记录用户ID以便清理缓存
-			if trade.UserID != "" {
-				processedUserIDs[trade.UserID] = true
-			}
-			continue
-		}
-
-		// 更新为成功状态
-		now := time.Now()
-		if err := repository.DB.Model(&trade).Updates(map[string]interface{}{
-			"payment_status": "success",
-			"paid_at":        &now,
-		}).Error; err != nil {
-			continue
-		}
-
-		// 记录用户ID（订单状态已更新，需要清理缓存）
-		if trade.UserID != "" {
-			processedUserIDs[trade.UserID] = true
-		}
-
-		// 重新加载 trade 以获取更新后的 paid_at
-		repository.DB.First(&trade, trade.ID)
-
-		// 解析 metadata 获取产品信息
-		var metadata map[string]interface{}
-		if trade.Metadata != nil {
-			json.Unmarshal([]byte(*trade.Metadata), &metadata)
-		}
-
-		productID, _ := metadata["product_id"].(float64)
-		if productID > 0 {
-			var product models.Production
-			if err := repository.DB.Where("id = ?", int(productID)).First(&product).Error; err == nil {
-				// 处理权益变更
-				benefitChanges := gin.H{}
-				if trade.User.UserID != "" {
-					user := trade.User
-					changes, err := benefitService.ProcessBenefitChanges(&user, &product, &trade)
-					if err == nil {
-						benefitChanges = gin.H{
-							"old_credits":            changes["old_credits"],
-							"new_credits":            changes["new_credits"],
-							"old_vip_level":          changes["old_vip_level"],
-							"new_vip_level":          changes["new_vip_level"],
-							"monthly_credits_issued": changes["monthly_credits_issued"],
-							"total_timed_credits":    changes["total_timed_credits"],
-							"total_monthly_credits":  changes["total_monthly_credits"],
-							"total_credits":          changes["total_credits"],
-							"changes":                changes["changes"],
-						}
-					}
-				}
+	force := req.Force == nil || *req.Force
+	_, repaired := payment.NewService().RepairTrades(req.TradeNos, force)
+	fixList := make([]gin.H, 0, len(repaired))
+	for _, item := range repaired {
+		changes := item.BenefitChanges
+		fixList = append(fixList, gin.H{
+			"user_id":      item.User.UserID,
+			"user_phone":   item.User.Phone,
+			"user_name":    item.User.Username,
+			"product_name": item.Product.Name,
+			"product_type": item.Product.ProductType,
+			"benefit_changes": gin.H{
+				"old_credits":            changes["old_credits"],
+				"new_credits":            changes["new_credits"],
+				"old_vip_level":          changes["old_vip_level"],
+				"new_vip_level":          changes["new_vip_level"],
+				"monthly_credits_issued": changes["monthly_credits_issued"],
+				"total_timed_credits":    changes["total_timed_credits"],
+				"total_monthly_credits":  changes["total_monthly_credits"],
+				"total_credits":          changes["total_credits"],
+				"changes":                changes["changes"],
+			},
+		})
+	}
 
-				fixList = append(fixList, gin.H{
-					"user_id":         trade.UserID,
-					"user_phone":      trade.User.Phone,
-					"user_name":       trade.User.Username,
-					"product_name":    product.Name,
-					"product_type":    product.ProductType,
-					"benefit_changes": benefitChanges,
-				})
+	middleware.Success(c, "修复完成", fixList)
+}
 
-				// 用户ID已在订单状态更新时记录，这里不需要重复记录
-			}
-		}
+// ReconcileTrades 按订单号向支付渠道对账，返回每个订单的对账结果，force 含义同 RepairIncompleteTrades
+func (h *AdminHandler) ReconcileTrades(c *gin.Context) {
+	var req struct {
+		TradeNos []string `json:"trade_nos" binding:"required"`
+		Force    bool     `json:"force"`
 	}
 
-	// 清理所有相关用户的 Redis 缓存（DB3）
-	for userID := range processedUserIDs {
-		tools.ClearUserCacheAsync(userID)
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(400, "参数错误: "+err.Error()))
+		return
 	}
 
-	middleware.Success(c, "修复完成", fixList)
+	results := payment.NewService().ReconcileTrades(req.TradeNos, req.Force)
+	middleware.Success(c, "对账完成", results)
+}
+
+// ReconcileStaleTrades 立即执行一次待支付订单自动对账（与定时任务逻辑相同）
+func (h *AdminHandler) ReconcileStaleTrades(c *gin.Context) {
+	results, err := payment.NewService().ReconcileStale()
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(500, "对账失败: "+err.Error()))
+		return
+	}
+	middleware.Success(c, "对账完成", results)
 }
 
 // GetProductList 获取产品列表
@@ -0,0 +1,95 @@
+package admin
+
+import (
+	"fmt"
+
+	"01agent_server/internal/middleware"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/service/payment"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RefundTrade 订单退款（支持全额与部分退款）
+// 退款受理后会按比例扣回该订单发放的积分，全额退款时撤销会员并冲正佣金
+func (h *AdminHandler) RefundTrade(c *gin.Context) {
+	var id int
+	if _, err := fmt.Sscanf(c.Param("id"), "%d", &id); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(400, "ID格式错误"))
+		return
+	}
+
+	var req struct {
+		Amount  float64 `json:"amount"` // 不传或为0时退还剩余全部金额
+		Reason  string  `json:"reason" binding:"required,max=256"`
+		Offline bool    `json:"offline"` // 已线下退款，仅登记并回收权益
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(400, "参数错误: "+err.Error()))
+		return
+	}
+
+	operatorID, _ := middleware.GetCurrentUserID(c)
+	refund, err := payment.NewService().RefundTrade(&payment.RefundTradeRequest{
+		TradeID:    id,
+		Amount:     req.Amount,
+		Reason:     req.Reason,
+		Offline:    req.Offline,
+		OperatorID: operatorID,
+	})
+	if err != nil {
+		handleRefundError(c, err)
+		return
+	}
+
+	middleware.Success(c, "退款已受理", refund)
+}
+
+// GetTradeRefunds 获取订单的退款记录
+func (h *AdminHandler) GetTradeRefunds(c *gin.Context) {
+	var id int
+	if _, err := fmt.Sscanf(c.Param("id"), "%d", &id); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(400, "ID格式错误"))
+		return
+	}
+
+	refunds, err := payment.NewService().ListTradeRefunds(id)
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(500, err.Error()))
+		return
+	}
+
+	middleware.Success(c, "success", refunds)
+}
+
+// RetryTradeRefund 重试未完成的退款（渠道结果未知、本地登记失败或权益回收未完成）
+func (h *AdminHandler) RetryTradeRefund(c *gin.Context) {
+	var refundID int
+	if _, err := fmt.Sscanf(c.Param("refund_id"), "%d", &refundID); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(400, "ID格式错误"))
+		return
+	}
+
+	refund, err := payment.NewService().RetryRefund(refundID)
+	if err != nil {
+		handleRefundError(c, err)
+		return
+	}
+
+	middleware.Success(c, "重试完成", refund)
+}
+
+// handleRefundError 将退款错误映射为业务错误码
+func handleRefundError(c *gin.Context, err error) {
+	switch err {
+	case payment.ErrTradeNotFound, payment.ErrRefundNotFound:
+		middleware.HandleError(c, middleware.NewBusinessError(404, err.Error()))
+	case payment.ErrRefundNotAllowed, payment.ErrRefundAmountInvalid, payment.ErrOfflineRefundRequired:
+		middleware.HandleError(c, middleware.NewBusinessError(400, err.Error()))
+	case payment.ErrRefundInProgress, payment.ErrTradeConcurrentUpdate:
+		middleware.HandleError(c, middleware.NewBusinessError(409, err.Error()))
+	default:
+		repository.Errorf("Refund trade failed: %v", err)
+		middleware.HandleError(c, middleware.NewBusinessError(500, "退款失败: "+err.Error()))
+	}
+}
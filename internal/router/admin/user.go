@@ -1449,56 +1449,27 @@ func (h *AdminHandler) GetUserToken(c *gin.Context) {
 		return
 	}
 
-	// 获取用户最近登录认证凭证token
-	sessionRepo := repository.NewUserSessionRepository()
-	activeSessions, err := sessionRepo.GetActiveSessionsByUserID(userID)
+	// 为管理员单独创建会话并签发令牌，不复用用户自己设备上的会话，
+	// 这样管理员下线该会话或令牌刷新都不会影响用户本人的登录
+	usernameStr := tools.GetStringValue(user.Username)
+	if usernameStr == "" {
+		usernameStr = user.UserID
+	}
+	tokens, err := service.NewSessionService().CreateSession(&models.UserSession{
+		UserID:    user.UserID,
+		LoginType: "admin",
+		IPAddress: c.ClientIP(),
+	}, usernameStr)
 	if err != nil {
-		repository.Errorf("查询用户会话失败: %v", err)
-		middleware.HandleError(c, middleware.NewBusinessError(500, "查询用户会话失败"))
+		repository.Errorf("创建会话失败: %v", err)
+		middleware.HandleError(c, middleware.NewBusinessError(500, "创建会话失败"))
 		return
 	}
 
-	var token string
-
-	// 如果找到活跃的session，使用现有的token
-	if len(activeSessions) > 0 && activeSessions[0].Token != nil {
-		token = *activeSessions[0].Token
-	} else {
-		// 如果没有找到活跃的session，创建新的token和session
-		usernameStr := tools.GetStringValue(user.Username)
-		if usernameStr == "" {
-			usernameStr = user.UserID
-		}
-
-		// 生成token
-		generatedToken, err := tools.GenerateToken(user.UserID, usernameStr)
-		if err != nil {
-			repository.Errorf("生成token失败: %v", err)
-			middleware.HandleError(c, middleware.NewBusinessError(500, "生成token失败"))
-			return
-		}
-		token = generatedToken
-
-		// 获取客户端IP
-		ipAddress := c.ClientIP()
-
-		// 创建新的session
-		session := &models.UserSession{
-			UserID:         user.UserID,
-			Token:          tools.StringPtr(token),
-			LoginType:      "web",
-			IPAddress:      ipAddress,
-			Status:         1,
-			LastActiveTime: time.Now(),
-		}
-
-		if err := sessionRepo.Create(session); err != nil {
-			repository.Errorf("创建会话失败: %v", err)
-			// 即使创建session失败，也返回token（确保不为空）
-		}
-	}
-
 	middleware.Success(c, "获取用户token成功", gin.H{
-		"token": token,
+		"token":              tokens.AccessToken,
+		"refresh_token":      tokens.RefreshToken,
+		"expires_in":         tokens.ExpiresIn,
+		"refresh_expires_in": tokens.RefreshExpiresIn,
 	})
 }
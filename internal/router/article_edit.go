@@ -2,15 +2,19 @@
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"regexp"
+	"strconv"
 	"time"
 
 	"01agent_server/internal/middleware"
 	"01agent_server/internal/models"
 	"01agent_server/internal/repository"
 	"01agent_server/internal/service"
+	"01agent_server/internal/service/wechatmp"
+	"01agent_server/internal/tools"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -20,12 +24,16 @@ import (
 type ArticleEditHandler struct {
 	db             *gorm.DB
 	articleEditSvc *service.ArticleEditService
+	revisionSvc    *service.ArticleRevisionService
+	presenceSvc    *service.EditPresenceService
 }
 
 func NewArticleEditHandler() *ArticleEditHandler {
 	return &ArticleEditHandler{
 		db:             repository.DB,
 		articleEditSvc: service.NewArticleEditService(),
+		revisionSvc:    service.NewArticleRevisionService(),
+		presenceSvc:    service.NewEditPresenceService(),
 	}
 }
 
@@ -88,6 +96,9 @@ type ArticleEditResponse struct {
 	CreatedAt     string                 `json:"created_at"`
 	UpdatedAt     string                 `json:"updated_at"`
 	PublishedAt   *string                `json:"published_at"`
+	EditVersion   int                    `json:"edit_version"` // 保存时通过 If-Match 带回，用于并发冲突检测
+	// RenderReport 重新生成section_html时，当前内容发布到公众号的兼容性报告
+	RenderReport *tools.WechatRenderReport `json:"render_report,omitempty"`
 }
 
 func (h *ArticleEditHandler) convertToResponse(editTask *models.ArticleEditTask, previewMode bool, articleTask *models.ArticleTask) (*ArticleEditResponse, error) {
@@ -152,6 +163,7 @@ func (h *ArticleEditHandler) convertToResponse(editTask *models.ArticleEditTask,
 		AuthorName:    authorName,
 		CreatedAt:     editTask.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:     editTask.UpdatedAt.Format(time.RFC3339),
+		EditVersion:   editTask.EditVersion,
 	}
 
 	if editTask.PublishedAt != nil {
@@ -388,7 +400,7 @@ func (h *ArticleEditHandler) CreateEditTask(c *gin.Context) {
 	updates["is_public"] = req.IsPublic
 
 	if len(updates) > 0 {
-		if err := h.db.Model(&editTask).Updates(updates).Error; err != nil {
+		if err := h.revisionSvc.UpdateWithRevision(&editTask, updates, nil); err != nil {
 			middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("Update failed: %v", err)))
 			return
 		}
@@ -396,11 +408,22 @@ func (h *ArticleEditHandler) CreateEditTask(c *gin.Context) {
 		h.db.Where("id = ?", editTask.ID).First(&editTask)
 	}
 
+	// 新建任务或内容、主题变化时重新生成section_html
+	var report *tools.WechatRenderReport
+	_, contentChanged := updates["content"]
+	_, themeChanged := updates["theme"]
+	if editTask.SectionHTML == nil || contentChanged || themeChanged {
+		if report, err = h.articleEditSvc.UpdateSectionHTML(&editTask); err != nil {
+			repository.Warnf("CreateEditTask: update section_html failed: %v", err)
+		}
+	}
+
 	data, err := h.convertToResponse(&editTask, true, &articleTask)
 	if err != nil {
 		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("Convert failed: %v", err)))
 		return
 	}
+	data.RenderReport = report
 
 	middleware.Success(c, "Success", data)
 }
@@ -480,10 +503,12 @@ func (h *ArticleEditHandler) GetEditTask(c *gin.Context) {
 		return
 	}
 
+	middleware.SetVersionETag(c, editTask.EditVersion)
 	middleware.Success(c, "Success", data)
 }
 
 // UpdateEditTask PUT /article-edit/:edit_task_id
+// 携带 If-Match 时只有编辑版本号一致才保存，否则返回 409 和服务端当前内容
 func (h *ArticleEditHandler) UpdateEditTask(c *gin.Context) {
 	editTaskID := c.Param("edit_task_id")
 	userID, _ := middleware.GetCurrentUserID(c)
@@ -493,6 +518,11 @@ func (h *ArticleEditHandler) UpdateEditTask(c *gin.Context) {
 		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, fmt.Sprintf("Invalid params: %v", err)))
 		return
 	}
+	expectedVersion, err := middleware.IfMatchVersion(c)
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, err.Error()))
+		return
+	}
 
 	var editTask models.ArticleEditTask
 	if err := h.db.Where("id = ? AND user_id = ?", editTaskID, userID).First(&editTask).Error; err != nil {
@@ -529,22 +559,63 @@ func (h *ArticleEditHandler) UpdateEditTask(c *gin.Context) {
 		}
 	}
 
-	if err := h.db.Model(&editTask).Updates(updates).Error; err != nil {
+	if err := h.revisionSvc.UpdateWithRevision(&editTask, updates, expectedVersion); err != nil {
+		if errors.Is(err, service.ErrEditConflict) {
+			h.handleEditConflict(c, editTaskID)
+			return
+		}
 		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("Update failed: %v", err)))
 		return
 	}
 
 	h.db.Where("id = ?", editTaskID).First(&editTask)
 
+	// 内容或主题变化时重新生成section_html
+	var report *tools.WechatRenderReport
+	if req.Content != nil || req.Theme != nil {
+		var err error
+		if report, err = h.articleEditSvc.UpdateSectionHTML(&editTask); err != nil {
+			repository.Warnf("UpdateEditTask: update section_html failed: %v", err)
+		}
+	}
+
 	data, err := h.convertToResponse(&editTask, false, nil)
 	if err != nil {
 		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("Convert failed: %v", err)))
 		return
 	}
+	data.RenderReport = report
 
+	middleware.SetVersionETag(c, editTask.EditVersion)
 	middleware.Success(c, "Success", data)
 }
 
+// GetRenderReport GET /article-edit/:edit_task_id/render-report
+// 检查当前内容在公众号兼容模式下被改写或移除的结构，不修改已保存的section_html
+func (h *ArticleEditHandler) GetRenderReport(c *gin.Context) {
+	editTaskID := c.Param("edit_task_id")
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	var editTask models.ArticleEditTask
+	if err := h.db.Where("id = ? AND user_id = ?", editTaskID, userID).First(&editTask).Error; err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusNotFound, "Edit task not found"))
+		return
+	}
+
+	theme := c.DefaultQuery("theme", editTask.Theme)
+	if theme == "" || theme == "none" {
+		theme = "default"
+	}
+
+	_, report, err := h.articleEditSvc.ConvertMarkdownToHTML(editTask.Content, theme, service.RenderModeWechat)
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("Render failed: %v", err)))
+		return
+	}
+
+	middleware.Success(c, "Success", report)
+}
+
 // DeleteEditTask DELETE /article-edit/:edit_task_id
 func (h *ArticleEditHandler) DeleteEditTask(c *gin.Context) {
 	editTaskID := c.Param("edit_task_id")
@@ -598,11 +669,36 @@ func (h *ArticleEditHandler) GetPublishStatus(c *gin.Context) {
 		return
 	}
 
+	// 已提交发布但尚未有结果时，向公众号查询最新状态
+	var publishResult *wechatmp.PublishResult
+	if editTask.WechatPublishID != nil && editTask.Status != models.ArticleEditStatusPublished {
+		result, err := h.articleEditSvc.RefreshPublishStatus(&editTask)
+		if err != nil {
+			repository.Warnf("Refresh wechat publish status failed: edit_task_id=%s, err=%v", editTaskID, err)
+		} else if result != nil {
+			publishResult = result
+			h.db.Where("id = ?", editTaskID).First(&editTask)
+		}
+	}
+
 	statusInfo := gin.H{
-		"task_id": editTaskID,
-		"status":  editTask.Status,
+		"task_id":            editTaskID,
+		"status":             editTask.Status,
+		"publish_error":      editTask.PublishError,
+		"wechat_media_id":    editTask.WechatMediaID,
+		"wechat_publish_id":  editTask.WechatPublishID,
+		"wechat_article_url": editTask.WechatArticleURL,
+	}
+	if publishResult != nil {
+		statusInfo["wechat_publish_status"] = publishResult.PublishStatus
+		statusInfo["wechat_publish_message"] = publishResult.StatusMessage()
 	}
 
+	// 多平台发布记录
+	var platformPublishes []models.ArticlePlatformPublish
+	h.db.Where("edit_task_id = ?", editTaskID).Order("id ASC").Find(&platformPublishes)
+	statusInfo["platforms"] = platformPublishes
+
 	if editTask.PublishedAt != nil {
 		statusInfo["published_at"] = editTask.PublishedAt.Format(time.RFC3339)
 	}
@@ -613,9 +709,17 @@ func (h *ArticleEditHandler) GetPublishStatus(c *gin.Context) {
 	case models.ArticleEditStatusPending:
 		statusInfo["message"] = "正在发布中..."
 	case models.ArticleEditStatusDraft:
-		statusInfo["message"] = "文章已同步到公众号草稿箱"
+		if publishResult != nil && publishResult.Pending() {
+			statusInfo["message"] = "文章已提交发布，等待公众号审核"
+		} else {
+			statusInfo["message"] = "文章已同步到公众号草稿箱"
+		}
 	case models.ArticleEditStatusEditing:
-		statusInfo["message"] = "文章正在编辑中..."
+		if editTask.PublishError != nil && *editTask.PublishError != "" {
+			statusInfo["message"] = "发布失败: " + *editTask.PublishError
+		} else {
+			statusInfo["message"] = "文章正在编辑中..."
+		}
 	default:
 		statusInfo["message"] = fmt.Sprintf("未知状态: %s", editTask.Status)
 	}
@@ -725,6 +829,210 @@ func (h *ArticleEditHandler) GetPublishConfig(c *gin.Context) {
 	})
 }
 
+// ListRevisions GET /article-edit/:edit_task_id/revisions
+// 版本保存的是每次覆盖前的内容，连续保存会合并为一个版本
+func (h *ArticleEditHandler) ListRevisions(c *gin.Context) {
+	editTask, ok := h.loadOwnedEditTask(c)
+	if !ok {
+		return
+	}
+
+	revisions, err := h.revisionSvc.List(editTask.ID)
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("Query failed: %v", err)))
+		return
+	}
+
+	middleware.Success(c, "Success", gin.H{
+		"edit_task_id": editTask.ID,
+		"current": gin.H{
+			"title":      editTask.Title,
+			"theme":      editTask.Theme,
+			"updated_at": editTask.UpdatedAt.Format(time.RFC3339),
+		},
+		"items": revisions,
+		"total": len(revisions),
+	})
+}
+
+// GetRevision GET /article-edit/:edit_task_id/revisions/:revision
+func (h *ArticleEditHandler) GetRevision(c *gin.Context) {
+	editTask, ok := h.loadOwnedEditTask(c)
+	if !ok {
+		return
+	}
+	revision, err := strconv.Atoi(c.Param("revision"))
+	if err != nil || revision < 0 {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, "Invalid revision"))
+		return
+	}
+
+	rev, err := h.revisionSvc.Get(editTask, revision)
+	if err != nil {
+		h.handleRevisionError(c, err)
+		return
+	}
+	middleware.Success(c, "Success", rev)
+}
+
+// DiffRevisions GET /article-edit/:edit_task_id/revisions/diff?from=1&to=0&mode=line
+// 版本号 0 表示当前内容，to 默认为当前内容；mode 为 line 按行或 word 按词比较
+func (h *ArticleEditHandler) DiffRevisions(c *gin.Context) {
+	editTask, ok := h.loadOwnedEditTask(c)
+	if !ok {
+		return
+	}
+	from, errFrom := strconv.Atoi(c.Query("from"))
+	to, errTo := strconv.Atoi(c.DefaultQuery("to", "0"))
+	if errFrom != nil || errTo != nil || from < 0 || to < 0 {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, "Invalid revision"))
+		return
+	}
+
+	diff, err := h.revisionSvc.Diff(editTask, from, to, c.DefaultQuery("mode", service.RevisionDiffModeLine))
+	if err != nil {
+		h.handleRevisionError(c, err)
+		return
+	}
+	middleware.Success(c, "Success", diff)
+}
+
+// RestoreRevision POST /article-edit/:edit_task_id/revisions/:revision/restore
+// 恢复前的内容会保存为新版本，恢复操作本身也可撤销
+func (h *ArticleEditHandler) RestoreRevision(c *gin.Context) {
+	editTask, ok := h.loadOwnedEditTask(c)
+	if !ok {
+		return
+	}
+	revision, err := strconv.Atoi(c.Param("revision"))
+	if err != nil || revision < 1 {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, "Invalid revision"))
+		return
+	}
+	expectedVersion, err := middleware.IfMatchVersion(c)
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	if err := h.revisionSvc.Restore(editTask, revision, expectedVersion); err != nil {
+		if errors.Is(err, service.ErrEditConflict) {
+			h.handleEditConflict(c, editTask.ID)
+			return
+		}
+		h.handleRevisionError(c, err)
+		return
+	}
+
+	h.db.Where("id = ?", editTask.ID).First(editTask)
+	report, err := h.articleEditSvc.UpdateSectionHTML(editTask)
+	if err != nil {
+		repository.Warnf("RestoreRevision: update section_html failed: %v", err)
+	}
+
+	data, err := h.convertToResponse(editTask, false, nil)
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("Convert failed: %v", err)))
+		return
+	}
+	data.RenderReport = report
+
+	middleware.SetVersionETag(c, editTask.EditVersion)
+	middleware.Success(c, "Success", data)
+}
+
+// EditPresenceRequest 协同编辑心跳请求
+type EditPresenceRequest struct {
+	ClientID string `json:"client_id" binding:"required"` // 页面标识，同一用户的多个标签页各不相同
+	Lock     bool   `json:"lock"`                         // 是否获取编辑锁，false 时释放自己持有的锁
+}
+
+// EditPresenceHeartbeat POST /article-edit/:edit_task_id/presence
+// 页面按 heartbeat_interval 定期调用，返回其他正在编辑的页面、编辑锁持有者与最新编辑版本号
+func (h *ArticleEditHandler) EditPresenceHeartbeat(c *gin.Context) {
+	editTask, ok := h.loadOwnedEditTask(c)
+	if !ok {
+		return
+	}
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	var req EditPresenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, fmt.Sprintf("Invalid params: %v", err)))
+		return
+	}
+
+	state, err := h.presenceSvc.Heartbeat(service.EditResourceArticle, editTask.ID, userID, req.ClientID, req.Lock)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidClientID) {
+			middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, err.Error()))
+			return
+		}
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("Presence failed: %v", err)))
+		return
+	}
+
+	middleware.SetVersionETag(c, editTask.EditVersion)
+	middleware.Success(c, "Success", gin.H{
+		"edit_version": editTask.EditVersion,
+		"presence":     state,
+	})
+}
+
+// LeaveEditPresence DELETE /article-edit/:edit_task_id/presence?client_id=xxx
+func (h *ArticleEditHandler) LeaveEditPresence(c *gin.Context) {
+	editTask, ok := h.loadOwnedEditTask(c)
+	if !ok {
+		return
+	}
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	if err := h.presenceSvc.Leave(service.EditResourceArticle, editTask.ID, userID, c.Query("client_id")); err != nil {
+		if errors.Is(err, service.ErrInvalidClientID) {
+			middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, err.Error()))
+			return
+		}
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("Presence failed: %v", err)))
+		return
+	}
+	middleware.Success(c, "Success", nil)
+}
+
+// handleEditConflict 保存冲突时返回 409 和服务端当前的完整内容
+func (h *ArticleEditHandler) handleEditConflict(c *gin.Context, editTaskID string) {
+	var current models.ArticleEditTask
+	if err := h.db.Where("id = ?", editTaskID).First(&current).Error; err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("Query failed: %v", err)))
+		return
+	}
+	data, err := h.convertToResponse(&current, true, nil)
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("Convert failed: %v", err)))
+		return
+	}
+	middleware.SetVersionETag(c, current.EditVersion)
+	middleware.HandleConflict(c, service.ErrEditConflict.Error(), data)
+}
+
+func (h *ArticleEditHandler) loadOwnedEditTask(c *gin.Context) (*models.ArticleEditTask, bool) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	var editTask models.ArticleEditTask
+	if err := h.db.Where("id = ? AND user_id = ?", c.Param("edit_task_id"), userID).First(&editTask).Error; err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusNotFound, "Edit task not found"))
+		return nil, false
+	}
+	return &editTask, true
+}
+
+func (h *ArticleEditHandler) handleRevisionError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrRevisionNotFound) {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusNotFound, "Revision not found"))
+		return
+	}
+	middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("Revision operation failed: %v", err)))
+}
+
 func SetupArticleEditRoutes(r *gin.Engine) {
 	handler := NewArticleEditHandler()
 	articleEdit := r.Group("/api/v1/article-edit")
@@ -740,10 +1048,17 @@ func SetupArticleEditRoutes(r *gin.Engine) {
 		articleEditWithAuth.GET("/drafts", handler.GetEditDrafts)
 		articleEditWithAuth.GET("/:edit_task_id", handler.GetEditTask)
 		articleEditWithAuth.PUT("/:edit_task_id", handler.UpdateEditTask)
+		articleEditWithAuth.GET("/:edit_task_id/render-report", handler.GetRenderReport)
 		articleEditWithAuth.DELETE("/:edit_task_id", handler.DeleteEditTask)
 		articleEditWithAuth.PUT("/:edit_task_id/publish", handler.PublishEditTask)
 		articleEditWithAuth.GET("/:edit_task_id/publish-status", handler.GetPublishStatus)
 		articleEditWithAuth.POST("/:edit_task_id/publish-config", handler.SavePublishConfig)
 		articleEditWithAuth.GET("/:edit_task_id/publish-config", handler.GetPublishConfig)
+		articleEditWithAuth.GET("/:edit_task_id/revisions", handler.ListRevisions)
+		articleEditWithAuth.GET("/:edit_task_id/revisions/diff", handler.DiffRevisions)
+		articleEditWithAuth.GET("/:edit_task_id/revisions/:revision", handler.GetRevision)
+		articleEditWithAuth.POST("/:edit_task_id/revisions/:revision/restore", handler.RestoreRevision)
+		articleEditWithAuth.POST("/:edit_task_id/presence", handler.EditPresenceHeartbeat)
+		articleEditWithAuth.DELETE("/:edit_task_id/presence", handler.LeaveEditPresence)
 	}
 }
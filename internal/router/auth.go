@@ -14,13 +14,15 @@ import (
 )
 
 type AuthHandler struct {
-	userService *service.UserService
+	userService       *service.UserService
+	verifyCodeService *service.VerifyCodeService
 }
 
 // NewAuthHandler 创建认证处理器
 func NewAuthHandler() *AuthHandler {
 	return &AuthHandler{
-		userService: service.NewUserService(),
+		userService:       service.NewUserService(),
+		verifyCodeService: service.NewVerifyCodeService(),
 	}
 }
 
@@ -66,6 +68,7 @@ type LoginRequest struct {
 	Identifier string `json:"identifier" binding:"required"` // 标识符（手机号、邮箱、用户名或openid）
 	InviteCode string `json:"invite_code"`                   // 邀请码（可选）
 	UtmSource  string `json:"utm_source"`                    // 用户来源渠道（可选）
+	Code       string `json:"code"`                          // 验证码（手机号/邮箱登录未预先验证时必填）
 }
 
 // Login 用户登录 - 对应Python的/auth/login接口
@@ -77,7 +80,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	// 获取客户端IP
-	ipAddress := getClientIP(c)
+	ipAddress := c.ClientIP()
 	// 获取设备ID
 	deviceID := c.GetHeader("X-Device-ID")
 
@@ -94,6 +97,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		Identifier: req.Identifier,
 		InviteCode: req.InviteCode,
 		UtmSource:  req.UtmSource,
+		Code:       req.Code,
 	}
 
 	// 调用服务层登录（支持自动创建用户）
@@ -149,13 +153,49 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	// 返回响应（与Python版本格式一致：code: 0, msg, data）
 	c.JSON(200, models.SuccessResponse(result.LoginMsg, gin.H{
-		"token":        token,
-		"user":         userInfo,
-		"session":      sessionInfo,
-		"max_sessions": result.MaxSessions,
+		"token":              token,
+		"refresh_token":      result.Tokens.RefreshToken,
+		"expires_in":         result.Tokens.ExpiresIn,
+		"refresh_expires_in": result.Tokens.RefreshExpiresIn,
+		"user":               userInfo,
+		"session":            sessionInfo,
+		"max_sessions":       result.MaxSessions,
 	}))
 }
 
+// RefreshToken 使用 refresh token 换取新的 access token 与 refresh token
+// 每个 refresh token 只能使用一次，旧令牌被重放时该设备会被强制下线
+func (h *AuthHandler) RefreshToken(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(400, "缺少refresh_token"))
+		return
+	}
+
+	tokens, err := h.userService.RefreshTokens(req.RefreshToken)
+	if err != nil {
+		switch err {
+		case service.ErrRefreshTokenInvalid, service.ErrRefreshTokenExpired, service.ErrRefreshTokenReused:
+			middleware.HandleError(c, middleware.NewBusinessError(401, err.Error()))
+		case service.ErrRefreshTokenRotated:
+			middleware.HandleError(c, middleware.NewBusinessError(409, err.Error()))
+		default:
+			repository.Errorf("Refresh token failed: %v", err)
+			middleware.HandleError(c, middleware.NewBusinessError(500, "刷新令牌失败"))
+		}
+		return
+	}
+
+	middleware.Success(c, "刷新成功", gin.H{
+		"token":              tokens.AccessToken,
+		"refresh_token":      tokens.RefreshToken,
+		"expires_in":         tokens.ExpiresIn,
+		"refresh_expires_in": tokens.RefreshExpiresIn,
+	})
+}
+
 // Logout 用户登出 - 对应Python的/auth/logout (PUT)
 func (h *AuthHandler) Logout(c *gin.Context) {
 	userID, exists := middleware.GetCurrentUserID(c)
@@ -227,8 +267,11 @@ func (h *AuthHandler) BindPhone(c *gin.Context) {
 		return
 	}
 
-	// TODO: 验证验证码
-	// 这里需要集成验证码验证逻辑
+	// 验证短信验证码
+	if err := h.verifyCodeService.VerifySMSCode(req.Phone, req.Code); err != nil {
+		c.JSON(400, models.ErrorResponse(400, err.Error()))
+		return
+	}
 
 	// 调用服务层绑定手机号
 	user, err := h.userService.BindPhone(req.Phone, req.Identifier)
@@ -273,10 +316,12 @@ func (h *AuthHandler) SendCode(c *gin.Context) {
 		return
 	}
 
-	// TODO: 检查是否频繁发送（使用Redis）
-	// TODO: 发送验证码（集成SMS服务）
+	// 频率限制、生成并发送验证码
+	if err := h.verifyCodeService.SendSMSCode(areaCode, phone, c.ClientIP()); err != nil {
+		h.handleVerifyCodeError(c, service.VerifyChannelSMS, phone, err)
+		return
+	}
 
-	// 模拟成功响应
 	c.JSON(200, gin.H{
 		"code": 0,
 		"msg":  "验证码发送成功",
@@ -303,8 +348,10 @@ func (h *AuthHandler) VerifyCode(c *gin.Context) {
 		return
 	}
 
-	// TODO: 从Redis获取验证码并验证
-	// 这里需要集成Redis验证逻辑
+	if err := h.verifyCodeService.VerifySMSCode(req.Phone, req.Code); err != nil {
+		h.handleVerifyCodeError(c, service.VerifyChannelSMS, req.Phone, err)
+		return
+	}
 
 	c.JSON(200, gin.H{
 		"code": 0,
@@ -365,7 +412,10 @@ func (h *AuthHandler) SendEmailCode(c *gin.Context) {
 		return
 	}
 
-	// TODO: 实现邮箱验证码发送逻辑
+	if err := h.verifyCodeService.SendEmailCode(email, c.ClientIP()); err != nil {
+		h.handleVerifyCodeError(c, service.VerifyChannelEmail, email, err)
+		return
+	}
 
 	c.JSON(200, gin.H{
 		"code": 0,
@@ -387,7 +437,10 @@ func (h *AuthHandler) VerifyEmailCode(c *gin.Context) {
 		return
 	}
 
-	// TODO: 实现邮箱验证码验证逻辑
+	if err := h.verifyCodeService.VerifyEmailCode(req.Email, req.Code); err != nil {
+		h.handleVerifyCodeError(c, service.VerifyChannelEmail, req.Email, err)
+		return
+	}
 
 	c.JSON(200, gin.H{
 		"code": 0,
@@ -395,22 +448,20 @@ func (h *AuthHandler) VerifyEmailCode(c *gin.Context) {
 	})
 }
 
-// getClientIP 获取客户端真实IP地址
-func getClientIP(c *gin.Context) string {
-	// 按优先级依次尝试获取真实IP
-	if xRealIP := c.GetHeader("X-Real-IP"); xRealIP != "" {
-		return xRealIP
-	}
-	if xForwardedFor := c.GetHeader("X-Forwarded-For"); xForwardedFor != "" {
-		// X-Forwarded-For 可能包含多个IP，第一个是客户端真实IP
-		for i := 0; i < len(xForwardedFor); i++ {
-			if xForwardedFor[i] == ',' {
-				return xForwardedFor[:i]
-			}
-		}
-		return xForwardedFor
+// handleVerifyCodeError 返回验证码相关错误，频率限制类错误使用429并附带剩余等待时间
+func (h *AuthHandler) handleVerifyCodeError(c *gin.Context, channel service.VerifyChannel, target string, err error) {
+	switch err {
+	case service.ErrCodeSendTooFrequent:
+		c.JSON(400, models.NewResponse(429, err.Error(), gin.H{
+			"retry_after": h.verifyCodeService.ResendWaitSeconds(channel, target),
+		}))
+	case service.ErrCodeTargetDailyLimit, service.ErrCodeIPDailyLimit, service.ErrCodeTooManyAttempts:
+		c.JSON(400, models.ErrorResponse(429, err.Error()))
+	case service.ErrCodeServiceUnavailable:
+		c.JSON(400, models.ErrorResponse(503, err.Error()))
+	default:
+		c.JSON(400, models.ErrorResponse(400, err.Error()))
 	}
-	return c.ClientIP()
 }
 
 // isDigit 检查字符串是否全为数字
@@ -453,6 +504,8 @@ func SetupAuthRoutes(r *gin.Engine, authHandler *AuthHandler) {
 		authPublic.POST("/verify-email-code", authHandler.VerifyEmailCode)
 		// 绑定手机号
 		authPublic.POST("/bind-phone", authHandler.BindPhone)
+		// 刷新令牌（access token过期后仍可调用）
+		authPublic.POST("/refresh", authHandler.RefreshToken)
 	}
 
 	// 需要认证的路由
@@ -0,0 +1,235 @@
+package router
+
+import (
+	"strconv"
+	"time"
+
+	"01agent_server/internal/middleware"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreditsHandler struct {
+	ledgerService *service.CreditLedgerService
+}
+
+// NewCreditsHandler 创建积分处理器
+func NewCreditsHandler() *CreditsHandler {
+	return &CreditsHandler{
+		ledgerService: service.NewCreditLedgerService(),
+	}
+}
+
+// ConsumeCreditsRequest 积分消费请求
+type ConsumeCreditsRequest struct {
+	ServiceCode string  `json:"service_code" binding:"required"`
+	Units       float64 `json:"units"` // 计费数量，默认为1
+}
+
+// Consume 消费积分 - POST /api/v1/credits/consume
+func (h *CreditsHandler) Consume(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		middleware.HandleError(c, middleware.NewBusinessError(401, "未授权访问"))
+		return
+	}
+
+	var req ConsumeCreditsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(400, "参数错误: "+err.Error()))
+		return
+	}
+	if req.Units == 0 {
+		req.Units = 1
+	}
+
+	result, err := h.ledgerService.Consume(userID, req.ServiceCode, req.Units)
+	if err != nil {
+		switch err {
+		case service.ErrServicePriceNotFound:
+			middleware.HandleError(c, middleware.NewBusinessError(404, err.Error()))
+		case service.ErrInsufficientCredits:
+			middleware.HandleError(c, middleware.NewBusinessError(402, err.Error()))
+		case service.ErrInvalidUnits:
+			middleware.HandleError(c, middleware.NewBusinessError(400, err.Error()))
+		default:
+			repository.Errorf("Consume credits failed: user=%s, service=%s, err=%v", userID, req.ServiceCode, err)
+			middleware.HandleError(c, middleware.NewBusinessError(500, "积分扣减失败: "+err.Error()))
+		}
+		return
+	}
+
+	middleware.Success(c, "积分扣减成功", result)
+}
+
+// GetBalance 获取积分余额明细 - GET /api/v1/credits/balance
+func (h *CreditsHandler) GetBalance(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		middleware.HandleError(c, middleware.NewBusinessError(401, "未授权访问"))
+		return
+	}
+
+	balance, err := h.ledgerService.GetBalance(nil, userID)
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(500, "获取积分余额失败: "+err.Error()))
+		return
+	}
+
+	middleware.Success(c, "获取积分余额成功", balance)
+}
+
+// HoldCreditsRequest 冻结积分请求
+type HoldCreditsRequest struct {
+	ServiceCode string  `json:"service_code" binding:"required"`
+	Units       float64 `json:"units"`       // 预估计费数量，默认为1
+	BizType     string  `json:"biz_type"`    // 业务类型
+	BizID       string  `json:"biz_id"`      // 业务ID
+	TTLSeconds  int     `json:"ttl_seconds"` // 冻结时长（秒），默认30分钟
+}
+
+// CaptureCreditsRequest 结算预扣请求
+type CaptureCreditsRequest struct {
+	ActualUnits *float64 `json:"actual_units" binding:"required"` // 为0时释放预扣
+}
+
+// Hold 冻结积分 - POST /api/v1/credits/reservations
+func (h *CreditsHandler) Hold(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		middleware.HandleError(c, middleware.NewBusinessError(401, "未授权访问"))
+		return
+	}
+
+	var req HoldCreditsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(400, "参数错误: "+err.Error()))
+		return
+	}
+	if req.Units == 0 {
+		req.Units = 1
+	}
+
+	reservation, err := h.ledgerService.Hold(userID, req.ServiceCode, req.Units, service.HoldOptions{
+		BizType: req.BizType,
+		BizID:   req.BizID,
+		TTL:     time.Duration(req.TTLSeconds) * time.Second,
+	})
+	if err != nil {
+		h.handleLedgerError(c, userID, err)
+		return
+	}
+
+	middleware.Success(c, "积分冻结成功", reservation)
+}
+
+// GetReservation 获取预扣详情 - GET /api/v1/credits/reservations/:id
+func (h *CreditsHandler) GetReservation(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		middleware.HandleError(c, middleware.NewBusinessError(401, "未授权访问"))
+		return
+	}
+
+	reservationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(400, "无效的预扣ID"))
+		return
+	}
+
+	reservation, err := h.ledgerService.GetReservation(userID, reservationID)
+	if err != nil {
+		h.handleLedgerError(c, userID, err)
+		return
+	}
+
+	middleware.Success(c, "获取积分预扣成功", reservation)
+}
+
+// Capture 按实际用量结算预扣 - POST /api/v1/credits/reservations/:id/capture
+func (h *CreditsHandler) Capture(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		middleware.HandleError(c, middleware.NewBusinessError(401, "未授权访问"))
+		return
+	}
+
+	reservationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(400, "无效的预扣ID"))
+		return
+	}
+
+	var req CaptureCreditsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(400, "参数错误: "+err.Error()))
+		return
+	}
+
+	result, err := h.ledgerService.Capture(userID, reservationID, *req.ActualUnits)
+	if err != nil {
+		h.handleLedgerError(c, userID, err)
+		return
+	}
+
+	middleware.Success(c, "积分结算成功", result)
+}
+
+// Release 释放预扣 - POST /api/v1/credits/reservations/:id/release
+func (h *CreditsHandler) Release(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		middleware.HandleError(c, middleware.NewBusinessError(401, "未授权访问"))
+		return
+	}
+
+	reservationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(400, "无效的预扣ID"))
+		return
+	}
+
+	if err := h.ledgerService.Release(userID, reservationID); err != nil {
+		h.handleLedgerError(c, userID, err)
+		return
+	}
+
+	middleware.Success(c, "积分释放成功", nil)
+}
+
+// handleLedgerError 将积分账本错误映射为业务错误码
+func (h *CreditsHandler) handleLedgerError(c *gin.Context, userID string, err error) {
+	switch err {
+	case service.ErrServicePriceNotFound, service.ErrReservationNotFound:
+		middleware.HandleError(c, middleware.NewBusinessError(404, err.Error()))
+	case service.ErrInsufficientCredits:
+		middleware.HandleError(c, middleware.NewBusinessError(402, err.Error()))
+	case service.ErrInvalidUnits:
+		middleware.HandleError(c, middleware.NewBusinessError(400, err.Error()))
+	case service.ErrReservationSettled:
+		middleware.HandleError(c, middleware.NewBusinessError(409, err.Error()))
+	default:
+		repository.Errorf("Credit ledger operation failed: user=%s, err=%v", userID, err)
+		middleware.HandleError(c, middleware.NewBusinessError(500, "积分操作失败: "+err.Error()))
+	}
+}
+
+// SetupCreditsRoutes 设置积分路由
+func SetupCreditsRoutes(r *gin.Engine) {
+	handler := NewCreditsHandler()
+
+	creditsGroup := r.Group("/api/v1/credits")
+	creditsGroup.Use(middleware.JWTAuth())
+	{
+		creditsGroup.POST("/consume", handler.Consume)
+		creditsGroup.GET("/balance", handler.GetBalance)
+
+		// 积分预扣（长耗时任务先冻结后结算）
+		creditsGroup.POST("/reservations", handler.Hold)
+		creditsGroup.GET("/reservations/:id", handler.GetReservation)
+		creditsGroup.POST("/reservations/:id/capture", handler.Capture)
+		creditsGroup.POST("/reservations/:id/release", handler.Release)
+	}
+}
@@ -0,0 +1,156 @@
+package router
+
+import (
+	"net/http"
+
+	"01agent_server/internal/middleware"
+	"01agent_server/internal/models"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/service/payment"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PaymentHandler struct {
+	paymentService *payment.Service
+}
+
+// NewPaymentHandler 创建支付处理器
+func NewPaymentHandler() *PaymentHandler {
+	return &PaymentHandler{
+		paymentService: payment.NewService(),
+	}
+}
+
+// CreateWxPayOrderRequest 微信支付下单请求
+type CreateWxPayOrderRequest struct {
+	ProductID      int    `json:"product_id" binding:"required"`
+	PaymentChannel string `json:"payment_channel" binding:"required"` // wx_qr / wx_pub / wx_lite
+	OpenID         string `json:"openid"`                             // wx_lite 必填，wx_pub 默认使用用户绑定的openid
+}
+
+// CreateWxPayOrder 微信支付下单 - POST /api/v1/payment/wxpay/order
+func (h *PaymentHandler) CreateWxPayOrder(c *gin.Context) {
+	var req CreateWxPayOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(400, "参数错误: "+err.Error()))
+		return
+	}
+	h.createOrder(c, payment.ProviderWxPay, req.ProductID, req.PaymentChannel, &payment.OrderOptions{OpenID: req.OpenID})
+}
+
+// CreateAlipayOrderRequest 支付宝下单请求
+type CreateAlipayOrderRequest struct {
+	ProductID      int    `json:"product_id" binding:"required"`
+	PaymentChannel string `json:"payment_channel" binding:"required"` // alipay_qr / alipay_wap / alipay
+}
+
+// CreateAlipayOrder 支付宝下单 - POST /api/v1/payment/alipay/order
+func (h *PaymentHandler) CreateAlipayOrder(c *gin.Context) {
+	var req CreateAlipayOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(400, "参数错误: "+err.Error()))
+		return
+	}
+	h.createOrder(c, payment.ProviderAlipay, req.ProductID, req.PaymentChannel, nil)
+}
+
+// createOrder 校验支付渠道属于指定服务商后下单
+func (h *PaymentHandler) createOrder(c *gin.Context, providerName string, productID int, channel string, opts *payment.OrderOptions) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		middleware.HandleError(c, middleware.NewBusinessError(401, "未授权访问"))
+		return
+	}
+
+	if payment.ProviderNameOf(models.PaymentChannel(channel)) != providerName {
+		h.handlePaymentError(c, payment.ErrUnsupportedPaymentChannel)
+		return
+	}
+
+	result, err := h.paymentService.CreateOrder(userID, productID, models.PaymentChannel(channel), opts)
+	if err != nil {
+		h.handlePaymentError(c, err)
+		return
+	}
+
+	middleware.Success(c, "下单成功", result)
+}
+
+// GetTrade 查询订单支付状态 - GET /api/v1/payment/trade/:trade_no
+// 订单仍为待支付时会主动向支付渠道查询一次，避免回调延迟
+func (h *PaymentHandler) GetTrade(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		middleware.HandleError(c, middleware.NewBusinessError(401, "未授权访问"))
+		return
+	}
+
+	trade, err := h.paymentService.GetUserTrade(userID, c.Param("trade_no"))
+	if err != nil {
+		h.handlePaymentError(c, err)
+		return
+	}
+
+	if synced, err := h.paymentService.SyncTrade(trade); err != nil {
+		repository.Warnf("Sync trade %s failed: %v", trade.TradeNo, err)
+	} else {
+		trade = synced
+	}
+
+	middleware.Success(c, "查询成功", trade.ToResponse())
+}
+
+// Notify 支付渠道异步通知 - POST /api/v1/payment/{wxpay|alipay}/notify
+// 应答格式由各服务商决定，处理失败时要求渠道重试
+func (h *PaymentHandler) Notify(providerName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provider, err := payment.GetProvider(providerName)
+		if err != nil {
+			repository.Errorf("Payment provider %s unavailable for notify: %v", providerName, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"code": "FAIL", "message": err.Error()})
+			return
+		}
+
+		err = h.paymentService.HandleNotify(provider, c.Request)
+		if err != nil {
+			repository.Errorf("Handle %s notify failed: %v", providerName, err)
+		}
+		provider.AckNotify(c.Writer, err)
+	}
+}
+
+// handlePaymentError 将支付错误映射为业务错误码
+func (h *PaymentHandler) handlePaymentError(c *gin.Context, err error) {
+	switch err {
+	case payment.ErrProductUnavailable, payment.ErrTradeNotFound:
+		middleware.HandleError(c, middleware.NewBusinessError(404, err.Error()))
+	case payment.ErrUnsupportedPaymentChannel, payment.ErrOpenIDRequired:
+		middleware.HandleError(c, middleware.NewBusinessError(400, err.Error()))
+	case payment.ErrWxPayNotConfigured, payment.ErrAlipayNotConfigured:
+		middleware.HandleError(c, middleware.NewBusinessError(503, err.Error()))
+	default:
+		repository.Errorf("Payment operation failed: %v", err)
+		middleware.HandleError(c, middleware.NewBusinessError(500, "支付失败: "+err.Error()))
+	}
+}
+
+// SetupPaymentRoutes 设置支付路由
+func SetupPaymentRoutes(r *gin.Engine) {
+	handler := NewPaymentHandler()
+
+	// 支付渠道回调（公开，依靠签名验证）
+	notifyGroup := r.Group("/api/v1/payment")
+	{
+		notifyGroup.POST("/wxpay/notify", handler.Notify(payment.ProviderWxPay))
+		notifyGroup.POST("/alipay/notify", handler.Notify(payment.ProviderAlipay))
+	}
+
+	paymentGroup := r.Group("/api/v1/payment")
+	paymentGroup.Use(middleware.JWTAuth())
+	{
+		paymentGroup.POST("/wxpay/order", handler.CreateWxPayOrder)
+		paymentGroup.POST("/alipay/order", handler.CreateAlipayOrder)
+		paymentGroup.GET("/trade/:trade_no", handler.GetTrade)
+	}
+}
@@ -0,0 +1,202 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+
+	"01agent_server/internal/middleware"
+	"01agent_server/internal/models"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/service/publisher"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PlatformPublishHandler 多平台文章发布
+type PlatformPublishHandler struct {
+	db         *gorm.DB
+	publishSvc *publisher.Service
+}
+
+// NewPlatformPublishHandler 创建多平台发布处理器
+func NewPlatformPublishHandler() *PlatformPublishHandler {
+	return &PlatformPublishHandler{
+		db:         repository.DB,
+		publishSvc: publisher.NewService(),
+	}
+}
+
+// PlatformPublishRequest 多平台发布请求
+type PlatformPublishRequest struct {
+	Platforms []string `json:"platforms" binding:"required"`
+	Mode      string   `json:"mode"` // draft 仅保存草稿 / publish 直接发布（默认）
+}
+
+// SavePlatformAccountRequest 绑定平台账号请求
+type SavePlatformAccountRequest struct {
+	OpenID      string            `json:"openid"`
+	Nickname    *string           `json:"nickname"`
+	Cookie      string            `json:"cookie"`
+	CSRFToken   string            `json:"csrf_token"`
+	AccessToken string            `json:"access_token"`
+	Options     map[string]string `json:"options"`
+}
+
+// ListAccounts 平台账号绑定状态 - GET /api/v1/publish/accounts
+func (h *PlatformPublishHandler) ListAccounts(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	accounts, err := h.publishSvc.ListAccounts(userID)
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(500, "查询平台账号失败: "+err.Error()))
+		return
+	}
+	middleware.Success(c, "获取成功", accounts)
+}
+
+// SaveAccount 绑定平台账号 - PUT /api/v1/publish/accounts/:platform
+func (h *PlatformPublishHandler) SaveAccount(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+	platform := models.ArticleSceneType(c.Param("platform"))
+
+	var req SavePlatformAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(400, "参数错误: "+err.Error()))
+		return
+	}
+
+	err := h.publishSvc.SaveAccount(userID, platform, req.OpenID, req.Nickname, &publisher.Credentials{
+		Cookie:      req.Cookie,
+		CSRFToken:   req.CSRFToken,
+		AccessToken: req.AccessToken,
+		Options:     req.Options,
+	})
+	if errors.Is(err, publisher.ErrCredentialsKeyMissing) {
+		repository.Errorf("Save platform account failed: %v", err)
+		middleware.HandleError(c, middleware.NewBusinessError(503, "平台账号绑定暂不可用"))
+		return
+	}
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(400, err.Error()))
+		return
+	}
+	middleware.Success(c, "绑定成功", nil)
+}
+
+// DeleteAccount 解除平台账号绑定 - DELETE /api/v1/publish/accounts/:platform
+func (h *PlatformPublishHandler) DeleteAccount(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+	platform := models.ArticleSceneType(c.Param("platform"))
+
+	if err := h.publishSvc.DeleteAccount(userID, platform); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(500, "解除绑定失败: "+err.Error()))
+		return
+	}
+	middleware.Success(c, "解除绑定成功", nil)
+}
+
+// Publish 发布到多个平台 - POST /api/v1/article-edit/:edit_task_id/platform-publish
+// 每个平台独立发布与重试，接口立即返回各平台的发布记录
+func (h *PlatformPublishHandler) Publish(c *gin.Context) {
+	editTaskID := c.Param("edit_task_id")
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	var req PlatformPublishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(400, "参数错误: "+err.Error()))
+		return
+	}
+	platforms, err := publisher.ParsePlatforms(req.Platforms)
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(400, err.Error()))
+		return
+	}
+	if !ownsEditTask(c, h.db, editTaskID, userID) {
+		return
+	}
+
+	records, err := h.publishSvc.Enqueue(userID, editTaskID, platforms, req.Mode)
+	if err != nil {
+		repository.Errorf("Enqueue platform publish failed: edit_task_id=%s, err=%v", editTaskID, err)
+		middleware.HandleError(c, middleware.NewBusinessError(500, "提交发布失败: "+err.Error()))
+		return
+	}
+	middleware.Success(c, "已提交发布", gin.H{
+		"edit_task_id": editTaskID,
+		"platforms":    records,
+	})
+}
+
+// ListPublishes 各平台发布状态 - GET /api/v1/article-edit/:edit_task_id/platform-publish
+func (h *PlatformPublishHandler) ListPublishes(c *gin.Context) {
+	editTaskID := c.Param("edit_task_id")
+	userID, _ := middleware.GetCurrentUserID(c)
+	if !ownsEditTask(c, h.db, editTaskID, userID) {
+		return
+	}
+
+	records, err := h.publishSvc.List(editTaskID)
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(500, "查询发布记录失败: "+err.Error()))
+		return
+	}
+	middleware.Success(c, "获取成功", gin.H{
+		"edit_task_id": editTaskID,
+		"platforms":    records,
+	})
+}
+
+// Retry 重试单个平台 - POST /api/v1/article-edit/:edit_task_id/platform-publish/:platform/retry
+func (h *PlatformPublishHandler) Retry(c *gin.Context) {
+	editTaskID := c.Param("edit_task_id")
+	userID, _ := middleware.GetCurrentUserID(c)
+	if !ownsEditTask(c, h.db, editTaskID, userID) {
+		return
+	}
+
+	record, err := h.publishSvc.Retry(editTaskID, models.ArticleSceneType(c.Param("platform")))
+	switch {
+	case errors.Is(err, publisher.ErrPublishRecordNotFound):
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusNotFound, err.Error()))
+		return
+	case errors.Is(err, publisher.ErrPublishInProgress):
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusConflict, err.Error()))
+		return
+	case err != nil:
+		middleware.HandleError(c, middleware.NewBusinessError(500, "重试失败: "+err.Error()))
+		return
+	}
+	middleware.Success(c, "已重新提交", record)
+}
+
+// ownsEditTask 校验编辑任务属于当前用户，不属于时直接返回 404
+func ownsEditTask(c *gin.Context, db *gorm.DB, editTaskID, userID string) bool {
+	var count int64
+	if err := db.Model(&models.ArticleEditTask{}).Where("id = ? AND user_id = ?", editTaskID, userID).Count(&count).Error; err != nil || count == 0 {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusNotFound, "Edit task not found"))
+		return false
+	}
+	return true
+}
+
+// SetupPlatformPublishRoutes 设置多平台发布路由
+func SetupPlatformPublishRoutes(r *gin.Engine) {
+	handler := NewPlatformPublishHandler()
+
+	accountGroup := r.Group("/api/v1/publish")
+	accountGroup.Use(middleware.JWTAuth())
+	{
+		accountGroup.GET("/accounts", handler.ListAccounts)
+		accountGroup.PUT("/accounts/:platform", handler.SaveAccount)
+		accountGroup.DELETE("/accounts/:platform", handler.DeleteAccount)
+	}
+
+	publishGroup := r.Group("/api/v1/article-edit")
+	publishGroup.Use(middleware.JWTAuth())
+	{
+		publishGroup.POST("/:edit_task_id/platform-publish", handler.Publish)
+		publishGroup.GET("/:edit_task_id/platform-publish", handler.ListPublishes)
+		publishGroup.POST("/:edit_task_id/platform-publish/:platform/retry", handler.Retry)
+	}
+}
@@ -8,9 +8,12 @@ import (
 	"01agent_server/internal/config"
 	"01agent_server/internal/middleware"
 	"01agent_server/internal/models"
+	"01agent_server/internal/repository"
 	"01agent_server/internal/router/admin"
 	"01agent_server/internal/router/digital"
 	"01agent_server/internal/router/short_post"
+	"01agent_server/internal/service"
+	"01agent_server/internal/service/storage"
 	utils "01agent_server/internal/tools"
 
 	"github.com/gin-gonic/gin"
@@ -22,6 +25,16 @@ func SetupRouter() *gin.Engine {
 	// 创建Gin实例
 	r := gin.New()
 
+	// 仅信任配置的反向代理转发的客户端IP，c.ClientIP() 用于验证码等按IP限流的场景
+	var trustedProxies []string
+	if config.AppConfig != nil {
+		trustedProxies = config.AppConfig.Server.TrustedProxies
+	}
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		repository.Errorf("Invalid trusted proxies %v: %v", trustedProxies, err)
+		_ = r.SetTrustedProxies(nil)
+	}
+
 	// 添加中间件
 	r.Use(middleware.LoggerMiddleware())
 	r.Use(middleware.ErrorHandler()) // 统一错误处理
@@ -52,6 +65,17 @@ func SetupRouter() *gin.Engine {
 	SetupSystemRoutes(r)               // 系统路由（反馈和通知）
 	SetupPromptTemplateRoutes(r)       // 提示词模板路由
 	SetupStylesRoutes(r)               // 样式主题路由
+	SetupCreditsRoutes(r)              // 积分消费路由
+	SetupPaymentRoutes(r)              // 支付路由
+	SetupWechatPlatformRoutes(r)       // 微信第三方平台路由
+	SetupPlatformPublishRoutes(r)      // 多平台发布路由
+	SetupScheduledPublishRoutes(r)     // 定时发布路由
+	SetupShareRoutes(r)                // 分享链接路由
+
+	// 未配置 OSS 时，服务端生成的文件（如短图文导出）保存在本地并由此提供访问
+	if _, ok := storage.Default().(*storage.Local); ok {
+		r.Static(storage.LocalURLPath(), storage.LocalDir())
+	}
 
 	// 健康检查
 	r.GET("/health", func(c *gin.Context) {
@@ -62,8 +86,10 @@ func SetupRouter() *gin.Engine {
 		})
 	})
 
-	// JWT调试接口
-	SetupJWTDebugRoutes(r)
+	// JWT调试接口（可签发任意用户的token，仅在配置开启时注册）
+	if config.AppConfig.JWT.EnableDebug {
+		SetupJWTDebugRoutes(r)
+	}
 
 	return r
 }
@@ -92,19 +118,24 @@ func SetupJWTDebugRoutes(r *gin.Engine) {
 				req.Username = "test_user"
 			}
 
-			// 生成token
-			token, err := utils.GenerateToken(req.UserID, req.Username)
+			// 与正常登录一样创建会话，否则认证中间件会因找不到会话拒绝该token
+			tokens, err := service.NewSessionService().CreateSession(&models.UserSession{
+				UserID:    req.UserID,
+				LoginType: "debug",
+				IPAddress: c.ClientIP(),
+			}, req.Username)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, models.ErrorResponse(500, "生成token失败: "+err.Error()))
 				return
 			}
 
 			// 获取token信息
-			info, _ := utils.GetTokenInfo(token)
+			info, _ := utils.GetTokenInfo(tokens.AccessToken)
 
 			middleware.Success(c, "测试token生成成功", gin.H{
-				"token": token,
-				"info":  info,
+				"token":         tokens.AccessToken,
+				"refresh_token": tokens.RefreshToken,
+				"info":          info,
 			})
 		})
 
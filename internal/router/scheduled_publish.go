@@ -0,0 +1,152 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"01agent_server/internal/middleware"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/service/scheduler"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ScheduledPublishHandler 编辑任务定时发布
+type ScheduledPublishHandler struct {
+	db          *gorm.DB
+	scheduleSvc *scheduler.Service
+}
+
+// NewScheduledPublishHandler 创建定时发布处理器
+func NewScheduledPublishHandler() *ScheduledPublishHandler {
+	return &ScheduledPublishHandler{
+		db:          repository.DB,
+		scheduleSvc: scheduler.NewService(),
+	}
+}
+
+// SchedulePublishRequest 设置定时发布请求
+type SchedulePublishRequest struct {
+	PublishAt string  `json:"publish_at" binding:"required"` // ISO8601 格式，需带时区
+	Mode      string  `json:"mode"`                          // draft 仅同步草稿箱 / publish 直接发布（默认）
+	TopicID   *string `json:"topic_id"`                      // 关联的选题计划
+}
+
+// SetSchedule 设置或修改定时发布 - PUT /api/v1/article-edit/:edit_task_id/schedule
+func (h *ScheduledPublishHandler) SetSchedule(c *gin.Context) {
+	editTaskID := c.Param("edit_task_id")
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	var req SchedulePublishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(400, "参数错误: "+err.Error()))
+		return
+	}
+	publishAt, err := time.Parse(time.RFC3339, req.PublishAt)
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(400, "发布时间格式错误，请使用ISO格式"))
+		return
+	}
+	if !ownsEditTask(c, h.db, editTaskID, userID) {
+		return
+	}
+
+	job, err := h.scheduleSvc.Schedule(userID, editTaskID, publishAt, req.Mode, req.TopicID)
+	switch {
+	case errors.Is(err, scheduler.ErrScheduleInPast):
+		middleware.HandleError(c, middleware.NewBusinessError(400, err.Error()))
+		return
+	case errors.Is(err, scheduler.ErrTopicNotFound):
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusNotFound, err.Error()))
+		return
+	case errors.Is(err, scheduler.ErrScheduleRunning):
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusConflict, err.Error()))
+		return
+	case err != nil:
+		repository.Errorf("Schedule publish failed: edit_task_id=%s, err=%v", editTaskID, err)
+		middleware.HandleError(c, middleware.NewBusinessError(500, "设置定时发布失败: "+err.Error()))
+		return
+	}
+	middleware.Success(c, "定时发布已设置", job)
+}
+
+// GetSchedule 查询定时发布 - GET /api/v1/article-edit/:edit_task_id/schedule
+func (h *ScheduledPublishHandler) GetSchedule(c *gin.Context) {
+	editTaskID := c.Param("edit_task_id")
+	userID, _ := middleware.GetCurrentUserID(c)
+	if !ownsEditTask(c, h.db, editTaskID, userID) {
+		return
+	}
+
+	job, err := h.scheduleSvc.Get(editTaskID)
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(500, "查询定时发布失败: "+err.Error()))
+		return
+	}
+	middleware.Success(c, "获取成功", job)
+}
+
+// CancelSchedule 取消定时发布 - DELETE /api/v1/article-edit/:edit_task_id/schedule
+func (h *ScheduledPublishHandler) CancelSchedule(c *gin.Context) {
+	editTaskID := c.Param("edit_task_id")
+	userID, _ := middleware.GetCurrentUserID(c)
+	if !ownsEditTask(c, h.db, editTaskID, userID) {
+		return
+	}
+
+	err := h.scheduleSvc.Cancel(editTaskID)
+	switch {
+	case errors.Is(err, scheduler.ErrScheduleNotFound):
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusNotFound, err.Error()))
+		return
+	case errors.Is(err, scheduler.ErrScheduleRunning):
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusConflict, err.Error()))
+		return
+	case err != nil:
+		middleware.HandleError(c, middleware.NewBusinessError(500, "取消定时发布失败: "+err.Error()))
+		return
+	}
+	middleware.Success(c, "已取消定时发布", nil)
+}
+
+// ListSchedules 当前用户的定时发布列表 - GET /api/v1/article-edit/schedules
+func (h *ScheduledPublishHandler) ListSchedules(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	jobs, total, err := h.scheduleSvc.List(userID, c.Query("status"), page, pageSize)
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(500, "查询定时发布失败: "+err.Error()))
+		return
+	}
+	middleware.Success(c, "获取成功", gin.H{
+		"items":     jobs,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// SetupScheduledPublishRoutes 设置定时发布路由
+func SetupScheduledPublishRoutes(r *gin.Engine) {
+	handler := NewScheduledPublishHandler()
+
+	scheduleGroup := r.Group("/api/v1/article-edit")
+	scheduleGroup.Use(middleware.JWTAuth())
+	{
+		scheduleGroup.GET("/schedules", handler.ListSchedules)
+		scheduleGroup.PUT("/:edit_task_id/schedule", handler.SetSchedule)
+		scheduleGroup.GET("/:edit_task_id/schedule", handler.GetSchedule)
+		scheduleGroup.DELETE("/:edit_task_id/schedule", handler.CancelSchedule)
+	}
+}
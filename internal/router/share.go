@@ -0,0 +1,243 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"01agent_server/internal/middleware"
+	"01agent_server/internal/models"
+	"01agent_server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sharePasswordHeader 访问有密码的分享链接时携带密码的请求头
+const sharePasswordHeader = "X-Share-Password"
+
+// ShareHandler 只读分享链接
+type ShareHandler struct {
+	shareSvc *service.ShareLinkService
+}
+
+// NewShareHandler 创建分享链接处理器
+func NewShareHandler() *ShareHandler {
+	return &ShareHandler{
+		shareSvc: service.NewShareLinkService(),
+	}
+}
+
+// CreateShareLinkRequest 创建分享链接请求
+type CreateShareLinkRequest struct {
+	ResourceType models.ShareResourceType `json:"resource_type" binding:"required"` // short_post_project / short_post_template / article_edit / user_template
+	ResourceID   string                   `json:"resource_id" binding:"required"`
+	Password     string                   `json:"password" binding:"max=32"`  // 访问密码，为空时无需密码
+	ExpiresIn    int                      `json:"expires_in" binding:"min=0"` // 有效期（小时），与 expires_at 二选一，都为空时永久有效
+	ExpiresAt    *time.Time               `json:"expires_at"`
+}
+
+// OpenShareRequest 打开有密码的分享链接请求
+type OpenShareRequest struct {
+	Password string `json:"password"`
+}
+
+// CreateShareLink 为自己的工程、编辑任务或模板创建只读分享链接 - POST /api/v1/share
+func (h *ShareHandler) CreateShareLink(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	var req CreateShareLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, fmt.Sprintf("参数错误: %v", err)))
+		return
+	}
+	expiresAt := req.ExpiresAt
+	if req.ExpiresIn > 0 {
+		t := time.Now().Add(time.Duration(req.ExpiresIn) * time.Hour)
+		expiresAt = &t
+	}
+
+	link, err := h.shareSvc.Create(userID, service.CreateShareLinkParams{
+		ResourceType: req.ResourceType,
+		ResourceID:   req.ResourceID,
+		Password:     req.Password,
+		ExpiresAt:    expiresAt,
+	})
+	if err != nil {
+		handleShareError(c, err)
+		return
+	}
+	view, err := h.buildShareLinkView(link)
+	if err != nil {
+		handleShareError(c, err)
+		return
+	}
+	middleware.Success(c, "分享链接已创建", view)
+}
+
+// GetShareLinks 我的分享链接 - GET /api/v1/share/list
+// 支持 resource_type、resource_id 筛选，include_revoked=true 时包含已撤销的链接
+func (h *ShareHandler) GetShareLinks(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	links, err := h.shareSvc.List(userID,
+		models.ShareResourceType(c.Query("resource_type")),
+		c.Query("resource_id"),
+		c.Query("include_revoked") == "true")
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("查询失败: %v", err)))
+		return
+	}
+
+	items := make([]gin.H, 0, len(links))
+	for i := range links {
+		view, err := h.buildShareLinkView(&links[i])
+		if err != nil {
+			handleShareError(c, err)
+			return
+		}
+		items = append(items, view)
+	}
+	middleware.Success(c, "success", gin.H{
+		"items": items,
+		"total": len(items),
+	})
+}
+
+// RevokeShareLink 撤销分享链接，撤销后链接立即失效 - DELETE /api/v1/share/:link_id
+func (h *ShareHandler) RevokeShareLink(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	link, err := h.shareSvc.Revoke(userID, c.Param("link_id"))
+	if err != nil {
+		handleShareError(c, err)
+		return
+	}
+	view, err := h.buildShareLinkView(link)
+	if err != nil {
+		handleShareError(c, err)
+		return
+	}
+	middleware.Success(c, "分享链接已撤销", view)
+}
+
+// GetSharedInfo 分享链接基本信息，用于分享页判断是否需要输入密码 - GET /api/v1/shared/:token/info
+func (h *ShareHandler) GetSharedInfo(c *gin.Context) {
+	link, err := h.shareSvc.Resolve(c.Param("token"))
+	if err != nil {
+		handleShareError(c, err)
+		return
+	}
+	middleware.Success(c, "success", gin.H{
+		"resource_type":     link.ResourceType,
+		"requires_password": link.PasswordHash != nil,
+		"expires_at":        formatShareTime(link.ExpiresAt),
+	})
+}
+
+// OpenShared 打开分享链接，返回只读内容，无需登录
+// GET /api/v1/shared/:token（密码放在 X-Share-Password 请求头）或 POST /api/v1/shared/:token（密码放在请求体）
+func (h *ShareHandler) OpenShared(c *gin.Context) {
+	password := c.GetHeader(sharePasswordHeader)
+	if c.Request.Method == http.MethodPost {
+		var req OpenShareRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, fmt.Sprintf("参数错误: %v", err)))
+			return
+		}
+		password = req.Password
+	}
+
+	shared, err := h.shareSvc.Open(c.Param("token"), password, c.ClientIP())
+	if err != nil {
+		handleShareError(c, err)
+		return
+	}
+	middleware.Success(c, "success", gin.H{
+		"resource_type": shared.ResourceType,
+		"content":       shared.Content,
+		"view_count":    shared.Link.ViewCount,
+		"expires_at":    formatShareTime(shared.Link.ExpiresAt),
+	})
+}
+
+func (h *ShareHandler) buildShareLinkView(link *models.ShareLink) (gin.H, error) {
+	token, err := h.shareSvc.Token(link)
+	if err != nil {
+		return nil, err
+	}
+	shareURL, err := h.shareSvc.URL(link)
+	if err != nil {
+		return nil, err
+	}
+
+	status := "active"
+	switch {
+	case link.RevokedAt != nil:
+		status = "revoked"
+	case link.ExpiresAt != nil && !link.ExpiresAt.After(time.Now()):
+		status = "expired"
+	}
+	return gin.H{
+		"id":             link.ID,
+		"resource_type":  link.ResourceType,
+		"resource_id":    link.ResourceID,
+		"token":          token,
+		"url":            shareURL,
+		"has_password":   link.PasswordHash != nil,
+		"status":         status,
+		"expires_at":     formatShareTime(link.ExpiresAt),
+		"view_count":     link.ViewCount,
+		"last_viewed_at": formatShareTime(link.LastViewedAt),
+		"revoked_at":     formatShareTime(link.RevokedAt),
+		"created_at":     link.CreatedAt.Format(time.RFC3339),
+	}, nil
+}
+
+func formatShareTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.Format(time.RFC3339)
+}
+
+func handleShareError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrShareLinkNotFound), errors.Is(err, service.ErrShareResourceNotFound):
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusNotFound, err.Error()))
+	case errors.Is(err, service.ErrShareLinkExpired):
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusGone, err.Error()))
+	case errors.Is(err, service.ErrSharePasswordRequired), errors.Is(err, service.ErrSharePasswordIncorrect):
+		middleware.HandleErrorWithData(c, middleware.NewBusinessError(http.StatusForbidden, err.Error()), gin.H{"requires_password": true})
+	case errors.Is(err, service.ErrSharePasswordLocked):
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusTooManyRequests, err.Error()))
+	case errors.Is(err, service.ErrShareInvalidResourceType), errors.Is(err, service.ErrShareInvalidExpiry):
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, err.Error()))
+	case errors.Is(err, service.ErrShareSecretMissing):
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusServiceUnavailable, err.Error()))
+	default:
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("分享操作失败: %v", err)))
+	}
+}
+
+// SetupShareRoutes 设置分享链接路由
+func SetupShareRoutes(r *gin.Engine) {
+	handler := NewShareHandler()
+
+	// 分享链接管理，需要认证
+	shareGroup := r.Group("/api/v1/share")
+	shareGroup.Use(middleware.JWTAuth())
+	{
+		shareGroup.POST("", handler.CreateShareLink)
+		shareGroup.GET("/list", handler.GetShareLinks)
+		shareGroup.DELETE("/:link_id", handler.RevokeShareLink)
+	}
+
+	// 分享页只读访问，不需要认证
+	sharedGroup := r.Group("/api/v1/shared")
+	{
+		sharedGroup.GET("/:token/info", handler.GetSharedInfo)
+		sharedGroup.GET("/:token", handler.OpenShared)
+		sharedGroup.POST("/:token", handler.OpenShared)
+	}
+}
@@ -0,0 +1,294 @@
+package short_post
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"01agent_server/internal/middleware"
+	"01agent_server/internal/models/short_post"
+	"01agent_server/internal/service"
+	"01agent_server/internal/service/carousel"
+	"01agent_server/internal/service/exporter"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBatchUploadBytes 批量生成上传数据的大小上限
+const maxBatchUploadBytes = 5 << 20
+
+// BatchHandler short post batch generation handler
+type BatchHandler struct {
+	batchSvc *carousel.Service
+}
+
+// NewBatchHandler create batch handler
+func NewBatchHandler() *BatchHandler {
+	return &BatchHandler{
+		batchSvc: carousel.NewService(),
+	}
+}
+
+// CreateBatchRequest 以 JSON 提交批量生成任务请求
+type CreateBatchRequest struct {
+	Name              string            `json:"name" binding:"max=200"`
+	TemplateID        string            `json:"template_id"`         // 模板ID，与 template_project_id 二选一
+	TemplateProjectID string            `json:"template_project_id"` // 作为模板使用的工程ID
+	Mapping           map[string]string `json:"mapping"`             // 列名 -> 占位符（name/title/content/topics/images 或模板占位符）
+	Rows              json.RawMessage   `json:"rows" binding:"required"`
+}
+
+// ExportBatchRequest 批量导出请求
+type ExportBatchRequest struct {
+	ExportFormat short_post.ExportFormat `json:"export_format" binding:"required"`
+	FrameIDs     []string                `json:"frame_ids"`
+	Scale        float64                 `json:"scale"`
+}
+
+// CreateBatch 创建批量生成任务 - POST /api/v1/short-post/batch
+// 支持 multipart 上传 csv/xlsx/json 文件（file、name、template_id、template_project_id、mapping），
+// 也支持直接提交 JSON 行数据；每一行生成一个工程与文案，任务在后台执行
+func (h *BatchHandler) CreateBatch(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBatchUploadBytes+1<<20)
+
+	var params carousel.CreateJobParams
+	if c.ContentType() == "multipart/form-data" {
+		file, err := c.FormFile("file")
+		if err != nil {
+			middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, fmt.Sprintf("获取文件失败: %v", err)))
+			return
+		}
+		if file.Size > maxBatchUploadBytes {
+			middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, "文件不能超过 5MB"))
+			return
+		}
+		format := c.PostForm("format")
+		if format == "" {
+			format = carousel.FormatFromFilename(file.Filename)
+		}
+		f, err := file.Open()
+		if err != nil {
+			middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, fmt.Sprintf("读取文件失败: %v", err)))
+			return
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, fmt.Sprintf("读取文件失败: %v", err)))
+			return
+		}
+		table, err := carousel.ParseTable(format, data)
+		if err != nil {
+			middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, err.Error()))
+			return
+		}
+		var mapping map[string]string
+		if raw := c.PostForm("mapping"); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+				middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, "mapping 需要是列名到占位符的 JSON 对象"))
+				return
+			}
+		}
+		params = carousel.CreateJobParams{
+			Name:              c.PostForm("name"),
+			TemplateID:        c.PostForm("template_id"),
+			TemplateProjectID: c.PostForm("template_project_id"),
+			Format:            format,
+			Table:             table,
+			Mapping:           mapping,
+		}
+	} else {
+		var req CreateBatchRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, fmt.Sprintf("参数错误: %v", err)))
+			return
+		}
+		table, err := carousel.ParseTable(carousel.FormatJSON, req.Rows)
+		if err != nil {
+			middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, err.Error()))
+			return
+		}
+		params = carousel.CreateJobParams{
+			Name:              req.Name,
+			TemplateID:        req.TemplateID,
+			TemplateProjectID: req.TemplateProjectID,
+			Format:            carousel.FormatJSON,
+			Table:             table,
+			Mapping:           req.Mapping,
+		}
+	}
+
+	job, err := h.batchSvc.CreateJob(userID, params)
+	if err != nil {
+		handleBatchError(c, err)
+		return
+	}
+	middleware.Success(c, "批量任务已创建", buildBatchJobView(job))
+}
+
+// GetBatchList 批量任务列表 - GET /api/v1/short-post/batch/list
+func (h *BatchHandler) GetBatchList(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	page, pageSize := batchPageParams(c)
+	jobs, total, err := h.batchSvc.ListJobs(userID, page, pageSize)
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("查询失败: %v", err)))
+		return
+	}
+
+	items := make([]gin.H, 0, len(jobs))
+	for i := range jobs {
+		items = append(items, buildBatchJobView(&jobs[i]))
+	}
+	middleware.Success(c, "success", gin.H{
+		"items":     items,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// GetBatchDetail 批量任务进度与各行结果 - GET /api/v1/short-post/batch/:job_id
+// 行结果分页返回，status 可筛选 pending / succeeded / failed
+func (h *BatchHandler) GetBatchDetail(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	job, err := h.batchSvc.GetJob(userID, c.Param("job_id"))
+	if err != nil {
+		handleBatchError(c, err)
+		return
+	}
+	page, pageSize := batchPageParams(c)
+	rows, total, err := h.batchSvc.ListRows(job.ID, short_post.BatchRowStatus(c.Query("status")), page, pageSize)
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("查询失败: %v", err)))
+		return
+	}
+
+	items := make([]gin.H, 0, len(rows))
+	for i := range rows {
+		items = append(items, buildBatchRowView(&rows[i]))
+	}
+	view := buildBatchJobView(job)
+	view["columns"] = decodeJSONField(job.Columns)
+	view["mapping"] = decodeJSONField(job.Mapping)
+	view["rows"] = gin.H{
+		"items":     items,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	}
+	middleware.Success(c, "success", view)
+}
+
+// RetryBatch 重新生成失败的行 - POST /api/v1/short-post/batch/:job_id/retry
+func (h *BatchHandler) RetryBatch(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	job, retried, err := h.batchSvc.Retry(userID, c.Param("job_id"))
+	if err != nil {
+		handleBatchError(c, err)
+		return
+	}
+	message := "没有需要重试的行"
+	if retried > 0 {
+		message = fmt.Sprintf("已重新提交 %d 行", retried)
+	}
+	middleware.Success(c, message, buildBatchJobView(job))
+}
+
+// ExportBatch 导出任务中生成成功的全部工程 - POST /api/v1/short-post/batch/:job_id/export
+// 每个工程创建一个服务端导出任务，可通过 /export/jobs/:job_id 查询进度
+func (h *BatchHandler) ExportBatch(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	var req ExportBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, fmt.Sprintf("参数错误: %v", err)))
+		return
+	}
+
+	results, err := h.batchSvc.Export(userID, c.Param("job_id"), req.ExportFormat, exporter.JobOptions{FrameIDs: req.FrameIDs, Scale: req.Scale})
+	if err != nil {
+		handleBatchError(c, err)
+		return
+	}
+	created := 0
+	for _, r := range results {
+		if r.JobID != "" {
+			created++
+		}
+	}
+	middleware.Success(c, fmt.Sprintf("已创建 %d 个导出任务", created), gin.H{
+		"items": results,
+		"total": len(results),
+	})
+}
+
+func batchPageParams(c *gin.Context) (int, int) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	return page, pageSize
+}
+
+func buildBatchJobView(job *short_post.ShortPostBatchJob) gin.H {
+	view := gin.H{
+		"id":                  job.ID,
+		"name":                job.Name,
+		"template_id":         job.TemplateID,
+		"template_project_id": job.TemplateProjectID,
+		"source_format":       job.SourceFormat,
+		"status":              job.Status,
+		"total_rows":          job.TotalRows,
+		"succeeded_rows":      job.SucceededRows,
+		"failed_rows":         job.FailedRows,
+		"error":               job.Error,
+		"created_at":          job.CreatedAt.Format(time.RFC3339),
+		"updated_at":          job.UpdatedAt.Format(time.RFC3339),
+	}
+	if job.StartedAt != nil {
+		view["started_at"] = job.StartedAt.Format(time.RFC3339)
+	}
+	if job.FinishedAt != nil {
+		view["finished_at"] = job.FinishedAt.Format(time.RFC3339)
+	}
+	return view
+}
+
+func buildBatchRowView(row *short_post.ShortPostBatchRow) gin.H {
+	return gin.H{
+		"row_index":      row.RowIndex,
+		"data":           decodeJSONField(&row.Data),
+		"status":         row.Status,
+		"project_id":     row.ProjectID,
+		"unfilled_slots": decodeJSONField(row.UnfilledSlots),
+		"error":          row.Error,
+		"updated_at":     row.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+func handleBatchError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, carousel.ErrJobNotFound), errors.Is(err, service.ErrTemplateNotFound), errors.Is(err, service.ErrTemplateSourceNotFound):
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusNotFound, err.Error()))
+	case errors.Is(err, carousel.ErrJobRunning):
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusConflict, err.Error()))
+	case errors.Is(err, carousel.ErrNoTemplate), errors.Is(err, carousel.ErrEmptyTable), errors.Is(err, carousel.ErrTooManyRows),
+		errors.Is(err, carousel.ErrUnknownColumn), errors.Is(err, exporter.ErrUnsupportedFormat):
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, err.Error()))
+	default:
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("批量任务操作失败: %v", err)))
+	}
+}
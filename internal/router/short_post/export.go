@@ -10,6 +10,7 @@ import (
 	"01agent_server/internal/middleware"
 	"01agent_server/internal/models/short_post"
 	"01agent_server/internal/repository"
+	"01agent_server/internal/service/exporter"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -18,13 +19,15 @@ import (
 
 // ExportHandler short post export handler
 type ExportHandler struct {
-	db *gorm.DB
+	db        *gorm.DB
+	exportSvc *exporter.Service
 }
 
 // NewExportHandler create export handler
 func NewExportHandler() *ExportHandler {
 	return &ExportHandler{
-		db: repository.DB,
+		db:        repository.DB,
+		exportSvc: exporter.NewService(),
 	}
 }
 
@@ -181,6 +184,13 @@ func (h *ExportHandler) GetExportDetail(c *gin.Context) {
 		json.Unmarshal([]byte(*record.ExportedData), &exportedData)
 	}
 
+	// 服务端导出的记录附带渲染任务状态
+	job, err := h.exportSvc.GetJobByRecord(record.ID)
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("查询失败: %v", err)))
+		return
+	}
+
 	middleware.Success(c, "success", gin.H{
 		"id":            record.ID,
 		"export_name":   record.ExportName,
@@ -189,6 +199,7 @@ func (h *ExportHandler) GetExportDetail(c *gin.Context) {
 		"file_size":     record.FileSize,
 		"export_config": exportConfig,
 		"exported_data": exportedData,
+		"job":           buildExportJobView(job),
 		"created_at":    record.CreatedAt.Format(time.RFC3339),
 	})
 }
@@ -0,0 +1,113 @@
+package short_post
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"01agent_server/internal/middleware"
+	"01agent_server/internal/models/short_post"
+	"01agent_server/internal/service/exporter"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RenderExportRequest 服务端渲染导出请求
+type RenderExportRequest struct {
+	ProjectID    string                  `json:"project_id" binding:"required"`
+	ExportName   string                  `json:"export_name" binding:"max=200"`
+	ExportFormat short_post.ExportFormat `json:"export_format" binding:"required"` // image / pdf / zip
+	FrameIDs     []string                `json:"frame_ids"`                        // 导出的画板，为空时导出全部
+	Scale        float64                 `json:"scale"`                            // 输出像素倍率（1-3），默认 1
+}
+
+// RenderExport 创建服务端渲染导出任务 - POST /api/v1/short-post/export/render
+// 渲染在后台进行，通过 GET /export/jobs/:job_id 查询进度，完成后导出记录中回填文件地址
+func (h *ExportHandler) RenderExport(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	var req RenderExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, fmt.Sprintf("参数错误: %v", err)))
+		return
+	}
+
+	job, err := h.exportSvc.CreateJob(userID, exporter.CreateJobParams{
+		ProjectID:  req.ProjectID,
+		ExportName: req.ExportName,
+		Format:     req.ExportFormat,
+		Options:    exporter.JobOptions{FrameIDs: req.FrameIDs, Scale: req.Scale},
+	})
+	switch {
+	case errors.Is(err, exporter.ErrProjectNotFound), errors.Is(err, exporter.ErrContentNotFound):
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusNotFound, err.Error()))
+		return
+	case errors.Is(err, exporter.ErrUnsupportedFormat), errors.Is(err, exporter.ErrInvalidContent):
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, err.Error()))
+		return
+	case err != nil:
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("创建导出任务失败: %v", err)))
+		return
+	}
+
+	middleware.Success(c, "导出任务已创建", buildExportJobView(job))
+}
+
+// GetExportJob 查询导出任务进度 - GET /api/v1/short-post/export/jobs/:job_id
+func (h *ExportHandler) GetExportJob(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	job, err := h.exportSvc.GetJob(userID, c.Param("job_id"))
+	if errors.Is(err, exporter.ErrJobNotFound) {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusNotFound, err.Error()))
+		return
+	}
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("查询失败: %v", err)))
+		return
+	}
+
+	view := buildExportJobView(job)
+	if job.Status == short_post.ExportJobStatusSucceeded {
+		var record short_post.ShortPostExportRecord
+		if err := h.db.Where("id = ?", job.ExportRecordID).First(&record).Error; err == nil {
+			var fileURLs interface{}
+			if record.FileURLs != nil {
+				json.Unmarshal([]byte(*record.FileURLs), &fileURLs)
+			}
+			view["file_urls"] = fileURLs
+			view["file_size"] = record.FileSize
+		}
+	}
+	middleware.Success(c, "success", view)
+}
+
+// buildExportJobView 导出任务的返回结构，job 为空时返回 nil
+func buildExportJobView(job *short_post.ShortPostExportJob) gin.H {
+	if job == nil {
+		return nil
+	}
+	var warnings []string
+	if job.Warnings != nil {
+		json.Unmarshal([]byte(*job.Warnings), &warnings)
+	}
+	view := gin.H{
+		"id":               job.ID,
+		"project_id":       job.ProjectID,
+		"export_record_id": job.ExportRecordID,
+		"export_format":    string(job.ExportFormat),
+		"status":           string(job.Status),
+		"progress":         job.Progress,
+		"total_frames":     job.TotalFrames,
+		"rendered_frames":  job.RenderedFrames,
+		"warnings":         warnings,
+		"error":            job.Error,
+		"created_at":       job.CreatedAt.Format(time.RFC3339),
+	}
+	if job.FinishedAt != nil {
+		view["finished_at"] = job.FinishedAt.Format(time.RFC3339)
+	}
+	return view
+}
@@ -0,0 +1,95 @@
+package short_post
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"01agent_server/internal/middleware"
+	"01agent_server/internal/models"
+	"01agent_server/internal/models/short_post"
+	"01agent_server/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ProjectPresenceRequest 协同编辑心跳请求
+type ProjectPresenceRequest struct {
+	ClientID string `json:"client_id" binding:"required"` // 页面标识，同一用户多个页面各不相同
+	Lock     bool   `json:"lock"`                         // 是否获取（续期）编辑锁
+}
+
+// ProjectPresenceHeartbeat 上报编辑心跳 - POST /api/v1/short-post/project/:project_id/presence
+// 返回当前编辑版本号、其他正在编辑的页面与编辑锁持有者
+func (h *ProjectHandler) ProjectPresenceHeartbeat(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	var req ProjectPresenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, fmt.Sprintf("参数错误: %v", err)))
+		return
+	}
+
+	project, ok := h.findEditableProject(c, userID, c.Param("project_id"))
+	if !ok {
+		return
+	}
+
+	state, err := h.presenceSvc.Heartbeat(service.EditResourceShortPost, project.ID, userID, req.ClientID, req.Lock)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidClientID) {
+			middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, err.Error()))
+			return
+		}
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("更新编辑状态失败: %v", err)))
+		return
+	}
+
+	middleware.SetVersionETag(c, project.EditVersion)
+	middleware.Success(c, "success", gin.H{
+		"edit_version": project.EditVersion,
+		"presence":     state,
+	})
+}
+
+// LeaveProjectPresence 离开编辑 - DELETE /api/v1/short-post/project/:project_id/presence?client_id=xxx
+func (h *ProjectHandler) LeaveProjectPresence(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	project, ok := h.findEditableProject(c, userID, c.Param("project_id"))
+	if !ok {
+		return
+	}
+
+	if err := h.presenceSvc.Leave(service.EditResourceShortPost, project.ID, userID, c.Query("client_id")); err != nil {
+		if errors.Is(err, service.ErrInvalidClientID) {
+			middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, err.Error()))
+			return
+		}
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("更新编辑状态失败: %v", err)))
+		return
+	}
+	middleware.Success(c, "success", nil)
+}
+
+// findEditableProject 查询当前用户可编辑的工程（所有者或管理员），不存在时已写入错误响应
+func (h *ProjectHandler) findEditableProject(c *gin.Context, userID, projectID string) (*short_post.ShortPostProject, bool) {
+	var user models.User
+	h.db.Where("user_id = ?", userID).First(&user)
+
+	var project short_post.ShortPostProject
+	query := h.db.Where("id = ?", projectID)
+	if user.Role != 3 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if err := query.First(&project).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			middleware.HandleError(c, middleware.NewBusinessError(http.StatusNotFound, "工程不存在"))
+			return nil, false
+		}
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("查询失败: %v", err)))
+		return nil, false
+	}
+	return &project, true
+}
@@ -2,6 +2,7 @@ package short_post
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"sort"
@@ -12,21 +13,29 @@ import (
 	"01agent_server/internal/models"
 	"01agent_server/internal/models/short_post"
 	"01agent_server/internal/repository"
+	"01agent_server/internal/service"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// errProjectEditConflict 保存时编辑版本号不一致
+var errProjectEditConflict = errors.New("project edit version conflict")
+
 // ProjectHandler short post project handler
 type ProjectHandler struct {
-	db *gorm.DB
+	db          *gorm.DB
+	presenceSvc *service.EditPresenceService
+	versionSvc  *service.ProjectVersionService
 }
 
 // NewProjectHandler create project handler
 func NewProjectHandler() *ProjectHandler {
 	return &ProjectHandler{
-		db: repository.DB,
+		db:          repository.DB,
+		presenceSvc: service.NewEditPresenceService(),
+		versionSvc:  service.NewProjectVersionService(),
 	}
 }
 
@@ -623,14 +632,20 @@ func (h *ProjectHandler) GetProjectDetail(c *gin.Context) {
 		return
 	}
 
+	middleware.SetVersionETag(c, project.EditVersion)
+	middleware.Success(c, "success", h.buildProjectDetail(&project))
+}
+
+// buildProjectDetail 工程详情，包含最新内容与文案
+func (h *ProjectHandler) buildProjectDetail(project *short_post.ShortPostProject) map[string]interface{} {
 	// 获取最新内容
 	var content short_post.ShortPostProjectContent
-	h.db.Where("project_id = ? AND is_latest = ?", projectID, true).First(&content)
+	h.db.Where("project_id = ? AND is_latest = ?", project.ID, true).First(&content)
 
 	// 如果是小红书类型，获取文案信息
 	var copywriting short_post.ShortPostProjectCopywriting
 	if project.ProjectType == short_post.ProjectTypeXiaohongshu {
-		h.db.Where("project_id = ?", projectID).First(&copywriting)
+		h.db.Where("project_id = ?", project.ID).First(&copywriting)
 	}
 
 	// 解析 JSON 字段
@@ -694,6 +709,7 @@ func (h *ProjectHandler) GetProjectDetail(c *gin.Context) {
 		"metadata":     projectMetadata,
 		"created_at":   project.CreatedAt.Format(time.RFC3339),
 		"updated_at":   project.UpdatedAt.Format(time.RFC3339),
+		"edit_version": project.EditVersion,
 		"content":      contentData,
 		"copywriting":  copywritingData,
 	}
@@ -710,8 +726,7 @@ func (h *ProjectHandler) GetProjectDetail(c *gin.Context) {
 	if project.SavedAt != nil {
 		result["saved_at"] = project.SavedAt.Format(time.RFC3339)
 	}
-
-	middleware.Success(c, "success", result)
+	return result
 }
 
 // CheckProjectHasContent check if project has content
@@ -807,6 +822,7 @@ func (h *ProjectHandler) UpdateProject(c *gin.Context) {
 }
 
 // SaveProjectContent save project content
+// 携带 If-Match 时只有编辑版本号一致才保存，否则返回 409 和服务端当前内容
 func (h *ProjectHandler) SaveProjectContent(c *gin.Context) {
 	userID, _ := middleware.GetCurrentUserID(c)
 	projectID := c.Param("project_id")
@@ -816,6 +832,11 @@ func (h *ProjectHandler) SaveProjectContent(c *gin.Context) {
 		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, fmt.Sprintf("参数错误: %v", err)))
 		return
 	}
+	expectedVersion, err := middleware.IfMatchVersion(c)
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, err.Error()))
+		return
+	}
 
 	// 检查是否为管理员或工程所有者
 	var user models.User
@@ -858,46 +879,93 @@ func (h *ProjectHandler) SaveProjectContent(c *gin.Context) {
 		metadataJSON = &str
 	}
 
+	// 更新工程信息
+	frameCount := 0
+	if req.FramesData != nil {
+		frameCount = len(req.FramesData)
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"saved_at":    now,
+		"status":      short_post.ProjectStatusSaved,
+		"frame_count": frameCount,
+	}
+
+	// 从 canvas_config 中提取 thumbnail
+	if req.CanvasConfig != nil {
+		if thumb, ok := req.CanvasConfig["thumbnail"].(string); ok && thumb != "" {
+			updates["thumbnail"] = thumb
+		}
+	}
+
+	// 编辑版本号递增与内容写入在同一事务中，写入失败时版本号一并回滚
 	var content short_post.ShortPostProjectContent
-	h.db.Where("project_id = ? AND is_latest = ?", projectID, true).First(&content)
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		// 先以编辑版本号为条件递增，多个页面同时保存时只有一个成功
+		bump := tx.Model(&short_post.ShortPostProject{}).Where("id = ?", projectID)
+		if expectedVersion != nil {
+			bump = bump.Where("edit_version = ?", *expectedVersion)
+		}
+		result := bump.Update("edit_version", gorm.Expr("edit_version + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errProjectEditConflict
+		}
+		if err := tx.Select("edit_version").Where("id = ?", projectID).First(&project).Error; err != nil {
+			return err
+		}
 
-	if req.CreateVersion && content.ID != "" {
-		// 创建新版本
-		h.db.Model(&short_post.ShortPostProjectContent{}).
-			Where("project_id = ? AND is_latest = ?", projectID, true).
-			Update("is_latest", false)
-
-		version := content.Version + 1
-		content = short_post.ShortPostProjectContent{
-			ID:           uuid.New().String(),
-			ProjectID:    projectID,
-			CanvasConfig: canvasConfigJSON,
-			FramesData:   framesDataJSON,
-			ElementsData: elementsDataJSON,
-			Metadata:     metadataJSON,
-			Version:      version,
-			IsLatest:     true,
+		if err := tx.Where("project_id = ? AND is_latest = ?", projectID, true).First(&content).Error; err != nil && err != gorm.ErrRecordNotFound {
+			return err
 		}
-		h.db.Create(&content)
-	} else {
-		if content.ID != "" {
+
+		if req.CreateVersion && content.ID != "" {
+			// 创建新版本
+			if err := tx.Model(&short_post.ShortPostProjectContent{}).
+				Where("project_id = ? AND is_latest = ?", projectID, true).
+				Update("is_latest", false).Error; err != nil {
+				return err
+			}
+
+			version := content.Version + 1
+			content = short_post.ShortPostProjectContent{
+				ID:           uuid.New().String(),
+				ProjectID:    projectID,
+				CanvasConfig: canvasConfigJSON,
+				FramesData:   framesDataJSON,
+				ElementsData: elementsDataJSON,
+				Metadata:     metadataJSON,
+				Version:      version,
+				IsLatest:     true,
+			}
+			if err := tx.Create(&content).Error; err != nil {
+				return err
+			}
+		} else if content.ID != "" {
 			// 更新现有内容
-			updates := make(map[string]interface{})
+			contentUpdates := make(map[string]interface{})
 			if req.CanvasConfig != nil {
-				updates["canvas_config"] = canvasConfigJSON
+				contentUpdates["canvas_config"] = canvasConfigJSON
 			}
 			if req.FramesData != nil {
-				updates["frames_data"] = framesDataJSON
+				contentUpdates["frames_data"] = framesDataJSON
 			}
 			if req.ElementsData != nil {
-				updates["elements_data"] = elementsDataJSON
+				contentUpdates["elements_data"] = elementsDataJSON
 			}
 			if req.Metadata != nil {
-				updates["metadata"] = metadataJSON
+				contentUpdates["metadata"] = metadataJSON
 			}
-			if len(updates) > 0 {
-				h.db.Model(&content).Updates(updates)
-				h.db.Where("id = ?", content.ID).First(&content)
+			if len(contentUpdates) > 0 {
+				if err := tx.Model(&content).Updates(contentUpdates).Error; err != nil {
+					return err
+				}
+				if err := tx.Where("id = ?", content.ID).First(&content).Error; err != nil {
+					return err
+				}
 			}
 		} else {
 			// 创建新内容
@@ -911,36 +979,29 @@ func (h *ProjectHandler) SaveProjectContent(c *gin.Context) {
 				Version:      1,
 				IsLatest:     true,
 			}
-			h.db.Create(&content)
+			if err := tx.Create(&content).Error; err != nil {
+				return err
+			}
 		}
-	}
-
-	// 更新工程信息
-	frameCount := 0
-	if req.FramesData != nil {
-		frameCount = len(req.FramesData)
-	}
-
-	now := time.Now()
-	updates := map[string]interface{}{
-		"saved_at":    now,
-		"status":      short_post.ProjectStatusSaved,
-		"frame_count": frameCount,
-	}
 
-	// 从 canvas_config 中提取 thumbnail
-	if req.CanvasConfig != nil {
-		if thumb, ok := req.CanvasConfig["thumbnail"].(string); ok && thumb != "" {
-			updates["thumbnail"] = thumb
-		}
+		return tx.Model(&project).Updates(updates).Error
+	})
+	if err == errProjectEditConflict {
+		h.db.Where("id = ?", projectID).First(&project)
+		middleware.SetVersionETag(c, project.EditVersion)
+		middleware.HandleConflict(c, "内容已被其他页面修改，请合并后重试", h.buildProjectDetail(&project))
+		return
+	} else if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("保存失败: %v", err)))
+		return
 	}
 
-	h.db.Model(&project).Updates(updates)
-
+	middleware.SetVersionETag(c, project.EditVersion)
 	middleware.Success(c, "保存成功", gin.H{
-		"content_id": content.ID,
-		"version":    content.Version,
-		"saved_at":   now.Format(time.RFC3339),
+		"content_id":   content.ID,
+		"version":      content.Version,
+		"edit_version": project.EditVersion,
+		"saved_at":     now.Format(time.RFC3339),
 	})
 }
 
@@ -982,17 +1043,15 @@ func (h *ProjectHandler) GetProjectVersions(c *gin.Context) {
 		return
 	}
 
-	var contents []short_post.ShortPostProjectContent
-	h.db.Where("project_id = ?", projectID).Order("version DESC").Find(&contents)
+	contents, err := h.versionSvc.List(projectID)
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("查询失败: %v", err)))
+		return
+	}
 
-	items := make([]map[string]interface{}, 0, len(contents))
-	for _, c := range contents {
-		items = append(items, map[string]interface{}{
-			"id":         c.ID,
-			"version":    c.Version,
-			"is_latest":  c.IsLatest,
-			"created_at": c.CreatedAt.Format(time.RFC3339),
-		})
+	items := make([]gin.H, 0, len(contents))
+	for i := range contents {
+		items = append(items, buildVersionItem(&contents[i]))
 	}
 
 	middleware.Success(c, "success", gin.H{
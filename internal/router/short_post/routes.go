@@ -10,6 +10,8 @@ import (
 func SetupShortPostRoutes(r *gin.Engine) {
 	projectHandler := NewProjectHandler()
 	exportHandler := NewExportHandler()
+	templateHandler := NewTemplateHandler()
+	batchHandler := NewBatchHandler()
 
 	// 短图文工程管理路由
 	projectGroup := r.Group("/api/v1/short-post/project")
@@ -22,18 +24,53 @@ func SetupShortPostRoutes(r *gin.Engine) {
 		projectGroup.GET("/:project_id/has-content", projectHandler.CheckProjectHasContent)
 		projectGroup.PUT("/:project_id", projectHandler.UpdateProject)
 		projectGroup.POST("/:project_id/save", projectHandler.SaveProjectContent)
+		projectGroup.POST("/:project_id/presence", projectHandler.ProjectPresenceHeartbeat)
+		projectGroup.DELETE("/:project_id/presence", projectHandler.LeaveProjectPresence)
 		projectGroup.DELETE("/:project_id", projectHandler.DeleteProject)
 		projectGroup.GET("/:project_id/versions", projectHandler.GetProjectVersions)
+		projectGroup.GET("/:project_id/versions/diff", projectHandler.DiffProjectVersions)
+		projectGroup.POST("/:project_id/versions/snapshot", projectHandler.SnapshotProjectVersion)
+		projectGroup.GET("/:project_id/versions/:version", projectHandler.GetProjectVersion)
+		projectGroup.PUT("/:project_id/versions/:version/label", projectHandler.LabelProjectVersion)
+		projectGroup.POST("/:project_id/versions/:version/restore", projectHandler.RestoreProjectVersion)
+		projectGroup.POST("/:project_id/versions/:version/branch", projectHandler.BranchProjectVersion)
 		projectGroup.POST("/:project_id/copywriting", projectHandler.SaveCopywriting)
 		projectGroup.GET("/:project_id/copywriting", projectHandler.GetCopywriting)
 	}
 
+	// 短图文工程模板路由
+	templateGroup := r.Group("/api/v1/short-post/template")
+	templateGroup.Use(middleware.JWTAuth())
+	{
+		templateGroup.POST("", templateHandler.PublishTemplate)
+		templateGroup.GET("/list", templateHandler.GetTemplateList)
+		templateGroup.GET("/review/list", templateHandler.GetReviewList) // 管理员接口
+		templateGroup.GET("/:template_id", templateHandler.GetTemplateDetail)
+		templateGroup.PUT("/:template_id", templateHandler.UpdateTemplate)
+		templateGroup.DELETE("/:template_id", templateHandler.DeleteTemplate)
+		templateGroup.POST("/:template_id/use", templateHandler.UseTemplate)
+		templateGroup.POST("/:template_id/review", templateHandler.ReviewTemplate) // 管理员接口
+	}
+
+	// 短图文批量生成路由
+	batchGroup := r.Group("/api/v1/short-post/batch")
+	batchGroup.Use(middleware.JWTAuth())
+	{
+		batchGroup.POST("", batchHandler.CreateBatch)
+		batchGroup.GET("/list", batchHandler.GetBatchList)
+		batchGroup.GET("/:job_id", batchHandler.GetBatchDetail)
+		batchGroup.POST("/:job_id/retry", batchHandler.RetryBatch)
+		batchGroup.POST("/:job_id/export", batchHandler.ExportBatch)
+	}
+
 	// 短图文导出管理路由
 	exportGroup := r.Group("/api/v1/short-post/export")
 	exportGroup.Use(middleware.JWTAuth())
 	{
 		exportGroup.POST("", exportHandler.CreateExportRecord)
 		exportGroup.GET("/list", exportHandler.GetExportList)
+		exportGroup.POST("/render", exportHandler.RenderExport)
+		exportGroup.GET("/jobs/:job_id", exportHandler.GetExportJob)
 		exportGroup.GET("/:export_id", exportHandler.GetExportDetail)
 		exportGroup.DELETE("/:export_id", exportHandler.DeleteExportRecord)
 		exportGroup.DELETE("/batch", exportHandler.BatchDeleteExportRecords)
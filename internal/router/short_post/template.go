@@ -0,0 +1,346 @@
+package short_post
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"01agent_server/internal/middleware"
+	"01agent_server/internal/models"
+	"01agent_server/internal/models/short_post"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TemplateHandler short post template handler
+type TemplateHandler struct {
+	db          *gorm.DB
+	templateSvc *service.ProjectTemplateService
+}
+
+// NewTemplateHandler create template handler
+func NewTemplateHandler() *TemplateHandler {
+	return &TemplateHandler{
+		db:          repository.DB,
+		templateSvc: service.NewProjectTemplateService(),
+	}
+}
+
+// PublishTemplateRequest 将工程发布为模板请求
+type PublishTemplateRequest struct {
+	ProjectID   string                        `json:"project_id" binding:"required"`
+	Name        string                        `json:"name" binding:"required,max=200"`
+	Description *string                       `json:"description"`
+	Tags        []string                      `json:"tags"`
+	Visibility  short_post.TemplateVisibility `json:"visibility"` // private / team / public，默认 private
+	MemberIDs   []string                      `json:"member_ids"` // 团队模板的成员用户ID
+	Thumbnail   *string                       `json:"thumbnail"`
+}
+
+// UpdateTemplateRequest 修改模板请求，未传的字段保持不变
+type UpdateTemplateRequest struct {
+	Name           *string                        `json:"name" binding:"omitempty,max=200"`
+	Description    *string                        `json:"description"`
+	Tags           []string                       `json:"tags"`
+	Visibility     *short_post.TemplateVisibility `json:"visibility"`
+	MemberIDs      []string                       `json:"member_ids"`
+	Thumbnail      *string                        `json:"thumbnail"`
+	RefreshContent bool                           `json:"refresh_content"` // 用来源工程的最新内容更新模板
+}
+
+// UseTemplateRequest 使用模板创建工程请求
+type UseTemplateRequest struct {
+	Name        string                       `json:"name" binding:"max=200"`
+	ThreadID    *string                      `json:"thread_id"`
+	Copywriting *service.TemplateCopywriting `json:"copywriting"` // 用于填充 {{title}}、{{image_1}} 等占位符
+	Slots       map[string]string            `json:"slots"`       // 直接指定占位符内容
+}
+
+// ReviewTemplateRequest 审核公开模板请求
+type ReviewTemplateRequest struct {
+	Approved *bool  `json:"approved" binding:"required"`
+	Note     string `json:"note" binding:"max=500"`
+}
+
+// PublishTemplate 将工程发布为模板 - POST /api/v1/short-post/template
+// 公开模板需要管理员审核，审核通过前仅自己可见
+func (h *TemplateHandler) PublishTemplate(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	var req PublishTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, fmt.Sprintf("参数错误: %v", err)))
+		return
+	}
+	if req.Visibility == "" {
+		req.Visibility = short_post.TemplateVisibilityPrivate
+	}
+
+	template, err := h.templateSvc.Publish(userID, service.PublishTemplateParams{
+		ProjectID:   req.ProjectID,
+		Name:        req.Name,
+		Description: req.Description,
+		Tags:        req.Tags,
+		Visibility:  req.Visibility,
+		MemberIDs:   req.MemberIDs,
+		Thumbnail:   req.Thumbnail,
+		IsAdmin:     h.isAdmin(userID),
+	})
+	if err != nil {
+		handleTemplateError(c, err)
+		return
+	}
+
+	message := "发布成功"
+	if template.ReviewStatus == short_post.TemplateReviewPending {
+		message = "已提交审核，审核通过后所有用户可见"
+	}
+	middleware.Success(c, message, h.buildTemplateDetail(template, true))
+}
+
+// GetTemplateList 模板列表 - GET /api/v1/short-post/template/list
+// scope: mine / team / public，为空时返回所有可见模板；支持 project_type、tag、keyword 筛选
+func (h *TemplateHandler) GetTemplateList(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	templates, total, err := h.templateSvc.List(userID, service.TemplateQuery{
+		Scope:       c.Query("scope"),
+		ProjectType: c.Query("project_type"),
+		Tag:         c.Query("tag"),
+		Keyword:     c.Query("keyword"),
+		OrderBy:     c.DefaultQuery("order_by", "created_at"),
+		Page:        page,
+		PageSize:    pageSize,
+	})
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("查询失败: %v", err)))
+		return
+	}
+
+	items := make([]gin.H, 0, len(templates))
+	for i := range templates {
+		items = append(items, buildTemplateItem(&templates[i], templates[i].UserID == userID))
+	}
+	middleware.Success(c, "success", gin.H{
+		"items":     items,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// GetTemplateDetail 模板详情，包含内容与占位符 - GET /api/v1/short-post/template/:template_id
+func (h *TemplateHandler) GetTemplateDetail(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	template, err := h.templateSvc.Get(userID, c.Param("template_id"))
+	if err != nil {
+		handleTemplateError(c, err)
+		return
+	}
+	isOwner := template.UserID == userID
+	detail := h.buildTemplateDetail(template, isOwner)
+	detail["is_owner"] = isOwner
+	middleware.Success(c, "success", detail)
+}
+
+// UpdateTemplate 修改模板 - PUT /api/v1/short-post/template/:template_id
+func (h *TemplateHandler) UpdateTemplate(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	var req UpdateTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, fmt.Sprintf("参数错误: %v", err)))
+		return
+	}
+
+	template, err := h.templateSvc.Update(userID, c.Param("template_id"), service.UpdateTemplateParams{
+		Name:           req.Name,
+		Description:    req.Description,
+		Tags:           req.Tags,
+		Visibility:     req.Visibility,
+		MemberIDs:      req.MemberIDs,
+		Thumbnail:      req.Thumbnail,
+		RefreshContent: req.RefreshContent,
+		IsAdmin:        h.isAdmin(userID),
+	})
+	if err != nil {
+		handleTemplateError(c, err)
+		return
+	}
+	middleware.Success(c, "更新成功", h.buildTemplateDetail(template, true))
+}
+
+// DeleteTemplate 删除模板 - DELETE /api/v1/short-post/template/:template_id
+func (h *TemplateHandler) DeleteTemplate(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	if err := h.templateSvc.Delete(userID, c.Param("template_id")); err != nil {
+		handleTemplateError(c, err)
+		return
+	}
+	middleware.Success(c, "删除成功", nil)
+}
+
+// UseTemplate 使用模板创建工程 - POST /api/v1/short-post/template/:template_id/use
+// 文案同时保存为新工程的文案，unfilled_slots 为没有内容可填充的占位符
+func (h *TemplateHandler) UseTemplate(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+
+	var req UseTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, fmt.Sprintf("参数错误: %v", err)))
+		return
+	}
+
+	project, unfilled, err := h.templateSvc.Use(userID, c.Param("template_id"), service.UseTemplateParams{
+		Name:        req.Name,
+		ThreadID:    req.ThreadID,
+		Copywriting: req.Copywriting,
+		Slots:       req.Slots,
+	})
+	if err != nil {
+		handleTemplateError(c, err)
+		return
+	}
+
+	middleware.Success(c, "创建成功", gin.H{
+		"id":             project.ID,
+		"name":           project.Name,
+		"project_type":   string(project.ProjectType),
+		"status":         string(project.Status),
+		"frame_count":    project.FrameCount,
+		"template_id":    c.Param("template_id"),
+		"unfilled_slots": unfilled,
+		"created_at":     project.CreatedAt.Format(time.RFC3339),
+	})
+}
+
+// GetReviewList 待审核的公开模板（管理员接口） - GET /api/v1/short-post/template/review/list
+func (h *TemplateHandler) GetReviewList(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+	if !h.isAdmin(userID) {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusForbidden, "需要管理员权限"))
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	status := short_post.TemplateReviewStatus(c.Query("review_status"))
+	templates, total, err := h.templateSvc.ListForReview(status, page, pageSize)
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("查询失败: %v", err)))
+		return
+	}
+
+	items := make([]gin.H, 0, len(templates))
+	for i := range templates {
+		items = append(items, buildTemplateItem(&templates[i], true))
+	}
+	middleware.Success(c, "success", gin.H{
+		"items":     items,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// ReviewTemplate 审核公开模板（管理员接口） - POST /api/v1/short-post/template/:template_id/review
+func (h *TemplateHandler) ReviewTemplate(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+	if !h.isAdmin(userID) {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusForbidden, "需要管理员权限"))
+		return
+	}
+
+	var req ReviewTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, fmt.Sprintf("参数错误: %v", err)))
+		return
+	}
+
+	template, err := h.templateSvc.Review(userID, c.Param("template_id"), *req.Approved, req.Note)
+	if err != nil {
+		handleTemplateError(c, err)
+		return
+	}
+	middleware.Success(c, "审核完成", buildTemplateItem(template, true))
+}
+
+// isAdmin 是否为管理员（Role = 3）
+func (h *TemplateHandler) isAdmin(userID string) bool {
+	var user models.User
+	if err := h.db.Select("role").Where("user_id = ?", userID).First(&user).Error; err != nil {
+		return false
+	}
+	return user.Role == 3
+}
+
+// buildTemplateDetail 模板详情，团队模板附带成员列表
+func (h *TemplateHandler) buildTemplateDetail(template *short_post.ShortPostTemplate, showReview bool) gin.H {
+	detail := buildTemplateItem(template, showReview)
+	detail["canvas_config"] = decodeJSONField(template.CanvasConfig)
+	detail["frames_data"] = decodeJSONField(template.FramesData)
+	detail["elements_data"] = decodeJSONField(template.ElementsData)
+	detail["metadata"] = decodeJSONField(template.Metadata)
+	if showReview && template.Visibility == short_post.TemplateVisibilityTeam {
+		members, _ := h.templateSvc.Members(template.ID)
+		detail["member_ids"] = members
+	}
+	return detail
+}
+
+// buildTemplateItem 模板列表项，来源工程与审核信息只返回给发布者和管理员
+func buildTemplateItem(template *short_post.ShortPostTemplate, showReview bool) gin.H {
+	var tags, slots []string
+	if template.Tags != nil {
+		json.Unmarshal([]byte(*template.Tags), &tags)
+	}
+	if template.Slots != nil {
+		json.Unmarshal([]byte(*template.Slots), &slots)
+	}
+	item := gin.H{
+		"id":           template.ID,
+		"user_id":      template.UserID,
+		"name":         template.Name,
+		"description":  template.Description,
+		"project_type": string(template.ProjectType),
+		"tags":         tags,
+		"thumbnail":    template.Thumbnail,
+		"slots":        slots,
+		"frame_count":  template.FrameCount,
+		"visibility":   string(template.Visibility),
+		"use_count":    template.UseCount,
+		"created_at":   template.CreatedAt.Format(time.RFC3339),
+		"updated_at":   template.UpdatedAt.Format(time.RFC3339),
+	}
+	if template.LastUsedAt != nil {
+		item["last_used_at"] = template.LastUsedAt.Format(time.RFC3339)
+	}
+	if showReview {
+		item["source_project_id"] = template.SourceProjectID
+		item["review_status"] = string(template.ReviewStatus)
+		item["review_note"] = template.ReviewNote
+	}
+	return item
+}
+
+func handleTemplateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrTemplateNotFound), errors.Is(err, service.ErrTemplateSourceNotFound):
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusNotFound, err.Error()))
+	case errors.Is(err, service.ErrTemplateInvalidVisibility), errors.Is(err, service.ErrTemplateMemberNotFound),
+		errors.Is(err, service.ErrTooManyTemplateMembers), errors.Is(err, service.ErrTemplateNotPending):
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, err.Error()))
+	default:
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("模板操作失败: %v", err)))
+	}
+}
@@ -0,0 +1,256 @@
+package short_post
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"01agent_server/internal/middleware"
+	"01agent_server/internal/models/short_post"
+	"01agent_server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VersionLabelRequest 命名版本请求，label 为空时取消命名
+type VersionLabelRequest struct {
+	Label string `json:"label" binding:"max=100"`
+}
+
+// BranchVersionRequest 从历史版本创建新工程请求
+type BranchVersionRequest struct {
+	Name string `json:"name" binding:"max=200"`
+}
+
+// GetProjectVersion 获取指定版本的完整内容 - GET /:project_id/versions/:version
+// version 为 0 时返回当前内容
+func (h *ProjectHandler) GetProjectVersion(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+	project, ok := h.findEditableProject(c, userID, c.Param("project_id"))
+	if !ok {
+		return
+	}
+	version, ok := parseVersionParam(c, c.Param("version"), 0)
+	if !ok {
+		return
+	}
+
+	content, err := h.versionSvc.Get(project.ID, version)
+	if err != nil {
+		handleVersionError(c, err)
+		return
+	}
+	middleware.Success(c, "success", buildVersionContent(content))
+}
+
+// DiffProjectVersions 比较两个版本的画板与元素 - GET /:project_id/versions/diff?from=3&to=0
+// to 默认为 0，即当前内容
+func (h *ProjectHandler) DiffProjectVersions(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+	project, ok := h.findEditableProject(c, userID, c.Param("project_id"))
+	if !ok {
+		return
+	}
+	from, ok := parseVersionParam(c, c.Query("from"), 1)
+	if !ok {
+		return
+	}
+	to, ok := parseVersionParam(c, c.DefaultQuery("to", "0"), 0)
+	if !ok {
+		return
+	}
+
+	diff, err := h.versionSvc.Diff(project.ID, from, to)
+	if err != nil {
+		handleVersionError(c, err)
+		return
+	}
+	middleware.Success(c, "success", diff)
+}
+
+// SnapshotProjectVersion 将当前内容保存为命名快照 - POST /:project_id/versions/snapshot
+// 命名快照不会被自动清理，之后的编辑写入快照的副本
+func (h *ProjectHandler) SnapshotProjectVersion(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+	project, ok := h.findEditableProject(c, userID, c.Param("project_id"))
+	if !ok {
+		return
+	}
+	var req VersionLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Label == "" {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, "请填写快照名称（不超过 100 个字符）"))
+		return
+	}
+
+	snapshot, err := h.versionSvc.Snapshot(project.ID, req.Label)
+	if err != nil {
+		handleVersionError(c, err)
+		return
+	}
+	h.respondWithEditVersion(c, project.ID, "快照已保存", buildVersionItem(snapshot))
+}
+
+// LabelProjectVersion 修改版本名称 - PUT /:project_id/versions/:version/label
+func (h *ProjectHandler) LabelProjectVersion(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+	project, ok := h.findEditableProject(c, userID, c.Param("project_id"))
+	if !ok {
+		return
+	}
+	version, ok := parseVersionParam(c, c.Param("version"), 1)
+	if !ok {
+		return
+	}
+	var req VersionLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, fmt.Sprintf("参数错误: %v", err)))
+		return
+	}
+
+	content, err := h.versionSvc.SetLabel(project.ID, version, req.Label)
+	if err != nil {
+		handleVersionError(c, err)
+		return
+	}
+	h.respondWithEditVersion(c, project.ID, "更新成功", buildVersionItem(content))
+}
+
+// RestoreProjectVersion 将历史版本恢复为新的当前版本 - POST /:project_id/versions/:version/restore
+// 携带 If-Match 时只有编辑版本号一致才恢复，否则返回 409 和服务端当前内容
+func (h *ProjectHandler) RestoreProjectVersion(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+	project, ok := h.findEditableProject(c, userID, c.Param("project_id"))
+	if !ok {
+		return
+	}
+	version, ok := parseVersionParam(c, c.Param("version"), 1)
+	if !ok {
+		return
+	}
+	expectedVersion, err := middleware.IfMatchVersion(c)
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	content, editVersion, err := h.versionSvc.Restore(project.ID, version, expectedVersion)
+	if errors.Is(err, service.ErrEditConflict) {
+		h.db.Where("id = ?", project.ID).First(project)
+		middleware.SetVersionETag(c, project.EditVersion)
+		middleware.HandleConflict(c, err.Error(), h.buildProjectDetail(project))
+		return
+	}
+	if err != nil {
+		handleVersionError(c, err)
+		return
+	}
+
+	middleware.SetVersionETag(c, editVersion)
+	middleware.Success(c, "恢复成功", gin.H{
+		"content_id":    content.ID,
+		"version":       content.Version,
+		"restored_from": version,
+		"edit_version":  editVersion,
+	})
+}
+
+// BranchProjectVersion 以历史版本创建新工程 - POST /:project_id/versions/:version/branch
+// version 为 0 时以当前内容创建
+func (h *ProjectHandler) BranchProjectVersion(c *gin.Context) {
+	userID, _ := middleware.GetCurrentUserID(c)
+	project, ok := h.findEditableProject(c, userID, c.Param("project_id"))
+	if !ok {
+		return
+	}
+	version, ok := parseVersionParam(c, c.Param("version"), 0)
+	if !ok {
+		return
+	}
+	var req BranchVersionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, fmt.Sprintf("参数错误: %v", err)))
+		return
+	}
+
+	branch, err := h.versionSvc.Branch(project, version, userID, req.Name)
+	if err != nil {
+		handleVersionError(c, err)
+		return
+	}
+	middleware.Success(c, "创建成功", gin.H{
+		"id":           branch.ID,
+		"name":         branch.Name,
+		"project_type": string(branch.ProjectType),
+		"status":       string(branch.Status),
+		"frame_count":  branch.FrameCount,
+		"created_at":   branch.CreatedAt.Format(time.RFC3339),
+	})
+}
+
+// respondWithEditVersion 版本操作会递增编辑版本号，返回最新的 ETag 供编辑页面继续保存
+func (h *ProjectHandler) respondWithEditVersion(c *gin.Context, projectID, message string, data gin.H) {
+	var project short_post.ShortPostProject
+	if err := h.db.Select("edit_version").Where("id = ?", projectID).First(&project).Error; err == nil {
+		middleware.SetVersionETag(c, project.EditVersion)
+		data["edit_version"] = project.EditVersion
+	}
+	middleware.Success(c, message, data)
+}
+
+// buildVersionItem 版本列表项
+func buildVersionItem(content *short_post.ShortPostProjectContent) gin.H {
+	source := content.Source
+	if source == "" {
+		source = short_post.ContentSourceSave
+	}
+	return gin.H{
+		"id":            content.ID,
+		"version":       content.Version,
+		"is_latest":     content.IsLatest,
+		"label":         content.Label,
+		"source":        source,
+		"restored_from": content.RestoredFrom,
+		"created_at":    content.CreatedAt.Format(time.RFC3339),
+		"updated_at":    content.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// buildVersionContent 版本详情，包含解析后的画板与元素
+func buildVersionContent(content *short_post.ShortPostProjectContent) gin.H {
+	data := buildVersionItem(content)
+	data["canvas_config"] = decodeJSONField(content.CanvasConfig)
+	data["frames_data"] = decodeJSONField(content.FramesData)
+	data["elements_data"] = decodeJSONField(content.ElementsData)
+	data["metadata"] = decodeJSONField(content.Metadata)
+	return data
+}
+
+// parseVersionParam 解析版本号参数，小于 min 时返回 400
+func parseVersionParam(c *gin.Context, value string, min int) (int, bool) {
+	version, err := strconv.Atoi(value)
+	if err != nil || version < min {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusBadRequest, "版本号无效"))
+		return 0, false
+	}
+	return version, true
+}
+
+// decodeJSONField 解析 JSON 字段，为空时返回 nil
+func decodeJSONField(data *string) interface{} {
+	var value interface{}
+	if data != nil {
+		json.Unmarshal([]byte(*data), &value)
+	}
+	return value
+}
+
+func handleVersionError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrProjectVersionNotFound) {
+		middleware.HandleError(c, middleware.NewBusinessError(http.StatusNotFound, err.Error()))
+		return
+	}
+	middleware.HandleError(c, middleware.NewBusinessError(http.StatusInternalServerError, fmt.Sprintf("版本操作失败: %v", err)))
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"01agent_server/internal/middleware"
@@ -461,11 +462,12 @@ func (h *StylesHandler) UpdateUserTheme(c *gin.Context) {
 	}
 
 	var req struct {
-		Name        *string             `json:"name"`
-		Description *string             `json:"description"`
-		Visibility  *int                `json:"visibility"`
-		Tags        []map[string]string `json:"tags"`
-		Category    *string             `json:"category"`
+		Name         *string                `json:"name"`
+		Description  *string                `json:"description"`
+		Visibility   *int                   `json:"visibility"`
+		Tags         []map[string]string    `json:"tags"`
+		Category     *string                `json:"category"`
+		TemplateData map[string]interface{} `json:"template_data"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -529,6 +531,21 @@ func (h *StylesHandler) UpdateUserTheme(c *gin.Context) {
 		hasUpdate = true
 	}
 
+	if req.TemplateData != nil {
+		templateData, result := tools.NormalizeThemeConfig(req.TemplateData)
+		if !result.Valid {
+			middleware.HandleErrorWithData(c, middleware.NewBusinessError(400, "主题配置校验失败: "+result.Summary()), result)
+			return
+		}
+		if templateDataJSON, err := json.Marshal(templateData); err == nil {
+			templateDataStr := string(templateDataJSON)
+			updates["template_data"] = &templateDataStr
+			// 模板数据变化后预览需要重新生成
+			updates["section_html"] = nil
+		}
+		hasUpdate = true
+	}
+
 	if !hasUpdate {
 		middleware.HandleError(c, middleware.NewBusinessError(400, "至少需要提供一个要更新的字段"))
 		return
@@ -542,6 +559,9 @@ func (h *StylesHandler) UpdateUserTheme(c *gin.Context) {
 		middleware.HandleError(c, middleware.NewBusinessError(500, "更新失败"))
 		return
 	}
+	if req.TemplateData != nil {
+		tools.InvalidateThemeCache(themeID)
+	}
 
 	// 重新查询获取最新数据
 	repository.DB.Where("template_id = ?", themeID).First(&template)
@@ -590,6 +610,7 @@ func (h *StylesHandler) DeleteUserTheme(c *gin.Context) {
 		middleware.HandleError(c, middleware.NewBusinessError(500, "删除失败"))
 		return
 	}
+	tools.InvalidateThemeCache(themeID)
 
 	middleware.Success(c, "模板删除成功", gin.H{
 		"id":         themeID,
@@ -621,6 +642,72 @@ func (h *StylesHandler) GetAllTags(c *gin.Context) {
 	})
 }
 
+// ValidateTheme 校验统一主题配置，返回逐项的错误与警告
+// 可通过 target_version 获取迁移到指定 Schema 版本后的配置
+func (h *StylesHandler) ValidateTheme(c *gin.Context) {
+	var req struct {
+		TemplateData  map[string]interface{} `json:"template_data" binding:"required"`
+		TargetVersion int                    `json:"target_version"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(400, "参数错误: "+err.Error()))
+		return
+	}
+
+	result := tools.ValidateThemeConfig(req.TemplateData)
+	response := gin.H{
+		"schema_version":  result.SchemaVersion,
+		"current_version": tools.CurrentThemeSchemaVersion,
+		"valid":           result.Valid,
+		"errors":          result.Errors,
+		"warnings":        result.Warnings,
+	}
+
+	if req.TargetVersion > 0 && result.Valid {
+		migrated, err := tools.MigrateThemeConfig(req.TemplateData, req.TargetVersion)
+		if err != nil {
+			middleware.HandleError(c, middleware.NewBusinessError(400, err.Error()))
+			return
+		}
+		migratedResult := tools.ValidateThemeConfig(migrated)
+		response["migrated"] = gin.H{
+			"schema_version": req.TargetVersion,
+			"template_data":  migrated,
+			"valid":          migratedResult.Valid,
+			"errors":         migratedResult.Errors,
+			"warnings":       migratedResult.Warnings,
+		}
+	}
+
+	middleware.Success(c, "校验完成", response)
+}
+
+// GetThemeSchema 获取统一主题配置的 JSON Schema，默认返回当前版本
+func (h *StylesHandler) GetThemeSchema(c *gin.Context) {
+	version := tools.CurrentThemeSchemaVersion
+	if v := c.Query("version"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil {
+			middleware.HandleError(c, middleware.NewBusinessError(400, "version格式错误"))
+			return
+		}
+		version = parsed
+	}
+
+	schema, ok := tools.ThemeSchema(version)
+	if !ok {
+		middleware.HandleError(c, middleware.NewBusinessError(404, fmt.Sprintf("Schema 版本 %d 不存在", version)))
+		return
+	}
+
+	middleware.Success(c, "获取Schema成功", gin.H{
+		"version":  version,
+		"versions": tools.ThemeSchemaVersions(),
+		"schema":   schema,
+	})
+}
+
 // SetupStylesRoutes 设置样式路由
 func SetupStylesRoutes(r *gin.Engine) {
 	handler := NewStylesHandler()
@@ -631,6 +718,8 @@ func SetupStylesRoutes(r *gin.Engine) {
 		styles.GET("/themes", handler.GetAllThemes)               // 获取所有主题列表
 		styles.GET("/themes/:theme_name", handler.GetThemeByName) // 根据主题ID获取主题配置
 		styles.GET("/tags", handler.GetAllTags)                   // 获取所有主题标签
+		styles.GET("/schema", handler.GetThemeSchema)             // 获取主题配置的 JSON Schema
+		styles.POST("/validate", handler.ValidateTheme)           // 校验主题配置
 
 		// 需要认证的接口
 		stylesAuth := styles.Group("")
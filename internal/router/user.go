@@ -2,12 +2,15 @@ package router
 
 import (
 	"encoding/json"
+	"strconv"
+	"strings"
 	"time"
 
 	"01agent_server/internal/middleware"
 	"01agent_server/internal/models"
 	"01agent_server/internal/repository"
 	"01agent_server/internal/service"
+	"01agent_server/internal/tools"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -272,7 +275,7 @@ func (h *UserHandler) UpdateUserParameters(c *gin.Context) {
 	})
 }
 
-// GetUserSessions 获取用户活跃会话
+// GetUserSessions 获取用户在线设备列表
 func (h *UserHandler) GetUserSessions(c *gin.Context) {
 	userID, exists := middleware.GetCurrentUserID(c)
 	if !exists {
@@ -287,23 +290,79 @@ func (h *UserHandler) GetUserSessions(c *gin.Context) {
 		return
 	}
 
+	currentSessionID, _ := middleware.GetCurrentSessionID(c)
+
 	// 构建响应
-	var sessionList []gin.H
+	sessionList := make([]gin.H, 0, len(sessions))
 	for _, session := range sessions {
 		sessionList = append(sessionList, gin.H{
-			"id":         session.ID,
-			"ip_address": session.IPAddress,
-			"login_type": session.LoginType,
-			"created_at": session.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+			"id":               session.ID,
+			"ip_address":       session.IPAddress,
+			"login_type":       session.LoginType,
+			"device_id":        session.DeviceID,
+			"is_current":       session.ID == currentSessionID,
+			"last_active_time": session.LastActiveTime.Format("2006-01-02T15:04:05Z07:00"),
+			"created_at":       session.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 		})
 	}
 
+	maxSessions := 0
+	if user, err := h.userService.GetByID(userID); err == nil {
+		maxSessions = service.GetMaxSessions(user)
+	}
+
 	middleware.Success(c, "获取会话列表成功", gin.H{
-		"sessions": sessionList,
-		"count":    len(sessionList),
+		"sessions":     sessionList,
+		"count":        len(sessionList),
+		"max_sessions": maxSessions,
 	})
 }
 
+// RevokeUserSession 将指定设备下线
+func (h *UserHandler) RevokeUserSession(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		middleware.HandleError(c, middleware.NewBusinessError(401, "未授权访问"))
+		return
+	}
+
+	sessionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(400, "会话ID格式错误"))
+		return
+	}
+
+	if err := h.userService.RevokeSession(userID, sessionID); err != nil {
+		if err == service.ErrSessionNotFound {
+			middleware.HandleError(c, middleware.NewBusinessError(404, err.Error()))
+			return
+		}
+		repository.Errorf("RevokeUserSession failed: %v", err)
+		middleware.HandleError(c, middleware.NewBusinessError(500, "下线设备失败: "+err.Error()))
+		return
+	}
+
+	middleware.Success(c, "设备已下线", nil)
+}
+
+// RevokeOtherUserSessions 将除当前设备外的其他设备全部下线
+func (h *UserHandler) RevokeOtherUserSessions(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		middleware.HandleError(c, middleware.NewBusinessError(401, "未授权访问"))
+		return
+	}
+
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if err := h.userService.RevokeOtherSessions(userID, token); err != nil {
+		repository.Errorf("RevokeOtherUserSessions failed: %v", err)
+		middleware.HandleError(c, middleware.NewBusinessError(500, "下线其他设备失败: "+err.Error()))
+		return
+	}
+
+	middleware.Success(c, "其他设备已下线", nil)
+}
+
 // GetConfigTemplateList 获取配置模板列表（客户端接口）
 // @Summary 获取配置模板列表
 // @Description 获取当前用户的配置模板列表，支持分页和筛选
@@ -471,6 +530,11 @@ func (h *UserHandler) SaveConfigTemplate(c *gin.Context) {
 		template.TemplateType = models.ConfigTemplateTypeCustom
 	}
 
+	if result := tools.ValidateEmbeddedThemeConfig(req.ConfigData); result != nil && !result.Valid {
+		middleware.HandleErrorWithData(c, middleware.NewBusinessError(400, "主题配置校验失败: "+result.Summary()), result)
+		return
+	}
+
 	if req.ConfigData != nil {
 		configDataBytes, err := json.Marshal(req.ConfigData)
 		if err != nil {
@@ -950,6 +1014,10 @@ func SetupUserRoutes(r *gin.Engine, userHandler *UserHandler) {
 		userGroup.PUT("/parameters", userHandler.UpdateUserParameters)
 		// 获取用户会话列表 - /api/v1/user/sessions
 		userGroup.GET("/sessions", userHandler.GetUserSessions)
+		// 下线其他所有设备 - /api/v1/user/sessions/others
+		userGroup.DELETE("/sessions/others", userHandler.RevokeOtherUserSessions)
+		// 下线指定设备 - /api/v1/user/sessions/:id
+		userGroup.DELETE("/sessions/:id", userHandler.RevokeUserSession)
 		// 获取用户邀请关系和佣金信息 - /api/v1/user/get_invited_users
 		userGroup.POST("/get_invited_users", userHandler.GetInvitedUsers)
 	}
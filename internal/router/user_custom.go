@@ -9,6 +9,7 @@ import (
 	"01agent_server/internal/middleware"
 	"01agent_server/internal/models"
 	"01agent_server/internal/repository"
+	"01agent_server/internal/tools"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -375,6 +376,16 @@ func (h *UserCustomHandler) SaveCustomTheme(c *gin.Context) {
 		return
 	}
 
+	// 主题数据是统一主题配置时，保存前按 Schema 校验并迁移到当前版本
+	if req.Data != nil && tools.IsUnifiedThemeConfig(req.Data) {
+		data, result := tools.NormalizeThemeConfig(req.Data)
+		if !result.Valid {
+			middleware.HandleErrorWithData(c, middleware.NewBusinessError(http.StatusBadRequest, "主题配置校验失败: "+result.Summary()), result)
+			return
+		}
+		req.Data = data
+	}
+
 	var theme models.UserCustomTheme
 	var isNew bool
 
@@ -0,0 +1,155 @@
+package router
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+
+	"01agent_server/internal/config"
+	"01agent_server/internal/middleware"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/service/wechatmp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WechatPlatformHandler 微信第三方平台（公众号授权、授权事件推送）
+type WechatPlatformHandler struct{}
+
+// NewWechatPlatformHandler 创建第三方平台处理器
+func NewWechatPlatformHandler() *WechatPlatformHandler {
+	return &WechatPlatformHandler{}
+}
+
+// ReceiveAuthEvent 授权事件接收 - POST /api/v1/wechat/platform/ticket
+// 微信推送 component_verify_ticket 与授权变更，需应答 success，否则会重复推送
+func (h *WechatPlatformHandler) ReceiveAuthEvent(c *gin.Context) {
+	client, err := wechatmp.GetPlatformClient()
+	if err != nil {
+		repository.Errorf("Wechat platform unavailable for auth event: %v", err)
+		c.String(http.StatusServiceUnavailable, "fail")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.String(http.StatusBadRequest, "fail")
+		return
+	}
+
+	event, err := client.DecryptAuthEvent(body, c.Query("msg_signature"), c.Query("timestamp"), c.Query("nonce"))
+	if err != nil {
+		repository.Warnf("Decrypt wechat auth event failed: %v", err)
+		c.String(http.StatusBadRequest, "fail")
+		return
+	}
+
+	switch event.InfoType {
+	case wechatmp.InfoTypeVerifyTicket:
+		if err := client.SaveVerifyTicket(event.ComponentVerifyTicket); err != nil {
+			repository.Errorf("Save component_verify_ticket failed: %v", err)
+			c.String(http.StatusInternalServerError, "fail")
+			return
+		}
+	case wechatmp.InfoTypeUnauthorized:
+		if err := client.Unauthorize(event.AuthorizerAppID); err != nil {
+			repository.Errorf("Handle wechat unauthorized event failed: appid=%s, err=%v", event.AuthorizerAppID, err)
+			c.String(http.StatusInternalServerError, "fail")
+			return
+		}
+	default:
+		// 授权成功与更新授权以授权回调为准
+		repository.Infof("Wechat auth event: type=%s, appid=%s", event.InfoType, event.AuthorizerAppID)
+	}
+
+	c.String(http.StatusOK, "success")
+}
+
+// GetAuthURL 获取公众号授权链接 - GET /api/v1/wechat/platform/auth-url
+func (h *WechatPlatformHandler) GetAuthURL(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		middleware.HandleError(c, middleware.NewBusinessError(401, "未授权访问"))
+		return
+	}
+
+	client, err := wechatmp.GetPlatformClient()
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(503, err.Error()))
+		return
+	}
+
+	authURL, err := client.AuthorizationURL(userID)
+	if err != nil {
+		repository.Errorf("Create wechat auth url failed: user_id=%s, err=%v", userID, err)
+		middleware.HandleError(c, middleware.NewBusinessError(500, "获取授权链接失败: "+err.Error()))
+		return
+	}
+
+	middleware.Success(c, "获取成功", gin.H{"auth_url": authURL})
+}
+
+// AuthCallback 公众号授权回调 - GET /api/v1/wechat/platform/auth-callback
+// 配置了 AuthResultURL 时跳转到前端页面，否则返回JSON
+func (h *WechatPlatformHandler) AuthCallback(c *gin.Context) {
+	authCode := c.Query("auth_code")
+	state := c.Query("state")
+
+	appID, err := h.completeAuthorization(state, authCode)
+	if err != nil {
+		repository.Warnf("Wechat authorization failed: %v", err)
+	}
+
+	if resultURL := config.AppConfig.WechatPlatform.AuthResultURL; resultURL != "" {
+		target, parseErr := url.Parse(resultURL)
+		if parseErr == nil {
+			q := target.Query()
+			if err != nil {
+				q.Set("status", "fail")
+				q.Set("message", err.Error())
+			} else {
+				q.Set("status", "success")
+				q.Set("appid", appID)
+			}
+			target.RawQuery = q.Encode()
+			c.Redirect(http.StatusFound, target.String())
+			return
+		}
+	}
+
+	if err != nil {
+		middleware.HandleError(c, middleware.NewBusinessError(400, "公众号授权失败: "+err.Error()))
+		return
+	}
+	middleware.Success(c, "授权成功", gin.H{"appid": appID})
+}
+
+func (h *WechatPlatformHandler) completeAuthorization(state, authCode string) (string, error) {
+	if state == "" || authCode == "" {
+		return "", errors.New("缺少auth_code或state参数")
+	}
+	client, err := wechatmp.GetPlatformClient()
+	if err != nil {
+		return "", err
+	}
+	return client.CompleteAuthorization(state, authCode)
+}
+
+// SetupWechatPlatformRoutes 设置微信第三方平台路由
+func SetupWechatPlatformRoutes(r *gin.Engine) {
+	handler := NewWechatPlatformHandler()
+
+	// 微信服务器回调（公开，依靠签名校验 / state 校验）
+	callbackGroup := r.Group("/api/v1/wechat/platform")
+	{
+		callbackGroup.POST("/ticket", handler.ReceiveAuthEvent)
+		callbackGroup.GET("/auth-callback", handler.AuthCallback)
+	}
+
+	platformGroup := r.Group("/api/v1/wechat/platform")
+	platformGroup.Use(middleware.JWTAuth())
+	{
+		platformGroup.GET("/auth-url", handler.GetAuthURL)
+	}
+}
@@ -3,6 +3,8 @@ package service
 import (
 	"01agent_server/internal/models"
 	"01agent_server/internal/repository"
+	"01agent_server/internal/service/wechatmp"
+	"01agent_server/internal/tools"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -36,6 +38,26 @@ type PublishEditTaskRequest struct {
 	SectionHTML        *string `json:"section_html"`
 }
 
+// MarkdownRenderMode Markdown 渲染模式
+type MarkdownRenderMode int
+
+const (
+	// RenderModeHTML 普通 HTML，用于编辑预览与 section_html
+	RenderModeHTML MarkdownRenderMode = iota
+	// RenderModeWechat 公众号兼容模式，样式全部内联，只在发布到公众号时使用
+	RenderModeWechat
+)
+
+// PublishTaskError 发布失败原因，Retryable 表示网络异常、接口繁忙等可稍后重试的失败
+type PublishTaskError struct {
+	Reason    string
+	Retryable bool
+}
+
+func (e *PublishTaskError) Error() string {
+	return e.Reason
+}
+
 // ProcessPublishTask 处理发布任务（后台执行）
 func (s *ArticleEditService) ProcessPublishTask(editTaskID string, params *PublishEditTaskRequest, userID string) {
 	defer func() {
@@ -44,60 +66,301 @@ func (s *ArticleEditService) ProcessPublishTask(editTaskID string, params *Publi
 		}
 	}()
 
-	log.Printf("[ProcessPublishTask] 开始处理发布任务: edit_task_id=%s, user_id=%s", editTaskID, userID)
+	if err := s.PublishEditTask(editTaskID, params, userID); err != nil {
+		log.Printf("[ProcessPublishTask] 发布失败: edit_task_id=%s, err=%v", editTaskID, err)
+	}
+}
+
+// PublishEditTask 同步到公众号草稿箱，SyncOnline 时提交发布并等待审核结果
+// 失败原因会记录到任务的 publish_error 并以 *PublishTaskError 返回；等待审核超时不视为失败
+func (s *ArticleEditService) PublishEditTask(editTaskID string, params *PublishEditTaskRequest, userID string) error {
+	log.Printf("[PublishEditTask] 开始处理发布任务: edit_task_id=%s, user_id=%s", editTaskID, userID)
 
-	// 首先将状态设置为待发布
+	// 首先将状态设置为待发布，清除上一次的失败原因
 	if err := s.db.Model(&models.ArticleEditTask{}).
 		Where("id = ?", editTaskID).
-		Update("status", models.ArticleEditStatusPending).Error; err != nil {
-		log.Printf("[ProcessPublishTask] 更新状态失败: %v", err)
-		return
+		Updates(map[string]interface{}{
+			"status":        models.ArticleEditStatusPending,
+			"publish_error": nil,
+		}).Error; err != nil {
+		log.Printf("[PublishEditTask] 更新状态失败: %v", err)
+		return &PublishTaskError{Reason: fmt.Sprintf("更新状态失败: %v", err), Retryable: true}
 	}
 
 	// 查询编辑任务
 	var editTask models.ArticleEditTask
 	if err := s.db.Where("id = ?", editTaskID).First(&editTask).Error; err != nil {
-		log.Printf("[ProcessPublishTask] 找不到编辑任务: %v", err)
-		return
+		log.Printf("[PublishEditTask] 找不到编辑任务: %v", err)
+		return &PublishTaskError{Reason: "找不到编辑任务"}
 	}
 
 	// 查询用户信息
 	var user models.User
 	if err := s.db.Where("user_id = ?", userID).First(&user).Error; err != nil {
-		log.Printf("[ProcessPublishTask] 找不到用户: %v", err)
-		s.updateTaskStatus(editTaskID, models.ArticleEditStatusEditing)
-		return
+		log.Printf("[PublishEditTask] 找不到用户: %v", err)
+		return s.failPublish(editTaskID, models.ArticleEditStatusEditing, "找不到用户", false)
 	}
 
 	// 检查用户是否绑定公众号
 	if user.AppID == nil || *user.AppID == "" {
-		log.Printf("[ProcessPublishTask] 用户未配置appid")
-		s.updateTaskStatus(editTaskID, models.ArticleEditStatusEditing)
-		return
+		log.Printf("[PublishEditTask] 用户未配置appid")
+		return s.failPublish(editTaskID, models.ArticleEditStatusEditing, "用户未配置appid", false)
 	}
 
 	// 查询用户参数
 	var userParams models.UserParameters
 	if err := s.db.Where("user_id = ?", userID).First(&userParams).Error; err != nil {
-		log.Printf("[ProcessPublishTask] 找不到用户参数: %v", err)
-		s.updateTaskStatus(editTaskID, models.ArticleEditStatusEditing)
-		return
+		log.Printf("[PublishEditTask] 找不到用户参数: %v", err)
+		return s.failPublish(editTaskID, models.ArticleEditStatusEditing, "找不到用户参数", false)
 	}
 
 	if !userParams.IsGzhBind {
-		log.Printf("[ProcessPublishTask] 用户未绑定公众号")
-		s.updateTaskStatus(editTaskID, models.ArticleEditStatusEditing)
+		log.Printf("[PublishEditTask] 用户未绑定公众号")
+		return s.failPublish(editTaskID, models.ArticleEditStatusEditing, "用户未绑定公众号", false)
+	}
+
+	client, err := wechatmp.GetPlatformClient()
+	if err != nil {
+		log.Printf("[PublishEditTask] 获取第三方平台客户端失败: %v", err)
+		return s.failPublish(editTaskID, models.ArticleEditStatusEditing, err.Error(), false)
+	}
+	appID := *user.AppID
+
+	// 发布配置可选，请求参数优先
+	var publishConfig *models.ArticlePublishConfig
+	var cfg models.ArticlePublishConfig
+	if err := s.db.Where("edit_task_id = ?", editTaskID).First(&cfg).Error; err == nil {
+		publishConfig = &cfg
+	}
+
+	article, coverURL, err := s.buildDraftArticle(&editTask, params, publishConfig)
+	if err != nil {
+		log.Printf("[PublishEditTask] 组装草稿失败: %v", err)
+		return s.failPublish(editTaskID, models.ArticleEditStatusEditing, err.Error(), false)
+	}
+
+	// 1. 正文图片上传到微信图床，单张失败保留原地址
+	content, warnings := client.RewriteContentImages(appID, article.Content)
+	for _, w := range warnings {
+		log.Printf("[PublishEditTask] 正文图片上传失败: src=%s, err=%s", w.Src, w.Error)
+	}
+	article.Content = content
+
+	// 2. 上传封面图
+	thumbMediaID, err := client.UploadThumb(appID, coverURL)
+	if err != nil {
+		log.Printf("[PublishEditTask] 上传封面失败: %v", err)
+		return s.failPublish(editTaskID, models.ArticleEditStatusEditing, fmt.Sprintf("上传封面失败: %v", err), wechatmp.Retryable(err))
+	}
+	article.ThumbMediaID = thumbMediaID
+
+	// 3. 创建草稿
+	mediaID, err := client.AddDraft(appID, *article)
+	if err != nil {
+		log.Printf("[PublishEditTask] 创建草稿失败: %v", err)
+		return s.failPublish(editTaskID, models.ArticleEditStatusEditing, fmt.Sprintf("创建草稿失败: %v", err), wechatmp.Retryable(err))
+	}
+	if err := s.db.Model(&models.ArticleEditTask{}).Where("id = ?", editTaskID).Updates(map[string]interface{}{
+		"status":             models.ArticleEditStatusDraft,
+		"wechat_media_id":    mediaID,
+		"wechat_publish_id":  nil,
+		"wechat_article_url": nil,
+		"publish_error":      nil,
+	}).Error; err != nil {
+		log.Printf("[PublishEditTask] 保存草稿信息失败: %v", err)
+	}
+	log.Printf("[PublishEditTask] 已同步到草稿箱: edit_task_id=%s, media_id=%s", editTaskID, mediaID)
+
+	if params == nil || params.SyncOnline == nil || !*params.SyncOnline {
+		return nil
+	}
+
+	// 4. 提交发布并轮询结果，草稿已存在，重试会重复创建草稿，失败时保持草稿状态且不再重试
+	publishID, err := client.SubmitPublish(appID, mediaID)
+	if err != nil {
+		log.Printf("[PublishEditTask] 提交发布失败: %v", err)
+		return s.failPublish(editTaskID, models.ArticleEditStatusDraft, fmt.Sprintf("提交发布失败: %v", err), false)
+	}
+	if err := s.db.Model(&models.ArticleEditTask{}).Where("id = ?", editTaskID).
+		Update("wechat_publish_id", publishID).Error; err != nil {
+		log.Printf("[PublishEditTask] 保存publish_id失败: %v", err)
+	}
+
+	result, err := client.WaitForPublish(appID, publishID, wechatmp.DefaultPollInterval, wechatmp.DefaultPollTimeout)
+	if err != nil {
+		// 超时仍在审核中，状态查询接口会继续刷新
+		log.Printf("[PublishEditTask] 等待发布结果: edit_task_id=%s, publish_id=%s, err=%v", editTaskID, publishID, err)
+		return nil
+	}
+	s.ApplyPublishResult(editTaskID, result)
+	if !result.Pending() && !result.Succeeded() {
+		return &PublishTaskError{Reason: "公众号发布失败: " + result.StatusMessage()}
+	}
+	return nil
+}
+
+// buildDraftArticle 合并请求参数、发布配置与编辑任务生成草稿内容，返回草稿与封面图地址
+func (s *ArticleEditService) buildDraftArticle(editTask *models.ArticleEditTask, params *PublishEditTaskRequest, publishConfig *models.ArticlePublishConfig) (*wechatmp.DraftArticle, string, error) {
+	if params == nil {
+		params = &PublishEditTaskRequest{}
+	}
+	article := &wechatmp.DraftArticle{
+		Title:              editTask.Title,
+		NeedOpenComment:    1,
+		OnlyFansCanComment: 0,
+	}
+
+	if publishConfig != nil {
+		if publishConfig.PublishTitle != "" {
+			article.Title = publishConfig.PublishTitle
+		}
+		article.Author = publishConfig.AuthorName
+		if publishConfig.Summary != nil {
+			article.Digest = *publishConfig.Summary
+		}
+		article.NeedOpenComment = boolToInt(publishConfig.EnableComments)
+		article.OnlyFansCanComment = boolToInt(publishConfig.FollowersOnlyComment)
+	}
+	if params.Title != nil && *params.Title != "" {
+		article.Title = *params.Title
+	}
+	if params.Author != nil && *params.Author != "" {
+		article.Author = *params.Author
+	}
+	if params.Digest != nil && *params.Digest != "" {
+		article.Digest = *params.Digest
+	}
+	if params.NeedOpenComment != nil {
+		article.NeedOpenComment = *params.NeedOpenComment
+	}
+	if params.OnlyFansCanComment != nil {
+		article.OnlyFansCanComment = *params.OnlyFansCanComment
+	}
+	if article.Title == "" {
+		return nil, "", fmt.Errorf("缺少文章标题")
+	}
+
+	// 正文：请求中的HTML > 请求中的Markdown > 任务内容 > 已生成的section_html
+	// Markdown 按公众号兼容模式渲染，section_html 是预览用的普通 HTML，只在没有内容时兜底
+	theme := editTask.Theme
+	if theme == "" || theme == "none" {
+		theme = "default"
+	}
+	switch {
+	case params.SectionHTML != nil && *params.SectionHTML != "":
+		article.Content = *params.SectionHTML
+	case params.Content != nil && *params.Content != "":
+		htmlContent, _, err := s.ConvertMarkdownToHTML(*params.Content, theme, RenderModeWechat)
+		if err != nil {
+			return nil, "", fmt.Errorf("Markdown转换失败: %v", err)
+		}
+		article.Content = htmlContent
+	case editTask.Content != "":
+		htmlContent, _, err := s.ConvertMarkdownToHTML(editTask.Content, theme, RenderModeWechat)
+		if err != nil {
+			return nil, "", fmt.Errorf("Markdown转换失败: %v", err)
+		}
+		article.Content = htmlContent
+	case editTask.SectionHTML != nil && *editTask.SectionHTML != "":
+		article.Content = *editTask.SectionHTML
+	default:
+		return nil, "", fmt.Errorf("文章内容为空")
+	}
+
+	// 封面：请求参数 > 发布配置 > 正文第一张图 > 关联文章任务的第一张图
+	coverURL := ""
+	if params.ThumbURL != nil && *params.ThumbURL != "" {
+		coverURL = *params.ThumbURL
+	} else if publishConfig != nil && publishConfig.CoverImage != nil && *publishConfig.CoverImage != "" {
+		coverURL = *publishConfig.CoverImage
+	} else if first := wechatmp.FirstContentImage(article.Content); first != "" {
+		coverURL = first
+	} else if editTask.ArticleTaskID != nil {
+		var articleTask models.ArticleTask
+		if err := s.db.Where("id = ?", *editTask.ArticleTaskID).First(&articleTask).Error; err == nil {
+			coverURL, _ = s.GetFirstImageFromArticleTask(&articleTask)
+		}
+	}
+	if coverURL == "" {
+		return nil, "", fmt.Errorf("缺少封面图")
+	}
+
+	return article, coverURL, nil
+}
+
+// ApplyPublishResult 根据公众号发布结果更新任务，发布中不做处理
+func (s *ArticleEditService) ApplyPublishResult(editTaskID string, result *wechatmp.PublishResult) {
+	if result == nil || result.Pending() {
 		return
 	}
 
-	// TODO: 实现实际的发布逻辑
-	// 1. 上传封面图
-	// 2. 创建草稿
-	// 3. 如果需要，发布草稿
+	if !result.Succeeded() {
+		log.Printf("[ApplyPublishResult] 发布失败: edit_task_id=%s, status=%d", editTaskID, result.PublishStatus)
+		s.failPublish(editTaskID, models.ArticleEditStatusDraft, "公众号发布失败: "+result.StatusMessage(), false)
+		return
+	}
 
-	// 模拟发布成功，更新为草稿状态
-	log.Printf("[ProcessPublishTask] 发布任务模拟完成，更新状态为draft")
-	s.updateTaskStatus(editTaskID, models.ArticleEditStatusDraft)
+	updates := map[string]interface{}{
+		"status":        models.ArticleEditStatusPublished,
+		"published_at":  time.Now(),
+		"publish_error": nil,
+	}
+	if len(result.ArticleURLs) > 0 {
+		updates["wechat_article_url"] = result.ArticleURLs[0]
+	}
+	if err := s.db.Model(&models.ArticleEditTask{}).
+		Where("id = ? AND status <> ?", editTaskID, models.ArticleEditStatusPublished).
+		Updates(updates).Error; err != nil {
+		log.Printf("[ApplyPublishResult] 更新发布结果失败: %v", err)
+		return
+	}
+	log.Printf("[ApplyPublishResult] 发布成功: edit_task_id=%s", editTaskID)
+}
+
+// RefreshPublishStatus 查询公众号发布任务的最新结果并更新任务
+func (s *ArticleEditService) RefreshPublishStatus(editTask *models.ArticleEditTask) (*wechatmp.PublishResult, error) {
+	if editTask.WechatPublishID == nil || *editTask.WechatPublishID == "" {
+		return nil, nil
+	}
+
+	var user models.User
+	if err := s.db.Where("user_id = ?", editTask.UserID).First(&user).Error; err != nil {
+		return nil, err
+	}
+	if user.AppID == nil || *user.AppID == "" {
+		return nil, fmt.Errorf("用户未配置appid")
+	}
+
+	client, err := wechatmp.GetPlatformClient()
+	if err != nil {
+		return nil, err
+	}
+	result, err := client.GetPublishResult(*user.AppID, *editTask.WechatPublishID)
+	if err != nil {
+		return nil, err
+	}
+	s.ApplyPublishResult(editTask.ID, result)
+	return result, nil
+}
+
+// failPublish 记录发布失败原因并回退状态，返回对应的 *PublishTaskError
+func (s *ArticleEditService) failPublish(editTaskID string, status string, reason string, retryable bool) error {
+	if err := s.db.Model(&models.ArticleEditTask{}).
+		Where("id = ?", editTaskID).
+		Updates(map[string]interface{}{
+			"status":        status,
+			"publish_error": reason,
+		}).Error; err != nil {
+		log.Printf("[failPublish] 更新状态失败: %v", err)
+	}
+	return &PublishTaskError{Reason: reason, Retryable: retryable}
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
 }
 
 // updateTaskStatus 更新任务状态
@@ -117,35 +380,47 @@ func (s *ArticleEditService) updateTaskStatus(editTaskID string, status string)
 	}
 }
 
-// ConvertMarkdownToHTML 将Markdown转换为HTML
-func (s *ArticleEditService) ConvertMarkdownToHTML(content string, theme string) (string, error) {
-	// TODO: 实现Markdown到HTML的转换
-	// 这里需要集成markdown处理器
-	return content, nil
+// ConvertMarkdownToHTML 将Markdown转换为HTML（使用主题配置渲染）
+// mode 为 RenderModeWechat 时输出公众号兼容的HTML（样式全部内联）并返回降级报告，否则报告为空
+func (s *ArticleEditService) ConvertMarkdownToHTML(content string, theme string, mode MarkdownRenderMode) (string, *tools.WechatRenderReport, error) {
+	processor := tools.NewUnifiedMarkdownProcessor()
+	if mode == RenderModeWechat {
+		return processor.ProcessMarkdownForWechat(content, theme)
+	}
+	htmlContent, err := processor.ProcessMarkdown(content, theme)
+	return htmlContent, nil, err
 }
 
-// UpdateSectionHTML 更新section_html字段
-func (s *ArticleEditService) UpdateSectionHTML(editTask *models.ArticleEditTask) error {
+// UpdateSectionHTML 根据内容与主题重新生成并保存section_html字段
+// section_html 使用普通模式渲染，同时返回发布到公众号时的降级报告
+func (s *ArticleEditService) UpdateSectionHTML(editTask *models.ArticleEditTask) (*tools.WechatRenderReport, error) {
+	var report *tools.WechatRenderReport
 	if editTask.Content == "" {
 		editTask.SectionHTML = nil
-		return nil
-	}
+	} else {
+		theme := editTask.Theme
+		if theme == "" || theme == "none" {
+			theme = "default"
+		}
 
-	theme := editTask.Theme
-	if theme == "" || theme == "none" {
-		theme = "default"
+		htmlContent, _, err := s.ConvertMarkdownToHTML(editTask.Content, theme, RenderModeHTML)
+		if err != nil {
+			log.Printf("[UpdateSectionHTML] Markdown转换失败: %v", err)
+			return nil, err
+		}
+		if _, report, err = s.ConvertMarkdownToHTML(editTask.Content, theme, RenderModeWechat); err != nil {
+			log.Printf("[UpdateSectionHTML] 生成降级报告失败: %v", err)
+		}
+		editTask.SectionHTML = &htmlContent
 	}
 
-	// TODO: 调用Markdown转换
-	htmlContent, err := s.ConvertMarkdownToHTML(editTask.Content, theme)
-	if err != nil {
-		log.Printf("[UpdateSectionHTML] Markdown转换失败: %v", err)
-		editTask.SectionHTML = nil
-		return err
+	if err := s.db.Model(&models.ArticleEditTask{}).
+		Where("id = ?", editTask.ID).
+		Update("section_html", editTask.SectionHTML).Error; err != nil {
+		log.Printf("[UpdateSectionHTML] 保存section_html失败: %v", err)
+		return nil, err
 	}
-
-	editTask.SectionHTML = &htmlContent
-	return nil
+	return report, nil
 }
 
 // GetFirstImageFromArticleTask 从文章任务中获取第一张图片
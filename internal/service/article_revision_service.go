@@ -0,0 +1,239 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"01agent_server/internal/models"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/tools"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// editRevisionCoalesceWindow 距上一个保存快照不足该时间的连续保存不再生成新版本
+const editRevisionCoalesceWindow = 5 * time.Minute
+
+// 版本比较方式
+const (
+	RevisionDiffModeLine = "line"
+	RevisionDiffModeWord = "word"
+)
+
+var (
+	// ErrRevisionNotFound 版本不存在
+	ErrRevisionNotFound = errors.New("版本不存在")
+	// ErrEditConflict 编辑任务已被其他页面或协作者修改
+	ErrEditConflict = errors.New("内容已被其他页面修改，请合并后重试")
+)
+
+// ArticleRevisionService 编辑任务版本历史服务
+// 版本保存的是被覆盖前的内容，编辑任务本身始终是最新内容，版本号 0 表示当前内容
+type ArticleRevisionService struct {
+	db *gorm.DB
+}
+
+// NewArticleRevisionService 创建版本历史服务
+func NewArticleRevisionService() *ArticleRevisionService {
+	return &ArticleRevisionService{
+		db: repository.DB,
+	}
+}
+
+// RevisionDiff 两个版本之间的差异
+type RevisionDiff struct {
+	From    int             `json:"from"`
+	To      int             `json:"to"`
+	Mode    string          `json:"mode"`
+	Changed []string        `json:"changed"` // 有变化的字段(title/theme/content/params)
+	Title   *tools.TextDiff `json:"title,omitempty"`
+	Content *tools.TextDiff `json:"content"`
+}
+
+// UpdateWithRevision 更新编辑任务并递增编辑版本号，标题、主题、内容或参数有变化时先保存修改前的快照
+// expectedVersion 不为空时与当前编辑版本号比较，不一致返回 ErrEditConflict
+func (s *ArticleRevisionService) UpdateWithRevision(editTask *models.ArticleEditTask, updates map[string]interface{}, expectedVersion *int) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// 锁定任务行，读取最新内容，并发保存时快照依次生成
+		var current models.ArticleEditTask
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", editTask.ID).First(&current).Error; err != nil {
+			return err
+		}
+		if expectedVersion != nil && *expectedVersion != current.EditVersion {
+			return ErrEditConflict
+		}
+		if revisionChanged(&current, updates) {
+			if err := s.snapshot(tx, &current, models.EditRevisionSourceSave, nil); err != nil {
+				return err
+			}
+		}
+		updates["edit_version"] = gorm.Expr("edit_version + 1")
+		return tx.Model(editTask).Updates(updates).Error
+	})
+}
+
+// List 编辑任务的版本列表（不含内容），按版本号倒序
+func (s *ArticleRevisionService) List(editTaskID string) ([]models.ArticleEditRevision, error) {
+	var revisions []models.ArticleEditRevision
+	err := s.db.Select("id", "edit_task_id", "user_id", "revision", "title", "theme", "source", "restored_from", "created_at").
+		Where("edit_task_id = ?", editTaskID).
+		Order("revision DESC").
+		Find(&revisions).Error
+	return revisions, err
+}
+
+// Get 获取指定版本，revision 为 0 时返回编辑任务的当前内容
+func (s *ArticleRevisionService) Get(editTask *models.ArticleEditTask, revision int) (*models.ArticleEditRevision, error) {
+	if revision == 0 {
+		return &models.ArticleEditRevision{
+			EditTaskID: editTask.ID,
+			UserID:     editTask.UserID,
+			Title:      editTask.Title,
+			Theme:      editTask.Theme,
+			Content:    editTask.Content,
+			Params:     editTask.Params,
+			CreatedAt:  editTask.UpdatedAt,
+		}, nil
+	}
+
+	var rev models.ArticleEditRevision
+	err := s.db.Where("edit_task_id = ? AND revision = ?", editTask.ID, revision).First(&rev).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// Diff 比较两个版本，版本号 0 表示当前内容
+func (s *ArticleRevisionService) Diff(editTask *models.ArticleEditTask, from, to int, mode string) (*RevisionDiff, error) {
+	if mode != RevisionDiffModeWord {
+		mode = RevisionDiffModeLine
+	}
+	fromRev, err := s.Get(editTask, from)
+	if err != nil {
+		return nil, err
+	}
+	toRev, err := s.Get(editTask, to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &RevisionDiff{From: from, To: to, Mode: mode, Changed: []string{}}
+	if fromRev.Title != toRev.Title {
+		diff.Changed = append(diff.Changed, "title")
+		diff.Title = tools.DiffWords(fromRev.Title, toRev.Title)
+	}
+	if fromRev.Theme != toRev.Theme {
+		diff.Changed = append(diff.Changed, "theme")
+	}
+	if fromRev.Content != toRev.Content {
+		diff.Changed = append(diff.Changed, "content")
+	}
+	if stringValue(fromRev.Params) != stringValue(toRev.Params) {
+		diff.Changed = append(diff.Changed, "params")
+	}
+
+	if mode == RevisionDiffModeWord {
+		diff.Content = tools.DiffWords(fromRev.Content, toRev.Content)
+	} else {
+		diff.Content = tools.DiffLines(fromRev.Content, toRev.Content)
+	}
+	return diff, nil
+}
+
+// Restore 将编辑任务恢复到指定版本，恢复前的内容保存为新版本，可再次恢复
+func (s *ArticleRevisionService) Restore(editTask *models.ArticleEditTask, revision int, expectedVersion *int) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var rev models.ArticleEditRevision
+		err := tx.Where("edit_task_id = ? AND revision = ?", editTask.ID, revision).First(&rev).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRevisionNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var current models.ArticleEditTask
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", editTask.ID).First(&current).Error; err != nil {
+			return err
+		}
+		if expectedVersion != nil && *expectedVersion != current.EditVersion {
+			return ErrEditConflict
+		}
+		if err := s.snapshot(tx, &current, models.EditRevisionSourceRestore, &revision); err != nil {
+			return err
+		}
+
+		return tx.Model(&models.ArticleEditTask{}).Where("id = ?", editTask.ID).Updates(map[string]interface{}{
+			"title":        rev.Title,
+			"theme":        rev.Theme,
+			"content":      rev.Content,
+			"params":       rev.Params,
+			"edit_version": gorm.Expr("edit_version + 1"),
+		}).Error
+	})
+}
+
+// snapshot 保存编辑任务的当前内容为新版本
+// 与最新版本相同时跳过；保存产生的快照在合并窗口内只保留第一个
+func (s *ArticleRevisionService) snapshot(tx *gorm.DB, task *models.ArticleEditTask, source string, restoredFrom *int) error {
+	var latest models.ArticleEditRevision
+	err := tx.Where("edit_task_id = ?", task.ID).Order("revision DESC").First(&latest).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	found := err == nil
+
+	if found {
+		if latest.Title == task.Title && latest.Theme == task.Theme && latest.Content == task.Content &&
+			stringValue(latest.Params) == stringValue(task.Params) {
+			return nil
+		}
+		if source == models.EditRevisionSourceSave && latest.Source == models.EditRevisionSourceSave &&
+			time.Since(latest.CreatedAt) < editRevisionCoalesceWindow {
+			return nil
+		}
+	}
+
+	return tx.Create(&models.ArticleEditRevision{
+		EditTaskID:   task.ID,
+		UserID:       task.UserID,
+		Revision:     latest.Revision + 1,
+		Title:        task.Title,
+		Theme:        task.Theme,
+		Content:      task.Content,
+		Params:       task.Params,
+		Source:       source,
+		RestoredFrom: restoredFrom,
+	}).Error
+}
+
+// revisionChanged 更新是否修改了版本快照包含的字段
+func revisionChanged(task *models.ArticleEditTask, updates map[string]interface{}) bool {
+	if v, ok := updates["title"].(string); ok && v != task.Title {
+		return true
+	}
+	if v, ok := updates["theme"].(string); ok && v != task.Theme {
+		return true
+	}
+	if v, ok := updates["content"].(string); ok && v != task.Content {
+		return true
+	}
+	if v, ok := updates["params"].(string); ok && v != stringValue(task.Params) {
+		return true
+	}
+	return false
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
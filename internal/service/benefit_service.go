@@ -9,6 +9,7 @@ import (
 	"01agent_server/internal/models"
 	"01agent_server/internal/repository"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -52,7 +53,7 @@ func (s *BenefitService) GetUserBenefits(userID string) (map[string]interface{},
 	// 获取用户最新的有效订阅服务
 	db := repository.GetDB()
 	var userProduction models.UserProduction
-	err = db.Where("user_id = ? AND status = ?", userID, "active").
+	err = db.Where("user_productions.user_id = ? AND user_productions.status = ?", userID, "active").
 		Joins("JOIN productions ON user_productions.production_id = productions.id").
 		Where("productions.product_type = ?", "订阅服务").
 		Order("user_productions.created_at DESC").
@@ -61,7 +62,7 @@ func (s *BenefitService) GetUserBenefits(userID string) (map[string]interface{},
 		First(&userProduction).Error
 
 	// 获取当日每日积分
-	dailyBenefit, _ := s.getOrCreateDailyBenefit(userID)
+	dailyBenefit, _ := s.getOrCreateDailyBenefit(db, userID)
 	dailyCredits := dailyBenefit.DailyCredits
 	if dailyCredits < 0 {
 		dailyCredits = 0
@@ -187,21 +188,32 @@ func (s *BenefitService) GetUserBenefits(userID string) (map[string]interface{},
 // - isActive: 会员是否有效
 // - productName: 最新产品名称（无订阅或全部过期返回空字符串）
 func (s *BenefitService) CalculateMembershipExpireTime(userID string) (*time.Time, bool, string) {
-	db := repository.GetDB()
+	expireTime, isActive, productName, err := s.CalculateMembershipExpireTimeWithDB(repository.GetDB(), userID)
+	if err != nil {
+		repository.Errorf("计算用户 %s 会员过期时间失败: %v", userID, err)
+	}
+	return expireTime, isActive, productName
+}
+
+// CalculateMembershipExpireTimeWithDB 与 CalculateMembershipExpireTime 相同，使用指定连接查询（可在事务中调用）
+// 查询失败时返回错误，调用方据此回滚事务，避免按"无有效订阅"处理
+func (s *BenefitService) CalculateMembershipExpireTimeWithDB(db *gorm.DB, userID string) (*time.Time, bool, string, error) {
 	now := time.Now()
 
 	// 获取用户所有有效的订阅服务订单，按创建时间升序排列
 	var userProductions []models.UserProduction
-	err := db.Where("user_id = ? AND status = ?", userID, "active").
+	err := db.Where("user_productions.user_id = ? AND user_productions.status = ?", userID, "active").
 		Joins("JOIN productions ON user_productions.production_id = productions.id").
 		Where("productions.product_type = ?", "订阅服务").
 		Order("user_productions.created_at ASC").
 		Preload("Production").
 		Preload("Trade").
 		Find(&userProductions).Error
-
-	if err != nil || len(userProductions) == 0 {
-		return nil, false, ""
+	if err != nil {
+		return nil, false, "", fmt.Errorf("查询用户订阅失败: %w", err)
+	}
+	if len(userProductions) == 0 {
+		return nil, false, "", nil
 	}
 
 	// 链式计算最终过期时间
@@ -268,28 +280,27 @@ func (s *BenefitService) CalculateMembershipExpireTime(userID string) (*time.Tim
 
 	// 终身会员优先
 	if hasLifetime {
-		return nil, true, latestProductName
+		return nil, true, latestProductName, nil
 	}
 
 	if currentExpireAt != nil {
 		isActive := currentExpireAt.After(now)
 		if isActive {
-			return currentExpireAt, true, latestProductName
+			return currentExpireAt, true, latestProductName, nil
 		} else {
-			return currentExpireAt, false, ""
+			return currentExpireAt, false, "", nil
 		}
 	}
 
-	return nil, false, ""
+	return nil, false, "", nil
 }
 
 // getOrCreateDailyBenefit 获取或创建用户当日的每日权益记录
-func (s *BenefitService) getOrCreateDailyBenefit(userID string) (*models.UserDailyBenefit, error) {
+func (s *BenefitService) getOrCreateDailyBenefit(db *gorm.DB, userID string) (*models.UserDailyBenefit, error) {
 	today := time.Now()
 	todayStart := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, today.Location())
 	todayEnd := time.Date(today.Year(), today.Month(), today.Day(), 23, 59, 59, 999999999, today.Location())
 
-	db := repository.GetDB()
 	var dailyBenefit models.UserDailyBenefit
 	err := db.Where("user_id = ? AND created_at >= ? AND created_at <= ?", userID, todayStart, todayEnd).
 		First(&dailyBenefit).Error
@@ -340,8 +351,12 @@ func indexOf(s, substr string) int {
 // ProcessBenefitChanges 处理用户权益变更，包括积分变更和会员等级更新
 // 参考 Python 版本的 BenefitManager.process_benefit_changes
 func (s *BenefitService) ProcessBenefitChanges(user *models.User, production *models.Production, trade *models.Trade) (map[string]interface{}, error) {
-	db := repository.GetDB()
+	return s.ProcessBenefitChangesWithDB(repository.GetDB(), user, production, trade)
+}
 
+// ProcessBenefitChangesWithDB 使用指定的数据库连接处理用户权益变更
+// 传入事务时所有写入随事务一起提交或回滚，不会留下只发放了一半的权益
+func (s *BenefitService) ProcessBenefitChangesWithDB(db *gorm.DB, user *models.User, production *models.Production, trade *models.Trade) (map[string]interface{}, error) {
 	// 确保用户字段不为None，设置默认值
 	if user.Credits < 0 {
 		user.Credits = 0
@@ -380,12 +395,18 @@ func (s *BenefitService) ProcessBenefitChanges(user *models.User, production *mo
 	}
 
 	// 获取或创建用户参数
-	userParam, err := s.parametersRepo.GetByUserID(user.UserID)
-	if err != nil {
+	userParam := &models.UserParameters{}
+	if err := db.Where("user_id = ?", user.UserID).First(userParam).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("查询用户参数失败: %w", err)
+		}
 		userParam = &models.UserParameters{
-			UserID: user.UserID,
+			ParamID: uuid.New().String(),
+			UserID:  user.UserID,
+		}
+		if err := db.Create(userParam).Error; err != nil {
+			return nil, fmt.Errorf("创建用户参数失败: %w", err)
 		}
-		s.parametersRepo.Create(userParam)
 	}
 
 	// 用于记录发放的每月权益积分
@@ -445,12 +466,12 @@ func (s *BenefitService) ProcessBenefitChanges(user *models.User, production *mo
 
 				// 计算记录发生后的总积分
 				userCredits := user.Credits
-				dailyBenefit, _ := s.getOrCreateDailyBenefit(user.UserID)
+				dailyBenefit, _ := s.getOrCreateDailyBenefit(db, user.UserID)
 				dailyCredits := dailyBenefit.DailyCredits
 				if dailyCredits < 0 {
 					dailyCredits = 0
 				}
-				allMonthlyCredits := s.getValidMonthlyCredits(user.UserID)
+				allMonthlyCredits := s.getValidMonthlyCredits(db, user.UserID)
 				totalBalance := userCredits + dailyCredits + allMonthlyCredits
 
 				// 记录每月积分发放
@@ -473,7 +494,7 @@ func (s *BenefitService) ProcessBenefitChanges(user *models.User, production *mo
 		}
 
 		// 更新用户参数
-		if err := s.parametersRepo.Update(userParam); err != nil {
+		if err := db.Save(userParam).Error; err != nil {
 			return nil, fmt.Errorf("更新用户参数失败: %w", err)
 		}
 
@@ -485,7 +506,7 @@ func (s *BenefitService) ProcessBenefitChanges(user *models.User, production *mo
 
 		// 处理终身会员库存扣减
 		if contains(production.Name, "终身") {
-			s.decreaseProductStock(production)
+			s.decreaseProductStock(db, production)
 		}
 
 	} else if production.ProductType == "积分套餐" {
@@ -508,6 +529,7 @@ func (s *BenefitService) ProcessBenefitChanges(user *models.User, production *mo
 					OriginalCredits: creditsAmount,
 					SourceType:      models.TimedCreditSourcePackage,
 					SourceDesc:      stringPtr(fmt.Sprintf("购买%s", production.Name)),
+					TradeID:         &trade.ID,
 					ExpireAt:        expireAt,
 					CreatedAt:       time.Now(),
 					UpdatedAt:       time.Now(),
@@ -518,13 +540,13 @@ func (s *BenefitService) ProcessBenefitChanges(user *models.User, production *mo
 
 				// 计算记录发生后的总积分
 				userCredits := user.Credits
-				dailyBenefit, _ := s.getOrCreateDailyBenefit(user.UserID)
+				dailyBenefit, _ := s.getOrCreateDailyBenefit(db, user.UserID)
 				dailyCredits := dailyBenefit.DailyCredits
 				if dailyCredits < 0 {
 					dailyCredits = 0
 				}
-				timedCredits := s.getValidTimedCredits(user.UserID)
-				monthlyCredits := s.getValidMonthlyCredits(user.UserID)
+				timedCredits := s.getValidTimedCredits(db, user.UserID)
+				monthlyCredits := s.getValidMonthlyCredits(db, user.UserID)
 				totalBalance := userCredits + dailyCredits + timedCredits + monthlyCredits
 
 				// 记录积分获得
@@ -548,13 +570,13 @@ func (s *BenefitService) ProcessBenefitChanges(user *models.User, production *mo
 
 				// 计算记录发生后的总积分
 				userCredits := user.Credits
-				dailyBenefit, _ := s.getOrCreateDailyBenefit(user.UserID)
+				dailyBenefit, _ := s.getOrCreateDailyBenefit(db, user.UserID)
 				dailyCredits := dailyBenefit.DailyCredits
 				if dailyCredits < 0 {
 					dailyCredits = 0
 				}
-				timedCredits := s.getValidTimedCredits(user.UserID)
-				monthlyCredits := s.getValidMonthlyCredits(user.UserID)
+				timedCredits := s.getValidTimedCredits(db, user.UserID)
+				monthlyCredits := s.getValidMonthlyCredits(db, user.UserID)
 				totalBalance := userCredits + dailyCredits + timedCredits + monthlyCredits
 
 				// 记录积分获得
@@ -577,14 +599,14 @@ func (s *BenefitService) ProcessBenefitChanges(user *models.User, production *mo
 	}
 
 	// 保存用户更新
-	if err := s.userRepo.Update(user); err != nil {
+	if err := db.Save(user).Error; err != nil {
 		return nil, fmt.Errorf("更新用户失败: %w", err)
 	}
 
 	// 获取当前各类积分
-	totalCredits := s.getTotalCredits(user.UserID)
-	timedCredits := s.getValidTimedCredits(user.UserID)
-	monthlyCredits := s.getValidMonthlyCredits(user.UserID)
+	totalCredits := s.getTotalCredits(db, user.UserID)
+	timedCredits := s.getValidTimedCredits(db, user.UserID)
+	monthlyCredits := s.getValidMonthlyCredits(db, user.UserID)
 
 	// 返回变更结果
 	result := map[string]interface{}{
@@ -612,7 +634,7 @@ func (s *BenefitService) ProcessBenefitChanges(user *models.User, production *mo
 }
 
 // 辅助方法：扣减产品库存
-func (s *BenefitService) decreaseProductStock(production *models.Production) {
+func (s *BenefitService) decreaseProductStock(db *gorm.DB, production *models.Production) {
 	if production.ExtraInfo == nil {
 		return
 	}
@@ -631,7 +653,6 @@ func (s *BenefitService) decreaseProductStock(production *models.Production) {
 			if err == nil {
 				stockStr := string(extraInfoJSON)
 				production.ExtraInfo = &stockStr
-				db := repository.GetDB()
 				db.Model(production).Update("extra_info", stockStr)
 			}
 		}
@@ -639,8 +660,7 @@ func (s *BenefitService) decreaseProductStock(production *models.Production) {
 }
 
 // 辅助方法：获取有效的每月权益积分总额
-func (s *BenefitService) getValidMonthlyCredits(userID string) int {
-	db := repository.GetDB()
+func (s *BenefitService) getValidMonthlyCredits(db *gorm.DB, userID string) int {
 	now := time.Now()
 
 	var monthlyBenefits []models.UserMonthlyBenefit
@@ -655,8 +675,7 @@ func (s *BenefitService) getValidMonthlyCredits(userID string) int {
 }
 
 // 辅助方法：获取有效的有期限积分总额
-func (s *BenefitService) getValidTimedCredits(userID string) int {
-	db := repository.GetDB()
+func (s *BenefitService) getValidTimedCredits(db *gorm.DB, userID string) int {
 	now := time.Now()
 
 	var timedCredits []models.UserTimedCredits
@@ -670,9 +689,9 @@ func (s *BenefitService) getValidTimedCredits(userID string) int {
 }
 
 // 辅助方法：获取总积分
-func (s *BenefitService) getTotalCredits(userID string) int {
-	user, err := s.userRepo.GetByID(userID)
-	if err != nil {
+func (s *BenefitService) getTotalCredits(db *gorm.DB, userID string) int {
+	var user models.User
+	if err := db.Where("user_id = ?", userID).First(&user).Error; err != nil {
 		return 0
 	}
 
@@ -681,14 +700,14 @@ func (s *BenefitService) getTotalCredits(userID string) int {
 		userCredits = 0
 	}
 
-	dailyBenefit, _ := s.getOrCreateDailyBenefit(userID)
+	dailyBenefit, _ := s.getOrCreateDailyBenefit(db, userID)
 	dailyCredits := dailyBenefit.DailyCredits
 	if dailyCredits < 0 {
 		dailyCredits = 0
 	}
 
-	timedCredits := s.getValidTimedCredits(userID)
-	monthlyCredits := s.getValidMonthlyCredits(userID)
+	timedCredits := s.getValidTimedCredits(db, userID)
+	monthlyCredits := s.getValidMonthlyCredits(db, userID)
 
 	return userCredits + dailyCredits + timedCredits + monthlyCredits
 }
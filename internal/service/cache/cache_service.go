@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sync"
 
+	"01agent_server/internal/config"
 	"01agent_server/internal/repository"
 	"01agent_server/internal/tools"
 )
@@ -498,3 +499,42 @@ func (s *CacheService) DeleteCache(req *DeleteCacheRequest) (*DeleteCacheRespons
 		DeletedCount: int64(len(req.Keys)),
 	}, nil
 }
+
+// GetMarkdownCacheStats 获取本实例 Markdown 处理器的缓存统计
+func (s *CacheService) GetMarkdownCacheStats() *tools.MarkdownCacheStats {
+	return tools.GetMarkdownCacheStats()
+}
+
+// ClearMarkdownCacheRequest 清除 Markdown 缓存的请求参数
+type ClearMarkdownCacheRequest struct {
+	IncludeRedis bool `json:"include_redis"` // 同时清除 Redis 二级缓存中的渲染结果
+}
+
+// ClearMarkdownCacheResponse 清除 Markdown 缓存的响应
+type ClearMarkdownCacheResponse struct {
+	ConfigEntries int   `json:"config_entries"`
+	RenderEntries int   `json:"render_entries"`
+	RedisKeys     int64 `json:"redis_keys"`
+}
+
+// ClearMarkdownCache 清空本实例的主题配置与渲染结果缓存，可选同时清除 Redis 二级缓存
+func (s *CacheService) ClearMarkdownCache(req *ClearMarkdownCacheRequest) (*ClearMarkdownCacheResponse, error) {
+	configs, renders := tools.ClearMarkdownCache()
+	response := &ClearMarkdownCacheResponse{
+		ConfigEntries: configs,
+		RenderEntries: renders,
+	}
+
+	if req.IncludeRedis {
+		cleared, err := s.ClearCache(&ClearCacheRequest{
+			DBIndex: config.AppConfig.Redis.DB,
+			Pattern: tools.MarkdownRenderRedisPrefix + "*",
+		})
+		if err != nil {
+			return nil, err
+		}
+		response.RedisKeys = cleared.DeletedCount
+	}
+
+	return response, nil
+}
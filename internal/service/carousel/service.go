@@ -0,0 +1,372 @@
+package carousel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"01agent_server/internal/models/short_post"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/service"
+	"01agent_server/internal/service/exporter"
+	"01agent_server/internal/tools"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxBatchRows 单个任务最多生成的工程数
+const maxBatchRows = 500
+
+var (
+	// ErrJobNotFound 批量任务不存在
+	ErrJobNotFound = errors.New("批量任务不存在")
+	// ErrNoTemplate 没有指定模板或模板工程
+	ErrNoTemplate = errors.New("请指定模板或作为模板的工程")
+	// ErrEmptyTable 没有可生成的数据行
+	ErrEmptyTable = errors.New("没有可生成的数据行")
+	// ErrTooManyRows 数据行超过上限
+	ErrTooManyRows = fmt.Errorf("单次最多生成 %d 个工程", maxBatchRows)
+	// ErrJobRunning 任务仍在生成中
+	ErrJobRunning = errors.New("任务正在生成中，请稍后再试")
+	// ErrUnknownColumn 映射中的列不在数据中
+	ErrUnknownColumn = errors.New("映射的列不存在")
+)
+
+// 文案字段对应的映射目标，其余目标作为模板占位符
+const (
+	targetName    = "name"
+	targetTitle   = "title"
+	targetContent = "content"
+	targetTopics  = "topics"
+	targetImages  = "images"
+)
+
+// indexedSlot 带序号的占位符，如 paragraph_2、image_1、topic_3
+var indexedSlot = regexp.MustCompile(`^(paragraph|image|topic)_(\d+)$`)
+
+// CreateJobParams 创建批量任务参数
+type CreateJobParams struct {
+	Name              string
+	TemplateID        string // 模板ID，与 TemplateProjectID 二选一
+	TemplateProjectID string // 作为模板使用的工程ID
+	Format            string
+	Table             *Table
+	Mapping           map[string]string // 列名 -> 占位符，为空时列名即占位符
+}
+
+// Service 批量文案生成短图文服务
+type Service struct {
+	db          *gorm.DB
+	templateSvc *service.ProjectTemplateService
+	exportSvc   *exporter.Service
+}
+
+// NewService 创建批量生成服务
+func NewService() *Service {
+	return &Service{
+		db:          repository.DB,
+		templateSvc: service.NewProjectTemplateService(),
+		exportSvc:   exporter.NewService(),
+	}
+}
+
+// CreateJob 校验模板与映射后保存任务与每一行数据，由后台逐行生成工程
+func (s *Service) CreateJob(userID string, params CreateJobParams) (*short_post.ShortPostBatchJob, error) {
+	if params.TemplateID == "" && params.TemplateProjectID == "" {
+		return nil, ErrNoTemplate
+	}
+	if params.Table == nil || len(params.Table.Rows) == 0 {
+		return nil, ErrEmptyTable
+	}
+	if len(params.Table.Rows) > maxBatchRows {
+		return nil, ErrTooManyRows
+	}
+	columns := make(map[string]bool, len(params.Table.Columns))
+	for _, column := range params.Table.Columns {
+		columns[column] = true
+	}
+	for column := range params.Mapping {
+		if !columns[column] {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownColumn, column)
+		}
+	}
+
+	// 创建时确认模板可用，避免整个任务在后台失败
+	source, err := s.templateSvc.ResolveSource(userID, params.TemplateID, params.TemplateProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	name := params.Name
+	if name == "" {
+		name = fmt.Sprintf("%s 批量生成 %s", source.Name, time.Now().Format("01-02 15:04"))
+	}
+	columnsJSON, _ := json.Marshal(params.Table.Columns)
+	job := &short_post.ShortPostBatchJob{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		Name:         name,
+		SourceFormat: params.Format,
+		Columns:      tools.StringPtr(string(columnsJSON)),
+		Status:       short_post.BatchJobStatusPending,
+		TotalRows:    len(params.Table.Rows),
+	}
+	if params.TemplateID != "" {
+		job.TemplateID = tools.StringPtr(params.TemplateID)
+	} else {
+		job.TemplateProjectID = tools.StringPtr(params.TemplateProjectID)
+	}
+	if len(params.Mapping) > 0 {
+		mappingJSON, _ := json.Marshal(params.Mapping)
+		job.Mapping = tools.StringPtr(string(mappingJSON))
+	}
+
+	rows := make([]short_post.ShortPostBatchRow, 0, len(params.Table.Rows))
+	for i, row := range params.Table.Rows {
+		data, _ := json.Marshal(row)
+		rows = append(rows, short_post.ShortPostBatchRow{
+			JobID:    job.ID,
+			RowIndex: i + 1,
+			Data:     string(data),
+			Status:   short_post.BatchRowStatusPending,
+		})
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(job).Error; err != nil {
+			return err
+		}
+		return tx.CreateInBatches(rows, 100).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	GetWorker().Kick()
+	return job, nil
+}
+
+// GetJob 查询用户的批量任务
+func (s *Service) GetJob(userID, jobID string) (*short_post.ShortPostBatchJob, error) {
+	var job short_post.ShortPostBatchJob
+	err := s.db.Where("id = ? AND user_id = ?", jobID, userID).First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListJobs 用户的批量任务列表
+func (s *Service) ListJobs(userID string, page, pageSize int) ([]short_post.ShortPostBatchJob, int64, error) {
+	query := s.db.Model(&short_post.ShortPostBatchJob{}).Where("user_id = ?", userID)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var jobs []short_post.ShortPostBatchJob
+	err := query.Order("created_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&jobs).Error
+	return jobs, total, err
+}
+
+// ListRows 任务的行列表，status 为空时返回所有行
+func (s *Service) ListRows(jobID string, status short_post.BatchRowStatus, page, pageSize int) ([]short_post.ShortPostBatchRow, int64, error) {
+	query := s.db.Model(&short_post.ShortPostBatchRow{}).Where("job_id = ?", jobID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var rows []short_post.ShortPostBatchRow
+	err := query.Order("row_index ASC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&rows).Error
+	return rows, total, err
+}
+
+// Retry 将失败的行重新排队，任务重新进入等待生成状态
+func (s *Service) Retry(userID, jobID string) (*short_post.ShortPostBatchJob, int64, error) {
+	job, err := s.GetJob(userID, jobID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if job.Status == short_post.BatchJobStatusPending || job.Status == short_post.BatchJobStatusRunning {
+		return nil, 0, ErrJobRunning
+	}
+
+	var retried int64
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&short_post.ShortPostBatchRow{}).
+			Where("job_id = ? AND status = ?", jobID, short_post.BatchRowStatusFailed).
+			Updates(map[string]interface{}{"status": short_post.BatchRowStatusPending, "error": nil})
+		if result.Error != nil {
+			return result.Error
+		}
+		retried = result.RowsAffected
+		if retried == 0 {
+			return nil
+		}
+		return tx.Model(&short_post.ShortPostBatchJob{}).
+			Where("id = ? AND status = ?", jobID, job.Status).
+			Updates(map[string]interface{}{
+				"status":       short_post.BatchJobStatusPending,
+				"failed_rows":  gorm.Expr("failed_rows - ?", retried),
+				"attempts":     0,
+				"error":        nil,
+				"locked_until": nil,
+				"finished_at":  nil,
+			}).Error
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if retried > 0 {
+		GetWorker().Kick()
+	}
+	job, err = s.GetJob(userID, jobID)
+	return job, retried, err
+}
+
+// ExportResult 批量导出中单个工程的导出任务
+type ExportResult struct {
+	RowIndex  int    `json:"row_index"`
+	ProjectID string `json:"project_id"`
+	JobID     string `json:"job_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Export 为任务中生成成功的工程逐个创建服务端导出任务
+func (s *Service) Export(userID, jobID string, format short_post.ExportFormat, options exporter.JobOptions) ([]ExportResult, error) {
+	job, err := s.GetJob(userID, jobID)
+	if err != nil {
+		return nil, err
+	}
+	var rows []short_post.ShortPostBatchRow
+	if err := s.db.Select("row_index", "project_id").
+		Where("job_id = ? AND status = ? AND project_id IS NOT NULL", job.ID, short_post.BatchRowStatusSucceeded).
+		Order("row_index ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	results := make([]ExportResult, 0, len(rows))
+	for _, row := range rows {
+		result := ExportResult{RowIndex: row.RowIndex, ProjectID: *row.ProjectID}
+		exportJob, err := s.exportSvc.CreateJob(userID, exporter.CreateJobParams{
+			ProjectID: *row.ProjectID,
+			Format:    format,
+			Options:   options,
+		})
+		if errors.Is(err, exporter.ErrUnsupportedFormat) {
+			return nil, err
+		}
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.JobID = exportJob.ID
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// rowParams 按映射把一行数据转为创建工程的文案与占位符
+// 映射到 title/content/topics/images 的列填入文案，name 作为工程名称，其余作为占位符；
+// 未映射 content、images、topics 时分别由 paragraph_N、image_N、topic_N 按序号组合
+func rowParams(row map[string]string, mapping map[string]string) service.UseTemplateParams {
+	if len(mapping) == 0 {
+		mapping = make(map[string]string, len(row))
+		for column := range row {
+			mapping[column] = column
+		}
+	}
+	// 按列名排序，多列映射到同一字段时拼接顺序稳定
+	columns := make([]string, 0, len(mapping))
+	for column := range mapping {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	cw := &service.TemplateCopywriting{}
+	params := service.UseTemplateParams{Copywriting: cw, Slots: map[string]string{}}
+	var contents []string
+	indexed := map[string]map[int]string{}
+	for _, column := range columns {
+		target := strings.TrimSpace(mapping[column])
+		value := strings.TrimSpace(row[column])
+		if target == "" || value == "" {
+			continue
+		}
+		switch target {
+		case targetName:
+			params.Name = value
+		case targetTitle:
+			cw.Title = value
+		case targetContent:
+			contents = append(contents, value)
+		case targetTopics:
+			cw.Topics = append(cw.Topics, splitList(value, true)...)
+		case targetImages:
+			cw.Images = append(cw.Images, splitList(value, false)...)
+		default:
+			params.Slots[target] = value
+			if m := indexedSlot.FindStringSubmatch(target); m != nil {
+				n, _ := strconv.Atoi(m[2])
+				if indexed[m[1]] == nil {
+					indexed[m[1]] = map[int]string{}
+				}
+				indexed[m[1]][n] = value
+			}
+		}
+	}
+
+	cw.Content = strings.Join(contents, "\n")
+	if cw.Content == "" {
+		cw.Content = strings.Join(orderedValues(indexed["paragraph"]), "\n")
+	}
+	if len(cw.Images) == 0 {
+		cw.Images = orderedValues(indexed["image"])
+	}
+	if len(cw.Topics) == 0 {
+		cw.Topics = orderedValues(indexed["topic"])
+	}
+	return params
+}
+
+// splitList 拆分单元格中的多个值，话题还会按空格与 # 拆分
+func splitList(value string, topics bool) []string {
+	separators := ",，、;；\n\r"
+	if topics {
+		separators += " #"
+	}
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return strings.ContainsRune(separators, r)
+	})
+	items := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if field = strings.TrimSpace(field); field != "" {
+			items = append(items, field)
+		}
+	}
+	return items
+}
+
+func orderedValues(values map[int]string) []string {
+	keys := make([]int, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	result := make([]string, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, values[k])
+	}
+	return result
+}
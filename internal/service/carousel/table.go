@@ -0,0 +1,336 @@
+package carousel
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 数据来源格式
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+	FormatJSON = "json"
+)
+
+// maxSheetBytes xlsx 中单个 XML 文件解压后的大小上限，防止压缩炸弹
+const maxSheetBytes = 50 << 20
+
+// Table 解析后的表格，第一行为列名
+type Table struct {
+	Columns []string
+	Rows    []map[string]string
+}
+
+// ParseTable 按格式解析上传的数据，空行会被跳过
+func ParseTable(format string, data []byte) (*Table, error) {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return parseCSV(data)
+	case FormatXLSX:
+		return parseXLSX(data)
+	case FormatJSON:
+		return parseJSON(data)
+	}
+	return nil, fmt.Errorf("不支持的数据格式: %s，仅支持 csv、xlsx、json", format)
+}
+
+// FormatFromFilename 根据文件扩展名判断数据格式
+func FormatFromFilename(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".csv":
+		return FormatCSV
+	case ".xlsx":
+		return FormatXLSX
+	case ".json":
+		return FormatJSON
+	}
+	return ""
+}
+
+func parseCSV(data []byte) (*Table, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("CSV 解析失败: %w", err)
+	}
+	return tableFromRecords(records)
+}
+
+func parseJSON(data []byte) (*Table, error) {
+	var items []map[string]interface{}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("JSON 需要是对象数组: %w", err)
+	}
+
+	table := &Table{}
+	seen := map[string]bool{}
+	for _, item := range items {
+		// 对象的键无序，列名按首次出现排序后追加，保证同一份数据结果稳定
+		var keys []string
+		for key := range item {
+			if !seen[key] {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			seen[key] = true
+			table.Columns = append(table.Columns, key)
+		}
+
+		row := make(map[string]string, len(item))
+		empty := true
+		for key, value := range item {
+			row[key] = jsonCellText(value)
+			if strings.TrimSpace(row[key]) != "" {
+				empty = false
+			}
+		}
+		if !empty {
+			table.Rows = append(table.Rows, row)
+		}
+	}
+	return table, nil
+}
+
+// jsonCellText JSON 值转为单元格文本，数组按行拼接
+func jsonCellText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			parts = append(parts, jsonCellText(item))
+		}
+		return strings.Join(parts, "\n")
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// tableFromRecords 第一行作为列名，空列名按列号命名，重复列名加序号
+func tableFromRecords(records [][]string) (*Table, error) {
+	if len(records) == 0 {
+		return nil, errors.New("数据为空")
+	}
+	table := &Table{}
+	seen := map[string]int{}
+	for i, name := range records[0] {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			name = columnName(i)
+		}
+		seen[name]++
+		if seen[name] > 1 {
+			name = fmt.Sprintf("%s_%d", name, seen[name])
+		}
+		table.Columns = append(table.Columns, name)
+	}
+
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(table.Columns))
+		empty := true
+		for i, column := range table.Columns {
+			if i < len(record) {
+				row[column] = record[i]
+				if strings.TrimSpace(record[i]) != "" {
+					empty = false
+				}
+			}
+		}
+		if !empty {
+			table.Rows = append(table.Rows, row)
+		}
+	}
+	return table, nil
+}
+
+// columnName 列号转为 Excel 列名，0 -> A，26 -> AA
+func columnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+// columnIndex 单元格引用中的列号，"B3" -> 1
+func columnIndex(ref string) int {
+	index := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		index = index*26 + int(r-'A') + 1
+	}
+	return index - 1
+}
+
+// xlsx 中用到的 XML 结构，只读取第一个工作表的单元格文本
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxRichText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxRichText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+	var b strings.Builder
+	for _, run := range t.Runs {
+		b.WriteString(run.Text)
+	}
+	return b.String()
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxRichText `xml:"si"`
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []struct {
+			Ref    string        `xml:"r,attr"`
+			Type   string        `xml:"t,attr"`
+			Value  string        `xml:"v"`
+			Inline *xlsxRichText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func parseXLSX(data []byte) (*Table, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("xlsx 文件无法打开: %w", err)
+	}
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	sheetPath, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+	var shared xlsxSharedStrings
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decodeZipXML(f, &shared); err != nil {
+			return nil, fmt.Errorf("xlsx 共享字符串解析失败: %w", err)
+		}
+	}
+	f, ok := files[sheetPath]
+	if !ok {
+		return nil, fmt.Errorf("xlsx 缺少工作表 %s", sheetPath)
+	}
+	var sheet xlsxSheet
+	if err := decodeZipXML(f, &sheet); err != nil {
+		return nil, fmt.Errorf("xlsx 工作表解析失败: %w", err)
+	}
+
+	records := make([][]string, 0, len(sheet.Rows))
+	for _, row := range sheet.Rows {
+		var record []string
+		for i, cell := range row.Cells {
+			col := i
+			if cell.Ref != "" {
+				col = columnIndex(cell.Ref)
+			}
+			if col < 0 {
+				continue
+			}
+			for len(record) <= col {
+				record = append(record, "")
+			}
+			switch cell.Type {
+			case "s":
+				if idx, err := strconv.Atoi(cell.Value); err == nil && idx >= 0 && idx < len(shared.Items) {
+					record[col] = shared.Items[idx].String()
+				}
+			case "inlineStr":
+				if cell.Inline != nil {
+					record[col] = cell.Inline.String()
+				}
+			case "b":
+				record[col] = strconv.FormatBool(cell.Value == "1")
+			default:
+				record[col] = cell.Value
+			}
+		}
+		records = append(records, record)
+	}
+	return tableFromRecords(records)
+}
+
+// firstSheetPath 从 workbook 关系中找到第一个工作表的文件路径
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	const fallback = "xl/worksheets/sheet1.xml"
+	wb, ok := files["xl/workbook.xml"]
+	rels, relsOK := files["xl/_rels/workbook.xml.rels"]
+	if !ok || !relsOK {
+		return fallback, nil
+	}
+	var workbook xlsxWorkbook
+	if err := decodeZipXML(wb, &workbook); err != nil || len(workbook.Sheets) == 0 {
+		return fallback, nil
+	}
+	var relationships xlsxRelationships
+	if err := decodeZipXML(rels, &relationships); err != nil {
+		return fallback, nil
+	}
+	for _, rel := range relationships.Relationships {
+		if rel.ID == workbook.Sheets[0].RID {
+			target := strings.TrimPrefix(rel.Target, "/")
+			if !strings.HasPrefix(target, "xl/") {
+				target = path.Join("xl", target)
+			}
+			return target, nil
+		}
+	}
+	return fallback, nil
+}
+
+func decodeZipXML(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(io.LimitReader(rc, maxSheetBytes)).Decode(v)
+}
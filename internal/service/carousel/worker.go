@@ -0,0 +1,348 @@
+package carousel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"01agent_server/internal/models"
+	"01agent_server/internal/models/short_post"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/service"
+	"01agent_server/internal/tools"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	batchPollInterval = 10 * time.Second
+	batchLockDuration = 2 * time.Minute // 每生成一行续期
+	batchMaxAttempts  = 3
+	batchRowsPerFetch = 50
+)
+
+// errRowClaimed 行已被其他实例处理
+var errRowClaimed = errors.New("row already processed")
+
+// Worker 批量生成任务执行器
+// 任务以状态与尝试次数为条件更新抢占；每一行在一个事务中创建工程并更新行状态与计数，
+// 进程中断后重新执行时只处理仍在等待的行
+type Worker struct {
+	db          *gorm.DB
+	templateSvc *service.ProjectTemplateService
+	kick        chan struct{}
+	running     sync.Once
+}
+
+var (
+	workerInstance *Worker
+	workerOnce     sync.Once
+)
+
+// GetWorker 获取批量生成执行器单例
+func GetWorker() *Worker {
+	workerOnce.Do(func() {
+		workerInstance = &Worker{
+			db:          repository.DB,
+			templateSvc: service.NewProjectTemplateService(),
+			kick:        make(chan struct{}, 1),
+		}
+	})
+	return workerInstance
+}
+
+// Start 启动执行协程，重复调用只启动一次
+func (w *Worker) Start() {
+	w.running.Do(func() {
+		go w.loop()
+		repository.Infof("Short post batch worker started")
+	})
+}
+
+// Kick 通知执行协程立即领取任务
+func (w *Worker) Kick() {
+	select {
+	case w.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Worker) loop() {
+	ticker := time.NewTicker(batchPollInterval)
+	defer ticker.Stop()
+	for {
+		for w.runNext() {
+		}
+		select {
+		case <-ticker.C:
+		case <-w.kick:
+		}
+	}
+}
+
+// runNext 领取并执行一个任务，没有可执行的任务时返回 false
+func (w *Worker) runNext() bool {
+	job, err := w.claim()
+	if err != nil {
+		repository.Errorf("Claim batch job failed: %v", err)
+		return false
+	}
+	if job == nil {
+		return false
+	}
+	w.process(job)
+	return true
+}
+
+// claim 领取等待中或锁已过期的任务
+func (w *Worker) claim() (*short_post.ShortPostBatchJob, error) {
+	now := time.Now()
+	var candidates []short_post.ShortPostBatchJob
+	err := w.db.Where("status IN ? AND (locked_until IS NULL OR locked_until < ?)",
+		[]short_post.BatchJobStatus{short_post.BatchJobStatusPending, short_post.BatchJobStatusRunning}, now).
+		Order("created_at ASC").
+		Limit(5).
+		Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range candidates {
+		job := &candidates[i]
+		if job.Status == short_post.BatchJobStatusRunning && job.Attempts >= batchMaxAttempts {
+			// 多次执行中断的任务不再重试，剩余行标记失败，可通过重试接口重新生成
+			w.abort(job, "生成多次中断，请重试失败的行")
+			continue
+		}
+		updates := map[string]interface{}{
+			"status":       short_post.BatchJobStatusRunning,
+			"attempts":     job.Attempts + 1,
+			"locked_until": now.Add(batchLockDuration),
+		}
+		if job.StartedAt == nil {
+			updates["started_at"] = now
+		}
+		result := w.db.Model(&short_post.ShortPostBatchJob{}).
+			Where("id = ? AND status = ? AND attempts = ? AND (locked_until IS NULL OR locked_until < ?)", job.ID, job.Status, job.Attempts, now).
+			Updates(updates)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			job.Attempts++
+			job.Status = short_post.BatchJobStatusRunning
+			return job, nil
+		}
+	}
+	return nil, nil
+}
+
+func (w *Worker) process(job *short_post.ShortPostBatchJob) {
+	defer func() {
+		if r := recover(); r != nil {
+			repository.Errorf("Batch job %s panic: %v", job.ID, r)
+			w.abort(job, fmt.Sprintf("生成异常: %v", r))
+		}
+	}()
+
+	source, err := w.templateSvc.ResolveSource(job.UserID, stringValue(job.TemplateID), stringValue(job.TemplateProjectID))
+	if err != nil {
+		if errors.Is(err, service.ErrTemplateNotFound) || errors.Is(err, service.ErrTemplateSourceNotFound) {
+			w.abort(job, "模板已删除或不可用: "+err.Error())
+			return
+		}
+		// 数据库等临时错误，锁过期后重新执行
+		repository.Warnf("Batch job %s load template failed: %v", job.ID, err)
+		return
+	}
+	var mapping map[string]string
+	if job.Mapping != nil {
+		json.Unmarshal([]byte(*job.Mapping), &mapping)
+	}
+
+	for {
+		var rows []short_post.ShortPostBatchRow
+		if err := w.db.Where("job_id = ? AND status = ?", job.ID, short_post.BatchRowStatusPending).
+			Order("row_index ASC").Limit(batchRowsPerFetch).Find(&rows).Error; err != nil {
+			repository.Warnf("Batch job %s load rows failed: %v", job.ID, err)
+			return
+		}
+		if len(rows) == 0 {
+			break
+		}
+		for i := range rows {
+			w.processRow(job, source, mapping, &rows[i])
+			w.db.Model(&short_post.ShortPostBatchJob{}).Where("id = ?", job.ID).
+				Update("locked_until", time.Now().Add(batchLockDuration))
+		}
+	}
+	w.finish(job)
+}
+
+// processRow 为一行数据创建工程，工程、行状态与任务计数在同一事务中更新
+func (w *Worker) processRow(job *short_post.ShortPostBatchJob, source *service.TemplateSource, mapping map[string]string, row *short_post.ShortPostBatchRow) {
+	var data map[string]string
+	if err := json.Unmarshal([]byte(row.Data), &data); err != nil {
+		w.failRow(job, row, "行数据解析失败")
+		return
+	}
+	params := rowParams(data, mapping)
+
+	err := w.db.Transaction(func(tx *gorm.DB) error {
+		project, unfilled, err := w.templateSvc.Instantiate(tx, job.UserID, source, params)
+		if err != nil {
+			return err
+		}
+		unfilledJSON, _ := json.Marshal(unfilled)
+		result := tx.Model(&short_post.ShortPostBatchRow{}).
+			Where("id = ? AND status = ?", row.ID, short_post.BatchRowStatusPending).
+			Updates(map[string]interface{}{
+				"status":         short_post.BatchRowStatusSucceeded,
+				"project_id":     project.ID,
+				"unfilled_slots": string(unfilledJSON),
+				"error":          nil,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errRowClaimed
+		}
+		return tx.Model(&short_post.ShortPostBatchJob{}).Where("id = ?", job.ID).
+			Update("succeeded_rows", gorm.Expr("succeeded_rows + 1")).Error
+	})
+	if errors.Is(err, errRowClaimed) {
+		return
+	}
+	if err != nil {
+		w.failRow(job, row, err.Error())
+	}
+}
+
+func (w *Worker) failRow(job *short_post.ShortPostBatchJob, row *short_post.ShortPostBatchRow, message string) {
+	err := w.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&short_post.ShortPostBatchRow{}).
+			Where("id = ? AND status = ?", row.ID, short_post.BatchRowStatusPending).
+			Updates(map[string]interface{}{
+				"status": short_post.BatchRowStatusFailed,
+				"error":  message,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Model(&short_post.ShortPostBatchJob{}).Where("id = ?", job.ID).
+			Update("failed_rows", gorm.Expr("failed_rows + 1")).Error
+	})
+	if err != nil {
+		repository.Errorf("Save batch row %d of job %s failure failed: %v", row.RowIndex, job.ID, err)
+	}
+}
+
+// abort 任务无法继续时将剩余行标记失败并结束任务
+func (w *Worker) abort(job *short_post.ShortPostBatchJob, message string) {
+	err := w.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&short_post.ShortPostBatchRow{}).
+			Where("job_id = ? AND status = ?", job.ID, short_post.BatchRowStatusPending).
+			Updates(map[string]interface{}{
+				"status": short_post.BatchRowStatusFailed,
+				"error":  message,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		return tx.Model(&short_post.ShortPostBatchJob{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+			"failed_rows": gorm.Expr("failed_rows + ?", result.RowsAffected),
+			"error":       message,
+		}).Error
+	})
+	if err != nil {
+		repository.Errorf("Abort batch job %s failed: %v", job.ID, err)
+		return
+	}
+	w.finish(job)
+}
+
+// finish 按行状态统计结果，结束任务并通知用户
+func (w *Worker) finish(job *short_post.ShortPostBatchJob) {
+	type statusCount struct {
+		Status short_post.BatchRowStatus
+		Count  int
+	}
+	var counts []statusCount
+	if err := w.db.Model(&short_post.ShortPostBatchRow{}).
+		Select("status, COUNT(*) AS count").
+		Where("job_id = ?", job.ID).
+		Group("status").Scan(&counts).Error; err != nil {
+		repository.Errorf("Count batch job %s rows failed: %v", job.ID, err)
+		return
+	}
+	var succeeded, failed, pending int
+	for _, c := range counts {
+		switch c.Status {
+		case short_post.BatchRowStatusSucceeded:
+			succeeded = c.Count
+		case short_post.BatchRowStatusFailed:
+			failed = c.Count
+		default:
+			pending += c.Count
+		}
+	}
+	if pending > 0 {
+		// 处理期间有行被重新排队，保持运行状态，锁过期后继续
+		return
+	}
+
+	status := short_post.BatchJobStatusSucceeded
+	switch {
+	case succeeded == 0 && failed > 0:
+		status = short_post.BatchJobStatusFailed
+	case failed > 0:
+		status = short_post.BatchJobStatusPartial
+	}
+	now := time.Now()
+	result := w.db.Model(&short_post.ShortPostBatchJob{}).
+		Where("id = ? AND status = ?", job.ID, short_post.BatchJobStatusRunning).
+		Updates(map[string]interface{}{
+			"status":         status,
+			"succeeded_rows": succeeded,
+			"failed_rows":    failed,
+			"locked_until":   nil,
+			"finished_at":    now,
+		})
+	if result.Error != nil {
+		repository.Errorf("Finish batch job %s failed: %v", job.ID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+	repository.Infof("Batch job %s finished: status=%s, succeeded=%d, failed=%d", job.ID, status, succeeded, failed)
+
+	content := fmt.Sprintf("批量任务《%s》已完成，成功生成 %d 个工程。", job.Name, succeeded)
+	if failed > 0 {
+		content = fmt.Sprintf("批量任务《%s》已完成，成功 %d 个，失败 %d 个，可在任务详情中查看原因并重试。", job.Name, succeeded, failed)
+	}
+	notification := &models.SystemNotification{
+		NotificationID: uuid.New().String(),
+		UserID:         tools.StringPtr(job.UserID),
+		Type:           "system",
+		Title:          "批量生成完成",
+		Content:        content,
+		Status:         "unread",
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := w.db.Create(notification).Error; err != nil {
+		repository.Warnf("Create batch job notification failed: %v", err)
+	}
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"01agent_server/internal/config"
+	"01agent_server/internal/tools"
 )
 
 type ConfigService struct{}
@@ -27,7 +28,8 @@ func (s *ConfigService) GetSafeConfig() map[string]interface{} {
 			"type": cfg.Database.Type,
 		},
 		"jwt": map[string]interface{}{
-			"expire": cfg.JWT.Expire.String(),
+			"accessExpire":  tools.AccessTokenExpire().String(),
+			"refreshExpire": tools.RefreshTokenExpire().String(),
 		},
 		"credits": cfg.Credits,
 		"themes":  cfg.Themes,
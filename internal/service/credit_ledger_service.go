@@ -0,0 +1,402 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"01agent_server/internal/config"
+	"01agent_server/internal/models"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/tools"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrServicePriceNotFound 服务定价不存在或已下架
+	ErrServicePriceNotFound = errors.New("服务定价不存在或已下架")
+	// ErrInsufficientCredits 积分不足
+	ErrInsufficientCredits = errors.New("积分不足")
+	// ErrInvalidUnits 计费数量无效
+	ErrInvalidUnits = errors.New("计费数量必须大于0")
+)
+
+// CreditLedgerService 积分账本服务
+// 统一处理积分消费，按 每日积分 > 有期限积分（按过期时间升序） > 每月权益积分 > 永久积分 的顺序扣减
+type CreditLedgerService struct {
+	db *gorm.DB
+}
+
+// NewCreditLedgerService 创建积分账本服务
+func NewCreditLedgerService() *CreditLedgerService {
+	return &CreditLedgerService{
+		db: repository.DB,
+	}
+}
+
+// CreditDeduction 各积分桶的扣减明细
+type CreditDeduction struct {
+	Daily     int `json:"daily"`     // 每日积分扣减
+	Timed     int `json:"timed"`     // 有期限积分扣减
+	Monthly   int `json:"monthly"`   // 每月权益积分扣减
+	Permanent int `json:"permanent"` // 永久积分扣减
+}
+
+// Total 扣减总额
+func (d *CreditDeduction) Total() int {
+	return d.Daily + d.Timed + d.Monthly + d.Permanent
+}
+
+// CreditBalance 各积分桶的余额
+type CreditBalance struct {
+	Daily     int `json:"daily"`
+	Timed     int `json:"timed"`
+	Monthly   int `json:"monthly"`
+	Permanent int `json:"permanent"`
+	Total     int `json:"total"`
+}
+
+// ConsumeResult 积分消费结果
+type ConsumeResult struct {
+	ServiceCode string          `json:"service_code"`
+	Units       float64         `json:"units"`
+	Cost        int             `json:"cost"`
+	Deduction   CreditDeduction `json:"deduction"`
+	Balance     CreditBalance   `json:"balance"`
+	RecordID    int             `json:"record_id"`
+}
+
+// GetServicePrice 获取有效的服务定价
+func (s *CreditLedgerService) GetServicePrice(serviceCode string) (*models.CreditServicePrice, error) {
+	var price models.CreditServicePrice
+	err := s.db.Where("service_code = ? AND status = ?", serviceCode, true).First(&price).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrServicePriceNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询服务定价失败: %w", err)
+	}
+	return &price, nil
+}
+
+// CalculateCost 根据服务定价计算消耗积分
+// units 为对应计费单位下的数量：次数/分钟/字符数/秒数/token数，不足一个单位按一个单位计
+func (s *CreditLedgerService) CalculateCost(price *models.CreditServicePrice, units float64) (int, error) {
+	if units <= 0 {
+		return 0, ErrInvalidUnits
+	}
+
+	perUnit := 0
+	if price.Credits != nil {
+		perUnit = *price.Credits
+	}
+
+	unit := models.ServiceUnitCount
+	if price.Unit != nil {
+		unit = models.ServiceUnit(*price.Unit)
+	}
+
+	switch unit {
+	case models.ServiceUnitCount, models.ServiceUnitMinute, models.ServiceUnitChar,
+		models.ServiceUnitSecond, models.ServiceUnitToken:
+		return perUnit * int(math.Ceil(units)), nil
+	default:
+		return 0, fmt.Errorf("不支持的计费单位: %d", unit)
+	}
+}
+
+// Consume 按服务代号消费积分
+// 在同一个事务中完成定价、按优先级扣减各积分桶以及写入积分记录
+func (s *CreditLedgerService) Consume(userID, serviceCode string, units float64) (*ConsumeResult, error) {
+	price, err := s.GetServicePrice(serviceCode)
+	if err != nil {
+		return nil, err
+	}
+
+	cost, err := s.CalculateCost(price, units)
+	if err != nil {
+		return nil, err
+	}
+
+	name := serviceCode
+	if price.Name != nil && *price.Name != "" {
+		name = *price.Name
+	}
+	description := fmt.Sprintf("使用%s, 消耗%d积分", name, cost)
+
+	result := &ConsumeResult{
+		ServiceCode: serviceCode,
+		Units:       units,
+		Cost:        cost,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 直接消费不能挪用其他任务预扣冻结的积分
+		if err := s.lockUser(tx, userID); err != nil {
+			return err
+		}
+		available, err := s.availableCredits(tx, userID, 0)
+		if err != nil {
+			return err
+		}
+		if available < cost {
+			return ErrInsufficientCredits
+		}
+
+		deduction, balance, recordID, err := s.DeductWithRecord(tx, userID, cost, serviceCode, description)
+		if err != nil {
+			return err
+		}
+		result.Deduction = *deduction
+		result.Balance = *balance
+		result.RecordID = recordID
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tools.ClearUserCacheAsync(userID)
+	return result, nil
+}
+
+// DeductWithRecord 在事务中扣减积分并写入消费记录，供其他服务复用
+// 返回扣减明细、扣减后余额和积分记录ID；cost 为 0 时不写记录
+func (s *CreditLedgerService) DeductWithRecord(tx *gorm.DB, userID string, cost int, serviceCode, description string) (*CreditDeduction, *CreditBalance, int, error) {
+	deduction, err := s.Deduct(tx, userID, cost)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	balance, err := s.GetBalance(tx, userID)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	if cost == 0 {
+		return deduction, balance, 0, nil
+	}
+
+	record := models.CreditRecord{
+		UserID:      userID,
+		RecordType:  models.CreditConsumption,
+		Credits:     tools.IntPtr(-cost),
+		Balance:     tools.IntPtr(balance.Total),
+		Description: tools.StringPtr(description),
+		CreatedAt:   time.Now(),
+	}
+	if serviceCode != "" {
+		record.ServiceCode = tools.StringPtr(serviceCode)
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return nil, nil, 0, fmt.Errorf("创建积分记录失败: %w", err)
+	}
+
+	return deduction, balance, record.ID, nil
+}
+
+// Deduct 在事务中按优先级扣减积分（不写积分记录）
+// 所有涉及的行都会加行锁，余额不足时返回 ErrInsufficientCredits 且不做任何修改
+func (s *CreditLedgerService) Deduct(tx *gorm.DB, userID string, amount int) (*CreditDeduction, error) {
+	deduction := &CreditDeduction{}
+	if amount <= 0 {
+		return deduction, nil
+	}
+
+	now := time.Now()
+
+	// 锁定用户行，串行化同一用户的并发扣减
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ?", userID).First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("用户不存在")
+		}
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+
+	daily, err := s.lockDailyBenefit(tx, userID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var timedCredits []models.UserTimedCredits
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND credits > 0 AND expire_at > ?", userID, now).
+		Order("expire_at ASC, id ASC").
+		Find(&timedCredits).Error; err != nil {
+		return nil, fmt.Errorf("查询有期限积分失败: %w", err)
+	}
+
+	var monthlyBenefits []models.UserMonthlyBenefit
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND monthly_credits > 0 AND (expire_at IS NULL OR expire_at > ?)", userID, now).
+		Order("benefit_month ASC, id ASC").
+		Find(&monthlyBenefits).Error; err != nil {
+		return nil, fmt.Errorf("查询每月权益积分失败: %w", err)
+	}
+
+	// 先校验总余额，避免部分扣减
+	available := nonNegative(daily.DailyCredits) + nonNegative(user.Credits)
+	for _, tc := range timedCredits {
+		available += tc.Credits
+	}
+	for _, mb := range monthlyBenefits {
+		available += mb.MonthlyCredits
+	}
+	if available < amount {
+		return nil, ErrInsufficientCredits
+	}
+
+	remaining := amount
+
+	// 1. 每日积分
+	if take := min(remaining, nonNegative(daily.DailyCredits)); take > 0 {
+		if err := tx.Model(&models.UserDailyBenefit{}).Where("id = ?", daily.ID).
+			Updates(map[string]interface{}{
+				"daily_credits": gorm.Expr("daily_credits - ?", take),
+				"updated_at":    now,
+			}).Error; err != nil {
+			return nil, fmt.Errorf("扣减每日积分失败: %w", err)
+		}
+		deduction.Daily = take
+		remaining -= take
+	}
+
+	// 2. 有期限积分（按过期时间升序）
+	for _, tc := range timedCredits {
+		if remaining == 0 {
+			break
+		}
+		take := min(remaining, tc.Credits)
+		if err := tx.Model(&models.UserTimedCredits{}).Where("id = ?", tc.ID).
+			Updates(map[string]interface{}{
+				"credits":    gorm.Expr("credits - ?", take),
+				"updated_at": now,
+			}).Error; err != nil {
+			return nil, fmt.Errorf("扣减有期限积分失败: %w", err)
+		}
+		deduction.Timed += take
+		remaining -= take
+	}
+
+	// 3. 每月权益积分
+	for _, mb := range monthlyBenefits {
+		if remaining == 0 {
+			break
+		}
+		take := min(remaining, mb.MonthlyCredits)
+		if err := tx.Model(&models.UserMonthlyBenefit{}).Where("id = ?", mb.ID).
+			Updates(map[string]interface{}{
+				"monthly_credits": gorm.Expr("monthly_credits - ?", take),
+				"updated_at":      now,
+			}).Error; err != nil {
+			return nil, fmt.Errorf("扣减每月权益积分失败: %w", err)
+		}
+		deduction.Monthly += take
+		remaining -= take
+	}
+
+	// 4. 永久积分
+	if remaining > 0 {
+		if err := tx.Model(&models.User{}).Where("user_id = ?", userID).
+			Update("credits", gorm.Expr("credits - ?", remaining)).Error; err != nil {
+			return nil, fmt.Errorf("扣减永久积分失败: %w", err)
+		}
+		deduction.Permanent = remaining
+	}
+
+	return deduction, nil
+}
+
+// GetBalance 获取用户各积分桶的当前余额
+// tx 为 nil 时使用默认连接
+func (s *CreditLedgerService) GetBalance(tx *gorm.DB, userID string) (*CreditBalance, error) {
+	if tx == nil {
+		tx = s.db
+	}
+	now := time.Now()
+	balance := &CreditBalance{}
+
+	var user models.User
+	if err := tx.Select("credits").Where("user_id = ?", userID).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+	balance.Permanent = nonNegative(user.Credits)
+
+	todayStart, todayEnd := dayRange(now)
+	var daily models.UserDailyBenefit
+	err := tx.Where("user_id = ? AND created_at >= ? AND created_at <= ?", userID, todayStart, todayEnd).
+		First(&daily).Error
+	switch {
+	case err == nil:
+		balance.Daily = nonNegative(daily.DailyCredits)
+	case err == gorm.ErrRecordNotFound:
+		// 当日记录在首次扣减时按默认额度创建
+		balance.Daily = nonNegative(config.GetDefaultDailyCredits())
+	default:
+		return nil, fmt.Errorf("查询每日积分失败: %w", err)
+	}
+
+	if err := tx.Model(&models.UserTimedCredits{}).
+		Select("COALESCE(SUM(credits), 0)").
+		Where("user_id = ? AND credits > 0 AND expire_at > ?", userID, now).
+		Scan(&balance.Timed).Error; err != nil {
+		return nil, fmt.Errorf("查询有期限积分失败: %w", err)
+	}
+
+	if err := tx.Model(&models.UserMonthlyBenefit{}).
+		Select("COALESCE(SUM(monthly_credits), 0)").
+		Where("user_id = ? AND monthly_credits > 0 AND (expire_at IS NULL OR expire_at > ?)", userID, now).
+		Scan(&balance.Monthly).Error; err != nil {
+		return nil, fmt.Errorf("查询每月权益积分失败: %w", err)
+	}
+
+	balance.Total = balance.Daily + balance.Timed + balance.Monthly + balance.Permanent
+	return balance, nil
+}
+
+// lockDailyBenefit 锁定（不存在则创建）用户当日的每日权益记录
+func (s *CreditLedgerService) lockDailyBenefit(tx *gorm.DB, userID string, now time.Time) (*models.UserDailyBenefit, error) {
+	todayStart, todayEnd := dayRange(now)
+
+	var daily models.UserDailyBenefit
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND created_at >= ? AND created_at <= ?", userID, todayStart, todayEnd).
+		First(&daily).Error
+	if err == nil {
+		return &daily, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("查询每日积分失败: %w", err)
+	}
+
+	daily = models.UserDailyBenefit{
+		UserID:       userID,
+		DailyCredits: config.GetDefaultDailyCredits(),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := tx.Create(&daily).Error; err != nil {
+		return nil, fmt.Errorf("创建每日积分记录失败: %w", err)
+	}
+	return &daily, nil
+}
+
+// dayRange 返回当天的起止时间
+func dayRange(t time.Time) (time.Time, time.Time) {
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	end := time.Date(t.Year(), t.Month(), t.Day(), 23, 59, 59, 999999999, t.Location())
+	return start, end
+}
+
+// nonNegative 负数按0处理
+func nonNegative(n int) int {
+	if n < 0 {
+		return 0
+	}
+	return n
+}
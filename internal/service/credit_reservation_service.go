@@ -0,0 +1,335 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"01agent_server/internal/models"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/tools"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrReservationNotFound 预扣记录不存在
+	ErrReservationNotFound = errors.New("积分预扣记录不存在")
+	// ErrReservationSettled 预扣已结算、释放或过期
+	ErrReservationSettled = errors.New("积分预扣已结算或已失效")
+)
+
+const (
+	// DefaultReservationTTL 默认冻结时长
+	DefaultReservationTTL = 30 * time.Minute
+	// MaxReservationTTL 最长冻结时长
+	MaxReservationTTL = 24 * time.Hour
+	// reservationSweepInterval 过期预扣清理间隔
+	reservationSweepInterval = time.Minute
+)
+
+// HoldOptions 冻结积分参数
+type HoldOptions struct {
+	BizType string        // 业务类型，如 article_task / synthesis / voice_train
+	BizID   string        // 业务ID
+	TTL     time.Duration // 冻结时长，为0时使用默认值
+}
+
+// Hold 按预估用量冻结积分
+// 冻结不会修改各积分桶，只占用可用额度；并发任务共享同一份可用额度，不会超额预扣
+func (s *CreditLedgerService) Hold(userID, serviceCode string, units float64, opts HoldOptions) (*models.CreditReservation, error) {
+	price, err := s.GetServicePrice(serviceCode)
+	if err != nil {
+		return nil, err
+	}
+	cost, err := s.CalculateCost(price, units)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = DefaultReservationTTL
+	}
+	if ttl > MaxReservationTTL {
+		ttl = MaxReservationTTL
+	}
+
+	now := time.Now()
+	reservation := &models.CreditReservation{
+		UserID:      userID,
+		ServiceCode: serviceCode,
+		Units:       units,
+		Credits:     cost,
+		Status:      models.CreditReservationHeld,
+		ExpireAt:    now.Add(ttl),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if opts.BizType != "" {
+		reservation.BizType = tools.StringPtr(opts.BizType)
+	}
+	if opts.BizID != "" {
+		reservation.BizID = tools.StringPtr(opts.BizID)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.lockUser(tx, userID); err != nil {
+			return err
+		}
+
+		available, err := s.availableCredits(tx, userID, 0)
+		if err != nil {
+			return err
+		}
+		if available < cost {
+			return ErrInsufficientCredits
+		}
+
+		if err := tx.Create(reservation).Error; err != nil {
+			return fmt.Errorf("创建积分预扣失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reservation, nil
+}
+
+// Capture 按实际用量结算预扣，实际扣减积分并写入积分记录
+// 实际用量可以超过预估用量，超出部分同样受其他预扣占用的额度约束；实际用量为0时直接释放预扣
+func (s *CreditLedgerService) Capture(userID string, reservationID int, actualUnits float64) (*ConsumeResult, error) {
+	if actualUnits < 0 {
+		return nil, ErrInvalidUnits
+	}
+	var result *ConsumeResult
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.lockUser(tx, userID); err != nil {
+			return err
+		}
+
+		reservation, err := s.lockReservation(tx, userID, reservationID)
+		if err != nil {
+			return err
+		}
+
+		if actualUnits == 0 {
+			if err := s.releaseReservation(tx, reservation.ID, map[string]interface{}{"actual_units": 0, "captured_credits": 0}); err != nil {
+				return err
+			}
+			balance, err := s.GetBalance(tx, userID)
+			if err != nil {
+				return err
+			}
+			result = &ConsumeResult{ServiceCode: reservation.ServiceCode, Balance: *balance}
+			return nil
+		}
+
+		price, err := s.GetServicePrice(reservation.ServiceCode)
+		if err != nil {
+			return err
+		}
+		cost, err := s.CalculateCost(price, actualUnits)
+		if err != nil {
+			return err
+		}
+
+		// 当前预扣自身占用的额度可直接使用，其他预扣占用的额度不可挪用
+		available, err := s.availableCredits(tx, userID, reservation.ID)
+		if err != nil {
+			return err
+		}
+		if available < cost {
+			return ErrInsufficientCredits
+		}
+
+		name := reservation.ServiceCode
+		if price.Name != nil && *price.Name != "" {
+			name = *price.Name
+		}
+		description := fmt.Sprintf("使用%s, 消耗%d积分", name, cost)
+
+		deduction, balance, recordID, err := s.DeductWithRecord(tx, userID, cost, reservation.ServiceCode, description)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{
+			"status":           models.CreditReservationCaptured,
+			"actual_units":     actualUnits,
+			"captured_credits": cost,
+			"settled_at":       now,
+			"updated_at":       now,
+		}
+		if recordID > 0 {
+			updates["credit_record_id"] = recordID
+		}
+		if err := tx.Model(&models.CreditReservation{}).Where("id = ?", reservation.ID).
+			Updates(updates).Error; err != nil {
+			return fmt.Errorf("更新积分预扣状态失败: %w", err)
+		}
+
+		result = &ConsumeResult{
+			ServiceCode: reservation.ServiceCode,
+			Units:       actualUnits,
+			Cost:        cost,
+			Deduction:   *deduction,
+			Balance:     *balance,
+			RecordID:    recordID,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tools.ClearUserCacheAsync(userID)
+	return result, nil
+}
+
+// Release 释放预扣，任务失败或取消时调用，不产生任何积分扣减
+func (s *CreditLedgerService) Release(userID string, reservationID int) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		reservation, err := s.lockReservation(tx, userID, reservationID)
+		if err != nil {
+			return err
+		}
+		return s.releaseReservation(tx, reservation.ID, nil)
+	})
+}
+
+// releaseReservation 将已锁定的预扣标记为已释放，extra 为需要一并更新的字段
+func (s *CreditLedgerService) releaseReservation(tx *gorm.DB, reservationID int, extra map[string]interface{}) error {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":     models.CreditReservationReleased,
+		"settled_at": now,
+		"updated_at": now,
+	}
+	for key, value := range extra {
+		updates[key] = value
+	}
+	if err := tx.Model(&models.CreditReservation{}).Where("id = ?", reservationID).
+		Updates(updates).Error; err != nil {
+		return fmt.Errorf("释放积分预扣失败: %w", err)
+	}
+	return nil
+}
+
+// GetReservation 获取用户的预扣记录
+func (s *CreditLedgerService) GetReservation(userID string, reservationID int) (*models.CreditReservation, error) {
+	var reservation models.CreditReservation
+	err := s.db.Where("id = ? AND user_id = ?", reservationID, userID).First(&reservation).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrReservationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询积分预扣失败: %w", err)
+	}
+	return &reservation, nil
+}
+
+// GetHeldCredits 获取用户当前被冻结的积分总数（不含已过期的预扣）
+// tx 为 nil 时使用默认连接
+func (s *CreditLedgerService) GetHeldCredits(tx *gorm.DB, userID string, excludeID int) (int, error) {
+	if tx == nil {
+		tx = s.db
+	}
+	var held int
+	query := tx.Model(&models.CreditReservation{}).
+		Select("COALESCE(SUM(credits), 0)").
+		Where("user_id = ? AND status = ? AND expire_at > ?", userID, models.CreditReservationHeld, time.Now())
+	if excludeID > 0 {
+		query = query.Where("id <> ?", excludeID)
+	}
+	if err := query.Scan(&held).Error; err != nil {
+		return 0, fmt.Errorf("查询冻结积分失败: %w", err)
+	}
+	return held, nil
+}
+
+// ExpireStaleHolds 将已超过冻结时长的预扣标记为过期，返回处理条数
+func (s *CreditLedgerService) ExpireStaleHolds() (int64, error) {
+	now := time.Now()
+	result := s.db.Model(&models.CreditReservation{}).
+		Where("status = ? AND expire_at <= ?", models.CreditReservationHeld, now).
+		Updates(map[string]interface{}{
+			"status":     models.CreditReservationExpired,
+			"settled_at": now,
+			"updated_at": now,
+		})
+	if result.Error != nil {
+		return 0, fmt.Errorf("清理过期积分预扣失败: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// StartReservationSweeper 启动后台协程定期清理过期预扣
+func (s *CreditLedgerService) StartReservationSweeper() {
+	go func() {
+		ticker := time.NewTicker(reservationSweepInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			count, err := s.ExpireStaleHolds()
+			if err != nil {
+				repository.Errorf("Expire stale credit holds failed: %v", err)
+				continue
+			}
+			if count > 0 {
+				repository.Infof("Expired %d stale credit holds", count)
+			}
+		}
+	}()
+}
+
+// availableCredits 可用积分 = 各积分桶余额 - 其他有效预扣冻结的积分
+// 当日每日权益记录在扣减时才创建，这里先创建并锁定，避免当天首次消费时每日积分按 0 计算
+func (s *CreditLedgerService) availableCredits(tx *gorm.DB, userID string, excludeID int) (int, error) {
+	if _, err := s.lockDailyBenefit(tx, userID, time.Now()); err != nil {
+		return 0, err
+	}
+	balance, err := s.GetBalance(tx, userID)
+	if err != nil {
+		return 0, err
+	}
+	held, err := s.GetHeldCredits(tx, userID, excludeID)
+	if err != nil {
+		return 0, err
+	}
+	return balance.Total - held, nil
+}
+
+// lockUser 锁定用户行，串行化同一用户的冻结与结算
+func (s *CreditLedgerService) lockUser(tx *gorm.DB, userID string) error {
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("user_id").Where("user_id = ?", userID).First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return fmt.Errorf("用户不存在")
+		}
+		return fmt.Errorf("查询用户失败: %w", err)
+	}
+	return nil
+}
+
+// lockReservation 锁定处于冻结状态且未过期的预扣记录
+func (s *CreditLedgerService) lockReservation(tx *gorm.DB, userID string, reservationID int) (*models.CreditReservation, error) {
+	var reservation models.CreditReservation
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND user_id = ?", reservationID, userID).First(&reservation).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrReservationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询积分预扣失败: %w", err)
+	}
+	if reservation.Status != models.CreditReservationHeld || !reservation.ExpireAt.After(time.Now()) {
+		return nil, ErrReservationSettled
+	}
+	return &reservation, nil
+}
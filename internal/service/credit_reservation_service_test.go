@@ -0,0 +1,68 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"01agent_server/internal/config"
+	"01agent_server/internal/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestCaptureZeroUnitsReleasesHold(t *testing.T) {
+	useTestRedis(t, useTestConfig(t, &config.Config{}))
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.CreditReservation{}, &models.UserDailyBenefit{},
+		&models.UserTimedCredits{}, &models.UserMonthlyBenefit{}); err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	svc := &CreditLedgerService{db: db}
+
+	if err := db.Create(&models.User{UserID: "u1", Credits: 100}).Error; err != nil {
+		t.Fatal(err)
+	}
+	reservation := &models.CreditReservation{
+		UserID:      "u1",
+		ServiceCode: "article_generate",
+		Units:       1,
+		Credits:     30,
+		Status:      models.CreditReservationHeld,
+		ExpireAt:    time.Now().Add(DefaultReservationTTL),
+	}
+	if err := db.Create(reservation).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.Capture("u1", reservation.ID, -1); !errors.Is(err, ErrInvalidUnits) {
+		t.Fatalf("negative units: want ErrInvalidUnits, got %v", err)
+	}
+
+	// 实际用量为0时释放预扣，不扣减积分
+	result, err := svc.Capture("u1", reservation.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Cost != 0 || result.RecordID != 0 || result.Balance.Permanent != 100 {
+		t.Fatalf("zero capture should not deduct credits, got %+v", result)
+	}
+	var saved models.CreditReservation
+	db.First(&saved, reservation.ID)
+	if saved.Status != models.CreditReservationReleased || saved.SettledAt == nil {
+		t.Fatalf("zero capture should release the hold, got status %v", saved.Status)
+	}
+	if held, err := svc.GetHeldCredits(nil, "u1", 0); err != nil || held != 0 {
+		t.Fatalf("want no held credits, got %d %v", held, err)
+	}
+	if _, err := svc.Capture("u1", reservation.ID, 0); !errors.Is(err, ErrReservationSettled) {
+		t.Fatalf("repeat capture: want ErrReservationSettled, got %v", err)
+	}
+}
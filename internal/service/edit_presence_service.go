@@ -0,0 +1,209 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"01agent_server/internal/models"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/tools"
+
+	"gorm.io/gorm"
+)
+
+// 协同编辑的资源类型
+const (
+	EditResourceArticle   = "article_edit"
+	EditResourceShortPost = "short_post"
+)
+
+const (
+	// EditPresenceHeartbeat 建议的心跳间隔（秒）
+	EditPresenceHeartbeat = 10
+	// editPresenceTTL 超过该时间（秒）未心跳的页面视为已离开，编辑锁同时释放
+	editPresenceTTL     = 30
+	editPresenceRedisDB = 3
+)
+
+// ErrInvalidClientID 页面标识格式错误
+var ErrInvalidClientID = errors.New("client_id 只能包含字母、数字、- 和 _，且不超过64个字符")
+
+var clientIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// EditorPresence 正在编辑的页面
+type EditorPresence struct {
+	UserID   string  `json:"user_id"`
+	ClientID string  `json:"client_id"`
+	Nickname *string `json:"nickname"`
+	Avatar   *string `json:"avatar"`
+	LastSeen int64   `json:"last_seen"` // 最后心跳时间（Unix 秒）
+}
+
+// EditPresenceState 资源的协同编辑状态
+type EditPresenceState struct {
+	Editors           []EditorPresence `json:"editors"`      // 除当前页面外正在编辑的页面
+	Lock              *EditorPresence  `json:"lock"`         // 持有编辑锁的页面，无人持有时为空
+	LockedByMe        bool             `json:"locked_by_me"` // 当前页面是否持有编辑锁
+	HeartbeatInterval int              `json:"heartbeat_interval"`
+}
+
+// EditPresenceService 协同编辑在线状态与编辑锁
+// 编辑锁只用于提示，保存时的并发冲突由编辑版本号检测
+type EditPresenceService struct {
+	db    *gorm.DB
+	redis *tools.Redis
+}
+
+// NewEditPresenceService 创建协同编辑状态服务
+func NewEditPresenceService() *EditPresenceService {
+	return &EditPresenceService{
+		db:    repository.DB,
+		redis: tools.GetRedisInstance(),
+	}
+}
+
+// Heartbeat 上报页面仍在编辑并返回其他编辑者；wantLock 为 true 时尝试获取或续期编辑锁，为 false 时释放自己持有的锁
+func (s *EditPresenceService) Heartbeat(resourceType, resourceID, userID, clientID string, wantLock bool) (*EditPresenceState, error) {
+	if !clientIDPattern.MatchString(clientID) {
+		return nil, ErrInvalidClientID
+	}
+
+	self := EditorPresence{UserID: userID, ClientID: clientID, LastSeen: time.Now().Unix()}
+	var user models.User
+	if err := s.db.Select("user_id", "nickname", "avatar").Where("user_id = ?", userID).First(&user).Error; err == nil {
+		self.Nickname = user.Nickname
+		self.Avatar = user.Avatar
+	}
+
+	editors, err := s.loadEditors(resourceType, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	editors[presenceMember(userID, clientID)] = self
+	if err := s.saveEditors(resourceType, resourceID, editors); err != nil {
+		return nil, err
+	}
+
+	lockKey := editLockKey(resourceType, resourceID)
+	member := presenceMember(userID, clientID)
+	holder, err := s.redis.Get(lockKey, editPresenceRedisDB)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case wantLock && holder == member:
+		err = s.redis.Expire(lockKey, editPresenceTTL, editPresenceRedisDB)
+	case wantLock && holder == "":
+		var ok bool
+		if ok, err = s.redis.SetNX(lockKey, member, editPresenceTTL, editPresenceRedisDB); ok {
+			holder = member
+		} else if err == nil {
+			holder, err = s.redis.Get(lockKey, editPresenceRedisDB)
+		}
+	case !wantLock && holder == member:
+		err = s.redis.Delete(lockKey, editPresenceRedisDB)
+		holder = ""
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return buildPresenceState(editors, holder, member), nil
+}
+
+// Leave 页面关闭时移除在线状态并释放其持有的编辑锁
+func (s *EditPresenceService) Leave(resourceType, resourceID, userID, clientID string) error {
+	if !clientIDPattern.MatchString(clientID) {
+		return ErrInvalidClientID
+	}
+	member := presenceMember(userID, clientID)
+
+	editors, err := s.loadEditors(resourceType, resourceID)
+	if err != nil {
+		return err
+	}
+	if _, ok := editors[member]; ok {
+		delete(editors, member)
+		if err := s.saveEditors(resourceType, resourceID, editors); err != nil {
+			return err
+		}
+	}
+
+	lockKey := editLockKey(resourceType, resourceID)
+	if holder, err := s.redis.Get(lockKey, editPresenceRedisDB); err == nil && holder == member {
+		return s.redis.Delete(lockKey, editPresenceRedisDB)
+	}
+	return nil
+}
+
+// loadEditors 读取资源的在线页面，并去掉心跳已超时的页面
+func (s *EditPresenceService) loadEditors(resourceType, resourceID string) (map[string]EditorPresence, error) {
+	editors := make(map[string]EditorPresence)
+	data, err := s.redis.Get(editPresenceKey(resourceType, resourceID), editPresenceRedisDB)
+	if err != nil {
+		return nil, err
+	}
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &editors); err != nil {
+			repository.Warnf("Invalid edit presence data for %s %s: %v", resourceType, resourceID, err)
+			editors = make(map[string]EditorPresence)
+		}
+	}
+
+	expired := time.Now().Unix() - editPresenceTTL
+	for member, editor := range editors {
+		if editor.LastSeen < expired {
+			delete(editors, member)
+		}
+	}
+	return editors, nil
+}
+
+func (s *EditPresenceService) saveEditors(resourceType, resourceID string, editors map[string]EditorPresence) error {
+	key := editPresenceKey(resourceType, resourceID)
+	if len(editors) == 0 {
+		return s.redis.Delete(key, editPresenceRedisDB)
+	}
+	data, err := json.Marshal(editors)
+	if err != nil {
+		return err
+	}
+	return s.redis.Set(key, string(data), editPresenceTTL, editPresenceRedisDB)
+}
+
+func buildPresenceState(editors map[string]EditorPresence, holder, self string) *EditPresenceState {
+	state := &EditPresenceState{
+		Editors:           []EditorPresence{},
+		LockedByMe:        holder != "" && holder == self,
+		HeartbeatInterval: EditPresenceHeartbeat,
+	}
+	for member, editor := range editors {
+		if member == holder {
+			lock := editor
+			state.Lock = &lock
+		}
+		if member != self {
+			state.Editors = append(state.Editors, editor)
+		}
+	}
+	sort.Slice(state.Editors, func(i, j int) bool {
+		return state.Editors[i].LastSeen > state.Editors[j].LastSeen
+	})
+	return state
+}
+
+func presenceMember(userID, clientID string) string {
+	return userID + ":" + clientID
+}
+
+func editPresenceKey(resourceType, resourceID string) string {
+	return fmt.Sprintf("editing:presence:%s:%s", resourceType, resourceID)
+}
+
+func editLockKey(resourceType, resourceID string) string {
+	return fmt.Sprintf("editing:lock:%s:%s", resourceType, resourceID)
+}
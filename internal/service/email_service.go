@@ -0,0 +1,308 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"01agent_server/internal/config"
+	"01agent_server/internal/repository"
+)
+
+var (
+	// ErrEmailQueueFull 邮件发送队列已满
+	ErrEmailQueueFull = errors.New("邮件发送队列繁忙，请稍后再试")
+	// ErrEmailNotConfigured 未配置SMTP
+	ErrEmailNotConfigured = errors.New("邮件服务未配置")
+)
+
+const (
+	emailQueueSize         = 1000
+	defaultEmailWorkers    = 2
+	defaultEmailMaxRetries = 3
+	emailRetryBaseDelay    = 2 * time.Second
+	emailSMTPTimeout       = 15 * time.Second
+)
+
+// EmailMessage 邮件内容
+type EmailMessage struct {
+	To      string
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// EmailSender 邮件发送接口
+type EmailSender interface {
+	Send(msg *EmailMessage) error
+}
+
+// ==================== SMTP ====================
+
+// SMTPEmailSender 基于SMTP的邮件发送器
+// 支持 465 隐式TLS、STARTTLS 以及无加密无认证的本地SMTP（如 MailHog、smtp4dev）
+type SMTPEmailSender struct {
+	cfg config.EmailConfig
+}
+
+// NewSMTPEmailSender 创建SMTP邮件发送器
+func NewSMTPEmailSender(cfg config.EmailConfig) *SMTPEmailSender {
+	return &SMTPEmailSender{cfg: cfg}
+}
+
+// Send 发送邮件
+func (s *SMTPEmailSender) Send(msg *EmailMessage) error {
+	if s.cfg.SMTPServer == "" || s.cfg.Sender == "" {
+		return ErrEmailNotConfigured
+	}
+
+	port := s.cfg.SMTPPort
+	if port == 0 {
+		port = 25
+	}
+	addr := net.JoinHostPort(s.cfg.SMTPServer, strconv.Itoa(port))
+
+	security := strings.ToLower(s.cfg.Security)
+	if security == "" && port == 465 {
+		security = "ssl"
+	}
+
+	tlsConfig := &tls.Config{ServerName: s.cfg.SMTPServer}
+
+	var conn net.Conn
+	var err error
+	if security == "ssl" {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: emailSMTPTimeout}, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, emailSMTPTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("连接SMTP服务器失败: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(emailSMTPTimeout * 2))
+
+	client, err := smtp.NewClient(conn, s.cfg.SMTPServer)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("创建SMTP客户端失败: %w", err)
+	}
+	defer client.Close()
+
+	if security != "ssl" && security != "none" {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("STARTTLS失败: %w", err)
+			}
+		} else if security == "starttls" {
+			return fmt.Errorf("SMTP服务器不支持STARTTLS")
+		}
+	}
+
+	if s.cfg.Password != "" {
+		// 配置了密码但服务器不支持认证时不降级为匿名发送，避免连到错误的服务器或被中间人去掉 AUTH
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return fmt.Errorf("SMTP服务器不支持AUTH认证")
+		}
+		auth := smtp.PlainAuth("", s.cfg.Sender, s.cfg.Password, s.cfg.SMTPServer)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("SMTP认证失败: %w", err)
+		}
+	}
+
+	if err := client.Mail(s.cfg.Sender); err != nil {
+		return fmt.Errorf("设置发件人失败: %w", err)
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("设置收件人失败: %w", err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("发送邮件数据失败: %w", err)
+	}
+	if _, err := w.Write(s.buildMIME(msg)); err != nil {
+		w.Close()
+		return fmt.Errorf("写入邮件内容失败: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("提交邮件失败: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// buildMIME 构建 multipart/alternative 邮件，同时包含纯文本与HTML正文
+func (s *SMTPEmailSender) buildMIME(msg *EmailMessage) []byte {
+	boundary := randomBoundary()
+
+	from := s.cfg.Sender
+	if s.cfg.SenderName != "" {
+		from = fmt.Sprintf("%s <%s>", mime.BEncoding.Encode("UTF-8", s.cfg.SenderName), s.cfg.Sender)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("From: " + from + "\r\n")
+	buf.WriteString("To: " + msg.To + "\r\n")
+	buf.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", msg.Subject) + "\r\n")
+	buf.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: multipart/alternative; boundary=\"" + boundary + "\"\r\n")
+	buf.WriteString("\r\n")
+
+	writePart := func(contentType, body string) {
+		buf.WriteString("--" + boundary + "\r\n")
+		buf.WriteString("Content-Type: " + contentType + "; charset=UTF-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+		encoded := base64.StdEncoding.EncodeToString([]byte(body))
+		for len(encoded) > 76 {
+			buf.WriteString(encoded[:76] + "\r\n")
+			encoded = encoded[76:]
+		}
+		buf.WriteString(encoded + "\r\n")
+	}
+	if msg.Text != "" {
+		writePart("text/plain", msg.Text)
+	}
+	if msg.HTML != "" {
+		writePart("text/html", msg.HTML)
+	}
+	buf.WriteString("--" + boundary + "--\r\n")
+
+	return buf.Bytes()
+}
+
+func randomBoundary() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "01agent-" + hex.EncodeToString(b)
+}
+
+// ==================== 发送队列 ====================
+
+type emailJob struct {
+	msg     *EmailMessage
+	attempt int
+}
+
+// EmailService 邮件服务
+// 邮件通过带缓冲的队列异步发送，失败后按指数退避重试
+type EmailService struct {
+	sender     EmailSender
+	queue      chan *emailJob
+	maxRetries int
+}
+
+var (
+	emailServiceInstance *EmailService
+	emailServiceOnce     sync.Once
+)
+
+// GetEmailService 获取邮件服务单例（首次调用时启动发送协程）
+func GetEmailService() *EmailService {
+	emailServiceOnce.Do(func() {
+		cfg := config.EmailConfig{}
+		if config.AppConfig != nil {
+			cfg = config.AppConfig.Email
+		}
+		emailServiceInstance = NewEmailService(NewSMTPEmailSender(cfg), cfg.Workers, cfg.MaxRetries)
+	})
+	return emailServiceInstance
+}
+
+// NewEmailService 使用指定发送器创建邮件服务并启动发送协程
+func NewEmailService(sender EmailSender, workers, maxRetries int) *EmailService {
+	s := &EmailService{
+		sender:     sender,
+		queue:      make(chan *emailJob, emailQueueSize),
+		maxRetries: intOrDefault(maxRetries, defaultEmailMaxRetries),
+	}
+	for i := 0; i < intOrDefault(workers, defaultEmailWorkers); i++ {
+		go s.worker()
+	}
+	return s
+}
+
+// Enqueue 将邮件加入发送队列
+func (s *EmailService) Enqueue(msg *EmailMessage) error {
+	select {
+	case s.queue <- &emailJob{msg: msg}:
+		return nil
+	default:
+		repository.Warnf("Email queue is full, drop email to %s: %s", msg.To, msg.Subject)
+		return ErrEmailQueueFull
+	}
+}
+
+// SendTemplate 渲染模板并加入发送队列
+func (s *EmailService) SendTemplate(to string, name EmailTemplate, data interface{}) error {
+	subject, html, text, err := RenderEmail(name, data)
+	if err != nil {
+		return err
+	}
+	return s.Enqueue(&EmailMessage{
+		To:      to,
+		Subject: subject,
+		HTML:    html,
+		Text:    text,
+	})
+}
+
+// SendVerifyCode 发送验证码邮件
+func (s *EmailService) SendVerifyCode(to, code string, expireMinutes int) error {
+	return s.SendTemplate(to, EmailTemplateVerifyCode, VerifyCodeEmailData{
+		Code:          code,
+		ExpireMinutes: expireMinutes,
+	})
+}
+
+// SendLoginAlert 发送登录提醒邮件
+func (s *EmailService) SendLoginAlert(to string, data LoginAlertEmailData) error {
+	return s.SendTemplate(to, EmailTemplateLoginAlert, data)
+}
+
+// SendPaymentReceipt 发送支付回执邮件
+func (s *EmailService) SendPaymentReceipt(to string, data PaymentReceiptEmailData) error {
+	return s.SendTemplate(to, EmailTemplatePaymentReceipt, data)
+}
+
+func (s *EmailService) worker() {
+	for job := range s.queue {
+		s.deliver(job)
+	}
+}
+
+// deliver 发送单封邮件，失败时延迟后重新入队
+func (s *EmailService) deliver(job *emailJob) {
+	err := s.sender.Send(job.msg)
+	if err == nil {
+		repository.Infof("Email sent to %s: %s", job.msg.To, job.msg.Subject)
+		return
+	}
+
+	job.attempt++
+	if err == ErrEmailNotConfigured || job.attempt > s.maxRetries {
+		repository.Errorf("Send email to %s failed after %d attempts: %v", job.msg.To, job.attempt, err)
+		return
+	}
+
+	delay := emailRetryBaseDelay * time.Duration(1<<(job.attempt-1))
+	repository.Warnf("Send email to %s failed (attempt %d), retry in %v: %v", job.msg.To, job.attempt, delay, err)
+	time.AfterFunc(delay, func() {
+		select {
+		case s.queue <- job:
+		default:
+			repository.Errorf("Email queue is full, drop retry to %s: %s", job.msg.To, job.msg.Subject)
+		}
+	})
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"bufio"
+	"encoding/base64"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"01agent_server/internal/config"
+)
+
+// receivedMail 本地SMTP服务器收到的邮件
+type receivedMail struct {
+	Auth string // AUTH PLAIN 解码后的凭证，\x00 分隔
+	From string
+	To   string
+	Data string
+}
+
+// startTestSMTPServer 启动一个最小的本地SMTP服务器，advertiseAuth 控制 EHLO 是否声明 AUTH
+func startTestSMTPServer(t *testing.T, advertiseAuth bool) (config.EmailConfig, <-chan receivedMail) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	mails := make(chan receivedMail, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestSMTP(conn, advertiseAuth, mails)
+		}
+	}()
+
+	return config.EmailConfig{
+		Sender:     "noreply@example.com",
+		SenderName: "01Agent",
+		SMTPServer: "127.0.0.1",
+		SMTPPort:   ln.Addr().(*net.TCPAddr).Port,
+		Security:   "none",
+	}, mails
+}
+
+func serveTestSMTP(conn net.Conn, advertiseAuth bool, mails chan<- receivedMail) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	var mail receivedMail
+	reply("220 localhost ESMTP test")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			if advertiseAuth {
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			} else {
+				reply("250 localhost")
+			}
+		case "AUTH":
+			parts := strings.Fields(line)
+			decoded, _ := base64.StdEncoding.DecodeString(parts[len(parts)-1])
+			mail.Auth = string(decoded)
+			reply("235 2.7.0 Authentication successful")
+		case "MAIL":
+			mail.From = line
+			reply("250 OK")
+		case "RCPT":
+			mail.To = line
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			mail.Data = data.String()
+			mails <- mail
+			reply("250 OK queued")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// decodeMailParts 解出邮件中 base64 编码的各个正文
+func decodeMailParts(data string) string {
+	var out strings.Builder
+	for _, part := range strings.Split(data, "Content-Transfer-Encoding: base64\r\n\r\n")[1:] {
+		body := part[:strings.Index(part, "--")]
+		decoded, _ := base64.StdEncoding.DecodeString(strings.ReplaceAll(body, "\r\n", ""))
+		out.Write(decoded)
+	}
+	return out.String()
+}
+
+func waitMail(t *testing.T, mails <-chan receivedMail) receivedMail {
+	t.Helper()
+	select {
+	case mail := <-mails:
+		return mail
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for mail")
+		return receivedMail{}
+	}
+}
+
+func TestSMTPEmailSenderPlain(t *testing.T) {
+	cfg, mails := startTestSMTPServer(t, false)
+	sender := NewSMTPEmailSender(cfg)
+
+	err := sender.Send(&EmailMessage{To: "user@example.com", Subject: "测试邮件", Text: "纯文本正文", HTML: "<p>HTML正文</p>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mail := waitMail(t, mails)
+	if mail.Auth != "" || !strings.Contains(mail.From, "<noreply@example.com>") || !strings.Contains(mail.To, "<user@example.com>") {
+		t.Fatalf("unexpected envelope: %+v", mail)
+	}
+	if !strings.Contains(mail.Data, "Subject: =?UTF-8?b?") || !strings.Contains(mail.Data, "multipart/alternative") {
+		t.Fatalf("unexpected headers:\n%s", mail.Data)
+	}
+	if body := decodeMailParts(mail.Data); !strings.Contains(body, "纯文本正文") || !strings.Contains(body, "<p>HTML正文</p>") {
+		t.Fatalf("unexpected body: %s", body)
+	}
+}
+
+func TestSMTPEmailSenderAuth(t *testing.T) {
+	cfg, mails := startTestSMTPServer(t, true)
+	cfg.Password = "secret"
+
+	if err := NewSMTPEmailSender(cfg).Send(&EmailMessage{To: "user@example.com", Subject: "auth", Text: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if mail := waitMail(t, mails); mail.Auth != "\x00noreply@example.com\x00secret" {
+		t.Fatalf("unexpected auth %q", mail.Auth)
+	}
+}
+
+func TestSMTPEmailSenderRequiresAuthWhenPasswordSet(t *testing.T) {
+	cfg, mails := startTestSMTPServer(t, false)
+	cfg.Password = "secret"
+
+	err := NewSMTPEmailSender(cfg).Send(&EmailMessage{To: "user@example.com", Subject: "auth", Text: "hi"})
+	if err == nil || !strings.Contains(err.Error(), "AUTH") {
+		t.Fatalf("want AUTH error, got %v", err)
+	}
+	select {
+	case mail := <-mails:
+		t.Fatalf("mail should not be sent without auth: %+v", mail)
+	default:
+	}
+}
+
+func TestEmailServiceSendsVerifyCode(t *testing.T) {
+	cfg, mails := startTestSMTPServer(t, false)
+	svc := NewEmailService(NewSMTPEmailSender(cfg), 1, 1)
+
+	if err := svc.SendVerifyCode("user@example.com", "472913", 5); err != nil {
+		t.Fatal(err)
+	}
+	mail := waitMail(t, mails)
+	if body := decodeMailParts(mail.Data); !strings.Contains(body, "472913") {
+		t.Fatalf("verify code not found in body: %s", body)
+	}
+}
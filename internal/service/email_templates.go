@@ -0,0 +1,152 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+)
+
+// EmailTemplate 邮件模板名称
+type EmailTemplate string
+
+const (
+	EmailTemplateVerifyCode     EmailTemplate = "verify_code"     // 验证码
+	EmailTemplateLoginAlert     EmailTemplate = "login_alert"     // 登录提醒
+	EmailTemplatePaymentReceipt EmailTemplate = "payment_receipt" // 支付回执
+)
+
+// VerifyCodeEmailData 验证码邮件数据
+type VerifyCodeEmailData struct {
+	Code          string
+	ExpireMinutes int
+}
+
+// LoginAlertEmailData 登录提醒邮件数据
+type LoginAlertEmailData struct {
+	Nickname  string
+	LoginTime string
+	IPAddress string
+	DeviceID  string
+}
+
+// PaymentReceiptEmailData 支付回执邮件数据
+type PaymentReceiptEmailData struct {
+	Nickname    string
+	OutTradeNo  string
+	ProductName string
+	Amount      string
+	PaymentType string
+	PaidAt      string
+}
+
+type emailTemplateSet struct {
+	subject string
+	html    *htmltemplate.Template
+	text    *texttemplate.Template
+}
+
+const emailLayoutHTML = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head><meta charset="UTF-8"><title>{{template "title" .}}</title></head>
+<body style="margin:0;padding:24px;background:#f5f6f8;font-family:-apple-system,BlinkMacSystemFont,'PingFang SC','Microsoft YaHei',sans-serif;color:#333;">
+<div style="max-width:560px;margin:0 auto;background:#fff;border-radius:8px;padding:32px;">
+{{template "content" .}}
+<p style="margin-top:32px;font-size:12px;color:#999;">此邮件由系统自动发送，请勿直接回复。</p>
+</div>
+</body>
+</html>`
+
+var emailTemplateSources = map[EmailTemplate]struct {
+	subject string
+	html    string
+	text    string
+}{
+	EmailTemplateVerifyCode: {
+		subject: "您的验证码",
+		html: `{{define "title"}}验证码{{end}}{{define "content"}}
+<h2 style="margin-top:0;">邮箱验证码</h2>
+<p>您正在进行邮箱验证，验证码为：</p>
+<p style="font-size:28px;font-weight:bold;letter-spacing:6px;color:#1677ff;">{{.Code}}</p>
+<p>验证码 {{.ExpireMinutes}} 分钟内有效，请勿泄露给他人。如非本人操作，请忽略此邮件。</p>
+{{end}}`,
+		text: `您的邮箱验证码为：{{.Code}}
+验证码 {{.ExpireMinutes}} 分钟内有效，请勿泄露给他人。如非本人操作，请忽略此邮件。
+`,
+	},
+	EmailTemplateLoginAlert: {
+		subject: "账号登录提醒",
+		html: `{{define "title"}}登录提醒{{end}}{{define "content"}}
+<h2 style="margin-top:0;">账号登录提醒</h2>
+<p>{{.Nickname}}，您好：</p>
+<p>您的账号于 <b>{{.LoginTime}}</b> 在新的设备上登录。</p>
+<table style="font-size:14px;color:#666;">
+<tr><td style="padding-right:16px;">IP地址</td><td>{{.IPAddress}}</td></tr>
+{{if .DeviceID}}<tr><td style="padding-right:16px;">设备</td><td>{{.DeviceID}}</td></tr>{{end}}
+</table>
+<p>如非本人操作，请尽快在「设备管理」中移除该设备并检查账号安全。</p>
+{{end}}`,
+		text: `{{.Nickname}}，您好：
+您的账号于 {{.LoginTime}} 在新的设备上登录。
+IP地址：{{.IPAddress}}
+{{if .DeviceID}}设备：{{.DeviceID}}
+{{end}}如非本人操作，请尽快在「设备管理」中移除该设备并检查账号安全。
+`,
+	},
+	EmailTemplatePaymentReceipt: {
+		subject: "支付成功回执",
+		html: `{{define "title"}}支付回执{{end}}{{define "content"}}
+<h2 style="margin-top:0;">支付成功</h2>
+<p>{{.Nickname}}，您好，感谢您的购买，以下是本次支付信息：</p>
+<table style="font-size:14px;color:#666;">
+<tr><td style="padding-right:16px;">订单号</td><td>{{.OutTradeNo}}</td></tr>
+<tr><td style="padding-right:16px;">商品</td><td>{{.ProductName}}</td></tr>
+<tr><td style="padding-right:16px;">金额</td><td>¥{{.Amount}}</td></tr>
+<tr><td style="padding-right:16px;">支付方式</td><td>{{.PaymentType}}</td></tr>
+<tr><td style="padding-right:16px;">支付时间</td><td>{{.PaidAt}}</td></tr>
+</table>
+{{end}}`,
+		text: `{{.Nickname}}，您好，感谢您的购买，以下是本次支付信息：
+订单号：{{.OutTradeNo}}
+商品：{{.ProductName}}
+金额：¥{{.Amount}}
+支付方式：{{.PaymentType}}
+支付时间：{{.PaidAt}}
+`,
+	},
+}
+
+var emailTemplates = mustParseEmailTemplates()
+
+// mustParseEmailTemplates 启动时解析所有邮件模板，模板错误属于编码错误直接panic
+func mustParseEmailTemplates() map[EmailTemplate]*emailTemplateSet {
+	sets := make(map[EmailTemplate]*emailTemplateSet, len(emailTemplateSources))
+	for name, src := range emailTemplateSources {
+		html := htmltemplate.Must(htmltemplate.New(string(name)).Parse(emailLayoutHTML))
+		html = htmltemplate.Must(html.Parse(src.html))
+		text := texttemplate.Must(texttemplate.New(string(name)).Parse(src.text))
+		sets[name] = &emailTemplateSet{
+			subject: src.subject,
+			html:    html,
+			text:    text,
+		}
+	}
+	return sets
+}
+
+// RenderEmail 渲染邮件模板，返回主题、HTML正文和纯文本正文
+func RenderEmail(name EmailTemplate, data interface{}) (subject, html, text string, err error) {
+	set, ok := emailTemplates[name]
+	if !ok {
+		return "", "", "", fmt.Errorf("邮件模板不存在: %s", name)
+	}
+
+	var htmlBuf, textBuf bytes.Buffer
+	if err := set.html.Execute(&htmlBuf, data); err != nil {
+		return "", "", "", fmt.Errorf("渲染邮件HTML失败: %w", err)
+	}
+	if err := set.text.Execute(&textBuf, data); err != nil {
+		return "", "", "", fmt.Errorf("渲染邮件文本失败: %w", err)
+	}
+	return set.subject, htmlBuf.String(), textBuf.String(), nil
+}
@@ -0,0 +1,203 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"01agent_server/internal/tools"
+)
+
+const (
+	maxImageBytes  = 20 << 20 // 单张图片最大字节数
+	maxImagePixels = 40 << 20 // 单张图片最大像素数，解码后约 160MB
+	imageTimeout   = 20 * time.Second
+)
+
+// namedColors 常用颜色名，其余颜色名按无法识别处理
+var namedColors = map[string]color.NRGBA{
+	"black":   {0, 0, 0, 255},
+	"white":   {255, 255, 255, 255},
+	"red":     {255, 0, 0, 255},
+	"green":   {0, 128, 0, 255},
+	"blue":    {0, 0, 255, 255},
+	"gray":    {128, 128, 128, 255},
+	"grey":    {128, 128, 128, 255},
+	"yellow":  {255, 255, 0, 255},
+	"orange":  {255, 165, 0, 255},
+	"purple":  {128, 0, 128, 255},
+	"pink":    {255, 192, 203, 255},
+	"silver":  {192, 192, 192, 255},
+	"navy":    {0, 0, 128, 255},
+	"teal":    {0, 128, 128, 255},
+	"maroon":  {128, 0, 0, 255},
+	"olive":   {128, 128, 0, 255},
+	"lime":    {0, 255, 0, 255},
+	"aqua":    {0, 255, 255, 255},
+	"cyan":    {0, 255, 255, 255},
+	"fuchsia": {255, 0, 255, 255},
+	"magenta": {255, 0, 255, 255},
+}
+
+// parseColor 解析 #rgb / #rgba / #rrggbb / #rrggbbaa / rgb() / rgba() 与常用颜色名
+// 空字符串、transparent 与无法识别的颜色返回 false
+func parseColor(s string) (color.NRGBA, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" || s == "transparent" || s == "none" {
+		return color.NRGBA{}, false
+	}
+	if c, ok := namedColors[s]; ok {
+		return c, true
+	}
+
+	if strings.HasPrefix(s, "#") {
+		hex := s[1:]
+		if len(hex) == 3 || len(hex) == 4 {
+			var expanded strings.Builder
+			for _, ch := range hex {
+				expanded.WriteRune(ch)
+				expanded.WriteRune(ch)
+			}
+			hex = expanded.String()
+		}
+		if len(hex) != 6 && len(hex) != 8 {
+			return color.NRGBA{}, false
+		}
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return color.NRGBA{}, false
+		}
+		if len(hex) == 6 {
+			return color.NRGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}, true
+		}
+		return color.NRGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}, true
+	}
+
+	if strings.HasPrefix(s, "rgb") {
+		open, end := strings.IndexByte(s, '('), strings.LastIndexByte(s, ')')
+		if open < 0 || end < open {
+			return color.NRGBA{}, false
+		}
+		parts := strings.FieldsFunc(s[open+1:end], func(r rune) bool { return r == ',' || r == ' ' || r == '/' })
+		if len(parts) < 3 {
+			return color.NRGBA{}, false
+		}
+		var c color.NRGBA
+		channels := []*uint8{&c.R, &c.G, &c.B}
+		for i, ch := range channels {
+			v, err := strconv.ParseFloat(strings.TrimSuffix(parts[i], "%"), 64)
+			if err != nil {
+				return color.NRGBA{}, false
+			}
+			if strings.HasSuffix(parts[i], "%") {
+				v = v * 255 / 100
+			}
+			*ch = clampByte(v)
+		}
+		c.A = 255
+		if len(parts) >= 4 {
+			a, err := strconv.ParseFloat(strings.TrimSuffix(parts[3], "%"), 64)
+			if err != nil {
+				return color.NRGBA{}, false
+			}
+			if strings.HasSuffix(parts[3], "%") {
+				a /= 100
+			}
+			c.A = clampByte(a * 255)
+		}
+		return c, true
+	}
+	return color.NRGBA{}, false
+}
+
+func clampByte(v float64) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 255 {
+		return 255
+	}
+	return uint8(v + 0.5)
+}
+
+// imageAsset 已下载并解码的图片
+type imageAsset struct {
+	data  []byte
+	mime  string
+	image *image.NRGBA
+	err   error
+}
+
+// AssetLoader 下载并缓存一次导出中用到的图片，支持 http(s) 地址与 data URI
+type AssetLoader struct {
+	mu    sync.Mutex
+	cache map[string]*imageAsset
+}
+
+// NewAssetLoader 创建图片加载器，同一导出任务的所有画板共用
+func NewAssetLoader() *AssetLoader {
+	return &AssetLoader{
+		cache: make(map[string]*imageAsset),
+	}
+}
+
+// load 获取图片，同一地址只下载一次
+func (l *AssetLoader) load(ctx context.Context, src string) (*imageAsset, error) {
+	l.mu.Lock()
+	asset, ok := l.cache[src]
+	l.mu.Unlock()
+	if ok {
+		return asset, asset.err
+	}
+
+	asset = &imageAsset{}
+	asset.data, asset.mime, asset.err = l.fetch(ctx, src)
+	if asset.err == nil {
+		var img image.Image
+		if cfg, _, err := image.DecodeConfig(bytes.NewReader(asset.data)); err == nil && cfg.Width*cfg.Height > maxImagePixels {
+			asset.err = fmt.Errorf("图片尺寸过大（%dx%d）", cfg.Width, cfg.Height)
+		} else if img, _, asset.err = image.Decode(bytes.NewReader(asset.data)); asset.err != nil {
+			asset.err = fmt.Errorf("不支持的图片格式: %w", asset.err)
+		} else {
+			asset.image = toNRGBA(img)
+		}
+	}
+	if ctx.Err() == nil {
+		// 任务取消导致的失败不缓存
+		l.mu.Lock()
+		l.cache[src] = asset
+		l.mu.Unlock()
+	}
+	return asset, asset.err
+}
+
+// fetch 获取图片数据，地址来自用户工程内容，通过只能访问公网地址的客户端下载
+func (l *AssetLoader) fetch(ctx context.Context, src string) ([]byte, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, imageTimeout)
+	defer cancel()
+	img, err := tools.FetchImage(ctx, src, maxImageBytes)
+	if err != nil {
+		return nil, "", err
+	}
+	return img.Data, img.ContentType, nil
+}
+
+func toNRGBA(img image.Image) *image.NRGBA {
+	if nrgba, ok := img.(*image.NRGBA); ok && nrgba.Rect.Min == (image.Point{}) {
+		return nrgba
+	}
+	b := img.Bounds()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(nrgba, nrgba.Rect, img, b.Min, draw.Src)
+	return nrgba
+}
@@ -0,0 +1,106 @@
+package exporter
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/png"
+)
+
+// pdfPage PDF 的一页，图片铺满整页
+type pdfPage struct {
+	PNG    []byte
+	Width  float64 // 页面尺寸（画板像素）
+	Height float64
+}
+
+// buildPDF 将每个画板的 PNG 作为一页写入 PDF
+// 页面按 96 DPI 换算为 pt，透明区域合成到白色背景上
+func buildPDF(pages []pdfPage) ([]byte, error) {
+	var buf bytes.Buffer
+	var offsets []int
+	beginObj := func() int {
+		offsets = append(offsets, buf.Len())
+		id := len(offsets)
+		fmt.Fprintf(&buf, "%d 0 obj\n", id)
+		return id
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// 对象 1 为目录，对象 2 为页面树，页面对象在写完所有页后才能确定，先预留编号
+	catalogID, pagesID := 1, 2
+	offsets = append(offsets, 0, 0)
+
+	var pageIDs []int
+	for i, page := range pages {
+		img, err := png.Decode(bytes.NewReader(page.PNG))
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 页图片解码失败: %w", i+1, err)
+		}
+		data, err := flattenRGB(img)
+		if err != nil {
+			return nil, err
+		}
+		bounds := img.Bounds()
+		w, h := page.Width*72/96, page.Height*72/96
+
+		imageID := beginObj()
+		fmt.Fprintf(&buf, "<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode /Length %d >>\nstream\n",
+			bounds.Dx(), bounds.Dy(), len(data))
+		buf.Write(data)
+		buf.WriteString("\nendstream\nendobj\n")
+
+		content := fmt.Sprintf("q\n%s 0 0 %s 0 0 cm\n/Im%d Do\nQ\n", f(w), f(h), i)
+		contentID := beginObj()
+		fmt.Fprintf(&buf, "<< /Length %d >>\nstream\n%sendstream\nendobj\n", len(content), content)
+
+		pageID := beginObj()
+		fmt.Fprintf(&buf, "<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << /XObject << /Im%d %d 0 R >> >> /Contents %d 0 R >>\nendobj\n",
+			pagesID, f(w), f(h), i, imageID, contentID)
+		pageIDs = append(pageIDs, pageID)
+	}
+
+	offsets[catalogID-1] = buf.Len()
+	fmt.Fprintf(&buf, "%d 0 obj\n<< /Type /Catalog /Pages %d 0 R >>\nendobj\n", catalogID, pagesID)
+	offsets[pagesID-1] = buf.Len()
+	fmt.Fprintf(&buf, "%d 0 obj\n<< /Type /Pages /Count %d /Kids [", pagesID, len(pageIDs))
+	for _, id := range pageIDs {
+		fmt.Fprintf(&buf, " %d 0 R", id)
+	}
+	buf.WriteString(" ] >>\nendobj\n")
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, catalogID, xref)
+	return buf.Bytes(), nil
+}
+
+// flattenRGB 将图片合成到白色背景并输出 zlib 压缩的 RGB 数据
+func flattenRGB(img image.Image) ([]byte, error) {
+	bounds := img.Bounds()
+	var out bytes.Buffer
+	zw := zlib.NewWriter(&out)
+	row := make([]byte, bounds.Dx()*3)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			// RGBA() 返回预乘 alpha 的 16 位分量
+			r, g, b, a := img.At(x, y).RGBA()
+			white := 0xffff - a
+			i := (x - bounds.Min.X) * 3
+			row[i] = uint8((r + white) >> 8)
+			row[i+1] = uint8((g + white) >> 8)
+			row[i+2] = uint8((b + white) >> 8)
+		}
+		if _, err := zw.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
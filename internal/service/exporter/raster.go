@@ -0,0 +1,330 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+)
+
+// 每个像素的超采样数（边长），用于边缘抗锯齿
+const (
+	shapeSamples = 3
+	imageSamples = 2
+)
+
+// RasterRenderer 纯 Go 内置渲染器，不依赖外部程序
+// 支持矩形、椭圆、线段、图片、透明度、圆角与旋转，不绘制文字
+type RasterRenderer struct{}
+
+// Name 渲染器名称
+func (r *RasterRenderer) Name() string {
+	return builtinRendererName
+}
+
+// Render 渲染画板
+func (r *RasterRenderer) Render(ctx context.Context, frame *Frame, opts RenderOptions) ([]byte, []string, error) {
+	scale := opts.Scale
+	width, height := int(math.Ceil(frame.Width*scale)), int(math.Ceil(frame.Height*scale))
+	canvas := &rasterCanvas{dst: image.NewRGBA(image.Rect(0, 0, width, height)), scale: scale}
+
+	var warnings []string
+	bg, _ := parseColor(frameBackground(frame))
+	canvas.paint(&Element{Width: frame.Width, Height: frame.Height, Opacity: 1}, 1, rectShape(frame.Width, frame.Height, 0), solid(bg))
+	if frame.BgImage != "" {
+		bgImage := &Element{Type: ElementImage, Width: frame.Width, Height: frame.Height, Src: frame.BgImage, Fit: "cover", Opacity: 1}
+		if warning := canvas.drawImage(ctx, bgImage, opts.Assets); warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+
+	skippedText := 0
+	for _, el := range frame.Elements {
+		if err := ctx.Err(); err != nil {
+			return nil, warnings, err
+		}
+		switch el.Type {
+		case ElementRect:
+			canvas.fillShape(el, roundedRectOutline(el))
+			canvas.strokeShape(el, roundedRectOutline(el))
+		case ElementEllipse:
+			canvas.fillShape(el, ellipseOutline(el))
+			canvas.strokeShape(el, ellipseOutline(el))
+		case ElementLine:
+			canvas.drawLine(el)
+		case ElementImage:
+			if warning := canvas.drawImage(ctx, el, opts.Assets); warning != "" {
+				warnings = append(warnings, warning)
+			}
+		case ElementText:
+			if el.Fill != "" {
+				if c, ok := parseColor(el.Fill); ok {
+					canvas.paint(el, shapeSamples, rectShape(el.Width, el.Height, 0), solid(c))
+				}
+			}
+			if wrapText(el.Text, el.FontSize, el.Width) != nil {
+				skippedText++
+			}
+		}
+	}
+	if skippedText > 0 {
+		warnings = append(warnings, fmt.Sprintf("内置渲染器不支持文字，画板「%s」跳过了 %d 个文字元素，请在服务器安装 rsvg-convert", frame.Name, skippedText))
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas.dst); err != nil {
+		return nil, warnings, err
+	}
+	return buf.Bytes(), warnings, nil
+}
+
+// shapeFunc 判断元素本地坐标（相对元素左上角）是否在形状内
+type shapeFunc func(x, y float64) bool
+
+// shaderFunc 元素本地坐标处的颜色，ok 为 false 表示该处不绘制
+type shaderFunc func(x, y float64) (color.NRGBA, bool)
+
+type rasterCanvas struct {
+	dst   *image.RGBA
+	scale float64
+}
+
+// outlineFunc 按 grow 向外（负数向内）扩展后的形状，坐标仍相对原元素左上角
+type outlineFunc func(grow float64) shapeFunc
+
+func roundedRectOutline(el *Element) outlineFunc {
+	return func(grow float64) shapeFunc {
+		return offsetShape(rectShape(el.Width+2*grow, el.Height+2*grow, math.Max(el.Radius+grow, 0)), grow)
+	}
+}
+
+func ellipseOutline(el *Element) outlineFunc {
+	return func(grow float64) shapeFunc {
+		return offsetShape(ellipseShape(el.Width+2*grow, el.Height+2*grow), grow)
+	}
+}
+
+func (c *rasterCanvas) fillShape(el *Element, outline outlineFunc) {
+	if fill, ok := parseColor(el.Fill); ok {
+		c.paint(el, shapeSamples, outline(0), solid(fill))
+	}
+}
+
+// strokeShape 绘制描边，描边以形状边缘为中心向内外各延伸一半线宽
+func (c *rasterCanvas) strokeShape(el *Element, outline outlineFunc) {
+	stroke, ok := parseColor(el.Stroke)
+	if !ok || el.StrokeWidth <= 0 {
+		return
+	}
+	half := el.StrokeWidth / 2
+	outer, inner := outline(half), outline(-half)
+	ring := func(x, y float64) bool {
+		return outer(x, y) && !inner(x, y)
+	}
+	c.paintBounds(el, shapeSamples, -half, ring, solid(stroke))
+}
+
+func (c *rasterCanvas) drawLine(el *Element) {
+	stroke, ok := parseColor(el.Stroke)
+	if !ok {
+		return
+	}
+	// 线段使用画板坐标，以包围盒作为元素区域
+	half := el.StrokeWidth / 2
+	minX, minY := math.Min(el.X, el.X2), math.Min(el.Y, el.Y2)
+	box := &Element{X: minX, Y: minY, Width: math.Abs(el.X2 - el.X), Height: math.Abs(el.Y2 - el.Y), Opacity: el.Opacity}
+	x1, y1, x2, y2 := el.X-minX, el.Y-minY, el.X2-minX, el.Y2-minY
+	onLine := func(x, y float64) bool {
+		return segmentDistance(x, y, x1, y1, x2, y2) <= half
+	}
+	c.paintBounds(box, shapeSamples, -half, onLine, solid(stroke))
+}
+
+func (c *rasterCanvas) drawImage(ctx context.Context, el *Element, assets *AssetLoader) string {
+	asset, err := assets.load(ctx, el.Src)
+	if err != nil {
+		return fmt.Sprintf("图片 %s 加载失败: %v", shortSrc(el.Src), err)
+	}
+	src := asset.image
+	sw, sh := float64(src.Rect.Dx()), float64(src.Rect.Dy())
+	if sw == 0 || sh == 0 {
+		return ""
+	}
+
+	// 计算图片在元素内的绘制区域
+	dw, dh := el.Width, el.Height
+	switch el.Fit {
+	case "contain":
+		s := math.Min(el.Width/sw, el.Height/sh)
+		dw, dh = sw*s, sh*s
+	case "cover":
+		s := math.Max(el.Width/sw, el.Height/sh)
+		dw, dh = sw*s, sh*s
+	}
+	ox, oy := (el.Width-dw)/2, (el.Height-dh)/2
+
+	shader := func(x, y float64) (color.NRGBA, bool) {
+		u, v := (x-ox)/dw*sw, (y-oy)/dh*sh
+		if u < 0 || v < 0 || u >= sw || v >= sh {
+			return color.NRGBA{}, false
+		}
+		return bilinear(src, u, v), true
+	}
+	c.paint(el, imageSamples, rectShape(el.Width, el.Height, el.Radius), shader)
+	c.strokeShape(el, roundedRectOutline(el))
+	return ""
+}
+
+func (c *rasterCanvas) paint(el *Element, samples int, shape shapeFunc, shader shaderFunc) {
+	c.paintBounds(el, samples, 0, shape, shader)
+}
+
+// paintBounds 对元素区域（向外扩展 -inset）内的像素超采样，按覆盖率与透明度混合到画布
+func (c *rasterCanvas) paintBounds(el *Element, samples int, inset float64, shape shapeFunc, shader shaderFunc) {
+	cx, cy := el.X+el.Width/2, el.Y+el.Height/2
+	sin, cos := math.Sincos(el.Rotation * math.Pi / 180)
+
+	// 旋转后的包围盒（画板坐标）
+	hw, hh := el.Width/2-inset, el.Height/2-inset
+	ex := math.Abs(hw*cos) + math.Abs(hh*sin)
+	ey := math.Abs(hw*sin) + math.Abs(hh*cos)
+	bounds := image.Rect(
+		int(math.Floor((cx-ex)*c.scale)), int(math.Floor((cy-ey)*c.scale)),
+		int(math.Ceil((cx+ex)*c.scale)), int(math.Ceil((cy+ey)*c.scale)),
+	).Intersect(c.dst.Rect)
+
+	n := float64(samples * samples)
+	for py := bounds.Min.Y; py < bounds.Max.Y; py++ {
+		for px := bounds.Min.X; px < bounds.Max.X; px++ {
+			var r, g, b, a float64
+			for sy := 0; sy < samples; sy++ {
+				for sx := 0; sx < samples; sx++ {
+					// 采样点转换为元素本地坐标：平移到中心、反向旋转、平移到左上角
+					gx := (float64(px)+(float64(sx)+0.5)/float64(samples))/c.scale - cx
+					gy := (float64(py)+(float64(sy)+0.5)/float64(samples))/c.scale - cy
+					lx := gx*cos + gy*sin + el.Width/2
+					ly := -gx*sin + gy*cos + el.Height/2
+					if !shape(lx, ly) {
+						continue
+					}
+					col, ok := shader(lx, ly)
+					if !ok {
+						continue
+					}
+					alpha := float64(col.A) / 255
+					r += float64(col.R) * alpha
+					g += float64(col.G) * alpha
+					b += float64(col.B) * alpha
+					a += alpha
+				}
+			}
+			if a == 0 {
+				continue
+			}
+			opacity := el.Opacity / n
+			r, g, b, a = r*opacity, g*opacity, b*opacity, a*opacity
+
+			// 预乘 alpha 的 source-over 混合
+			i := c.dst.PixOffset(px, py)
+			pix := c.dst.Pix[i : i+4 : i+4]
+			rest := 1 - a
+			pix[0] = clampByte(r + float64(pix[0])*rest)
+			pix[1] = clampByte(g + float64(pix[1])*rest)
+			pix[2] = clampByte(b + float64(pix[2])*rest)
+			pix[3] = clampByte(a*255 + float64(pix[3])*rest)
+		}
+	}
+}
+
+func solid(c color.NRGBA) shaderFunc {
+	return func(x, y float64) (color.NRGBA, bool) {
+		return c, true
+	}
+}
+
+// rectShape 圆角矩形，圆角半径不超过短边的一半
+func rectShape(w, h, radius float64) shapeFunc {
+	radius = math.Min(radius, math.Min(w, h)/2)
+	return func(x, y float64) bool {
+		if x < 0 || y < 0 || x > w || y > h {
+			return false
+		}
+		if radius <= 0 {
+			return true
+		}
+		dx := math.Max(radius-x, x-(w-radius))
+		dy := math.Max(radius-y, y-(h-radius))
+		if dx <= 0 || dy <= 0 {
+			return true
+		}
+		return dx*dx+dy*dy <= radius*radius
+	}
+}
+
+func ellipseShape(w, h float64) shapeFunc {
+	rx, ry := w/2, h/2
+	return func(x, y float64) bool {
+		if rx <= 0 || ry <= 0 {
+			return false
+		}
+		dx, dy := (x-rx)/rx, (y-ry)/ry
+		return dx*dx+dy*dy <= 1
+	}
+}
+
+// offsetShape 将向外扩展 grow 后的形状平移回原元素坐标系
+func offsetShape(shape shapeFunc, grow float64) shapeFunc {
+	return func(x, y float64) bool {
+		return shape(x+grow, y+grow)
+	}
+}
+
+func segmentDistance(px, py, x1, y1, x2, y2 float64) float64 {
+	dx, dy := x2-x1, y2-y1
+	t := 0.0
+	if l := dx*dx + dy*dy; l > 0 {
+		t = math.Max(0, math.Min(1, ((px-x1)*dx+(py-y1)*dy)/l))
+	}
+	return math.Hypot(px-(x1+t*dx), py-(y1+t*dy))
+}
+
+// bilinear 双线性插值取色
+func bilinear(img *image.NRGBA, u, v float64) color.NRGBA {
+	maxX, maxY := img.Rect.Dx()-1, img.Rect.Dy()-1
+	u, v = u-0.5, v-0.5
+	x0, y0 := int(math.Floor(u)), int(math.Floor(v))
+	fx, fy := u-float64(x0), v-float64(y0)
+	clampInt := func(v, max int) int {
+		if v < 0 {
+			return 0
+		}
+		if v > max {
+			return max
+		}
+		return v
+	}
+	x1, y1 := clampInt(x0+1, maxX), clampInt(y0+1, maxY)
+	x0, y0 = clampInt(x0, maxX), clampInt(y0, maxY)
+
+	at := func(x, y int) [4]float64 {
+		i := img.PixOffset(x, y)
+		p := img.Pix[i : i+4 : i+4]
+		a := float64(p[3])
+		// 按预乘 alpha 插值，避免透明像素的颜色渗入边缘
+		return [4]float64{float64(p[0]) * a, float64(p[1]) * a, float64(p[2]) * a, a}
+	}
+	p00, p10, p01, p11 := at(x0, y0), at(x1, y0), at(x0, y1), at(x1, y1)
+	var out [4]float64
+	for i := range out {
+		top := p00[i]*(1-fx) + p10[i]*fx
+		bottom := p01[i]*(1-fx) + p11[i]*fx
+		out[i] = top*(1-fy) + bottom*fy
+	}
+	if out[3] == 0 {
+		return color.NRGBA{}
+	}
+	return color.NRGBA{clampByte(out[0] / out[3]), clampByte(out[1] / out[3]), clampByte(out[2] / out[3]), clampByte(out[3])}
+}
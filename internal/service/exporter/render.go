@@ -0,0 +1,80 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"01agent_server/internal/config"
+	"01agent_server/internal/repository"
+)
+
+const (
+	defaultRendererCommand = "rsvg-convert"
+	builtinRendererName    = "builtin"
+	renderFrameTimeout     = 2 * time.Minute
+)
+
+// RenderOptions 渲染参数
+type RenderOptions struct {
+	Scale  float64      // 输出像素倍率，1 表示按画板尺寸输出
+	Assets *AssetLoader // 图片加载器，同一任务的画板共用缓存
+}
+
+// Renderer 将画板渲染为 PNG，返回图片数据与不影响导出的警告（如图片加载失败）
+type Renderer interface {
+	Name() string
+	Render(ctx context.Context, frame *Frame, opts RenderOptions) ([]byte, []string, error)
+}
+
+// NewRenderer 根据配置创建渲染器
+// 默认将画板转换为 SVG 后调用 rsvg-convert（librsvg）离线渲染，支持文字与系统字体；
+// 命令不存在或配置为 builtin 时使用纯 Go 的内置渲染器，内置渲染器不绘制文字
+func NewRenderer() Renderer {
+	command := defaultRendererCommand
+	if config.AppConfig != nil && config.AppConfig.Export.RendererCommand != "" {
+		command = config.AppConfig.Export.RendererCommand
+	}
+	if command == builtinRendererName {
+		return &RasterRenderer{}
+	}
+	path, err := exec.LookPath(command)
+	if err != nil {
+		repository.Warnf("Export renderer %q not found, falling back to builtin renderer without text support: %v", command, err)
+		return &RasterRenderer{}
+	}
+	return &SVGRenderer{Command: path}
+}
+
+// SVGRenderer 通过 rsvg-convert 将 SVG 转换为 PNG
+type SVGRenderer struct {
+	Command string
+}
+
+// Name 渲染器名称
+func (r *SVGRenderer) Name() string {
+	return "rsvg"
+}
+
+// Render 渲染画板
+func (r *SVGRenderer) Render(ctx context.Context, frame *Frame, opts RenderOptions) ([]byte, []string, error) {
+	svg, warnings := buildSVG(ctx, frame, opts.Assets)
+
+	ctx, cancel := context.WithTimeout(ctx, renderFrameTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, r.Command, "-f", "png", "-z", f(opts.Scale))
+	cmd.Stdin = bytes.NewReader(svg)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, warnings, fmt.Errorf("渲染画板「%s」超时", frame.Name)
+		}
+		return nil, warnings, fmt.Errorf("渲染画板「%s」失败: %v %s", frame.Name, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), warnings, nil
+}
@@ -0,0 +1,367 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"01agent_server/internal/models/short_post"
+)
+
+const (
+	defaultFrameWidth  = 1080
+	defaultFrameHeight = 1440
+	maxFrameSide       = 8000 // 画板单边最大像素，防止超大画板占满内存
+)
+
+// 元素类型
+const (
+	ElementRect    = "rect"
+	ElementEllipse = "ellipse"
+	ElementLine    = "line"
+	ElementImage   = "image"
+	ElementText    = "text"
+)
+
+// elementTypeAliases 前端不同版本使用的元素类型名
+var elementTypeAliases = map[string]string{
+	"rect":      ElementRect,
+	"rectangle": ElementRect,
+	"shape":     ElementRect,
+	"ellipse":   ElementEllipse,
+	"circle":    ElementEllipse,
+	"line":      ElementLine,
+	"image":     ElementImage,
+	"img":       ElementImage,
+	"picture":   ElementImage,
+	"text":      ElementText,
+	"textbox":   ElementText,
+	"i-text":    ElementText,
+}
+
+// Scene 工程内容解析后的画板列表
+type Scene struct {
+	Frames []*Frame
+}
+
+// Frame 一个导出页（画板），元素坐标已换算为相对画板左上角
+type Frame struct {
+	ID         string
+	Name       string
+	Width      float64
+	Height     float64
+	Background string
+	BgImage    string
+	Elements   []*Element
+
+	x, y float64 // 画板在画布上的位置
+}
+
+// Element 画板中的元素
+type Element struct {
+	ID          string
+	Type        string
+	X, Y        float64
+	Width       float64
+	Height      float64
+	Rotation    float64 // 角度，绕元素中心顺时针旋转
+	Opacity     float64
+	Fill        string
+	Stroke      string
+	StrokeWidth float64
+	Radius      float64
+	Src         string
+	Fit         string // 图片填充方式：fill / contain / cover
+	Text        string
+	FontSize    float64
+	FontFamily  string
+	FontWeight  string
+	Color       string
+	Align       string
+	LineHeight  float64
+
+	// 线段终点（相对画板），仅 line 使用
+	X2, Y2 float64
+
+	frameID string
+	zIndex  float64
+	order   int
+}
+
+// ParseScene 解析工程内容中的画布配置、画板与元素
+// elements_data 中的元素使用画布坐标，通过 frame_id / parent_id 或元素中心点归属画板；
+// 画板 children 中的元素使用相对画板的坐标。没有画板时整个画布作为一个画板
+func ParseScene(content *short_post.ShortPostProjectContent) (*Scene, error) {
+	var canvas map[string]interface{}
+	var frames, elements []interface{}
+	if err := unmarshalField(content.CanvasConfig, &canvas); err != nil {
+		return nil, fmt.Errorf("画布配置格式错误: %w", err)
+	}
+	if err := unmarshalField(content.FramesData, &frames); err != nil {
+		return nil, fmt.Errorf("画板数据格式错误: %w", err)
+	}
+	if err := unmarshalField(content.ElementsData, &elements); err != nil {
+		return nil, fmt.Errorf("元素数据格式错误: %w", err)
+	}
+
+	scene := &Scene{}
+	byID := make(map[string]*Frame)
+	order := 0
+	for i, raw := range frames {
+		m, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		frame := &Frame{
+			ID:         str(m, "id"),
+			Name:       str(m, "name", "title"),
+			Width:      num(m, 0, "width", "w"),
+			Height:     num(m, 0, "height", "h"),
+			Background: str(m, "background", "backgroundColor", "background_color", "fill"),
+			BgImage:    str(m, "backgroundImage", "background_image"),
+			x:          num(m, 0, "x", "left"),
+			y:          num(m, 0, "y", "top"),
+		}
+		if frame.ID == "" {
+			frame.ID = strconv.Itoa(i + 1)
+		}
+		if frame.Name == "" {
+			frame.Name = fmt.Sprintf("画板%d", i+1)
+		}
+		if err := normalizeFrameSize(frame); err != nil {
+			return nil, err
+		}
+		for _, child := range list(m, "children", "elements") {
+			if el := parseElement(child, &order); el != nil {
+				frame.Elements = append(frame.Elements, el)
+			}
+		}
+		scene.Frames = append(scene.Frames, frame)
+		byID[frame.ID] = frame
+	}
+
+	if len(scene.Frames) == 0 {
+		frame := &Frame{
+			ID:         "canvas",
+			Name:       "画布",
+			Width:      num(canvas, 0, "width", "w"),
+			Height:     num(canvas, 0, "height", "h"),
+			Background: str(canvas, "background", "backgroundColor", "background_color"),
+		}
+		if err := normalizeFrameSize(frame); err != nil {
+			return nil, err
+		}
+		scene.Frames = append(scene.Frames, frame)
+	}
+
+	for _, raw := range elements {
+		el := parseElement(raw, &order)
+		if el == nil {
+			continue
+		}
+		frame := byID[el.frameID]
+		if frame == nil {
+			frame = containingFrame(scene.Frames, el)
+		}
+		if frame == nil {
+			continue
+		}
+		el.X -= frame.x
+		el.Y -= frame.y
+		el.X2 -= frame.x
+		el.Y2 -= frame.y
+		frame.Elements = append(frame.Elements, el)
+	}
+
+	for _, frame := range scene.Frames {
+		sort.SliceStable(frame.Elements, func(i, j int) bool {
+			a, b := frame.Elements[i], frame.Elements[j]
+			if a.zIndex != b.zIndex {
+				return a.zIndex < b.zIndex
+			}
+			return a.order < b.order
+		})
+	}
+	return scene, nil
+}
+
+// Select 按画板 ID 筛选画板，ids 为空时返回全部
+func (s *Scene) Select(ids []string) ([]*Frame, error) {
+	if len(ids) == 0 {
+		return s.Frames, nil
+	}
+	byID := make(map[string]*Frame, len(s.Frames))
+	for _, frame := range s.Frames {
+		byID[frame.ID] = frame
+	}
+	selected := make([]*Frame, 0, len(ids))
+	for _, id := range ids {
+		frame, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("画板不存在: %s", id)
+		}
+		selected = append(selected, frame)
+	}
+	return selected, nil
+}
+
+func parseElement(raw interface{}, order *int) *Element {
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if hidden, _ := m["hidden"].(bool); hidden {
+		return nil
+	}
+	if visible, ok := m["visible"].(bool); ok && !visible {
+		return nil
+	}
+	typ, ok := elementTypeAliases[strings.ToLower(str(m, "type"))]
+	if !ok {
+		return nil
+	}
+
+	*order++
+	el := &Element{
+		ID:          str(m, "id"),
+		Type:        typ,
+		X:           num(m, 0, "x", "left"),
+		Y:           num(m, 0, "y", "top"),
+		Width:       num(m, 0, "width", "w"),
+		Height:      num(m, 0, "height", "h"),
+		Rotation:    num(m, 0, "rotation", "angle"),
+		Opacity:     num(m, 1, "opacity"),
+		Fill:        str(m, "fill", "backgroundColor", "background_color", "background"),
+		Stroke:      str(m, "stroke", "borderColor", "border_color"),
+		StrokeWidth: num(m, 0, "strokeWidth", "stroke_width", "borderWidth", "border_width"),
+		Radius:      num(m, 0, "radius", "cornerRadius", "corner_radius", "borderRadius", "border_radius"),
+		Src:         str(m, "src", "url", "imageUrl", "image_url"),
+		Fit:         str(m, "objectFit", "object_fit", "fit"),
+		Text:        str(m, "text", "content"),
+		FontSize:    num(m, 16, "fontSize", "font_size"),
+		FontFamily:  str(m, "fontFamily", "font_family"),
+		FontWeight:  str(m, "fontWeight", "font_weight"),
+		Color:       str(m, "color", "textColor", "text_color"),
+		Align:       str(m, "textAlign", "text_align", "align"),
+		LineHeight:  num(m, 1.4, "lineHeight", "line_height"),
+		frameID:     str(m, "frameId", "frame_id", "parentId", "parent_id"),
+		zIndex:      num(m, 0, "zIndex", "z_index"),
+		order:       *order,
+	}
+	if el.Opacity < 0 || el.Opacity > 1 {
+		el.Opacity = 1
+	}
+
+	if el.Type == ElementLine {
+		// 线段支持 points: [x1, y1, x2, y2]（相对元素位置）或 x2/y2
+		if pts := list(m, "points"); len(pts) >= 4 {
+			x1, y1 := toFloat(pts[0]), toFloat(pts[1])
+			el.X2, el.Y2 = el.X+toFloat(pts[2]), el.Y+toFloat(pts[3])
+			el.X, el.Y = el.X+x1, el.Y+y1
+		} else {
+			el.X2 = num(m, el.X+el.Width, "x2")
+			el.Y2 = num(m, el.Y+el.Height, "y2")
+		}
+		if el.Stroke == "" {
+			el.Stroke = el.Fill
+		}
+		if el.StrokeWidth <= 0 {
+			el.StrokeWidth = 1
+		}
+		return el
+	}
+
+	if el.Type == ElementText && el.Color == "" {
+		el.Color = el.Fill
+		el.Fill = ""
+	}
+	if el.Width <= 0 || el.Height <= 0 {
+		if el.Type != ElementText {
+			return nil
+		}
+	}
+	return el
+}
+
+// containingFrame 元素中心点所在的画板
+func containingFrame(frames []*Frame, el *Element) *Frame {
+	cx, cy := el.X+el.Width/2, el.Y+el.Height/2
+	for _, frame := range frames {
+		if cx >= frame.x && cx < frame.x+frame.Width && cy >= frame.y && cy < frame.y+frame.Height {
+			return frame
+		}
+	}
+	return nil
+}
+
+func normalizeFrameSize(frame *Frame) error {
+	if frame.Width <= 0 {
+		frame.Width = defaultFrameWidth
+	}
+	if frame.Height <= 0 {
+		frame.Height = defaultFrameHeight
+	}
+	if frame.Width > maxFrameSide || frame.Height > maxFrameSide {
+		return fmt.Errorf("画板「%s」尺寸过大（%.0fx%.0f），单边不能超过 %d", frame.Name, frame.Width, frame.Height, maxFrameSide)
+	}
+	return nil
+}
+
+func unmarshalField(data *string, v interface{}) error {
+	if data == nil || *data == "" || *data == "null" {
+		return nil
+	}
+	return json.Unmarshal([]byte(*data), v)
+}
+
+// str 依次读取多个候选字段中第一个非空字符串
+func str(m map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		switch v := m[key].(type) {
+		case string:
+			if v != "" {
+				return v
+			}
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	}
+	return ""
+}
+
+// num 依次读取多个候选字段中第一个数值，都不存在时返回 def
+func num(m map[string]interface{}, def float64, keys ...string) float64 {
+	for _, key := range keys {
+		if v, ok := m[key]; ok && v != nil {
+			if f := toFloat(v); !math.IsNaN(f) {
+				return f
+			}
+		}
+	}
+	return def
+}
+
+func list(m map[string]interface{}, keys ...string) []interface{} {
+	for _, key := range keys {
+		if v, ok := m[key].([]interface{}); ok {
+			return v
+		}
+	}
+	return nil
+}
+
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(n), "px"), 64)
+		if err == nil {
+			return f
+		}
+	}
+	return math.NaN()
+}
@@ -0,0 +1,192 @@
+package exporter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"01agent_server/internal/config"
+	"01agent_server/internal/models/short_post"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/tools"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultMaxFrames = 50
+	defaultScale     = 1
+	maxScale         = 3
+)
+
+var (
+	// ErrProjectNotFound 工程不存在
+	ErrProjectNotFound = errors.New("工程不存在")
+	// ErrContentNotFound 工程还没有保存内容
+	ErrContentNotFound = errors.New("工程还没有保存内容")
+	// ErrUnsupportedFormat 服务端不支持的导出格式
+	ErrUnsupportedFormat = errors.New("服务端导出仅支持 image、pdf、zip 格式")
+	// ErrInvalidContent 工程内容无法解析或不满足导出要求
+	ErrInvalidContent = errors.New("工程内容无法导出")
+	// ErrJobNotFound 导出任务不存在
+	ErrJobNotFound = errors.New("导出任务不存在")
+)
+
+// JobOptions 导出参数，保存在任务与导出记录的 export_config 中
+type JobOptions struct {
+	FrameIDs []string `json:"frame_ids,omitempty"` // 导出的画板，为空时导出全部
+	Scale    float64  `json:"scale"`               // 输出像素倍率（1-3）
+}
+
+// ExportFile 导出结果中的一个文件，写入导出记录的 file_urls
+type ExportFile struct {
+	URL     string `json:"url"`
+	Name    string `json:"name"`
+	Size    int64  `json:"size"`
+	FrameID string `json:"frame_id,omitempty"`
+}
+
+// CreateJobParams 创建导出任务参数
+type CreateJobParams struct {
+	ProjectID  string
+	ExportName string
+	Format     short_post.ExportFormat
+	Options    JobOptions
+}
+
+// Service 短图文服务端导出服务
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService 创建导出服务
+func NewService() *Service {
+	return &Service{db: repository.DB}
+}
+
+// CreateJob 为工程的最新内容创建导出任务与导出记录，渲染完成后回填导出记录的文件地址
+func (s *Service) CreateJob(userID string, params CreateJobParams) (*short_post.ShortPostExportJob, error) {
+	switch params.Format {
+	case short_post.ExportFormatImage, short_post.ExportFormatPDF, short_post.ExportFormatZIP:
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	opts := params.Options
+	if opts.Scale <= 0 {
+		opts.Scale = defaultScale
+	}
+	opts.Scale = math.Min(opts.Scale, maxScale)
+
+	var project short_post.ShortPostProject
+	if err := s.db.Where("id = ? AND user_id = ?", params.ProjectID, userID).First(&project).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProjectNotFound
+		}
+		return nil, err
+	}
+	var content short_post.ShortPostProjectContent
+	if err := s.db.Where("project_id = ? AND is_latest = ?", project.ID, true).First(&content).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrContentNotFound
+		}
+		return nil, err
+	}
+
+	// 创建时先校验内容，格式错误或画板不存在时直接返回，不进入队列
+	frames, err := selectFrames(&content, opts.FrameIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	optionsJSON, _ := json.Marshal(opts)
+	exportedJSON, _ := json.Marshal(map[string]interface{}{
+		"project_id":      project.ID,
+		"content_id":      content.ID,
+		"content_version": content.Version,
+	})
+	name := params.ExportName
+	if name == "" {
+		name = project.Name
+	}
+
+	record := &short_post.ShortPostExportRecord{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		ExportName:   name,
+		ExportFormat: params.Format,
+		ExportConfig: tools.StringPtr(string(optionsJSON)),
+		ExportedData: tools.StringPtr(string(exportedJSON)),
+	}
+	job := &short_post.ShortPostExportJob{
+		ID:             uuid.New().String(),
+		UserID:         userID,
+		ProjectID:      project.ID,
+		ContentID:      content.ID,
+		ContentVersion: content.Version,
+		ExportRecordID: record.ID,
+		ExportFormat:   params.Format,
+		Options:        tools.StringPtr(string(optionsJSON)),
+		Status:         short_post.ExportJobStatusPending,
+		TotalFrames:    len(frames),
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(record).Error; err != nil {
+			return err
+		}
+		return tx.Create(job).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	GetWorker().Kick()
+	return job, nil
+}
+
+// GetJob 查询用户的导出任务
+func (s *Service) GetJob(userID, jobID string) (*short_post.ShortPostExportJob, error) {
+	var job short_post.ShortPostExportJob
+	err := s.db.Where("id = ? AND user_id = ?", jobID, userID).First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetJobByRecord 查询导出记录对应的服务端导出任务，前端直接上传的记录返回 nil
+func (s *Service) GetJobByRecord(recordID string) (*short_post.ShortPostExportJob, error) {
+	var job short_post.ShortPostExportJob
+	err := s.db.Where("export_record_id = ?", recordID).First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// selectFrames 解析内容并选出需要导出的画板
+func selectFrames(content *short_post.ShortPostProjectContent, frameIDs []string) ([]*Frame, error) {
+	scene, err := ParseScene(content)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidContent, err)
+	}
+	frames, err := scene.Select(frameIDs)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidContent, err)
+	}
+	limit := defaultMaxFrames
+	if config.AppConfig != nil && config.AppConfig.Export.MaxFrames > 0 {
+		limit = config.AppConfig.Export.MaxFrames
+	}
+	if len(frames) > limit {
+		return nil, fmt.Errorf("%w: 单次最多导出 %d 个画板", ErrInvalidContent, limit)
+	}
+	return frames, nil
+}
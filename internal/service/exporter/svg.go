@@ -0,0 +1,271 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultFontFamily 未指定字体时使用的字体，服务器需安装中文字体（如 Noto Sans CJK）
+const defaultFontFamily = `"PingFang SC", "Noto Sans CJK SC", "Source Han Sans SC", "WenQuanYi Micro Hei", "Microsoft YaHei", sans-serif`
+
+// buildSVG 将画板转换为 SVG，图片以 data URI 内嵌，渲染时不再访问网络
+func buildSVG(ctx context.Context, frame *Frame, assets *AssetLoader) ([]byte, []string) {
+	var warnings []string
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="%s" height="%s" viewBox="0 0 %s %s">`,
+		f(frame.Width), f(frame.Height), f(frame.Width), f(frame.Height))
+	buf.WriteString("\n")
+
+	bg := frameBackground(frame)
+	fmt.Fprintf(&buf, `<rect x="0" y="0" width="%s" height="%s"%s/>`+"\n", f(frame.Width), f(frame.Height), paintAttrs("fill", bg, true))
+	if frame.BgImage != "" {
+		bgImage := &Element{Type: ElementImage, Width: frame.Width, Height: frame.Height, Src: frame.BgImage, Fit: "cover", Opacity: 1}
+		if warning := writeSVGImage(ctx, &buf, bgImage, assets, "bg"); warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+
+	for i, el := range frame.Elements {
+		open := `<g`
+		if el.Opacity < 1 {
+			open += fmt.Sprintf(` opacity="%s"`, f(el.Opacity))
+		}
+		if el.Rotation != 0 && el.Type != ElementLine {
+			open += fmt.Sprintf(` transform="rotate(%s %s %s)"`, f(el.Rotation), f(el.X+el.Width/2), f(el.Y+el.Height/2))
+		}
+		buf.WriteString(open + ">\n")
+
+		switch el.Type {
+		case ElementRect:
+			fmt.Fprintf(&buf, `<rect x="%s" y="%s" width="%s" height="%s"`, f(el.X), f(el.Y), f(el.Width), f(el.Height))
+			if el.Radius > 0 {
+				fmt.Fprintf(&buf, ` rx="%s" ry="%s"`, f(el.Radius), f(el.Radius))
+			}
+			buf.WriteString(paintAttrs("fill", el.Fill, true) + strokeAttrs(el) + "/>\n")
+		case ElementEllipse:
+			fmt.Fprintf(&buf, `<ellipse cx="%s" cy="%s" rx="%s" ry="%s"`, f(el.X+el.Width/2), f(el.Y+el.Height/2), f(el.Width/2), f(el.Height/2))
+			buf.WriteString(paintAttrs("fill", el.Fill, true) + strokeAttrs(el) + "/>\n")
+		case ElementLine:
+			fmt.Fprintf(&buf, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke-linecap="round"`, f(el.X), f(el.Y), f(el.X2), f(el.Y2))
+			buf.WriteString(strokeAttrs(el) + "/>\n")
+		case ElementImage:
+			if warning := writeSVGImage(ctx, &buf, el, assets, strconv.Itoa(i)); warning != "" {
+				warnings = append(warnings, warning)
+			}
+		case ElementText:
+			writeSVGText(&buf, el)
+		}
+		buf.WriteString("</g>\n")
+	}
+	buf.WriteString("</svg>\n")
+	return buf.Bytes(), warnings
+}
+
+func writeSVGImage(ctx context.Context, buf *bytes.Buffer, el *Element, assets *AssetLoader, id string) string {
+	asset, err := assets.load(ctx, el.Src)
+	if err != nil {
+		return fmt.Sprintf("图片 %s 加载失败: %v", shortSrc(el.Src), err)
+	}
+
+	clip := ""
+	if el.Radius > 0 {
+		fmt.Fprintf(buf, `<clipPath id="clip-%s"><rect x="%s" y="%s" width="%s" height="%s" rx="%s" ry="%s"/></clipPath>`+"\n",
+			id, f(el.X), f(el.Y), f(el.Width), f(el.Height), f(el.Radius), f(el.Radius))
+		clip = fmt.Sprintf(` clip-path="url(#clip-%s)"`, id)
+	}
+	aspect := "none"
+	switch el.Fit {
+	case "contain":
+		aspect = "xMidYMid meet"
+	case "cover":
+		aspect = "xMidYMid slice"
+	}
+	fmt.Fprintf(buf, `<image x="%s" y="%s" width="%s" height="%s" preserveAspectRatio="%s"%s xlink:href="data:%s;base64,%s"/>`+"\n",
+		f(el.X), f(el.Y), f(el.Width), f(el.Height), aspect, clip, asset.mime, base64.StdEncoding.EncodeToString(asset.data))
+	if el.StrokeWidth > 0 && el.Stroke != "" {
+		fmt.Fprintf(buf, `<rect x="%s" y="%s" width="%s" height="%s" rx="%s" ry="%s" fill="none"%s/>`+"\n",
+			f(el.X), f(el.Y), f(el.Width), f(el.Height), f(el.Radius), f(el.Radius), strokeAttrs(el))
+	}
+	return ""
+}
+
+func writeSVGText(buf *bytes.Buffer, el *Element) {
+	if el.Fill != "" && el.Width > 0 && el.Height > 0 {
+		fmt.Fprintf(buf, `<rect x="%s" y="%s" width="%s" height="%s"%s/>`+"\n", f(el.X), f(el.Y), f(el.Width), f(el.Height), paintAttrs("fill", el.Fill, false))
+	}
+	lines := wrapText(el.Text, el.FontSize, el.Width)
+	if len(lines) == 0 {
+		return
+	}
+
+	anchor, x := "start", el.X
+	switch el.Align {
+	case "center":
+		anchor, x = "middle", el.X+el.Width/2
+	case "right", "end":
+		anchor, x = "end", el.X+el.Width
+	}
+	family := el.FontFamily
+	if family == "" {
+		family = defaultFontFamily
+	}
+	lineHeight := el.FontSize * el.LineHeight
+	textColor := el.Color
+	if _, ok := parseColor(textColor); !ok {
+		textColor = "#000000"
+	}
+
+	fmt.Fprintf(buf, `<text x="%s" y="%s" font-size="%s" font-family="%s" text-anchor="%s" xml:space="preserve"%s`,
+		f(x), f(el.Y+textBaseline(el.FontSize, lineHeight)), f(el.FontSize), escapeAttr(family), anchor, paintAttrs("fill", textColor, false))
+	if el.FontWeight != "" {
+		fmt.Fprintf(buf, ` font-weight="%s"`, escapeAttr(el.FontWeight))
+	}
+	buf.WriteString(">")
+	for i, line := range lines {
+		dy := "0"
+		if i > 0 {
+			dy = f(lineHeight)
+		}
+		fmt.Fprintf(buf, `<tspan x="%s" dy="%s">`, f(x), dy)
+		xml.EscapeText(buf, []byte(line))
+		buf.WriteString("</tspan>")
+	}
+	buf.WriteString("</text>\n")
+}
+
+// textBaseline 首行基线相对文字框顶部的偏移：半行距加字体上伸部分
+func textBaseline(fontSize, lineHeight float64) float64 {
+	return (lineHeight-fontSize)/2 + fontSize*0.88
+}
+
+// wrapText 按文字框宽度估算折行，中日韩文字按一个字号宽，其余字符按半个字号宽
+func wrapText(text string, fontSize, width float64) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		if width <= 0 {
+			lines = append(lines, paragraph)
+			continue
+		}
+		var line strings.Builder
+		lineWidth := 0.0
+		lastSpace := -1 // 行内最后一个空格的字节位置，英文优先在空格处折行
+		for _, r := range paragraph {
+			w := runeWidth(r, fontSize)
+			if lineWidth+w > width && line.Len() > 0 {
+				current := line.String()
+				if !isCJK(r) && !unicode.IsSpace(r) && lastSpace > 0 {
+					lines = append(lines, current[:lastSpace])
+					rest := current[lastSpace+1:]
+					line.Reset()
+					line.WriteString(rest)
+					lineWidth = 0
+					for _, rr := range rest {
+						lineWidth += runeWidth(rr, fontSize)
+					}
+				} else {
+					lines = append(lines, current)
+					line.Reset()
+					lineWidth = 0
+				}
+				lastSpace = -1
+				if unicode.IsSpace(r) {
+					continue
+				}
+			}
+			if unicode.IsSpace(r) {
+				lastSpace = line.Len()
+			}
+			line.WriteRune(r)
+			lineWidth += w
+		}
+		lines = append(lines, line.String())
+	}
+	return lines
+}
+
+func runeWidth(r rune, fontSize float64) float64 {
+	if isCJK(r) || r >= 0xFF00 && r <= 0xFFEF || utf8.RuneLen(r) >= 4 {
+		return fontSize
+	}
+	return fontSize * 0.55
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) ||
+		r >= 0x3000 && r <= 0x303F // 中文标点
+}
+
+// frameBackground 画板背景色，未设置时为白色
+func frameBackground(frame *Frame) string {
+	if _, ok := parseColor(frame.Background); ok {
+		return frame.Background
+	}
+	return "#ffffff"
+}
+
+// paintAttrs 颜色转换为 SVG 属性，透明度单独输出以兼容只支持 #rrggbb 的渲染器
+// none 为 true 时无法识别的颜色输出 none，否则不输出属性
+func paintAttrs(attr, value string, none bool) string {
+	c, ok := parseColor(value)
+	if !ok {
+		if none {
+			return fmt.Sprintf(` %s="none"`, attr)
+		}
+		return ""
+	}
+	s := fmt.Sprintf(` %s="%s"`, attr, hexColor(c))
+	if c.A < 255 {
+		s += fmt.Sprintf(` %s-opacity="%s"`, attr, f(float64(c.A)/255))
+	}
+	return s
+}
+
+func strokeAttrs(el *Element) string {
+	if el.StrokeWidth <= 0 {
+		return ""
+	}
+	if _, ok := parseColor(el.Stroke); !ok {
+		return ""
+	}
+	return paintAttrs("stroke", el.Stroke, false) + fmt.Sprintf(` stroke-width="%s"`, f(el.StrokeWidth))
+}
+
+func hexColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func escapeAttr(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// f 格式化坐标，最多保留两位小数
+func f(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// shortSrc 日志与警告中使用的图片地址，data URI 与过长的地址截断显示
+func shortSrc(src string) string {
+	if strings.HasPrefix(src, "data:") {
+		return "data URI"
+	}
+	if len(src) > 120 {
+		return src[:120] + "..."
+	}
+	return src
+}
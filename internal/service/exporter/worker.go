@@ -0,0 +1,393 @@
+package exporter
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"01agent_server/internal/config"
+	"01agent_server/internal/models/short_post"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/service/storage"
+	"01agent_server/internal/tools"
+
+	"gorm.io/gorm"
+)
+
+const (
+	exportPollInterval   = 10 * time.Second
+	exportLockDuration   = 5 * time.Minute // 每渲染完一个画板续期
+	exportJobTimeout     = 30 * time.Minute
+	exportMaxAttempts    = 3
+	exportRetryDelay     = 30 * time.Second
+	exportRenderProgress = 90 // 渲染阶段占总进度的比例，其余为打包与上传
+	defaultExportWorkers = 2
+	maxExportWarnings    = 50
+)
+
+// permanentError 重试也无法成功的错误（如内容格式错误），任务直接失败
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// Worker 导出任务工作池
+// 任务持久化在数据库中，以状态与尝试次数为条件更新抢占，多实例部署时每个任务只由一个实例渲染；
+// 进程退出时执行中的任务在锁过期后重新执行
+type Worker struct {
+	db       *gorm.DB
+	renderer Renderer
+	storage  storage.Storage
+	workers  int
+	kick     chan struct{}
+	running  sync.Once
+}
+
+var (
+	workerInstance *Worker
+	workerOnce     sync.Once
+)
+
+// GetWorker 获取导出工作池单例
+func GetWorker() *Worker {
+	workerOnce.Do(func() {
+		workers := defaultExportWorkers
+		if config.AppConfig != nil && config.AppConfig.Export.Workers > 0 {
+			workers = config.AppConfig.Export.Workers
+		}
+		workerInstance = &Worker{
+			db:      repository.DB,
+			workers: workers,
+			kick:    make(chan struct{}, workers),
+		}
+	})
+	return workerInstance
+}
+
+// Start 启动渲染协程，重复调用只启动一次
+func (w *Worker) Start() {
+	w.running.Do(func() {
+		w.renderer = NewRenderer()
+		w.storage = storage.Default()
+		for i := 0; i < w.workers; i++ {
+			go w.loop()
+		}
+		repository.Infof("Short post export worker started: workers=%d, renderer=%s", w.workers, w.renderer.Name())
+	})
+}
+
+// Kick 通知空闲的渲染协程立即领取任务
+func (w *Worker) Kick() {
+	select {
+	case w.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Worker) loop() {
+	ticker := time.NewTicker(exportPollInterval)
+	defer ticker.Stop()
+	for {
+		// 连续处理直到没有可领取的任务
+		for w.runNext() {
+		}
+		select {
+		case <-ticker.C:
+		case <-w.kick:
+		}
+	}
+}
+
+// runNext 领取并执行一个任务，没有可执行的任务时返回 false
+func (w *Worker) runNext() bool {
+	job, err := w.claim()
+	if err != nil {
+		repository.Errorf("Claim export job failed: %v", err)
+		return false
+	}
+	if job == nil {
+		return false
+	}
+	w.process(job)
+	return true
+}
+
+// claim 领取等待中或锁已过期的任务
+func (w *Worker) claim() (*short_post.ShortPostExportJob, error) {
+	now := time.Now()
+	var candidates []short_post.ShortPostExportJob
+	err := w.db.Where("status IN ? AND (locked_until IS NULL OR locked_until < ?)",
+		[]short_post.ExportJobStatus{short_post.ExportJobStatusPending, short_post.ExportJobStatusRunning}, now).
+		Order("created_at ASC").
+		Limit(w.workers + 1).
+		Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range candidates {
+		job := &candidates[i]
+		if job.Status == short_post.ExportJobStatusRunning && job.Attempts >= exportMaxAttempts {
+			// 多次执行中断（如渲染时进程崩溃）的任务不再重试
+			w.db.Model(&short_post.ShortPostExportJob{}).
+				Where("id = ? AND status = ? AND attempts = ?", job.ID, job.Status, job.Attempts).
+				Updates(map[string]interface{}{
+					"status":       short_post.ExportJobStatusFailed,
+					"error":        "渲染多次中断，请减少画板数量或图片尺寸后重试",
+					"locked_until": nil,
+					"finished_at":  now,
+				})
+			continue
+		}
+		updates := map[string]interface{}{
+			"status":       short_post.ExportJobStatusRunning,
+			"attempts":     job.Attempts + 1,
+			"locked_until": now.Add(exportLockDuration),
+		}
+		if job.StartedAt == nil {
+			updates["started_at"] = now
+		}
+		result := w.db.Model(&short_post.ShortPostExportJob{}).
+			Where("id = ? AND status = ? AND attempts = ? AND (locked_until IS NULL OR locked_until < ?)", job.ID, job.Status, job.Attempts, now).
+			Updates(updates)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			job.Attempts++
+			job.Status = short_post.ExportJobStatusRunning
+			return job, nil
+		}
+	}
+	return nil, nil
+}
+
+func (w *Worker) process(job *short_post.ShortPostExportJob) {
+	defer func() {
+		if r := recover(); r != nil {
+			repository.Errorf("Export job %s panic: %v", job.ID, r)
+			w.fail(job, permanent(fmt.Errorf("渲染异常: %v", r)))
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), exportJobTimeout)
+	defer cancel()
+
+	files, warnings, err := w.render(ctx, job)
+	if err != nil {
+		w.fail(job, err)
+		return
+	}
+	w.succeed(job, files, warnings)
+}
+
+// render 渲染画板并按导出格式打包上传
+func (w *Worker) render(ctx context.Context, job *short_post.ShortPostExportJob) ([]ExportFile, []string, error) {
+	var opts JobOptions
+	if job.Options != nil {
+		json.Unmarshal([]byte(*job.Options), &opts)
+	}
+	if opts.Scale <= 0 {
+		opts.Scale = defaultScale
+	}
+
+	var content short_post.ShortPostProjectContent
+	if err := w.db.Where("id = ?", job.ContentID).First(&content).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, permanent(errors.New("导出的工程内容已被删除"))
+		}
+		return nil, nil, err
+	}
+	frames, err := selectFrames(&content, opts.FrameIDs)
+	if err != nil {
+		return nil, nil, permanent(err)
+	}
+
+	assets := NewAssetLoader()
+	var warnings []string
+	pngs := make([][]byte, len(frames))
+	for i, frame := range frames {
+		data, frameWarnings, err := w.renderer.Render(ctx, frame, RenderOptions{Scale: opts.Scale, Assets: assets})
+		warnings = append(warnings, frameWarnings...)
+		if err != nil {
+			return nil, warnings, err
+		}
+		pngs[i] = data
+
+		w.db.Model(&short_post.ShortPostExportJob{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+			"rendered_frames": i + 1,
+			"total_frames":    len(frames),
+			"progress":        (i + 1) * exportRenderProgress / len(frames),
+			"locked_until":    time.Now().Add(exportLockDuration),
+		})
+	}
+
+	prefix := fmt.Sprintf("short-post/exports/%s/%s/", job.UserID, job.ID)
+	upload := func(key, name string, data []byte, contentType, frameID string) (ExportFile, error) {
+		url, err := w.storage.Put(ctx, prefix+key, data, contentType)
+		if err != nil {
+			return ExportFile{}, err
+		}
+		return ExportFile{URL: url, Name: name, Size: int64(len(data)), FrameID: frameID}, nil
+	}
+
+	var files []ExportFile
+	switch job.ExportFormat {
+	case short_post.ExportFormatImage:
+		for i, frame := range frames {
+			file, err := upload(fmt.Sprintf("frame-%02d.png", i+1), frameFileName(i, frame, ".png"), pngs[i], "image/png", frame.ID)
+			if err != nil {
+				return nil, warnings, err
+			}
+			files = append(files, file)
+		}
+	case short_post.ExportFormatPDF:
+		pages := make([]pdfPage, len(frames))
+		for i, frame := range frames {
+			pages[i] = pdfPage{PNG: pngs[i], Width: frame.Width, Height: frame.Height}
+		}
+		data, err := buildPDF(pages)
+		if err != nil {
+			return nil, warnings, permanent(err)
+		}
+		file, err := upload("export.pdf", "export.pdf", data, "application/pdf", "")
+		if err != nil {
+			return nil, warnings, err
+		}
+		files = append(files, file)
+	case short_post.ExportFormatZIP:
+		data, err := buildZip(frames, pngs)
+		if err != nil {
+			return nil, warnings, permanent(err)
+		}
+		file, err := upload("export.zip", "export.zip", data, "application/zip", "")
+		if err != nil {
+			return nil, warnings, err
+		}
+		files = append(files, file)
+	default:
+		return nil, warnings, permanent(ErrUnsupportedFormat)
+	}
+	return files, warnings, nil
+}
+
+// succeed 回填导出记录的文件地址与大小，并标记任务完成
+func (w *Worker) succeed(job *short_post.ShortPostExportJob, files []ExportFile, warnings []string) {
+	var total int64
+	for _, file := range files {
+		total += file.Size
+	}
+	filesJSON, _ := json.Marshal(files)
+
+	err := w.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&short_post.ShortPostExportRecord{}).Where("id = ?", job.ExportRecordID).Updates(map[string]interface{}{
+			"file_urls": string(filesJSON),
+			"file_size": total,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&short_post.ShortPostExportJob{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+			"status":       short_post.ExportJobStatusSucceeded,
+			"progress":     100,
+			"warnings":     warningsJSON(warnings),
+			"error":        nil,
+			"locked_until": nil,
+			"finished_at":  time.Now(),
+		}).Error
+	})
+	if err != nil {
+		repository.Errorf("Save export job %s result failed: %v", job.ID, err)
+		return
+	}
+	repository.Infof("Export job %s succeeded: format=%s, files=%d, size=%d, warnings=%d",
+		job.ID, job.ExportFormat, len(files), total, len(warnings))
+}
+
+// fail 可重试的错误在尝试次数内延迟重新排队，否则标记任务失败
+func (w *Worker) fail(job *short_post.ShortPostExportJob, err error) {
+	var perm *permanentError
+	updates := map[string]interface{}{
+		"error": tools.StringPtr(err.Error()),
+	}
+	if !errors.As(err, &perm) && job.Attempts < exportMaxAttempts {
+		delay := exportRetryDelay * time.Duration(job.Attempts)
+		updates["status"] = short_post.ExportJobStatusPending
+		updates["locked_until"] = time.Now().Add(delay)
+		updates["progress"] = 0
+		updates["rendered_frames"] = 0
+		repository.Warnf("Export job %s failed (attempt %d), retry in %v: %v", job.ID, job.Attempts, delay, err)
+	} else {
+		updates["status"] = short_post.ExportJobStatusFailed
+		updates["locked_until"] = nil
+		updates["finished_at"] = time.Now()
+		repository.Errorf("Export job %s failed after %d attempts: %v", job.ID, job.Attempts, err)
+	}
+	if dbErr := w.db.Model(&short_post.ShortPostExportJob{}).Where("id = ?", job.ID).Updates(updates).Error; dbErr != nil {
+		repository.Errorf("Save export job %s failure failed: %v", job.ID, dbErr)
+	}
+}
+
+// buildZip 将画板 PNG 打包为 zip，PNG 已压缩，直接存储
+func buildZip(frames []*Frame, pngs [][]byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	modified := time.Now()
+	for i, frame := range frames {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     frameFileName(i, frame, ".png"),
+			Method:   zip.Store,
+			Modified: modified,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fw.Write(pngs[i]); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// frameFileName 按序号与画板名称生成文件名，去掉文件名中不允许的字符
+func frameFileName(index int, frame *Frame, ext string) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(frame.Name))
+	if runes := []rune(name); len(runes) > 50 {
+		name = string(runes[:50])
+	}
+	if name == "" {
+		return fmt.Sprintf("%02d%s", index+1, ext)
+	}
+	return fmt.Sprintf("%02d-%s%s", index+1, name, ext)
+}
+
+func warningsJSON(warnings []string) interface{} {
+	if len(warnings) == 0 {
+		return nil
+	}
+	if len(warnings) > maxExportWarnings {
+		warnings = append(warnings[:maxExportWarnings:maxExportWarnings], fmt.Sprintf("另有 %d 条警告未显示", len(warnings)-maxExportWarnings))
+	}
+	data, _ := json.Marshal(warnings)
+	return string(data)
+}
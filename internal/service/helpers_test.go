@@ -0,0 +1,28 @@
+package service
+
+import (
+	"strconv"
+	"testing"
+
+	"01agent_server/internal/config"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// useTestConfig 替换全局配置，测试结束后恢复
+func useTestConfig(t *testing.T, cfg *config.Config) *config.Config {
+	t.Helper()
+	prev := config.AppConfig
+	config.AppConfig = cfg
+	t.Cleanup(func() { config.AppConfig = prev })
+	return cfg
+}
+
+// useTestRedis 启动内存 Redis 并让 tools.Redis 连接到它
+func useTestRedis(t *testing.T, cfg *config.Config) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	port, _ := strconv.Atoi(mr.Port())
+	cfg.Redis = config.RedisConfig{Host: mr.Host(), Port: port, PoolSize: 2}
+	return mr
+}
@@ -0,0 +1,400 @@
+package payment
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"01agent_server/internal/config"
+)
+
+const alipayDefaultGatewayURL = "https://openapi.alipay.com/gateway.do"
+
+var (
+	// ErrAlipayNotConfigured 支付宝未配置
+	ErrAlipayNotConfigured = errors.New("支付宝支付未配置")
+	// ErrAlipaySignature 支付宝签名验证失败
+	ErrAlipaySignature = errors.New("支付宝签名验证失败")
+)
+
+// AlipayAPIError 支付宝网关返回的业务错误
+type AlipayAPIError struct {
+	Method  string
+	Code    string
+	SubCode string
+	SubMsg  string
+}
+
+func (e *AlipayAPIError) Error() string {
+	return fmt.Sprintf("支付宝接口 %s 错误: %s %s %s", e.Method, e.Code, e.SubCode, e.SubMsg)
+}
+
+// AlipayClient 支付宝开放平台网关客户端（RSA2 签名）
+type AlipayClient struct {
+	cfg        config.AlipayConfig
+	gatewayURL string
+	privateKey *rsa.PrivateKey
+	publicKey  *rsa.PublicKey
+	httpClient *http.Client
+}
+
+var (
+	alipayClientInstance *AlipayClient
+	alipayClientErr      error
+	alipayClientOnce     sync.Once
+)
+
+// GetAlipayClient 获取支付宝客户端单例
+func GetAlipayClient() (*AlipayClient, error) {
+	alipayClientOnce.Do(func() {
+		if config.AppConfig == nil {
+			alipayClientErr = ErrAlipayNotConfigured
+			return
+		}
+		alipayClientInstance, alipayClientErr = NewAlipayClient(config.AppConfig.Alipay)
+	})
+	return alipayClientInstance, alipayClientErr
+}
+
+// NewAlipayClient 创建支付宝客户端，密钥支持PEM或裸base64格式
+func NewAlipayClient(cfg config.AlipayConfig) (*AlipayClient, error) {
+	if cfg.AppID == "" || cfg.PrivateKey == "" || cfg.PublicKey == "" {
+		return nil, ErrAlipayNotConfigured
+	}
+
+	privateKey, err := parseRSAPrivateKey([]byte(cfg.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("解析支付宝应用私钥失败: %w", err)
+	}
+	publicKey, err := parseRSAPublicKey([]byte(cfg.PublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("解析支付宝公钥失败: %w", err)
+	}
+
+	gatewayURL := cfg.GatewayURL
+	if gatewayURL == "" {
+		gatewayURL = alipayDefaultGatewayURL
+	}
+
+	return &AlipayClient{
+		cfg:        cfg,
+		gatewayURL: gatewayURL,
+		privateKey: privateKey,
+		publicKey:  publicKey,
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+	}, nil
+}
+
+// AppID 应用ID
+func (c *AlipayClient) AppID() string {
+	return c.cfg.AppID
+}
+
+// AlipayTradeBiz 下单业务参数
+type AlipayTradeBiz struct {
+	OutTradeNo     string `json:"out_trade_no"`
+	TotalAmount    string `json:"total_amount"`
+	Subject        string `json:"subject"`
+	ProductCode    string `json:"product_code,omitempty"`
+	TimeoutExpress string `json:"timeout_express,omitempty"`
+	QuitURL        string `json:"quit_url,omitempty"`
+}
+
+// AlipayTradeQueryResult 订单查询结果
+type AlipayTradeQueryResult struct {
+	Code        string `json:"code"`
+	Msg         string `json:"msg"`
+	SubCode     string `json:"sub_code"`
+	SubMsg      string `json:"sub_msg"`
+	TradeNo     string `json:"trade_no"`
+	OutTradeNo  string `json:"out_trade_no"`
+	TradeStatus string `json:"trade_status"`
+	TotalAmount string `json:"total_amount"`
+	SendPayDate string `json:"send_pay_date"`
+}
+
+// AlipayRefundResult 退款结果
+type AlipayRefundResult struct {
+	Code         string `json:"code"`
+	Msg          string `json:"msg"`
+	SubCode      string `json:"sub_code"`
+	SubMsg       string `json:"sub_msg"`
+	TradeNo      string `json:"trade_no"`
+	OutTradeNo   string `json:"out_trade_no"`
+	RefundFee    string `json:"refund_fee"`
+	FundChange   string `json:"fund_change"`
+	GmtRefundPay string `json:"gmt_refund_pay"`
+}
+
+// Precreate 当面付预下单（alipay.trade.precreate），返回二维码内容
+func (c *AlipayClient) Precreate(biz *AlipayTradeBiz) (string, error) {
+	var resp struct {
+		Code    string `json:"code"`
+		Msg     string `json:"msg"`
+		SubCode string `json:"sub_code"`
+		SubMsg  string `json:"sub_msg"`
+		QRCode  string `json:"qr_code"`
+	}
+	if err := c.execute("alipay.trade.precreate", biz, &resp); err != nil {
+		return "", err
+	}
+	if resp.Code != "10000" {
+		return "", &AlipayAPIError{Method: "alipay.trade.precreate", Code: resp.Code, SubCode: resp.SubCode, SubMsg: resp.SubMsg}
+	}
+	return resp.QRCode, nil
+}
+
+// WapPayURL 手机网站支付（alipay.trade.wap.pay），返回跳转链接
+func (c *AlipayClient) WapPayURL(biz *AlipayTradeBiz) (string, error) {
+	if biz.ProductCode == "" {
+		biz.ProductCode = "QUICK_WAP_WAY"
+	}
+	extra := url.Values{}
+	if c.cfg.ReturnURL != "" {
+		extra.Set("return_url", c.cfg.ReturnURL)
+	}
+	params, err := c.buildParams("alipay.trade.wap.pay", biz, extra)
+	if err != nil {
+		return "", err
+	}
+	return c.gatewayURL + "?" + params.Encode(), nil
+}
+
+// AppPayOrderString APP支付（alipay.trade.app.pay），返回客户端SDK使用的订单串
+func (c *AlipayClient) AppPayOrderString(biz *AlipayTradeBiz) (string, error) {
+	if biz.ProductCode == "" {
+		biz.ProductCode = "QUICK_MSECURITY_PAY"
+	}
+	params, err := c.buildParams("alipay.trade.app.pay", biz, nil)
+	if err != nil {
+		return "", err
+	}
+	return params.Encode(), nil
+}
+
+// Query 查询订单（alipay.trade.query）
+func (c *AlipayClient) Query(outTradeNo string) (*AlipayTradeQueryResult, error) {
+	var resp AlipayTradeQueryResult
+	if err := c.execute("alipay.trade.query", map[string]string{"out_trade_no": outTradeNo}, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != "10000" {
+		return nil, &AlipayAPIError{Method: "alipay.trade.query", Code: resp.Code, SubCode: resp.SubCode, SubMsg: resp.SubMsg}
+	}
+	return &resp, nil
+}
+
+// Close 关闭未支付的交易（alipay.trade.close）
+func (c *AlipayClient) Close(outTradeNo string) error {
+	var resp struct {
+		Code    string `json:"code"`
+		Msg     string `json:"msg"`
+		SubCode string `json:"sub_code"`
+		SubMsg  string `json:"sub_msg"`
+	}
+	if err := c.execute("alipay.trade.close", map[string]string{"out_trade_no": outTradeNo}, &resp); err != nil {
+		return err
+	}
+	if resp.Code != "10000" {
+		return &AlipayAPIError{Method: "alipay.trade.close", Code: resp.Code, SubCode: resp.SubCode, SubMsg: resp.SubMsg}
+	}
+	return nil
+}
+
+// Refund 退款（alipay.trade.refund），outRequestNo 用于部分退款的幂等标识
+func (c *AlipayClient) Refund(outTradeNo, outRequestNo string, amount float64, reason string) (*AlipayRefundResult, error) {
+	biz := map[string]string{
+		"out_trade_no":   outTradeNo,
+		"refund_amount":  fmt.Sprintf("%.2f", amount),
+		"out_request_no": outRequestNo,
+	}
+	if reason != "" {
+		biz["refund_reason"] = reason
+	}
+	var resp AlipayRefundResult
+	if err := c.execute("alipay.trade.refund", biz, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != "10000" {
+		return nil, &AlipayAPIError{Method: "alipay.trade.refund", Code: resp.Code, SubCode: resp.SubCode, SubMsg: resp.SubMsg}
+	}
+	return &resp, nil
+}
+
+// VerifyNotify 验证异步通知签名
+func (c *AlipayClient) VerifyNotify(form url.Values) error {
+	sign := form.Get("sign")
+	if sign == "" {
+		return ErrAlipaySignature
+	}
+	if appID := form.Get("app_id"); appID != c.cfg.AppID {
+		return fmt.Errorf("支付宝通知app_id不匹配: %s", appID)
+	}
+
+	return c.verify(alipaySignContent(form, "sign", "sign_type"), sign)
+}
+
+// execute 调用网关接口并验证应答签名
+func (c *AlipayClient) execute(method string, biz interface{}, result interface{}) error {
+	params, err := c.buildParams(method, biz, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.PostForm(c.gatewayURL, params)
+	if err != nil {
+		return fmt.Errorf("请求支付宝网关失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取支付宝应答失败: %w", err)
+	}
+
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("解析支付宝应答失败: %w", err)
+	}
+
+	responseKey := strings.ReplaceAll(method, ".", "_") + "_response"
+	content, ok := envelope[responseKey]
+	if !ok {
+		if errContent, exists := envelope["error_response"]; exists {
+			return fmt.Errorf("支付宝接口错误: %s", string(errContent))
+		}
+		return fmt.Errorf("支付宝应答缺少 %s", responseKey)
+	}
+
+	// 签名存在时一律校验；部分网关级错误不带签名，但成功应答必须带签名
+	var sign string
+	if raw, exists := envelope["sign"]; exists {
+		json.Unmarshal(raw, &sign)
+	}
+	if sign != "" {
+		if err := c.verify(string(content), sign); err != nil {
+			return err
+		}
+	} else {
+		var status struct {
+			Code string `json:"code"`
+		}
+		json.Unmarshal(content, &status)
+		if status.Code == "10000" {
+			return ErrAlipaySignature
+		}
+	}
+
+	if err := json.Unmarshal(content, result); err != nil {
+		return fmt.Errorf("解析支付宝业务应答失败: %w", err)
+	}
+	return nil
+}
+
+// buildParams 构建并签名公共请求参数，extra 为额外的公共参数（如 return_url）
+func (c *AlipayClient) buildParams(method string, biz interface{}, extra url.Values) (url.Values, error) {
+	bizContent, err := json.Marshal(biz)
+	if err != nil {
+		return nil, fmt.Errorf("序列化支付宝业务参数失败: %w", err)
+	}
+
+	params := url.Values{}
+	params.Set("app_id", c.cfg.AppID)
+	params.Set("method", method)
+	params.Set("format", "JSON")
+	params.Set("charset", "utf-8")
+	params.Set("sign_type", "RSA2")
+	params.Set("timestamp", time.Now().Format("2006-01-02 15:04:05"))
+	params.Set("version", "1.0")
+	params.Set("biz_content", string(bizContent))
+	if c.cfg.NotifyURL != "" {
+		params.Set("notify_url", c.cfg.NotifyURL)
+	}
+	for k := range extra {
+		params.Set(k, extra.Get(k))
+	}
+
+	if err := c.signParams(params); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// signParams 按参数名排序拼接后签名，写入 sign 参数
+func (c *AlipayClient) signParams(params url.Values) error {
+	hashed := sha256.Sum256([]byte(alipaySignContent(params, "sign")))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, c.privateKey, crypto.SHA256, hashed[:])
+	if err != nil {
+		return fmt.Errorf("支付宝签名失败: %w", err)
+	}
+	params.Set("sign", base64.StdEncoding.EncodeToString(signature))
+	return nil
+}
+
+// verify 使用支付宝公钥验证 RSA2 签名
+func (c *AlipayClient) verify(content, sign string) error {
+	sig, err := base64.StdEncoding.DecodeString(sign)
+	if err != nil {
+		return ErrAlipaySignature
+	}
+	hashed := sha256.Sum256([]byte(content))
+	if err := rsa.VerifyPKCS1v15(c.publicKey, crypto.SHA256, hashed[:], sig); err != nil {
+		return ErrAlipaySignature
+	}
+	return nil
+}
+
+// alipaySignContent 按参数名升序拼接待签名字符串，忽略空值与 excludes 中的参数
+func alipaySignContent(params url.Values, excludes ...string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		skip := params.Get(k) == ""
+		for _, e := range excludes {
+			if k == e {
+				skip = true
+			}
+		}
+		if !skip {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+params.Get(k))
+	}
+	return strings.Join(pairs, "&")
+}
+
+// parseRSAPublicKey 解析PEM或裸base64格式的RSA公钥
+func parseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("公钥格式错误")
+		}
+		block = &pem.Block{Bytes: der}
+	}
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		if rsaKey, ok := key.(*rsa.PublicKey); ok {
+			return rsaKey, nil
+		}
+		return nil, fmt.Errorf("公钥不是RSA类型")
+	}
+	return x509.ParsePKCS1PublicKey(block.Bytes)
+}
@@ -0,0 +1,366 @@
+package payment
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"01agent_server/internal/config"
+	"01agent_server/internal/models"
+)
+
+const testAlipayAppID = "2021000000000001"
+
+// fakeAlipay 模拟支付宝网关：校验应用签名，使用支付宝私钥为应答与异步通知签名
+type fakeAlipay struct {
+	t         *testing.T
+	server    *httptest.Server
+	appKey    *rsa.PrivateKey // 应用私钥，网关用其公钥验签请求
+	alipayKey *rsa.PrivateKey // 支付宝私钥，客户端用其公钥验签应答与通知
+	methods   []string
+}
+
+func newFakeAlipay(t *testing.T) (*fakeAlipay, *AlipayClient) {
+	t.Helper()
+	f := &fakeAlipay{t: t}
+
+	var err error
+	if f.appKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+	if f.alipayKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+	t.Cleanup(f.server.Close)
+
+	// 应用私钥使用PEM格式，支付宝公钥使用开放平台下载的裸base64格式
+	publicDER, err := x509.MarshalPKIXPublicKey(&f.alipayKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewAlipayClient(config.AlipayConfig{
+		AppID:      testAlipayAppID,
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(f.appKey)})),
+		PublicKey:  base64.StdEncoding.EncodeToString(publicDER),
+		NotifyURL:  "https://example.com/api/v1/payment/notify/alipay",
+		GatewayURL: f.server.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, client
+}
+
+func (f *fakeAlipay) handle(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	method := r.PostForm.Get("method")
+	f.methods = append(f.methods, method)
+	responseKey := strings.ReplaceAll(method, ".", "_") + "_response"
+
+	if !f.verifyAppSignature(r.PostForm) {
+		f.writeResponse(w, responseKey, `{"code":"40002","msg":"Invalid Arguments","sub_code":"isv.invalid-signature","sub_msg":"验签出错"}`, false)
+		return
+	}
+
+	var biz map[string]string
+	json.Unmarshal([]byte(r.PostForm.Get("biz_content")), &biz)
+	outTradeNo := biz["out_trade_no"]
+
+	switch {
+	case outTradeNo == "T-MISSING":
+		f.writeResponse(w, responseKey, `{"code":"40004","msg":"Business Failed","sub_code":"ACQ.TRADE_NOT_EXIST","sub_msg":"交易不存在"}`, true)
+	case outTradeNo == "T-UNSIGNED":
+		// 成功应答缺少签名
+		f.writeResponse(w, responseKey, `{"code":"10000","msg":"Success","out_trade_no":"T-UNSIGNED","trade_status":"TRADE_SUCCESS","total_amount":"99.00"}`, false)
+	case outTradeNo == "T-FORGED":
+		content := `{"code":"10000","msg":"Success","out_trade_no":"T-FORGED","trade_status":"TRADE_SUCCESS","total_amount":"99.00"}`
+		body := `{"` + responseKey + `":` + content + `,"sign":"` + base64.StdEncoding.EncodeToString([]byte("forged")) + `"}`
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	case method == "alipay.trade.precreate":
+		f.writeResponse(w, responseKey, `{"code":"10000","msg":"Success","out_trade_no":"`+outTradeNo+`","qr_code":"https://qr.alipay.com/bax00000"}`, true)
+	case method == "alipay.trade.query":
+		f.writeResponse(w, responseKey, `{"code":"10000","msg":"Success","trade_no":"2026101622001400000000000001","out_trade_no":"`+outTradeNo+
+			`","trade_status":"TRADE_SUCCESS","total_amount":"99.00","send_pay_date":"2026-10-16 10:00:00"}`, true)
+	case method == "alipay.trade.close":
+		f.writeResponse(w, responseKey, `{"code":"10000","msg":"Success","out_trade_no":"`+outTradeNo+`"}`, true)
+	default:
+		f.writeResponse(w, responseKey, `{"code":"40004","msg":"Business Failed","sub_code":"ACQ.SYSTEM_ERROR","sub_msg":"未知接口"}`, true)
+	}
+}
+
+// verifyAppSignature 使用应用公钥校验请求签名
+func (f *fakeAlipay) verifyAppSignature(form url.Values) bool {
+	sig, err := base64.StdEncoding.DecodeString(form.Get("sign"))
+	if err != nil {
+		return false
+	}
+	hashed := sha256.Sum256([]byte(alipaySignContent(form, "sign")))
+	return rsa.VerifyPKCS1v15(&f.appKey.PublicKey, crypto.SHA256, hashed[:], sig) == nil
+}
+
+func (f *fakeAlipay) sign(key *rsa.PrivateKey, content string) string {
+	hashed := sha256.Sum256([]byte(content))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+// writeResponse 输出网关应答，签名内容为应答节点的原始 JSON
+func (f *fakeAlipay) writeResponse(w http.ResponseWriter, responseKey, content string, signed bool) {
+	body := `{"` + responseKey + `":` + content
+	if signed {
+		body += `,"sign":"` + f.sign(f.alipayKey, content) + `"`
+	}
+	body += "}"
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(body))
+}
+
+// notification 构造使用 key 签名的异步通知参数，sign 与 sign_type 不参与签名
+func (f *fakeAlipay) notification(key *rsa.PrivateKey, tradeNo, tradeStatus, totalAmount string) url.Values {
+	form := url.Values{}
+	form.Set("notify_time", "2026-10-16 10:00:05")
+	form.Set("notify_type", "trade_status_sync")
+	form.Set("notify_id", "ac05099524730693a8b330c5ecf72da9786")
+	form.Set("app_id", testAlipayAppID)
+	form.Set("charset", "utf-8")
+	form.Set("version", "1.0")
+	form.Set("trade_no", "2026101622001400000000000001")
+	form.Set("out_trade_no", tradeNo)
+	form.Set("trade_status", tradeStatus)
+	form.Set("total_amount", totalAmount)
+	form.Set("gmt_payment", "2026-10-16 10:00:00")
+	form.Set("sign_type", "RSA2")
+	form.Set("sign", f.sign(key, alipaySignContent(form, "sign", "sign_type")))
+	return form
+}
+
+func newAlipayNotifyRequest(form url.Values) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/payment/notify/alipay", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestAlipaySignParams(t *testing.T) {
+	f, client := newFakeAlipay(t)
+
+	params, err := client.buildParams("alipay.trade.precreate", &AlipayTradeBiz{OutTradeNo: "T1", TotalAmount: "0.01", Subject: "会员"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Get("sign_type") != "RSA2" || params.Get("notify_url") == "" {
+		t.Fatalf("unexpected common params: %v", params)
+	}
+	if !f.verifyAppSignature(params) {
+		t.Fatal("request signature should verify with the app public key")
+	}
+	params.Set("biz_content", strings.Replace(params.Get("biz_content"), "0.01", "0.02", 1))
+	if f.verifyAppSignature(params) {
+		t.Fatal("tampered request should not verify")
+	}
+
+	// 空值参数不参与签名，参数按名称升序拼接
+	if got := alipaySignContent(url.Values{"b": {"2"}, "a": {"1"}, "c": {""}, "sign": {"x"}}, "sign"); got != "a=1&b=2" {
+		t.Fatalf("unexpected sign content %q", got)
+	}
+}
+
+func TestAlipayVerifyNotify(t *testing.T) {
+	f, client := newFakeAlipay(t)
+
+	form := f.notification(f.alipayKey, "T202610160011", "TRADE_SUCCESS", "99.00")
+	if err := client.VerifyNotify(form); err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := f.notification(f.alipayKey, "T202610160011", "TRADE_SUCCESS", "99.00")
+	tampered.Set("total_amount", "0.01")
+	if err := client.VerifyNotify(tampered); !errors.Is(err, ErrAlipaySignature) {
+		t.Fatalf("tampered notify: want ErrAlipaySignature, got %v", err)
+	}
+
+	unsigned := f.notification(f.alipayKey, "T202610160011", "TRADE_SUCCESS", "99.00")
+	unsigned.Del("sign")
+	if err := client.VerifyNotify(unsigned); !errors.Is(err, ErrAlipaySignature) {
+		t.Fatalf("unsigned notify: want ErrAlipaySignature, got %v", err)
+	}
+
+	// 攻击者用自己的私钥签名
+	forgerKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	if err := client.VerifyNotify(f.notification(forgerKey, "T202610160011", "TRADE_SUCCESS", "99.00")); !errors.Is(err, ErrAlipaySignature) {
+		t.Fatalf("forged notify: want ErrAlipaySignature, got %v", err)
+	}
+
+	// 其他应用的通知
+	otherApp := f.notification(f.alipayKey, "T202610160011", "TRADE_SUCCESS", "99.00")
+	otherApp.Set("app_id", "2021000000000002")
+	otherApp.Set("sign", f.sign(f.alipayKey, alipaySignContent(otherApp, "sign", "sign_type")))
+	if err := client.VerifyNotify(otherApp); err == nil {
+		t.Fatal("notify for another app: want error")
+	}
+}
+
+func TestAlipayProviderVerifyNotify(t *testing.T) {
+	f, client := newFakeAlipay(t)
+	provider := &alipayProvider{client: client}
+
+	result, err := provider.VerifyNotify(newAlipayNotifyRequest(f.notification(f.alipayKey, "T202610160012", "TRADE_SUCCESS", "99.00")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPaidAt, _ := time.ParseInLocation("2006-01-02 15:04:05", "2026-10-16 10:00:00", time.Local)
+	if result == nil || result.State != ProviderTradePaid || result.TradeNo != "T202610160012" ||
+		result.PaymentID != "2026101622001400000000000001" || result.PaidAmount != 99 || !result.PaidAt.Equal(wantPaidAt) {
+		t.Fatalf("unexpected trade result: %+v", result)
+	}
+
+	// 非支付成功的通知验签通过后忽略
+	result, err = provider.VerifyNotify(newAlipayNotifyRequest(f.notification(f.alipayKey, "T202610160012", "WAIT_BUYER_PAY", "99.00")))
+	if err != nil || result != nil {
+		t.Fatalf("non-paid notify should be ignored, got %+v %v", result, err)
+	}
+
+	forgerKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	if _, err := provider.VerifyNotify(newAlipayNotifyRequest(f.notification(forgerKey, "T202610160012", "TRADE_SUCCESS", "99.00"))); !errors.Is(err, ErrAlipaySignature) {
+		t.Fatalf("forged notify: want ErrAlipaySignature, got %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	provider.AckNotify(rec, nil)
+	if rec.Body.String() != "success" {
+		t.Fatalf("ack: want success, got %q", rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	provider.AckNotify(rec, ErrAlipaySignature)
+	if rec.Body.String() != "failure" {
+		t.Fatalf("ack error: want failure, got %q", rec.Body.String())
+	}
+}
+
+func TestAlipayClientVerifiesResponses(t *testing.T) {
+	f, client := newFakeAlipay(t)
+	provider := &alipayProvider{client: client}
+
+	qrCode, err := client.Precreate(&AlipayTradeBiz{OutTradeNo: "T202610160013", TotalAmount: "99.00", Subject: "会员"})
+	if err != nil || qrCode != "https://qr.alipay.com/bax00000" {
+		t.Fatalf("precreate: %q %v", qrCode, err)
+	}
+
+	result, err := provider.Query(&models.Trade{TradeNo: "T202610160013"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.State != ProviderTradePaid || result.PaidAmount != 99 {
+		t.Fatalf("unexpected query result: %+v", result)
+	}
+
+	// 成功应答必须带有效签名
+	if _, err := client.Query("T-UNSIGNED"); !errors.Is(err, ErrAlipaySignature) {
+		t.Fatalf("unsigned response: want ErrAlipaySignature, got %v", err)
+	}
+	if _, err := client.Query("T-FORGED"); !errors.Is(err, ErrAlipaySignature) {
+		t.Fatalf("forged response: want ErrAlipaySignature, got %v", err)
+	}
+
+	// 支付宝侧不存在的交易
+	if result, err := provider.Query(&models.Trade{TradeNo: "T-MISSING"}); err != nil || result.State != ProviderTradeNotFound {
+		t.Fatalf("missing trade: want ProviderTradeNotFound, got %+v %v", result, err)
+	}
+	if err := provider.Close(&models.Trade{TradeNo: "T-MISSING"}); err != nil {
+		t.Fatalf("closing a trade alipay never created should succeed: %v", err)
+	}
+	if err := provider.Close(&models.Trade{TradeNo: "T202610160013"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 应用私钥与网关登记的公钥不匹配时请求被拒绝
+	wrongKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	client.privateKey = wrongKey
+	var apiErr *AlipayAPIError
+	if _, err := client.Precreate(&AlipayTradeBiz{OutTradeNo: "T202610160014", TotalAmount: "99.00", Subject: "会员"}); !errors.As(err, &apiErr) || apiErr.SubCode != "isv.invalid-signature" {
+		t.Fatalf("wrong app key: want isv.invalid-signature, got %v", err)
+	}
+	if len(f.methods) != 8 {
+		t.Fatalf("want 8 gateway calls, got %v", f.methods)
+	}
+}
+
+func TestAlipayDuplicateNotifyFulfilsOnce(t *testing.T) {
+	f, client := newFakeAlipay(t)
+	db := newTestPaymentDB(t)
+	svc := &Service{db: db, machine: NewTradeStateMachine()}
+	provider := &alipayProvider{client: client}
+
+	trade := &models.Trade{
+		TradeNo:        "T202610160015",
+		UserID:         "u1",
+		Amount:         99,
+		TradeType:      string(models.TradeTypeRecharge),
+		PaymentChannel: string(models.PaymentChannelAlipayQR),
+		PaymentStatus:  string(models.PaymentStatusPending),
+		Title:          "会员",
+		CreatedAt:      time.Now(),
+	}
+	if err := db.Create(trade).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 支付宝在未收到 success 时会重复发送同一通知
+	form := f.notification(f.alipayKey, trade.TradeNo, "TRADE_SUCCESS", "99.00")
+	for i := 0; i < 2; i++ {
+		if err := svc.HandleNotify(provider, newAlipayNotifyRequest(form)); err != nil {
+			t.Fatalf("notify %d: %v", i, err)
+		}
+	}
+
+	var latest models.Trade
+	db.First(&latest, trade.ID)
+	if latest.PaymentStatus != string(models.PaymentStatusSuccess) || latest.Version != 1 ||
+		latest.PaymentID == nil || *latest.PaymentID != "2026101622001400000000000001" {
+		t.Fatalf("unexpected trade: status=%s version=%d payment_id=%v", latest.PaymentStatus, latest.Version, latest.PaymentID)
+	}
+	var events []models.PaymentEvent
+	db.Where("trade_id = ?", trade.ID).Find(&events)
+	if len(events) != 1 || events[0].EventType != EventTradePaid {
+		t.Fatalf("want exactly one trade.paid event, got %+v", events)
+	}
+
+	// 伪造的通知不会改变订单
+	other := *trade
+	other.ID, other.TradeNo, other.Version = 0, "T202610160016", 0
+	db.Create(&other)
+	forgerKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	if err := svc.HandleNotify(provider, newAlipayNotifyRequest(f.notification(forgerKey, other.TradeNo, "TRADE_SUCCESS", "99.00"))); !errors.Is(err, ErrAlipaySignature) {
+		t.Fatalf("want ErrAlipaySignature, got %v", err)
+	}
+
+	// 金额与订单不一致的通知被拒绝
+	if err := svc.HandleNotify(provider, newAlipayNotifyRequest(f.notification(f.alipayKey, other.TradeNo, "TRADE_SUCCESS", "0.01"))); !errors.Is(err, ErrTradeAmountMismatch) {
+		t.Fatalf("want ErrTradeAmountMismatch, got %v", err)
+	}
+	var rejected models.Trade
+	db.First(&rejected, other.ID)
+	if rejected.PaymentStatus != string(models.PaymentStatusPending) {
+		t.Fatalf("rejected notifies should leave the trade pending, got %s", rejected.PaymentStatus)
+	}
+}
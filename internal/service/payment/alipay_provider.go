@@ -0,0 +1,152 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"01agent_server/internal/models"
+)
+
+// alipayProvider 支付宝服务商
+// alipay_qr 使用当面付预下单；alipay_wap 使用手机网站支付；alipay 使用APP支付
+type alipayProvider struct {
+	client *AlipayClient
+}
+
+func (p *alipayProvider) Name() string {
+	return ProviderAlipay
+}
+
+func (p *alipayProvider) Supports(channel models.PaymentChannel) bool {
+	return ProviderNameOf(channel) == ProviderAlipay
+}
+
+func (p *alipayProvider) CreateOrder(trade *models.Trade, opts *OrderOptions) (*OrderResult, error) {
+	channel := models.PaymentChannel(trade.PaymentChannel)
+	if !p.Supports(channel) {
+		return nil, ErrUnsupportedPaymentChannel
+	}
+
+	biz := &AlipayTradeBiz{
+		OutTradeNo:     trade.TradeNo,
+		TotalAmount:    fmt.Sprintf("%.2f", trade.Amount),
+		Subject:        trade.Title,
+		TimeoutExpress: fmt.Sprintf("%dm", int(OrderExpire.Minutes())),
+	}
+	result := &OrderResult{
+		TradeNo: trade.TradeNo,
+		Amount:  trade.Amount,
+		Title:   trade.Title,
+	}
+
+	var err error
+	switch channel {
+	case models.PaymentChannelAlipayQR:
+		result.QRCode, err = p.client.Precreate(biz)
+	case models.PaymentChannelAlipayWap:
+		result.PayURL, err = p.client.WapPayURL(biz)
+	default:
+		result.OrderString, err = p.client.AppPayOrderString(biz)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (p *alipayProvider) Query(trade *models.Trade) (*TradeResult, error) {
+	resp, err := p.client.Query(trade.TradeNo)
+	if err != nil {
+		var apiErr *AlipayAPIError
+		if errors.As(err, &apiErr) && apiErr.SubCode == "ACQ.TRADE_NOT_EXIST" {
+			return &TradeResult{TradeNo: trade.TradeNo, State: ProviderTradeNotFound}, nil
+		}
+		return nil, err
+	}
+	return toAlipayTradeResult(resp.OutTradeNo, resp.TradeNo, resp.TradeStatus, resp.TotalAmount, resp.SendPayDate)
+}
+
+func (p *alipayProvider) Close(trade *models.Trade) error {
+	err := p.client.Close(trade.TradeNo)
+	var apiErr *AlipayAPIError
+	if errors.As(err, &apiErr) && apiErr.SubCode == "ACQ.TRADE_NOT_EXIST" {
+		// 用户未扫码时支付宝侧尚未创建交易
+		return nil
+	}
+	return err
+}
+
+func (p *alipayProvider) Refund(trade *models.Trade, req *RefundRequest) (*RefundResult, error) {
+	if _, err := p.client.Refund(trade.TradeNo, req.RefundNo, req.Amount, req.Reason); err != nil {
+		return nil, err
+	}
+	// 支付宝退款为同步接口，返回 10000 即表示退款成功（重复提交时 fund_change 为 N）
+	return &RefundResult{
+		RefundID: req.RefundNo,
+		Success:  true,
+	}, nil
+}
+
+// VerifyNotify 验签异步通知，非支付成功通知返回 nil
+func (p *alipayProvider) VerifyNotify(r *http.Request) (*TradeResult, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, fmt.Errorf("解析通知参数失败: %w", err)
+	}
+	form := r.PostForm
+	if err := p.client.VerifyNotify(form); err != nil {
+		return nil, err
+	}
+
+	result, err := toAlipayTradeResult(form.Get("out_trade_no"), form.Get("trade_no"), form.Get("trade_status"), form.Get("total_amount"), form.Get("gmt_payment"))
+	if err != nil {
+		return nil, err
+	}
+	if result.State != ProviderTradePaid {
+		return nil, nil
+	}
+	return result, nil
+}
+
+// AckNotify 处理成功返回纯文本 success，否则返回 failure 以触发重试
+func (p *alipayProvider) AckNotify(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if err != nil {
+		w.Write([]byte("failure"))
+		return
+	}
+	w.Write([]byte("success"))
+}
+
+func toAlipayTradeResult(outTradeNo, alipayTradeNo, tradeStatus, totalAmount, payTime string) (*TradeResult, error) {
+	result := &TradeResult{
+		TradeNo:   outTradeNo,
+		PaymentID: alipayTradeNo,
+	}
+	switch tradeStatus {
+	case "TRADE_SUCCESS", "TRADE_FINISHED":
+		result.State = ProviderTradePaid
+	case "TRADE_CLOSED":
+		result.State = ProviderTradeClosed
+		return result, nil
+	default: // WAIT_BUYER_PAY
+		result.State = ProviderTradeNotPaid
+		return result, nil
+	}
+
+	amount, err := strconv.ParseFloat(totalAmount, 64)
+	if err != nil {
+		return nil, fmt.Errorf("支付金额格式错误: %s", totalAmount)
+	}
+	result.PaidAmount = amount
+
+	if paidAt, err := time.ParseInLocation("2006-01-02 15:04:05", payTime, time.Local); err == nil {
+		result.PaidAt = paidAt
+	} else {
+		result.PaidAt = time.Now()
+	}
+	return result, nil
+}
@@ -0,0 +1,222 @@
+package payment
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"01agent_server/internal/models"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/tools"
+
+	"gorm.io/gorm"
+)
+
+// 支付事件类型
+const (
+	EventTradePaid     = "trade.paid"     // 订单支付成功，发放权益
+	EventTradeRefunded = "trade.refunded" // 订单退款已受理，回收权益
+)
+
+const (
+	outboxPollInterval = 5 * time.Second
+	outboxBatchSize    = 50
+	outboxMaxAttempts  = 10
+	outboxLockDuration = 5 * time.Minute
+	outboxRetryBase    = 10 * time.Second
+	outboxRetryMax     = time.Hour
+)
+
+// EventHandler 支付事件处理函数，返回错误时事件会按退避策略重试
+// 同一事件可能被投递多次，处理函数需保证幂等
+type EventHandler func(event *models.PaymentEvent) error
+
+// Outbox 支付事件发件箱投递器
+type Outbox struct {
+	db       *gorm.DB
+	handlers map[string]EventHandler
+	kick     chan struct{}
+	running  sync.Once
+}
+
+var (
+	outboxInstance *Outbox
+	outboxOnce     sync.Once
+)
+
+// GetOutbox 获取发件箱投递器单例
+func GetOutbox() *Outbox {
+	outboxOnce.Do(func() {
+		outboxInstance = &Outbox{
+			db:       repository.DB,
+			handlers: make(map[string]EventHandler),
+			kick:     make(chan struct{}, 1),
+		}
+		svc := NewService()
+		outboxInstance.handlers[EventTradePaid] = svc.handleTradePaid
+		outboxInstance.handlers[EventTradeRefunded] = svc.handleTradeRefunded
+	})
+	return outboxInstance
+}
+
+// RegisterHandler 注册事件处理函数，需在 Start 之前调用
+func (o *Outbox) RegisterHandler(eventType string, handler EventHandler) {
+	o.handlers[eventType] = handler
+}
+
+// Start 启动后台投递协程，重复调用只启动一次
+func (o *Outbox) Start() {
+	o.running.Do(func() {
+		go func() {
+			ticker := time.NewTicker(outboxPollInterval)
+			defer ticker.Stop()
+			for {
+				if _, err := o.DispatchPending(); err != nil {
+					repository.Errorf("Dispatch payment events failed: %v", err)
+				}
+				select {
+				case <-ticker.C:
+				case <-o.kick:
+				}
+			}
+		}()
+		repository.Infof("Payment event outbox started, polling every %v", outboxPollInterval)
+	})
+}
+
+// Kick 通知投递协程立即处理新事件
+func (o *Outbox) Kick() {
+	select {
+	case o.kick <- struct{}{}:
+	default:
+	}
+}
+
+// DispatchPending 投递到期的事件，返回成功处理的数量
+// 处理中但锁已过期的事件（进程崩溃遗留）会被重新投递
+func (o *Outbox) DispatchPending() (int, error) {
+	now := time.Now()
+	var events []models.PaymentEvent
+	err := o.db.Where("(status = ? AND next_run_at <= ?) OR (status = ? AND locked_until < ?)",
+		models.PaymentEventStatusPending, now, models.PaymentEventStatusProcessing, now).
+		Order("id ASC").
+		Limit(outboxBatchSize).
+		Find(&events).Error
+	if err != nil {
+		return 0, fmt.Errorf("查询待投递支付事件失败: %w", err)
+	}
+
+	done := 0
+	for i := range events {
+		if o.dispatch(&events[i]) {
+			done++
+		}
+	}
+	return done, nil
+}
+
+// DispatchTrade 立即投递指定订单的待处理事件
+func (o *Outbox) DispatchTrade(tradeID int) {
+	var events []models.PaymentEvent
+	if err := o.db.Where("trade_id = ? AND status = ?", tradeID, models.PaymentEventStatusPending).
+		Order("id ASC").Find(&events).Error; err != nil {
+		repository.Errorf("Query payment events for trade %d failed: %v", tradeID, err)
+		return
+	}
+	for i := range events {
+		o.dispatch(&events[i])
+	}
+}
+
+// DispatchTradeEvent 立即使用 handler 投递指定订单某一类型的待处理事件，用于需要拿到处理结果的同步调用
+// 事件已被其他实例抢占或已处理时不会调用 handler，返回是否处理成功
+func (o *Outbox) DispatchTradeEvent(tradeID int, eventType string, handler EventHandler) bool {
+	var events []models.PaymentEvent
+	if err := o.db.Where("trade_id = ? AND event_type = ? AND status = ?", tradeID, eventType, models.PaymentEventStatusPending).
+		Order("id ASC").Find(&events).Error; err != nil {
+		repository.Errorf("Query payment events for trade %d failed: %v", tradeID, err)
+		return false
+	}
+	ok := true
+	for i := range events {
+		ok = o.dispatchWith(&events[i], handler) && ok
+	}
+	return ok
+}
+
+// dispatch 抢占并使用已注册的处理函数处理单个事件，返回是否处理成功
+func (o *Outbox) dispatch(event *models.PaymentEvent) bool {
+	handler, ok := o.handlers[event.EventType]
+	if !ok {
+		handler = func(event *models.PaymentEvent) error {
+			return fmt.Errorf("未注册的支付事件类型: %s", event.EventType)
+		}
+	}
+	return o.dispatchWith(event, handler)
+}
+
+// dispatchWith 抢占并使用 handler 处理单个事件，返回是否处理成功
+func (o *Outbox) dispatchWith(event *models.PaymentEvent, handler EventHandler) bool {
+	now := time.Now()
+	lockedUntil := now.Add(outboxLockDuration)
+
+	// 以状态与投递次数为条件抢占事件，多实例下只有一个能成功
+	claim := o.db.Model(&models.PaymentEvent{}).
+		Where("id = ? AND status = ? AND attempts = ?", event.ID, event.Status, event.Attempts).
+		Updates(map[string]interface{}{
+			"status":       models.PaymentEventStatusProcessing,
+			"attempts":     event.Attempts + 1,
+			"locked_until": lockedUntil,
+		})
+	if claim.Error != nil {
+		repository.Errorf("Claim payment event %d failed: %v", event.ID, claim.Error)
+		return false
+	}
+	if claim.RowsAffected == 0 {
+		return false
+	}
+	event.Attempts++
+
+	err := o.safeHandle(handler, event)
+
+	if err == nil {
+		o.db.Model(&models.PaymentEvent{}).Where("id = ?", event.ID).Updates(map[string]interface{}{
+			"status":       models.PaymentEventStatusDone,
+			"processed_at": time.Now(),
+			"locked_until": nil,
+			"last_error":   nil,
+		})
+		return true
+	}
+
+	updates := map[string]interface{}{
+		"last_error":   tools.StringPtr(err.Error()),
+		"locked_until": nil,
+	}
+	if event.Attempts >= outboxMaxAttempts {
+		updates["status"] = models.PaymentEventStatusDead
+		repository.Errorf("Payment event %d (%s, trade %s) dead after %d attempts: %v",
+			event.ID, event.EventType, event.TradeNo, event.Attempts, err)
+	} else {
+		delay := outboxRetryBase * time.Duration(1<<(event.Attempts-1))
+		if delay > outboxRetryMax {
+			delay = outboxRetryMax
+		}
+		updates["status"] = models.PaymentEventStatusPending
+		updates["next_run_at"] = time.Now().Add(delay)
+		repository.Warnf("Payment event %d (%s, trade %s) failed (attempt %d), retry in %v: %v",
+			event.ID, event.EventType, event.TradeNo, event.Attempts, delay, err)
+	}
+	o.db.Model(&models.PaymentEvent{}).Where("id = ?", event.ID).Updates(updates)
+	return false
+}
+
+// safeHandle 执行处理函数并将 panic 转为错误，避免投递协程退出
+func (o *Outbox) safeHandle(handler EventHandler, event *models.PaymentEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("处理支付事件 panic: %v", r)
+		}
+	}()
+	return handler(event)
+}
@@ -0,0 +1,147 @@
+package payment
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"01agent_server/internal/models"
+)
+
+// 支付服务商名称
+const (
+	ProviderWxPay  = "wxpay"
+	ProviderAlipay = "alipay"
+)
+
+// OrderExpire 渠道订单有效期，超过后仍未支付的订单由对账任务关闭
+const OrderExpire = 2 * time.Hour
+
+var (
+	// ErrUnsupportedPaymentChannel 不支持的支付渠道
+	ErrUnsupportedPaymentChannel = errors.New("不支持的支付渠道")
+	// ErrOpenIDRequired JSAPI/小程序支付缺少openid
+	ErrOpenIDRequired = errors.New("缺少微信openid，无法发起JSAPI支付")
+)
+
+// ProviderTradeState 支付渠道侧的订单状态
+type ProviderTradeState string
+
+const (
+	ProviderTradeNotPaid  ProviderTradeState = "not_paid"  // 未支付（含支付中）
+	ProviderTradePaid     ProviderTradeState = "paid"      // 已支付
+	ProviderTradeClosed   ProviderTradeState = "closed"    // 已关闭/已撤销
+	ProviderTradeNotFound ProviderTradeState = "not_found" // 渠道侧不存在（用户未打开支付页）
+	ProviderTradeRefunded ProviderTradeState = "refunded"  // 已全额退款
+)
+
+// OrderOptions 下单附加参数
+type OrderOptions struct {
+	OpenID string // wx_pub / wx_lite 支付者openid
+}
+
+// OrderResult 下单结果，不同渠道只返回各自需要的字段
+type OrderResult struct {
+	TradeNo     string            `json:"trade_no"`
+	Amount      float64           `json:"amount"`
+	Title       string            `json:"title"`
+	CodeURL     string            `json:"code_url,omitempty"`     // wx_qr 二维码链接
+	JSAPIParams *WxPayJSAPIParams `json:"jsapi_params,omitempty"` // wx_pub / wx_lite 调起支付参数
+	QRCode      string            `json:"qr_code,omitempty"`      // alipay_qr 二维码内容
+	PayURL      string            `json:"pay_url,omitempty"`      // alipay_wap 跳转链接
+	OrderString string            `json:"order_string,omitempty"` // alipay APP支付订单串
+}
+
+// TradeResult 渠道查询或回调得到的订单结果
+type TradeResult struct {
+	TradeNo    string             // 商户订单号
+	PaymentID  string             // 渠道交易号
+	State      ProviderTradeState // 渠道侧状态
+	PaidAmount float64            // 实付金额（元）
+	PaidAt     time.Time          // 支付时间
+}
+
+// RefundRequest 退款请求
+type RefundRequest struct {
+	RefundNo string  // 商户退款单号，重复提交只会退款一次
+	Amount   float64 // 退款金额（元）
+	Reason   string
+}
+
+// RefundResult 退款结果
+type RefundResult struct {
+	RefundID string // 渠道退款单号
+	Success  bool   // 是否已退款成功；为 false 时表示渠道处理中
+}
+
+// Provider 支付服务商
+// 屏蔽各渠道的下单、查询、退款与回调验签差异，订单状态流转统一由 Service 处理
+type Provider interface {
+	// Name 服务商名称
+	Name() string
+	// Supports 是否支持该支付渠道
+	Supports(channel models.PaymentChannel) bool
+	// CreateOrder 在渠道侧为订单下单
+	CreateOrder(trade *models.Trade, opts *OrderOptions) (*OrderResult, error)
+	// Query 查询渠道侧订单状态
+	Query(trade *models.Trade) (*TradeResult, error)
+	// Close 关闭渠道侧未支付的订单，订单已关闭或渠道侧不存在时返回 nil
+	Close(trade *models.Trade) error
+	// Refund 申请退款
+	Refund(trade *models.Trade, req *RefundRequest) (*RefundResult, error)
+	// VerifyNotify 验证并解析异步通知
+	VerifyNotify(r *http.Request) (*TradeResult, error)
+	// AckNotify 按渠道要求应答异步通知，err 不为空时要求渠道重试
+	AckNotify(w http.ResponseWriter, err error)
+}
+
+// ProviderNameOf 返回支付渠道所属的服务商名称，不支持的渠道返回空字符串
+func ProviderNameOf(channel models.PaymentChannel) string {
+	switch channel {
+	case models.PaymentChannelWxQR, models.PaymentChannelWxPub, models.PaymentChannelWxLite:
+		return ProviderWxPay
+	case models.PaymentChannelAlipayQR, models.PaymentChannelAlipayWap, models.PaymentChannelAlipay:
+		return ProviderAlipay
+	default:
+		return ""
+	}
+}
+
+// GetProvider 按名称获取服务商，服务商未配置时返回对应的未配置错误
+func GetProvider(name string) (Provider, error) {
+	switch name {
+	case ProviderWxPay:
+		client, err := GetWxPayClient()
+		if err != nil {
+			return nil, err
+		}
+		return &wxPayProvider{client: client}, nil
+	case ProviderAlipay:
+		client, err := GetAlipayClient()
+		if err != nil {
+			return nil, err
+		}
+		return &alipayProvider{client: client}, nil
+	default:
+		return nil, ErrUnsupportedPaymentChannel
+	}
+}
+
+// GetProviderForChannel 获取支付渠道对应的服务商
+func GetProviderForChannel(channel models.PaymentChannel) (Provider, error) {
+	return GetProvider(ProviderNameOf(channel))
+}
+
+// ChannelsOf 返回服务商支持的全部支付渠道
+func ChannelsOf(name string) []string {
+	var channels []string
+	for _, channel := range []models.PaymentChannel{
+		models.PaymentChannelWxQR, models.PaymentChannelWxPub, models.PaymentChannelWxLite,
+		models.PaymentChannelAlipayQR, models.PaymentChannelAlipayWap, models.PaymentChannelAlipay,
+	} {
+		if ProviderNameOf(channel) == name {
+			channels = append(channels, string(channel))
+		}
+	}
+	return channels
+}
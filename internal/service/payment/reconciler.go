@@ -0,0 +1,229 @@
+package payment
+
+import (
+	"time"
+
+	"01agent_server/internal/models"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/tools"
+)
+
+const (
+	reconcileInterval  = 5 * time.Minute
+	reconcileMinAge    = 5 * time.Minute // 下单后先等待渠道回调，超过该时间仍未支付才主动查询
+	reconcileMaxAge    = 48 * time.Hour  // 超过该时间的订单不再自动对账
+	reconcileBatchSize = 100
+	reconcileLockKey   = "payment:reconcile:lock"
+	reconcileLockDB    = 0
+)
+
+// 对账处理结果
+const (
+	ReconcileActionPaid      = "paid"      // 渠道已支付，订单置为成功
+	ReconcileActionClosed    = "closed"    // 渠道未支付且已超时，渠道订单关闭后订单置为失败
+	ReconcileActionForced    = "forced"    // 管理员强制置为成功
+	ReconcileActionUnchanged = "unchanged" // 渠道仍未支付
+	ReconcileActionSkipped   = "skipped"   // 订单无需或无法对账
+	ReconcileActionError     = "error"     // 对账失败
+)
+
+// ReconcileResult 单个订单的对账结果
+type ReconcileResult struct {
+	TradeNo        string `json:"trade_no"`
+	UserID         string `json:"user_id"`
+	PaymentChannel string `json:"payment_channel"`
+	PreviousStatus string `json:"previous_status"`
+	Status         string `json:"status"`
+	Action         string `json:"action"`
+	Message        string `json:"message,omitempty"`
+}
+
+// StartReconciler 启动定时对账任务：定期向渠道查询长时间未支付的订单
+// 多实例部署时通过 Redis 锁保证同一时刻只有一个实例执行
+func (s *Service) StartReconciler() {
+	go func() {
+		ticker := time.NewTicker(reconcileInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if locked, err := tools.GetRedisInstance().SetNX(reconcileLockKey, "1", int(reconcileInterval.Seconds())-1, reconcileLockDB); err == nil && !locked {
+				continue
+			}
+			results, err := s.ReconcileStale()
+			if err != nil {
+				repository.Errorf("Reconcile stale trades failed: %v", err)
+				continue
+			}
+			changed := 0
+			for _, result := range results {
+				if result.Action == ReconcileActionPaid || result.Action == ReconcileActionClosed {
+					changed++
+				}
+			}
+			if changed > 0 {
+				repository.Infof("Reconciled %d stale trades, %d changed", len(results), changed)
+			}
+		}
+	}()
+}
+
+// ReconcileStale 对账已过回调等待期、仍为待支付的渠道订单
+func (s *Service) ReconcileStale() ([]*ReconcileResult, error) {
+	channels := append(ChannelsOf(ProviderWxPay), ChannelsOf(ProviderAlipay)...)
+	now := time.Now()
+
+	var trades []models.Trade
+	err := s.db.Where("payment_status = ? AND payment_channel IN ? AND created_at BETWEEN ? AND ?",
+		models.PaymentStatusPending, channels, now.Add(-reconcileMaxAge), now.Add(-reconcileMinAge)).
+		Order("id ASC").
+		Limit(reconcileBatchSize).
+		Find(&trades).Error
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*ReconcileResult, 0, len(trades))
+	for i := range trades {
+		results = append(results, s.ReconcileTrade(&trades[i], false))
+	}
+	return results, nil
+}
+
+// ReconcileTrades 按订单号对账，force 为 true 时渠道未确认支付的订单也强制置为成功
+func (s *Service) ReconcileTrades(tradeNos []string, force bool) []*ReconcileResult {
+	results := make([]*ReconcileResult, 0, len(tradeNos))
+	for _, tradeNo := range tradeNos {
+		var trade models.Trade
+		if err := s.db.Where("trade_no = ?", tradeNo).First(&trade).Error; err != nil {
+			results = append(results, &ReconcileResult{
+				TradeNo: tradeNo,
+				Action:  ReconcileActionError,
+				Message: ErrTradeNotFound.Error(),
+			})
+			continue
+		}
+		result := s.ReconcileTrade(&trade, force)
+		if result.Action == ReconcileActionPaid || result.Action == ReconcileActionForced {
+			// 管理员操作需要立即看到结果，直接投递履约事件
+			GetOutbox().DispatchTrade(trade.ID)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// RepairedTrade 修复未完成订单时实际发放了权益的订单
+type RepairedTrade struct {
+	Reconcile *ReconcileResult
+	*TradeFulfilment
+}
+
+// RepairTrades 按订单号对账并立即同步发放权益，返回对账结果与本次实际发放了权益的订单
+// force 含义同 ReconcileTrades
+func (s *Service) RepairTrades(tradeNos []string, force bool) ([]*ReconcileResult, []*RepairedTrade) {
+	results := make([]*ReconcileResult, 0, len(tradeNos))
+	repaired := make([]*RepairedTrade, 0)
+	for _, tradeNo := range tradeNos {
+		var trade models.Trade
+		if err := s.db.Where("trade_no = ?", tradeNo).First(&trade).Error; err != nil {
+			results = append(results, &ReconcileResult{
+				TradeNo: tradeNo,
+				Action:  ReconcileActionError,
+				Message: ErrTradeNotFound.Error(),
+			})
+			continue
+		}
+		result := s.ReconcileTrade(&trade, force)
+		results = append(results, result)
+		if result.Action != ReconcileActionPaid && result.Action != ReconcileActionForced {
+			continue
+		}
+
+		var fulfilment *TradeFulfilment
+		GetOutbox().DispatchTradeEvent(trade.ID, EventTradePaid, func(event *models.PaymentEvent) error {
+			var err error
+			fulfilment, err = s.fulfilTradePaid(event)
+			return err
+		})
+		if fulfilment != nil {
+			repaired = append(repaired, &RepairedTrade{Reconcile: result, TradeFulfilment: fulfilment})
+		}
+	}
+	return results, repaired
+}
+
+// ReconcileTrade 向渠道查询单个订单并按结果推进订单状态
+func (s *Service) ReconcileTrade(trade *models.Trade, force bool) *ReconcileResult {
+	result := &ReconcileResult{
+		TradeNo:        trade.TradeNo,
+		UserID:         trade.UserID,
+		PaymentChannel: trade.PaymentChannel,
+		PreviousStatus: trade.PaymentStatus,
+		Status:         trade.PaymentStatus,
+	}
+
+	status := models.PaymentStatus(trade.PaymentStatus)
+	if status != models.PaymentStatusPending && status != models.PaymentStatusFailed {
+		result.Action = ReconcileActionSkipped
+		result.Message = "订单已支付"
+		return result
+	}
+
+	var tradeResult *TradeResult
+	provider, err := GetProviderForChannel(models.PaymentChannel(trade.PaymentChannel))
+	if err == nil {
+		tradeResult, err = provider.Query(trade)
+	}
+	if err != nil && !force {
+		result.Action = ReconcileActionError
+		if err == ErrUnsupportedPaymentChannel {
+			result.Action = ReconcileActionSkipped
+		}
+		result.Message = err.Error()
+		return result
+	}
+
+	switch {
+	case tradeResult != nil && tradeResult.State == ProviderTradePaid:
+		latest, _, err := s.MarkPaid(trade.TradeNo, tradeResult.PaymentID, &tradeResult.PaidAmount, tradeResult.PaidAt)
+		if err != nil {
+			result.Action = ReconcileActionError
+			result.Message = err.Error()
+			return result
+		}
+		result.Action = ReconcileActionPaid
+		result.Status = latest.PaymentStatus
+
+	case force:
+		latest, _, err := s.MarkPaid(trade.TradeNo, "", nil, time.Now())
+		if err != nil {
+			result.Action = ReconcileActionError
+			result.Message = err.Error()
+			return result
+		}
+		repository.Warnf("Trade %s force marked as paid by admin", trade.TradeNo)
+		result.Action = ReconcileActionForced
+		result.Status = latest.PaymentStatus
+
+	case status == models.PaymentStatusPending && time.Since(trade.CreatedAt) > OrderExpire:
+		// 超时未支付的订单先在渠道侧关闭，关闭成功或渠道侧已关闭后才置为失败，避免用户仍能完成支付
+		// 若之后仍收到支付成功通知，状态机允许 failed -> success
+		if tradeResult.State != ProviderTradeClosed {
+			if err := provider.Close(trade); err != nil {
+				result.Action = ReconcileActionError
+				result.Message = "关闭渠道订单失败: " + err.Error()
+				return result
+			}
+		}
+		if err := s.machine.Transition(s.db, trade, models.PaymentStatusFailed, nil); err != nil {
+			result.Action = ReconcileActionError
+			result.Message = err.Error()
+			return result
+		}
+		result.Action = ReconcileActionClosed
+		result.Status = trade.PaymentStatus
+
+	default:
+		result.Action = ReconcileActionUnchanged
+	}
+	return result
+}
@@ -0,0 +1,667 @@
+package payment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"01agent_server/internal/config"
+	"01agent_server/internal/models"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/service"
+	"01agent_server/internal/tools"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrRefundNotFound 退款记录不存在
+	ErrRefundNotFound = errors.New("退款记录不存在")
+	// ErrRefundNotAllowed 订单当前状态不允许退款
+	ErrRefundNotAllowed = errors.New("订单当前状态不允许退款")
+	// ErrRefundAmountInvalid 退款金额无效
+	ErrRefundAmountInvalid = errors.New("退款金额必须大于0且不超过可退金额")
+	// ErrRefundInProgress 订单存在处理中的退款
+	ErrRefundInProgress = errors.New("订单存在处理中的退款，请稍后再试")
+	// ErrOfflineRefundRequired 渠道不支持原路退款
+	ErrOfflineRefundRequired = errors.New("该支付渠道不支持原路退款，请线下退款后以 offline 方式登记")
+)
+
+// RefundTradeRequest 退款请求
+type RefundTradeRequest struct {
+	TradeID    int
+	Amount     float64 // 退款金额，0 表示退还剩余全部金额
+	Reason     string
+	Offline    bool   // 线下已退款，仅登记并回收权益，不调用支付渠道
+	OperatorID string // 操作管理员
+}
+
+// RefundReversal 退款的权益回收明细，保存在退款记录中作为审计依据
+type RefundReversal struct {
+	Ratio                    float64              `json:"ratio"`                      // 本次退款占订单金额的比例
+	MembershipRevoked        bool                 `json:"membership_revoked"`         // 是否撤销了该订单开通的会员
+	OldVipLevel              int                  `json:"old_vip_level"`              // 回收前VIP等级
+	NewVipLevel              int                  `json:"new_vip_level"`              // 回收后VIP等级
+	OldStorageQuota          int64                `json:"old_storage_quota"`          // 回收前存储配额
+	NewStorageQuota          int64                `json:"new_storage_quota"`          // 回收后存储配额
+	MonthlyCreditsClawed     int                  `json:"monthly_credits_clawed"`     // 扣回的每月权益积分
+	TimedCreditsClawed       int                  `json:"timed_credits_clawed"`       // 扣回的有期限积分
+	PermanentCreditsClawed   int                  `json:"permanent_credits_clawed"`   // 扣回的永久积分
+	UncollectedCredits       int                  `json:"uncollected_credits"`        // 已被消费、无法扣回的积分
+	TotalConsumptionDeducted float64              `json:"total_consumption_deducted"` // 扣减的累计消费金额
+	Commissions              []CommissionReversal `json:"commissions"`                // 佣金处理明细
+}
+
+// CommissionReversal 佣金冲正明细
+type CommissionReversal struct {
+	CommissionID int     `json:"commission_id"`
+	InviterID    string  `json:"inviter_id"`
+	Action       string  `json:"action"` // rejected 驳回 / reduced 调减 / offset 已发放的佣金生成冲正记录
+	Amount       float64 `json:"amount"` // 冲减的佣金金额
+}
+
+// RefundTrade 发起退款
+//
+// 流程分为三步，每一步失败都有明确的补偿方式：
+//  1. 创建退款单并以乐观锁占用订单，失败时没有任何变更；
+//  2. 调用支付渠道退款，渠道拒绝时退款单置为失败，订单与权益保持不变；
+//     渠道结果未知（网络错误）时退款单保持 pending，可通过 RetryRefund 使用同一退款单号重试；
+//  3. 渠道受理后在一个事务中更新退款单、订单退款金额/状态并写入权益回收事件，
+//     权益回收（积分扣回、会员降级、佣金冲正）由发件箱在单个事务中执行，失败时自动重试。
+func (s *Service) RefundTrade(req *RefundTradeRequest) (*models.TradeRefund, error) {
+	refund, trade, err := s.createRefund(req)
+	if err != nil {
+		return nil, err
+	}
+	return s.submitRefund(refund, trade)
+}
+
+// RetryRefund 重试停留在 pending 的退款（渠道结果未知或本地记录失败时使用）
+// 渠道按退款单号幂等，重复提交不会重复退款
+func (s *Service) RetryRefund(refundID int) (*models.TradeRefund, error) {
+	refund, err := s.GetRefund(refundID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch models.TradeRefundStatus(refund.Status) {
+	case models.TradeRefundStatusPending:
+	case models.TradeRefundStatusProcessing, models.TradeRefundStatusSuccess:
+		// 渠道已受理，只需补投权益回收事件
+		if refund.ReversedAt == nil {
+			GetOutbox().DispatchTrade(refund.TradeID)
+		}
+		return s.GetRefund(refundID)
+	default:
+		return nil, ErrRefundNotAllowed
+	}
+
+	var trade models.Trade
+	if err := s.db.Where("id = ?", refund.TradeID).First(&trade).Error; err != nil {
+		return nil, ErrTradeNotFound
+	}
+	return s.submitRefund(refund, &trade)
+}
+
+// GetRefund 获取退款记录
+func (s *Service) GetRefund(refundID int) (*models.TradeRefund, error) {
+	var refund models.TradeRefund
+	if err := s.db.Where("id = ?", refundID).First(&refund).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrRefundNotFound
+		}
+		return nil, fmt.Errorf("查询退款记录失败: %w", err)
+	}
+	return &refund, nil
+}
+
+// ListTradeRefunds 获取订单的全部退款记录
+func (s *Service) ListTradeRefunds(tradeID int) ([]models.TradeRefund, error) {
+	var refunds []models.TradeRefund
+	if err := s.db.Where("trade_id = ?", tradeID).Order("id ASC").Find(&refunds).Error; err != nil {
+		return nil, fmt.Errorf("查询退款记录失败: %w", err)
+	}
+	return refunds, nil
+}
+
+// createRefund 校验可退金额并创建退款单
+func (s *Service) createRefund(req *RefundTradeRequest) (*models.TradeRefund, *models.Trade, error) {
+	var trade models.Trade
+	var refund *models.TradeRefund
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", req.TradeID).First(&trade).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrTradeNotFound
+			}
+			return fmt.Errorf("查询订单失败: %w", err)
+		}
+		if models.PaymentStatus(trade.PaymentStatus) != models.PaymentStatusSuccess {
+			return ErrRefundNotAllowed
+		}
+
+		offline := req.Offline
+		if !offline {
+			if _, err := GetProviderForChannel(models.PaymentChannel(trade.PaymentChannel)); err != nil {
+				if err == ErrUnsupportedPaymentChannel {
+					return ErrOfflineRefundRequired
+				}
+				return err
+			}
+		}
+
+		var inflight int64
+		if err := tx.Model(&models.TradeRefund{}).
+			Where("trade_id = ? AND status IN ?", trade.ID, []string{
+				string(models.TradeRefundStatusPending), string(models.TradeRefundStatusProcessing),
+			}).Count(&inflight).Error; err != nil {
+			return fmt.Errorf("查询退款记录失败: %w", err)
+		}
+		if inflight > 0 {
+			return ErrRefundInProgress
+		}
+
+		refundable := roundAmount(trade.Amount - trade.RefundedAmount)
+		amount := roundAmount(req.Amount)
+		if amount == 0 {
+			amount = refundable
+		}
+		if amount <= 0 || amount > refundable {
+			return ErrRefundAmountInvalid
+		}
+
+		// 递增订单版本号占用订单，并发发起的退款只有一个能成功
+		if err := s.machine.Update(tx, &trade, nil); err != nil {
+			return err
+		}
+
+		refund = &models.TradeRefund{
+			RefundNo:     "R" + GenerateTradeNo(),
+			TradeID:      trade.ID,
+			TradeNo:      trade.TradeNo,
+			UserID:       trade.UserID,
+			Amount:       amount,
+			IsFullRefund: amount >= refundable,
+			Offline:      offline,
+			Reason:       req.Reason,
+			Status:       string(models.TradeRefundStatusPending),
+			OperatorID:   req.OperatorID,
+		}
+		if err := tx.Create(refund).Error; err != nil {
+			return fmt.Errorf("创建退款记录失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	repository.Infof("Refund %s created for trade %s: amount=%.2f, offline=%v, operator=%s",
+		refund.RefundNo, trade.TradeNo, refund.Amount, refund.Offline, refund.OperatorID)
+	return refund, &trade, nil
+}
+
+// submitRefund 向渠道申请退款并在受理后登记
+func (s *Service) submitRefund(refund *models.TradeRefund, trade *models.Trade) (*models.TradeRefund, error) {
+	result := &RefundResult{Success: true}
+	if !refund.Offline {
+		provider, err := GetProviderForChannel(models.PaymentChannel(trade.PaymentChannel))
+		if err == nil {
+			result, err = provider.Refund(trade, &RefundRequest{
+				RefundNo: refund.RefundNo,
+				Amount:   refund.Amount,
+				Reason:   refund.Reason,
+			})
+		}
+		if err != nil {
+			s.markRefundError(refund, err)
+			return refund, err
+		}
+	}
+
+	if err := s.acceptRefund(refund, result); err != nil {
+		// 渠道已受理但本地登记失败：退款单保持 pending，RetryRefund 会用同一退款单号重新登记
+		repository.Errorf("Refund %s accepted by provider but record failed: %v", refund.RefundNo, err)
+		s.db.Model(&models.TradeRefund{}).Where("id = ?", refund.ID).
+			Update("error_message", tools.StringPtr("渠道已受理，本地登记失败: "+err.Error()))
+		return refund, err
+	}
+
+	// 立即回收权益，失败时由发件箱按退避策略重试
+	GetOutbox().DispatchTrade(trade.ID)
+	return s.GetRefund(refund.ID)
+}
+
+// markRefundError 渠道退款失败：明确拒绝的退款单置为失败，结果未知时保持 pending 以便重试
+func (s *Service) markRefundError(refund *models.TradeRefund, err error) {
+	updates := map[string]interface{}{
+		"error_message": tools.StringPtr(err.Error()),
+	}
+
+	// 渠道明确返回业务错误（非 5XX）时退款未发生，可以安全地置为失败
+	var wxErr *WxPayAPIError
+	var alipayErr *AlipayAPIError
+	rejected := (errors.As(err, &wxErr) && wxErr.StatusCode < 500) || errors.As(err, &alipayErr)
+	if rejected || err == ErrWxPayNotConfigured || err == ErrAlipayNotConfigured {
+		updates["status"] = string(models.TradeRefundStatusFailed)
+		refund.Status = string(models.TradeRefundStatusFailed)
+	}
+	refund.ErrorMessage = tools.StringPtr(err.Error())
+
+	if dbErr := s.db.Model(&models.TradeRefund{}).Where("id = ?", refund.ID).Updates(updates).Error; dbErr != nil {
+		repository.Errorf("Update refund %s failed: %v", refund.RefundNo, dbErr)
+	}
+	repository.Warnf("Refund %s for trade %s failed: %v", refund.RefundNo, refund.TradeNo, err)
+}
+
+// acceptRefund 渠道受理后，在一个事务中更新退款单与订单，并写入权益回收事件
+func (s *Service) acceptRefund(refund *models.TradeRefund, result *RefundResult) error {
+	for attempt := 0; attempt < markPaidMaxRetries; attempt++ {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			var trade models.Trade
+			if err := tx.Where("id = ?", refund.TradeID).First(&trade).Error; err != nil {
+				return fmt.Errorf("查询订单失败: %w", err)
+			}
+
+			refunded := roundAmount(trade.RefundedAmount + refund.Amount)
+			fields := map[string]interface{}{"refunded_amount": refunded}
+			if refunded >= roundAmount(trade.Amount) {
+				if err := s.machine.Transition(tx, &trade, models.PaymentStatusRefunded, fields); err != nil {
+					return err
+				}
+			} else if err := s.machine.Update(tx, &trade, fields); err != nil {
+				return err
+			}
+
+			now := time.Now()
+			updates := map[string]interface{}{
+				"status":        string(models.TradeRefundStatusProcessing),
+				"error_message": nil,
+			}
+			if result.Success {
+				updates["status"] = string(models.TradeRefundStatusSuccess)
+				updates["refunded_at"] = now
+			}
+			if result.RefundID != "" {
+				updates["provider_refund_id"] = result.RefundID
+			}
+			res := tx.Model(&models.TradeRefund{}).
+				Where("id = ? AND status = ?", refund.ID, models.TradeRefundStatusPending).
+				Updates(updates)
+			if res.Error != nil {
+				return fmt.Errorf("更新退款记录失败: %w", res.Error)
+			}
+			if res.RowsAffected == 0 {
+				return ErrRefundNotAllowed
+			}
+
+			return enqueueEvent(tx, &trade, EventTradeRefunded, EventTradeRefunded+":"+refund.RefundNo, map[string]interface{}{
+				"refund_id": refund.ID,
+			})
+		})
+		if err == ErrTradeConcurrentUpdate {
+			continue
+		}
+		if err == nil {
+			repository.Infof("Refund %s accepted for trade %s", refund.RefundNo, refund.TradeNo)
+		}
+		return err
+	}
+	return ErrTradeConcurrentUpdate
+}
+
+// handleTradeRefunded 处理退款事件：在一个事务中扣回积分、降级会员、冲正佣金并记录回收明细
+func (s *Service) handleTradeRefunded(event *models.PaymentEvent) error {
+	var payload struct {
+		RefundID int `json:"refund_id"`
+	}
+	if event.Payload != nil {
+		json.Unmarshal([]byte(*event.Payload), &payload)
+	}
+
+	// 支付成功事件尚未处理完时先等待，避免权益在回收之后才发放
+	var paidPending int64
+	s.db.Model(&models.PaymentEvent{}).
+		Where("dedup_key = ? AND status IN ?", fmt.Sprintf("%s:%d", EventTradePaid, event.TradeID), []string{
+			string(models.PaymentEventStatusPending), string(models.PaymentEventStatusProcessing),
+		}).Count(&paidPending)
+	if paidPending > 0 {
+		return fmt.Errorf("订单 %s 权益尚未发放完成，稍后重试", event.TradeNo)
+	}
+
+	var userID string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var refund models.TradeRefund
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", payload.RefundID).First(&refund).Error; err != nil {
+			return fmt.Errorf("查询退款记录失败: %w", err)
+		}
+		if refund.ReversedAt != nil {
+			return nil
+		}
+
+		var trade models.Trade
+		if err := tx.Where("id = ?", refund.TradeID).First(&trade).Error; err != nil {
+			return fmt.Errorf("查询订单失败: %w", err)
+		}
+
+		reversal, err := s.reverseBenefits(tx, &trade, &refund)
+		if err != nil {
+			return err
+		}
+
+		data, _ := json.Marshal(reversal)
+		if err := tx.Model(&models.TradeRefund{}).Where("id = ?", refund.ID).Updates(map[string]interface{}{
+			"reversal":    string(data),
+			"reversed_at": time.Now(),
+		}).Error; err != nil {
+			return fmt.Errorf("更新退款记录失败: %w", err)
+		}
+		userID = refund.UserID
+		repository.Infof("Refund %s reversed for trade %s: %s", refund.RefundNo, trade.TradeNo, string(data))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if userID != "" {
+		tools.ClearUserCacheAsync(userID)
+	}
+	return nil
+}
+
+// reverseBenefits 回收订单发放的权益
+// 部分退款按退款比例扣回积分；全额退款扣回该订单剩余的全部积分并撤销会员，
+// 已被消费的积分不再追扣，计入 UncollectedCredits 供人工处理
+func (s *Service) reverseBenefits(tx *gorm.DB, trade *models.Trade, refund *models.TradeRefund) (*RefundReversal, error) {
+	reversal := &RefundReversal{
+		Ratio:       math.Min(refund.Amount/trade.Amount, 1),
+		Commissions: []CommissionReversal{},
+	}
+	full := refund.IsFullRefund
+
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ?", trade.UserID).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+	reversal.OldVipLevel = user.VipLevel
+	reversal.NewVipLevel = user.VipLevel
+
+	var params models.UserParameters
+	hasParams := tx.Where("user_id = ?", trade.UserID).First(&params).Error == nil
+	reversal.OldStorageQuota = params.StorageQuota
+	reversal.NewStorageQuota = params.StorageQuota
+
+	userUpdates := map[string]interface{}{}
+
+	var userProduction models.UserProduction
+	err := tx.Where("trade_id = ?", trade.ID).Preload("Production").First(&userProduction).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("查询用户产品失败: %w", err)
+	}
+
+	if err == nil && userProduction.Production != nil {
+		product := userProduction.Production
+		switch product.ProductType {
+		case "订阅服务":
+			if err := s.reverseSubscription(tx, &user, &userProduction, reversal, full); err != nil {
+				return nil, err
+			}
+			if full {
+				userUpdates["vip_level"] = reversal.NewVipLevel
+				if reversal.NewVipLevel == 0 && user.Role == 2 {
+					userUpdates["role"] = 1
+				}
+				if hasParams && reversal.NewStorageQuota != reversal.OldStorageQuota {
+					if err := tx.Model(&models.UserParameters{}).Where("user_id = ?", trade.UserID).
+						Update("storage_quota", reversal.NewStorageQuota).Error; err != nil {
+						return nil, fmt.Errorf("更新存储配额失败: %w", err)
+					}
+				} else if !hasParams {
+					reversal.NewStorageQuota = reversal.OldStorageQuota
+				}
+			}
+		case "积分套餐":
+			if err := s.reverseCreditPackage(tx, &user, &userProduction, product, reversal, full); err != nil {
+				return nil, err
+			}
+			if reversal.PermanentCreditsClawed > 0 {
+				userUpdates["credits"] = gorm.Expr("credits - ?", reversal.PermanentCreditsClawed)
+			}
+		}
+	}
+
+	if trade.TradeType == string(models.TradeTypeRecharge) && user.TotalConsumption != nil {
+		deducted := math.Min(refund.Amount, *user.TotalConsumption)
+		if deducted > 0 {
+			reversal.TotalConsumptionDeducted = roundAmount(deducted)
+			userUpdates["total_consumption"] = gorm.Expr("total_consumption - ?", reversal.TotalConsumptionDeducted)
+		}
+	}
+
+	if len(userUpdates) > 0 {
+		if err := tx.Model(&models.User{}).Where("user_id = ?", trade.UserID).Updates(userUpdates).Error; err != nil {
+			return nil, fmt.Errorf("更新用户失败: %w", err)
+		}
+	}
+
+	if err := s.reverseCommissions(tx, trade, refund, reversal); err != nil {
+		return nil, err
+	}
+
+	clawed := reversal.MonthlyCreditsClawed + reversal.TimedCreditsClawed + reversal.PermanentCreditsClawed
+	if clawed > 0 || reversal.UncollectedCredits > 0 {
+		balance, err := service.NewCreditLedgerService().GetBalance(tx, trade.UserID)
+		if err != nil {
+			return nil, err
+		}
+		credits := -clawed
+		description := fmt.Sprintf("订单%s退款%.2f元，扣回%d积分", trade.TradeNo, refund.Amount, clawed)
+		if reversal.UncollectedCredits > 0 {
+			description += fmt.Sprintf("（%d积分已使用未扣回）", reversal.UncollectedCredits)
+		}
+		record := models.CreditRecord{
+			UserID:      trade.UserID,
+			Credits:     &credits,
+			RecordType:  models.CreditRefund,
+			Description: &description,
+			Balance:     &balance.Total,
+			CreatedAt:   time.Now(),
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return nil, fmt.Errorf("创建积分记录失败: %w", err)
+		}
+	}
+	return reversal, nil
+}
+
+// reverseSubscription 扣回订阅发放的每月权益积分，全额退款时撤销该订阅并按剩余有效订阅重新计算会员等级
+func (s *Service) reverseSubscription(tx *gorm.DB, user *models.User, userProduction *models.UserProduction, reversal *RefundReversal, full bool) error {
+	perMonth := 0
+	if productConfig := config.GetSubscriptionProduct(userProduction.Production.Name); productConfig != nil {
+		perMonth = productConfig.MonthlyCredits()
+	}
+
+	var benefits []models.UserMonthlyBenefit
+	if err := tx.Where("user_production_id = ?", userProduction.ID).Find(&benefits).Error; err != nil {
+		return fmt.Errorf("查询每月权益失败: %w", err)
+	}
+	for _, benefit := range benefits {
+		target := int(math.Round(float64(perMonth) * reversal.Ratio))
+		claw := min(benefit.MonthlyCredits, target)
+		if full {
+			claw = max(benefit.MonthlyCredits, 0)
+		}
+		reversal.UncollectedCredits += max(target-claw, 0)
+		if claw <= 0 {
+			continue
+		}
+		if err := tx.Model(&models.UserMonthlyBenefit{}).Where("id = ?", benefit.ID).Updates(map[string]interface{}{
+			"monthly_credits": gorm.Expr("monthly_credits - ?", claw),
+			"updated_at":      time.Now(),
+		}).Error; err != nil {
+			return fmt.Errorf("扣回每月权益积分失败: %w", err)
+		}
+		reversal.MonthlyCreditsClawed += claw
+	}
+
+	if !full {
+		return nil
+	}
+
+	if err := tx.Model(&models.UserProduction{}).Where("id = ?", userProduction.ID).Updates(map[string]interface{}{
+		"status":     string(models.UserProductionStatusInactive),
+		"updated_at": time.Now(),
+	}).Error; err != nil {
+		return fmt.Errorf("撤销用户产品失败: %w", err)
+	}
+	reversal.MembershipRevoked = true
+
+	// 按仍有效的订阅重新计算会员等级与存储配额
+	reversal.NewVipLevel = 0
+	reversal.NewStorageQuota = config.StorageQuotaMap[0]
+	_, active, productName, err := service.NewBenefitService().CalculateMembershipExpireTimeWithDB(tx, user.UserID)
+	if err != nil {
+		return err
+	}
+	if active {
+		if productConfig := config.GetSubscriptionProduct(productName); productConfig != nil {
+			reversal.NewVipLevel = productConfig.VipLevel
+			reversal.NewStorageQuota = productConfig.StorageQuota
+		}
+	}
+	return nil
+}
+
+// reverseCreditPackage 按比例扣回积分套餐发放的积分，积分不足时只扣剩余部分
+func (s *Service) reverseCreditPackage(tx *gorm.DB, user *models.User, userProduction *models.UserProduction, product *models.Production, reversal *RefundReversal, full bool) error {
+	if product.ValidityPeriod != nil && *product.ValidityPeriod > 0 {
+		var timedCredits []models.UserTimedCredits
+		query := tx.Where("trade_id = ?", userProduction.TradeID)
+		var count int64
+		tx.Model(&models.UserTimedCredits{}).Where("trade_id = ?", userProduction.TradeID).Count(&count)
+		if count == 0 {
+			// 兼容未记录 trade_id 的历史数据：按来源与发放时间匹配
+			query = tx.Where("user_id = ? AND source_type = ? AND source_desc = ? AND created_at BETWEEN ? AND ?",
+				user.UserID, models.TimedCreditSourcePackage, "购买"+product.Name,
+				userProduction.CreatedAt.Add(-time.Minute), userProduction.CreatedAt.Add(time.Minute))
+		}
+		if err := query.Find(&timedCredits).Error; err != nil {
+			return fmt.Errorf("查询有期限积分失败: %w", err)
+		}
+
+		for _, credit := range timedCredits {
+			target := int(math.Round(float64(credit.OriginalCredits) * reversal.Ratio))
+			claw := min(credit.Credits, target)
+			if full {
+				claw = max(credit.Credits, 0)
+			}
+			reversal.UncollectedCredits += max(target-claw, 0)
+			if claw <= 0 {
+				continue
+			}
+			if err := tx.Model(&models.UserTimedCredits{}).Where("id = ?", credit.ID).Updates(map[string]interface{}{
+				"credits":    gorm.Expr("credits - ?", claw),
+				"updated_at": time.Now(),
+			}).Error; err != nil {
+				return fmt.Errorf("扣回有期限积分失败: %w", err)
+			}
+			reversal.TimedCreditsClawed += claw
+		}
+		return nil
+	}
+
+	packageConfig := config.GetCreditPackage(product.Name)
+	if packageConfig == nil {
+		return nil
+	}
+	target := int(math.Round(float64(packageConfig.Credits) * reversal.Ratio))
+	claw := min(max(user.Credits, 0), target)
+	reversal.PermanentCreditsClawed = claw
+	reversal.UncollectedCredits += target - claw
+	return nil
+}
+
+// reverseCommissions 冲正订单产生的佣金
+// 未发放的佣金全额退款时驳回、部分退款时按比例调减；已发放或已提现的佣金生成负数冲正记录
+func (s *Service) reverseCommissions(tx *gorm.DB, trade *models.Trade, refund *models.TradeRefund, reversal *RefundReversal) error {
+	var commissions []models.CommissionRecord
+	if err := tx.Where("order_id = ? AND amount > 0 AND status <> ?", trade.ID, models.CommissionRejected).
+		Find(&commissions).Error; err != nil {
+		return fmt.Errorf("查询佣金记录失败: %w", err)
+	}
+
+	// 已发放佣金此前生成的冲正记录（负数），按邀请人汇总后从已发放金额中扣除
+	offsets := make(map[string]float64)
+	var offsetRows []models.CommissionRecord
+	if err := tx.Where("order_id = ? AND amount < 0", trade.ID).Find(&offsetRows).Error; err != nil {
+		return fmt.Errorf("查询佣金冲正记录失败: %w", err)
+	}
+	for _, row := range offsetRows {
+		offsets[row.UserID] += row.Amount
+	}
+
+	// 以本次退款前的剩余实付金额为基数计算冲减比例，多次部分退款后佣金恰好归零
+	remainingBefore := trade.Amount - trade.RefundedAmount + refund.Amount
+	factor := 1.0
+	if remainingBefore > 0 && !refund.IsFullRefund {
+		factor = math.Min(refund.Amount/remainingBefore, 1)
+	}
+
+	for _, commission := range commissions {
+		base := commission.Amount
+		if commission.Status != models.CommissionPending && commission.Status != models.CommissionApplying {
+			net := math.Max(base+offsets[commission.UserID], 0)
+			offsets[commission.UserID] += base - net
+			base = net
+		}
+		item := CommissionReversal{
+			CommissionID: commission.ID,
+			InviterID:    commission.UserID,
+			Amount:       roundAmount(base * factor),
+		}
+		if item.Amount <= 0 {
+			continue
+		}
+
+		switch commission.Status {
+		case models.CommissionPending, models.CommissionApplying:
+			updates := map[string]interface{}{"amount": roundAmount(commission.Amount - item.Amount)}
+			item.Action = "reduced"
+			if refund.IsFullRefund {
+				updates = map[string]interface{}{"status": models.CommissionRejected}
+				item.Action = "rejected"
+			}
+			if err := tx.Model(&models.CommissionRecord{}).Where("id = ?", commission.ID).Updates(updates).Error; err != nil {
+				return fmt.Errorf("更新佣金记录失败: %w", err)
+			}
+		default:
+			now := time.Now()
+			orderID := trade.ID
+			offset := &models.CommissionRecord{
+				UserID:      commission.UserID,
+				RelationID:  commission.RelationID,
+				OrderID:     &orderID,
+				Amount:      -item.Amount,
+				Status:      models.CommissionIssued,
+				Description: fmt.Sprintf("订单%s退款，佣金冲正", trade.TradeNo),
+				IssueTime:   &now,
+			}
+			if err := tx.Create(offset).Error; err != nil {
+				return fmt.Errorf("创建佣金冲正记录失败: %w", err)
+			}
+			item.Action = "offset"
+		}
+		reversal.Commissions = append(reversal.Commissions, item)
+	}
+	return nil
+}
+
+// roundAmount 金额保留两位小数
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,142 @@
+package payment
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"01agent_server/internal/config"
+	"01agent_server/internal/models"
+	"01agent_server/internal/tools"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestSubscription 创建一笔已支付的订阅订单及其用户产品与当月权益
+func newTestSubscription(t *testing.T, svc *Service, product *models.Production, paidAt time.Time, credits int) (*models.Trade, *models.UserProduction) {
+	t.Helper()
+	trade := &models.Trade{
+		TradeNo:        fmt.Sprintf("T%d", paidAt.UnixNano()),
+		UserID:         "u1",
+		Amount:         product.Price,
+		TradeType:      string(models.TradeTypeRecharge),
+		PaymentChannel: string(models.PaymentChannelWxQR),
+		PaymentStatus:  string(models.PaymentStatusSuccess),
+		Title:          product.Name,
+		PaidAt:         &paidAt,
+		CreatedAt:      paidAt,
+	}
+	if err := svc.db.Create(trade).Error; err != nil {
+		t.Fatal(err)
+	}
+	userProduction := &models.UserProduction{
+		UserID:       "u1",
+		ProductionID: product.ID,
+		TradeID:      trade.ID,
+		Status:       tools.StringPtr(string(models.UserProductionStatusActive)),
+		CreatedAt:    paidAt,
+		UpdatedAt:    paidAt,
+	}
+	if err := svc.db.Create(userProduction).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.db.Create(&models.UserMonthlyBenefit{
+		UserID:           "u1",
+		UserProductionID: &userProduction.ID,
+		MonthlyCredits:   credits,
+		BenefitMonth:     paidAt,
+		CreatedAt:        paidAt,
+		UpdatedAt:        paidAt,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return trade, userProduction
+}
+
+func TestRefundSubscriptionKeepsOtherActiveSubscription(t *testing.T) {
+	db := newTestPaymentDB(t)
+	if err := db.AutoMigrate(&models.Production{}, &models.User{}, &models.UserParameters{}, &models.UserMonthlyBenefit{},
+		&models.TradeRefund{}, &models.CommissionRecord{}, &models.UserDailyBenefit{}, &models.UserTimedCredits{},
+		&models.CreditRecord{}); err != nil {
+		t.Fatal(err)
+	}
+	svc := &Service{db: db, machine: NewTradeStateMachine()}
+
+	// 回收完成后异步清理用户缓存，需要可用的 Redis
+	mr := miniredis.RunT(t)
+	port, _ := strconv.Atoi(mr.Port())
+	prevConfig := config.AppConfig
+	config.AppConfig = &config.Config{Redis: config.RedisConfig{Host: mr.Host(), Port: port, PoolSize: 2}}
+	t.Cleanup(func() { config.AppConfig = prevConfig })
+
+	lite := &models.Production{Name: "轻量版", Price: 29, ProductType: "订阅服务"}
+	pro := &models.Production{Name: "专业版", Price: 99, ProductType: "订阅服务"}
+	for _, p := range []*models.Production{lite, pro} {
+		if err := db.Create(p).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Create(&models.User{UserID: "u1", VipLevel: 3, Role: 2}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.UserParameters{UserID: "u1", StorageQuota: config.StorageQuotaMap[2]}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 用户先后购买了轻量版与专业版，全额退款专业版后仍保留轻量版会员
+	newTestSubscription(t, svc, lite, time.Now().Add(-10*24*time.Hour), 870)
+	proTrade, proProduction := newTestSubscription(t, svc, pro, time.Now().Add(-time.Hour), 4500)
+
+	refund := &models.TradeRefund{
+		RefundNo:     "R1",
+		TradeID:      proTrade.ID,
+		TradeNo:      proTrade.TradeNo,
+		UserID:       "u1",
+		Amount:       proTrade.Amount,
+		IsFullRefund: true,
+		Offline:      true,
+		Status:       string(models.TradeRefundStatusSuccess),
+	}
+	if err := db.Create(refund).Error; err != nil {
+		t.Fatal(err)
+	}
+	db.Model(proTrade).Updates(map[string]interface{}{
+		"refunded_amount": proTrade.Amount,
+		"payment_status":  string(models.PaymentStatusRefunded),
+	})
+
+	payload := fmt.Sprintf(`{"refund_id":%d}`, refund.ID)
+	event := &models.PaymentEvent{EventType: EventTradeRefunded, TradeID: proTrade.ID, TradeNo: proTrade.TradeNo, Payload: &payload}
+	if err := svc.handleTradeRefunded(event); err != nil {
+		t.Fatal(err)
+	}
+
+	var user models.User
+	db.First(&user, "user_id = ?", "u1")
+	if user.VipLevel != 1 || user.Role != 2 {
+		t.Fatalf("want vip level 1 from the remaining subscription, got vip=%d role=%d", user.VipLevel, user.Role)
+	}
+	var params models.UserParameters
+	db.First(&params, "user_id = ?", "u1")
+	if params.StorageQuota != config.StorageQuotaMap[1] {
+		t.Fatalf("want storage quota of the remaining subscription, got %d", params.StorageQuota)
+	}
+
+	var revoked models.UserProduction
+	db.First(&revoked, proProduction.ID)
+	if revoked.Status == nil || *revoked.Status != string(models.UserProductionStatusInactive) {
+		t.Fatalf("refunded subscription should be inactive, got %v", revoked.Status)
+	}
+	var benefit models.UserMonthlyBenefit
+	db.First(&benefit, "user_production_id = ?", proProduction.ID)
+	if benefit.MonthlyCredits != 0 {
+		t.Fatalf("refunded subscription credits should be clawed back, got %d", benefit.MonthlyCredits)
+	}
+
+	var saved models.TradeRefund
+	db.First(&saved, refund.ID)
+	if saved.ReversedAt == nil || saved.Reversal == nil {
+		t.Fatal("refund should record the reversal")
+	}
+}
@@ -0,0 +1,362 @@
+package payment
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"time"
+
+	"01agent_server/internal/models"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/service"
+	"01agent_server/internal/tools"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrProductUnavailable 产品不存在或已下架
+	ErrProductUnavailable = errors.New("产品不存在或已下架")
+	// ErrTradeNotFound 订单不存在
+	ErrTradeNotFound = errors.New("订单不存在")
+	// ErrTradeAmountMismatch 支付金额与订单金额不一致
+	ErrTradeAmountMismatch = errors.New("支付金额与订单金额不一致")
+)
+
+// markPaidMaxRetries 乐观锁冲突时的最大重试次数
+const markPaidMaxRetries = 3
+
+// Service 支付服务
+// 负责创建订单、处理渠道回调与主动查询，订单状态通过 TradeStateMachine 流转，
+// 支付成功后的权益发放由发件箱事件异步完成
+type Service struct {
+	db             *gorm.DB
+	machine        *TradeStateMachine
+	benefitService *service.BenefitService
+}
+
+// NewService 创建支付服务
+func NewService() *Service {
+	return &Service{
+		db:             repository.DB,
+		machine:        NewTradeStateMachine(),
+		benefitService: service.NewBenefitService(),
+	}
+}
+
+// CreateOrder 为产品创建待支付订单并在渠道侧下单
+// 渠道下单失败时订单置为失败
+func (s *Service) CreateOrder(userID string, productID int, channel models.PaymentChannel, opts *OrderOptions) (*OrderResult, error) {
+	provider, err := GetProviderForChannel(channel)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts == nil {
+		opts = &OrderOptions{}
+	}
+	if channel == models.PaymentChannelWxPub && opts.OpenID == "" {
+		var user models.User
+		if err := s.db.Select("openid").Where("user_id = ?", userID).First(&user).Error; err == nil {
+			opts.OpenID = tools.GetStringValue(user.OpenID)
+		}
+	}
+	if (channel == models.PaymentChannelWxPub || channel == models.PaymentChannelWxLite) && opts.OpenID == "" {
+		return nil, ErrOpenIDRequired
+	}
+
+	trade, err := s.createTrade(userID, productID, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := provider.CreateOrder(trade, opts)
+	if err != nil {
+		if tErr := s.machine.Transition(s.db, trade, models.PaymentStatusFailed, nil); tErr != nil {
+			repository.Warnf("Mark trade %s failed: %v", trade.TradeNo, tErr)
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+// createTrade 为产品创建待支付交易
+func (s *Service) createTrade(userID string, productID int, channel models.PaymentChannel) (*models.Trade, error) {
+	var product models.Production
+	if err := s.db.Where("id = ? AND status = ?", productID, 1).First(&product).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrProductUnavailable
+		}
+		return nil, fmt.Errorf("查询产品失败: %w", err)
+	}
+	if product.Price <= 0 {
+		return nil, ErrProductUnavailable
+	}
+
+	metadata, _ := json.Marshal(map[string]interface{}{
+		"product_id":   product.ID,
+		"product_name": product.Name,
+		"product_type": product.ProductType,
+	})
+
+	trade := &models.Trade{
+		TradeNo:        GenerateTradeNo(),
+		UserID:         userID,
+		Amount:         product.Price,
+		TradeType:      string(models.TradeTypeRecharge),
+		PaymentChannel: string(channel),
+		PaymentStatus:  string(models.PaymentStatusPending),
+		Title:          product.Name,
+		Metadata:       tools.StringPtr(string(metadata)),
+		CreatedAt:      time.Now(),
+	}
+	if err := s.db.Create(trade).Error; err != nil {
+		return nil, fmt.Errorf("创建订单失败: %w", err)
+	}
+	return trade, nil
+}
+
+// GetUserTrade 获取用户的订单
+func (s *Service) GetUserTrade(userID, tradeNo string) (*models.Trade, error) {
+	var trade models.Trade
+	err := s.db.Where("trade_no = ? AND user_id = ?", tradeNo, userID).First(&trade).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrTradeNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询订单失败: %w", err)
+	}
+	return &trade, nil
+}
+
+// HandleNotify 验证渠道异步通知并幂等地将订单置为支付成功
+func (s *Service) HandleNotify(provider Provider, r *http.Request) error {
+	result, err := provider.VerifyNotify(r)
+	if err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	_, err = s.applyTradeResult(result)
+	return err
+}
+
+// SyncTrade 待支付订单主动向渠道查询一次，已支付则置为成功，返回最新订单
+func (s *Service) SyncTrade(trade *models.Trade) (*models.Trade, error) {
+	if models.PaymentStatus(trade.PaymentStatus) != models.PaymentStatusPending {
+		return trade, nil
+	}
+	provider, err := GetProviderForChannel(models.PaymentChannel(trade.PaymentChannel))
+	if err != nil {
+		return trade, nil
+	}
+	result, err := provider.Query(trade)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.applyTradeResult(result); err != nil {
+		return nil, err
+	}
+
+	var latest models.Trade
+	if err := s.db.Where("id = ?", trade.ID).First(&latest).Error; err != nil {
+		return nil, fmt.Errorf("查询订单失败: %w", err)
+	}
+	return &latest, nil
+}
+
+// applyTradeResult 渠道确认已支付时将订单置为成功，返回本次调用是否实际变更了订单
+func (s *Service) applyTradeResult(result *TradeResult) (bool, error) {
+	if result.State != ProviderTradePaid {
+		return false, nil
+	}
+	_, changed, err := s.MarkPaid(result.TradeNo, result.PaymentID, &result.PaidAmount, result.PaidAt)
+	return changed, err
+}
+
+// MarkPaid 将订单置为支付成功并写入履约事件，可被回调、主动查询、对账等多个入口重复调用
+// paidAmount 为 nil 时不校验金额（仅用于管理员强制补单）；paymentID 为空时保留原支付ID
+// 返回最新订单以及本次调用是否实际变更了订单
+func (s *Service) MarkPaid(tradeNo, paymentID string, paidAmount *float64, paidAt time.Time) (*models.Trade, bool, error) {
+	var trade models.Trade
+	for attempt := 0; attempt < markPaidMaxRetries; attempt++ {
+		changed := false
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("trade_no = ?", tradeNo).First(&trade).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return ErrTradeNotFound
+				}
+				return fmt.Errorf("查询订单失败: %w", err)
+			}
+
+			switch models.PaymentStatus(trade.PaymentStatus) {
+			case models.PaymentStatusSuccess, models.PaymentStatusFinished, models.PaymentStatusRefunded:
+				// 已支付，重复通知直接忽略
+				return nil
+			}
+
+			if paidAmount != nil && math.Abs(trade.Amount-*paidAmount) > 0.001 {
+				repository.Errorf("Trade amount mismatch: trade_no=%s, amount=%.2f, paid=%.2f", tradeNo, trade.Amount, *paidAmount)
+				return ErrTradeAmountMismatch
+			}
+
+			fields := map[string]interface{}{"paid_at": paidAt}
+			if paymentID != "" {
+				fields["payment_id"] = paymentID
+			}
+			if err := s.machine.Transition(tx, &trade, models.PaymentStatusSuccess, fields); err != nil {
+				return err
+			}
+			trade.PaidAt = &paidAt
+			if paymentID != "" {
+				trade.PaymentID = tools.StringPtr(paymentID)
+			}
+			changed = true
+			return nil
+		})
+		if err == ErrTradeConcurrentUpdate {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if changed {
+			GetOutbox().Kick()
+		}
+		return &trade, changed, nil
+	}
+	return nil, false, ErrTradeConcurrentUpdate
+}
+
+// TradeFulfilment 一次支付成功履约的结果
+type TradeFulfilment struct {
+	Trade          *models.Trade
+	User           *models.User
+	Product        *models.Production
+	BenefitChanges map[string]interface{} // BenefitService.ProcessBenefitChanges 返回的权益变更
+}
+
+// handleTradePaid 处理支付成功事件：按订单元数据中的产品发放权益并发送支付回执
+func (s *Service) handleTradePaid(event *models.PaymentEvent) error {
+	_, err := s.fulfilTradePaid(event)
+	return err
+}
+
+// fulfilTradePaid 发放支付成功订单的权益，订单无需履约或权益已发放时返回 nil
+// 在同一事务内抢占订单的履约标记并发放权益，失败时整体回滚，重复投递不会重复或漏发权益
+func (s *Service) fulfilTradePaid(event *models.PaymentEvent) (*TradeFulfilment, error) {
+	var (
+		trade   models.Trade
+		product models.Production
+		user    models.User
+		changes map[string]interface{}
+	)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", event.TradeID).First(&trade).Error; err != nil {
+			return fmt.Errorf("查询订单失败: %w", err)
+		}
+		if status := models.PaymentStatus(trade.PaymentStatus); status != models.PaymentStatusSuccess && status != models.PaymentStatusFinished {
+			repository.Warnf("Trade %s is %s, skip benefit changes", trade.TradeNo, status)
+			return nil
+		}
+
+		// 条件更新抢占履约标记，并发投递时只有一个事务能更新成功
+		now := time.Now()
+		claim := tx.Model(&models.Trade{}).Where("id = ? AND fulfilled_at IS NULL", trade.ID).Update("fulfilled_at", now)
+		if claim.Error != nil {
+			return fmt.Errorf("标记订单履约失败: %w", claim.Error)
+		}
+		if claim.RowsAffected == 0 {
+			repository.Infof("Trade %s benefits already granted, skip", trade.TradeNo)
+			return nil
+		}
+		trade.FulfilledAt = &now
+
+		// 履约标记上线前已发放权益的订单只有用户产品关联，补上标记后跳过
+		var granted int64
+		if err := tx.Model(&models.UserProduction{}).Where("trade_id = ?", trade.ID).Count(&granted).Error; err != nil {
+			return fmt.Errorf("查询权益发放记录失败: %w", err)
+		}
+		if granted > 0 {
+			repository.Infof("Trade %s benefits already granted, skip", trade.TradeNo)
+			return nil
+		}
+
+		var metadata map[string]interface{}
+		if trade.Metadata != nil {
+			json.Unmarshal([]byte(*trade.Metadata), &metadata)
+		}
+		productID, _ := metadata["product_id"].(float64)
+		if productID <= 0 {
+			repository.Warnf("Trade %s has no product_id in metadata, skip benefit changes", trade.TradeNo)
+			return nil
+		}
+
+		if err := tx.Where("id = ?", int(productID)).First(&product).Error; err != nil {
+			return fmt.Errorf("查询产品 %d 失败: %w", int(productID), err)
+		}
+		if err := tx.Where("user_id = ?", trade.UserID).First(&user).Error; err != nil {
+			return fmt.Errorf("查询用户 %s 失败: %w", trade.UserID, err)
+		}
+
+		var err error
+		changes, err = s.benefitService.ProcessBenefitChangesWithDB(tx, &user, &product, &trade)
+		if err != nil {
+			return fmt.Errorf("发放权益失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if changes == nil {
+		return nil, nil
+	}
+
+	tools.ClearUserCacheAsync(trade.UserID)
+	repository.Infof("Trade %s fulfilled for user %s, product %d", trade.TradeNo, trade.UserID, product.ID)
+
+	if user.Email != nil && *user.Email != "" {
+		paidAt := time.Now()
+		if trade.PaidAt != nil {
+			paidAt = *trade.PaidAt
+		}
+		if err := service.GetEmailService().SendPaymentReceipt(*user.Email, service.PaymentReceiptEmailData{
+			Nickname:    tools.GetStringValue(user.Nickname),
+			OutTradeNo:  trade.TradeNo,
+			ProductName: product.Name,
+			Amount:      fmt.Sprintf("%.2f", trade.Amount),
+			PaymentType: paymentChannelName(trade.PaymentChannel),
+			PaidAt:      paidAt.Format("2006-01-02 15:04:05"),
+		}); err != nil {
+			repository.Warnf("Send payment receipt for trade %s failed: %v", trade.TradeNo, err)
+		}
+	}
+	return &TradeFulfilment{Trade: &trade, User: &user, Product: &product, BenefitChanges: changes}, nil
+}
+
+// ==================== 工具函数 ====================
+
+// GenerateTradeNo 生成交易流水号：时间戳 + 6位随机数
+func GenerateTradeNo() string {
+	n, _ := rand.Int(rand.Reader, big.NewInt(1000000))
+	return fmt.Sprintf("%s%06d", time.Now().Format("20060102150405"), n.Int64())
+}
+
+// paymentChannelName 支付渠道展示名称
+func paymentChannelName(channel string) string {
+	switch models.PaymentChannel(channel) {
+	case models.PaymentChannelWxQR, models.PaymentChannelWxPub, models.PaymentChannelWxLite, models.PaymentChannelWxScan:
+		return "微信支付"
+	case models.PaymentChannelAlipay, models.PaymentChannelAlipayQR, models.PaymentChannelAlipayWap,
+		models.PaymentChannelAlipayLite, models.PaymentChannelAlipayPub, models.PaymentChannelAlipayScan:
+		return "支付宝"
+	default:
+		return channel
+	}
+}
@@ -0,0 +1,77 @@
+package payment
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"01agent_server/internal/models"
+	"01agent_server/internal/service"
+)
+
+func TestFulfilTradePaidRollsBackPartialGrant(t *testing.T) {
+	db := newTestPaymentDB(t)
+	if err := db.AutoMigrate(&models.Production{}, &models.User{}, &models.UserParameters{}, &models.UserMonthlyBenefit{},
+		&models.UserDailyBenefit{}, &models.UserTimedCredits{}, &models.CreditRecord{}); err != nil {
+		t.Fatal(err)
+	}
+	svc := &Service{db: db, machine: NewTradeStateMachine(), benefitService: service.NewBenefitService()}
+
+	// 产品名称没有对应的订阅配置，创建用户产品关联后发放失败
+	product := &models.Production{Name: "未知套餐", Price: 99, ProductType: "订阅服务"}
+	if err := db.Create(product).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.User{UserID: "u1"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	paidAt := time.Now()
+	metadata := fmt.Sprintf(`{"product_id":%d}`, product.ID)
+	trade := &models.Trade{
+		TradeNo:        "T202610160005",
+		UserID:         "u1",
+		Amount:         99,
+		TradeType:      string(models.TradeTypeRecharge),
+		PaymentChannel: string(models.PaymentChannelWxQR),
+		PaymentStatus:  string(models.PaymentStatusSuccess),
+		Title:          product.Name,
+		Metadata:       &metadata,
+		PaidAt:         &paidAt,
+		CreatedAt:      paidAt,
+	}
+	if err := db.Create(trade).Error; err != nil {
+		t.Fatal(err)
+	}
+	event := &models.PaymentEvent{EventType: EventTradePaid, TradeID: trade.ID, TradeNo: trade.TradeNo}
+
+	if _, err := svc.fulfilTradePaid(event); err == nil {
+		t.Fatal("want error for a product without subscription config")
+	}
+	var granted int64
+	db.Model(&models.UserProduction{}).Where("trade_id = ?", trade.ID).Count(&granted)
+	var latest models.Trade
+	db.First(&latest, trade.ID)
+	if granted != 0 || latest.FulfilledAt != nil {
+		t.Fatalf("failed fulfilment should roll back, got %d user productions, fulfilled_at=%v", granted, latest.FulfilledAt)
+	}
+
+	// 修复产品配置后重新投递，权益正常发放
+	db.Model(product).Update("name", "专业版")
+	fulfilment, err := svc.fulfilTradePaid(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fulfilment == nil || fulfilment.User.VipLevel == 0 {
+		t.Fatalf("retry should grant benefits, got %+v", fulfilment)
+	}
+
+	// 再次投递时按履约标记跳过
+	if fulfilment, err = svc.fulfilTradePaid(event); err != nil || fulfilment != nil {
+		t.Fatalf("repeat delivery should be skipped, got %+v %v", fulfilment, err)
+	}
+	db.Model(&models.UserProduction{}).Where("trade_id = ?", trade.ID).Count(&granted)
+	db.First(&latest, trade.ID)
+	if granted != 1 || latest.FulfilledAt == nil {
+		t.Fatalf("want exactly one grant with fulfilled_at set, got %d fulfilled_at=%v", granted, latest.FulfilledAt)
+	}
+}
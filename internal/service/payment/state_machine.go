@@ -0,0 +1,145 @@
+package payment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"01agent_server/internal/models"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/tools"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrIllegalTransition 订单状态不允许该变更
+	ErrIllegalTransition = errors.New("订单状态不允许该操作")
+	// ErrTradeConcurrentUpdate 订单已被其他请求修改
+	ErrTradeConcurrentUpdate = errors.New("订单已被修改，请刷新后重试")
+)
+
+// tradeTransitions 订单状态的合法流转
+//
+//	pending  -> success | failed
+//	failed   -> success            （订单关闭后渠道回调迟到，用户实际已付款）
+//	success  -> refunded | finished
+//	refunded / finished 为终态
+var tradeTransitions = map[models.PaymentStatus][]models.PaymentStatus{
+	models.PaymentStatusPending: {models.PaymentStatusSuccess, models.PaymentStatusFailed},
+	models.PaymentStatusFailed:  {models.PaymentStatusSuccess},
+	models.PaymentStatusSuccess: {models.PaymentStatusRefunded, models.PaymentStatusFinished},
+}
+
+// transitionEvents 进入某状态时写入发件箱的事件类型
+var transitionEvents = map[models.PaymentStatus]string{
+	models.PaymentStatusSuccess: EventTradePaid,
+}
+
+// CanTransition 判断订单状态能否从 from 变更为 to
+func CanTransition(from, to models.PaymentStatus) bool {
+	for _, next := range tradeTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TradeStateMachine 订单状态机
+// 状态变更以 payment_status + version 作为乐观锁条件，并在同一事务中写入对应的发件箱事件
+type TradeStateMachine struct{}
+
+// NewTradeStateMachine 创建订单状态机
+func NewTradeStateMachine() *TradeStateMachine {
+	return &TradeStateMachine{}
+}
+
+// Transition 在 tx 中将订单变更为 to 状态，fields 为同时更新的其他字段
+// 订单已被并发修改时返回 ErrTradeConcurrentUpdate，成功后同步更新 trade 的状态与版本号
+func (m *TradeStateMachine) Transition(tx *gorm.DB, trade *models.Trade, to models.PaymentStatus, fields map[string]interface{}) error {
+	from := models.PaymentStatus(trade.PaymentStatus)
+	if !CanTransition(from, to) {
+		repository.Warnf("Illegal trade transition: trade_no=%s, %s -> %s", trade.TradeNo, from, to)
+		return ErrIllegalTransition
+	}
+
+	updates := map[string]interface{}{
+		"payment_status": string(to),
+		"version":        gorm.Expr("version + 1"),
+	}
+	for k, v := range fields {
+		updates[k] = v
+	}
+
+	result := tx.Model(&models.Trade{}).
+		Where("id = ? AND payment_status = ? AND version = ?", trade.ID, trade.PaymentStatus, trade.Version).
+		Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("更新订单状态失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrTradeConcurrentUpdate
+	}
+	trade.PaymentStatus = string(to)
+	trade.Version++
+
+	if eventType, ok := transitionEvents[to]; ok {
+		if err := enqueueEvent(tx, trade, eventType, fmt.Sprintf("%s:%d", eventType, trade.ID), map[string]interface{}{
+			"from": string(from),
+			"to":   string(to),
+		}); err != nil {
+			return err
+		}
+	}
+
+	repository.Infof("Trade %s transitioned %s -> %s (version %d)", trade.TradeNo, from, to, trade.Version)
+	return nil
+}
+
+// Update 在 tx 中以乐观锁更新订单字段（不改变状态），fields 为空时仅递增版本号，可用于占用订单
+func (m *TradeStateMachine) Update(tx *gorm.DB, trade *models.Trade, fields map[string]interface{}) error {
+	updates := map[string]interface{}{
+		"version": gorm.Expr("version + 1"),
+	}
+	for k, v := range fields {
+		updates[k] = v
+	}
+
+	result := tx.Model(&models.Trade{}).
+		Where("id = ? AND payment_status = ? AND version = ?", trade.ID, trade.PaymentStatus, trade.Version).
+		Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("更新订单失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrTradeConcurrentUpdate
+	}
+	trade.Version++
+	return nil
+}
+
+// enqueueEvent 在 tx 中写入发件箱事件，相同 dedupKey 的事件只写入一次
+func enqueueEvent(tx *gorm.DB, trade *models.Trade, eventType, dedupKey string, payload map[string]interface{}) error {
+	var payloadStr *string
+	if payload != nil {
+		data, _ := json.Marshal(payload)
+		payloadStr = tools.StringPtr(string(data))
+	}
+
+	event := &models.PaymentEvent{
+		EventType: eventType,
+		DedupKey:  dedupKey,
+		TradeID:   trade.ID,
+		TradeNo:   trade.TradeNo,
+		Payload:   payloadStr,
+		Status:    string(models.PaymentEventStatusPending),
+		NextRunAt: time.Now(),
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(event).Error; err != nil {
+		return fmt.Errorf("写入支付事件失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,663 @@
+package payment
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"01agent_server/internal/config"
+	"01agent_server/internal/repository"
+)
+
+const (
+	wxPayDefaultBaseURL = "https://api.mch.weixin.qq.com"
+	wxPayAuthSchema     = "WECHATPAY2-SHA256-RSA2048"
+	// wxPayNotifyMaxSkew 回调时间戳允许的最大偏差
+	wxPayNotifyMaxSkew = 5 * time.Minute
+)
+
+var (
+	// ErrWxPayNotConfigured 微信支付未配置
+	ErrWxPayNotConfigured = errors.New("微信支付未配置")
+	// ErrWxPaySignature 微信支付签名验证失败
+	ErrWxPaySignature = errors.New("微信支付签名验证失败")
+)
+
+// WxPayAPIError 微信支付接口返回的业务错误
+type WxPayAPIError struct {
+	StatusCode int    `json:"-"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *WxPayAPIError) Error() string {
+	return fmt.Sprintf("微信支付接口错误(%d): %s %s", e.StatusCode, e.Code, e.Message)
+}
+
+// WxPayClient 微信支付 API v3 客户端
+// 请求使用商户私钥签名，应答与回调使用平台证书验签，回调资源使用 APIv3 密钥 AES-GCM 解密
+type WxPayClient struct {
+	cfg        config.WxPayConfig
+	baseURL    string
+	privateKey *rsa.PrivateKey
+	httpClient *http.Client
+
+	certMu      sync.RWMutex
+	certs       map[string]*x509.Certificate // 平台证书，按序列号索引
+	lastRefresh time.Time
+}
+
+var (
+	wxPayClientInstance *WxPayClient
+	wxPayClientErr      error
+	wxPayClientOnce     sync.Once
+)
+
+// GetWxPayClient 获取微信支付客户端单例
+func GetWxPayClient() (*WxPayClient, error) {
+	wxPayClientOnce.Do(func() {
+		if config.AppConfig == nil {
+			wxPayClientErr = ErrWxPayNotConfigured
+			return
+		}
+		wxPayClientInstance, wxPayClientErr = NewWxPayClient(config.AppConfig.WxPay)
+	})
+	return wxPayClientInstance, wxPayClientErr
+}
+
+// NewWxPayClient 创建微信支付客户端
+func NewWxPayClient(cfg config.WxPayConfig) (*WxPayClient, error) {
+	if cfg.MchID == "" || cfg.SerialNo == "" || cfg.PrivateKeyPath == "" || cfg.APIV3Key == "" {
+		return nil, ErrWxPayNotConfigured
+	}
+
+	keyPEM, err := os.ReadFile(cfg.PrivateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取微信支付商户私钥失败: %w", err)
+	}
+	privateKey, err := parseRSAPrivateKey(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("解析微信支付商户私钥失败: %w", err)
+	}
+
+	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = wxPayDefaultBaseURL
+	}
+
+	client := &WxPayClient{
+		cfg:        cfg,
+		baseURL:    baseURL,
+		privateKey: privateKey,
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+		certs:      make(map[string]*x509.Certificate),
+	}
+
+	if cfg.PlatformCertPath != "" {
+		certPEM, err := os.ReadFile(cfg.PlatformCertPath)
+		if err != nil {
+			return nil, fmt.Errorf("读取微信支付平台证书失败: %w", err)
+		}
+		cert, err := parseCertificate(certPEM)
+		if err != nil {
+			return nil, fmt.Errorf("解析微信支付平台证书失败: %w", err)
+		}
+		client.certs[strings.ToUpper(cert.SerialNumber.Text(16))] = cert
+	}
+
+	return client, nil
+}
+
+// MchID 商户号
+func (c *WxPayClient) MchID() string {
+	return c.cfg.MchID
+}
+
+// ==================== 下单 ====================
+
+// WxPayAmount 订单金额（单位：分）
+type WxPayAmount struct {
+	Total    int    `json:"total"`
+	Currency string `json:"currency,omitempty"`
+}
+
+// WxPayPayer 支付者
+type WxPayPayer struct {
+	OpenID string `json:"openid"`
+}
+
+// WxPayOrderRequest 下单请求
+type WxPayOrderRequest struct {
+	AppID       string      `json:"appid"`
+	MchID       string      `json:"mchid"`
+	Description string      `json:"description"`
+	OutTradeNo  string      `json:"out_trade_no"`
+	TimeExpire  string      `json:"time_expire,omitempty"`
+	Attach      string      `json:"attach,omitempty"`
+	NotifyURL   string      `json:"notify_url"`
+	Amount      WxPayAmount `json:"amount"`
+	Payer       *WxPayPayer `json:"payer,omitempty"`
+}
+
+// WxPayJSAPIParams 前端调起 JSAPI/小程序支付所需参数
+type WxPayJSAPIParams struct {
+	AppID     string `json:"appId"`
+	TimeStamp string `json:"timeStamp"`
+	NonceStr  string `json:"nonceStr"`
+	Package   string `json:"package"`
+	SignType  string `json:"signType"`
+	PaySign   string `json:"paySign"`
+}
+
+// WxPayTransaction 微信支付订单（查询结果与回调解密后的资源）
+type WxPayTransaction struct {
+	AppID          string `json:"appid"`
+	MchID          string `json:"mchid"`
+	OutTradeNo     string `json:"out_trade_no"`
+	TransactionID  string `json:"transaction_id"`
+	TradeType      string `json:"trade_type"`
+	TradeState     string `json:"trade_state"`
+	TradeStateDesc string `json:"trade_state_desc"`
+	SuccessTime    string `json:"success_time"`
+	Attach         string `json:"attach"`
+	Payer          struct {
+		OpenID string `json:"openid"`
+	} `json:"payer"`
+	Amount struct {
+		Total         int    `json:"total"`
+		PayerTotal    int    `json:"payer_total"`
+		Currency      string `json:"currency"`
+		PayerCurrency string `json:"payer_currency"`
+	} `json:"amount"`
+}
+
+// CreateNativeOrder Native下单，返回二维码链接
+func (c *WxPayClient) CreateNativeOrder(req *WxPayOrderRequest) (string, error) {
+	c.fillOrderRequest(req)
+	var resp struct {
+		CodeURL string `json:"code_url"`
+	}
+	if err := c.do(http.MethodPost, "/v3/pay/transactions/native", req, &resp); err != nil {
+		return "", err
+	}
+	return resp.CodeURL, nil
+}
+
+// CreateJSAPIOrder JSAPI/小程序下单，返回前端调起支付的参数
+func (c *WxPayClient) CreateJSAPIOrder(req *WxPayOrderRequest) (*WxPayJSAPIParams, error) {
+	c.fillOrderRequest(req)
+	if req.Payer == nil || req.Payer.OpenID == "" {
+		return nil, fmt.Errorf("JSAPI支付缺少openid")
+	}
+	var resp struct {
+		PrepayID string `json:"prepay_id"`
+	}
+	if err := c.do(http.MethodPost, "/v3/pay/transactions/jsapi", req, &resp); err != nil {
+		return nil, err
+	}
+
+	params := &WxPayJSAPIParams{
+		AppID:     req.AppID,
+		TimeStamp: strconv.FormatInt(time.Now().Unix(), 10),
+		NonceStr:  randomNonce(),
+		Package:   "prepay_id=" + resp.PrepayID,
+		SignType:  "RSA",
+	}
+	sign, err := c.sign(params.AppID + "\n" + params.TimeStamp + "\n" + params.NonceStr + "\n" + params.Package + "\n")
+	if err != nil {
+		return nil, err
+	}
+	params.PaySign = sign
+	return params, nil
+}
+
+// QueryOrder 按商户订单号查询订单
+func (c *WxPayClient) QueryOrder(outTradeNo string) (*WxPayTransaction, error) {
+	path := "/v3/pay/transactions/out-trade-no/" + url.PathEscape(outTradeNo) + "?mchid=" + url.QueryEscape(c.cfg.MchID)
+	var tx WxPayTransaction
+	if err := c.do(http.MethodGet, path, nil, &tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// CloseOrder 关闭订单
+func (c *WxPayClient) CloseOrder(outTradeNo string) error {
+	path := "/v3/pay/transactions/out-trade-no/" + url.PathEscape(outTradeNo) + "/close"
+	return c.do(http.MethodPost, path, map[string]string{"mchid": c.cfg.MchID}, nil)
+}
+
+// WxPayRefundRequest 退款请求
+type WxPayRefundRequest struct {
+	OutTradeNo  string `json:"out_trade_no"`
+	OutRefundNo string `json:"out_refund_no"`
+	Reason      string `json:"reason,omitempty"`
+	NotifyURL   string `json:"notify_url,omitempty"`
+	Amount      struct {
+		Refund   int    `json:"refund"`
+		Total    int    `json:"total"`
+		Currency string `json:"currency"`
+	} `json:"amount"`
+}
+
+// WxPayRefund 退款结果
+type WxPayRefund struct {
+	RefundID    string `json:"refund_id"`
+	OutRefundNo string `json:"out_refund_no"`
+	OutTradeNo  string `json:"out_trade_no"`
+	Status      string `json:"status"` // SUCCESS / CLOSED / PROCESSING / ABNORMAL
+	SuccessTime string `json:"success_time"`
+	Amount      struct {
+		Refund int `json:"refund"`
+		Total  int `json:"total"`
+	} `json:"amount"`
+}
+
+// Refund 申请退款，outRefundNo 为商户退款单号，同一单号重复提交只会退款一次
+func (c *WxPayClient) Refund(req *WxPayRefundRequest) (*WxPayRefund, error) {
+	if req.Amount.Currency == "" {
+		req.Amount.Currency = "CNY"
+	}
+	var refund WxPayRefund
+	if err := c.do(http.MethodPost, "/v3/refund/domestic/refunds", req, &refund); err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+func (c *WxPayClient) fillOrderRequest(req *WxPayOrderRequest) {
+	req.MchID = c.cfg.MchID
+	if req.AppID == "" {
+		req.AppID = c.cfg.AppID
+	}
+	if req.NotifyURL == "" {
+		req.NotifyURL = c.cfg.NotifyURL
+	}
+	if req.Amount.Currency == "" {
+		req.Amount.Currency = "CNY"
+	}
+}
+
+// ==================== 回调 ====================
+
+// WxPayNotification 回调通知
+type WxPayNotification struct {
+	ID           string `json:"id"`
+	CreateTime   string `json:"create_time"`
+	EventType    string `json:"event_type"`
+	ResourceType string `json:"resource_type"`
+	Summary      string `json:"summary"`
+	Resource     struct {
+		Algorithm      string `json:"algorithm"`
+		Ciphertext     string `json:"ciphertext"`
+		AssociatedData string `json:"associated_data"`
+		Nonce          string `json:"nonce"`
+		OriginalType   string `json:"original_type"`
+	} `json:"resource"`
+}
+
+// ParseNotification 验证回调签名并解密支付结果
+func (c *WxPayClient) ParseNotification(header http.Header, body []byte) (*WxPayNotification, *WxPayTransaction, error) {
+	if err := c.verifyResponse(header, body, true); err != nil {
+		return nil, nil, err
+	}
+
+	var notification WxPayNotification
+	if err := json.Unmarshal(body, &notification); err != nil {
+		return nil, nil, fmt.Errorf("解析回调通知失败: %w", err)
+	}
+	if notification.Resource.Algorithm != "AEAD_AES_256_GCM" {
+		return nil, nil, fmt.Errorf("不支持的回调加密算法: %s", notification.Resource.Algorithm)
+	}
+
+	plaintext, err := c.decryptResource(notification.Resource.Ciphertext, notification.Resource.Nonce, notification.Resource.AssociatedData)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var tx WxPayTransaction
+	if err := json.Unmarshal(plaintext, &tx); err != nil {
+		return nil, nil, fmt.Errorf("解析支付结果失败: %w", err)
+	}
+	return &notification, &tx, nil
+}
+
+// decryptResource 使用 APIv3 密钥 AES-256-GCM 解密回调资源/平台证书
+func (c *WxPayClient) decryptResource(ciphertext, nonce, associatedData string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("解码密文失败: %w", err)
+	}
+	block, err := aes.NewCipher([]byte(c.cfg.APIV3Key))
+	if err != nil {
+		return nil, fmt.Errorf("APIv3密钥无效: %w", err)
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(nonce))
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, []byte(nonce), data, []byte(associatedData))
+	if err != nil {
+		return nil, fmt.Errorf("解密回调资源失败: %w", err)
+	}
+	return plaintext, nil
+}
+
+// ==================== 签名与验签 ====================
+
+// do 发送签名请求并验证应答签名
+func (c *WxPayClient) do(method, path string, reqBody interface{}, respBody interface{}) error {
+	var payload []byte
+	if reqBody != nil {
+		var err error
+		payload, err = json.Marshal(reqBody)
+		if err != nil {
+			return fmt.Errorf("序列化微信支付请求失败: %w", err)
+		}
+	}
+
+	authorization, err := c.authorization(method, path, payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("创建微信支付请求失败: %w", err)
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求微信支付失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取微信支付应答失败: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		apiErr := &WxPayAPIError{StatusCode: resp.StatusCode}
+		json.Unmarshal(body, apiErr)
+		return apiErr
+	}
+
+	if err := c.verifyResponse(resp.Header, body, false); err != nil {
+		return err
+	}
+
+	if respBody != nil && len(body) > 0 {
+		if err := json.Unmarshal(body, respBody); err != nil {
+			return fmt.Errorf("解析微信支付应答失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// authorization 生成请求的 Authorization 头
+func (c *WxPayClient) authorization(method, path string, body []byte) (string, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := randomNonce()
+	message := method + "\n" + path + "\n" + timestamp + "\n" + nonce + "\n" + string(body) + "\n"
+	signature, err := c.sign(message)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`%s mchid="%s",nonce_str="%s",signature="%s",timestamp="%s",serial_no="%s"`,
+		wxPayAuthSchema, c.cfg.MchID, nonce, signature, timestamp, c.cfg.SerialNo), nil
+}
+
+// sign 使用商户私钥 SHA256-RSA 签名
+func (c *WxPayClient) sign(message string) (string, error) {
+	hashed := sha256.Sum256([]byte(message))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, c.privateKey, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", fmt.Errorf("微信支付签名失败: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// verifyResponse 使用平台证书验证应答/回调签名
+func (c *WxPayClient) verifyResponse(header http.Header, body []byte, checkTimestamp bool) error {
+	serial := header.Get("Wechatpay-Serial")
+	signature := header.Get("Wechatpay-Signature")
+	timestamp := header.Get("Wechatpay-Timestamp")
+	nonce := header.Get("Wechatpay-Nonce")
+	if serial == "" || signature == "" || timestamp == "" || nonce == "" {
+		return ErrWxPaySignature
+	}
+
+	if checkTimestamp {
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return ErrWxPaySignature
+		}
+		if skew := time.Since(time.Unix(ts, 0)); skew > wxPayNotifyMaxSkew || skew < -wxPayNotifyMaxSkew {
+			return fmt.Errorf("微信支付回调时间戳过期")
+		}
+	}
+
+	cert, err := c.platformCertificate(serial)
+	if err != nil {
+		return err
+	}
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("平台证书公钥类型错误")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrWxPaySignature
+	}
+	message := timestamp + "\n" + nonce + "\n" + string(body) + "\n"
+	hashed := sha256.Sum256([]byte(message))
+	if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hashed[:], sig); err != nil {
+		return ErrWxPaySignature
+	}
+	return nil
+}
+
+// platformCertificate 获取指定序列号的平台证书，本地不存在时重新下载
+func (c *WxPayClient) platformCertificate(serial string) (*x509.Certificate, error) {
+	serial = strings.ToUpper(serial)
+
+	c.certMu.RLock()
+	cert, ok := c.certs[serial]
+	c.certMu.RUnlock()
+	if ok {
+		if time.Now().After(cert.NotAfter) {
+			return nil, fmt.Errorf("微信支付平台证书已过期: %s", serial)
+		}
+		return cert, nil
+	}
+
+	if err := c.RefreshPlatformCertificates(); err != nil {
+		return nil, err
+	}
+
+	c.certMu.RLock()
+	cert, ok = c.certs[serial]
+	c.certMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("未找到微信支付平台证书: %s", serial)
+	}
+	return cert, nil
+}
+
+// RefreshPlatformCertificates 下载并解密平台证书
+// 下载结果中的证书需能验证本次应答签名，否则视为不可信
+func (c *WxPayClient) RefreshPlatformCertificates() error {
+	// 限制下载频率，避免伪造的证书序列号触发频繁下载
+	c.certMu.Lock()
+	if !c.lastRefresh.IsZero() && time.Since(c.lastRefresh) < time.Minute {
+		c.certMu.Unlock()
+		return nil
+	}
+	c.lastRefresh = time.Now()
+	c.certMu.Unlock()
+
+	path := "/v3/certificates"
+	authorization, err := c.authorization(http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("下载微信支付平台证书失败: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取平台证书应答失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("下载微信支付平台证书失败(%d): %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Data []struct {
+			SerialNo           string `json:"serial_no"`
+			EffectiveTime      string `json:"effective_time"`
+			ExpireTime         string `json:"expire_time"`
+			EncryptCertificate struct {
+				Algorithm      string `json:"algorithm"`
+				Nonce          string `json:"nonce"`
+				AssociatedData string `json:"associated_data"`
+				Ciphertext     string `json:"ciphertext"`
+			} `json:"encrypt_certificate"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("解析平台证书应答失败: %w", err)
+	}
+
+	downloaded := make(map[string]*x509.Certificate)
+	for _, item := range result.Data {
+		certPEM, err := c.decryptResource(item.EncryptCertificate.Ciphertext, item.EncryptCertificate.Nonce, item.EncryptCertificate.AssociatedData)
+		if err != nil {
+			return err
+		}
+		cert, err := parseCertificate(certPEM)
+		if err != nil {
+			return fmt.Errorf("解析平台证书失败: %w", err)
+		}
+		downloaded[strings.ToUpper(item.SerialNo)] = cert
+	}
+
+	// 使用下载到的证书验证本次应答（证书由 APIv3 密钥加密，能解密即说明来源可信，这里再做一次签名校验）
+	serial := strings.ToUpper(resp.Header.Get("Wechatpay-Serial"))
+	cert, ok := downloaded[serial]
+	if !ok {
+		c.certMu.RLock()
+		cert, ok = c.certs[serial]
+		c.certMu.RUnlock()
+	}
+	if !ok {
+		return fmt.Errorf("平台证书应答签名证书不存在: %s", serial)
+	}
+	if err := verifyWithCert(cert, resp.Header, body); err != nil {
+		return err
+	}
+
+	c.certMu.Lock()
+	for serialNo, cert := range downloaded {
+		c.certs[serialNo] = cert
+	}
+	c.certMu.Unlock()
+
+	repository.Infof("Refreshed %d WeChat Pay platform certificates", len(downloaded))
+	return nil
+}
+
+func verifyWithCert(cert *x509.Certificate, header http.Header, body []byte) error {
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("平台证书公钥类型错误")
+	}
+	sig, err := base64.StdEncoding.DecodeString(header.Get("Wechatpay-Signature"))
+	if err != nil {
+		return ErrWxPaySignature
+	}
+	message := header.Get("Wechatpay-Timestamp") + "\n" + header.Get("Wechatpay-Nonce") + "\n" + string(body) + "\n"
+	hashed := sha256.Sum256([]byte(message))
+	if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hashed[:], sig); err != nil {
+		return ErrWxPaySignature
+	}
+	return nil
+}
+
+// ==================== 工具函数 ====================
+
+// parseRSAPrivateKey 解析 PKCS#1 / PKCS#8 格式的RSA私钥
+func parseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		// 兼容不带PEM头的base64私钥
+		der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("私钥格式错误")
+		}
+		block = &pem.Block{Bytes: der}
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("私钥不是RSA类型")
+	}
+	return rsaKey, nil
+}
+
+// parseCertificate 解析PEM格式证书
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("证书格式错误")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// randomNonce 生成32位随机字符串
+func randomNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
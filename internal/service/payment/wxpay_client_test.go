@@ -0,0 +1,404 @@
+package payment
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"01agent_server/internal/config"
+	"01agent_server/internal/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+const (
+	testMchID    = "1900000001"
+	testAPIV3Key = "0123456789abcdef0123456789abcdef"
+)
+
+// fakeWxPay 模拟微信支付平台：持有平台私钥与证书，提供 /v3/certificates 下载
+type fakeWxPay struct {
+	t            *testing.T
+	server       *httptest.Server
+	merchantKey  *rsa.PrivateKey
+	platformKey  *rsa.PrivateKey
+	platformCert []byte // PEM
+	serial       string
+	certRequests int32
+}
+
+func newFakeWxPay(t *testing.T) (*fakeWxPay, *WxPayClient) {
+	t.Helper()
+	f := &fakeWxPay{t: t, serial: "5157F09EFDC096DE15EBE81A47057A7232F1B8E1"}
+
+	var err error
+	if f.merchantKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+	if f.platformKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+	serialNumber, _ := new(big.Int).SetString(f.serial, 16)
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      pkix.Name{CommonName: "Tenpay.com Root CA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &f.platformKey.PublicKey, f.platformKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.platformCert = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+	t.Cleanup(f.server.Close)
+
+	keyPath := filepath.Join(t.TempDir(), "apiclient_key.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(f.merchantKey)})
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewWxPayClient(config.WxPayConfig{
+		MchID:          testMchID,
+		SerialNo:       "MERCHANTSERIAL",
+		PrivateKeyPath: keyPath,
+		APIV3Key:       testAPIV3Key,
+		BaseURL:        f.server.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, client
+}
+
+func (f *fakeWxPay) handle(w http.ResponseWriter, r *http.Request) {
+	if !f.verifyMerchantSignature(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"code":"SIGN_ERROR","message":"签名错误"}`))
+		return
+	}
+	switch r.URL.Path {
+	case "/v3/certificates":
+		atomic.AddInt32(&f.certRequests, 1)
+		nonce, ciphertext := f.encrypt(f.platformCert, "certificate")
+		body, _ := json.Marshal(map[string]interface{}{
+			"data": []map[string]interface{}{{
+				"serial_no":      f.serial,
+				"effective_time": time.Now().Format(time.RFC3339),
+				"expire_time":    time.Now().Add(24 * time.Hour).Format(time.RFC3339),
+				"encrypt_certificate": map[string]string{
+					"algorithm":       "AEAD_AES_256_GCM",
+					"nonce":           nonce,
+					"associated_data": "certificate",
+					"ciphertext":      ciphertext,
+				},
+			}},
+		})
+		f.writeSigned(w, body)
+	case "/v3/pay/transactions/out-trade-no/T-OPEN/close":
+		for k, v := range f.signHeaders(nil, time.Now()) {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "/v3/pay/transactions/out-trade-no/T-PAID/close":
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":"ORDERPAID","message":"订单已支付"}`))
+	case "/v3/pay/transactions/out-trade-no/T-CLOSED/close":
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":"ORDER_CLOSED","message":"订单已关闭"}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"code":"NOT_FOUND","message":"not found"}`))
+	}
+}
+
+// verifyMerchantSignature 使用商户公钥校验请求的 Authorization 头
+func (f *fakeWxPay) verifyMerchantSignature(r *http.Request) bool {
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), wxPayAuthSchema+" ")
+	params := map[string]string{}
+	for _, kv := range strings.Split(auth, ",") {
+		if parts := strings.SplitN(kv, "=", 2); len(parts) == 2 {
+			params[parts[0]] = strings.Trim(parts[1], `"`)
+		}
+	}
+	if params["mchid"] != testMchID {
+		return false
+	}
+	body := new(bytes.Buffer)
+	body.ReadFrom(r.Body)
+	message := r.Method + "\n" + r.URL.RequestURI() + "\n" + params["timestamp"] + "\n" + params["nonce_str"] + "\n" + body.String() + "\n"
+	sig, _ := base64.StdEncoding.DecodeString(params["signature"])
+	hashed := sha256.Sum256([]byte(message))
+	return rsa.VerifyPKCS1v15(&f.merchantKey.PublicKey, crypto.SHA256, hashed[:], sig) == nil
+}
+
+// encrypt 使用 APIv3 密钥 AES-256-GCM 加密
+func (f *fakeWxPay) encrypt(plaintext []byte, associatedData string) (nonce, ciphertext string) {
+	block, _ := aes.NewCipher([]byte(testAPIV3Key))
+	gcm, _ := cipher.NewGCM(block)
+	nonce = "abcdefghijkl"
+	return nonce, base64.StdEncoding.EncodeToString(gcm.Seal(nil, []byte(nonce), plaintext, []byte(associatedData)))
+}
+
+// signHeaders 使用平台私钥为应答或回调签名
+func (f *fakeWxPay) signHeaders(body []byte, timestamp time.Time) http.Header {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	nonce := randomNonce()
+	hashed := sha256.Sum256([]byte(ts + "\n" + nonce + "\n" + string(body) + "\n"))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, f.platformKey, crypto.SHA256, hashed[:])
+	header := http.Header{}
+	header.Set("Wechatpay-Serial", f.serial)
+	header.Set("Wechatpay-Timestamp", ts)
+	header.Set("Wechatpay-Nonce", nonce)
+	header.Set("Wechatpay-Signature", base64.StdEncoding.EncodeToString(sig))
+	return header
+}
+
+func (f *fakeWxPay) writeSigned(w http.ResponseWriter, body []byte) {
+	for k, v := range f.signHeaders(body, time.Now()) {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// notification 构造支付成功回调的请求体
+func (f *fakeWxPay) notification(tx map[string]interface{}) []byte {
+	plaintext, _ := json.Marshal(tx)
+	nonce, ciphertext := f.encrypt(plaintext, "transaction")
+	body, _ := json.Marshal(map[string]interface{}{
+		"id":            "EV-2018022511223320873",
+		"create_time":   time.Now().Format(time.RFC3339),
+		"event_type":    "TRANSACTION.SUCCESS",
+		"resource_type": "encrypt-resource",
+		"summary":       "支付成功",
+		"resource": map[string]string{
+			"algorithm":       "AEAD_AES_256_GCM",
+			"ciphertext":      ciphertext,
+			"associated_data": "transaction",
+			"nonce":           nonce,
+			"original_type":   "transaction",
+		},
+	})
+	return body
+}
+
+func paidTransaction(tradeNo string, totalFen int) map[string]interface{} {
+	return map[string]interface{}{
+		"appid":            "wx0000000000000000",
+		"mchid":            testMchID,
+		"out_trade_no":     tradeNo,
+		"transaction_id":   "4200000000000000000000000001",
+		"trade_type":       "NATIVE",
+		"trade_state":      "SUCCESS",
+		"trade_state_desc": "支付成功",
+		"success_time":     "2026-10-16T10:00:00+08:00",
+		"amount":           map[string]interface{}{"total": totalFen, "payer_total": totalFen, "currency": "CNY"},
+	}
+}
+
+func TestWxPayParseNotification(t *testing.T) {
+	f, client := newFakeWxPay(t)
+	body := f.notification(paidTransaction("T202610160001", 9900))
+
+	// 本地没有平台证书，首次验签时下载并解密平台证书
+	notification, tx, err := client.ParseNotification(f.signHeaders(body, time.Now()), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notification.EventType != "TRANSACTION.SUCCESS" || tx.OutTradeNo != "T202610160001" || tx.Amount.Total != 9900 {
+		t.Fatalf("unexpected notification: %+v %+v", notification, tx)
+	}
+	if n := atomic.LoadInt32(&f.certRequests); n != 1 {
+		t.Fatalf("want 1 certificate download, got %d", n)
+	}
+
+	// 证书已缓存，再次验签不重新下载
+	if _, _, err := client.ParseNotification(f.signHeaders(body, time.Now()), body); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&f.certRequests); n != 1 {
+		t.Fatalf("certificate should be cached, got %d downloads", n)
+	}
+}
+
+func TestWxPayParseNotificationRejectsInvalidSignature(t *testing.T) {
+	f, client := newFakeWxPay(t)
+	body := f.notification(paidTransaction("T202610160002", 100))
+
+	tampered := bytes.Replace(body, []byte("TRANSACTION.SUCCESS"), []byte("TRANSACTION.SUCCEED"), 1)
+	if _, _, err := client.ParseNotification(f.signHeaders(body, time.Now()), tampered); !errors.Is(err, ErrWxPaySignature) {
+		t.Fatalf("tampered body: want ErrWxPaySignature, got %v", err)
+	}
+
+	header := f.signHeaders(body, time.Now())
+	header.Del("Wechatpay-Signature")
+	if _, _, err := client.ParseNotification(header, body); !errors.Is(err, ErrWxPaySignature) {
+		t.Fatalf("missing signature: want ErrWxPaySignature, got %v", err)
+	}
+
+	if _, _, err := client.ParseNotification(f.signHeaders(body, time.Now().Add(-10*time.Minute)), body); err == nil {
+		t.Fatal("stale timestamp: want error")
+	}
+
+	// 用其他私钥签名，即使序列号正确也无法通过验签
+	forger := *f
+	forger.platformKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+	if _, _, err := client.ParseNotification(forger.signHeaders(body, time.Now()), body); !errors.Is(err, ErrWxPaySignature) {
+		t.Fatalf("forged signature: want ErrWxPaySignature, got %v", err)
+	}
+}
+
+func TestWxPayDecryptResource(t *testing.T) {
+	f, client := newFakeWxPay(t)
+	nonce, ciphertext := f.encrypt([]byte(`{"out_trade_no":"T1"}`), "transaction")
+
+	plaintext, err := client.decryptResource(ciphertext, nonce, "transaction")
+	if err != nil || string(plaintext) != `{"out_trade_no":"T1"}` {
+		t.Fatalf("decrypt: %q %v", plaintext, err)
+	}
+	if _, err := client.decryptResource(ciphertext, nonce, "certificate"); err == nil {
+		t.Fatal("wrong associated data: want error")
+	}
+	raw, _ := base64.StdEncoding.DecodeString(ciphertext)
+	raw[0] ^= 0xff
+	if _, err := client.decryptResource(base64.StdEncoding.EncodeToString(raw), nonce, "transaction"); err == nil {
+		t.Fatal("tampered ciphertext: want error")
+	}
+}
+
+func TestWxPayRefreshPlatformCertificatesRequiresValidSignature(t *testing.T) {
+	f, client := newFakeWxPay(t)
+	// 证书下载应答签名无法用下载到的证书验证时不采用这些证书
+	f.server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		f.handle(rec, r)
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.Header().Set("Wechatpay-Signature", base64.StdEncoding.EncodeToString([]byte("forged")))
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+	})
+
+	if err := client.RefreshPlatformCertificates(); !errors.Is(err, ErrWxPaySignature) {
+		t.Fatalf("want ErrWxPaySignature, got %v", err)
+	}
+	client.certMu.RLock()
+	defer client.certMu.RUnlock()
+	if len(client.certs) != 0 {
+		t.Fatalf("untrusted certificates should not be stored: %d", len(client.certs))
+	}
+}
+
+func TestWxPayProviderClose(t *testing.T) {
+	_, client := newFakeWxPay(t)
+	provider := &wxPayProvider{client: client}
+
+	if err := provider.Close(&models.Trade{TradeNo: "T-OPEN"}); err != nil {
+		t.Fatalf("close open order: %v", err)
+	}
+	if err := provider.Close(&models.Trade{TradeNo: "T-CLOSED"}); err != nil {
+		t.Fatalf("already closed order should be treated as closed: %v", err)
+	}
+	var apiErr *WxPayAPIError
+	if err := provider.Close(&models.Trade{TradeNo: "T-PAID"}); !errors.As(err, &apiErr) || apiErr.Code != "ORDERPAID" {
+		t.Fatalf("paid order: want ORDERPAID error, got %v", err)
+	}
+}
+
+func newTestPaymentDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{DisableForeignKeyConstraintWhenMigrating: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Trade{}, &models.PaymentEvent{}, &models.UserProduction{}); err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+func TestWxPayDuplicateNotifyFulfilsOnce(t *testing.T) {
+	f, client := newFakeWxPay(t)
+	db := newTestPaymentDB(t)
+	svc := &Service{db: db, machine: NewTradeStateMachine()}
+	provider := &wxPayProvider{client: client}
+
+	trade := &models.Trade{
+		TradeNo:        "T202610160003",
+		UserID:         "u1",
+		Amount:         99,
+		TradeType:      string(models.TradeTypeRecharge),
+		PaymentChannel: string(models.PaymentChannelWxQR),
+		PaymentStatus:  string(models.PaymentStatusPending),
+		Title:          "会员",
+		CreatedAt:      time.Now(),
+	}
+	if err := db.Create(trade).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	body := f.notification(paidTransaction(trade.TradeNo, 9900))
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/payment/notify/wxpay", bytes.NewReader(body))
+		req.Header = f.signHeaders(body, time.Now())
+		if err := svc.HandleNotify(provider, req); err != nil {
+			t.Fatalf("notify %d: %v", i, err)
+		}
+	}
+
+	var latest models.Trade
+	db.First(&latest, trade.ID)
+	if latest.PaymentStatus != string(models.PaymentStatusSuccess) || latest.Version != 1 ||
+		latest.PaymentID == nil || *latest.PaymentID != "4200000000000000000000000001" {
+		t.Fatalf("unexpected trade: status=%s version=%d payment_id=%v", latest.PaymentStatus, latest.Version, latest.PaymentID)
+	}
+	var events []models.PaymentEvent
+	db.Where("trade_id = ?", trade.ID).Find(&events)
+	if len(events) != 1 || events[0].EventType != EventTradePaid {
+		t.Fatalf("want exactly one trade.paid event, got %+v", events)
+	}
+
+	// 权益已发放时重复投递的事件直接跳过
+	db.Create(&models.UserProduction{UserID: "u1", ProductionID: 1, TradeID: trade.ID, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	if err := svc.handleTradePaid(&events[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	// 金额与订单不一致的通知被拒绝
+	other := *trade
+	other.ID, other.TradeNo, other.Version, other.PaymentStatus = 0, "T202610160004", 0, string(models.PaymentStatusPending)
+	db.Create(&other)
+	body = f.notification(paidTransaction(other.TradeNo, 1))
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/payment/notify/wxpay", bytes.NewReader(body))
+	req.Header = f.signHeaders(body, time.Now())
+	if err := svc.HandleNotify(provider, req); !errors.Is(err, ErrTradeAmountMismatch) {
+		t.Fatalf("want ErrTradeAmountMismatch, got %v", err)
+	}
+}
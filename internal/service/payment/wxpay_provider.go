@@ -0,0 +1,179 @@
+package payment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"01agent_server/internal/config"
+	"01agent_server/internal/models"
+)
+
+// wxPayProvider 微信支付服务商
+// wx_qr 使用 Native 支付；wx_pub 使用公众号 JSAPI 支付；wx_lite 使用小程序支付
+type wxPayProvider struct {
+	client *WxPayClient
+}
+
+func (p *wxPayProvider) Name() string {
+	return ProviderWxPay
+}
+
+func (p *wxPayProvider) Supports(channel models.PaymentChannel) bool {
+	return ProviderNameOf(channel) == ProviderWxPay
+}
+
+func (p *wxPayProvider) CreateOrder(trade *models.Trade, opts *OrderOptions) (*OrderResult, error) {
+	channel := models.PaymentChannel(trade.PaymentChannel)
+	if !p.Supports(channel) {
+		return nil, ErrUnsupportedPaymentChannel
+	}
+
+	req := &WxPayOrderRequest{
+		Description: trade.Title,
+		OutTradeNo:  trade.TradeNo,
+		TimeExpire:  trade.CreatedAt.Add(OrderExpire).Format(time.RFC3339),
+		Amount:      WxPayAmount{Total: yuanToFen(trade.Amount)},
+	}
+	result := &OrderResult{
+		TradeNo: trade.TradeNo,
+		Amount:  trade.Amount,
+		Title:   trade.Title,
+	}
+
+	if channel == models.PaymentChannelWxQR {
+		codeURL, err := p.client.CreateNativeOrder(req)
+		if err != nil {
+			return nil, err
+		}
+		result.CodeURL = codeURL
+		return result, nil
+	}
+
+	if opts == nil || opts.OpenID == "" {
+		return nil, ErrOpenIDRequired
+	}
+	if config.AppConfig != nil {
+		if channel == models.PaymentChannelWxPub {
+			req.AppID = config.AppConfig.WechatGzh.AppID
+		} else {
+			req.AppID = config.AppConfig.Wechat.AppID
+		}
+	}
+	req.Payer = &WxPayPayer{OpenID: opts.OpenID}
+	params, err := p.client.CreateJSAPIOrder(req)
+	if err != nil {
+		return nil, err
+	}
+	result.JSAPIParams = params
+	return result, nil
+}
+
+func (p *wxPayProvider) Query(trade *models.Trade) (*TradeResult, error) {
+	tx, err := p.client.QueryOrder(trade.TradeNo)
+	if err != nil {
+		var apiErr *WxPayAPIError
+		if errors.As(err, &apiErr) && apiErr.Code == "ORDER_NOT_EXIST" {
+			return &TradeResult{TradeNo: trade.TradeNo, State: ProviderTradeNotFound}, nil
+		}
+		return nil, err
+	}
+	return p.toTradeResult(tx)
+}
+
+func (p *wxPayProvider) Close(trade *models.Trade) error {
+	err := p.client.CloseOrder(trade.TradeNo)
+	var apiErr *WxPayAPIError
+	if errors.As(err, &apiErr) && (apiErr.Code == "ORDER_CLOSED" || apiErr.Code == "ORDER_NOT_EXIST") {
+		return nil
+	}
+	return err
+}
+
+func (p *wxPayProvider) Refund(trade *models.Trade, req *RefundRequest) (*RefundResult, error) {
+	refundReq := &WxPayRefundRequest{
+		OutTradeNo:  trade.TradeNo,
+		OutRefundNo: req.RefundNo,
+		Reason:      req.Reason,
+	}
+	refundReq.Amount.Refund = yuanToFen(req.Amount)
+	refundReq.Amount.Total = yuanToFen(trade.Amount)
+
+	refund, err := p.client.Refund(refundReq)
+	if err != nil {
+		return nil, err
+	}
+	if refund.Status == "CLOSED" || refund.Status == "ABNORMAL" {
+		return nil, fmt.Errorf("微信支付退款失败: %s", refund.Status)
+	}
+	return &RefundResult{
+		RefundID: refund.RefundID,
+		Success:  refund.Status == "SUCCESS",
+	}, nil
+}
+
+// VerifyNotify 验签并解密支付结果通知，非支付成功事件返回 nil
+func (p *wxPayProvider) VerifyNotify(r *http.Request) (*TradeResult, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取回调请求失败: %w", err)
+	}
+
+	notification, tx, err := p.client.ParseNotification(r.Header, body)
+	if err != nil {
+		return nil, err
+	}
+	if notification.EventType != "TRANSACTION.SUCCESS" {
+		return nil, nil
+	}
+	return p.toTradeResult(tx)
+}
+
+// AckNotify 成功返回 204，失败返回 5XX 及 {"code":"FAIL","message":"..."} 以触发重试
+func (p *wxPayProvider) AckNotify(w http.ResponseWriter, err error) {
+	if err == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(map[string]string{"code": "FAIL", "message": err.Error()})
+}
+
+func (p *wxPayProvider) toTradeResult(tx *WxPayTransaction) (*TradeResult, error) {
+	if tx.MchID != p.client.MchID() {
+		return nil, fmt.Errorf("商户号不匹配: %s", tx.MchID)
+	}
+
+	result := &TradeResult{
+		TradeNo:    tx.OutTradeNo,
+		PaymentID:  tx.TransactionID,
+		PaidAmount: float64(tx.Amount.Total) / 100,
+	}
+	switch tx.TradeState {
+	case "SUCCESS":
+		result.State = ProviderTradePaid
+	case "REFUND":
+		result.State = ProviderTradeRefunded
+	case "CLOSED", "REVOKED":
+		result.State = ProviderTradeClosed
+	default: // NOTPAY / USERPAYING / PAYERROR
+		result.State = ProviderTradeNotPaid
+	}
+
+	if paidAt, err := time.Parse(time.RFC3339, tx.SuccessTime); err == nil {
+		result.PaidAt = paidAt
+	} else {
+		result.PaidAt = time.Now()
+	}
+	return result, nil
+}
+
+// yuanToFen 元转分
+func yuanToFen(amount float64) int {
+	return int(math.Round(amount * 100))
+}
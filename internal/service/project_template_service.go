@@ -0,0 +1,789 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"01agent_server/internal/models"
+	"01agent_server/internal/models/short_post"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/tools"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxTemplateMembers 团队模板最多可添加的成员数
+const maxTemplateMembers = 50
+
+var (
+	// ErrTemplateNotFound 模板不存在或无权访问
+	ErrTemplateNotFound = errors.New("模板不存在")
+	// ErrTemplateSourceNotFound 发布模板的工程不存在或还没有内容
+	ErrTemplateSourceNotFound = errors.New("工程不存在或还没有保存内容")
+	// ErrTemplateInvalidVisibility 可见范围无效
+	ErrTemplateInvalidVisibility = errors.New("可见范围只能是 private、team 或 public")
+	// ErrTemplateMemberNotFound 团队成员不存在
+	ErrTemplateMemberNotFound = errors.New("团队成员不存在")
+	// ErrTooManyTemplateMembers 团队成员超过上限
+	ErrTooManyTemplateMembers = fmt.Errorf("团队成员不能超过 %d 人", maxTemplateMembers)
+	// ErrTemplateNotPending 模板不在待审核状态
+	ErrTemplateNotPending = errors.New("模板不在待审核状态")
+)
+
+// slotPattern 内容中的占位符，如 {{title}}、{{image_1}}
+var slotPattern = regexp.MustCompile(`\{\{\s*([A-Za-z][A-Za-z0-9_]*)\s*\}\}`)
+
+// ProjectTemplateService 短图文工程模板服务
+type ProjectTemplateService struct {
+	db *gorm.DB
+}
+
+// NewProjectTemplateService 创建工程模板服务
+func NewProjectTemplateService() *ProjectTemplateService {
+	return &ProjectTemplateService{
+		db: repository.DB,
+	}
+}
+
+// PublishTemplateParams 发布模板参数
+type PublishTemplateParams struct {
+	ProjectID   string
+	Name        string
+	Description *string
+	Tags        []string
+	Visibility  short_post.TemplateVisibility
+	MemberIDs   []string // 团队模板的成员
+	Thumbnail   *string  // 为空时使用工程缩略图或封面
+	IsAdmin     bool     // 管理员发布的公开模板无需审核
+}
+
+// UpdateTemplateParams 修改模板参数，为 nil 的字段保持不变
+type UpdateTemplateParams struct {
+	Name           *string
+	Description    *string
+	Tags           []string
+	Visibility     *short_post.TemplateVisibility
+	MemberIDs      []string
+	Thumbnail      *string
+	RefreshContent bool // 重新从来源工程的最新内容生成模板
+	IsAdmin        bool
+}
+
+// TemplateQuery 模板列表查询条件
+type TemplateQuery struct {
+	Scope       string // mine / team / public，为空时返回所有可见模板
+	ProjectType string
+	Tag         string
+	Keyword     string
+	OrderBy     string // use_count / created_at
+	Page        int
+	PageSize    int
+}
+
+// TemplateCopywriting 使用模板时填充占位符的文案
+type TemplateCopywriting struct {
+	Title   string   `json:"title"`
+	Content string   `json:"content"`
+	Topics  []string `json:"topics"`
+	Images  []string `json:"images"`
+}
+
+// UseTemplateParams 使用模板创建工程参数
+type UseTemplateParams struct {
+	Name        string
+	ThreadID    *string
+	Copywriting *TemplateCopywriting
+	Slots       map[string]string // 直接指定的占位符内容，优先于文案
+}
+
+// Publish 将工程的最新内容发布为模板
+func (s *ProjectTemplateService) Publish(userID string, params PublishTemplateParams) (*short_post.ShortPostTemplate, error) {
+	if !validVisibility(params.Visibility) {
+		return nil, ErrTemplateInvalidVisibility
+	}
+	project, content, err := s.loadSource(userID, params.ProjectID, params.IsAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &short_post.ShortPostTemplate{
+		ID:              uuid.New().String(),
+		UserID:          userID,
+		SourceProjectID: project.ID,
+		Name:            params.Name,
+		Description:     params.Description,
+		ProjectType:     project.ProjectType,
+		Tags:            tagsJSON(params.Tags),
+		Thumbnail:       params.Thumbnail,
+		Visibility:      params.Visibility,
+		ReviewStatus:    initialReviewStatus(params.Visibility, params.IsAdmin),
+	}
+	if template.Thumbnail == nil {
+		template.Thumbnail = project.Thumbnail
+	}
+	if template.Thumbnail == nil {
+		template.Thumbnail = project.CoverImage
+	}
+	applyTemplateContent(template, content)
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(template).Error; err != nil {
+			return err
+		}
+		if params.Visibility == short_post.TemplateVisibilityTeam {
+			return s.replaceMembers(tx, template.ID, userID, params.MemberIDs)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// Update 修改模板信息，改为公开或公开模板更新内容后需要重新审核
+func (s *ProjectTemplateService) Update(userID, templateID string, params UpdateTemplateParams) (*short_post.ShortPostTemplate, error) {
+	var template short_post.ShortPostTemplate
+	if err := s.db.Where("id = ? AND user_id = ?", templateID, userID).First(&template).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTemplateNotFound
+		}
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if params.Name != nil {
+		updates["name"] = *params.Name
+	}
+	if params.Description != nil {
+		updates["description"] = *params.Description
+	}
+	if params.Tags != nil {
+		updates["tags"] = tagsJSON(params.Tags)
+	}
+	if params.Thumbnail != nil {
+		updates["thumbnail"] = *params.Thumbnail
+	}
+	visibility := template.Visibility
+	if params.Visibility != nil && *params.Visibility != template.Visibility {
+		if !validVisibility(*params.Visibility) {
+			return nil, ErrTemplateInvalidVisibility
+		}
+		visibility = *params.Visibility
+		updates["visibility"] = visibility
+		updates["review_status"] = initialReviewStatus(visibility, params.IsAdmin)
+	}
+	if params.RefreshContent {
+		_, content, err := s.loadSource(userID, template.SourceProjectID, params.IsAdmin)
+		if err != nil {
+			return nil, err
+		}
+		refreshed := short_post.ShortPostTemplate{}
+		applyTemplateContent(&refreshed, content)
+		updates["canvas_config"] = refreshed.CanvasConfig
+		updates["frames_data"] = refreshed.FramesData
+		updates["elements_data"] = refreshed.ElementsData
+		updates["metadata"] = refreshed.Metadata
+		updates["slots"] = refreshed.Slots
+		updates["frame_count"] = refreshed.FrameCount
+		if visibility == short_post.TemplateVisibilityPublic {
+			updates["review_status"] = initialReviewStatus(visibility, params.IsAdmin)
+		}
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&template).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if visibility != short_post.TemplateVisibilityTeam {
+			return tx.Where("template_id = ?", templateID).Delete(&short_post.ShortPostTemplateMember{}).Error
+		}
+		if params.MemberIDs != nil {
+			return s.replaceMembers(tx, templateID, userID, params.MemberIDs)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.db.Where("id = ?", templateID).First(&template)
+	return &template, nil
+}
+
+// Delete 删除自己发布的模板，已使用模板创建的工程不受影响
+func (s *ProjectTemplateService) Delete(userID, templateID string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND user_id = ?", templateID, userID).Delete(&short_post.ShortPostTemplate{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTemplateNotFound
+		}
+		return tx.Where("template_id = ?", templateID).Delete(&short_post.ShortPostTemplateMember{}).Error
+	})
+}
+
+// Get 获取用户可见的模板
+func (s *ProjectTemplateService) Get(userID, templateID string) (*short_post.ShortPostTemplate, error) {
+	var template short_post.ShortPostTemplate
+	err := s.visible(s.db.Model(&short_post.ShortPostTemplate{}), userID).
+		Where("id = ?", templateID).First(&template).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// Members 团队模板的成员用户ID
+func (s *ProjectTemplateService) Members(templateID string) ([]string, error) {
+	var userIDs []string
+	err := s.db.Model(&short_post.ShortPostTemplateMember{}).
+		Where("template_id = ?", templateID).
+		Order("id ASC").
+		Pluck("user_id", &userIDs).Error
+	return userIDs, err
+}
+
+// List 用户可见的模板列表（不含内容）
+func (s *ProjectTemplateService) List(userID string, q TemplateQuery) ([]short_post.ShortPostTemplate, int64, error) {
+	query := s.db.Model(&short_post.ShortPostTemplate{})
+	switch q.Scope {
+	case "mine":
+		query = query.Where("user_id = ?", userID)
+	case "team":
+		query = query.Where("visibility = ? AND (user_id = ? OR id IN (?))", short_post.TemplateVisibilityTeam, userID,
+			s.db.Model(&short_post.ShortPostTemplateMember{}).Select("template_id").Where("user_id = ?", userID))
+	case "public":
+		query = query.Where("visibility = ? AND review_status = ?", short_post.TemplateVisibilityPublic, short_post.TemplateReviewApproved)
+	default:
+		query = s.visible(query, userID)
+	}
+	if q.ProjectType != "" {
+		query = query.Where("project_type = ?", q.ProjectType)
+	}
+	if q.Tag != "" {
+		// tags 为 JSON 字符串数组，按带引号的完整标签匹配
+		quoted, _ := json.Marshal(q.Tag)
+		query = query.Where("tags LIKE ?", "%"+string(quoted)+"%")
+	}
+	if q.Keyword != "" {
+		query = query.Where("name LIKE ? OR description LIKE ?", "%"+q.Keyword+"%", "%"+q.Keyword+"%")
+	}
+	return s.page(query, q.OrderBy, q.Page, q.PageSize)
+}
+
+// ListForReview 管理员查看待审核（或指定审核状态）的公开模板
+func (s *ProjectTemplateService) ListForReview(status short_post.TemplateReviewStatus, page, pageSize int) ([]short_post.ShortPostTemplate, int64, error) {
+	if status == "" {
+		status = short_post.TemplateReviewPending
+	}
+	query := s.db.Model(&short_post.ShortPostTemplate{}).
+		Where("visibility = ? AND review_status = ?", short_post.TemplateVisibilityPublic, status)
+	return s.page(query, "created_at", page, pageSize)
+}
+
+// Review 审核公开模板，结果通过系统通知告知发布者
+func (s *ProjectTemplateService) Review(adminID, templateID string, approved bool, note string) (*short_post.ShortPostTemplate, error) {
+	status := short_post.TemplateReviewRejected
+	if approved {
+		status = short_post.TemplateReviewApproved
+	}
+	now := time.Now()
+	updates := map[string]interface{}{
+		"review_status": status,
+		"reviewed_by":   adminID,
+		"reviewed_at":   now,
+		"review_note":   nil,
+	}
+	if note != "" {
+		updates["review_note"] = note
+	}
+	result := s.db.Model(&short_post.ShortPostTemplate{}).
+		Where("id = ? AND visibility = ? AND review_status = ?", templateID, short_post.TemplateVisibilityPublic, short_post.TemplateReviewPending).
+		Updates(updates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		s.db.Model(&short_post.ShortPostTemplate{}).Where("id = ?", templateID).Count(&count)
+		if count == 0 {
+			return nil, ErrTemplateNotFound
+		}
+		return nil, ErrTemplateNotPending
+	}
+
+	var template short_post.ShortPostTemplate
+	if err := s.db.Where("id = ?", templateID).First(&template).Error; err != nil {
+		return nil, err
+	}
+	title := "模板审核通过"
+	content := fmt.Sprintf("你发布的模板《%s》已通过审核，所有用户都可以使用。", template.Name)
+	if !approved {
+		title = "模板审核未通过"
+		content = fmt.Sprintf("你发布的模板《%s》未通过审核，目前仅自己可见。", template.Name)
+		if note != "" {
+			content += "原因：" + note
+		}
+	}
+	notification := &models.SystemNotification{
+		NotificationID: uuid.New().String(),
+		UserID:         tools.StringPtr(template.UserID),
+		Type:           "system",
+		Title:          title,
+		Content:        content,
+		Status:         "unread",
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := s.db.Create(notification).Error; err != nil {
+		repository.Warnf("Create template review notification failed: %v", err)
+	}
+	return &template, nil
+}
+
+// TemplateSource 创建工程使用的内容来源：模板，或者作为模板使用的工程最新内容
+type TemplateSource struct {
+	TemplateID   string // 来源为模板时记录使用次数
+	ProjectID    string
+	Name         string
+	ProjectType  short_post.ProjectType
+	CanvasConfig *string
+	FramesData   *string
+	ElementsData *string
+	Metadata     *string
+	FrameCount   int
+}
+
+// ResolveSource 读取模板或工程作为创建工程的内容来源，templateID 优先
+// 模板需要对用户可见，工程只能是用户自己的
+func (s *ProjectTemplateService) ResolveSource(userID, templateID, projectID string) (*TemplateSource, error) {
+	if templateID != "" {
+		template, err := s.Get(userID, templateID)
+		if err != nil {
+			return nil, err
+		}
+		return &TemplateSource{
+			TemplateID:   template.ID,
+			Name:         template.Name,
+			ProjectType:  template.ProjectType,
+			CanvasConfig: template.CanvasConfig,
+			FramesData:   template.FramesData,
+			ElementsData: template.ElementsData,
+			Metadata:     template.Metadata,
+			FrameCount:   template.FrameCount,
+		}, nil
+	}
+
+	project, content, err := s.loadSource(userID, projectID, false)
+	if err != nil {
+		return nil, err
+	}
+	return &TemplateSource{
+		ProjectID:    project.ID,
+		Name:         project.Name,
+		ProjectType:  project.ProjectType,
+		CanvasConfig: content.CanvasConfig,
+		FramesData:   content.FramesData,
+		ElementsData: content.ElementsData,
+		Metadata:     content.Metadata,
+		FrameCount:   countFrames(content.FramesData),
+	}, nil
+}
+
+// Use 使用模板创建新工程，占位符按文案和 slots 替换，返回未填充的占位符
+func (s *ProjectTemplateService) Use(userID, templateID string, params UseTemplateParams) (*short_post.ShortPostProject, []string, error) {
+	source, err := s.ResolveSource(userID, templateID, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	var project *short_post.ShortPostProject
+	var unfilled []string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		project, unfilled, err = s.Instantiate(tx, userID, source, params)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return project, unfilled, nil
+}
+
+// Instantiate 在事务中由内容来源创建工程、内容与文案，返回未填充的占位符
+func (s *ProjectTemplateService) Instantiate(tx *gorm.DB, userID string, source *TemplateSource, params UseTemplateParams) (*short_post.ShortPostProject, []string, error) {
+	values := slotValues(params.Copywriting, params.Slots)
+	unfilled := map[string]bool{}
+	framesData, err := fillSlots(source.FramesData, values, unfilled)
+	if err != nil {
+		return nil, nil, fmt.Errorf("模板内容解析失败: %w", err)
+	}
+	elementsData, err := fillSlots(source.ElementsData, values, unfilled)
+	if err != nil {
+		return nil, nil, fmt.Errorf("模板内容解析失败: %w", err)
+	}
+
+	name := params.Name
+	if name == "" {
+		name = source.Name
+		if params.Copywriting != nil && params.Copywriting.Title != "" {
+			name = params.Copywriting.Title
+		}
+	}
+	origin := map[string]interface{}{}
+	if source.TemplateID != "" {
+		origin["template_id"] = source.TemplateID
+	} else {
+		origin["template_project_id"] = source.ProjectID
+	}
+	metadata, _ := json.Marshal(origin)
+	now := time.Now()
+	project := &short_post.ShortPostProject{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		ThreadID:    params.ThreadID,
+		Name:        name,
+		ProjectType: source.ProjectType,
+		Metadata:    tools.StringPtr(string(metadata)),
+		Status:      short_post.ProjectStatusDraft,
+		FrameCount:  source.FrameCount,
+		SavedAt:     &now,
+	}
+	content := &short_post.ShortPostProjectContent{
+		ID:           uuid.New().String(),
+		ProjectID:    project.ID,
+		CanvasConfig: source.CanvasConfig,
+		FramesData:   framesData,
+		ElementsData: elementsData,
+		Metadata:     source.Metadata,
+		Version:      1,
+		IsLatest:     true,
+		Source:       short_post.ContentSourceTemplate,
+	}
+
+	if err := tx.Create(project).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Create(content).Error; err != nil {
+		return nil, nil, err
+	}
+	if cw := params.Copywriting; cw != nil {
+		copywriting := &short_post.ShortPostProjectCopywriting{
+			ID:        uuid.New().String(),
+			ProjectID: project.ID,
+			Title:     nonEmptyPtr(cw.Title),
+			Content:   nonEmptyPtr(cw.Content),
+			Topics:    jsonArrayPtr(cw.Topics),
+			Images:    jsonArrayPtr(cw.Images),
+		}
+		if err := tx.Create(copywriting).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+	if source.TemplateID != "" {
+		if err := tx.Model(&short_post.ShortPostTemplate{}).Where("id = ?", source.TemplateID).Updates(map[string]interface{}{
+			"use_count":    gorm.Expr("use_count + 1"),
+			"last_used_at": now,
+		}).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+
+	missing := make([]string, 0, len(unfilled))
+	for key := range unfilled {
+		missing = append(missing, key)
+	}
+	sort.Strings(missing)
+	return project, missing, nil
+}
+
+// visible 用户可见的模板：自己发布的、审核通过的公开模板、自己是成员的团队模板
+func (s *ProjectTemplateService) visible(query *gorm.DB, userID string) *gorm.DB {
+	members := s.db.Model(&short_post.ShortPostTemplateMember{}).Select("template_id").Where("user_id = ?", userID)
+	return query.Where("user_id = ? OR (visibility = ? AND review_status = ?) OR (visibility = ? AND id IN (?))",
+		userID,
+		short_post.TemplateVisibilityPublic, short_post.TemplateReviewApproved,
+		short_post.TemplateVisibilityTeam, members)
+}
+
+// page 分页查询模板列表，列表不返回模板内容
+func (s *ProjectTemplateService) page(query *gorm.DB, orderBy string, page, pageSize int) ([]short_post.ShortPostTemplate, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	order := "created_at DESC"
+	if orderBy == "use_count" {
+		order = "use_count DESC, created_at DESC"
+	}
+	var templates []short_post.ShortPostTemplate
+	err := query.Omit("canvas_config", "frames_data", "elements_data", "metadata").
+		Order(order).Offset((page - 1) * pageSize).Limit(pageSize).
+		Find(&templates).Error
+	return templates, total, err
+}
+
+// loadSource 读取发布模板的工程及其最新内容，管理员可以发布任意工程
+func (s *ProjectTemplateService) loadSource(userID, projectID string, isAdmin bool) (*short_post.ShortPostProject, *short_post.ShortPostProjectContent, error) {
+	var project short_post.ShortPostProject
+	query := s.db.Where("id = ?", projectID)
+	if !isAdmin {
+		query = query.Where("user_id = ?", userID)
+	}
+	if err := query.First(&project).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrTemplateSourceNotFound
+		}
+		return nil, nil, err
+	}
+	var content short_post.ShortPostProjectContent
+	if err := s.db.Where("project_id = ? AND is_latest = ?", project.ID, true).First(&content).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrTemplateSourceNotFound
+		}
+		return nil, nil, err
+	}
+	return &project, &content, nil
+}
+
+// replaceMembers 替换团队模板的成员，发布者本人不需要加入
+func (s *ProjectTemplateService) replaceMembers(tx *gorm.DB, templateID, ownerID string, memberIDs []string) error {
+	seen := map[string]bool{ownerID: true}
+	var unique []string
+	for _, id := range memberIDs {
+		id = strings.TrimSpace(id)
+		if id != "" && !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) > maxTemplateMembers {
+		return ErrTooManyTemplateMembers
+	}
+	if len(unique) > 0 {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("user_id IN ?", unique).Count(&count).Error; err != nil {
+			return err
+		}
+		if int(count) != len(unique) {
+			return ErrTemplateMemberNotFound
+		}
+	}
+
+	if err := tx.Where("template_id = ?", templateID).Delete(&short_post.ShortPostTemplateMember{}).Error; err != nil {
+		return err
+	}
+	for _, id := range unique {
+		if err := tx.Create(&short_post.ShortPostTemplateMember{TemplateID: templateID, UserID: id}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyTemplateContent 复制工程内容到模板，并记录其中的占位符
+func applyTemplateContent(template *short_post.ShortPostTemplate, content *short_post.ShortPostProjectContent) {
+	template.CanvasConfig = content.CanvasConfig
+	template.FramesData = content.FramesData
+	template.ElementsData = content.ElementsData
+	template.Metadata = content.Metadata
+	template.FrameCount = countFrames(content.FramesData)
+
+	found := map[string]bool{}
+	for _, data := range []*string{content.FramesData, content.ElementsData} {
+		var value interface{}
+		if data != nil && json.Unmarshal([]byte(*data), &value) == nil {
+			collectSlots(value, found)
+		}
+	}
+	slots := make([]string, 0, len(found))
+	for key := range found {
+		slots = append(slots, key)
+	}
+	sort.Strings(slots)
+	template.Slots = jsonArrayPtr(slots)
+}
+
+// collectSlots 查找内容中的占位符，包括文本中的 {{key}} 与元素的 slot 属性
+func collectSlots(value interface{}, found map[string]bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if slot, ok := v["slot"].(string); ok && slot != "" {
+			found[slot] = true
+		}
+		for _, child := range v {
+			collectSlots(child, found)
+		}
+	case []interface{}:
+		for _, child := range v {
+			collectSlots(child, found)
+		}
+	case string:
+		for _, match := range slotPattern.FindAllStringSubmatch(v, -1) {
+			found[match[1]] = true
+		}
+	}
+}
+
+// slotValues 由文案生成占位符内容：
+// title、content、topics（#话题 空格分隔）、topic_N、paragraph_N（正文按行拆分）、image_N，序号从 1 开始
+func slotValues(cw *TemplateCopywriting, overrides map[string]string) map[string]string {
+	values := map[string]string{}
+	if cw != nil {
+		values["title"] = cw.Title
+		values["content"] = cw.Content
+
+		var topics []string
+		for i, topic := range cw.Topics {
+			topic = strings.TrimPrefix(strings.TrimSpace(topic), "#")
+			values["topic_"+strconv.Itoa(i+1)] = topic
+			if topic != "" {
+				topics = append(topics, "#"+topic)
+			}
+		}
+		values["topics"] = strings.Join(topics, " ")
+
+		n := 0
+		for _, line := range strings.Split(cw.Content, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				n++
+				values["paragraph_"+strconv.Itoa(n)] = line
+			}
+		}
+		for i, image := range cw.Images {
+			values["image_"+strconv.Itoa(i+1)] = image
+		}
+	}
+	for key, value := range overrides {
+		values[key] = value
+	}
+	return values
+}
+
+// fillSlots 替换 JSON 内容中的占位符，没有内容的文本占位符替换为空，记录到 unfilled
+func fillSlots(data *string, values map[string]string, unfilled map[string]bool) (*string, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(*data), &value); err != nil {
+		return nil, err
+	}
+	filled, err := json.Marshal(fillSlotValue(value, values, unfilled))
+	if err != nil {
+		return nil, err
+	}
+	return tools.StringPtr(string(filled)), nil
+}
+
+func fillSlotValue(value interface{}, values map[string]string, unfilled map[string]bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = fillSlotValue(child, values, unfilled)
+		}
+		// 带 slot 属性的元素：图片替换地址，其他元素替换文本
+		if slot, ok := v["slot"].(string); ok && slot != "" {
+			replacement, ok := values[slot]
+			if !ok || replacement == "" {
+				unfilled[slot] = true
+				return v
+			}
+			field := "text"
+			candidates := []string{"text", "content"}
+			if strings.HasPrefix(slot, "image") || v["type"] == "image" {
+				field = "src"
+				candidates = []string{"src", "url", "imageUrl", "image_url"}
+			}
+			for _, key := range candidates {
+				if _, exists := v[key]; exists {
+					field = key
+					break
+				}
+			}
+			v[field] = replacement
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = fillSlotValue(child, values, unfilled)
+		}
+		return v
+	case string:
+		return slotPattern.ReplaceAllStringFunc(v, func(token string) string {
+			key := slotPattern.FindStringSubmatch(token)[1]
+			replacement, ok := values[key]
+			if !ok || replacement == "" {
+				unfilled[key] = true
+			}
+			return replacement
+		})
+	}
+	return value
+}
+
+func validVisibility(v short_post.TemplateVisibility) bool {
+	switch v {
+	case short_post.TemplateVisibilityPrivate, short_post.TemplateVisibilityTeam, short_post.TemplateVisibilityPublic:
+		return true
+	}
+	return false
+}
+
+// initialReviewStatus 公开模板需要管理员审核，管理员自己发布的直接通过
+func initialReviewStatus(v short_post.TemplateVisibility, isAdmin bool) short_post.TemplateReviewStatus {
+	if v != short_post.TemplateVisibilityPublic {
+		return short_post.TemplateReviewNone
+	}
+	if isAdmin {
+		return short_post.TemplateReviewApproved
+	}
+	return short_post.TemplateReviewPending
+}
+
+// tagsJSON 去除空白与重复后的标签 JSON
+func tagsJSON(tags []string) *string {
+	seen := map[string]bool{}
+	cleaned := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			cleaned = append(cleaned, tag)
+		}
+	}
+	return jsonArrayPtr(cleaned)
+}
+
+func jsonArrayPtr(items []string) *string {
+	if items == nil {
+		items = []string{}
+	}
+	data, _ := json.Marshal(items)
+	return tools.StringPtr(string(data))
+}
+
+func nonEmptyPtr(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
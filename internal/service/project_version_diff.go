@@ -0,0 +1,260 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"01agent_server/internal/models/short_post"
+)
+
+// ProjectVersionDiff 两个工程版本之间的结构差异
+type ProjectVersionDiff struct {
+	From            int         `json:"from"`
+	To              int         `json:"to"`
+	CanvasChanged   bool        `json:"canvas_changed"`
+	MetadataChanged bool        `json:"metadata_changed"`
+	Frames          NodeDiff    `json:"frames"`
+	Elements        NodeDiff    `json:"elements"`
+	Summary         DiffSummary `json:"summary"`
+}
+
+// NodeDiff 画板或元素的增删改列表
+type NodeDiff struct {
+	Added   []NodeChange `json:"added"`
+	Removed []NodeChange `json:"removed"`
+	Changed []NodeChange `json:"changed"`
+}
+
+// NodeChange 一个画板或元素的变化，Fields 为修改过的属性名
+type NodeChange struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name,omitempty"`
+	Type    string   `json:"type,omitempty"`
+	FrameID string   `json:"frame_id,omitempty"`
+	Fields  []string `json:"fields,omitempty"`
+}
+
+// DiffSummary 差异数量统计
+type DiffSummary struct {
+	FramesAdded     int `json:"frames_added"`
+	FramesRemoved   int `json:"frames_removed"`
+	FramesChanged   int `json:"frames_changed"`
+	ElementsAdded   int `json:"elements_added"`
+	ElementsRemoved int `json:"elements_removed"`
+	ElementsChanged int `json:"elements_changed"`
+}
+
+// diffNode 参与比较的画板或元素，attrs 不含子元素
+type diffNode struct {
+	id      string
+	frameID string
+	attrs   map[string]interface{}
+}
+
+// 画板中保存子元素的字段，比较画板属性时忽略，子元素单独作为元素比较
+var frameChildKeys = []string{"children", "elements"}
+
+// diffProjectContents 按 id 比较两个版本的画板与元素
+// 画板来自 frames_data，元素包括 elements_data 与画板 children 中的元素；元素换到其他画板时 fields 包含 frame_id
+func diffProjectContents(from, to *short_post.ShortPostProjectContent) (*ProjectVersionDiff, error) {
+	fromFrames, fromElements, err := collectDiffNodes(from)
+	if err != nil {
+		return nil, fmt.Errorf("版本 %d 内容解析失败: %w", from.Version, err)
+	}
+	toFrames, toElements, err := collectDiffNodes(to)
+	if err != nil {
+		return nil, fmt.Errorf("版本 %d 内容解析失败: %w", to.Version, err)
+	}
+
+	diff := &ProjectVersionDiff{
+		From:            from.Version,
+		To:              to.Version,
+		CanvasChanged:   !jsonEqual(from.CanvasConfig, to.CanvasConfig),
+		MetadataChanged: !jsonEqual(from.Metadata, to.Metadata),
+		Frames:          diffNodes(fromFrames, toFrames),
+		Elements:        diffNodes(fromElements, toElements),
+	}
+	diff.Summary = DiffSummary{
+		FramesAdded:     len(diff.Frames.Added),
+		FramesRemoved:   len(diff.Frames.Removed),
+		FramesChanged:   len(diff.Frames.Changed),
+		ElementsAdded:   len(diff.Elements.Added),
+		ElementsRemoved: len(diff.Elements.Removed),
+		ElementsChanged: len(diff.Elements.Changed),
+	}
+	return diff, nil
+}
+
+// collectDiffNodes 展开内容中的画板与元素，保持原有顺序
+func collectDiffNodes(content *short_post.ShortPostProjectContent) (frames, elements []diffNode, err error) {
+	frameItems, err := decodeJSONList(content.FramesData)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, item := range frameItems {
+		attrs, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		frame := diffNode{id: nodeID(attrs, "frame", i), attrs: make(map[string]interface{}, len(attrs))}
+		for key, value := range attrs {
+			if !isFrameChildKey(key) {
+				frame.attrs[key] = value
+			}
+		}
+		frames = append(frames, frame)
+
+		for _, key := range frameChildKeys {
+			children, _ := attrs[key].([]interface{})
+			for j, child := range children {
+				if childAttrs, ok := child.(map[string]interface{}); ok {
+					elements = append(elements, diffNode{
+						id:      nodeID(childAttrs, frame.id+"/element", j),
+						frameID: frame.id,
+						attrs:   childAttrs,
+					})
+				}
+			}
+		}
+	}
+
+	elementItems, err := decodeJSONList(content.ElementsData)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, item := range elementItems {
+		if attrs, ok := item.(map[string]interface{}); ok {
+			elements = append(elements, diffNode{
+				id:      nodeID(attrs, "element", i),
+				frameID: stringAttr(attrs, "frameId", "frame_id", "parentId", "parent_id"),
+				attrs:   attrs,
+			})
+		}
+	}
+	return frames, elements, nil
+}
+
+// diffNodes 比较两组节点，新增与修改按新版本中的顺序，删除按旧版本中的顺序
+func diffNodes(from, to []diffNode) NodeDiff {
+	result := NodeDiff{Added: []NodeChange{}, Removed: []NodeChange{}, Changed: []NodeChange{}}
+	fromByID := make(map[string]diffNode, len(from))
+	for _, node := range from {
+		fromByID[node.id] = node
+	}
+	toIDs := make(map[string]bool, len(to))
+	for _, node := range to {
+		toIDs[node.id] = true
+		old, ok := fromByID[node.id]
+		if !ok {
+			result.Added = append(result.Added, nodeChange(node, nil))
+			continue
+		}
+		if fields := changedFields(old, node); len(fields) > 0 {
+			result.Changed = append(result.Changed, nodeChange(node, fields))
+		}
+	}
+	for _, node := range from {
+		if !toIDs[node.id] {
+			result.Removed = append(result.Removed, nodeChange(node, nil))
+		}
+	}
+	return result
+}
+
+// changedFields 比较节点的属性，返回排序后的属性名
+func changedFields(from, to diffNode) []string {
+	var fields []string
+	for key, value := range to.attrs {
+		if old, ok := from.attrs[key]; !ok || !reflect.DeepEqual(old, value) {
+			fields = append(fields, key)
+		}
+	}
+	for key := range from.attrs {
+		if _, ok := to.attrs[key]; !ok {
+			fields = append(fields, key)
+		}
+	}
+	// 画板 children 中的元素换画板时属性本身不变，用 frame_id 表示
+	if from.frameID != to.frameID && !hasFrameKey(fields) {
+		fields = append(fields, "frame_id")
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// hasFrameKey 属性名中是否已有记录所属画板的字段
+func hasFrameKey(fields []string) bool {
+	for _, field := range fields {
+		switch field {
+		case "frameId", "frame_id", "parentId", "parent_id":
+			return true
+		}
+	}
+	return false
+}
+
+func nodeChange(node diffNode, fields []string) NodeChange {
+	return NodeChange{
+		ID:      node.id,
+		Name:    stringAttr(node.attrs, "name", "title"),
+		Type:    stringAttr(node.attrs, "type"),
+		FrameID: node.frameID,
+		Fields:  fields,
+	}
+}
+
+// nodeID 节点 id，缺少 id 时按位置生成，只能与同位置的节点比较
+func nodeID(attrs map[string]interface{}, prefix string, index int) string {
+	if id := stringAttr(attrs, "id"); id != "" {
+		return id
+	}
+	return fmt.Sprintf("%s#%d", prefix, index)
+}
+
+func stringAttr(attrs map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		switch v := attrs[key].(type) {
+		case string:
+			if v != "" {
+				return v
+			}
+		case float64:
+			return fmt.Sprintf("%v", v)
+		}
+	}
+	return ""
+}
+
+func isFrameChildKey(key string) bool {
+	for _, k := range frameChildKeys {
+		if key == k {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeJSONList(data *string) ([]interface{}, error) {
+	if data == nil || *data == "" || *data == "null" {
+		return nil, nil
+	}
+	var items []interface{}
+	if err := json.Unmarshal([]byte(*data), &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// jsonEqual 按解析后的值比较两个 JSON 字段，忽略键顺序与空白
+func jsonEqual(a, b *string) bool {
+	var va, vb interface{}
+	if a != nil {
+		json.Unmarshal([]byte(*a), &va)
+	}
+	if b != nil {
+		json.Unmarshal([]byte(*b), &vb)
+	}
+	return reflect.DeepEqual(va, vb)
+}
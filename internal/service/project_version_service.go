@@ -0,0 +1,409 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"01agent_server/internal/config"
+	"01agent_server/internal/models/short_post"
+	"01agent_server/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 历史版本保留策略默认值
+const (
+	defaultKeepLatestVersions = 20
+	defaultKeepDailyDays      = 30
+	defaultPruneInterval      = time.Hour
+)
+
+// ErrProjectVersionNotFound 工程版本不存在
+var ErrProjectVersionNotFound = errors.New("版本不存在")
+
+// ProjectVersionService 短图文工程版本管理服务
+// 工程内容的每一行是一个版本，is_latest 为当前内容；恢复、快照都追加新版本，历史版本本身不会被修改
+type ProjectVersionService struct {
+	db *gorm.DB
+}
+
+// NewProjectVersionService 创建工程版本服务
+func NewProjectVersionService() *ProjectVersionService {
+	return &ProjectVersionService{
+		db: repository.DB,
+	}
+}
+
+// List 工程的版本列表（不含内容），按版本号倒序
+func (s *ProjectVersionService) List(projectID string) ([]short_post.ShortPostProjectContent, error) {
+	var contents []short_post.ShortPostProjectContent
+	err := s.db.Select("id", "project_id", "version", "is_latest", "label", "source", "restored_from", "created_at", "updated_at").
+		Where("project_id = ?", projectID).
+		Order("version DESC").
+		Find(&contents).Error
+	return contents, err
+}
+
+// Get 获取指定版本的内容，version 为 0 时返回当前内容
+func (s *ProjectVersionService) Get(projectID string, version int) (*short_post.ShortPostProjectContent, error) {
+	query := s.db.Where("project_id = ?", projectID)
+	if version == 0 {
+		query = query.Where("is_latest = ?", true)
+	} else {
+		query = query.Where("version = ?", version)
+	}
+	var content short_post.ShortPostProjectContent
+	err := query.First(&content).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrProjectVersionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &content, nil
+}
+
+// Restore 将指定版本的内容复制为新的当前版本，原有版本全部保留，可以再次恢复
+// expectedVersion 不为空时与工程编辑版本号比较，不一致返回 ErrEditConflict
+func (s *ProjectVersionService) Restore(projectID string, version int, expectedVersion *int) (*short_post.ShortPostProjectContent, int, error) {
+	var created *short_post.ShortPostProjectContent
+	var editVersion int
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		project, err := s.lockProject(tx, projectID, expectedVersion)
+		if err != nil {
+			return err
+		}
+		var source short_post.ShortPostProjectContent
+		err = tx.Where("project_id = ? AND version = ?", projectID, version).First(&source).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrProjectVersionNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		created, err = s.appendLatest(tx, projectID, &source, short_post.ContentSourceRestore, &version)
+		if err != nil {
+			return err
+		}
+		editVersion = project.EditVersion + 1
+		return tx.Model(&short_post.ShortPostProject{}).Where("id = ?", projectID).Updates(map[string]interface{}{
+			"edit_version": gorm.Expr("edit_version + 1"),
+			"saved_at":     time.Now(),
+			"status":       short_post.ProjectStatusSaved,
+			"frame_count":  countFrames(created.FramesData),
+		}).Error
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return created, editVersion, nil
+}
+
+// Snapshot 将当前内容命名为快照
+// 保存时未创建新版本会原地覆盖当前内容，因此命名后追加一份副本作为新的当前内容，快照本身不再被修改
+func (s *ProjectVersionService) Snapshot(projectID, label string) (*short_post.ShortPostProjectContent, error) {
+	var snapshot short_post.ShortPostProjectContent
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := s.lockProject(tx, projectID, nil); err != nil {
+			return err
+		}
+		err := tx.Where("project_id = ? AND is_latest = ?", projectID, true).First(&snapshot).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrProjectVersionNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&snapshot).Update("label", label).Error; err != nil {
+			return err
+		}
+		snapshot.Label = &label
+
+		if _, err := s.appendLatest(tx, projectID, &snapshot, short_post.ContentSourceSnapshot, &snapshot.Version); err != nil {
+			return err
+		}
+		snapshot.IsLatest = false
+		// 编辑中的页面持有的仍是快照那一行，递增编辑版本号让其下次保存时重新加载
+		return tx.Model(&short_post.ShortPostProject{}).Where("id = ?", projectID).
+			Update("edit_version", gorm.Expr("edit_version + 1")).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// SetLabel 修改历史版本的名称，label 为空时取消命名，该版本重新参与自动清理
+// 当前版本仍会被继续编辑，需要通过 Snapshot 命名
+func (s *ProjectVersionService) SetLabel(projectID string, version int, label string) (*short_post.ShortPostProjectContent, error) {
+	content, err := s.Get(projectID, version)
+	if err != nil {
+		return nil, err
+	}
+	if content.IsLatest {
+		if label == "" {
+			return content, nil
+		}
+		return s.Snapshot(projectID, label)
+	}
+
+	var value interface{}
+	if label != "" {
+		value = label
+		content.Label = &label
+	} else {
+		content.Label = nil
+	}
+	if err := s.db.Model(content).Update("label", value).Error; err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// Branch 以指定版本的内容创建一个新工程，新工程只有一个版本，文案一并复制
+func (s *ProjectVersionService) Branch(project *short_post.ShortPostProject, version int, userID, name string) (*short_post.ShortPostProject, error) {
+	source, err := s.Get(project.ID, version)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = fmt.Sprintf("%s（v%d）", project.Name, source.Version)
+	}
+
+	now := time.Now()
+	branch := &short_post.ShortPostProject{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Name:        name,
+		Description: project.Description,
+		CoverImage:  project.CoverImage,
+		Thumbnail:   project.Thumbnail,
+		ProjectType: project.ProjectType,
+		Metadata:    project.Metadata,
+		Status:      short_post.ProjectStatusSaved,
+		FrameCount:  countFrames(source.FramesData),
+		SavedAt:     &now,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(branch).Error; err != nil {
+			return err
+		}
+		content := &short_post.ShortPostProjectContent{
+			ID:           uuid.New().String(),
+			ProjectID:    branch.ID,
+			CanvasConfig: source.CanvasConfig,
+			FramesData:   source.FramesData,
+			ElementsData: source.ElementsData,
+			Metadata:     source.Metadata,
+			Version:      1,
+			IsLatest:     true,
+			Source:       short_post.ContentSourceBranch,
+			RestoredFrom: &source.Version,
+		}
+		if err := tx.Create(content).Error; err != nil {
+			return err
+		}
+
+		var copywriting short_post.ShortPostProjectCopywriting
+		err := tx.Where("project_id = ?", project.ID).First(&copywriting).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		copywriting.ID = uuid.New().String()
+		copywriting.ProjectID = branch.ID
+		copywriting.CreatedAt = time.Time{}
+		copywriting.UpdatedAt = time.Time{}
+		return tx.Create(&copywriting).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return branch, nil
+}
+
+// Diff 比较两个版本的画板与元素，版本号 0 表示当前内容
+func (s *ProjectVersionService) Diff(projectID string, from, to int) (*ProjectVersionDiff, error) {
+	fromContent, err := s.Get(projectID, from)
+	if err != nil {
+		return nil, err
+	}
+	toContent, err := s.Get(projectID, to)
+	if err != nil {
+		return nil, err
+	}
+	return diffProjectContents(fromContent, toContent)
+}
+
+// StartPruner 按保留策略定期清理工程历史版本
+func (s *ProjectVersionService) StartPruner() {
+	interval := defaultPruneInterval
+	if minutes := config.AppConfig.ProjectVersion.PruneInterval; minutes > 0 {
+		interval = time.Duration(minutes) * time.Minute
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			count, err := s.PruneAll()
+			if err != nil {
+				repository.Errorf("Prune short post project versions failed: %v", err)
+				continue
+			}
+			if count > 0 {
+				repository.Infof("Pruned %d short post project versions", count)
+			}
+		}
+	}()
+}
+
+// PruneAll 清理所有版本数超过保留数量的工程
+func (s *ProjectVersionService) PruneAll() (int64, error) {
+	keepLatest, _ := retentionPolicy()
+
+	var projectIDs []string
+	err := s.db.Model(&short_post.ShortPostProjectContent{}).
+		Select("project_id").
+		Group("project_id").
+		Having("COUNT(*) > ?", keepLatest).
+		Pluck("project_id", &projectIDs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, projectID := range projectIDs {
+		count, err := s.Prune(projectID)
+		if err != nil {
+			repository.Warnf("Prune versions of project %s failed: %v", projectID, err)
+			continue
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// Prune 按保留策略清理单个工程的历史版本：
+// 保留当前版本、最近 N 个版本、所有命名快照、最近若干天内每天的最后一个版本，以及导出任务正在使用的版本
+func (s *ProjectVersionService) Prune(projectID string) (int64, error) {
+	keepLatest, keepDays := retentionPolicy()
+
+	versions, err := s.List(projectID)
+	if err != nil {
+		return 0, err
+	}
+	if len(versions) <= keepLatest {
+		return 0, nil
+	}
+
+	var exporting []string
+	err = s.db.Model(&short_post.ShortPostExportJob{}).
+		Where("project_id = ? AND status IN ?", projectID,
+			[]short_post.ExportJobStatus{short_post.ExportJobStatusPending, short_post.ExportJobStatusRunning}).
+		Pluck("content_id", &exporting).Error
+	if err != nil {
+		return 0, err
+	}
+	inUse := make(map[string]bool, len(exporting))
+	for _, id := range exporting {
+		inUse[id] = true
+	}
+
+	dailyCutoff := time.Now().AddDate(0, 0, -keepDays)
+	seenDays := make(map[string]bool)
+	var expired []string
+	// 版本按版本号倒序，每天遇到的第一个即当天最后保存的版本
+	for i, v := range versions {
+		day := v.CreatedAt.Format("2006-01-02")
+		keepDaily := v.CreatedAt.After(dailyCutoff) && !seenDays[day]
+		seenDays[day] = true
+
+		if v.IsLatest || i < keepLatest || (v.Label != nil && *v.Label != "") || keepDaily || inUse[v.ID] {
+			continue
+		}
+		expired = append(expired, v.ID)
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	result := s.db.Where("project_id = ? AND is_latest = ? AND id IN ?", projectID, false, expired).
+		Delete(&short_post.ShortPostProjectContent{})
+	return result.RowsAffected, result.Error
+}
+
+// lockProject 锁定工程行，串行化同一工程的版本变更
+func (s *ProjectVersionService) lockProject(tx *gorm.DB, projectID string, expectedVersion *int) (*short_post.ShortPostProject, error) {
+	var project short_post.ShortPostProject
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", projectID).First(&project).Error; err != nil {
+		return nil, err
+	}
+	if expectedVersion != nil && *expectedVersion != project.EditVersion {
+		return nil, ErrEditConflict
+	}
+	return &project, nil
+}
+
+// appendLatest 复制内容为新的当前版本，版本号在现有最大版本号上递增
+func (s *ProjectVersionService) appendLatest(tx *gorm.DB, projectID string, source *short_post.ShortPostProjectContent, origin string, from *int) (*short_post.ShortPostProjectContent, error) {
+	var maxVersion int
+	if err := tx.Model(&short_post.ShortPostProjectContent{}).
+		Where("project_id = ?", projectID).
+		Select("COALESCE(MAX(version), 0)").Scan(&maxVersion).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&short_post.ShortPostProjectContent{}).
+		Where("project_id = ? AND is_latest = ?", projectID, true).
+		Update("is_latest", false).Error; err != nil {
+		return nil, err
+	}
+
+	content := &short_post.ShortPostProjectContent{
+		ID:           uuid.New().String(),
+		ProjectID:    projectID,
+		CanvasConfig: source.CanvasConfig,
+		FramesData:   source.FramesData,
+		ElementsData: source.ElementsData,
+		Metadata:     source.Metadata,
+		Version:      maxVersion + 1,
+		IsLatest:     true,
+		Source:       origin,
+		RestoredFrom: from,
+	}
+	if err := tx.Create(content).Error; err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// retentionPolicy 历史版本保留数量与按天保留的天数
+func retentionPolicy() (keepLatest, keepDays int) {
+	keepLatest, keepDays = defaultKeepLatestVersions, defaultKeepDailyDays
+	if config.AppConfig != nil {
+		if n := config.AppConfig.ProjectVersion.KeepLatest; n > 0 {
+			keepLatest = n
+		}
+		if n := config.AppConfig.ProjectVersion.KeepDailyDays; n > 0 {
+			keepDays = n
+		}
+	}
+	return keepLatest, keepDays
+}
+
+// countFrames 内容中的画板数量
+func countFrames(framesData *string) int {
+	if framesData == nil {
+		return 0
+	}
+	var frames []json.RawMessage
+	json.Unmarshal([]byte(*framesData), &frames)
+	return len(frames)
+}
@@ -0,0 +1,84 @@
+package publisher
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"01agent_server/internal/config"
+)
+
+// credentialsCipherPrefix 加密后凭据的前缀，不带前缀的是加密上线前保存的明文 JSON
+const credentialsCipherPrefix = "enc:v1:"
+
+var (
+	// ErrCredentialsKeyMissing 未配置平台凭据加密密钥
+	ErrCredentialsKeyMissing = errors.New("平台凭据加密密钥未配置")
+	// ErrCredentialsCorrupted 凭据无法解密（密钥变更或数据被篡改）
+	ErrCredentialsCorrupted = errors.New("平台凭据无法解密，请重新绑定")
+)
+
+// credentialsAEAD 使用 publish.credentialsKey 派生的 AES-256-GCM
+func credentialsAEAD() (cipher.AEAD, error) {
+	if config.AppConfig == nil || config.AppConfig.Publish.CredentialsKey == "" {
+		return nil, ErrCredentialsKeyMissing
+	}
+	key := sha256.Sum256([]byte(config.AppConfig.Publish.CredentialsKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptCredentials 序列化并加密凭据，返回写入 UserAuthorization.Credentials 的内容
+func encryptCredentials(creds *Credentials) (string, error) {
+	aead, err := credentialsAEAD()
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := json.Marshal(creds)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("生成随机数失败: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, nil)
+	return credentialsCipherPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptCredentials 解密保存的凭据，legacy 表示读到的是加密上线前的明文 JSON
+func decryptCredentials(stored string) (creds *Credentials, legacy bool, err error) {
+	creds = &Credentials{}
+	if !strings.HasPrefix(stored, credentialsCipherPrefix) {
+		if err := json.Unmarshal([]byte(stored), creds); err != nil {
+			return nil, true, fmt.Errorf("平台凭据格式错误: %w", err)
+		}
+		return creds, true, nil
+	}
+
+	aead, err := credentialsAEAD()
+	if err != nil {
+		return nil, false, err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, credentialsCipherPrefix))
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, false, ErrCredentialsCorrupted
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, false, ErrCredentialsCorrupted
+	}
+	if err := json.Unmarshal(plaintext, creds); err != nil {
+		return nil, false, fmt.Errorf("平台凭据格式错误: %w", err)
+	}
+	return creds, false, nil
+}
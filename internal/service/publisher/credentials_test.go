@@ -0,0 +1,65 @@
+package publisher
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"01agent_server/internal/config"
+)
+
+func useCredentialsKey(t *testing.T, key string) {
+	t.Helper()
+	prevConfig := config.AppConfig
+	config.AppConfig = &config.Config{Publish: config.PublishConfig{CredentialsKey: key}}
+	t.Cleanup(func() { config.AppConfig = prevConfig })
+}
+
+func TestCredentialsRoundTrip(t *testing.T) {
+	useCredentialsKey(t, "test-credentials-key")
+	creds := &Credentials{Cookie: "z_c0=secret", CSRFToken: "csrf", Options: map[string]string{"column": "c1"}}
+
+	stored, err := encryptCredentials(creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(stored, credentialsCipherPrefix) || strings.Contains(stored, "z_c0") {
+		t.Fatalf("credentials should be stored encrypted, got %q", stored)
+	}
+	got, legacy, err := decryptCredentials(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if legacy || got.Cookie != creds.Cookie || got.CSRFToken != creds.CSRFToken || got.Options["column"] != "c1" {
+		t.Fatalf("unexpected decrypted credentials %+v legacy=%v", got, legacy)
+	}
+
+	// 加密上线前保存的明文凭据仍可读取
+	got, legacy, err = decryptCredentials(`{"cookie":"legacy"}`)
+	if err != nil || !legacy || got.Cookie != "legacy" {
+		t.Fatalf("legacy plaintext: got %+v legacy=%v err=%v", got, legacy, err)
+	}
+
+	// 篡改密文或更换密钥后无法解密
+	sealed, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, credentialsCipherPrefix))
+	sealed[len(sealed)-1] ^= 1
+	tampered := credentialsCipherPrefix + base64.StdEncoding.EncodeToString(sealed)
+	if _, _, err := decryptCredentials(tampered); !errors.Is(err, ErrCredentialsCorrupted) {
+		t.Fatalf("tampered: want ErrCredentialsCorrupted, got %v", err)
+	}
+	config.AppConfig.Publish.CredentialsKey = "other-key"
+	if _, _, err := decryptCredentials(stored); !errors.Is(err, ErrCredentialsCorrupted) {
+		t.Fatalf("other key: want ErrCredentialsCorrupted, got %v", err)
+	}
+}
+
+func TestCredentialsRequireKey(t *testing.T) {
+	useCredentialsKey(t, "")
+	if _, err := encryptCredentials(&Credentials{Cookie: "c"}); !errors.Is(err, ErrCredentialsKeyMissing) {
+		t.Fatalf("want ErrCredentialsKeyMissing, got %v", err)
+	}
+	if _, _, err := decryptCredentials(credentialsCipherPrefix + "AAAA"); !errors.Is(err, ErrCredentialsKeyMissing) {
+		t.Fatalf("want ErrCredentialsKeyMissing, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package publisher
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"01agent_server/internal/models"
+)
+
+// csdnPublisher CSDN 博客
+// 同时提交 Markdown 原文与渲染后的 HTML；标签最多 7 个
+type csdnPublisher struct {
+	client *webClient
+}
+
+func newCSDNPublisher() *csdnPublisher {
+	return &csdnPublisher{client: newWebClient(models.ArticleSceneCSDN, "https://bizapi.csdn.net")}
+}
+
+func (p *csdnPublisher) Platform() models.ArticleSceneType {
+	return models.ArticleSceneCSDN
+}
+
+func (p *csdnPublisher) Spec() Spec {
+	return Spec{
+		Format:             FormatHTML,
+		MinTitleLength:     5,
+		MaxTitleLength:     100,
+		MaxTags:            7,
+		MaxTagLength:       20,
+		HostedImageDomains: []string{"csdnimg.cn"},
+	}
+}
+
+func (p *csdnPublisher) Publish(account *Account, article *Article, mode string) (*Result, error) {
+	status, pubStatus := 0, "publish"
+	if mode == models.PlatformPublishModeDraft {
+		status, pubStatus = 2, "draft"
+	}
+	var coverImages []string
+	if article.CoverURL != "" {
+		coverImages = []string{article.CoverURL}
+	}
+
+	var resp struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+		Data struct {
+			ArticleID int64  `json:"article_id"`
+			URL       string `json:"url"`
+		} `json:"data"`
+	}
+	if err := p.client.doJSON(http.MethodPost, "/blog-console-api/v3/mdeditor/saveArticle", account, map[string]interface{}{
+		"title":           article.Title,
+		"markdowncontent": article.Markdown,
+		"content":         article.Content,
+		"description":     article.Digest,
+		"tags":            strings.Join(article.Tags, ","),
+		"categories":      account.Option("categories"),
+		"type":            "original",
+		"readType":        "public",
+		"status":          status,
+		"pubStatus":       pubStatus,
+		"cover_images":    coverImages,
+		"cover_type":      len(coverImages),
+		"source":          "pc_mdeditor",
+		"not_auto_saved":  "1",
+		"is_new":          1,
+	}, nil, &resp); err != nil {
+		return nil, err
+	}
+	switch resp.Code {
+	case 200:
+	case 400000101, 401:
+		return nil, Permanent(ErrCredentialExpired)
+	default:
+		return nil, Permanentf("CSDN接口错误: %d %s", resp.Code, resp.Msg)
+	}
+	if resp.Data.ArticleID == 0 {
+		return nil, fmt.Errorf("CSDN保存文章未返回文章ID")
+	}
+
+	result := &Result{ExternalID: fmt.Sprintf("%d", resp.Data.ArticleID)}
+	if mode == models.PlatformPublishModeDraft {
+		result.Status = models.PlatformPublishStatusDraft
+	} else {
+		result.Status = models.PlatformPublishStatusPublished
+		result.URL = resp.Data.URL
+	}
+	return result, nil
+}
@@ -0,0 +1,272 @@
+package publisher
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"01agent_server/internal/config"
+	"01agent_server/internal/models"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/tools"
+
+	"gorm.io/gorm"
+)
+
+const (
+	dispatchPollInterval = 10 * time.Second
+	dispatchBatchSize    = 20
+	dispatchLockDuration = 10 * time.Minute
+	defaultMaxAttempts   = 5
+	retryBase            = 30 * time.Second
+	retryMax             = 30 * time.Minute
+	statusCheckInterval  = time.Minute
+)
+
+// Dispatcher 平台发布调度器
+// 每条发布记录独立抢占、发布与重试，一个平台失败不影响其他平台
+type Dispatcher struct {
+	db      *gorm.DB
+	kick    chan struct{}
+	running sync.Once
+}
+
+var (
+	dispatcherInstance *Dispatcher
+	dispatcherOnce     sync.Once
+)
+
+// GetDispatcher 获取发布调度器单例
+func GetDispatcher() *Dispatcher {
+	dispatcherOnce.Do(func() {
+		dispatcherInstance = &Dispatcher{
+			db:   repository.DB,
+			kick: make(chan struct{}, 1),
+		}
+	})
+	return dispatcherInstance
+}
+
+// Start 启动后台调度协程，重复调用只启动一次
+func (d *Dispatcher) Start() {
+	d.running.Do(func() {
+		go func() {
+			ticker := time.NewTicker(dispatchPollInterval)
+			defer ticker.Stop()
+			for {
+				if _, err := d.DispatchPending(); err != nil {
+					repository.Errorf("Dispatch platform publishes failed: %v", err)
+				}
+				select {
+				case <-ticker.C:
+				case <-d.kick:
+				}
+			}
+		}()
+		repository.Infof("Platform publish dispatcher started, polling every %v", dispatchPollInterval)
+	})
+}
+
+// Kick 通知调度协程立即处理新任务
+func (d *Dispatcher) Kick() {
+	select {
+	case d.kick <- struct{}{}:
+	default:
+	}
+}
+
+// DispatchPending 处理到期的发布与状态查询，返回处理的记录数
+// 处理中但锁已过期的记录（进程崩溃遗留）会被重新处理
+func (d *Dispatcher) DispatchPending() (int, error) {
+	now := time.Now()
+	var records []models.ArticlePlatformPublish
+	err := d.db.Where("(status IN ? AND next_run_at <= ? AND (locked_until IS NULL OR locked_until < ?))",
+		[]string{models.PlatformPublishStatusPending, models.PlatformPublishStatusPublishing}, now, now).
+		Order("next_run_at ASC").
+		Limit(dispatchBatchSize).
+		Find(&records).Error
+	if err != nil {
+		return 0, fmt.Errorf("查询待发布记录失败: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range records {
+		wg.Add(1)
+		go func(record *models.ArticlePlatformPublish) {
+			defer wg.Done()
+			d.dispatch(record)
+		}(&records[i])
+	}
+	wg.Wait()
+	return len(records), nil
+}
+
+// dispatch 抢占并处理单条记录
+func (d *Dispatcher) dispatch(record *models.ArticlePlatformPublish) {
+	defer func() {
+		if r := recover(); r != nil {
+			repository.Errorf("Platform publish %d panic: %v", record.ID, r)
+			d.fail(record, fmt.Errorf("发布 panic: %v", r))
+		}
+	}()
+
+	now := time.Now()
+	checking := record.Status == models.PlatformPublishStatusPublishing
+	updates := map[string]interface{}{
+		"locked_until": now.Add(dispatchLockDuration),
+	}
+	if !checking {
+		updates["attempts"] = record.Attempts + 1
+	}
+
+	// 以状态与尝试次数为条件抢占，多实例下只有一个能成功
+	claim := d.db.Model(&models.ArticlePlatformPublish{}).
+		Where("id = ? AND status = ? AND attempts = ? AND (locked_until IS NULL OR locked_until < ?)",
+			record.ID, record.Status, record.Attempts, now).
+		Updates(updates)
+	if claim.Error != nil {
+		repository.Errorf("Claim platform publish %d failed: %v", record.ID, claim.Error)
+		return
+	}
+	if claim.RowsAffected == 0 {
+		return
+	}
+
+	if checking {
+		d.checkStatus(record)
+		return
+	}
+	record.Attempts++
+	d.publish(record)
+}
+
+func (d *Dispatcher) publish(record *models.ArticlePlatformPublish) {
+	p, err := Get(record.Platform)
+	if err != nil {
+		d.fail(record, Permanent(err))
+		return
+	}
+	account, err := LoadAccount(record.UserID, record.Platform)
+	if err != nil {
+		d.fail(record, err)
+		return
+	}
+
+	var task models.ArticleEditTask
+	if err := d.db.Where("id = ?", record.EditTaskID).First(&task).Error; err != nil {
+		d.fail(record, fmt.Errorf("找不到编辑任务: %w", err))
+		return
+	}
+	var publishConfig *models.ArticlePublishConfig
+	var cfg models.ArticlePublishConfig
+	if err := d.db.Where("edit_task_id = ?", record.EditTaskID).First(&cfg).Error; err == nil {
+		publishConfig = &cfg
+	}
+
+	article, warnings, err := BuildArticle(p, account, &task, publishConfig)
+	if err != nil {
+		d.fail(record, err)
+		return
+	}
+
+	result, err := p.Publish(account, article, record.Mode)
+	if err != nil {
+		d.fail(record, err)
+		return
+	}
+	d.succeed(record, result, append(warnings, result.Warnings...))
+}
+
+func (d *Dispatcher) checkStatus(record *models.ArticlePlatformPublish) {
+	p, err := Get(record.Platform)
+	if err != nil {
+		d.fail(record, Permanent(err))
+		return
+	}
+	checker, ok := p.(StatusChecker)
+	if !ok || record.ExternalID == nil {
+		d.succeed(record, &Result{Status: models.PlatformPublishStatusPublished}, nil)
+		return
+	}
+	account, err := LoadAccount(record.UserID, record.Platform)
+	if err == nil {
+		var result *Result
+		result, err = checker.CheckStatus(account, *record.ExternalID)
+		if err == nil && result.Status != models.PlatformPublishStatusPublishing {
+			d.succeed(record, result, nil)
+			return
+		}
+	}
+	if err != nil && IsPermanent(err) {
+		d.fail(record, err)
+		return
+	}
+
+	// 仍在审核中或查询失败，稍后再查；文章已提交，不能回到待发布重新提交
+	d.db.Model(&models.ArticlePlatformPublish{}).Where("id = ?", record.ID).Updates(map[string]interface{}{
+		"next_run_at":  time.Now().Add(statusCheckInterval),
+		"locked_until": nil,
+	})
+}
+
+func (d *Dispatcher) succeed(record *models.ArticlePlatformPublish, result *Result, warnings []string) {
+	updates := map[string]interface{}{
+		"status":       result.Status,
+		"last_error":   nil,
+		"locked_until": nil,
+	}
+	if result.ExternalID != "" {
+		updates["external_id"] = result.ExternalID
+	}
+	if result.URL != "" {
+		updates["article_url"] = result.URL
+	}
+	if len(warnings) > 0 {
+		data, _ := json.Marshal(warnings)
+		updates["warnings"] = string(data)
+	}
+	switch result.Status {
+	case models.PlatformPublishStatusPublished:
+		updates["published_at"] = time.Now()
+	case models.PlatformPublishStatusPublishing:
+		updates["next_run_at"] = time.Now().Add(statusCheckInterval)
+	}
+
+	if err := d.db.Model(&models.ArticlePlatformPublish{}).Where("id = ?", record.ID).Updates(updates).Error; err != nil {
+		repository.Errorf("Save platform publish %d result failed: %v", record.ID, err)
+		return
+	}
+	repository.Infof("Platform publish %d (%s, edit task %s) %s", record.ID, record.Platform, record.EditTaskID, result.Status)
+}
+
+// fail 记录失败原因，可重试的错误按退避策略重新排队，达到上限或不可重试时标记失败
+func (d *Dispatcher) fail(record *models.ArticlePlatformPublish, err error) {
+	updates := map[string]interface{}{
+		"last_error":   tools.StringPtr(err.Error()),
+		"locked_until": nil,
+	}
+	maxAttempts := defaultMaxAttempts
+	if config.AppConfig != nil && config.AppConfig.Publish.MaxAttempts > 0 {
+		maxAttempts = config.AppConfig.Publish.MaxAttempts
+	}
+
+	if IsPermanent(err) || record.Attempts >= maxAttempts {
+		updates["status"] = models.PlatformPublishStatusFailed
+		repository.Errorf("Platform publish %d (%s, edit task %s) failed after %d attempts: %v",
+			record.ID, record.Platform, record.EditTaskID, record.Attempts, err)
+	} else {
+		delay := retryBase
+		if record.Attempts > 1 {
+			delay = retryBase * time.Duration(1<<(record.Attempts-1))
+		}
+		if delay > retryMax {
+			delay = retryMax
+		}
+		updates["status"] = models.PlatformPublishStatusPending
+		updates["next_run_at"] = time.Now().Add(delay)
+		repository.Warnf("Platform publish %d (%s, edit task %s) failed (attempt %d), retry in %v: %v",
+			record.ID, record.Platform, record.EditTaskID, record.Attempts, delay, err)
+	}
+	d.db.Model(&models.ArticlePlatformPublish{}).Where("id = ?", record.ID).Updates(updates)
+}
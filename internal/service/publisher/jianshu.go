@@ -0,0 +1,76 @@
+package publisher
+
+import (
+	"fmt"
+	"net/http"
+
+	"01agent_server/internal/models"
+)
+
+// jianshuPublisher 简书文章
+// 正文为 Markdown（需账号开启 Markdown 编辑器），文章保存在 options.notebook_id 指定的文集中；不支持标签
+type jianshuPublisher struct {
+	client *webClient
+}
+
+func newJianshuPublisher() *jianshuPublisher {
+	return &jianshuPublisher{client: newWebClient(models.ArticleSceneJianshu, "https://www.jianshu.com")}
+}
+
+func (p *jianshuPublisher) Platform() models.ArticleSceneType {
+	return models.ArticleSceneJianshu
+}
+
+func (p *jianshuPublisher) Spec() Spec {
+	return Spec{
+		Format:             FormatMarkdown,
+		MaxTitleLength:     100,
+		HostedImageDomains: []string{"jianshu.io"},
+	}
+}
+
+func (p *jianshuPublisher) Publish(account *Account, article *Article, mode string) (*Result, error) {
+	notebookID := account.Option("notebook_id")
+	if notebookID == "" {
+		return nil, Permanentf("未设置简书文集(options.notebook_id)")
+	}
+
+	var note struct {
+		ID   int64  `json:"id"`
+		Slug string `json:"slug"`
+	}
+	if err := p.client.doJSON(http.MethodPost, "/author/notes", account, map[string]interface{}{
+		"notebook_id": notebookID,
+		"title":       article.Title,
+		"at_bottom":   false,
+	}, nil, &note); err != nil {
+		return nil, err
+	}
+	if note.ID == 0 {
+		return nil, fmt.Errorf("简书创建文章未返回文章ID")
+	}
+	noteID := fmt.Sprintf("%d", note.ID)
+
+	if err := p.client.doJSON(http.MethodPut, "/author/notes/"+noteID, account, map[string]interface{}{
+		"id":               noteID,
+		"autosave_control": 1,
+		"title":            article.Title,
+		"content":          article.Content,
+	}, nil, nil); err != nil {
+		return nil, err
+	}
+
+	if mode == models.PlatformPublishModeDraft {
+		return &Result{Status: models.PlatformPublishStatusDraft, ExternalID: noteID}, nil
+	}
+
+	if err := p.client.doJSON(http.MethodPost, "/author/notes/"+noteID+"/publicize", account, map[string]interface{}{}, nil, nil); err != nil {
+		return nil, err
+	}
+
+	result := &Result{Status: models.PlatformPublishStatusPublished, ExternalID: noteID}
+	if note.Slug != "" {
+		result.URL = "https://www.jianshu.com/p/" + note.Slug
+	}
+	return result, nil
+}
@@ -0,0 +1,138 @@
+package publisher
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"01agent_server/internal/models"
+)
+
+// 掘金摘要长度要求
+const (
+	juejinMinBriefLength = 50
+	juejinMaxBriefLength = 100
+)
+
+// juejinPublisher 掘金文章
+// 正文为 Markdown；分类与标签使用掘金的ID，需在绑定账号时通过 options.category_id / options.tag_ids 设置
+type juejinPublisher struct {
+	client *webClient
+}
+
+func newJuejinPublisher() *juejinPublisher {
+	return &juejinPublisher{client: newWebClient(models.ArticleSceneJuejin, "https://api.juejin.cn")}
+}
+
+func (p *juejinPublisher) Platform() models.ArticleSceneType {
+	return models.ArticleSceneJuejin
+}
+
+func (p *juejinPublisher) Spec() Spec {
+	return Spec{
+		Format:             FormatMarkdown,
+		MaxTitleLength:     100,
+		MaxTags:            3,
+		MaxTagLength:       20,
+		HostedImageDomains: []string{"byteimg.com", "juejin.cn"},
+	}
+}
+
+// juejinResponse 掘金接口的通用响应
+type juejinResponse struct {
+	ErrNo  int             `json:"err_no"`
+	ErrMsg string          `json:"err_msg"`
+	Data   json.RawMessage `json:"data"`
+}
+
+func (r *juejinResponse) err() error {
+	switch r.ErrNo {
+	case 0:
+		return nil
+	case 403:
+		return Permanent(ErrCredentialExpired)
+	default:
+		return Permanentf("掘金接口错误: %d %s", r.ErrNo, r.ErrMsg)
+	}
+}
+
+func (p *juejinPublisher) Publish(account *Account, article *Article, mode string) (*Result, error) {
+	categoryID := account.Option("category_id")
+	if categoryID == "" {
+		return nil, Permanentf("未设置掘金文章分类(options.category_id)")
+	}
+	var tagIDs []string
+	for _, id := range strings.Split(account.Option("tag_ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" && len(tagIDs) < p.Spec().MaxTags {
+			tagIDs = append(tagIDs, id)
+		}
+	}
+	if len(tagIDs) == 0 && mode != models.PlatformPublishModeDraft {
+		return nil, Permanentf("未设置掘金文章标签(options.tag_ids)")
+	}
+
+	var draftResp juejinResponse
+	if err := p.client.doJSON(http.MethodPost, "/content_api/v1/article_draft/create", account, map[string]interface{}{
+		"category_id":   categoryID,
+		"tag_ids":       tagIDs,
+		"link_url":      "",
+		"cover_image":   article.CoverURL,
+		"title":         article.Title,
+		"brief_content": juejinBrief(article),
+		"edit_type":     10, // Markdown 编辑器
+		"html_content":  "deprecated",
+		"mark_content":  article.Content,
+		"theme_ids":     []string{},
+	}, nil, &draftResp); err != nil {
+		return nil, err
+	}
+	if err := draftResp.err(); err != nil {
+		return nil, err
+	}
+	var draft struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(draftResp.Data, &draft); err != nil || draft.ID == "" {
+		return nil, fmt.Errorf("掘金创建草稿未返回草稿ID")
+	}
+
+	if mode == models.PlatformPublishModeDraft {
+		return &Result{Status: models.PlatformPublishStatusDraft, ExternalID: draft.ID}, nil
+	}
+
+	var publishResp juejinResponse
+	if err := p.client.doJSON(http.MethodPost, "/content_api/v1/article/publish", account, map[string]interface{}{
+		"draft_id":    draft.ID,
+		"sync_to_org": false,
+		"column_ids":  []string{},
+		"theme_ids":   []string{},
+	}, nil, &publishResp); err != nil {
+		return nil, err
+	}
+	if err := publishResp.err(); err != nil {
+		return nil, err
+	}
+	var published struct {
+		ArticleID string `json:"article_id"`
+	}
+	if err := json.Unmarshal(publishResp.Data, &published); err != nil || published.ArticleID == "" {
+		return nil, fmt.Errorf("掘金发布未返回文章ID")
+	}
+
+	return &Result{
+		Status:     models.PlatformPublishStatusPublished,
+		ExternalID: published.ArticleID,
+		URL:        "https://juejin.cn/post/" + published.ArticleID,
+	}, nil
+}
+
+// juejinBrief 掘金要求摘要 50-100 字，不足时从正文补齐
+func juejinBrief(article *Article) string {
+	brief := article.Digest
+	if utf8.RuneCountInString(brief) < juejinMinBriefLength {
+		brief = plainText(article.Markdown)
+	}
+	return truncateRunes(brief, juejinMaxBriefLength)
+}
@@ -0,0 +1,213 @@
+package publisher
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"01agent_server/internal/models"
+	"01agent_server/internal/repository"
+)
+
+// ContentFormat 平台接受的正文格式
+type ContentFormat string
+
+const (
+	FormatMarkdown   ContentFormat = "markdown"    // Markdown 原文
+	FormatHTML       ContentFormat = "html"        // 通用 HTML
+	FormatWechatHTML ContentFormat = "wechat_html" // 样式全部内联的公众号 HTML
+)
+
+var (
+	// ErrUnsupportedPlatform 不支持的发布平台
+	ErrUnsupportedPlatform = errors.New("不支持的发布平台")
+	// ErrAccountNotBound 用户未绑定平台账号
+	ErrAccountNotBound = errors.New("未绑定该平台账号")
+	// ErrCredentialExpired 平台凭据失效，需要重新绑定
+	ErrCredentialExpired = errors.New("平台凭据已失效，请重新绑定")
+)
+
+// Spec 平台对内容的限制，BuildArticle 据此转换编辑任务内容
+type Spec struct {
+	Format         ContentFormat
+	MinTitleLength int  // 标题最少字数，0 表示不限制
+	MaxTitleLength int  // 标题最多字数，超出时截断
+	MaxTags        int  // 标签数量上限，0 表示平台不支持标签
+	MaxTagLength   int  // 单个标签的字数上限
+	RequiresCover  bool // 是否必须有封面图
+	// HostedImageDomains 已在平台图床上的图片域名，这些图片无需转存
+	HostedImageDomains []string
+}
+
+// Account 用户在平台上的账号凭据
+type Account struct {
+	UserID      string
+	Platform    models.ArticleSceneType
+	OpenID      string
+	AppID       string // 公众号appid，仅公众号使用
+	Cookie      string
+	CSRFToken   string
+	AccessToken string
+	Options     map[string]string // 平台相关的默认设置，如专栏、分类、文集
+}
+
+// Option 读取账号的默认设置
+func (a *Account) Option(key string) string {
+	if a.Options == nil {
+		return ""
+	}
+	return a.Options[key]
+}
+
+// Credentials 平台凭据，序列化为 JSON 并加密后保存在 UserAuthorization.Credentials 中
+type Credentials struct {
+	Cookie      string            `json:"cookie,omitempty"`
+	CSRFToken   string            `json:"csrf_token,omitempty"`
+	AccessToken string            `json:"access_token,omitempty"`
+	Options     map[string]string `json:"options,omitempty"`
+}
+
+// Article 转换后待发布的文章
+type Article struct {
+	Title                string
+	Content              string // 按平台格式转换后的正文
+	Markdown             string // Markdown 原文，部分平台需要同时提交
+	Digest               string
+	Author               string
+	CoverURL             string
+	Tags                 []string
+	EnableComments       bool
+	FollowersOnlyComment bool
+}
+
+// Result 平台发布结果
+type Result struct {
+	Status     string // draft / published / publishing，取值同 models.PlatformPublishStatus*
+	ExternalID string // 平台侧文章或草稿ID
+	URL        string // 平台侧文章链接
+	Warnings   []string
+}
+
+// Publisher 平台发布适配器
+// 各平台的正文格式、图床与标签限制由 Spec 描述，内容转换统一由 BuildArticle 完成
+type Publisher interface {
+	// Platform 平台标识
+	Platform() models.ArticleSceneType
+	// Spec 平台的内容限制
+	Spec() Spec
+	// Publish 发布文章，mode 为 models.PlatformPublishModeDraft 时只保存草稿
+	Publish(account *Account, article *Article, mode string) (*Result, error)
+}
+
+// ImageUploader 需要将正文图片转存到平台图床的适配器实现此接口
+type ImageUploader interface {
+	// UploadImage 转存图片，返回平台图床地址
+	UploadImage(account *Account, src string) (string, error)
+}
+
+// StatusChecker 发布需要平台审核的适配器实现此接口，发布中的记录会定期查询结果
+type StatusChecker interface {
+	// CheckStatus 查询发布结果，仍在审核中时返回 publishing 状态
+	CheckStatus(account *Account, externalID string) (*Result, error)
+}
+
+// permanentError 重试无法恢复的错误（凭据失效、内容不符合要求等）
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 标记错误不可重试
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Permanentf 格式化一个不可重试的错误
+func Permanentf(format string, args ...interface{}) error {
+	return Permanent(fmt.Errorf(format, args...))
+}
+
+// IsPermanent 错误是否不可重试
+func IsPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+var registry = map[models.ArticleSceneType]func() Publisher{
+	models.ArticleSceneWeixin:  func() Publisher { return &wechatPublisher{} },
+	models.ArticleSceneZhihu:   func() Publisher { return newZhihuPublisher() },
+	models.ArticleSceneToutiao: func() Publisher { return newToutiaoPublisher() },
+	models.ArticleSceneJuejin:  func() Publisher { return newJuejinPublisher() },
+	models.ArticleSceneCSDN:    func() Publisher { return newCSDNPublisher() },
+	models.ArticleSceneJianshu: func() Publisher { return newJianshuPublisher() },
+}
+
+// Get 获取平台的发布适配器
+func Get(platform models.ArticleSceneType) (Publisher, error) {
+	factory, ok := registry[platform]
+	if !ok {
+		return nil, ErrUnsupportedPlatform
+	}
+	return factory(), nil
+}
+
+// Platforms 支持发布的全部平台
+func Platforms() []models.ArticleSceneType {
+	platforms := make([]models.ArticleSceneType, 0, len(registry))
+	for platform := range registry {
+		platforms = append(platforms, platform)
+	}
+	sort.Slice(platforms, func(i, j int) bool { return platforms[i] < platforms[j] })
+	return platforms
+}
+
+// LoadAccount 加载用户在平台上的账号
+// 公众号使用第三方平台授权，其他平台使用 UserAuthorization 中保存的凭据
+func LoadAccount(userID string, platform models.ArticleSceneType) (*Account, error) {
+	if platform == models.ArticleSceneWeixin {
+		var user models.User
+		if err := repository.DB.Where("user_id = ?", userID).First(&user).Error; err != nil {
+			return nil, err
+		}
+		var params models.UserParameters
+		if err := repository.DB.Where("user_id = ?", userID).First(&params).Error; err != nil || !params.IsGzhBind ||
+			user.AppID == nil || *user.AppID == "" {
+			return nil, Permanent(ErrAccountNotBound)
+		}
+		return &Account{UserID: userID, Platform: platform, AppID: *user.AppID}, nil
+	}
+
+	var auth models.UserAuthorization
+	err := repository.DB.Where("user_id = ? AND platform = ? AND status = ?", userID, string(platform), "enabled").
+		Order("updated_at DESC").First(&auth).Error
+	if err != nil || auth.Credentials == nil || *auth.Credentials == "" {
+		return nil, Permanent(ErrAccountNotBound)
+	}
+
+	creds, legacy, err := decryptCredentials(*auth.Credentials)
+	if err != nil {
+		return nil, Permanent(err)
+	}
+	if legacy {
+		// 加密上线前保存的明文凭据，读取时顺带加密
+		if encrypted, err := encryptCredentials(creds); err == nil {
+			if err := repository.DB.Model(&auth).Update("credentials", encrypted).Error; err != nil {
+				repository.Warnf("Encrypt legacy credentials %s failed: %v", auth.AuthID, err)
+			}
+		}
+	}
+	return &Account{
+		UserID:      userID,
+		Platform:    platform,
+		OpenID:      auth.OpenID,
+		Cookie:      creds.Cookie,
+		CSRFToken:   creds.CSRFToken,
+		AccessToken: creds.AccessToken,
+		Options:     creds.Options,
+	}, nil
+}
@@ -0,0 +1,261 @@
+package publisher
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"01agent_server/internal/models"
+	"01agent_server/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrPublishInProgress 该平台的发布正在进行中
+	ErrPublishInProgress = errors.New("该平台的发布正在进行中")
+	// ErrPublishRecordNotFound 没有该平台的发布记录
+	ErrPublishRecordNotFound = errors.New("没有该平台的发布记录")
+)
+
+// Service 多平台发布服务
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService 创建多平台发布服务
+func NewService() *Service {
+	return &Service{db: repository.DB}
+}
+
+// ParsePlatforms 校验并去重平台列表
+func ParsePlatforms(names []string) ([]models.ArticleSceneType, error) {
+	seen := make(map[models.ArticleSceneType]bool)
+	var platforms []models.ArticleSceneType
+	for _, name := range names {
+		platform := models.ArticleSceneType(strings.ToLower(strings.TrimSpace(name)))
+		if _, ok := registry[platform]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedPlatform, name)
+		}
+		if !seen[platform] {
+			seen[platform] = true
+			platforms = append(platforms, platform)
+		}
+	}
+	if len(platforms) == 0 {
+		return nil, errors.New("请选择发布平台")
+	}
+	return platforms, nil
+}
+
+// Enqueue 将编辑任务发布到多个平台，每个平台生成一条独立的发布记录
+// 已在进行中的平台保持不变，其余平台（含之前失败或已发布的）重新排队
+func (s *Service) Enqueue(userID, editTaskID string, platforms []models.ArticleSceneType, mode string) ([]models.ArticlePlatformPublish, error) {
+	if mode != models.PlatformPublishModeDraft {
+		mode = models.PlatformPublishModePublish
+	}
+	now := time.Now()
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for _, platform := range platforms {
+			var record models.ArticlePlatformPublish
+			err := tx.Where("edit_task_id = ? AND platform = ?", editTaskID, platform).First(&record).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				record = models.ArticlePlatformPublish{
+					EditTaskID: editTaskID,
+					UserID:     userID,
+					Platform:   platform,
+					Mode:       mode,
+					Status:     models.PlatformPublishStatusPending,
+					NextRunAt:  now,
+				}
+				if err := tx.Create(&record).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if inProgress(&record) {
+				continue
+			}
+			if err := tx.Model(&record).Updates(map[string]interface{}{
+				"mode":         mode,
+				"status":       models.PlatformPublishStatusPending,
+				"attempts":     0,
+				"last_error":   nil,
+				"warnings":     nil,
+				"next_run_at":  now,
+				"locked_until": nil,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	GetDispatcher().Kick()
+	return s.List(editTaskID)
+}
+
+// Retry 立即重试单个平台的发布
+func (s *Service) Retry(editTaskID string, platform models.ArticleSceneType) (*models.ArticlePlatformPublish, error) {
+	var record models.ArticlePlatformPublish
+	if err := s.db.Where("edit_task_id = ? AND platform = ?", editTaskID, platform).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPublishRecordNotFound
+		}
+		return nil, err
+	}
+	if inProgress(&record) {
+		return nil, ErrPublishInProgress
+	}
+
+	if err := s.db.Model(&record).Updates(map[string]interface{}{
+		"status":       models.PlatformPublishStatusPending,
+		"attempts":     0,
+		"last_error":   nil,
+		"next_run_at":  time.Now(),
+		"locked_until": nil,
+	}).Error; err != nil {
+		return nil, err
+	}
+	GetDispatcher().Kick()
+
+	s.db.Where("id = ?", record.ID).First(&record)
+	return &record, nil
+}
+
+// List 编辑任务在各平台的发布记录
+func (s *Service) List(editTaskID string) ([]models.ArticlePlatformPublish, error) {
+	var records []models.ArticlePlatformPublish
+	err := s.db.Where("edit_task_id = ?", editTaskID).Order("id ASC").Find(&records).Error
+	return records, err
+}
+
+// inProgress 记录正在发布或等待平台审核
+func inProgress(record *models.ArticlePlatformPublish) bool {
+	switch record.Status {
+	case models.PlatformPublishStatusPublishing:
+		return true
+	case models.PlatformPublishStatusPending:
+		return record.LockedUntil != nil && record.LockedUntil.After(time.Now())
+	}
+	return false
+}
+
+// ==================== 平台账号 ====================
+
+// AccountInfo 平台账号绑定状态，不包含凭据
+type AccountInfo struct {
+	Platform   models.ArticleSceneType `json:"platform"`
+	Format     ContentFormat           `json:"format"`
+	MaxTags    int                     `json:"max_tags"`
+	Bound      bool                    `json:"bound"`
+	Nickname   *string                 `json:"nickname,omitempty"`
+	OpenID     string                  `json:"openid,omitempty"`
+	AppID      string                  `json:"appid,omitempty"` // 公众号appid
+	OptionKeys []string                `json:"option_keys,omitempty"`
+	UpdatedAt  *time.Time              `json:"updated_at,omitempty"`
+}
+
+// ListAccounts 返回用户在各平台的绑定状态
+func (s *Service) ListAccounts(userID string) ([]AccountInfo, error) {
+	var auths []models.UserAuthorization
+	if err := s.db.Where("user_id = ? AND status = ?", userID, "enabled").Find(&auths).Error; err != nil {
+		return nil, err
+	}
+	byPlatform := make(map[string]*models.UserAuthorization)
+	for i := range auths {
+		if auths[i].Credentials != nil && *auths[i].Credentials != "" {
+			byPlatform[auths[i].Platform] = &auths[i]
+		}
+	}
+
+	var infos []AccountInfo
+	for _, platform := range Platforms() {
+		p, _ := Get(platform)
+		spec := p.Spec()
+		info := AccountInfo{Platform: platform, Format: spec.Format, MaxTags: spec.MaxTags}
+		if platform == models.ArticleSceneWeixin {
+			if account, err := LoadAccount(userID, platform); err == nil {
+				info.Bound = true
+				info.AppID = account.AppID
+			}
+		} else if auth, ok := byPlatform[string(platform)]; ok {
+			info.Bound = true
+			info.Nickname = auth.PlatformNickname
+			info.OpenID = auth.OpenID
+			updatedAt := auth.UpdatedAt
+			info.UpdatedAt = &updatedAt
+			if creds, _, err := decryptCredentials(*auth.Credentials); err == nil {
+				for key := range creds.Options {
+					info.OptionKeys = append(info.OptionKeys, key)
+				}
+				sort.Strings(info.OptionKeys)
+			}
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// SaveAccount 保存用户在平台上的凭据，同一平台只保留一个账号
+func (s *Service) SaveAccount(userID string, platform models.ArticleSceneType, openID string, nickname *string, creds *Credentials) error {
+	if platform == models.ArticleSceneWeixin {
+		return errors.New("公众号请通过第三方平台授权绑定")
+	}
+	if _, ok := registry[platform]; !ok {
+		return ErrUnsupportedPlatform
+	}
+	if creds.Cookie == "" && creds.AccessToken == "" {
+		return errors.New("缺少平台凭据(cookie或access_token)")
+	}
+	credentials, err := encryptCredentials(creds)
+	if err != nil {
+		return err
+	}
+	if openID == "" {
+		openID = userID
+	}
+
+	var auth models.UserAuthorization
+	err = s.db.Where("user_id = ? AND platform = ?", userID, string(platform)).First(&auth).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.db.Create(&models.UserAuthorization{
+			AuthID:           uuid.New().String(),
+			UserID:           userID,
+			Platform:         string(platform),
+			OpenID:           openID,
+			PlatformNickname: nickname,
+			Credentials:      &credentials,
+			Status:           "enabled",
+		}).Error
+	}
+	if err != nil {
+		return err
+	}
+	return s.db.Model(&auth).Updates(map[string]interface{}{
+		"openid":            openID,
+		"platform_nickname": nickname,
+		"credentials":       credentials,
+		"status":            "enabled",
+	}).Error
+}
+
+// DeleteAccount 解除平台绑定，清除保存的凭据
+func (s *Service) DeleteAccount(userID string, platform models.ArticleSceneType) error {
+	return s.db.Model(&models.UserAuthorization{}).
+		Where("user_id = ? AND platform = ?", userID, string(platform)).
+		Updates(map[string]interface{}{
+			"credentials": nil,
+			"status":      "disabled",
+		}).Error
+}
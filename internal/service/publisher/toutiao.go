@@ -0,0 +1,109 @@
+package publisher
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"01agent_server/internal/models"
+)
+
+// toutiaoPublisher 今日头条（头条号）文章
+// 正文为 HTML，图片需转存到头条图床；标题 5-30 字，不支持标签
+type toutiaoPublisher struct {
+	client *webClient
+}
+
+func newToutiaoPublisher() *toutiaoPublisher {
+	return &toutiaoPublisher{client: newWebClient(models.ArticleSceneToutiao, "https://mp.toutiao.com")}
+}
+
+func (p *toutiaoPublisher) Platform() models.ArticleSceneType {
+	return models.ArticleSceneToutiao
+}
+
+func (p *toutiaoPublisher) Spec() Spec {
+	return Spec{
+		Format:             FormatHTML,
+		MinTitleLength:     5,
+		MaxTitleLength:     30,
+		HostedImageDomains: []string{"pstatp.com", "toutiaoimg.com", "byteimg.com"},
+	}
+}
+
+// toutiaoResponse 头条号接口的通用响应
+type toutiaoResponse struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+func (r *toutiaoResponse) err() error {
+	if r.Code == 0 {
+		return nil
+	}
+	return Permanentf("头条号接口错误: %d %s", r.Code, r.Message)
+}
+
+func (p *toutiaoPublisher) UploadImage(account *Account, src string) (string, error) {
+	data, filename, err := p.client.fetchImage(src)
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		toutiaoResponse
+		URL string `json:"url"`
+	}
+	if err := p.client.doFile("/mp/agw/article_material/photo/upload_picture?type=ueditor&pgc_watermark=1", account,
+		"upfile", filename, data, nil, &resp); err != nil {
+		return "", err
+	}
+	if err := resp.err(); err != nil {
+		return "", err
+	}
+	return resp.URL, nil
+}
+
+func (p *toutiaoPublisher) Publish(account *Account, article *Article, mode string) (*Result, error) {
+	save := "1"
+	if mode == models.PlatformPublishModeDraft {
+		save = "0"
+	}
+
+	form := url.Values{}
+	form.Set("title", article.Title)
+	form.Set("content", article.Content)
+	form.Set("abstract", article.Digest)
+	form.Set("save", save)
+	form.Set("article_ad_type", "3")
+	form.Set("is_fans_article", "0")
+	if article.CoverURL != "" {
+		covers, _ := json.Marshal([]map[string]interface{}{{"url": article.CoverURL, "id": 0}})
+		form.Set("pgc_feed_covers", string(covers))
+	}
+
+	var resp toutiaoResponse
+	if err := p.client.doForm("/mp/agw/article/publish?source=mp&type=article", account, form, nil, &resp); err != nil {
+		return nil, err
+	}
+	if err := resp.err(); err != nil {
+		return nil, err
+	}
+
+	var data struct {
+		PgcID json.Number `json:"pgc_id"`
+	}
+	if err := json.Unmarshal(resp.Data, &data); err != nil || data.PgcID == "" {
+		return nil, fmt.Errorf("头条号发布未返回文章ID")
+	}
+
+	result := &Result{ExternalID: data.PgcID.String()}
+	if mode == models.PlatformPublishModeDraft {
+		result.Status = models.PlatformPublishStatusDraft
+	} else {
+		// 头条号发布后进入审核，链接在审核通过后可访问
+		result.Status = models.PlatformPublishStatusPublished
+		result.URL = "https://www.toutiao.com/article/" + result.ExternalID + "/"
+	}
+	return result, nil
+}
@@ -0,0 +1,259 @@
+package publisher
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"01agent_server/internal/models"
+	"01agent_server/internal/tools"
+)
+
+// defaultDigestLength 未设置摘要时从正文截取的字数
+const defaultDigestLength = 100
+
+var (
+	reMarkdownImage = regexp.MustCompile(`!\[([^\]]*)\]\(\s*<?([^)\s>]+)>?((?:\s+"[^"]*")?)\s*\)`)
+	reHTMLImgTag    = regexp.MustCompile(`(?is)<img\b[^>]*>`)
+	reHTMLImgSrc    = regexp.MustCompile(`(?is)(\ssrc\s*=\s*)("[^"]*"|'[^']*')`)
+	reHTMLTag       = regexp.MustCompile(`(?s)<[^>]*>`)
+	reMarkdownMark  = regexp.MustCompile("(?m)^\\s{0,3}(?:#{1,6}\\s+|>\\s?|[-*+]\\s+|\\d+\\.\\s+)|[*_`~]|!?\\[([^\\]]*)\\]\\([^)]*\\)")
+	reSpaces        = regexp.MustCompile(`\s+`)
+)
+
+// BuildArticle 按平台限制转换编辑任务内容
+// 返回的警告记录被截断的标题与标签、转存失败的图片等不影响发布的问题
+func BuildArticle(p Publisher, account *Account, task *models.ArticleEditTask, publishConfig *models.ArticlePublishConfig) (*Article, []string, error) {
+	spec := p.Spec()
+	var warnings []string
+
+	article := &Article{
+		Title:          strings.TrimSpace(task.Title),
+		Markdown:       task.Content,
+		EnableComments: true,
+	}
+	if publishConfig != nil {
+		if title := strings.TrimSpace(publishConfig.PublishTitle); title != "" {
+			article.Title = title
+		}
+		article.Author = publishConfig.AuthorName
+		if publishConfig.Summary != nil {
+			article.Digest = strings.TrimSpace(*publishConfig.Summary)
+		}
+		if publishConfig.CoverImage != nil {
+			article.CoverURL = strings.TrimSpace(*publishConfig.CoverImage)
+		}
+		article.EnableComments = publishConfig.EnableComments
+		article.FollowersOnlyComment = publishConfig.FollowersOnlyComment
+	}
+
+	// 标题
+	titleLen := utf8.RuneCountInString(article.Title)
+	if titleLen == 0 {
+		return nil, nil, Permanentf("缺少文章标题")
+	}
+	if spec.MinTitleLength > 0 && titleLen < spec.MinTitleLength {
+		return nil, nil, Permanentf("标题至少需要%d个字", spec.MinTitleLength)
+	}
+	if spec.MaxTitleLength > 0 && titleLen > spec.MaxTitleLength {
+		article.Title = truncateRunes(article.Title, spec.MaxTitleLength)
+		warnings = append(warnings, fmt.Sprintf("标题超过%d字，已截断", spec.MaxTitleLength))
+	}
+
+	// 正文
+	if strings.TrimSpace(task.Content) == "" {
+		return nil, nil, Permanentf("文章内容为空")
+	}
+	theme := task.Theme
+	if theme == "" || theme == "none" {
+		theme = "default"
+	}
+	switch spec.Format {
+	case FormatMarkdown:
+		article.Content = task.Content
+	case FormatHTML:
+		content, err := tools.NewUnifiedMarkdownProcessor().ProcessMarkdown(task.Content, theme)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Markdown转换失败: %w", err)
+		}
+		article.Content = content
+	case FormatWechatHTML:
+		// section_html 是预览用的普通 HTML，发布时按公众号兼容模式重新渲染
+		content, _, err := tools.NewUnifiedMarkdownProcessor().ProcessMarkdownForWechat(task.Content, theme)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Markdown转换失败: %w", err)
+		}
+		article.Content = content
+	}
+
+	// 图片转存到平台图床
+	if uploader, ok := p.(ImageUploader); ok {
+		upload := imageRewriter(uploader, account, spec.HostedImageDomains, &warnings)
+		if spec.Format == FormatMarkdown {
+			article.Content = rewriteMarkdownImages(article.Content, upload)
+			article.Markdown = article.Content
+		} else {
+			article.Content = rewriteHTMLImages(article.Content, upload)
+			article.Markdown = rewriteMarkdownImages(article.Markdown, upload)
+		}
+	}
+
+	// 摘要
+	if article.Digest == "" {
+		article.Digest = truncateRunes(plainText(task.Content), defaultDigestLength)
+	}
+
+	// 封面，正文图片已转存时使用转存后的地址
+	if article.CoverURL == "" {
+		article.CoverURL = firstImage(article.Markdown)
+	}
+	if spec.RequiresCover && article.CoverURL == "" {
+		return nil, nil, Permanentf("缺少封面图")
+	}
+
+	// 标签
+	tags, tagWarnings := normalizeTags(task.Tags, spec)
+	article.Tags = tags
+	warnings = append(warnings, tagWarnings...)
+
+	return article, warnings, nil
+}
+
+// normalizeTags 去重并按平台的数量与长度限制截断标签
+func normalizeTags(raw *string, spec Spec) ([]string, []string) {
+	if raw == nil || *raw == "" {
+		return nil, nil
+	}
+	var source []string
+	if err := json.Unmarshal([]byte(*raw), &source); err != nil {
+		return nil, nil
+	}
+
+	var warnings []string
+	if spec.MaxTags == 0 {
+		if len(source) > 0 {
+			warnings = append(warnings, "平台不支持标签，已忽略")
+		}
+		return nil, warnings
+	}
+
+	seen := make(map[string]bool)
+	var tags []string
+	truncated := false
+	for _, tag := range source {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		if spec.MaxTagLength > 0 && utf8.RuneCountInString(tag) > spec.MaxTagLength {
+			tag = truncateRunes(tag, spec.MaxTagLength)
+			truncated = true
+		}
+		key := strings.ToLower(tag)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		tags = append(tags, tag)
+	}
+	if truncated {
+		warnings = append(warnings, fmt.Sprintf("部分标签超过%d字，已截断", spec.MaxTagLength))
+	}
+	if len(tags) > spec.MaxTags {
+		warnings = append(warnings, fmt.Sprintf("标签超过%d个，仅保留前%d个", spec.MaxTags, spec.MaxTags))
+		tags = tags[:spec.MaxTags]
+	}
+	return tags, warnings
+}
+
+// imageRewriter 返回图片转存函数，同一地址只转存一次，失败时保留原地址并记录警告
+func imageRewriter(uploader ImageUploader, account *Account, hostedDomains []string, warnings *[]string) func(string) string {
+	uploaded := make(map[string]string)
+	return func(src string) string {
+		if src == "" || isHostedImage(src, hostedDomains) {
+			return src
+		}
+		if newSrc, ok := uploaded[src]; ok {
+			return newSrc
+		}
+		newSrc, err := uploader.UploadImage(account, src)
+		if err != nil || newSrc == "" {
+			*warnings = append(*warnings, fmt.Sprintf("图片转存失败(%s): %v", truncateRunes(src, 100), err))
+			newSrc = src
+		}
+		uploaded[src] = newSrc
+		return newSrc
+	}
+}
+
+func isHostedImage(src string, domains []string) bool {
+	u, err := url.Parse(src)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func rewriteMarkdownImages(content string, upload func(string) string) string {
+	return reMarkdownImage.ReplaceAllStringFunc(content, func(m string) string {
+		parts := reMarkdownImage.FindStringSubmatch(m)
+		return "![" + parts[1] + "](" + upload(parts[2]) + parts[3] + ")"
+	})
+}
+
+func rewriteHTMLImages(content string, upload func(string) string) string {
+	return reHTMLImgTag.ReplaceAllStringFunc(content, func(tag string) string {
+		m := reHTMLImgSrc.FindStringSubmatchIndex(tag)
+		if m == nil {
+			return tag
+		}
+		quoted := tag[m[4]:m[5]]
+		src := strings.TrimSpace(html.UnescapeString(quoted[1 : len(quoted)-1]))
+		newSrc := upload(src)
+		if newSrc == src {
+			return tag
+		}
+		return tag[:m[4]] + `"` + html.EscapeString(newSrc) + `"` + tag[m[5]:]
+	})
+}
+
+// firstImage 返回 Markdown 或 HTML 正文中第一张图片的地址
+func firstImage(content string) string {
+	mdIdx := reMarkdownImage.FindStringSubmatchIndex(content)
+	htmlIdx := reHTMLImgTag.FindStringIndex(content)
+	if mdIdx != nil && (htmlIdx == nil || mdIdx[0] < htmlIdx[0]) {
+		return content[mdIdx[4]:mdIdx[5]]
+	}
+	if htmlIdx != nil {
+		if m := reHTMLImgSrc.FindStringSubmatch(content[htmlIdx[0]:htmlIdx[1]]); m != nil {
+			return strings.TrimSpace(html.UnescapeString(m[2][1 : len(m[2])-1]))
+		}
+	}
+	return ""
+}
+
+// plainText 去除 Markdown 与 HTML 标记后的纯文本，用于生成摘要
+func plainText(content string) string {
+	text := reMarkdownImage.ReplaceAllString(content, "")
+	text = reHTMLTag.ReplaceAllString(text, "")
+	text = reMarkdownMark.ReplaceAllString(text, "$1")
+	text = html.UnescapeString(text)
+	return strings.TrimSpace(reSpaces.ReplaceAllString(text, " "))
+}
+
+func truncateRunes(s string, n int) string {
+	if n <= 0 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
@@ -0,0 +1,172 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"01agent_server/internal/config"
+	"01agent_server/internal/models"
+	"01agent_server/internal/tools"
+)
+
+const (
+	webClientTimeout = 30 * time.Second
+	maxResponseBytes = 4 << 20
+	maxImageBytes    = 10 << 20
+	webUserAgent     = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+)
+
+// webClient 调用平台网页端接口，凭据为用户绑定时提供的 Cookie
+// 接口地址默认指向平台线上地址，可通过 publish.endpoints 指向本地模拟服务
+type webClient struct {
+	platform models.ArticleSceneType
+	baseURL  string
+	http     *http.Client
+}
+
+func newWebClient(platform models.ArticleSceneType, defaultBaseURL string) *webClient {
+	baseURL := defaultBaseURL
+	if config.AppConfig != nil {
+		if endpoint := config.AppConfig.Publish.Endpoints[string(platform)]; endpoint != "" {
+			baseURL = endpoint
+		}
+	}
+	return &webClient{
+		platform: platform,
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		http:     &http.Client{Timeout: webClientTimeout},
+	}
+}
+
+// requestOptions 单次请求的附加参数
+type requestOptions struct {
+	contentType string
+	headers     map[string]string
+}
+
+// do 发送请求并返回响应体
+// 401/403 视为凭据失效，不再重试；其他 4xx 视为请求内容问题，同样不重试；5xx 与网络错误可重试
+func (c *webClient) do(method, apiPath string, account *Account, body io.Reader, opts requestOptions) ([]byte, error) {
+	req, err := http.NewRequest(method, c.baseURL+apiPath, body)
+	if err != nil {
+		return nil, Permanent(err)
+	}
+	req.Header.Set("User-Agent", webUserAgent)
+	req.Header.Set("Accept", "application/json")
+	if opts.contentType != "" {
+		req.Header.Set("Content-Type", opts.contentType)
+	}
+	if account != nil && account.Cookie != "" {
+		req.Header.Set("Cookie", account.Cookie)
+	}
+	for k, v := range opts.headers {
+		if v != "" {
+			req.Header.Set(k, v)
+		}
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求%s接口失败: %w", c.platform, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("读取%s接口响应失败: %w", c.platform, err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, Permanent(ErrCredentialExpired)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, fmt.Errorf("%s接口响应异常: HTTP %d", c.platform, resp.StatusCode)
+	case resp.StatusCode >= 400:
+		return nil, Permanentf("%s接口拒绝请求: HTTP %d %s", c.platform, resp.StatusCode, truncateRunes(string(data), 200))
+	}
+	return data, nil
+}
+
+// doJSON 以 JSON 提交请求并解析 JSON 响应
+func (c *webClient) doJSON(method, apiPath string, account *Account, payload interface{}, headers map[string]string, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return Permanent(err)
+		}
+		body = bytes.NewReader(data)
+	}
+	data, err := c.do(method, apiPath, account, body, requestOptions{contentType: "application/json", headers: headers})
+	if err != nil {
+		return err
+	}
+	return c.decode(data, out)
+}
+
+// doForm 以表单提交请求并解析 JSON 响应
+func (c *webClient) doForm(apiPath string, account *Account, form url.Values, headers map[string]string, out interface{}) error {
+	data, err := c.do(http.MethodPost, apiPath, account, strings.NewReader(form.Encode()),
+		requestOptions{contentType: "application/x-www-form-urlencoded", headers: headers})
+	if err != nil {
+		return err
+	}
+	return c.decode(data, out)
+}
+
+// doFile 以 multipart/form-data 上传文件并解析 JSON 响应
+func (c *webClient) doFile(apiPath string, account *Account, field, filename string, file []byte, headers map[string]string, out interface{}) error {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	part, err := writer.CreateFormFile(field, filename)
+	if err != nil {
+		return Permanent(err)
+	}
+	if _, err := part.Write(file); err != nil {
+		return Permanent(err)
+	}
+	if err := writer.Close(); err != nil {
+		return Permanent(err)
+	}
+	data, err := c.do(http.MethodPost, apiPath, account, &buf, requestOptions{contentType: writer.FormDataContentType(), headers: headers})
+	if err != nil {
+		return err
+	}
+	return c.decode(data, out)
+}
+
+func (c *webClient) decode(data []byte, out interface{}) error {
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		// 凭据失效时部分平台返回登录页
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("<")) {
+			return Permanent(ErrCredentialExpired)
+		}
+		return fmt.Errorf("解析%s接口响应失败: %w", c.platform, err)
+	}
+	return nil
+}
+
+// fetchImage 下载图片，支持 http(s) 地址与 base64 data URI，返回数据与带扩展名的文件名
+// 地址被禁止访问时不再重试
+func (c *webClient) fetchImage(src string) ([]byte, string, error) {
+	img, err := tools.FetchImage(context.Background(), src, maxImageBytes)
+	if errors.Is(err, tools.ErrBlockedAddress) || errors.Is(err, tools.ErrBlockedPort) || errors.Is(err, tools.ErrUnsupportedImageSrc) {
+		return nil, "", Permanent(err)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return img.Data, img.Filename, nil
+}
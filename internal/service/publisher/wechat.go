@@ -0,0 +1,120 @@
+package publisher
+
+import (
+	"time"
+
+	"01agent_server/internal/models"
+	"01agent_server/internal/service/wechatmp"
+)
+
+// wechatPublishWait 发布后在适配器内等待审核结果的时间，超时后由发布调度定期查询
+const wechatPublishWait = time.Minute
+
+// wechatPublisher 微信公众号，通过第三方平台代公众号创建草稿并发布
+type wechatPublisher struct{}
+
+func (p *wechatPublisher) Platform() models.ArticleSceneType {
+	return models.ArticleSceneWeixin
+}
+
+func (p *wechatPublisher) Spec() Spec {
+	return Spec{
+		Format:         FormatWechatHTML,
+		MaxTitleLength: 64,
+		RequiresCover:  true,
+	}
+}
+
+func (p *wechatPublisher) Publish(account *Account, article *Article, mode string) (*Result, error) {
+	client, err := wechatmp.GetPlatformClient()
+	if err != nil {
+		return nil, Permanent(err)
+	}
+
+	// 公众号正文图片需上传到微信图床，接口按公众号凭据调用，因此不走 ImageUploader
+	content, imageWarnings := client.RewriteContentImages(account.AppID, article.Content)
+	var warnings []string
+	for _, w := range imageWarnings {
+		warnings = append(warnings, "图片上传失败("+w.Src+"): "+w.Error)
+	}
+
+	thumbMediaID, err := client.UploadThumb(account.AppID, article.CoverURL)
+	if err != nil {
+		return nil, wechatError(err)
+	}
+
+	needOpenComment, onlyFans := 0, 0
+	if article.EnableComments {
+		needOpenComment = 1
+		if article.FollowersOnlyComment {
+			onlyFans = 1
+		}
+	}
+	mediaID, err := client.AddDraft(account.AppID, wechatmp.DraftArticle{
+		Title:              article.Title,
+		Author:             article.Author,
+		Digest:             truncateRunes(article.Digest, 120),
+		Content:            content,
+		ThumbMediaID:       thumbMediaID,
+		NeedOpenComment:    needOpenComment,
+		OnlyFansCanComment: onlyFans,
+	})
+	if err != nil {
+		return nil, wechatError(err)
+	}
+	if mode == models.PlatformPublishModeDraft {
+		return &Result{Status: models.PlatformPublishStatusDraft, ExternalID: mediaID, Warnings: warnings}, nil
+	}
+
+	publishID, err := client.SubmitPublish(account.AppID, mediaID)
+	if err != nil {
+		// 草稿已创建，重试会重复创建草稿，保留草稿状态并记录原因
+		warnings = append(warnings, "提交发布失败，文章已保存在草稿箱: "+err.Error())
+		return &Result{Status: models.PlatformPublishStatusDraft, ExternalID: mediaID, Warnings: warnings}, nil
+	}
+
+	result, err := client.WaitForPublish(account.AppID, publishID, wechatmp.DefaultPollInterval, wechatPublishWait)
+	if err != nil || result == nil {
+		return &Result{Status: models.PlatformPublishStatusPublishing, ExternalID: publishID, Warnings: warnings}, nil
+	}
+	r, err := wechatResult(publishID, result)
+	if r != nil {
+		r.Warnings = warnings
+	}
+	return r, err
+}
+
+func (p *wechatPublisher) CheckStatus(account *Account, externalID string) (*Result, error) {
+	client, err := wechatmp.GetPlatformClient()
+	if err != nil {
+		return nil, Permanent(err)
+	}
+	result, err := client.GetPublishResult(account.AppID, externalID)
+	if err != nil {
+		return nil, wechatError(err)
+	}
+	return wechatResult(externalID, result)
+}
+
+func wechatResult(publishID string, result *wechatmp.PublishResult) (*Result, error) {
+	switch {
+	case result.Pending():
+		return &Result{Status: models.PlatformPublishStatusPublishing, ExternalID: publishID}, nil
+	case result.Succeeded():
+		r := &Result{Status: models.PlatformPublishStatusPublished, ExternalID: publishID}
+		if len(result.ArticleURLs) > 0 {
+			r.URL = result.ArticleURLs[0]
+		}
+		return r, nil
+	default:
+		return nil, Permanentf("公众号发布失败: %s", result.StatusMessage())
+	}
+}
+
+// wechatError 授权与参数类错误不可重试，其余（网络、频率限制、系统繁忙）可重试
+func wechatError(err error) error {
+	if wechatmp.Retryable(err) {
+		return err
+	}
+	return Permanent(err)
+}
@@ -0,0 +1,101 @@
+package publisher
+
+import (
+	"fmt"
+	"net/http"
+
+	"01agent_server/internal/models"
+)
+
+// zhihuPublisher 知乎专栏文章
+// 正文为 HTML，图片由知乎在保存草稿时自动转存；话题最多 3 个
+type zhihuPublisher struct {
+	client *webClient
+}
+
+func newZhihuPublisher() *zhihuPublisher {
+	return &zhihuPublisher{client: newWebClient(models.ArticleSceneZhihu, "https://zhuanlan.zhihu.com")}
+}
+
+func (p *zhihuPublisher) Platform() models.ArticleSceneType {
+	return models.ArticleSceneZhihu
+}
+
+func (p *zhihuPublisher) Spec() Spec {
+	return Spec{
+		Format:             FormatHTML,
+		MaxTitleLength:     100,
+		MaxTags:            3,
+		MaxTagLength:       20,
+		HostedImageDomains: []string{"zhimg.com"},
+	}
+}
+
+func (p *zhihuPublisher) headers(account *Account) map[string]string {
+	return map[string]string{
+		"x-xsrftoken": account.CSRFToken,
+		"Origin":      "https://zhuanlan.zhihu.com",
+		"Referer":     "https://zhuanlan.zhihu.com/write",
+	}
+}
+
+func (p *zhihuPublisher) Publish(account *Account, article *Article, mode string) (*Result, error) {
+	headers := p.headers(account)
+
+	var draft struct {
+		ID int64 `json:"id"`
+	}
+	if err := p.client.doJSON(http.MethodPost, "/api/articles/drafts", account, map[string]interface{}{
+		"title":      article.Title,
+		"content":    article.Content,
+		"delta_time": 0,
+	}, headers, &draft); err != nil {
+		return nil, err
+	}
+	if draft.ID == 0 {
+		return nil, fmt.Errorf("知乎创建草稿未返回文章ID")
+	}
+	articleID := fmt.Sprintf("%d", draft.ID)
+
+	// 封面与摘要需在草稿创建后单独保存
+	if err := p.client.doJSON(http.MethodPatch, "/api/articles/"+articleID+"/draft", account, map[string]interface{}{
+		"title":                  article.Title,
+		"content":                article.Content,
+		"titleImage":             article.CoverURL,
+		"isTitleImageFullScreen": false,
+		"summary":                article.Digest,
+	}, headers, nil); err != nil {
+		return nil, err
+	}
+
+	if mode == models.PlatformPublishModeDraft {
+		return &Result{Status: models.PlatformPublishStatusDraft, ExternalID: articleID}, nil
+	}
+
+	topics := make([]map[string]string, 0, len(article.Tags))
+	for _, tag := range article.Tags {
+		topics = append(topics, map[string]string{"name": tag})
+	}
+	commentPermission := "anyone"
+	if !article.EnableComments {
+		commentPermission = "censor"
+	} else if article.FollowersOnlyComment {
+		commentPermission = "follower"
+	}
+	publish := map[string]interface{}{
+		"commentPermission": commentPermission,
+		"topics":            topics,
+	}
+	if column := account.Option("column"); column != "" {
+		publish["column"] = map[string]string{"id": column}
+	}
+	if err := p.client.doJSON(http.MethodPut, "/api/articles/"+articleID+"/publish", account, publish, headers, nil); err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		Status:     models.PlatformPublishStatusPublished,
+		ExternalID: articleID,
+		URL:        "https://zhuanlan.zhihu.com/p/" + articleID,
+	}, nil
+}
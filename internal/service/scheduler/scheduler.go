@@ -0,0 +1,259 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"01agent_server/internal/config"
+	"01agent_server/internal/models"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/service"
+	"01agent_server/internal/tools"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	schedulePollInterval = 15 * time.Second
+	scheduleBatchSize    = 20
+	scheduleLockDuration = 10 * time.Minute // 需覆盖公众号发布后等待审核结果的时间
+	scheduleLockKey      = "scheduler:publish:lock:"
+	scheduleLockDB       = 0
+	defaultMaxAttempts   = 5
+	retryBase            = time.Minute
+	retryMax             = 30 * time.Minute
+)
+
+// 选题计划状态
+const (
+	topicStatusDraft     = "draft"
+	topicStatusScheduled = "scheduled"
+	topicStatusPublished = "published"
+	topicStatusExpired   = "expired"
+)
+
+// Scheduler 定时发布调度器
+// 任务持久化在数据库中，重启后继续执行；多实例下通过 Redis 锁与数据库条件更新保证每个任务只由一个实例执行
+type Scheduler struct {
+	db      *gorm.DB
+	editSvc *service.ArticleEditService
+	kick    chan struct{}
+	running sync.Once
+}
+
+var (
+	schedulerInstance *Scheduler
+	schedulerOnce     sync.Once
+)
+
+// GetScheduler 获取定时发布调度器单例
+func GetScheduler() *Scheduler {
+	schedulerOnce.Do(func() {
+		schedulerInstance = &Scheduler{
+			db:      repository.DB,
+			editSvc: service.NewArticleEditService(),
+			kick:    make(chan struct{}, 1),
+		}
+	})
+	return schedulerInstance
+}
+
+// Start 启动后台调度协程，重复调用只启动一次
+func (s *Scheduler) Start() {
+	s.running.Do(func() {
+		go func() {
+			ticker := time.NewTicker(schedulePollInterval)
+			defer ticker.Stop()
+			for {
+				if _, err := s.RunDue(); err != nil {
+					repository.Errorf("Run scheduled publishes failed: %v", err)
+				}
+				select {
+				case <-ticker.C:
+				case <-s.kick:
+				}
+			}
+		}()
+		repository.Infof("Publish scheduler started, polling every %v", schedulePollInterval)
+	})
+}
+
+// Kick 通知调度协程立即检查到期任务
+func (s *Scheduler) Kick() {
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
+
+// RunDue 执行到期的定时发布，返回处理的任务数
+// 执行中但锁已过期的任务（进程崩溃遗留）会被重新执行
+func (s *Scheduler) RunDue() (int, error) {
+	now := time.Now()
+	var jobs []models.ArticleScheduledPublish
+	err := s.db.Where("(status = ? AND next_run_at <= ? AND (locked_until IS NULL OR locked_until < ?)) OR (status = ? AND locked_until < ?)",
+		models.ScheduledPublishStatusScheduled, now, now, models.ScheduledPublishStatusRunning, now).
+		Order("next_run_at ASC").
+		Limit(scheduleBatchSize).
+		Find(&jobs).Error
+	if err != nil {
+		return 0, fmt.Errorf("查询到期定时发布失败: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range jobs {
+		wg.Add(1)
+		go func(job *models.ArticleScheduledPublish) {
+			defer wg.Done()
+			s.run(job)
+		}(&jobs[i])
+	}
+	wg.Wait()
+	return len(jobs), nil
+}
+
+// run 加锁并执行单个定时发布
+func (s *Scheduler) run(job *models.ArticleScheduledPublish) {
+	// Redis 锁先挡住其他实例，Redis 不可用时仍由数据库条件更新保证只执行一次
+	lockKey := scheduleLockKey + strconv.Itoa(job.ID)
+	redis := tools.GetRedisInstance()
+	if locked, err := redis.SetNX(lockKey, "1", int(scheduleLockDuration.Seconds()), scheduleLockDB); err == nil && !locked {
+		return
+	}
+	defer redis.Delete(lockKey, scheduleLockDB)
+
+	now := time.Now()
+	claim := s.db.Model(&models.ArticleScheduledPublish{}).
+		Where("id = ? AND status = ? AND attempts = ? AND (locked_until IS NULL OR locked_until < ?)",
+			job.ID, job.Status, job.Attempts, now).
+		Updates(map[string]interface{}{
+			"status":       models.ScheduledPublishStatusRunning,
+			"attempts":     job.Attempts + 1,
+			"locked_until": now.Add(scheduleLockDuration),
+		})
+	if claim.Error != nil {
+		repository.Errorf("Claim scheduled publish %d failed: %v", job.ID, claim.Error)
+		return
+	}
+	if claim.RowsAffected == 0 {
+		return
+	}
+	job.Attempts++
+
+	defer func() {
+		if r := recover(); r != nil {
+			repository.Errorf("Scheduled publish %d panic: %v", job.ID, r)
+			s.fail(job, &service.PublishTaskError{Reason: fmt.Sprintf("发布 panic: %v", r)})
+		}
+	}()
+
+	syncOnline := job.Mode != models.PlatformPublishModeDraft
+	err := s.editSvc.PublishEditTask(job.EditTaskID, &service.PublishEditTaskRequest{SyncOnline: &syncOnline}, job.UserID)
+	if err != nil {
+		s.fail(job, err)
+		return
+	}
+	s.succeed(job)
+}
+
+func (s *Scheduler) succeed(job *models.ArticleScheduledPublish) {
+	now := time.Now()
+	if err := s.db.Model(&models.ArticleScheduledPublish{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+		"status":       models.ScheduledPublishStatusSucceeded,
+		"last_error":   nil,
+		"locked_until": nil,
+		"finished_at":  now,
+	}).Error; err != nil {
+		repository.Errorf("Save scheduled publish %d result failed: %v", job.ID, err)
+	}
+	if job.TopicID != nil {
+		s.db.Model(&models.ArticleTopic{}).Where("id = ?", *job.TopicID).Updates(map[string]interface{}{
+			"status":       topicStatusPublished,
+			"publish_time": now,
+		})
+	}
+
+	var task models.ArticleEditTask
+	if err := s.db.Where("id = ?", job.EditTaskID).First(&task).Error; err != nil {
+		repository.Errorf("Load edit task %s for scheduled publish %d failed: %v", job.EditTaskID, job.ID, err)
+		return
+	}
+	content := fmt.Sprintf("文章《%s》已按计划同步到公众号草稿箱。", task.Title)
+	switch {
+	case task.Status == models.ArticleEditStatusPublished:
+		content = fmt.Sprintf("文章《%s》已按计划发布到公众号。", task.Title)
+	case task.WechatPublishID != nil && *task.WechatPublishID != "":
+		content = fmt.Sprintf("文章《%s》已按计划提交发布，正在等待公众号审核。", task.Title)
+	}
+	s.notify(job.UserID, "定时发布成功", content, task.WechatArticleURL, false)
+	repository.Infof("Scheduled publish %d (edit task %s) succeeded", job.ID, job.EditTaskID)
+}
+
+// fail 记录失败原因，可重试的错误按退避策略重新排队，达到上限或不可重试时标记失败并通知用户
+func (s *Scheduler) fail(job *models.ArticleScheduledPublish, err error) {
+	updates := map[string]interface{}{
+		"last_error":   tools.StringPtr(err.Error()),
+		"locked_until": nil,
+	}
+	maxAttempts := defaultMaxAttempts
+	if config.AppConfig != nil && config.AppConfig.Publish.MaxAttempts > 0 {
+		maxAttempts = config.AppConfig.Publish.MaxAttempts
+	}
+
+	var taskErr *service.PublishTaskError
+	retryable := !errors.As(err, &taskErr) || taskErr.Retryable
+	if retryable && job.Attempts < maxAttempts {
+		delay := retryBase
+		if job.Attempts > 1 {
+			delay = retryBase * time.Duration(1<<(job.Attempts-1))
+		}
+		if delay > retryMax {
+			delay = retryMax
+		}
+		updates["status"] = models.ScheduledPublishStatusScheduled
+		updates["next_run_at"] = time.Now().Add(delay)
+		repository.Warnf("Scheduled publish %d (edit task %s) failed (attempt %d), retry in %v: %v",
+			job.ID, job.EditTaskID, job.Attempts, delay, err)
+		s.db.Model(&models.ArticleScheduledPublish{}).Where("id = ?", job.ID).Updates(updates)
+		return
+	}
+
+	updates["status"] = models.ScheduledPublishStatusFailed
+	updates["finished_at"] = time.Now()
+	repository.Errorf("Scheduled publish %d (edit task %s) failed after %d attempts: %v",
+		job.ID, job.EditTaskID, job.Attempts, err)
+	s.db.Model(&models.ArticleScheduledPublish{}).Where("id = ?", job.ID).Updates(updates)
+	if job.TopicID != nil {
+		s.db.Model(&models.ArticleTopic{}).Where("id = ?", *job.TopicID).Update("status", topicStatusExpired)
+	}
+
+	title := job.EditTaskID
+	var task models.ArticleEditTask
+	if s.db.Select("title").Where("id = ?", job.EditTaskID).First(&task).Error == nil {
+		title = task.Title
+	}
+	s.notify(job.UserID, "定时发布失败", fmt.Sprintf("文章《%s》定时发布失败：%s", title, err.Error()), nil, true)
+}
+
+func (s *Scheduler) notify(userID, title, content string, link *string, important bool) {
+	now := time.Now()
+	notification := &models.SystemNotification{
+		NotificationID: uuid.New().String(),
+		UserID:         tools.StringPtr(userID),
+		Type:           "system",
+		Title:          title,
+		Content:        content,
+		Link:           link,
+		IsImportant:    important,
+		Status:         "unread",
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := s.db.Create(notification).Error; err != nil {
+		repository.Errorf("发送定时发布通知失败: user_id=%s, err=%v", userID, err)
+	}
+}
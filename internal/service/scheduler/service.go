@@ -0,0 +1,211 @@
+package scheduler
+
+import (
+	"errors"
+	"time"
+
+	"01agent_server/internal/models"
+	"01agent_server/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrScheduleNotFound 编辑任务没有待执行的定时发布
+	ErrScheduleNotFound = errors.New("没有待执行的定时发布")
+	// ErrScheduleRunning 定时发布正在执行，不能修改或取消
+	ErrScheduleRunning = errors.New("定时发布正在执行中")
+	// ErrScheduleInPast 计划发布时间早于当前时间
+	ErrScheduleInPast = errors.New("发布时间不能早于当前时间")
+	// ErrTopicNotFound 选题计划不存在
+	ErrTopicNotFound = errors.New("选题计划不存在")
+)
+
+// Service 定时发布服务
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService 创建定时发布服务
+func NewService() *Service {
+	return &Service{db: repository.DB}
+}
+
+// Schedule 设置编辑任务的定时发布，已有未执行的定时发布时改为新的时间
+// 关联选题计划时，选题状态同步为 scheduled 并记录计划发布时间
+func (s *Service) Schedule(userID, editTaskID string, scheduledAt time.Time, mode string, topicID *string) (*models.ArticleScheduledPublish, error) {
+	if !scheduledAt.After(time.Now()) {
+		return nil, ErrScheduleInPast
+	}
+	if mode != models.PlatformPublishModeDraft {
+		mode = models.PlatformPublishModePublish
+	}
+	if topicID != nil && *topicID == "" {
+		topicID = nil
+	}
+
+	var job models.ArticleScheduledPublish
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if topicID != nil {
+			var count int64
+			if err := tx.Model(&models.ArticleTopic{}).Where("id = ? AND user_id = ?", *topicID, userID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return ErrTopicNotFound
+			}
+		}
+
+		active, err := s.active(tx, editTaskID)
+		if err != nil {
+			return err
+		}
+		if active != nil && locked(active) {
+			return ErrScheduleRunning
+		}
+
+		if active == nil {
+			job = models.ArticleScheduledPublish{
+				EditTaskID:  editTaskID,
+				UserID:      userID,
+				TopicID:     topicID,
+				Mode:        mode,
+				ScheduledAt: scheduledAt,
+				Status:      models.ScheduledPublishStatusScheduled,
+				NextRunAt:   scheduledAt,
+			}
+			if err := tx.Create(&job).Error; err != nil {
+				return err
+			}
+		} else {
+			// 以状态与尝试次数为条件更新，避免与调度器抢占冲突
+			result := tx.Model(&models.ArticleScheduledPublish{}).
+				Where("id = ? AND status = ? AND attempts = ?", active.ID, models.ScheduledPublishStatusScheduled, active.Attempts).
+				Updates(map[string]interface{}{
+					"topic_id":     topicID,
+					"mode":         mode,
+					"scheduled_at": scheduledAt,
+					"attempts":     0,
+					"last_error":   nil,
+					"next_run_at":  scheduledAt,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrScheduleRunning
+			}
+			if active.TopicID != nil && (topicID == nil || *active.TopicID != *topicID) {
+				if err := resetTopic(tx, *active.TopicID); err != nil {
+					return err
+				}
+			}
+			if err := tx.Where("id = ?", active.ID).First(&job).Error; err != nil {
+				return err
+			}
+		}
+
+		if topicID != nil {
+			return tx.Model(&models.ArticleTopic{}).Where("id = ?", *topicID).Updates(map[string]interface{}{
+				"status":       topicStatusScheduled,
+				"publish_date": scheduledAt,
+				"publish_time": scheduledAt,
+			}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Cancel 取消编辑任务未执行的定时发布，关联的选题计划恢复为草稿
+func (s *Service) Cancel(editTaskID string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		active, err := s.active(tx, editTaskID)
+		if err != nil {
+			return err
+		}
+		if active == nil {
+			return ErrScheduleNotFound
+		}
+		if locked(active) {
+			return ErrScheduleRunning
+		}
+
+		result := tx.Model(&models.ArticleScheduledPublish{}).
+			Where("id = ? AND status = ? AND attempts = ?", active.ID, models.ScheduledPublishStatusScheduled, active.Attempts).
+			Updates(map[string]interface{}{
+				"status":      models.ScheduledPublishStatusCanceled,
+				"finished_at": time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrScheduleRunning
+		}
+		if active.TopicID != nil {
+			return resetTopic(tx, *active.TopicID)
+		}
+		return nil
+	})
+}
+
+// Get 编辑任务最近一次的定时发布，没有时返回 nil
+func (s *Service) Get(editTaskID string) (*models.ArticleScheduledPublish, error) {
+	var job models.ArticleScheduledPublish
+	err := s.db.Where("edit_task_id = ?", editTaskID).Order("id DESC").First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// List 用户的定时发布，按计划时间排序
+func (s *Service) List(userID, status string, page, pageSize int) ([]models.ArticleScheduledPublish, int64, error) {
+	query := s.db.Model(&models.ArticleScheduledPublish{}).Where("user_id = ?", userID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var jobs []models.ArticleScheduledPublish
+	err := query.Order("scheduled_at ASC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&jobs).Error
+	return jobs, total, err
+}
+
+// active 编辑任务未结束（等待或执行中）的定时发布
+func (s *Service) active(tx *gorm.DB, editTaskID string) (*models.ArticleScheduledPublish, error) {
+	var job models.ArticleScheduledPublish
+	err := tx.Where("edit_task_id = ? AND status IN ?", editTaskID,
+		[]string{models.ScheduledPublishStatusScheduled, models.ScheduledPublishStatusRunning}).
+		Order("id DESC").First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// locked 定时发布正在执行
+func locked(job *models.ArticleScheduledPublish) bool {
+	return job.Status == models.ScheduledPublishStatusRunning ||
+		(job.LockedUntil != nil && job.LockedUntil.After(time.Now()))
+}
+
+// resetTopic 选题不再关联定时发布时恢复为草稿，已发布的选题保持不变
+func resetTopic(tx *gorm.DB, topicID string) error {
+	return tx.Model(&models.ArticleTopic{}).
+		Where("id = ? AND status = ?", topicID, topicStatusScheduled).
+		Update("status", topicStatusDraft).Error
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"01agent_server/internal/models"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/tools"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrRefreshTokenInvalid refresh token 无效或会话已停用
+	ErrRefreshTokenInvalid = errors.New("refresh token无效，请重新登录")
+	// ErrRefreshTokenExpired refresh token 已过期
+	ErrRefreshTokenExpired = errors.New("refresh token已过期，请重新登录")
+	// ErrRefreshTokenReused 已轮换的 refresh token 被再次使用，整个会话已被停用
+	ErrRefreshTokenReused = errors.New("登录凭证存在异常使用，该设备已下线，请重新登录")
+	// ErrRefreshTokenRotated 并发刷新时较晚到达的请求，客户端应使用先返回的新令牌
+	ErrRefreshTokenRotated = errors.New("refresh token已刷新，请使用最新的令牌")
+)
+
+// refreshReuseGrace 上一个 refresh token 在轮换后的宽限时间
+// 宽限期内再次使用视为客户端并发刷新，仅拒绝请求而不停用会话
+const refreshReuseGrace = 30 * time.Second
+
+// TokenPair 登录或刷新后签发的令牌
+type TokenPair struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int64  `json:"expires_in"`         // access token有效期（秒）
+	RefreshExpiresIn int64  `json:"refresh_expires_in"` // refresh token有效期（秒）
+}
+
+// newTokenPair 构建令牌响应
+func newTokenPair(accessToken, refreshToken string) *TokenPair {
+	return &TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		ExpiresIn:        int64(tools.AccessTokenExpire().Seconds()),
+		RefreshExpiresIn: int64(tools.RefreshTokenExpire().Seconds()),
+	}
+}
+
+// generateRefreshToken 生成 refresh token，格式为 {family}.{随机串}
+// family 用于定位会话，随机串保证不可猜测，数据库只保存整体摘要
+func generateRefreshToken(family string) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("生成refresh token失败: %w", err)
+	}
+	return family + "." + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// generateRefreshFamily 生成 refresh token 家族标识
+func generateRefreshFamily() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成refresh token失败: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// PrepareRefreshToken 为待创建的会话生成 refresh token，写入会话字段并返回明文
+func (s *SessionService) PrepareRefreshToken(session *models.UserSession) (string, error) {
+	family, err := generateRefreshFamily()
+	if err != nil {
+		return "", err
+	}
+	refreshToken, err := generateRefreshToken(family)
+	if err != nil {
+		return "", err
+	}
+
+	expiresAt := time.Now().Add(tools.RefreshTokenExpire())
+	refreshHash := repository.HashToken(refreshToken)
+	session.RefreshFamily = &family
+	session.RefreshTokenHash = &refreshHash
+	session.RefreshExpiresAt = &expiresAt
+	return refreshToken, nil
+}
+
+// CreateSession 创建新会话并签发 access token 与 refresh token，所有登录入口都应通过此方法签发令牌
+// session 只需填写用户、登录方式、IP 等信息；会话写入失败时返回错误，不签发任何令牌
+func (s *SessionService) CreateSession(session *models.UserSession, username string) (*TokenPair, error) {
+	accessToken, err := tools.GenerateToken(session.UserID, username)
+	if err != nil {
+		return nil, fmt.Errorf("生成token失败: %w", err)
+	}
+	refreshToken, err := s.PrepareRefreshToken(session)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session.Token = &accessToken
+	session.Status = int16(SessionStatusActive)
+	session.LastActiveTime = now
+	session.CreatedAt = now
+	if err := s.sessionRepo.Create(session); err != nil {
+		return nil, fmt.Errorf("创建会话失败: %w", err)
+	}
+	return newTokenPair(accessToken, refreshToken), nil
+}
+
+// RenewSession 为已有会话重新签发 access token 与 refresh token（同一设备重新登录）
+// fields 为同时更新的其他会话字段；旧令牌立即失效
+func (s *SessionService) RenewSession(session *models.UserSession, accessToken string, fields map[string]interface{}) (string, error) {
+	family := tools.GetStringValue(session.RefreshFamily)
+	if family == "" {
+		var err error
+		if family, err = generateRefreshFamily(); err != nil {
+			return "", err
+		}
+	}
+	refreshToken, err := generateRefreshToken(family)
+	if err != nil {
+		return "", err
+	}
+
+	expiresAt := time.Now().Add(tools.RefreshTokenExpire())
+	accessHash := repository.HashToken(accessToken)
+	refreshHash := repository.HashToken(refreshToken)
+	updates := map[string]interface{}{
+		"token":                   accessToken,
+		"token_hash":              accessHash,
+		"refresh_family":          family,
+		"refresh_token_hash":      refreshHash,
+		"prev_refresh_token_hash": nil,
+		"refresh_rotated_at":      nil,
+		"refresh_expires_at":      expiresAt,
+	}
+	for k, v := range fields {
+		updates[k] = v
+	}
+	if err := s.sessionRepo.UpdateFields(session.ID, updates); err != nil {
+		return "", fmt.Errorf("更新会话令牌失败: %w", err)
+	}
+
+	if session.TokenHash != nil {
+		s.invalidate([]string{*session.TokenHash})
+	}
+	session.Token = &accessToken
+	session.TokenHash = &accessHash
+	session.RefreshFamily = &family
+	session.RefreshTokenHash = &refreshHash
+	session.PrevRefreshTokenHash = nil
+	session.RefreshRotatedAt = nil
+	session.RefreshExpiresAt = &expiresAt
+	return refreshToken, nil
+}
+
+// Refresh 使用 refresh token 换取新的令牌对，旧的 refresh token 随即失效
+// 已轮换的 refresh token 被再次使用时（超出并发宽限期），视为令牌泄露并停用整个会话
+func (s *SessionService) Refresh(refreshToken string) (*TokenPair, error) {
+	family, _, ok := strings.Cut(refreshToken, ".")
+	if !ok || family == "" {
+		return nil, ErrRefreshTokenInvalid
+	}
+	presentedHash := repository.HashToken(refreshToken)
+
+	// 轮换失败说明有并发刷新，重新读取会话后再判断一次
+	for attempt := 0; attempt < 2; attempt++ {
+		session, err := s.sessionRepo.GetByRefreshFamily(family)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrRefreshTokenInvalid
+			}
+			return nil, fmt.Errorf("查询会话失败: %w", err)
+		}
+		if session.Status != int16(SessionStatusActive) {
+			return nil, ErrRefreshTokenInvalid
+		}
+
+		if tools.GetStringValue(session.RefreshTokenHash) != presentedHash {
+			if tools.GetStringValue(session.PrevRefreshTokenHash) == presentedHash &&
+				session.RefreshRotatedAt != nil && time.Since(*session.RefreshRotatedAt) < refreshReuseGrace {
+				return nil, ErrRefreshTokenRotated
+			}
+			repository.Warnf("Refresh token reuse detected: user=%s, session=%d, revoking session", session.UserID, session.ID)
+			if err := s.RevokeSession(session.UserID, session.ID); err != nil && err != ErrSessionNotFound {
+				repository.Errorf("Revoke session %d after refresh token reuse failed: %v", session.ID, err)
+			}
+			return nil, ErrRefreshTokenReused
+		}
+
+		if session.RefreshExpiresAt == nil || time.Now().After(*session.RefreshExpiresAt) {
+			return nil, ErrRefreshTokenExpired
+		}
+
+		pair, rotated, err := s.rotate(session, family, presentedHash)
+		if err != nil {
+			return nil, err
+		}
+		if rotated {
+			return pair, nil
+		}
+	}
+	return nil, ErrRefreshTokenRotated
+}
+
+// rotate 签发新令牌并以当前 refresh token 摘要为条件轮换，返回是否轮换成功
+func (s *SessionService) rotate(session *models.UserSession, family, presentedHash string) (*TokenPair, bool, error) {
+	user, err := repository.NewUserRepository().GetByID(session.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, ErrRefreshTokenInvalid
+		}
+		return nil, false, fmt.Errorf("查询用户失败: %w", err)
+	}
+	if user.Status != 1 {
+		return nil, false, ErrRefreshTokenInvalid
+	}
+
+	username := tools.GetStringValue(user.Username)
+	if username == "" {
+		username = user.UserID
+	}
+	accessToken, err := tools.GenerateToken(user.UserID, username)
+	if err != nil {
+		return nil, false, fmt.Errorf("生成token失败: %w", err)
+	}
+	refreshToken, err := generateRefreshToken(family)
+	if err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now()
+	rotated, err := s.sessionRepo.RotateRefreshToken(session.ID, presentedHash, map[string]interface{}{
+		"token":                   accessToken,
+		"token_hash":              repository.HashToken(accessToken),
+		"refresh_token_hash":      repository.HashToken(refreshToken),
+		"prev_refresh_token_hash": presentedHash,
+		"refresh_rotated_at":      now,
+		"refresh_expires_at":      now.Add(tools.RefreshTokenExpire()),
+		"last_active_time":        now,
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("轮换refresh token失败: %w", err)
+	}
+	if !rotated {
+		return nil, false, nil
+	}
+
+	// 旧的 access token 随轮换失效
+	if session.TokenHash != nil {
+		s.invalidate([]string{*session.TokenHash})
+	}
+	return newTokenPair(accessToken, refreshToken), true, nil
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"01agent_server/internal/models"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/tools"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrSessionRevoked 会话已被登出、踢下线或清理
+	ErrSessionRevoked = errors.New("登录状态已失效，请重新登录")
+	// ErrSessionNotFound 会话不存在或不属于当前用户
+	ErrSessionNotFound = errors.New("会话不存在")
+)
+
+const (
+	// sessionCacheRedisDB 会话状态缓存使用的Redis库（与用户缓存共用）
+	sessionCacheRedisDB = 3
+	// sessionCacheExpire 会话状态缓存时间（秒），会话停用时会主动清除，
+	// 较短的过期时间用于兜底清除与并发回填之间的竞争
+	sessionCacheExpire = 60
+	// sessionActiveDebounce 最后活跃时间的最小更新间隔（秒）
+	sessionActiveDebounce = 300
+)
+
+// SessionState 认证时使用的会话状态
+type SessionState struct {
+	SessionID int    `json:"session_id"`
+	UserID    string `json:"user_id"`
+	Status    int16  `json:"status"`
+}
+
+// SessionService 用户会话服务
+// 认证中间件通过token摘要查找会话，会话状态缓存在Redis中；
+// 所有停用会话的操作都需经过本服务，以便同步清除缓存
+type SessionService struct {
+	sessionRepo *repository.UserSessionRepository
+	redis       *tools.Redis
+}
+
+// NewSessionService 创建会话服务
+func NewSessionService() *SessionService {
+	return &SessionService{
+		sessionRepo: repository.NewUserSessionRepository(),
+		redis:       tools.GetRedisInstance(),
+	}
+}
+
+// Validate 校验token对应的会话是否仍然有效，并按间隔刷新最后活跃时间
+// token已通过签名校验，userID为token中的用户ID
+func (s *SessionService) Validate(token, userID string) (*SessionState, error) {
+	tokenHash := repository.HashToken(token)
+
+	state, err := s.loadState(token, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	if state.Status != int16(SessionStatusActive) || state.UserID != userID {
+		return nil, ErrSessionRevoked
+	}
+
+	s.touch(state.SessionID)
+	return state, nil
+}
+
+// loadState 优先从缓存读取会话状态，未命中时查询数据库并回填缓存
+// 不存在的会话同样缓存为停用状态，避免无效token反复查库
+func (s *SessionService) loadState(token, tokenHash string) (*SessionState, error) {
+	key := sessionCacheKey(tokenHash)
+	if cached, err := s.redis.Get(key, sessionCacheRedisDB); err == nil && cached != "" {
+		var state SessionState
+		if json.Unmarshal([]byte(cached), &state) == nil {
+			return &state, nil
+		}
+	}
+
+	session, err := s.sessionRepo.GetByTokenHash(tokenHash)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		session, err = s.sessionRepo.BackfillTokenHash(token)
+	}
+
+	state := &SessionState{Status: int16(SessionStatusInactive)}
+	switch {
+	case err == nil:
+		state.SessionID = session.ID
+		state.UserID = session.UserID
+		state.Status = session.Status
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, fmt.Errorf("查询会话失败: %w", err)
+	}
+
+	// 缓存写入失败时下次认证回源数据库即可
+	if data, err := json.Marshal(state); err == nil {
+		s.redis.Set(key, string(data), sessionCacheExpire, sessionCacheRedisDB)
+	}
+	return state, nil
+}
+
+// touch 更新会话最后活跃时间，同一会话在间隔内只写一次数据库
+func (s *SessionService) touch(sessionID int) {
+	ok, err := s.redis.SetNX(fmt.Sprintf("session:active:%d", sessionID), "1", sessionActiveDebounce, sessionCacheRedisDB)
+	if err != nil || !ok {
+		return
+	}
+	if err := s.sessionRepo.UpdateLastActiveTime(sessionID); err != nil {
+		repository.Warnf("Update session %d last active time failed: %v", sessionID, err)
+	}
+}
+
+// ListSessions 获取用户的在线设备（按登录时间降序）
+func (s *SessionService) ListSessions(userID string) ([]models.UserSession, error) {
+	return s.sessionRepo.GetActiveSessionsByUserID(userID)
+}
+
+// RevokeSession 将用户的指定设备下线
+func (s *SessionService) RevokeSession(userID string, sessionID int) error {
+	session, err := s.sessionRepo.GetByIDAndUserID(sessionID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionNotFound
+		}
+		return fmt.Errorf("查询会话失败: %w", err)
+	}
+
+	revoked, err := s.sessionRepo.DeactivateByID(userID, sessionID)
+	if err != nil {
+		return fmt.Errorf("停用会话失败: %w", err)
+	}
+	if !revoked {
+		return ErrSessionNotFound
+	}
+	if session.TokenHash != nil {
+		s.invalidate([]string{*session.TokenHash})
+	}
+	repository.Infof("Session %d of user %s revoked", sessionID, userID)
+	return nil
+}
+
+// RevokeToken 停用token对应的会话（登出）
+func (s *SessionService) RevokeToken(token string) error {
+	if err := s.sessionRepo.DeactivateByToken(token); err != nil {
+		return err
+	}
+	s.invalidate([]string{repository.HashToken(token)})
+	return nil
+}
+
+// RevokeAllSessions 停用用户的所有会话
+func (s *SessionService) RevokeAllSessions(userID string) error {
+	return s.withInvalidation(userID, "", func() error {
+		return s.sessionRepo.DeactivateByUserID(userID)
+	})
+}
+
+// DeactivateOtherSessions 停用用户除当前token外的其他会话
+func (s *SessionService) DeactivateOtherSessions(userID, currentToken string) error {
+	return s.withInvalidation(userID, currentToken, func() error {
+		return s.sessionRepo.DeactivateOtherSessions(userID, currentToken)
+	})
+}
+
+// CleanupSessionsKeepRecent 只保留用户最近的N个会话，返回被删除的会话数量
+func (s *SessionService) CleanupSessionsKeepRecent(userID string, keepCount int) (int64, error) {
+	var deleted int64
+	err := s.withInvalidation(userID, "", func() error {
+		var err error
+		deleted, err = s.sessionRepo.CleanupSessionsKeepRecent(userID, keepCount)
+		return err
+	})
+	return deleted, err
+}
+
+// withInvalidation 执行批量停用操作，并清除用户会话的缓存（keepToken 对应的会话除外）
+// 缓存键需在操作前收集，被删除的会话之后将无法再查到摘要
+func (s *SessionService) withInvalidation(userID, keepToken string, fn func() error) error {
+	hashes, err := s.sessionRepo.GetTokenHashesByUserID(userID)
+	if err != nil {
+		repository.Warnf("Get session token hashes for user %s failed: %v", userID, err)
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	if keepToken != "" {
+		keepHash := repository.HashToken(keepToken)
+		filtered := hashes[:0]
+		for _, hash := range hashes {
+			if hash != keepHash {
+				filtered = append(filtered, hash)
+			}
+		}
+		hashes = filtered
+	}
+	s.invalidate(hashes)
+	return nil
+}
+
+// invalidate 清除会话状态缓存
+func (s *SessionService) invalidate(tokenHashes []string) {
+	if len(tokenHashes) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(tokenHashes))
+	for _, hash := range tokenHashes {
+		keys = append(keys, sessionCacheKey(hash))
+	}
+	if err := s.redis.DeleteKeys(keys, sessionCacheRedisDB); err != nil {
+		repository.Warnf("Invalidate session cache failed: %v", err)
+	}
+}
+
+func sessionCacheKey(tokenHash string) string {
+	return "session:token:" + tokenHash
+}
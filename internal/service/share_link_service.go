@@ -0,0 +1,522 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"01agent_server/internal/config"
+	"01agent_server/internal/models"
+	"01agent_server/internal/models/short_post"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/tools"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	shareCodeLength   = 12
+	shareCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz23456789"
+	shareSigLength    = 12 // 签名截取的字节数，编码后 16 个字符
+	shareRedisDB      = 3
+	// sharePasswordMaxFails 同一访问者在锁定窗口内允许输错密码的次数
+	sharePasswordMaxFails = 10
+	sharePasswordLockTTL  = 15 * 60
+	// shareViewDedupeTTL 同一访问者在该时间（秒）内重复打开只计一次访问
+	shareViewDedupeTTL = 30 * 60
+)
+
+var (
+	// ErrShareInvalidResourceType 不支持分享的资源类型
+	ErrShareInvalidResourceType = errors.New("不支持分享的资源类型")
+	// ErrShareResourceNotFound 分享的资源不存在或已删除
+	ErrShareResourceNotFound = errors.New("分享的内容不存在或已删除")
+	// ErrShareLinkNotFound 分享链接不存在、签名错误或已撤销
+	ErrShareLinkNotFound = errors.New("分享链接不存在或已失效")
+	// ErrShareLinkExpired 分享链接已过期
+	ErrShareLinkExpired = errors.New("分享链接已过期")
+	// ErrShareInvalidExpiry 过期时间早于当前时间
+	ErrShareInvalidExpiry = errors.New("过期时间必须晚于当前时间")
+	// ErrSharePasswordRequired 访问需要密码
+	ErrSharePasswordRequired = errors.New("请输入访问密码")
+	// ErrSharePasswordIncorrect 访问密码错误
+	ErrSharePasswordIncorrect = errors.New("访问密码错误")
+	// ErrSharePasswordLocked 密码输错次数过多
+	ErrSharePasswordLocked = errors.New("密码错误次数过多，请稍后再试")
+	// ErrShareSecretMissing 未配置分享链接签名密钥（share.secret 与 jwt.secret 均为空）
+	ErrShareSecretMissing = errors.New("分享功能未配置签名密钥")
+)
+
+// CreateShareLinkParams 创建分享链接参数
+type CreateShareLinkParams struct {
+	ResourceType models.ShareResourceType
+	ResourceID   string
+	Password     string     // 为空时无需密码
+	ExpiresAt    *time.Time // 为空时永久有效
+}
+
+// SharedContent 分享页展示的只读内容
+type SharedContent struct {
+	Link         *models.ShareLink
+	ResourceType models.ShareResourceType
+	Content      map[string]interface{}
+}
+
+// ShareLinkService 项目、编辑任务与模板的只读分享链接
+// 链接令牌由分享码与其 HMAC 签名组成，签名错误的令牌不查询数据库
+type ShareLinkService struct {
+	db             *gorm.DB
+	redis          *tools.Redis
+	articleEditSvc *ArticleEditService
+}
+
+// NewShareLinkService 创建分享链接服务
+func NewShareLinkService() *ShareLinkService {
+	return &ShareLinkService{
+		db:             repository.DB,
+		redis:          tools.GetRedisInstance(),
+		articleEditSvc: NewArticleEditService(),
+	}
+}
+
+// Create 为用户自己的资源创建分享链接
+func (s *ShareLinkService) Create(userID string, params CreateShareLinkParams) (*models.ShareLink, error) {
+	if _, err := shareSecret(); err != nil {
+		return nil, err
+	}
+	if err := s.checkOwner(userID, params.ResourceType, params.ResourceID); err != nil {
+		return nil, err
+	}
+	if params.ExpiresAt != nil && !params.ExpiresAt.After(time.Now()) {
+		return nil, ErrShareInvalidExpiry
+	}
+
+	code, err := randomShareCode()
+	if err != nil {
+		return nil, err
+	}
+	link := &models.ShareLink{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		ResourceType: params.ResourceType,
+		ResourceID:   params.ResourceID,
+		Code:         code,
+		ExpiresAt:    params.ExpiresAt,
+	}
+	if params.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(params.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		link.PasswordHash = tools.StringPtr(string(hash))
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(link).Error; err != nil {
+			return err
+		}
+		if link.ResourceType != models.ShareResourceUserTemplate {
+			return nil
+		}
+		// 用户模板沿用原有的分享字段：标记为分享链接可见并记录分享码与分享次数
+		updates := map[string]interface{}{
+			"share_count": gorm.Expr("share_count + 1"),
+			"share_code":  gorm.Expr("COALESCE(share_code, ?)", link.Code),
+		}
+		if err := tx.Model(&models.UserTemplate{}).
+			Where("template_id = ? AND visibility = ?", link.ResourceID, models.VisibilityTypePrivate).
+			Update("visibility", models.VisibilityTypeShared).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.UserTemplate{}).Where("template_id = ?", link.ResourceID).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// List 用户创建的分享链接，可按资源筛选；默认不返回已撤销的链接
+func (s *ShareLinkService) List(userID string, resourceType models.ShareResourceType, resourceID string, includeRevoked bool) ([]models.ShareLink, error) {
+	query := s.db.Where("user_id = ?", userID)
+	if resourceType != "" {
+		query = query.Where("resource_type = ?", resourceType)
+	}
+	if resourceID != "" {
+		query = query.Where("resource_id = ?", resourceID)
+	}
+	if !includeRevoked {
+		query = query.Where("revoked_at IS NULL")
+	}
+	var links []models.ShareLink
+	err := query.Order("created_at DESC").Find(&links).Error
+	return links, err
+}
+
+// Revoke 撤销分享链接，重复撤销直接返回
+func (s *ShareLinkService) Revoke(userID, linkID string) (*models.ShareLink, error) {
+	var link models.ShareLink
+	err := s.db.Where("id = ? AND user_id = ?", linkID, userID).First(&link).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrShareLinkNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if link.RevokedAt != nil {
+		return &link, nil
+	}
+
+	now := time.Now()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&link).Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		if link.ResourceType != models.ShareResourceUserTemplate {
+			return nil
+		}
+		// 用户模板的分享码指向其余仍有效的链接；全部撤销后恢复为私有
+		var next models.ShareLink
+		err := tx.Where("resource_type = ? AND resource_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)",
+			link.ResourceType, link.ResourceID, now).
+			Order("created_at DESC").First(&next).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tx.Model(&models.UserTemplate{}).Where("template_id = ?", link.ResourceID).
+				Updates(map[string]interface{}{
+					"share_code": nil,
+					"visibility": gorm.Expr("CASE WHEN visibility = ? THEN ? ELSE visibility END", models.VisibilityTypeShared, models.VisibilityTypePrivate),
+				}).Error
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.UserTemplate{}).Where("template_id = ? AND share_code = ?", link.ResourceID, link.Code).
+			Update("share_code", next.Code).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	link.RevokedAt = &now
+	return &link, nil
+}
+
+// Token 分享链接的访问令牌
+func (s *ShareLinkService) Token(link *models.ShareLink) (string, error) {
+	sig, err := s.sign(link.Code)
+	if err != nil {
+		return "", err
+	}
+	return link.Code + "." + sig, nil
+}
+
+// URL 分享页完整地址，未配置分享页地址时返回空
+func (s *ShareLinkService) URL(link *models.ShareLink) (string, error) {
+	if config.AppConfig == nil || config.AppConfig.Share.BaseURL == "" {
+		return "", nil
+	}
+	token, err := s.Token(link)
+	if err != nil {
+		return "", err
+	}
+	return config.AppConfig.Share.BaseURL + token, nil
+}
+
+// Resolve 校验令牌签名并返回仍有效的分享链接
+func (s *ShareLinkService) Resolve(token string) (*models.ShareLink, error) {
+	code, sig, ok := strings.Cut(token, ".")
+	if !ok || code == "" {
+		return nil, ErrShareLinkNotFound
+	}
+	expected, err := s.sign(code)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return nil, ErrShareLinkNotFound
+	}
+
+	var link models.ShareLink
+	err = s.db.Where("code = ?", code).First(&link).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrShareLinkNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if link.RevokedAt != nil {
+		return nil, ErrShareLinkNotFound
+	}
+	if link.ExpiresAt != nil && !link.ExpiresAt.After(time.Now()) {
+		return nil, ErrShareLinkExpired
+	}
+	return &link, nil
+}
+
+// Open 校验访问密码后返回分享的内容并记录访问；viewerKey 标识访问者（如 IP），用于密码错误限流与访问去重
+func (s *ShareLinkService) Open(token, password, viewerKey string) (*SharedContent, error) {
+	link, err := s.Resolve(token)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkPassword(link, password, viewerKey); err != nil {
+		return nil, err
+	}
+
+	content, err := s.loadContent(link)
+	if err != nil {
+		return nil, err
+	}
+	s.recordView(link, viewerKey)
+	return &SharedContent{Link: link, ResourceType: link.ResourceType, Content: content}, nil
+}
+
+func (s *ShareLinkService) checkPassword(link *models.ShareLink, password, viewerKey string) error {
+	if link.PasswordHash == nil {
+		return nil
+	}
+	if password == "" {
+		return ErrSharePasswordRequired
+	}
+
+	// 无法读取或记录输错次数时按已锁定处理，避免 Redis 故障期间密码可被无限次尝试
+	failKey := fmt.Sprintf("share:pwd_fail:%s:%s", link.ID, viewerKey)
+	value, err := s.redis.Get(failKey, shareRedisDB)
+	if err != nil {
+		repository.Errorf("Load share password failures failed: %v", err)
+		return ErrSharePasswordLocked
+	}
+	if fails, _ := strconv.Atoi(value); fails >= sharePasswordMaxFails {
+		return ErrSharePasswordLocked
+	}
+	if bcrypt.CompareHashAndPassword([]byte(*link.PasswordHash), []byte(password)) != nil {
+		if _, err := s.redis.Incr(failKey, sharePasswordLockTTL, shareRedisDB); err != nil {
+			repository.Errorf("Record share password failure failed: %v", err)
+			return ErrSharePasswordLocked
+		}
+		return ErrSharePasswordIncorrect
+	}
+	return nil
+}
+
+// recordView 累加访问次数，同一访问者短时间内重复打开只计一次；Redis 不可用时每次都计数
+func (s *ShareLinkService) recordView(link *models.ShareLink, viewerKey string) {
+	first, err := s.redis.SetNX(fmt.Sprintf("share:view:%s:%s", link.ID, viewerKey), "1", shareViewDedupeTTL, shareRedisDB)
+	if err == nil && !first {
+		return
+	}
+	now := time.Now()
+	if err := s.db.Model(&models.ShareLink{}).Where("id = ?", link.ID).Updates(map[string]interface{}{
+		"view_count":     gorm.Expr("view_count + 1"),
+		"last_viewed_at": now,
+	}).Error; err != nil {
+		repository.Warnf("Record share link %s view failed: %v", link.ID, err)
+		return
+	}
+	link.ViewCount++
+	link.LastViewedAt = &now
+}
+
+// checkOwner 确认资源存在且属于用户
+func (s *ShareLinkService) checkOwner(userID string, resourceType models.ShareResourceType, resourceID string) error {
+	var query *gorm.DB
+	switch resourceType {
+	case models.ShareResourceShortPostProject:
+		query = s.db.Model(&short_post.ShortPostProject{}).Where("id = ? AND user_id = ?", resourceID, userID)
+	case models.ShareResourceShortPostTemplate:
+		query = s.db.Model(&short_post.ShortPostTemplate{}).Where("id = ? AND user_id = ?", resourceID, userID)
+	case models.ShareResourceArticleEdit:
+		query = s.db.Model(&models.ArticleEditTask{}).Where("id = ? AND user_id = ?", resourceID, userID)
+	case models.ShareResourceUserTemplate:
+		query = s.db.Model(&models.UserTemplate{}).Where("template_id = ? AND user_id = ? AND status <> ?", resourceID, userID, models.TemplateStatusDeleted)
+	default:
+		return ErrShareInvalidResourceType
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrShareResourceNotFound
+	}
+	return nil
+}
+
+// loadContent 读取分享页展示的内容，只返回只读展示需要的字段
+func (s *ShareLinkService) loadContent(link *models.ShareLink) (map[string]interface{}, error) {
+	var content map[string]interface{}
+	var err error
+	switch link.ResourceType {
+	case models.ShareResourceShortPostProject:
+		content, err = s.projectContent(link.ResourceID)
+	case models.ShareResourceShortPostTemplate:
+		content, err = s.shortPostTemplateContent(link.ResourceID)
+	case models.ShareResourceArticleEdit:
+		content, err = s.editTaskContent(link.ResourceID)
+	case models.ShareResourceUserTemplate:
+		content, err = s.userTemplateContent(link.ResourceID)
+	default:
+		return nil, ErrShareInvalidResourceType
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrShareResourceNotFound
+	}
+	return content, err
+}
+
+func (s *ShareLinkService) projectContent(projectID string) (map[string]interface{}, error) {
+	var project short_post.ShortPostProject
+	if err := s.db.Where("id = ?", projectID).First(&project).Error; err != nil {
+		return nil, err
+	}
+	var latest short_post.ShortPostProjectContent
+	if err := s.db.Where("project_id = ? AND is_latest = ?", projectID, true).First(&latest).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	content := map[string]interface{}{
+		"name":          project.Name,
+		"description":   project.Description,
+		"project_type":  project.ProjectType,
+		"cover_image":   project.CoverImage,
+		"thumbnail":     project.Thumbnail,
+		"frame_count":   project.FrameCount,
+		"canvas_config": rawJSON(latest.CanvasConfig),
+		"frames_data":   rawJSON(latest.FramesData),
+		"elements_data": rawJSON(latest.ElementsData),
+		"updated_at":    project.UpdatedAt.Format(time.RFC3339),
+		"copywriting":   nil,
+	}
+	var cw short_post.ShortPostProjectCopywriting
+	if err := s.db.Where("project_id = ?", projectID).First(&cw).Error; err == nil {
+		content["copywriting"] = map[string]interface{}{
+			"title":   cw.Title,
+			"content": cw.Content,
+			"topics":  rawJSON(cw.Topics),
+			"images":  rawJSON(cw.Images),
+		}
+	}
+	return content, nil
+}
+
+func (s *ShareLinkService) shortPostTemplateContent(templateID string) (map[string]interface{}, error) {
+	var template short_post.ShortPostTemplate
+	if err := s.db.Where("id = ?", templateID).First(&template).Error; err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"name":          template.Name,
+		"description":   template.Description,
+		"project_type":  template.ProjectType,
+		"thumbnail":     template.Thumbnail,
+		"frame_count":   template.FrameCount,
+		"tags":          rawJSON(template.Tags),
+		"slots":         rawJSON(template.Slots),
+		"canvas_config": rawJSON(template.CanvasConfig),
+		"frames_data":   rawJSON(template.FramesData),
+		"elements_data": rawJSON(template.ElementsData),
+		"updated_at":    template.UpdatedAt.Format(time.RFC3339),
+	}, nil
+}
+
+func (s *ShareLinkService) editTaskContent(taskID string) (map[string]interface{}, error) {
+	var task models.ArticleEditTask
+	if err := s.db.Where("id = ?", taskID).First(&task).Error; err != nil {
+		return nil, err
+	}
+	// 还没有生成 section_html 的任务按当前主题渲染，不写回数据库
+	sectionHTML := task.SectionHTML
+	if sectionHTML == nil && task.Content != "" {
+		theme := task.Theme
+		if theme == "" || theme == "none" {
+			theme = "default"
+		}
+		if html, _, err := s.articleEditSvc.ConvertMarkdownToHTML(task.Content, theme, RenderModeHTML); err == nil {
+			sectionHTML = &html
+		} else {
+			repository.Warnf("Render shared edit task %s failed: %v", task.ID, err)
+		}
+	}
+	return map[string]interface{}{
+		"title":        task.Title,
+		"theme":        task.Theme,
+		"content":      task.Content,
+		"section_html": sectionHTML,
+		"updated_at":   task.UpdatedAt.Format(time.RFC3339),
+	}, nil
+}
+
+func (s *ShareLinkService) userTemplateContent(templateID string) (map[string]interface{}, error) {
+	var template models.UserTemplate
+	if err := s.db.Where("template_id = ? AND status <> ?", templateID, models.TemplateStatusDeleted).First(&template).Error; err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"name":          template.Name,
+		"description":   template.Description,
+		"template_type": template.TemplateType,
+		"section_html":  template.SectionHTML,
+		"preview_url":   template.PreviewURL,
+		"thumbnail_url": template.ThumbnailURL,
+		"primary_color": template.PrimaryColor,
+		"template_data": rawJSON(template.TemplateData),
+		"tags":          rawJSON(template.Tags),
+		"category":      template.Category,
+		"updated_at":    template.UpdatedAt.Format(time.RFC3339),
+	}, nil
+}
+
+// sign 分享码的签名，密钥未配置时使用 JWT 密钥
+func (s *ShareLinkService) sign(code string) (string, error) {
+	secret, err := shareSecret()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("share:" + code))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:shareSigLength]), nil
+}
+
+// shareSecret 分享链接签名密钥，未配置时使用 JWT 密钥；两者都为空时拒绝签名，避免令牌可被伪造
+func shareSecret() (string, error) {
+	if config.AppConfig == nil {
+		return "", ErrShareSecretMissing
+	}
+	if secret := config.AppConfig.Share.Secret; secret != "" {
+		return secret, nil
+	}
+	if secret := config.AppConfig.JWT.Secret; secret != "" {
+		return secret, nil
+	}
+	return "", ErrShareSecretMissing
+}
+
+func randomShareCode() (string, error) {
+	max := big.NewInt(int64(len(shareCodeAlphabet)))
+	b := make([]byte, shareCodeLength)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = shareCodeAlphabet[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// rawJSON 数据库中的 JSON 字段原样输出，为空时输出 null
+func rawJSON(data *string) json.RawMessage {
+	if data == nil || *data == "" {
+		return nil
+	}
+	return json.RawMessage(*data)
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"01agent_server/internal/config"
+	"01agent_server/internal/models"
+	"01agent_server/internal/models/short_post"
+	"01agent_server/internal/tools"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// newTestShareLinkService 创建使用内存数据库与内存 Redis 的分享服务，并准备一个属于 u1 的工程
+func newTestShareLinkService(t *testing.T, cfg *config.Config) (*ShareLinkService, *miniredis.Miniredis) {
+	t.Helper()
+	mr := useTestRedis(t, useTestConfig(t, cfg))
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.ShareLink{}, &short_post.ShortPostProject{},
+		&short_post.ShortPostProjectContent{}, &short_post.ShortPostProjectCopywriting{}); err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.Create(&short_post.ShortPostProject{ID: "p1", UserID: "u1", Name: "工程"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &ShareLinkService{db: db, redis: tools.GetRedisInstance(), articleEditSvc: &ArticleEditService{db: db}}, mr
+}
+
+func createTestShareLink(t *testing.T, svc *ShareLinkService, password string) (*models.ShareLink, string) {
+	t.Helper()
+	link, err := svc.Create("u1", CreateShareLinkParams{
+		ResourceType: models.ShareResourceShortPostProject,
+		ResourceID:   "p1",
+		Password:     password,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := svc.Token(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return link, token
+}
+
+// tamperFirst 替换首位字符；末位字符可能只含填充位，改动后解码结果不变
+func tamperFirst(s string) string {
+	if s[0] == 'A' {
+		return "B" + s[1:]
+	}
+	return "A" + s[1:]
+}
+
+func TestShareLinkRequiresSecret(t *testing.T) {
+	svc, _ := newTestShareLinkService(t, &config.Config{JWT: config.JWTConfig{Secret: "jwt-secret"}})
+	link, token := createTestShareLink(t, svc, "")
+
+	// share.secret 为空时使用 JWT 密钥签名
+	if _, err := svc.Resolve(token); err != nil {
+		t.Fatal(err)
+	}
+
+	config.AppConfig.JWT.Secret = ""
+	if _, err := svc.Create("u1", CreateShareLinkParams{ResourceType: models.ShareResourceShortPostProject, ResourceID: "p1"}); !errors.Is(err, ErrShareSecretMissing) {
+		t.Fatalf("Create: want ErrShareSecretMissing, got %v", err)
+	}
+	if _, err := svc.Token(link); !errors.Is(err, ErrShareSecretMissing) {
+		t.Fatalf("Token: want ErrShareSecretMissing, got %v", err)
+	}
+	if _, err := svc.Resolve(token); !errors.Is(err, ErrShareSecretMissing) {
+		t.Fatalf("Resolve: want ErrShareSecretMissing, got %v", err)
+	}
+}
+
+func TestShareLinkResolve(t *testing.T) {
+	svc, _ := newTestShareLinkService(t, &config.Config{Share: config.ShareConfig{Secret: "share-secret"}})
+	link, token := createTestShareLink(t, svc, "")
+
+	resolved, err := svc.Resolve(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.ID != link.ID {
+		t.Fatalf("resolved wrong link %s", resolved.ID)
+	}
+
+	// 签名错误：篡改签名、篡改分享码、其他密钥签发的令牌
+	code, sig, _ := strings.Cut(token, ".")
+	config.AppConfig.Share.Secret = "other-secret"
+	_, forged := createTestShareLink(t, svc, "")
+	_, forgedSig, _ := strings.Cut(forged, ".")
+	config.AppConfig.Share.Secret = "share-secret"
+	for _, bad := range []string{code + "." + tamperFirst(sig), tamperFirst(code) + "." + sig, code + "." + forgedSig, code, ""} {
+		if _, err := svc.Resolve(bad); !errors.Is(err, ErrShareLinkNotFound) {
+			t.Errorf("Resolve(%q): want ErrShareLinkNotFound, got %v", bad, err)
+		}
+	}
+
+	// 已过期
+	if err := svc.db.Model(link).Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Resolve(token); !errors.Is(err, ErrShareLinkExpired) {
+		t.Fatalf("want ErrShareLinkExpired, got %v", err)
+	}
+
+	// 已撤销
+	if err := svc.db.Model(link).Update("expires_at", nil).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Revoke("u2", link.ID); !errors.Is(err, ErrShareLinkNotFound) {
+		t.Fatalf("other users cannot revoke: %v", err)
+	}
+	if _, err := svc.Revoke("u1", link.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Resolve(token); !errors.Is(err, ErrShareLinkNotFound) {
+		t.Fatalf("want revoked link not found, got %v", err)
+	}
+	if _, err := svc.Open(token, "", "1.2.3.4"); !errors.Is(err, ErrShareLinkNotFound) {
+		t.Fatalf("want revoked link not found on open, got %v", err)
+	}
+}
+
+func TestShareLinkPassword(t *testing.T) {
+	svc, mr := newTestShareLinkService(t, &config.Config{Share: config.ShareConfig{Secret: "share-secret"}})
+	_, token := createTestShareLink(t, svc, "pa55")
+
+	if _, err := svc.Open(token, "", "1.2.3.4"); !errors.Is(err, ErrSharePasswordRequired) {
+		t.Fatalf("want ErrSharePasswordRequired, got %v", err)
+	}
+	for i := 0; i < sharePasswordMaxFails; i++ {
+		if _, err := svc.Open(token, "wrong", "1.2.3.4"); !errors.Is(err, ErrSharePasswordIncorrect) {
+			t.Fatalf("attempt %d: want ErrSharePasswordIncorrect, got %v", i, err)
+		}
+	}
+	// 输错次数达到上限后，正确的密码也被拒绝
+	if _, err := svc.Open(token, "pa55", "1.2.3.4"); !errors.Is(err, ErrSharePasswordLocked) {
+		t.Fatalf("want ErrSharePasswordLocked, got %v", err)
+	}
+
+	// 锁定只针对该访问者
+	shared, err := svc.Open(token, "pa55", "5.6.7.8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shared.Content["name"] != "工程" || shared.Link.ViewCount != 1 {
+		t.Fatalf("unexpected shared content %+v", shared)
+	}
+	// 同一访问者短时间内重复打开只计一次访问
+	if shared, err = svc.Open(token, "pa55", "5.6.7.8"); err != nil || shared.Link.ViewCount != 1 {
+		t.Fatalf("repeat view should not be counted: %+v %v", shared, err)
+	}
+
+	// Redis 不可用时无法确认输错次数，按已锁定处理
+	mr.SetError("connection refused")
+	for _, password := range []string{"pa55", "wrong"} {
+		if _, err := svc.Open(token, password, "9.9.9.9"); !errors.Is(err, ErrSharePasswordLocked) {
+			t.Fatalf("password %q with redis down: want ErrSharePasswordLocked, got %v", password, err)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"01agent_server/internal/config"
+	"01agent_server/internal/repository"
+)
+
+// SMSSender 短信发送接口
+type SMSSender interface {
+	// SendCode 发送验证码短信，phone 为不含区号的手机号
+	SendCode(areaCode, phone, code string, expireMinutes int) error
+}
+
+// ErrSMSNotConfigured 短信发送器配置缺失或无效
+var ErrSMSNotConfigured = errors.New("短信服务未配置")
+
+// NewSMSSender 根据配置创建短信发送器
+// 未指定 driver 时需配置腾讯云密钥；内存发送器不会真正发送短信，必须显式配置 driver: memory
+func NewSMSSender() (SMSSender, error) {
+	cfg := config.SMSConfig{}
+	if config.AppConfig != nil {
+		cfg = config.AppConfig.SMS
+	}
+
+	switch cfg.Driver {
+	case "memory":
+		repository.Warn("Using in-memory SMS sender, verification codes will not be delivered")
+		return NewMemorySMSSender(), nil
+	case "tencent", "":
+		if cfg.SecretID == "" || cfg.SecretKey == "" || cfg.SDKAppID == "" || cfg.TemplateID == "" {
+			return nil, fmt.Errorf("%w: 缺少 secretID、secretKey、sdkAppID 或 templateID", ErrSMSNotConfigured)
+		}
+		return NewTencentSMSSender(cfg), nil
+	default:
+		return nil, fmt.Errorf("%w: 未知的 driver %q", ErrSMSNotConfigured, cfg.Driver)
+	}
+}
+
+// ==================== 腾讯云短信 ====================
+
+const (
+	tencentSMSHost    = "sms.tencentcloudapi.com"
+	tencentSMSService = "sms"
+	tencentSMSAction  = "SendSms"
+	tencentSMSVersion = "2021-01-11"
+)
+
+// TencentSMSSender 腾讯云短信发送器（API 3.0，TC3-HMAC-SHA256 签名）
+type TencentSMSSender struct {
+	cfg      config.SMSConfig
+	endpoint string
+	client   *http.Client
+}
+
+// NewTencentSMSSender 创建腾讯云短信发送器
+func NewTencentSMSSender(cfg config.SMSConfig) *TencentSMSSender {
+	if cfg.Region == "" {
+		cfg.Region = "ap-guangzhou"
+	}
+	return &TencentSMSSender{
+		cfg:      cfg,
+		endpoint: "https://" + tencentSMSHost,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type tencentSendSmsRequest struct {
+	PhoneNumberSet   []string `json:"PhoneNumberSet"`
+	SmsSdkAppId      string   `json:"SmsSdkAppId"`
+	SignName         string   `json:"SignName"`
+	TemplateId       string   `json:"TemplateId"`
+	TemplateParamSet []string `json:"TemplateParamSet"`
+}
+
+type tencentSendSmsResponse struct {
+	Response struct {
+		SendStatusSet []struct {
+			PhoneNumber string `json:"PhoneNumber"`
+			Code        string `json:"Code"`
+			Message     string `json:"Message"`
+		} `json:"SendStatusSet"`
+		Error *struct {
+			Code    string `json:"Code"`
+			Message string `json:"Message"`
+		} `json:"Error"`
+		RequestId string `json:"RequestId"`
+	} `json:"Response"`
+}
+
+// SendCode 发送验证码短信，模板参数为 {1}验证码 {2}有效分钟数
+func (s *TencentSMSSender) SendCode(areaCode, phone, code string, expireMinutes int) error {
+	if areaCode == "" {
+		areaCode = "86"
+	}
+
+	payload, err := json.Marshal(tencentSendSmsRequest{
+		PhoneNumberSet:   []string{"+" + areaCode + phone},
+		SmsSdkAppId:      s.cfg.SDKAppID,
+		SignName:         s.cfg.SignName,
+		TemplateId:       s.cfg.TemplateID,
+		TemplateParamSet: []string{code, strconv.Itoa(expireMinutes)},
+	})
+	if err != nil {
+		return fmt.Errorf("序列化短信请求失败: %w", err)
+	}
+
+	now := time.Now().UTC()
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("创建短信请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Host", tencentSMSHost)
+	req.Header.Set("X-TC-Action", tencentSMSAction)
+	req.Header.Set("X-TC-Version", tencentSMSVersion)
+	req.Header.Set("X-TC-Region", s.cfg.Region)
+	req.Header.Set("X-TC-Timestamp", strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set("Authorization", s.sign(payload, now))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("调用腾讯云短信接口失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取短信响应失败: %w", err)
+	}
+
+	var result tencentSendSmsResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("解析短信响应失败: %w", err)
+	}
+	if result.Response.Error != nil {
+		return fmt.Errorf("腾讯云短信发送失败: %s %s", result.Response.Error.Code, result.Response.Error.Message)
+	}
+	for _, status := range result.Response.SendStatusSet {
+		if status.Code != "Ok" {
+			return fmt.Errorf("腾讯云短信发送失败: %s %s", status.Code, status.Message)
+		}
+	}
+	return nil
+}
+
+// sign 生成 TC3-HMAC-SHA256 签名的 Authorization 头
+func (s *TencentSMSSender) sign(payload []byte, now time.Time) string {
+	date := now.Format("2006-01-02")
+
+	canonicalHeaders := "content-type:application/json; charset=utf-8\nhost:" + tencentSMSHost + "\n"
+	signedHeaders := "content-type;host"
+	canonicalRequest := strings.Join([]string{
+		http.MethodPost,
+		"/",
+		"",
+		canonicalHeaders,
+		signedHeaders,
+		sha256Hex(payload),
+	}, "\n")
+
+	credentialScope := date + "/" + tencentSMSService + "/tc3_request"
+	stringToSign := strings.Join([]string{
+		"TC3-HMAC-SHA256",
+		strconv.FormatInt(now.Unix(), 10),
+		credentialScope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	secretDate := hmacSHA256([]byte("TC3"+s.cfg.SecretKey), date)
+	secretService := hmacSHA256(secretDate, tencentSMSService)
+	secretSigning := hmacSHA256(secretService, "tc3_request")
+	signature := hex.EncodeToString(hmacSHA256(secretSigning, stringToSign))
+
+	return fmt.Sprintf("TC3-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.SecretID, credentialScope, signedHeaders, signature)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// ==================== 内存短信（开发/测试） ====================
+
+// SentSMS 内存发送器记录的短信
+type SentSMS struct {
+	AreaCode string
+	Phone    string
+	Code     string
+	SentAt   time.Time
+}
+
+// memorySMSMaxRecords 内存发送器保留的最近短信条数
+const memorySMSMaxRecords = 100
+
+// MemorySMSSender 内存短信发送器，不实际发送，仅记录最近发送的验证码
+type MemorySMSSender struct {
+	mu   sync.Mutex
+	sent []SentSMS
+}
+
+// NewMemorySMSSender 创建内存短信发送器
+func NewMemorySMSSender() *MemorySMSSender {
+	return &MemorySMSSender{}
+}
+
+// SendCode 记录验证码，超过 memorySMSMaxRecords 条时丢弃最早的记录
+func (s *MemorySMSSender) SendCode(areaCode, phone, code string, expireMinutes int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.sent) >= memorySMSMaxRecords {
+		s.sent = append(s.sent[:0], s.sent[len(s.sent)-memorySMSMaxRecords+1:]...)
+	}
+	s.sent = append(s.sent, SentSMS{
+		AreaCode: areaCode,
+		Phone:    phone,
+		Code:     code,
+		SentAt:   time.Now(),
+	})
+	return nil
+}
+
+// LastCode 获取发送给指定手机号的最后一个验证码
+func (s *MemorySMSSender) LastCode(phone string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.sent) - 1; i >= 0; i-- {
+		if s.sent[i].Phone == phone {
+			return s.sent[i].Code, true
+		}
+	}
+	return "", false
+}
+
+// Sent 获取所有已发送短信
+func (s *MemorySMSSender) Sent() []SentSMS {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SentSMS(nil), s.sent...)
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"01agent_server/internal/config"
+)
+
+func TestNewSMSSenderRequiresExplicitMemoryDriver(t *testing.T) {
+	cases := []struct {
+		name    string
+		sms     config.SMSConfig
+		wantErr bool
+		want    string
+	}{
+		{name: "empty", sms: config.SMSConfig{}, wantErr: true},
+		{name: "tencent without secret", sms: config.SMSConfig{Driver: "tencent", SecretID: "id"}, wantErr: true},
+		{name: "unknown driver", sms: config.SMSConfig{Driver: "aliyun"}, wantErr: true},
+		{name: "memory", sms: config.SMSConfig{Driver: "memory"}, want: "*service.MemorySMSSender"},
+		{
+			name: "secrets without driver",
+			sms:  config.SMSConfig{SecretID: "id", SecretKey: "key", SDKAppID: "1400000000", TemplateID: "1"},
+			want: "*service.TencentSMSSender",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			useTestConfig(t, &config.Config{SMS: tc.sms})
+			sender, err := NewSMSSender()
+			if tc.wantErr {
+				if !errors.Is(err, ErrSMSNotConfigured) {
+					t.Fatalf("want ErrSMSNotConfigured, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprintf("%T", sender); got != tc.want {
+				t.Fatalf("want %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestMemorySMSSenderKeepsRecentRecords(t *testing.T) {
+	sender := NewMemorySMSSender()
+	for i := 0; i < memorySMSMaxRecords+20; i++ {
+		sender.SendCode("86", fmt.Sprintf("1380000%04d", i), fmt.Sprintf("%06d", i), 5)
+	}
+	sent := sender.Sent()
+	if len(sent) != memorySMSMaxRecords {
+		t.Fatalf("want %d records, got %d", memorySMSMaxRecords, len(sent))
+	}
+	if sent[0].Phone != "13800000020" {
+		t.Fatalf("oldest records should be dropped, first is %s", sent[0].Phone)
+	}
+	if code, ok := sender.LastCode("13800000119"); !ok || code != "000119" {
+		t.Fatalf("LastCode = %q, %v", code, ok)
+	}
+	if _, ok := sender.LastCode("13800000000"); ok {
+		t.Fatal("dropped record should not be returned")
+	}
+}
+
+func TestTencentSMSSender(t *testing.T) {
+	var got tencentSendSmsRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-TC-Action") != tencentSMSAction || r.Header.Get("X-TC-Region") != "ap-guangzhou" {
+			t.Errorf("unexpected headers: %v", r.Header)
+		}
+		if auth := r.Header.Get("Authorization"); !strings.HasPrefix(auth, "TC3-HMAC-SHA256 Credential=sid/") {
+			t.Errorf("unexpected authorization: %s", auth)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		if got.PhoneNumberSet[0] == "+8613900000000" {
+			w.Write([]byte(`{"Response":{"SendStatusSet":[{"PhoneNumber":"+8613900000000","Code":"LimitExceeded.PhoneNumberDailyLimit","Message":"daily limit"}],"RequestId":"r2"}}`))
+			return
+		}
+		w.Write([]byte(`{"Response":{"SendStatusSet":[{"PhoneNumber":"+8613800000000","Code":"Ok","Message":"send success"}],"RequestId":"r1"}}`))
+	}))
+	defer server.Close()
+
+	sender := NewTencentSMSSender(config.SMSConfig{SecretID: "sid", SecretKey: "skey", SDKAppID: "1400000000", SignName: "sign", TemplateID: "100"})
+	sender.endpoint = server.URL
+
+	if err := sender.SendCode("", "13800000000", "123456", 5); err != nil {
+		t.Fatal(err)
+	}
+	if got.SmsSdkAppId != "1400000000" || got.TemplateId != "100" || strings.Join(got.TemplateParamSet, ",") != "123456,5" {
+		t.Fatalf("unexpected request: %+v", got)
+	}
+	if err := sender.SendCode("86", "13900000000", "123456", 5); err == nil || !strings.Contains(err.Error(), "PhoneNumberDailyLimit") {
+		t.Fatalf("want provider error, got %v", err)
+	}
+}
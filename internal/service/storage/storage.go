@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"01agent_server/internal/config"
+)
+
+const (
+	defaultLocalDir     = "data/files"
+	defaultLocalURLPath = "/files"
+)
+
+// Storage 文件存储，保存后返回可公开访问的地址
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) (string, error)
+}
+
+var (
+	defaultStorage Storage
+	defaultOnce    sync.Once
+)
+
+// Default 默认存储：配置了 OSS 时上传到 OSS，否则保存到本地目录
+func Default() Storage {
+	defaultOnce.Do(func() {
+		if cfg := config.AppConfig; cfg != nil && cfg.OSS.BucketName != "" && cfg.OSS.AccessKeyID != "" {
+			defaultStorage = NewOSS(cfg.OSS)
+			return
+		}
+		defaultStorage = NewLocal(LocalDir(), localBaseURL())
+	})
+	return defaultStorage
+}
+
+// LocalDir 本地存储目录，由路由注册为静态文件目录
+func LocalDir() string {
+	if cfg := config.AppConfig; cfg != nil && cfg.Export.StorageDir != "" {
+		return cfg.Export.StorageDir
+	}
+	return defaultLocalDir
+}
+
+// LocalURLPath 本地存储文件的访问路径
+func LocalURLPath() string {
+	return defaultLocalURLPath
+}
+
+func localBaseURL() string {
+	if cfg := config.AppConfig; cfg != nil && cfg.Export.PublicBaseURL != "" {
+		return strings.TrimRight(cfg.Export.PublicBaseURL, "/")
+	}
+	return defaultLocalURLPath
+}
+
+// Local 本地磁盘存储
+type Local struct {
+	Dir     string
+	BaseURL string
+}
+
+// NewLocal 创建本地存储
+func NewLocal(dir, baseURL string) *Local {
+	return &Local{Dir: dir, BaseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// Put 写入文件，先写临时文件再重命名，避免读到写了一半的文件
+func (s *Local) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	target := filepath.Join(s.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return "", fmt.Errorf("创建存储目录失败: %w", err)
+	}
+	tmp := target + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return "", fmt.Errorf("写入文件失败: %w", err)
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("写入文件失败: %w", err)
+	}
+	return s.BaseURL + "/" + key, nil
+}
+
+// OSS 阿里云对象存储，使用 PUT Object 接口上传
+type OSS struct {
+	accessKeyID     string
+	accessKeySecret string
+	bucket          string
+	uploadHost      string // 上传地址，优先使用内网地址
+	publicHost      string // 访问地址
+	httpClient      *http.Client
+}
+
+// NewOSS 根据配置创建 OSS 存储
+func NewOSS(cfg config.OSSConfig) *OSS {
+	endpoint := trimScheme(cfg.Endpoint)
+	uploadEndpoint := endpoint
+	if cfg.InternalEndpoint != "" {
+		uploadEndpoint = trimScheme(cfg.InternalEndpoint)
+	}
+	return &OSS{
+		accessKeyID:     cfg.AccessKeyID,
+		accessKeySecret: cfg.AccessKeySecret,
+		bucket:          cfg.BucketName,
+		uploadHost:      cfg.BucketName + "." + uploadEndpoint,
+		publicHost:      cfg.BucketName + "." + endpoint,
+		httpClient:      &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Put 上传文件并返回公开访问地址
+func (s *OSS) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	escaped := (&url.URL{Path: "/" + key}).EscapedPath()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, "https://"+s.uploadHost+escaped, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+
+	sum := md5.Sum(data)
+	contentMD5 := base64.StdEncoding.EncodeToString(sum[:])
+	date := time.Now().UTC().Format(http.TimeFormat)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Content-MD5", contentMD5)
+	req.Header.Set("Date", date)
+	req.Header.Set("Authorization", "OSS "+s.accessKeyID+":"+s.sign(http.MethodPut, contentMD5, contentType, date, key))
+	req.ContentLength = int64(len(data))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("上传 OSS 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("上传 OSS 失败: HTTP %d %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return "https://" + s.publicHost + escaped, nil
+}
+
+// sign OSS V1 签名
+func (s *OSS) sign(method, contentMD5, contentType, date, key string) string {
+	stringToSign := method + "\n" + contentMD5 + "\n" + contentType + "\n" + date + "\n/" + s.bucket + "/" + key
+	mac := hmac.New(sha1.New, []byte(s.accessKeySecret))
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// cleanKey 规范化存储路径，拒绝跳出存储目录的路径
+func cleanKey(key string) (string, error) {
+	cleaned := strings.TrimPrefix(path.Clean("/"+key), "/")
+	if cleaned == "" || cleaned != strings.TrimPrefix(key, "/") {
+		return "", fmt.Errorf("非法的存储路径: %s", key)
+	}
+	return cleaned, nil
+}
+
+func trimScheme(endpoint string) string {
+	endpoint = strings.TrimPrefix(endpoint, "https://")
+	endpoint = strings.TrimPrefix(endpoint, "http://")
+	return strings.TrimRight(endpoint, "/")
+}
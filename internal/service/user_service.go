@@ -64,8 +64,10 @@ func GetMaxSessions(user *models.User) int {
 type UserService struct {
 	userRepo       *repository.UserRepository
 	sessionRepo    *repository.UserSessionRepository
+	sessionSvc     *SessionService
 	parametersRepo *repository.UserParametersRepository
 	invitationRepo *repository.InvitationRepository
+	verifyCodeSvc  *VerifyCodeService
 }
 
 // NewUserService 创建用户服务
@@ -73,8 +75,10 @@ func NewUserService() *UserService {
 	return &UserService{
 		userRepo:       repository.NewUserRepository(),
 		sessionRepo:    repository.NewUserSessionRepository(),
+		sessionSvc:     NewSessionService(),
 		parametersRepo: repository.NewUserParametersRepository(),
 		invitationRepo: repository.NewInvitationRepository(),
+		verifyCodeSvc:  NewVerifyCodeService(),
 	}
 }
 
@@ -185,7 +189,7 @@ func (s *UserService) Register(req *models.UserRegisterRequest) (*models.User, e
 }
 
 // Login 用户登录（传统登录方式）
-func (s *UserService) Login(req *models.UserLoginRequest, ipAddress string) (*models.User, string, error) {
+func (s *UserService) Login(req *models.UserLoginRequest, ipAddress string) (*models.User, *TokenPair, error) {
 	var user *models.User
 	var err error
 
@@ -193,24 +197,24 @@ func (s *UserService) Login(req *models.UserLoginRequest, ipAddress string) (*mo
 	if req.Username != "" {
 		user, err = s.userRepo.GetByUsername(req.Username)
 		if err != nil && err != gorm.ErrRecordNotFound {
-			return nil, "", fmt.Errorf("failed to get user by username: %w", err)
+			return nil, nil, fmt.Errorf("failed to get user by username: %w", err)
 		}
 	}
 
 	if user == nil && req.Email != "" {
 		user, err = s.userRepo.GetByEmail(req.Email)
 		if err != nil && err != gorm.ErrRecordNotFound {
-			return nil, "", fmt.Errorf("failed to get user by email: %w", err)
+			return nil, nil, fmt.Errorf("failed to get user by email: %w", err)
 		}
 	}
 
 	if user == nil {
-		return nil, "", fmt.Errorf("user not found")
+		return nil, nil, fmt.Errorf("user not found")
 	}
 
 	// 验证密码
 	if !user.CheckPassword(req.Password) {
-		return nil, "", fmt.Errorf("invalid password")
+		return nil, nil, fmt.Errorf("invalid password")
 	}
 
 	// 更新最后登录时间
@@ -218,25 +222,17 @@ func (s *UserService) Login(req *models.UserLoginRequest, ipAddress string) (*mo
 		repository.Errorf("Failed to update last login time: %v", err)
 	}
 
-	// 生成JWT token
-	token, err := tools.GenerateToken(user.UserID, tools.GetStringValue(user.Username))
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to generate token: %w", err)
-	}
-
-	// 创建会话记录
-	session := &models.UserSession{
+	// 创建会话并签发令牌，会话创建失败视为登录失败
+	tokens, err := s.sessionSvc.CreateSession(&models.UserSession{
 		UserID:    user.UserID,
-		Token:     tools.StringPtr(token),
+		LoginType: "web",
 		IPAddress: ipAddress,
-		CreatedAt: time.Now(),
-	}
-
-	if err := s.sessionRepo.Create(session); err != nil {
-		repository.Errorf("Failed to create session: %v", err)
+	}, tools.GetStringValue(user.Username))
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return user, token, nil
+	return user, tokens, nil
 }
 
 // GetByID 根据ID获取用户
@@ -303,12 +299,27 @@ func (s *UserService) UpdateUserParameters(userID string, params *models.UserPar
 
 // Logout 用户登出
 func (s *UserService) Logout(userID, token string) error {
-	return s.sessionRepo.DeactivateByToken(token)
+	return s.sessionSvc.RevokeToken(token)
+}
+
+// RefreshTokens 使用 refresh token 换取新的令牌对
+func (s *UserService) RefreshTokens(refreshToken string) (*TokenPair, error) {
+	return s.sessionSvc.Refresh(refreshToken)
 }
 
-// GetActiveSessions 获取用户活跃会话
+// GetActiveSessions 获取用户活跃会话（按登录时间降序）
 func (s *UserService) GetActiveSessions(userID string) ([]models.UserSession, error) {
-	return s.sessionRepo.GetByUserID(userID)
+	return s.sessionSvc.ListSessions(userID)
+}
+
+// RevokeSession 将用户的指定设备下线
+func (s *UserService) RevokeSession(userID string, sessionID int) error {
+	return s.sessionSvc.RevokeSession(userID, sessionID)
+}
+
+// RevokeOtherSessions 将用户除当前设备外的其他设备下线
+func (s *UserService) RevokeOtherSessions(userID, currentToken string) error {
+	return s.sessionSvc.DeactivateOtherSessions(userID, currentToken)
 }
 
 // LoginRequest 登录请求（用于多种登录类型）- 对应Python的LoginData
@@ -317,12 +328,14 @@ type LoginRequest struct {
 	Identifier string `json:"identifier"`  // 标识符
 	InviteCode string `json:"invite_code"` // 邀请码
 	UtmSource  string `json:"utm_source"`  // 用户来源
+	Code       string `json:"code"`        // 验证码（手机号/邮箱登录时，未预先调用验证接口则必填）
 }
 
 // LoginResult 登录结果
 type LoginResult struct {
 	User        *models.User
 	Token       string
+	Tokens      *TokenPair // access token 与 refresh token
 	Session     *models.UserSession
 	MaxSessions int
 	LoginMsg    string
@@ -330,12 +343,12 @@ type LoginResult struct {
 }
 
 // LoginWithType 支持多种登录类型的登录方法（对应Python的/auth/login）
-func (s *UserService) LoginWithType(req *LoginRequest, ipAddress, deviceID, oldToken string) (*models.User, string, *models.UserSession, error) {
+func (s *UserService) LoginWithType(req *LoginRequest, ipAddress, deviceID, oldToken string) (*models.User, *TokenPair, *models.UserSession, error) {
 	result, err := s.LoginWithTypeV2(req, ipAddress, deviceID, oldToken)
 	if err != nil {
-		return nil, "", nil, err
+		return nil, nil, nil, err
 	}
-	return result.User, result.Token, result.Session, nil
+	return result.User, result.Tokens, result.Session, nil
 }
 
 // LoginWithTypeV2 支持多种登录类型的登录方法（返回更详细的结果）
@@ -355,6 +368,10 @@ func (s *UserService) LoginWithTypeV2(req *LoginRequest, ipAddress, deviceID, ol
 	// 根据登录类型查找或创建用户
 	switch req.LoginType {
 	case "phone":
+		// 手机号登录必须通过短信验证码校验
+		if err := s.verifyCodeSvc.RequireVerified(VerifyChannelSMS, req.Identifier, req.Code); err != nil {
+			return nil, err
+		}
 		user, err = s.userRepo.GetByPhone(req.Identifier)
 		if err == gorm.ErrRecordNotFound {
 			isNewUser = true
@@ -378,6 +395,10 @@ func (s *UserService) LoginWithTypeV2(req *LoginRequest, ipAddress, deviceID, ol
 		}
 
 	case "email":
+		// 邮箱登录必须通过邮箱验证码校验
+		if err := s.verifyCodeSvc.RequireVerified(VerifyChannelEmail, req.Identifier, req.Code); err != nil {
+			return nil, err
+		}
 		user, err = s.userRepo.GetByEmail(req.Identifier)
 		if err == gorm.ErrRecordNotFound {
 			isNewUser = true
@@ -459,7 +480,7 @@ func (s *UserService) LoginWithTypeV2(req *LoginRequest, ipAddress, deviceID, ol
 
 	// 如果提供了旧token，使旧会话失效
 	if oldToken != "" {
-		s.sessionRepo.DeactivateByToken(oldToken)
+		s.sessionSvc.RevokeToken(oldToken)
 	}
 
 	// 检查用户状态
@@ -489,56 +510,56 @@ func (s *UserService) LoginWithTypeV2(req *LoginRequest, ipAddress, deviceID, ol
 		username = user.UserID
 	}
 
-	// 尝试复用现有有效的token（优先检查现有会话）
-	var token string
-	var reuseExistingSession bool
-	existingSession, err := s.sessionRepo.GetLatestActiveSession(user.UserID)
-	if err == nil && existingSession != nil && existingSession.Token != nil && *existingSession.Token != "" {
-		// 检查token是否在有效期内
-		// 获取JWT过期时间配置（默认720小时=30天）
-		tokenExpireDuration := config.AppConfig.JWT.Expire
-		if tokenExpireDuration == 0 {
-			tokenExpireDuration = 720 * time.Hour // 默认30天
-		}
-
-		// 计算token已存在的时间
-		tokenAge := time.Since(existingSession.CreatedAt)
-		if tokenAge < tokenExpireDuration {
-			// token仍在有效期内，复用现有token
-			token = *existingSession.Token
-			reuseExistingSession = true
-			repository.Infof("Reusing existing valid token for user %s, token age: %v", user.UserID, tokenAge)
-		}
+	// 同一设备上已有活跃会话时沿用该会话并重新签发令牌
+	// 不同设备必须使用各自的会话，否则无法单独下线设备，设备数限制也会失效
+	var existingSession *models.UserSession
+	if deviceID != "" {
+		existingSession, _ = s.sessionRepo.GetLatestActiveSessionByDevice(user.UserID, deviceID)
 	}
 
-	// 如果没有可复用的token，生成新的JWT token
-	if token == "" {
-		token, err = tools.GenerateToken(user.UserID, username)
-		if err != nil {
-			return nil, fmt.Errorf("failed to generate token: %w", err)
-		}
+	token, err := tools.GenerateToken(user.UserID, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
 
 	// 获取用户允许的最大设备数
 	maxSessions := GetMaxSessions(user)
 
-	// 根据用户角色处理会话
-	loginMsg := s.handleSessionByRole(user, token, maxSessions)
-
 	var session *models.UserSession
+	var refreshToken string
+	var loginMsg string
 	var deletedCount int64
 
-	if reuseExistingSession && existingSession != nil {
-		// 复用现有会话，更新会话信息
+	if existingSession != nil {
+		// 复用现有会话，更新令牌与会话信息
+		refreshToken, err = s.sessionSvc.RenewSession(existingSession, token, map[string]interface{}{
+			"ip_address":       ipAddress,
+			"last_active_time": time.Now(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to renew session: %w", err)
+		}
 		existingSession.IPAddress = ipAddress
-		existingSession.DeviceID = tools.StringPtr(deviceID)
 		existingSession.LastActiveTime = time.Now()
 		session = existingSession
-
-		// 更新会话的最后活跃时间和IP等信息
-		s.sessionRepo.UpdateLastActiveTime(existingSession.ID)
 		repository.Infof("Reusing existing session for user %s, session ID: %d", user.UserID, existingSession.ID)
+
+		// 根据用户角色处理会话
+		loginMsg = s.handleSessionByRole(user, token, maxSessions)
 	} else {
+		// 根据用户角色处理会话
+		loginMsg = s.handleSessionByRole(user, token, maxSessions)
+
+		// 老用户从未使用过的设备和IP登录时才发送邮件提醒，需在创建新会话前判断
+		sendLoginAlert := false
+		if !isNewUser && user.Email != nil && *user.Email != "" {
+			known, err := s.sessionRepo.HasSessionFromDeviceOrIP(user.UserID, deviceID, ipAddress)
+			if err != nil {
+				repository.Warnf("Check known login device failed for user %s: %v", user.UserID, err)
+			}
+			sendLoginAlert = err == nil && !known
+		}
+
 		// 创建新会话记录
 		session = &models.UserSession{
 			UserID:         user.UserID,
@@ -550,13 +571,27 @@ func (s *UserService) LoginWithTypeV2(req *LoginRequest, ipAddress, deviceID, ol
 			LastActiveTime: time.Now(),
 			CreatedAt:      time.Now(),
 		}
+		if refreshToken, err = s.sessionSvc.PrepareRefreshToken(session); err != nil {
+			return nil, err
+		}
 
 		if err := s.sessionRepo.Create(session); err != nil {
 			return nil, fmt.Errorf("failed to create session: %w", err)
 		}
 
 		// 清理会话：根据用户等级保留对应数量的在线session
-		deletedCount, _ = s.sessionRepo.CleanupSessionsKeepRecent(user.UserID, maxSessions)
+		deletedCount, _ = s.sessionSvc.CleanupSessionsKeepRecent(user.UserID, maxSessions)
+
+		if sendLoginAlert {
+			if err := GetEmailService().SendLoginAlert(*user.Email, LoginAlertEmailData{
+				Nickname:  tools.GetStringValue(user.Nickname),
+				LoginTime: session.CreatedAt.Format("2006-01-02 15:04:05"),
+				IPAddress: ipAddress,
+				DeviceID:  deviceID,
+			}); err != nil {
+				repository.Warnf("Send login alert email failed for user %s: %v", user.UserID, err)
+			}
+		}
 	}
 
 	// 发送登录成功系统通知
@@ -565,6 +600,7 @@ func (s *UserService) LoginWithTypeV2(req *LoginRequest, ipAddress, deviceID, ol
 	return &LoginResult{
 		User:        user,
 		Token:       token,
+		Tokens:      newTokenPair(token, refreshToken),
 		Session:     session,
 		MaxSessions: maxSessions,
 		LoginMsg:    loginMsg,
@@ -678,7 +714,7 @@ func (s *UserService) handleSessionByRole(user *models.User, token string, maxSe
 	case UserRoleVIP:
 		sessionCount, _ := s.sessionRepo.CountActiveSessionsByUserID(user.UserID)
 		if sessionCount > int64(maxSessions) {
-			s.sessionRepo.DeactivateOtherSessions(user.UserID, token)
+			s.sessionSvc.DeactivateOtherSessions(user.UserID, token)
 			sessionCount = 1
 		}
 		remaining := maxSessions - int(sessionCount)
@@ -688,7 +724,7 @@ func (s *UserService) handleSessionByRole(user *models.User, token string, maxSe
 		return fmt.Sprintf("VIP登录成功，还剩%d设备可登录", remaining)
 	default:
 		// 普通用户只允许1个设备，使其他会话失效
-		s.sessionRepo.DeactivateOtherSessions(user.UserID, token)
+		s.sessionSvc.DeactivateOtherSessions(user.UserID, token)
 		return "登录成功"
 	}
 }
@@ -773,7 +809,15 @@ func (s *UserService) DeleteAccount(userID string) error {
 	}
 
 	// 删除用户（软删除）
-	return s.userRepo.Delete(userID)
+	if err := s.userRepo.Delete(userID); err != nil {
+		return err
+	}
+
+	// 注销后所有设备立即下线
+	if err := s.sessionSvc.RevokeAllSessions(userID); err != nil {
+		repository.Errorf("Revoke sessions of deleted user %s failed: %v", userID, err)
+	}
+	return nil
 }
 
 // BindPhone 绑定手机号
@@ -0,0 +1,315 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"01agent_server/internal/config"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/tools"
+)
+
+// VerifyChannel 验证码发送渠道
+type VerifyChannel string
+
+const (
+	VerifyChannelSMS   VerifyChannel = "sms"
+	VerifyChannelEmail VerifyChannel = "email"
+)
+
+var (
+	// ErrCodeSendTooFrequent 发送过于频繁
+	ErrCodeSendTooFrequent = errors.New("验证码发送过于频繁，请稍后再试")
+	// ErrCodeTargetDailyLimit 单个手机号/邮箱超出每日发送上限
+	ErrCodeTargetDailyLimit = errors.New("今日验证码发送次数已达上限")
+	// ErrCodeIPDailyLimit 单个IP超出每日发送上限
+	ErrCodeIPDailyLimit = errors.New("当前网络今日验证码发送次数已达上限")
+	// ErrCodeExpired 验证码不存在或已过期
+	ErrCodeExpired = errors.New("验证码已过期，请重新获取")
+	// ErrCodeMismatch 验证码错误
+	ErrCodeMismatch = errors.New("验证码错误")
+	// ErrCodeTooManyAttempts 校验次数过多
+	ErrCodeTooManyAttempts = errors.New("验证码错误次数过多，请重新获取")
+	// ErrCodeNotVerified 未通过验证码校验
+	ErrCodeNotVerified = errors.New("请先完成验证码验证")
+	// ErrInvalidEmail 邮箱格式错误
+	ErrInvalidEmail = errors.New("无效的邮箱地址")
+	// ErrCodeServiceUnavailable 验证码服务不可用
+	ErrCodeServiceUnavailable = errors.New("验证码服务暂不可用，请稍后再试")
+)
+
+const (
+	// verifyCodeRedisDB 验证码使用的Redis库
+	verifyCodeRedisDB = 0
+
+	defaultCodeExpire       = 300
+	defaultResendInterval   = 60
+	defaultCodeLength       = 6
+	defaultMaxAttempts      = 5
+	defaultTargetDailyLimit = 10
+	defaultIPDailyLimit     = 50
+	defaultVerifiedExpire   = 600
+)
+
+var (
+	defaultSMSSender     SMSSender
+	defaultSMSSenderErr  error
+	defaultSMSSenderOnce sync.Once
+)
+
+// InitSMSSender 按配置创建全局短信发送器，配置缺失时返回错误，启动时调用
+func InitSMSSender() error {
+	defaultSMSSenderOnce.Do(func() {
+		defaultSMSSender, defaultSMSSenderErr = NewSMSSender()
+	})
+	return defaultSMSSenderErr
+}
+
+// VerifyCodeService 验证码服务
+// 验证码统一存储在Redis中，短信与邮件共用同一套频率限制与校验逻辑
+type VerifyCodeService struct {
+	redis        *tools.Redis
+	smsSender    SMSSender
+	emailService *EmailService
+}
+
+// NewVerifyCodeService 创建验证码服务
+func NewVerifyCodeService() *VerifyCodeService {
+	if err := InitSMSSender(); err != nil {
+		repository.Errorf("SMS sender unavailable: %v", err)
+	}
+	return &VerifyCodeService{
+		redis:        tools.GetRedisInstance(),
+		smsSender:    defaultSMSSender,
+		emailService: GetEmailService(),
+	}
+}
+
+// NewVerifyCodeServiceWithSender 使用指定的短信发送器和邮件服务创建验证码服务
+func NewVerifyCodeServiceWithSender(smsSender SMSSender, emailService *EmailService) *VerifyCodeService {
+	return &VerifyCodeService{
+		redis:        tools.GetRedisInstance(),
+		smsSender:    smsSender,
+		emailService: emailService,
+	}
+}
+
+// SendSMSCode 发送短信验证码
+func (s *VerifyCodeService) SendSMSCode(areaCode, phone, ip string) error {
+	if s.smsSender == nil {
+		return ErrCodeServiceUnavailable
+	}
+	expire := s.codeExpire()
+	return s.send(VerifyChannelSMS, phone, ip, func(code string) error {
+		return s.smsSender.SendCode(areaCode, phone, code, (expire+59)/60)
+	})
+}
+
+// VerifySMSCode 校验短信验证码
+func (s *VerifyCodeService) VerifySMSCode(phone, code string) error {
+	return s.Verify(VerifyChannelSMS, phone, code)
+}
+
+// SendEmailCode 发送邮箱验证码
+func (s *VerifyCodeService) SendEmailCode(email, ip string) error {
+	if !IsValidEmail(email) {
+		return ErrInvalidEmail
+	}
+	expire := s.codeExpire()
+	return s.send(VerifyChannelEmail, email, ip, func(code string) error {
+		return s.emailService.SendVerifyCode(normalizeVerifyTarget(VerifyChannelEmail, email), code, (expire+59)/60)
+	})
+}
+
+// VerifyEmailCode 校验邮箱验证码
+func (s *VerifyCodeService) VerifyEmailCode(email, code string) error {
+	return s.Verify(VerifyChannelEmail, email, code)
+}
+
+// send 生成验证码并通过 deliver 投递，供各渠道复用
+// 依次校验重发间隔、目标每日上限、IP每日上限，投递失败时回滚本次发送状态
+func (s *VerifyCodeService) send(channel VerifyChannel, target, ip string, deliver func(code string) error) error {
+	target = normalizeVerifyTarget(channel, target)
+	cfg := s.config()
+
+	resendKey := s.key("resend", channel, target)
+	ok, err := s.redis.SetNX(resendKey, "1", intOrDefault(cfg.ResendInterval, defaultResendInterval), verifyCodeRedisDB)
+	if err != nil {
+		repository.Errorf("Verify code redis error: %v", err)
+		return ErrCodeServiceUnavailable
+	}
+	if !ok {
+		return ErrCodeSendTooFrequent
+	}
+
+	today := time.Now().Format("20060102")
+	targetCount, err := s.redis.Incr(s.key("daily", channel, target+":"+today), 86400, verifyCodeRedisDB)
+	if err != nil {
+		repository.Errorf("Verify code redis error: %v", err)
+		return ErrCodeServiceUnavailable
+	}
+	if targetCount > int64(intOrDefault(cfg.TargetDailyLimit, defaultTargetDailyLimit)) {
+		return ErrCodeTargetDailyLimit
+	}
+
+	if ip != "" {
+		ipCount, err := s.redis.Incr(fmt.Sprintf("verify_code:daily_ip:%s:%s", ip, today), 86400, verifyCodeRedisDB)
+		if err != nil {
+			repository.Errorf("Verify code redis error: %v", err)
+			return ErrCodeServiceUnavailable
+		}
+		if ipCount > int64(intOrDefault(cfg.IPDailyLimit, defaultIPDailyLimit)) {
+			return ErrCodeIPDailyLimit
+		}
+	}
+
+	code, err := generateNumericCode(intOrDefault(cfg.Length, defaultCodeLength))
+	if err != nil {
+		return fmt.Errorf("生成验证码失败: %w", err)
+	}
+
+	codeKey := s.key("code", channel, target)
+	attemptsKey := s.key("attempts", channel, target)
+	if err := s.redis.Set(codeKey, code, s.codeExpire(), verifyCodeRedisDB); err != nil {
+		repository.Errorf("Verify code redis error: %v", err)
+		return ErrCodeServiceUnavailable
+	}
+	s.redis.Delete(attemptsKey, verifyCodeRedisDB)
+
+	if err := deliver(code); err != nil {
+		repository.Errorf("Deliver verify code failed: channel=%s, target=%s, err=%v", channel, target, err)
+		s.redis.DeleteKeys([]string{codeKey, resendKey}, verifyCodeRedisDB)
+		return fmt.Errorf("验证码发送失败，请稍后再试")
+	}
+
+	repository.Infof("Verify code sent: channel=%s, target=%s, ip=%s", channel, target, ip)
+	return nil
+}
+
+// Verify 校验验证码，成功后验证码立即失效并签发一个短期的已验证凭证
+func (s *VerifyCodeService) Verify(channel VerifyChannel, target, code string) error {
+	target = normalizeVerifyTarget(channel, target)
+	cfg := s.config()
+
+	codeKey := s.key("code", channel, target)
+	attemptsKey := s.key("attempts", channel, target)
+
+	stored, err := s.redis.Get(codeKey, verifyCodeRedisDB)
+	if err != nil {
+		repository.Errorf("Verify code redis error: %v", err)
+		return ErrCodeServiceUnavailable
+	}
+	if stored == "" {
+		return ErrCodeExpired
+	}
+
+	attempts, err := s.redis.Incr(attemptsKey, s.codeExpire(), verifyCodeRedisDB)
+	if err != nil {
+		repository.Errorf("Verify code redis error: %v", err)
+		return ErrCodeServiceUnavailable
+	}
+	if attempts > int64(intOrDefault(cfg.MaxAttempts, defaultMaxAttempts)) {
+		s.redis.DeleteKeys([]string{codeKey, attemptsKey}, verifyCodeRedisDB)
+		return ErrCodeTooManyAttempts
+	}
+
+	if subtle.ConstantTimeCompare([]byte(stored), []byte(strings.TrimSpace(code))) != 1 {
+		return ErrCodeMismatch
+	}
+
+	s.redis.DeleteKeys([]string{codeKey, attemptsKey}, verifyCodeRedisDB)
+	verifiedExpire := intOrDefault(cfg.VerifiedExpire, defaultVerifiedExpire)
+	if err := s.redis.Set(s.key("verified", channel, target), "1", verifiedExpire, verifyCodeRedisDB); err != nil {
+		repository.Errorf("Verify code redis error: %v", err)
+		return ErrCodeServiceUnavailable
+	}
+	return nil
+}
+
+// RequireVerified 确认目标已通过验证码校验
+// code 不为空时直接校验验证码；否则消费此前 Verify 成功后签发的已验证凭证（一次性）
+func (s *VerifyCodeService) RequireVerified(channel VerifyChannel, target, code string) error {
+	if code != "" {
+		if err := s.Verify(channel, target, code); err != nil {
+			return err
+		}
+	}
+
+	target = normalizeVerifyTarget(channel, target)
+	verifiedKey := s.key("verified", channel, target)
+	existed, err := s.redis.DeleteExisting(verifiedKey, verifyCodeRedisDB)
+	if err != nil {
+		repository.Errorf("Verify code redis error: %v", err)
+		return ErrCodeServiceUnavailable
+	}
+	if !existed {
+		return ErrCodeNotVerified
+	}
+	return nil
+}
+
+// ResendWaitSeconds 距离可重新发送的剩余秒数
+func (s *VerifyCodeService) ResendWaitSeconds(channel VerifyChannel, target string) int64 {
+	ttl, err := s.redis.TTL(s.key("resend", channel, normalizeVerifyTarget(channel, target)), verifyCodeRedisDB)
+	if err != nil || ttl < 0 {
+		return 0
+	}
+	return ttl
+}
+
+func (s *VerifyCodeService) key(kind string, channel VerifyChannel, target string) string {
+	return fmt.Sprintf("verify_code:%s:%s:%s", kind, channel, target)
+}
+
+func (s *VerifyCodeService) config() config.VerifyCodeConfig {
+	if config.AppConfig == nil {
+		return config.VerifyCodeConfig{}
+	}
+	return config.AppConfig.VerifyCode
+}
+
+func (s *VerifyCodeService) codeExpire() int {
+	return intOrDefault(s.config().Expire, defaultCodeExpire)
+}
+
+// normalizeVerifyTarget 统一目标格式，邮箱不区分大小写
+func normalizeVerifyTarget(channel VerifyChannel, target string) string {
+	target = strings.TrimSpace(target)
+	if channel == VerifyChannelEmail {
+		target = strings.ToLower(target)
+	}
+	return target
+}
+
+// IsValidEmail 校验邮箱格式（仅允许纯地址，不含显示名）
+func IsValidEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == strings.TrimSpace(email) && len(email) <= 254
+}
+
+// generateNumericCode 生成指定位数的数字验证码
+func generateNumericCode(length int) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(byte('0' + n.Int64()))
+	}
+	return sb.String(), nil
+}
+
+func intOrDefault(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
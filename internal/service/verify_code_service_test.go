@@ -0,0 +1,127 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"01agent_server/internal/config"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// failingSMSSender 总是发送失败的短信发送器
+type failingSMSSender struct{}
+
+func (failingSMSSender) SendCode(areaCode, phone, code string, expireMinutes int) error {
+	return errors.New("provider unavailable")
+}
+
+func newTestVerifyCodeService(t *testing.T, sender SMSSender) (*VerifyCodeService, *miniredis.Miniredis) {
+	t.Helper()
+	cfg := useTestConfig(t, &config.Config{VerifyCode: config.VerifyCodeConfig{
+		ResendInterval:   60,
+		MaxAttempts:      3,
+		TargetDailyLimit: 3,
+		IPDailyLimit:     4,
+	}})
+	mr := useTestRedis(t, cfg)
+	return NewVerifyCodeServiceWithSender(sender, nil), mr
+}
+
+func TestVerifyCodeSendAndVerify(t *testing.T) {
+	sender := NewMemorySMSSender()
+	svc, _ := newTestVerifyCodeService(t, sender)
+
+	if err := svc.SendSMSCode("86", "13800000000", "1.2.3.4"); err != nil {
+		t.Fatal(err)
+	}
+	code, ok := sender.LastCode("13800000000")
+	if !ok || len(code) != defaultCodeLength {
+		t.Fatalf("unexpected code %q", code)
+	}
+	if err := svc.SendSMSCode("86", "13800000000", "1.2.3.4"); !errors.Is(err, ErrCodeSendTooFrequent) {
+		t.Fatalf("want ErrCodeSendTooFrequent, got %v", err)
+	}
+	if wait := svc.ResendWaitSeconds(VerifyChannelSMS, "13800000000"); wait <= 0 || wait > 60 {
+		t.Fatalf("unexpected resend wait %d", wait)
+	}
+
+	if err := svc.RequireVerified(VerifyChannelSMS, "13800000000", code); err != nil {
+		t.Fatal(err)
+	}
+	// 验证码与已验证凭证都是一次性的
+	if err := svc.VerifySMSCode("13800000000", code); !errors.Is(err, ErrCodeExpired) {
+		t.Fatalf("want ErrCodeExpired, got %v", err)
+	}
+	if err := svc.RequireVerified(VerifyChannelSMS, "13800000000", ""); !errors.Is(err, ErrCodeNotVerified) {
+		t.Fatalf("want ErrCodeNotVerified, got %v", err)
+	}
+}
+
+func TestVerifyCodeAttemptLimit(t *testing.T) {
+	sender := NewMemorySMSSender()
+	svc, _ := newTestVerifyCodeService(t, sender)
+
+	if err := svc.SendSMSCode("86", "13800000001", ""); err != nil {
+		t.Fatal(err)
+	}
+	code, _ := sender.LastCode("13800000001")
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	for i := 0; i < 3; i++ {
+		if err := svc.VerifySMSCode("13800000001", wrong); !errors.Is(err, ErrCodeMismatch) {
+			t.Fatalf("attempt %d: want ErrCodeMismatch, got %v", i, err)
+		}
+	}
+	if err := svc.VerifySMSCode("13800000001", wrong); !errors.Is(err, ErrCodeTooManyAttempts) {
+		t.Fatalf("want ErrCodeTooManyAttempts, got %v", err)
+	}
+	// 超过次数后验证码作废，正确的验证码也无法通过
+	if err := svc.VerifySMSCode("13800000001", code); !errors.Is(err, ErrCodeExpired) {
+		t.Fatalf("want ErrCodeExpired, got %v", err)
+	}
+}
+
+func TestVerifyCodeDailyLimits(t *testing.T) {
+	sender := NewMemorySMSSender()
+	svc, mr := newTestVerifyCodeService(t, sender)
+
+	for i := 0; i < 3; i++ {
+		if err := svc.SendSMSCode("86", "13800000002", "5.6.7.8"); err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+		mr.FastForward(61 * time.Second)
+	}
+	if err := svc.SendSMSCode("86", "13800000002", "5.6.7.8"); !errors.Is(err, ErrCodeTargetDailyLimit) {
+		t.Fatalf("want ErrCodeTargetDailyLimit, got %v", err)
+	}
+
+	// 同一IP给不同手机号发送，超过IP每日上限
+	if err := svc.SendSMSCode("86", "13800000003", "5.6.7.8"); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.SendSMSCode("86", "13800000004", "5.6.7.8"); !errors.Is(err, ErrCodeIPDailyLimit) {
+		t.Fatalf("want ErrCodeIPDailyLimit, got %v", err)
+	}
+}
+
+func TestVerifyCodeDeliveryFailureRollsBack(t *testing.T) {
+	svc, _ := newTestVerifyCodeService(t, failingSMSSender{})
+
+	if err := svc.SendSMSCode("86", "13800000005", ""); err == nil {
+		t.Fatal("want delivery error")
+	}
+	// 投递失败不占用重发间隔
+	svc.smsSender = NewMemorySMSSender()
+	if err := svc.SendSMSCode("86", "13800000005", ""); err != nil {
+		t.Fatalf("resend after failure: %v", err)
+	}
+
+	svc.smsSender = nil
+	if err := svc.SendSMSCode("86", "13800000006", ""); !errors.Is(err, ErrCodeServiceUnavailable) {
+		t.Fatalf("want ErrCodeServiceUnavailable, got %v", err)
+	}
+}
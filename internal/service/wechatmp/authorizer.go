@@ -0,0 +1,210 @@
+package wechatmp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"01agent_server/internal/models"
+	"01agent_server/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// authStateTTL 授权链接的有效期，与微信预授权码的有效期一致
+const authStateTTL = 10 * time.Minute
+
+// AuthorizationURL 生成公众号授权页地址，state 关联发起授权的用户，授权回调时据此绑定
+func (c *PlatformClient) AuthorizationURL(userID string) (string, error) {
+	if c.cfg.AuthRedirectURL == "" {
+		return "", fmt.Errorf("未配置公众号授权回调地址")
+	}
+
+	var preAuthCode string
+	err := c.withComponentToken(func(token string) error {
+		var resp struct {
+			PreAuthCode string `json:"pre_auth_code"`
+		}
+		err := c.postJSON("/cgi-bin/component/api_create_preauthcode", tokenQuery("component_access_token", token),
+			map[string]string{"component_appid": c.cfg.AppID}, &resp)
+		preAuthCode = resp.PreAuthCode
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	stateBytes := make([]byte, 16)
+	if _, err := rand.Read(stateBytes); err != nil {
+		return "", err
+	}
+	state := hex.EncodeToString(stateBytes)
+	if err := c.redis.Set(redisKeyAuthState+state, userID, int(authStateTTL.Seconds()), c.redisDB); err != nil {
+		return "", fmt.Errorf("保存授权状态失败: %w", err)
+	}
+
+	redirectURI, err := url.Parse(c.cfg.AuthRedirectURL)
+	if err != nil {
+		return "", fmt.Errorf("公众号授权回调地址无效: %w", err)
+	}
+	q := redirectURI.Query()
+	q.Set("state", state)
+	redirectURI.RawQuery = q.Encode()
+
+	params := url.Values{}
+	params.Set("component_appid", c.cfg.AppID)
+	params.Set("pre_auth_code", preAuthCode)
+	params.Set("redirect_uri", redirectURI.String())
+	params.Set("auth_type", "1") // 仅展示公众号
+	return componentLoginPage + "?" + params.Encode(), nil
+}
+
+// CompleteAuthorization 处理授权回调：用授权码换取公众号的凭据并保存授权关系，返回公众号appid
+func (c *PlatformClient) CompleteAuthorization(state, authCode string) (string, error) {
+	userID, err := c.redis.Get(redisKeyAuthState+state, c.redisDB)
+	if err != nil {
+		return "", fmt.Errorf("读取授权状态失败: %w", err)
+	}
+	if userID == "" {
+		return "", fmt.Errorf("授权链接已过期，请重新发起授权")
+	}
+	c.redis.Delete(redisKeyAuthState+state, c.redisDB)
+
+	var resp struct {
+		AuthorizationInfo struct {
+			AuthorizerAppID        string          `json:"authorizer_appid"`
+			AuthorizerAccessToken  string          `json:"authorizer_access_token"`
+			ExpiresIn              int             `json:"expires_in"`
+			AuthorizerRefreshToken string          `json:"authorizer_refresh_token"`
+			FuncInfo               json.RawMessage `json:"func_info"`
+		} `json:"authorization_info"`
+	}
+	err = c.withComponentToken(func(token string) error {
+		return c.postJSON("/cgi-bin/component/api_query_auth", tokenQuery("component_access_token", token), map[string]string{
+			"component_appid":    c.cfg.AppID,
+			"authorization_code": authCode,
+		}, &resp)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	info := resp.AuthorizationInfo
+	if info.AuthorizerAppID == "" || info.AuthorizerRefreshToken == "" {
+		return "", fmt.Errorf("授权信息不完整")
+	}
+
+	now := time.Now()
+	authorizer := models.WechatAuthorizer{
+		AuthorizerAppID: info.AuthorizerAppID,
+		UserID:          userID,
+		RefreshToken:    info.AuthorizerRefreshToken,
+		Status:          models.WechatAuthorizerStatusAuthorized,
+		AuthorizedAt:    now,
+	}
+	if len(info.FuncInfo) > 0 {
+		funcInfo := string(info.FuncInfo)
+		authorizer.FuncInfo = &funcInfo
+	}
+
+	err = repository.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&authorizer).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("user_id = ?", userID).Update("appid", info.AuthorizerAppID).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.UserParameters{}).Where("user_id = ?", userID).Update("is_gzh_bind", true).Error
+	})
+	if err != nil {
+		return "", fmt.Errorf("保存公众号授权失败: %w", err)
+	}
+
+	c.cacheToken(redisKeyAuthorizerTok+info.AuthorizerAppID, info.AuthorizerAccessToken, info.ExpiresIn)
+	repository.Infof("公众号授权完成: user_id=%s, appid=%s", userID, info.AuthorizerAppID)
+	return info.AuthorizerAppID, nil
+}
+
+// Unauthorize 处理微信推送的取消授权事件
+func (c *PlatformClient) Unauthorize(appID string) error {
+	now := time.Now()
+	var authorizer models.WechatAuthorizer
+	if err := repository.DB.Where("authorizer_appid = ?", appID).First(&authorizer).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return err
+	}
+
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&authorizer).Updates(map[string]interface{}{
+			"status":          models.WechatAuthorizerStatusUnauthorized,
+			"unauthorized_at": &now,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.UserParameters{}).Where("user_id = ?", authorizer.UserID).Update("is_gzh_bind", false).Error
+	})
+	if err != nil {
+		return err
+	}
+	c.redis.Delete(redisKeyAuthorizerTok+appID, c.redisDB)
+	repository.Infof("公众号取消授权: user_id=%s, appid=%s", authorizer.UserID, appID)
+	return nil
+}
+
+// AuthorizerAccessToken 获取公众号的接口调用凭据，过期时用刷新令牌换取并保存轮换后的刷新令牌
+func (c *PlatformClient) AuthorizerAccessToken(appID string) (string, error) {
+	cacheKey := redisKeyAuthorizerTok + appID
+	if token, err := c.redis.Get(cacheKey, c.redisDB); err == nil && token != "" {
+		return token, nil
+	}
+
+	lock, _ := c.authorizerMu.LoadOrStore(appID, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if token, err := c.redis.Get(cacheKey, c.redisDB); err == nil && token != "" {
+		return token, nil
+	}
+
+	var authorizer models.WechatAuthorizer
+	if err := repository.DB.Where("authorizer_appid = ? AND status = ?", appID, models.WechatAuthorizerStatusAuthorized).
+		First(&authorizer).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrAuthorizerNotFound
+		}
+		return "", err
+	}
+
+	var resp struct {
+		AuthorizerAccessToken  string `json:"authorizer_access_token"`
+		ExpiresIn              int    `json:"expires_in"`
+		AuthorizerRefreshToken string `json:"authorizer_refresh_token"`
+	}
+	err := c.withComponentToken(func(token string) error {
+		return c.postJSON("/cgi-bin/component/api_authorizer_token", tokenQuery("component_access_token", token), map[string]string{
+			"component_appid":          c.cfg.AppID,
+			"authorizer_appid":         appID,
+			"authorizer_refresh_token": authorizer.RefreshToken,
+		}, &resp)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if resp.AuthorizerRefreshToken != "" && resp.AuthorizerRefreshToken != authorizer.RefreshToken {
+		if err := repository.DB.Model(&authorizer).Update("refresh_token", resp.AuthorizerRefreshToken).Error; err != nil {
+			repository.Errorf("保存公众号刷新令牌失败: appid=%s, err=%v", appID, err)
+		}
+	}
+
+	c.cacheToken(cacheKey, resp.AuthorizerAccessToken, resp.ExpiresIn)
+	return resp.AuthorizerAccessToken, nil
+}
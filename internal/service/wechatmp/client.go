@@ -0,0 +1,297 @@
+package wechatmp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"01agent_server/internal/config"
+	"01agent_server/internal/repository"
+	"01agent_server/internal/tools"
+)
+
+const (
+	defaultAPIBaseURL = "https://api.weixin.qq.com"
+	// componentLoginPage 公众号授权页
+	componentLoginPage = "https://mp.weixin.qq.com/cgi-bin/componentloginpage"
+
+	// tokenRefreshAhead 凭据在过期前多久视为失效，避免请求途中过期
+	tokenRefreshAhead = 5 * time.Minute
+
+	redisKeyVerifyTicket   = "wechat:platform:verify_ticket"
+	redisKeyComponentToken = "wechat:platform:component_token"
+	redisKeyAuthorizerTok  = "wechat:platform:authorizer_token:"
+	redisKeyAuthState      = "wechat:platform:auth_state:"
+)
+
+var (
+	// ErrPlatformNotConfigured 第三方平台未配置
+	ErrPlatformNotConfigured = errors.New("微信第三方平台未配置")
+	// ErrVerifyTicketMissing 尚未收到微信推送的 component_verify_ticket
+	ErrVerifyTicketMissing = errors.New("尚未收到微信推送的component_verify_ticket")
+	// ErrAuthorizerNotFound 公众号未通过第三方平台授权
+	ErrAuthorizerNotFound = errors.New("公众号未授权给第三方平台")
+)
+
+// APIError 微信接口返回的错误
+type APIError struct {
+	Path    string `json:"-"`
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("微信接口错误(%s): %d %s", e.Path, e.ErrCode, e.ErrMsg)
+}
+
+// tokenExpired 凭据无效或过期，清除缓存后重新获取即可恢复
+func (e *APIError) tokenExpired() bool {
+	switch e.ErrCode {
+	case 40001, 40014, 42001:
+		return true
+	}
+	return false
+}
+
+// Retryable 错误是否可稍后重试：网络异常、频率限制与系统繁忙可重试，授权与参数类错误不可重试
+func Retryable(err error) bool {
+	if err == nil || errors.Is(err, ErrAuthorizerNotFound) || errors.Is(err, ErrPlatformNotConfigured) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrCode {
+		case -1, 45009, 45011:
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// PlatformClient 微信第三方平台客户端
+// 凭据（verify_ticket、component_access_token、authorizer_access_token）缓存在 Redis 中，多实例共享
+type PlatformClient struct {
+	cfg        config.WechatPlatformConfig
+	baseURL    string
+	httpClient *http.Client
+	redis      *tools.Redis
+	redisDB    int
+
+	// componentMu 同一实例内串行刷新 component_access_token
+	componentMu sync.Mutex
+	// authorizerMu 同一实例内按公众号串行刷新 authorizer_access_token，避免并发刷新导致刷新令牌轮换冲突
+	authorizerMu sync.Map
+}
+
+var (
+	platformClientInstance *PlatformClient
+	platformClientErr      error
+	platformClientOnce     sync.Once
+)
+
+// GetPlatformClient 获取第三方平台客户端单例
+func GetPlatformClient() (*PlatformClient, error) {
+	platformClientOnce.Do(func() {
+		if config.AppConfig == nil {
+			platformClientErr = ErrPlatformNotConfigured
+			return
+		}
+		platformClientInstance, platformClientErr = NewPlatformClient(config.AppConfig.WechatPlatform, config.AppConfig.Redis.DB)
+	})
+	return platformClientInstance, platformClientErr
+}
+
+// NewPlatformClient 创建第三方平台客户端
+func NewPlatformClient(cfg config.WechatPlatformConfig, redisDB int) (*PlatformClient, error) {
+	if cfg.AppID == "" || cfg.AppSecret == "" {
+		return nil, ErrPlatformNotConfigured
+	}
+
+	baseURL := strings.TrimRight(cfg.APIBaseURL, "/")
+	if baseURL == "" {
+		baseURL = defaultAPIBaseURL
+	}
+
+	return &PlatformClient{
+		cfg:        cfg,
+		baseURL:    baseURL,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		redis:      tools.GetRedisInstance(),
+		redisDB:    redisDB,
+	}, nil
+}
+
+// AppID 第三方平台appid
+func (c *PlatformClient) AppID() string {
+	return c.cfg.AppID
+}
+
+// postJSON 调用微信接口，query 中的凭据参数由调用方传入
+func (c *PlatformClient) postJSON(path string, query url.Values, body interface{}, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return c.send(path, query, "application/json", bytes.NewReader(payload), out)
+}
+
+// postFile 以 multipart/form-data 上传文件，字段名为 media
+func (c *PlatformClient) postFile(path string, query url.Values, filename string, data []byte, out interface{}) error {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	part, err := writer.CreateFormFile("media", filename)
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(data); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return c.send(path, query, writer.FormDataContentType(), &buf, out)
+}
+
+func (c *PlatformClient) send(path string, query url.Values, contentType string, body io.Reader, out interface{}) error {
+	endpoint := c.baseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	resp, err := c.httpClient.Post(endpoint, contentType, body)
+	if err != nil {
+		return fmt.Errorf("请求微信接口失败(%s): %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return fmt.Errorf("读取微信接口响应失败(%s): %w", path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("微信接口响应异常(%s): HTTP %d", path, resp.StatusCode)
+	}
+
+	// 成功响应不一定带 errcode，先按错误结构解析
+	var apiErr APIError
+	if err := json.Unmarshal(data, &apiErr); err != nil {
+		return fmt.Errorf("解析微信接口响应失败(%s): %w", path, err)
+	}
+	if apiErr.ErrCode != 0 {
+		apiErr.Path = path
+		return &apiErr
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("解析微信接口响应失败(%s): %w", path, err)
+		}
+	}
+	return nil
+}
+
+// ==================== 第三方平台凭据 ====================
+
+// SaveVerifyTicket 保存微信推送的 component_verify_ticket，微信每 10 分钟推送一次，有效期 12 小时
+func (c *PlatformClient) SaveVerifyTicket(ticket string) error {
+	return c.redis.Set(redisKeyVerifyTicket, ticket, int((12 * time.Hour).Seconds()), c.redisDB)
+}
+
+// ComponentAccessToken 获取第三方平台的 component_access_token
+func (c *PlatformClient) ComponentAccessToken() (string, error) {
+	if token, err := c.redis.Get(redisKeyComponentToken, c.redisDB); err == nil && token != "" {
+		return token, nil
+	}
+
+	c.componentMu.Lock()
+	defer c.componentMu.Unlock()
+
+	// 等锁期间可能已被其他请求刷新
+	if token, err := c.redis.Get(redisKeyComponentToken, c.redisDB); err == nil && token != "" {
+		return token, nil
+	}
+
+	ticket, err := c.redis.Get(redisKeyVerifyTicket, c.redisDB)
+	if err != nil {
+		return "", fmt.Errorf("读取component_verify_ticket失败: %w", err)
+	}
+	if ticket == "" {
+		return "", ErrVerifyTicketMissing
+	}
+
+	var resp struct {
+		ComponentAccessToken string `json:"component_access_token"`
+		ExpiresIn            int    `json:"expires_in"`
+	}
+	err = c.postJSON("/cgi-bin/component/api_component_token", nil, map[string]string{
+		"component_appid":         c.cfg.AppID,
+		"component_appsecret":     c.cfg.AppSecret,
+		"component_verify_ticket": ticket,
+	}, &resp)
+	if err != nil {
+		return "", err
+	}
+
+	c.cacheToken(redisKeyComponentToken, resp.ComponentAccessToken, resp.ExpiresIn)
+	return resp.ComponentAccessToken, nil
+}
+
+// cacheToken 缓存凭据，有效期按接口返回值提前一段时间结束
+func (c *PlatformClient) cacheToken(key, token string, expiresIn int) {
+	ttl := time.Duration(expiresIn)*time.Second - tokenRefreshAhead
+	if ttl <= 0 {
+		return
+	}
+	if err := c.redis.Set(key, token, int(ttl.Seconds()), c.redisDB); err != nil {
+		repository.Warnf("缓存微信接口凭据失败: %v", err)
+	}
+}
+
+// withComponentToken 携带 component_access_token 调用接口，凭据失效时刷新后重试一次
+func (c *PlatformClient) withComponentToken(call func(token string) error) error {
+	token, err := c.ComponentAccessToken()
+	if err != nil {
+		return err
+	}
+	err = call(token)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.tokenExpired() {
+		c.redis.Delete(redisKeyComponentToken, c.redisDB)
+		if token, err = c.ComponentAccessToken(); err != nil {
+			return err
+		}
+		return call(token)
+	}
+	return err
+}
+
+// withAuthorizerToken 携带公众号的 access_token 调用接口，凭据失效时刷新后重试一次
+func (c *PlatformClient) withAuthorizerToken(appID string, call func(token string) error) error {
+	token, err := c.AuthorizerAccessToken(appID)
+	if err != nil {
+		return err
+	}
+	err = call(token)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.tokenExpired() {
+		c.redis.Delete(redisKeyAuthorizerTok+appID, c.redisDB)
+		if token, err = c.AuthorizerAccessToken(appID); err != nil {
+			return err
+		}
+		return call(token)
+	}
+	return err
+}
+
+func tokenQuery(name, token string) url.Values {
+	return url.Values{name: []string{token}}
+}
@@ -0,0 +1,427 @@
+package wechatmp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"01agent_server/internal/config"
+	"01agent_server/internal/models"
+	"01agent_server/internal/repository"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+const (
+	testComponentAppID  = "wx_component"
+	testComponentSecret = "component_secret"
+	testVerifyTicket    = "ticket@@@1"
+	testAuthorizerAppID = "wx_authorizer"
+	testRefreshToken    = "refresh-0"
+	testPublishID       = "2247483647123456789"
+)
+
+// testPNG 最小的 PNG 文件头，足以按内容识别为 image/png
+var testPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00\x1f\x15\xc4\x89")
+
+// fakeWechat 模拟微信第三方平台与公众号接口
+// 凭据按签发顺序编号，只有最新签发的凭据有效，刷新令牌每次换取 access_token 时轮换
+type fakeWechat struct {
+	t *testing.T
+
+	mu               sync.Mutex
+	componentToken   string
+	authorizerToken  string
+	refreshToken     string
+	componentIssued  int
+	authorizerIssued int
+	calls            map[string]int
+	uploads          []string
+	drafts           []DraftArticle
+	// pendingPolls 发布状态查询返回"发布中"的次数
+	pendingPolls int
+}
+
+func newFakeWechat(t *testing.T) (*fakeWechat, *httptest.Server) {
+	f := &fakeWechat{t: t, refreshToken: testRefreshToken, calls: make(map[string]int)}
+	server := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+func (f *fakeWechat) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[r.URL.Path]++
+
+	reply := func(v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+	fail := func(code int, msg string) {
+		reply(map[string]interface{}{"errcode": code, "errmsg": msg})
+	}
+	var body map[string]interface{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			fail(47001, "data format error")
+			return
+		}
+	}
+
+	switch r.URL.Path {
+	case "/cgi-bin/component/api_component_token":
+		if body["component_appid"] != testComponentAppID || body["component_appsecret"] != testComponentSecret ||
+			body["component_verify_ticket"] != testVerifyTicket {
+			fail(61004, "invalid component credential")
+			return
+		}
+		f.componentIssued++
+		f.componentToken = fmt.Sprintf("component-%d", f.componentIssued)
+		reply(map[string]interface{}{"component_access_token": f.componentToken, "expires_in": 7200})
+		return
+	case "/cgi-bin/component/api_authorizer_token":
+		if r.URL.Query().Get("component_access_token") != f.componentToken {
+			fail(42001, "component access_token expired")
+			return
+		}
+		if body["authorizer_appid"] != testAuthorizerAppID || body["authorizer_refresh_token"] != f.refreshToken {
+			fail(61023, "refresh_token is invalid")
+			return
+		}
+		f.authorizerIssued++
+		f.authorizerToken = fmt.Sprintf("authorizer-%d", f.authorizerIssued)
+		f.refreshToken = fmt.Sprintf("refresh-%d", f.authorizerIssued)
+		reply(map[string]interface{}{
+			"authorizer_access_token":  f.authorizerToken,
+			"expires_in":               7200,
+			"authorizer_refresh_token": f.refreshToken,
+		})
+		return
+	}
+
+	// 以下为公众号接口，使用 authorizer_access_token
+	if token := r.URL.Query().Get("access_token"); token == "" || token != f.authorizerToken {
+		fail(40001, "invalid credential, access_token is invalid or not latest")
+		return
+	}
+	switch r.URL.Path {
+	case "/cgi-bin/media/uploadimg", "/cgi-bin/material/add_material":
+		file, header, err := r.FormFile("media")
+		if err != nil {
+			fail(41005, "media data missing")
+			return
+		}
+		data, _ := io.ReadAll(file)
+		if string(data) != string(testPNG) {
+			fail(40005, "invalid file type")
+			return
+		}
+		f.uploads = append(f.uploads, header.Filename)
+		if r.URL.Path == "/cgi-bin/media/uploadimg" {
+			reply(map[string]interface{}{"url": fmt.Sprintf("http://mmbiz.qpic.cn/mmbiz_png/%d/0", len(f.uploads))})
+			return
+		}
+		if r.URL.Query().Get("type") != "image" {
+			fail(40004, "invalid media type")
+			return
+		}
+		reply(map[string]interface{}{"media_id": fmt.Sprintf("thumb-%d", len(f.uploads)), "url": "http://mmbiz.qpic.cn/thumb"})
+	case "/cgi-bin/draft/add":
+		raw, _ := json.Marshal(body["articles"])
+		var articles []DraftArticle
+		if err := json.Unmarshal(raw, &articles); err != nil || len(articles) == 0 {
+			fail(40007, "invalid articles")
+			return
+		}
+		f.drafts = append(f.drafts, articles...)
+		reply(map[string]interface{}{"media_id": fmt.Sprintf("draft-%d", len(f.drafts))})
+	case "/cgi-bin/freepublish/submit":
+		// publish_id 以超过 float64 精度的数字返回
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"errcode":0,"errmsg":"ok","publish_id":%s}`, testPublishID)
+	case "/cgi-bin/freepublish/get":
+		if body["publish_id"] != testPublishID {
+			fail(53600, "invalid publish_id")
+			return
+		}
+		if f.pendingPolls > 0 {
+			f.pendingPolls--
+			reply(map[string]interface{}{"publish_id": testPublishID, "publish_status": PublishStatusPublishing})
+			return
+		}
+		reply(map[string]interface{}{
+			"publish_id":     testPublishID,
+			"publish_status": PublishStatusSuccess,
+			"article_id":     "article-1",
+			"article_detail": map[string]interface{}{
+				"count": 1,
+				"item":  []map[string]interface{}{{"idx": 1, "article_url": "https://mp.weixin.qq.com/s/abc"}},
+			},
+		})
+	default:
+		f.t.Errorf("unexpected request %s", r.URL.Path)
+		http.NotFound(w, r)
+	}
+}
+
+// expireTokens 使已签发的凭据全部失效，模拟凭据在缓存有效期内被微信作废
+func (f *fakeWechat) expireTokens() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.componentToken = "expired"
+	f.authorizerToken = "expired"
+}
+
+func (f *fakeWechat) callCount(path string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[path]
+}
+
+// newTestPlatformClient 创建指向模拟服务的客户端，Redis 与数据库使用内存实现
+func newTestPlatformClient(t *testing.T) (*PlatformClient, *fakeWechat, *gorm.DB) {
+	t.Helper()
+	f, server := newFakeWechat(t)
+
+	mr := miniredis.RunT(t)
+	port, _ := strconv.Atoi(mr.Port())
+	prevConfig := config.AppConfig
+	config.AppConfig = &config.Config{Redis: config.RedisConfig{Host: mr.Host(), Port: port, PoolSize: 2}}
+	t.Cleanup(func() { config.AppConfig = prevConfig })
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.WechatAuthorizer{}); err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	prevDB := repository.DB
+	repository.DB = db
+	t.Cleanup(func() {
+		repository.DB = prevDB
+		sqlDB.Close()
+	})
+
+	if err := db.Create(&models.WechatAuthorizer{
+		AuthorizerAppID: testAuthorizerAppID,
+		UserID:          "u1",
+		RefreshToken:    testRefreshToken,
+		Status:          models.WechatAuthorizerStatusAuthorized,
+		AuthorizedAt:    time.Now(),
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewPlatformClient(config.WechatPlatformConfig{
+		AppID:      testComponentAppID,
+		AppSecret:  testComponentSecret,
+		APIBaseURL: server.URL + "/",
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SaveVerifyTicket(testVerifyTicket); err != nil {
+		t.Fatal(err)
+	}
+	return client, f, db
+}
+
+func storedRefreshToken(t *testing.T, db *gorm.DB) string {
+	t.Helper()
+	var authorizer models.WechatAuthorizer
+	if err := db.First(&authorizer, "authorizer_appid = ?", testAuthorizerAppID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return authorizer.RefreshToken
+}
+
+func TestPlatformClientTokens(t *testing.T) {
+	client, f, db := newTestPlatformClient(t)
+
+	token, err := client.AuthorizerAccessToken(testAuthorizerAppID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "authorizer-1" {
+		t.Fatalf("unexpected authorizer token %q", token)
+	}
+	// 轮换后的刷新令牌写回数据库
+	if got := storedRefreshToken(t, db); got != "refresh-1" {
+		t.Fatalf("want rotated refresh token, got %q", got)
+	}
+
+	// 凭据缓存在 Redis 中，再次获取不请求微信
+	if token, err = client.AuthorizerAccessToken(testAuthorizerAppID); err != nil || token != "authorizer-1" {
+		t.Fatalf("want cached token, got %q %v", token, err)
+	}
+	if n := f.callCount("/cgi-bin/component/api_component_token"); n != 1 {
+		t.Fatalf("want 1 component token request, got %d", n)
+	}
+	if n := f.callCount("/cgi-bin/component/api_authorizer_token"); n != 1 {
+		t.Fatalf("want 1 authorizer token request, got %d", n)
+	}
+
+	if _, err := client.AuthorizerAccessToken("wx_unknown"); !errors.Is(err, ErrAuthorizerNotFound) {
+		t.Fatalf("want ErrAuthorizerNotFound, got %v", err)
+	}
+}
+
+func TestPlatformClientRequiresVerifyTicket(t *testing.T) {
+	client, f, _ := newTestPlatformClient(t)
+	client.redis.Delete(redisKeyVerifyTicket, client.redisDB)
+
+	if _, err := client.ComponentAccessToken(); !errors.Is(err, ErrVerifyTicketMissing) {
+		t.Fatalf("want ErrVerifyTicketMissing, got %v", err)
+	}
+	if n := f.callCount("/cgi-bin/component/api_component_token"); n != 0 {
+		t.Fatalf("should not request token without ticket, got %d requests", n)
+	}
+}
+
+func TestPlatformClientRetriesExpiredToken(t *testing.T) {
+	client, f, db := newTestPlatformClient(t)
+	if _, err := client.AuthorizerAccessToken(testAuthorizerAppID); err != nil {
+		t.Fatal(err)
+	}
+
+	// 缓存中的两个凭据都已被微信作废：业务接口返回 40001 后刷新 authorizer_access_token，
+	// 刷新时 component_access_token 返回 42001，再刷新 component_access_token
+	f.expireTokens()
+	mediaID, err := client.AddDraft(testAuthorizerAppID, DraftArticle{Title: "标题", Content: "<p>正文</p>", ThumbMediaID: "thumb-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mediaID != "draft-1" {
+		t.Fatalf("unexpected media_id %q", mediaID)
+	}
+	if n := f.callCount("/cgi-bin/draft/add"); n != 2 {
+		t.Fatalf("want draft/add retried once, got %d calls", n)
+	}
+	if n := f.callCount("/cgi-bin/component/api_component_token"); n != 2 {
+		t.Fatalf("want component token refreshed, got %d requests", n)
+	}
+	if got := storedRefreshToken(t, db); got != "refresh-2" {
+		t.Fatalf("want refresh token rotated twice, got %q", got)
+	}
+
+	// 只重试一次：刷新后的凭据仍然无效时返回错误
+	f.mu.Lock()
+	f.refreshToken = "revoked"
+	f.mu.Unlock()
+	f.expireTokens()
+	_, err = client.AddDraft(testAuthorizerAppID, DraftArticle{Title: "标题", Content: "<p>正文</p>", ThumbMediaID: "thumb-1"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.ErrCode != 61023 {
+		t.Fatalf("want refresh token error, got %v", err)
+	}
+	if Retryable(err) {
+		t.Fatal("invalid refresh token should not be retryable")
+	}
+}
+
+func TestPlatformClientUploads(t *testing.T) {
+	client, f, _ := newTestPlatformClient(t)
+	src := "data:image/png;base64," + base64.StdEncoding.EncodeToString(testPNG)
+
+	content := `<p><img src="` + src + `"><img alt="x" src='` + src + `'>` +
+		`<img src="https://mmbiz.qpic.cn/mmbiz_png/existing/0"><img src="data:image/png;base64,!!"></p>`
+	rewritten, warnings := client.RewriteContentImages(testAuthorizerAppID, content)
+	if strings.Contains(rewritten, "data:image/png;base64,iVBOR") {
+		t.Fatalf("image not rewritten: %s", rewritten)
+	}
+	if strings.Count(rewritten, "http://mmbiz.qpic.cn/mmbiz_png/1/0") != 2 {
+		t.Fatalf("same image should be uploaded once and reused: %s", rewritten)
+	}
+	if !strings.Contains(rewritten, "mmbiz_png/existing/0") {
+		t.Fatalf("wechat image should be kept: %s", rewritten)
+	}
+	if len(warnings) != 1 || !strings.HasPrefix(warnings[0].Src, "data:image/png;base64,!!") {
+		t.Fatalf("unexpected warnings %+v", warnings)
+	}
+	if n := f.callCount("/cgi-bin/media/uploadimg"); n != 1 {
+		t.Fatalf("want 1 uploadimg request, got %d", n)
+	}
+
+	mediaID, err := client.UploadThumb(testAuthorizerAppID, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mediaID != "thumb-2" {
+		t.Fatalf("unexpected thumb media_id %q", mediaID)
+	}
+	f.mu.Lock()
+	uploads := append([]string(nil), f.uploads...)
+	f.mu.Unlock()
+	for _, name := range uploads {
+		if !strings.HasSuffix(name, ".png") {
+			t.Fatalf("uploaded file should keep the image extension, got %q", name)
+		}
+	}
+}
+
+func TestPlatformClientPublish(t *testing.T) {
+	client, f, _ := newTestPlatformClient(t)
+
+	mediaID, err := client.AddDraft(testAuthorizerAppID, DraftArticle{
+		Title:           "标题",
+		Author:          "作者",
+		Content:         "<p>正文</p>",
+		ThumbMediaID:    "thumb-1",
+		NeedOpenComment: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.mu.Lock()
+	draft := f.drafts[0]
+	f.mu.Unlock()
+	if draft.Title != "标题" || draft.ThumbMediaID != "thumb-1" || draft.NeedOpenComment != 1 {
+		t.Fatalf("unexpected draft %+v", draft)
+	}
+
+	publishID, err := client.SubmitPublish(testAuthorizerAppID, mediaID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if publishID != testPublishID {
+		t.Fatalf("publish_id lost precision: %s", publishID)
+	}
+
+	f.mu.Lock()
+	f.pendingPolls = 2
+	f.mu.Unlock()
+	result, err := client.WaitForPublish(testAuthorizerAppID, publishID, time.Millisecond, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Succeeded() || result.ArticleID != "article-1" || len(result.ArticleURLs) != 1 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if n := f.callCount("/cgi-bin/freepublish/get"); n != 3 {
+		t.Fatalf("want 3 status polls, got %d", n)
+	}
+
+	// 超时后返回最后一次的结果与 ErrPublishPending
+	f.mu.Lock()
+	f.pendingPolls = 1000
+	f.mu.Unlock()
+	result, err = client.WaitForPublish(testAuthorizerAppID, publishID, time.Millisecond, 20*time.Millisecond)
+	if !errors.Is(err, ErrPublishPending) || result == nil || !result.Pending() {
+		t.Fatalf("want pending result with ErrPublishPending, got %+v %v", result, err)
+	}
+}
@@ -0,0 +1,116 @@
+package wechatmp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 授权事件类型
+const (
+	InfoTypeVerifyTicket     = "component_verify_ticket"
+	InfoTypeAuthorized       = "authorized"
+	InfoTypeUpdateAuthorized = "updateauthorized"
+	InfoTypeUnauthorized     = "unauthorized"
+)
+
+// encryptedMessage 微信推送的加密消息体
+type encryptedMessage struct {
+	AppID   string `xml:"AppId"`
+	Encrypt string `xml:"Encrypt"`
+}
+
+// AuthEvent 第三方平台授权事件（验证票据推送、授权变更）
+type AuthEvent struct {
+	AppID                 string `xml:"AppId"`
+	CreateTime            int64  `xml:"CreateTime"`
+	InfoType              string `xml:"InfoType"`
+	ComponentVerifyTicket string `xml:"ComponentVerifyTicket"`
+	AuthorizerAppID       string `xml:"AuthorizerAppid"`
+}
+
+// DecryptAuthEvent 校验签名并解密微信推送的授权事件
+func (c *PlatformClient) DecryptAuthEvent(body []byte, msgSignature, timestamp, nonce string) (*AuthEvent, error) {
+	var msg encryptedMessage
+	if err := xml.Unmarshal(body, &msg); err != nil {
+		return nil, fmt.Errorf("解析推送消息失败: %w", err)
+	}
+	if msg.Encrypt == "" {
+		return nil, errors.New("推送消息缺少Encrypt字段")
+	}
+
+	expected := messageSignature(c.cfg.Token, timestamp, nonce, msg.Encrypt)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(msgSignature)) != 1 {
+		return nil, errors.New("推送消息签名校验失败")
+	}
+
+	plain, err := decryptMessage(c.cfg.EncodingAESKey, c.cfg.AppID, msg.Encrypt)
+	if err != nil {
+		return nil, err
+	}
+
+	var event AuthEvent
+	if err := xml.Unmarshal(plain, &event); err != nil {
+		return nil, fmt.Errorf("解析授权事件失败: %w", err)
+	}
+	return &event, nil
+}
+
+// messageSignature 消息签名：token、timestamp、nonce、密文按字典序排序拼接后做 sha1
+func messageSignature(token, timestamp, nonce, encrypt string) string {
+	parts := []string{token, timestamp, nonce, encrypt}
+	sort.Strings(parts)
+	sum := sha1.Sum([]byte(strings.Join(parts, "")))
+	return hex.EncodeToString(sum[:])
+}
+
+// decryptMessage 解密消息
+// 明文结构：16字节随机串 + 4字节消息长度(网络字节序) + 消息 + 第三方平台appid
+func decryptMessage(encodingAESKey, appID, encrypt string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encodingAESKey + "=")
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("EncodingAESKey无效")
+	}
+	data, err := base64.StdEncoding.DecodeString(encrypt)
+	if err != nil {
+		return nil, fmt.Errorf("密文解码失败: %w", err)
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("密文长度无效")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, key[:aes.BlockSize]).CryptBlocks(plain, data)
+
+	// PKCS#7 填充，块大小为 32
+	pad := int(plain[len(plain)-1])
+	if pad < 1 || pad > 32 || pad > len(plain) {
+		return nil, errors.New("密文填充无效")
+	}
+	plain = plain[:len(plain)-pad]
+
+	if len(plain) < 20 {
+		return nil, errors.New("明文长度无效")
+	}
+	msgLen := int(binary.BigEndian.Uint32(plain[16:20]))
+	if msgLen < 0 || 20+msgLen > len(plain) {
+		return nil, errors.New("明文长度无效")
+	}
+	if receiver := string(plain[20+msgLen:]); receiver != appID {
+		return nil, fmt.Errorf("消息接收方不匹配: %s", receiver)
+	}
+	return plain[20 : 20+msgLen], nil
+}
@@ -0,0 +1,155 @@
+package wechatmp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DraftArticle 草稿中的图文消息
+type DraftArticle struct {
+	Title              string `json:"title"`
+	Author             string `json:"author,omitempty"`
+	Digest             string `json:"digest,omitempty"`
+	Content            string `json:"content"`
+	ContentSourceURL   string `json:"content_source_url,omitempty"`
+	ThumbMediaID       string `json:"thumb_media_id"`
+	NeedOpenComment    int    `json:"need_open_comment"`
+	OnlyFansCanComment int    `json:"only_fans_can_comment"`
+}
+
+// 发布状态，见 freepublish/get 接口
+const (
+	PublishStatusSuccess        = 0
+	PublishStatusPublishing     = 1
+	PublishStatusOriginalFailed = 2
+	PublishStatusFailed         = 3
+	PublishStatusAuditRejected  = 4
+	PublishStatusDeletedByUser  = 5
+	PublishStatusBannedBySystem = 6
+	publishStatusUnknown        = -1
+)
+
+// PublishResult 发布任务的状态
+type PublishResult struct {
+	PublishID     string   `json:"publish_id"`
+	PublishStatus int      `json:"publish_status"`
+	ArticleID     string   `json:"article_id,omitempty"`
+	ArticleURLs   []string `json:"article_urls,omitempty"`
+	FailIdx       []int    `json:"fail_idx,omitempty"`
+}
+
+// Pending 发布仍在进行中
+func (r *PublishResult) Pending() bool {
+	return r.PublishStatus == PublishStatusPublishing
+}
+
+// Succeeded 发布成功
+func (r *PublishResult) Succeeded() bool {
+	return r.PublishStatus == PublishStatusSuccess
+}
+
+// StatusMessage 发布状态的说明
+func (r *PublishResult) StatusMessage() string {
+	switch r.PublishStatus {
+	case PublishStatusSuccess:
+		return "发布成功"
+	case PublishStatusPublishing:
+		return "发布中"
+	case PublishStatusOriginalFailed:
+		return "原创声明失败"
+	case PublishStatusFailed:
+		return "常规失败"
+	case PublishStatusAuditRejected:
+		return "平台审核不通过"
+	case PublishStatusDeletedByUser:
+		return "成功后用户删除所有文章"
+	case PublishStatusBannedBySystem:
+		return "成功后系统封禁所有文章"
+	default:
+		return fmt.Sprintf("未知状态: %d", r.PublishStatus)
+	}
+}
+
+// AddDraft 新建草稿，返回草稿的 media_id
+func (c *PlatformClient) AddDraft(appID string, article DraftArticle) (string, error) {
+	var resp struct {
+		MediaID string `json:"media_id"`
+	}
+	err := c.withAuthorizerToken(appID, func(token string) error {
+		return c.postJSON("/cgi-bin/draft/add", tokenQuery("access_token", token), map[string]interface{}{
+			"articles": []DraftArticle{article},
+		}, &resp)
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.MediaID == "" {
+		return "", errors.New("新建草稿未返回media_id")
+	}
+	return resp.MediaID, nil
+}
+
+// SubmitPublish 提交草稿发布，返回发布任务的 publish_id
+// 发布是异步的，结果通过 GetPublishResult 轮询
+func (c *PlatformClient) SubmitPublish(appID, mediaID string) (string, error) {
+	var resp struct {
+		PublishID json.RawMessage `json:"publish_id"`
+	}
+	err := c.withAuthorizerToken(appID, func(token string) error {
+		return c.postJSON("/cgi-bin/freepublish/submit", tokenQuery("access_token", token),
+			map[string]string{"media_id": mediaID}, &resp)
+	})
+	if err != nil {
+		return "", err
+	}
+	publishID := idString(resp.PublishID)
+	if publishID == "" {
+		return "", errors.New("提交发布未返回publish_id")
+	}
+	return publishID, nil
+}
+
+// GetPublishResult 查询发布任务的状态
+func (c *PlatformClient) GetPublishResult(appID, publishID string) (*PublishResult, error) {
+	var resp struct {
+		PublishStatus *int   `json:"publish_status"`
+		ArticleID     string `json:"article_id"`
+		ArticleDetail struct {
+			Item []struct {
+				Idx        int    `json:"idx"`
+				ArticleURL string `json:"article_url"`
+			} `json:"item"`
+		} `json:"article_detail"`
+		FailIdx []int `json:"fail_idx"`
+	}
+	err := c.withAuthorizerToken(appID, func(token string) error {
+		return c.postJSON("/cgi-bin/freepublish/get", tokenQuery("access_token", token),
+			map[string]string{"publish_id": publishID}, &resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PublishResult{
+		PublishID:     publishID,
+		PublishStatus: publishStatusUnknown,
+		ArticleID:     resp.ArticleID,
+		FailIdx:       resp.FailIdx,
+	}
+	if resp.PublishStatus != nil {
+		result.PublishStatus = *resp.PublishStatus
+	}
+	for _, item := range resp.ArticleDetail.Item {
+		if item.ArticleURL != "" {
+			result.ArticleURLs = append(result.ArticleURLs, item.ArticleURL)
+		}
+	}
+	return result, nil
+}
+
+// idString publish_id 在不同版本的接口中可能是数字或字符串，数字按原文保留避免精度丢失
+func idString(raw json.RawMessage) string {
+	return strings.Trim(strings.TrimSpace(string(raw)), `"`)
+}
@@ -0,0 +1,151 @@
+package wechatmp
+
+import (
+	"context"
+	"errors"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"01agent_server/internal/tools"
+)
+
+const (
+	// maxImageBytes 图片下载上限，公众号图文内图片限制 1MB、永久素材限制 10MB，超出部分由微信返回错误
+	maxImageBytes = 10 << 20
+)
+
+var (
+	reImgTag = regexp.MustCompile(`(?is)<img\b[^>]*>`)
+	reImgSrc = regexp.MustCompile(`(?is)(\ssrc\s*=\s*)("[^"]*"|'[^']*')`)
+)
+
+// isWechatImage 已在微信图床上的图片无需重新上传
+func isWechatImage(src string) bool {
+	u, err := url.Parse(src)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	return host == "mmbiz.qpic.cn" || strings.HasSuffix(host, ".qpic.cn")
+}
+
+// fetchImage 下载图片，支持 http(s) 地址与 data URI，返回数据与带扩展名的文件名
+// 图片地址来自用户内容，通过只能访问公网地址的客户端下载
+func (c *PlatformClient) fetchImage(src string) ([]byte, string, error) {
+	img, err := tools.FetchImage(context.Background(), src, maxImageBytes)
+	if err != nil {
+		return nil, "", err
+	}
+	return img.Data, img.Filename, nil
+}
+
+// UploadContentImage 上传图文消息内的图片，返回微信图床地址
+func (c *PlatformClient) UploadContentImage(appID, src string) (string, error) {
+	data, filename, err := c.fetchImage(src)
+	if err != nil {
+		return "", err
+	}
+
+	var resp struct {
+		URL string `json:"url"`
+	}
+	err = c.withAuthorizerToken(appID, func(token string) error {
+		return c.postFile("/cgi-bin/media/uploadimg", tokenQuery("access_token", token), filename, data, &resp)
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.URL == "" {
+		return "", errors.New("上传图片未返回地址")
+	}
+	return resp.URL, nil
+}
+
+// UploadThumb 上传封面图为永久素材，返回 media_id
+func (c *PlatformClient) UploadThumb(appID, src string) (string, error) {
+	data, filename, err := c.fetchImage(src)
+	if err != nil {
+		return "", err
+	}
+
+	query := func(token string) url.Values {
+		q := tokenQuery("access_token", token)
+		q.Set("type", "image")
+		return q
+	}
+	var resp struct {
+		MediaID string `json:"media_id"`
+	}
+	err = c.withAuthorizerToken(appID, func(token string) error {
+		return c.postFile("/cgi-bin/material/add_material", query(token), filename, data, &resp)
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.MediaID == "" {
+		return "", errors.New("上传封面未返回media_id")
+	}
+	return resp.MediaID, nil
+}
+
+// ImageWarning 单张图片处理失败的记录，图片保留原地址
+type ImageWarning struct {
+	Src   string `json:"src"`
+	Error string `json:"error"`
+}
+
+// RewriteContentImages 将正文中的图片上传到微信图床并替换 <img> 的 src
+// 同一地址只上传一次，单张图片失败不影响整体，原地址保留并记录到 warnings
+func (c *PlatformClient) RewriteContentImages(appID, content string) (string, []ImageWarning) {
+	uploaded := make(map[string]string)
+	failed := make(map[string]bool)
+	var warnings []ImageWarning
+
+	rewritten := reImgTag.ReplaceAllStringFunc(content, func(tag string) string {
+		m := reImgSrc.FindStringSubmatchIndex(tag)
+		if m == nil {
+			return tag
+		}
+		quoted := tag[m[4]:m[5]]
+		src := strings.TrimSpace(html.UnescapeString(quoted[1 : len(quoted)-1]))
+		if src == "" || isWechatImage(src) || failed[src] {
+			return tag
+		}
+
+		newSrc, ok := uploaded[src]
+		if !ok {
+			var err error
+			newSrc, err = c.UploadContentImage(appID, src)
+			if err != nil {
+				failed[src] = true
+				warnings = append(warnings, ImageWarning{Src: truncateSrc(src), Error: err.Error()})
+				return tag
+			}
+			uploaded[src] = newSrc
+		}
+		return tag[:m[4]] + `"` + html.EscapeString(newSrc) + `"` + tag[m[5]:]
+	})
+	return rewritten, warnings
+}
+
+// FirstContentImage 返回正文中第一张图片的地址
+func FirstContentImage(content string) string {
+	for _, tag := range reImgTag.FindAllString(content, -1) {
+		if m := reImgSrc.FindStringSubmatch(tag); m != nil {
+			if src := strings.TrimSpace(html.UnescapeString(m[2][1 : len(m[2])-1])); src != "" {
+				return src
+			}
+		}
+	}
+	return ""
+}
+
+// truncateSrc data URI 只保留开头部分，避免错误信息过长
+func truncateSrc(src string) string {
+	if len(src) > 120 {
+		return src[:120] + "..."
+	}
+	return src
+}
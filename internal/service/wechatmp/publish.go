@@ -0,0 +1,43 @@
+package wechatmp
+
+import (
+	"errors"
+	"time"
+)
+
+// 发布结果轮询的默认间隔与超时，审核通常在数分钟内完成
+const (
+	DefaultPollInterval = 5 * time.Second
+	DefaultPollTimeout  = 5 * time.Minute
+)
+
+// ErrPublishPending 轮询超时时发布仍在进行中，稍后可再次查询
+var ErrPublishPending = errors.New("公众号发布仍在进行中")
+
+// WaitForPublish 轮询发布结果直到结束或超时
+// 查询失败时继续重试，超时返回最后一次查询到的结果与 ErrPublishPending
+func (c *PlatformClient) WaitForPublish(appID, publishID string, interval, timeout time.Duration) (*PublishResult, error) {
+	deadline := time.Now().Add(timeout)
+	var last *PublishResult
+	var lastErr error
+
+	for {
+		result, err := c.GetPublishResult(appID, publishID)
+		if err == nil {
+			last, lastErr = result, nil
+			if !result.Pending() {
+				return result, nil
+			}
+		} else {
+			lastErr = err
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			if last == nil && lastErr != nil {
+				return nil, lastErr
+			}
+			return last, ErrPublishPending
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,258 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	remoteFetchTimeout  = 30 * time.Second
+	remoteMaxRedirects  = 5
+	remoteDialTimeout   = 10 * time.Second
+	defaultImageMaxSize = 10 << 20
+)
+
+var (
+	// ErrBlockedAddress 目标地址为本机、内网或保留地址
+	ErrBlockedAddress = errors.New("不允许访问内网或保留地址")
+	// ErrBlockedPort 目标端口不在允许范围内
+	ErrBlockedPort = errors.New("不允许访问该端口")
+	// ErrUnsupportedImageSrc 图片地址既不是 http(s) 也不是 data URI
+	ErrUnsupportedImageSrc = errors.New("不支持的图片地址")
+)
+
+// allowedRemotePorts 下载用户提供的地址时允许的端口
+var allowedRemotePorts = map[string]bool{"80": true, "443": true, "8080": true, "8443": true}
+
+// blockedNetworks 回环、私有、链路本地（含 169.254.169.254 元数据服务）与组播地址之外额外禁止的网段
+var blockedNetworks = func() []*net.IPNet {
+	cidrs := []string{
+		"0.0.0.0/8",     // 本网络
+		"100.64.0.0/10", // 运营商级 NAT
+		"192.0.0.0/24",  // IETF 协议分配
+		"198.18.0.0/15", // 基准测试
+		"240.0.0.0/4",   // 保留地址与广播
+		"64:ff9b::/96",  // NAT64，可映射到内网 IPv4
+		"2002::/16",     // 6to4，可映射到内网 IPv4
+		"100::/64",      // 丢弃前缀
+		"fec0::/10",     // 已废弃的站点本地地址
+	}
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}()
+
+// CheckPublicIP 判断 IP 是否为可访问的公网地址
+func CheckPublicIP(ip net.IP) error {
+	if ip == nil || ip.IsUnspecified() || ip.IsLoopback() || ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return ErrBlockedAddress
+	}
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return ErrBlockedAddress
+		}
+	}
+	return nil
+}
+
+// remoteGuard 下载用户提供的地址时的访问限制
+type remoteGuard struct {
+	ports   map[string]bool
+	checkIP func(net.IP) error
+}
+
+var defaultRemoteGuard = remoteGuard{ports: allowedRemotePorts, checkIP: CheckPublicIP}
+
+// checkURL 校验协议与端口，地址在建立连接时按解析后的 IP 校验
+func (g remoteGuard) checkURL(u *url.URL) error {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrUnsupportedImageSrc
+	}
+	port := u.Port()
+	if port == "" {
+		return nil
+	}
+	if !g.ports[port] {
+		return ErrBlockedPort
+	}
+	return nil
+}
+
+// client 创建只能访问允许地址的 HTTP 客户端
+// 在连接建立前校验解析后的 IP，DNS 重绑定与每一跳重定向都会重新校验；不使用环境变量中的代理
+func (g remoteGuard) client() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: remoteDialTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, port, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !g.ports[port] {
+				return ErrBlockedPort
+			}
+			return g.checkIP(net.ParseIP(host))
+		},
+	}
+	transport := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          50,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 20 * time.Second,
+	}
+	return &http.Client{
+		Timeout:   remoteFetchTimeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= remoteMaxRedirects {
+				return errors.New("重定向次数过多")
+			}
+			return g.checkURL(req.URL)
+		},
+	}
+}
+
+var (
+	guardedClient     *http.Client
+	guardedClientOnce sync.Once
+)
+
+// GuardedHTTPClient 下载用户提供的地址时使用的 HTTP 客户端，拒绝访问本机、内网、链路本地与元数据地址
+func GuardedHTTPClient() *http.Client {
+	guardedClientOnce.Do(func() {
+		guardedClient = defaultRemoteGuard.client()
+	})
+	return guardedClient
+}
+
+// imageExtensions 根据 Content-Type 推断文件扩展名，部分平台按扩展名识别图片格式
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/jpg":  ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/bmp":  ".bmp",
+	"image/webp": ".webp",
+}
+
+// RemoteImage 下载或解码得到的图片
+type RemoteImage struct {
+	Data        []byte
+	ContentType string // 按内容识别的类型，无法识别时使用声明的类型
+	Filename    string // 带扩展名的文件名，如 image.png
+}
+
+// FetchImage 获取用户内容中引用的图片，支持 http(s) 地址、// 开头的地址与 base64 data URI
+// 网络图片通过 GuardedHTTPClient 下载；maxBytes 不大于 0 时上限为 10MB
+func FetchImage(ctx context.Context, src string, maxBytes int64) (*RemoteImage, error) {
+	return fetchImage(ctx, GuardedHTTPClient(), defaultRemoteGuard, src, maxBytes)
+}
+
+func fetchImage(ctx context.Context, client *http.Client, guard remoteGuard, src string, maxBytes int64) (*RemoteImage, error) {
+	if maxBytes <= 0 {
+		maxBytes = defaultImageMaxSize
+	}
+	src = strings.TrimSpace(src)
+	if len(src) > 5 && strings.EqualFold(src[:5], "data:") {
+		return decodeImageDataURI(src, maxBytes)
+	}
+	if strings.HasPrefix(src, "//") {
+		src = "https:" + src
+	}
+
+	u, err := url.Parse(src)
+	if err != nil {
+		return nil, ErrUnsupportedImageSrc
+	}
+	if err := guard.checkURL(u); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("下载图片失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("下载图片失败: HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("下载图片失败: %w", err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("图片超过%dMB", maxBytes>>20)
+	}
+	return newRemoteImage(data, resp.Header.Get("Content-Type"), u.Path), nil
+}
+
+// decodeImageDataURI 解析 base64 编码的 data URI
+func decodeImageDataURI(src string, maxBytes int64) (*RemoteImage, error) {
+	comma := strings.IndexByte(src, ',')
+	if comma < 0 {
+		return nil, errors.New("data URI格式无效")
+	}
+	meta := strings.ToLower(src[len("data:"):comma])
+	if !strings.HasSuffix(meta, ";base64") {
+		return nil, errors.New("仅支持base64编码的data URI")
+	}
+	payload := src[comma+1:]
+	if int64(base64.StdEncoding.DecodedLen(len(payload))) > maxBytes+2 {
+		return nil, fmt.Errorf("图片超过%dMB", maxBytes>>20)
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("data URI解码失败: %w", err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("图片超过%dMB", maxBytes>>20)
+	}
+	return newRemoteImage(data, strings.TrimSuffix(meta, ";base64"), ""), nil
+}
+
+func newRemoteImage(data []byte, declared, urlPath string) *RemoteImage {
+	declared = strings.ToLower(strings.TrimSpace(strings.SplitN(declared, ";", 2)[0]))
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") && declared != "" && declared != "application/octet-stream" {
+		// svg 等格式无法按内容识别
+		contentType = declared
+	}
+	return &RemoteImage{Data: data, ContentType: contentType, Filename: imageFilename(urlPath, contentType)}
+}
+
+func imageFilename(urlPath, contentType string) string {
+	if ext, ok := imageExtensions[contentType]; ok {
+		return "image" + ext
+	}
+	if ext := strings.ToLower(path.Ext(urlPath)); ext != "" && len(ext) <= 5 {
+		return "image" + ext
+	}
+	return "image.jpg"
+}
@@ -0,0 +1,164 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// pngPixel 1x1 透明 PNG
+var pngPixel, _ = base64.StdEncoding.DecodeString("iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAQAAAC1HAwCAAAAC0lEQVR42mNkYAAAAAYAAjCB0C8AAAAASUVORK5CYII=")
+
+// testGuard 允许访问测试服务器所在的回环地址与端口，其余地址按公网规则校验
+func testGuard(t *testing.T, server *httptest.Server, extraPorts ...string) remoteGuard {
+	t.Helper()
+	u, _ := url.Parse(server.URL)
+	ports := map[string]bool{u.Port(): true}
+	for _, p := range extraPorts {
+		ports[p] = true
+	}
+	return remoteGuard{
+		ports: ports,
+		checkIP: func(ip net.IP) error {
+			if ip.Equal(net.IPv4(127, 0, 0, 1)) {
+				return nil
+			}
+			return CheckPublicIP(ip)
+		},
+	}
+}
+
+func TestCheckPublicIP(t *testing.T) {
+	blocked := []string{
+		"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254",
+		"100.64.0.1", "0.0.0.0", "224.0.0.1", "255.255.255.255",
+		"::1", "fe80::1", "fc00::1", "fd00:ec2::254", "::ffff:127.0.0.1", "::ffff:10.0.0.1", "64:ff9b::a00:1",
+	}
+	for _, addr := range blocked {
+		if err := CheckPublicIP(net.ParseIP(addr)); !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("%s: want ErrBlockedAddress, got %v", addr, err)
+		}
+	}
+	for _, addr := range []string{"8.8.8.8", "203.119.1.1", "2001:4860:4860::8888"} {
+		if err := CheckPublicIP(net.ParseIP(addr)); err != nil {
+			t.Errorf("%s: want allowed, got %v", addr, err)
+		}
+	}
+}
+
+func TestFetchImageRejectsLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pngPixel)
+	}))
+	defer server.Close()
+
+	// 默认规则下测试服务器的端口不在允许范围内
+	if _, err := FetchImage(context.Background(), server.URL+"/a.png", 0); !errors.Is(err, ErrBlockedPort) {
+		t.Fatalf("want ErrBlockedPort, got %v", err)
+	}
+
+	// 放开端口后回环地址仍然在连接前被拒绝
+	u, _ := url.Parse(server.URL)
+	guard := remoteGuard{ports: map[string]bool{u.Port(): true}, checkIP: CheckPublicIP}
+	if _, err := fetchImage(context.Background(), guard.client(), guard, server.URL+"/a.png", 0); !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("want ErrBlockedAddress, got %v", err)
+	}
+}
+
+func TestFetchImageChecksRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/metadata":
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+		case "/private":
+			http.Redirect(w, r, "http://10.0.0.5/internal.png", http.StatusFound)
+		case "/ssh":
+			http.Redirect(w, r, "http://example.com:22/", http.StatusFound)
+		case "/file":
+			http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			w.Write(pngPixel)
+		}
+	}))
+	defer server.Close()
+	guard := testGuard(t, server, "80")
+	client := guard.client()
+
+	cases := map[string]error{
+		"/metadata": ErrBlockedAddress,
+		"/private":  ErrBlockedAddress,
+		"/ssh":      ErrBlockedPort,
+		"/file":     ErrUnsupportedImageSrc,
+	}
+	for p, want := range cases {
+		if _, err := fetchImage(context.Background(), client, guard, server.URL+p, 0); !errors.Is(err, want) {
+			t.Errorf("%s: want %v, got %v", p, want, err)
+		}
+	}
+	if _, err := fetchImage(context.Background(), client, guard, server.URL+"/loop", 0); err == nil || !strings.Contains(err.Error(), "重定向次数过多") {
+		t.Errorf("/loop: want redirect limit error, got %v", err)
+	}
+}
+
+func TestFetchImageDownloads(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/big" {
+			w.Write(make([]byte, 2048))
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(pngPixel)
+	}))
+	defer server.Close()
+	guard := testGuard(t, server)
+
+	img, err := fetchImage(context.Background(), guard.client(), guard, server.URL+"/pic", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.ContentType != "image/png" || img.Filename != "image.png" || len(img.Data) != len(pngPixel) {
+		t.Fatalf("unexpected image: %s %s %d", img.ContentType, img.Filename, len(img.Data))
+	}
+	if _, err := fetchImage(context.Background(), guard.client(), guard, server.URL+"/big", 1024); err == nil {
+		t.Fatal("want size limit error")
+	}
+}
+
+func TestFetchImageSources(t *testing.T) {
+	dataURI := "data:image/png;base64," + base64.StdEncoding.EncodeToString(pngPixel)
+	img, err := FetchImage(context.Background(), dataURI, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Filename != "image.png" {
+		t.Fatalf("want image.png, got %s", img.Filename)
+	}
+
+	svg := "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`))
+	if img, err := FetchImage(context.Background(), svg, 0); err != nil || img.ContentType != "image/svg+xml" {
+		t.Fatalf("svg data URI: %v %+v", err, img)
+	}
+
+	for _, src := range []string{
+		"data:image/png,rawdata",
+		"file:///etc/passwd",
+		"gopher://127.0.0.1:70/",
+		"ftp://example.com/a.png",
+		"http://127.0.0.1:6379/",
+	} {
+		if _, err := FetchImage(context.Background(), src, 0); err == nil {
+			t.Errorf("%s: want error", src)
+		}
+	}
+	if _, err := FetchImage(context.Background(), dataURI, 10); err == nil {
+		t.Error("want size limit error for data URI")
+	}
+}
@@ -27,7 +27,7 @@ func GenerateToken(userID string, username string) (string, error) {
 		Username: username,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Subject:   userID, // 添加Subject字段
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(cfg.Expire)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenExpire())),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    "gin_web",
@@ -36,7 +36,7 @@ func GenerateToken(userID string, username string) (string, error) {
 
 	// 添加调试信息
 	// fmt.Printf("GenerateToken - Creating token for UserID: '%s', Username: '%s'\n", userID, username)
-	// fmt.Printf("JWT Config - Secret: %s, Expire: %v\n", cfg.Secret, cfg.Expire)
+	// fmt.Printf("JWT Config - Secret: %s, Expire: %v\n", cfg.Secret, AccessTokenExpire())
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString([]byte(cfg.Secret))
@@ -133,20 +133,27 @@ func ParseToken(tokenString string) (*Claims, error) {
 	return nil, errors.New("invalid token")
 }
 
-// RefreshToken 刷新token
-func RefreshToken(tokenString string) (string, error) {
-	claims, err := ParseToken(tokenString)
-	if err != nil {
-		return "", err
-	}
+// defaultAccessTokenExpire access token 默认有效期
+const defaultAccessTokenExpire = 2 * time.Hour
 
-	// 检查token是否即将过期（还有1小时过期）
-	if time.Until(claims.ExpiresAt.Time) > time.Hour {
-		return tokenString, nil
+// AccessTokenExpire 获取access token有效期（默认2小时）
+// 未配置 jwt.accessExpire 时兼容旧的 jwt.expire 配置
+func AccessTokenExpire() time.Duration {
+	if expire := config.AppConfig.JWT.AccessExpire; expire > 0 {
+		return expire
 	}
+	if expire := config.AppConfig.JWT.Expire; expire > 0 {
+		return expire
+	}
+	return defaultAccessTokenExpire
+}
 
-	// 生成新token
-	return GenerateToken(claims.UserID, claims.Username)
+// RefreshTokenExpire 获取refresh token有效期（默认30天）
+func RefreshTokenExpire() time.Duration {
+	if expire := config.AppConfig.JWT.RefreshExpire; expire > 0 {
+		return expire
+	}
+	return 720 * time.Hour
 }
 
 // GetStringValue 获取字符串指针的值，如果为nil则返回空字符串
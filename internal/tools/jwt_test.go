@@ -0,0 +1,39 @@
+package tools
+
+import (
+	"testing"
+	"time"
+
+	"01agent_server/internal/config"
+)
+
+func TestAccessTokenExpire(t *testing.T) {
+	prev := config.AppConfig
+	defer func() { config.AppConfig = prev }()
+
+	for _, expire := range []time.Duration{0, -time.Minute} {
+		config.AppConfig = &config.Config{JWT: config.JWTConfig{Secret: "test", AccessExpire: expire}}
+		if got := AccessTokenExpire(); got != defaultAccessTokenExpire {
+			t.Errorf("AccessExpire=%v: want default %v, got %v", expire, defaultAccessTokenExpire, got)
+		}
+	}
+
+	// 未配置 accessExpire 时沿用旧的 jwt.expire
+	config.AppConfig = &config.Config{JWT: config.JWTConfig{Secret: "test", Expire: 24 * time.Hour}}
+	if got := AccessTokenExpire(); got != 24*time.Hour {
+		t.Errorf("want legacy expire 24h, got %v", got)
+	}
+
+	config.AppConfig = &config.Config{JWT: config.JWTConfig{Secret: "test", AccessExpire: 15 * time.Minute, Expire: 24 * time.Hour}}
+	token, err := GenerateToken("u1", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ParseToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl := time.Until(claims.ExpiresAt.Time); ttl > 15*time.Minute || ttl < 14*time.Minute {
+		t.Fatalf("want token to expire in 15m, got %v", ttl)
+	}
+}
@@ -0,0 +1,254 @@
+package tools
+
+import (
+	"container/list"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LRUCache 进程内缓存，同时按条数和字节数限制容量，超出时淘汰最久未使用的条目
+// 条目超过有效期后在下一次读取时失效
+type LRUCache struct {
+	name       string
+	maxEntries int
+	maxBytes   int64
+	ttl        time.Duration
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	bytes int64
+
+	hits        uint64
+	misses      uint64
+	evictions   uint64
+	expirations uint64
+}
+
+type lruEntry struct {
+	key       string
+	value     interface{}
+	size      int64
+	expiresAt time.Time
+}
+
+// LRUCacheStats 缓存统计信息
+type LRUCacheStats struct {
+	Name        string  `json:"name"`
+	Entries     int     `json:"entries"`
+	Bytes       int64   `json:"bytes"`
+	MaxEntries  int     `json:"max_entries"`
+	MaxBytes    int64   `json:"max_bytes"`
+	TTLSeconds  int64   `json:"ttl_seconds"`
+	Hits        uint64  `json:"hits"`
+	Misses      uint64  `json:"misses"`
+	Evictions   uint64  `json:"evictions"`
+	Expirations uint64  `json:"expirations"`
+	HitRate     float64 `json:"hit_rate"`
+}
+
+// NewLRUCache 创建缓存，maxEntries / maxBytes / ttl 为 0 时表示不限制
+func NewLRUCache(name string, maxEntries int, maxBytes int64, ttl time.Duration) *LRUCache {
+	return &LRUCache{
+		name:       name,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ttl:        ttl,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get 读取缓存，命中时将条目移到最近使用的位置
+func (c *LRUCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+	entry := elem.Value.(*lruEntry)
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		c.removeElement(elem)
+		atomic.AddUint64(&c.expirations, 1)
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+
+	c.ll.MoveToFront(elem)
+	atomic.AddUint64(&c.hits, 1)
+	return entry.value, true
+}
+
+// Set 写入缓存，size 为条目占用的字节数估算
+// 单个条目超过字节上限时不缓存
+func (c *LRUCache) Set(key string, value interface{}, size int64) {
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return
+	}
+
+	var expiresAt time.Time
+	if c.ttl > 0 {
+		expiresAt = time.Now().Add(c.ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*lruEntry)
+		c.bytes += size - entry.size
+		entry.value = value
+		entry.size = size
+		entry.expiresAt = expiresAt
+		c.ll.MoveToFront(elem)
+	} else {
+		c.items[key] = c.ll.PushFront(&lruEntry{key: key, value: value, size: size, expiresAt: expiresAt})
+		c.bytes += size
+	}
+
+	for c.overflow() {
+		oldest := c.ll.Back()
+		if oldest == nil {
+			break
+		}
+		c.removeElement(oldest)
+		atomic.AddUint64(&c.evictions, 1)
+	}
+}
+
+// Delete 删除指定条目
+func (c *LRUCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// DeletePrefix 删除键以 prefix 开头的条目，返回删除数量
+func (c *LRUCache) DeletePrefix(prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count := 0
+	for key, elem := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.removeElement(elem)
+			count++
+		}
+	}
+	return count
+}
+
+// Purge 清空缓存，统计计数保留
+func (c *LRUCache) Purge() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count := len(c.items)
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+	c.bytes = 0
+	return count
+}
+
+// Stats 返回当前的容量与命中统计
+func (c *LRUCache) Stats() LRUCacheStats {
+	c.mu.Lock()
+	entries, bytes := len(c.items), c.bytes
+	c.mu.Unlock()
+
+	stats := LRUCacheStats{
+		Name:        c.name,
+		Entries:     entries,
+		Bytes:       bytes,
+		MaxEntries:  c.maxEntries,
+		MaxBytes:    c.maxBytes,
+		TTLSeconds:  int64(c.ttl / time.Second),
+		Hits:        atomic.LoadUint64(&c.hits),
+		Misses:      atomic.LoadUint64(&c.misses),
+		Evictions:   atomic.LoadUint64(&c.evictions),
+		Expirations: atomic.LoadUint64(&c.expirations),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+func (c *LRUCache) overflow() bool {
+	if c.maxEntries > 0 && c.ll.Len() > c.maxEntries {
+		return true
+	}
+	return c.maxBytes > 0 && c.bytes > c.maxBytes
+}
+
+func (c *LRUCache) removeElement(elem *list.Element) {
+	entry := elem.Value.(*lruEntry)
+	c.ll.Remove(elem)
+	delete(c.items, entry.key)
+	c.bytes -= entry.size
+}
+
+// singleFlight 合并同一个键的并发调用，只有第一个调用真正执行，其余等待并共享结果
+type singleFlight struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+
+	shared uint64
+}
+
+type flightCall struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+// Do 执行 fn，shared 表示结果来自其他并发调用
+// fn 发生 panic 时会被恢复，执行者与等待者都收到包含堆栈的错误，value 为空
+func (g *singleFlight) Do(key string, fn func() (interface{}, error)) (value interface{}, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		call.wg.Wait()
+		atomic.AddUint64(&g.shared, 1)
+		return call.value, call.err, true
+	}
+	call := &flightCall{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		call.wg.Done()
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				call.value = nil
+				call.err = fmt.Errorf("singleflight %s panic: %v\n%s", key, r, debug.Stack())
+			}
+		}()
+		call.value, call.err = fn()
+	}()
+	return call.value, call.err, false
+}
+
+// Shared 返回被合并的调用次数
+func (g *singleFlight) Shared() uint64 {
+	return atomic.LoadUint64(&g.shared)
+}
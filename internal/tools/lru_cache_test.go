@@ -0,0 +1,56 @@
+package tools
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSingleFlightRecoversPanic(t *testing.T) {
+	var g singleFlight
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	type outcome struct {
+		err    error
+		shared bool
+	}
+	results := make([]outcome, 3)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err, shared := g.Do("k", func() (interface{}, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+		results[0] = outcome{err, shared}
+	}()
+	<-started
+	for i := 1; i < len(results); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err, shared := g.Do("k", func() (interface{}, error) { return "ok", nil })
+			results[i] = outcome{err, shared}
+		}(i)
+	}
+	// 给等待者进入等待的时间，未赶上合并的调用会自行执行
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for i, r := range results {
+		if (i == 0 || r.shared) && (r.err == nil || !strings.Contains(r.err.Error(), "boom")) {
+			t.Errorf("call %d: want panic error, got %v", i, r.err)
+		}
+	}
+
+	// panic 后键被释放，后续调用正常执行
+	value, err, shared := g.Do("k", func() (interface{}, error) { return "ok", nil })
+	if err != nil || value != "ok" || shared {
+		t.Fatalf("want fresh call, got %v %v %v", value, err, shared)
+	}
+}
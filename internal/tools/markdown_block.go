@@ -0,0 +1,891 @@
+package tools
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// mdNodeType Markdown 语法树节点类型
+type mdNodeType int
+
+const (
+	// 块级节点
+	mdDocument mdNodeType = iota
+	mdBlockquote
+	mdList
+	mdItem
+	mdParagraph
+	mdHeading
+	mdThematicBreak
+	mdCodeBlock
+	mdHTMLBlock
+	mdTable
+	mdTableRow
+	mdTableCell
+
+	// 行内节点
+	mdText
+	mdSoftBreak
+	mdHardBreak
+	mdCodeSpan
+	mdEmph
+	mdStrong
+	mdStrikethrough
+	mdLink
+	mdImage
+	mdHTMLInline
+)
+
+// mdNode 语法树节点，子节点以双向链表组织，便于行内解析时移动节点
+type mdNode struct {
+	typ        mdNodeType
+	parent     *mdNode
+	firstChild *mdNode
+	lastChild  *mdNode
+	prev       *mdNode
+	next       *mdNode
+
+	// 解析状态
+	open          bool
+	lastLineBlank bool
+	startLine     int
+	content       []byte // 段落、标题、代码块等收集的原始文本
+
+	literal string // 文本、代码、HTML 的内容
+	level   int    // 标题级别
+
+	// 列表
+	list    *mdListData
+	tight   bool
+	checked *bool // 任务列表项的勾选状态，非任务项为 nil
+
+	// 代码块
+	fenced      bool
+	fenceChar   byte
+	fenceLength int
+	fenceOffset int
+	info        string
+
+	htmlBlockType int
+
+	// 表格
+	align  []string   // 各列对齐方式：left / center / right / 空
+	rows   [][]string // 表格原始单元格，第一行为表头
+	header bool       // 表头行或表头单元格
+
+	// 链接与图片
+	destination string
+	title       string
+}
+
+// mdListData 列表标记信息
+type mdListData struct {
+	ordered      bool
+	bulletChar   byte
+	delimiter    byte
+	start        int
+	markerOffset int
+	padding      int
+}
+
+// mdLinkRef 链接引用定义
+type mdLinkRef struct {
+	destination string
+	title       string
+}
+
+func newMdNode(typ mdNodeType) *mdNode {
+	return &mdNode{typ: typ, open: true}
+}
+
+func (n *mdNode) appendChild(child *mdNode) {
+	child.unlink()
+	child.parent = n
+	if n.lastChild != nil {
+		n.lastChild.next = child
+		child.prev = n.lastChild
+		n.lastChild = child
+	} else {
+		n.firstChild = child
+		n.lastChild = child
+	}
+}
+
+func (n *mdNode) unlink() {
+	if n.prev != nil {
+		n.prev.next = n.next
+	} else if n.parent != nil {
+		n.parent.firstChild = n.next
+	}
+	if n.next != nil {
+		n.next.prev = n.prev
+	} else if n.parent != nil {
+		n.parent.lastChild = n.prev
+	}
+	n.parent = nil
+	n.next = nil
+	n.prev = nil
+}
+
+func (n *mdNode) insertAfter(sibling *mdNode) {
+	sibling.unlink()
+	sibling.next = n.next
+	if sibling.next != nil {
+		sibling.next.prev = sibling
+	}
+	sibling.prev = n
+	n.next = sibling
+	sibling.parent = n.parent
+	if sibling.next == nil && sibling.parent != nil {
+		sibling.parent.lastChild = sibling
+	}
+}
+
+func (n *mdNode) isContainer() bool {
+	switch n.typ {
+	case mdDocument, mdBlockquote, mdList, mdItem, mdParagraph, mdHeading, mdTable, mdTableRow, mdTableCell,
+		mdEmph, mdStrong, mdStrikethrough, mdLink, mdImage:
+		return true
+	}
+	return false
+}
+
+var (
+	reATXHeadingMarker   = regexp.MustCompile(`^#{1,6}(?:[ \t]+|$)`)
+	reATXClosingEmpty    = regexp.MustCompile(`^[ \t]*#+[ \t]*$`)
+	reATXClosingSequence = regexp.MustCompile(`[ \t]+#+[ \t]*$`)
+	reCodeFence          = regexp.MustCompile("^(?:`{3,}|~{3,})")
+	reClosingCodeFence   = regexp.MustCompile("^(?:`{3,}|~{3,})[ \t]*$")
+	reSetextHeadingLine  = regexp.MustCompile(`^(?:=+|-+)[ \t]*$`)
+	reThematicBreak      = regexp.MustCompile(`^(?:(?:\*[ \t]*){3,}|(?:_[ \t]*){3,}|(?:-[ \t]*){3,})$`)
+	reBulletListMarker   = regexp.MustCompile(`^[*+-]`)
+	reOrderedListMarker  = regexp.MustCompile(`^(\d{1,9})([.)])`)
+	reTableDelimiterRow  = regexp.MustCompile(`^\|?[ \t]*:?-+:?[ \t]*(?:\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	reTaskListMarker     = regexp.MustCompile(`^\[([ xX])\](?:[ \t]+|$)`)
+
+	reHTMLBlockOpen = []*regexp.Regexp{
+		nil,
+		regexp.MustCompile(`(?i)^<(?:script|pre|textarea|style)(?:\s|>|$)`),
+		regexp.MustCompile(`^<!--`),
+		regexp.MustCompile(`^<[?]`),
+		regexp.MustCompile(`^<![A-Za-z]`),
+		regexp.MustCompile(`^<!\[CDATA\[`),
+		regexp.MustCompile(`(?i)^</?(?:address|article|aside|base|basefont|blockquote|body|caption|center|col|colgroup|dd|details|dialog|dir|div|dl|dt|fieldset|figcaption|figure|footer|form|frame|frameset|h[123456]|head|header|hr|html|iframe|legend|li|link|main|menu|menuitem|nav|noframes|ol|optgroup|option|p|param|search|section|summary|table|tbody|td|tfoot|th|thead|title|tr|track|ul)(?:\s|/?>|$)`),
+		regexp.MustCompile(`(?i)^(?:` + mdOpenTag + `|` + mdCloseTag + `)\s*$`),
+	}
+	reHTMLBlockClose = []*regexp.Regexp{
+		nil,
+		regexp.MustCompile(`(?i)</(?:script|pre|textarea|style)>`),
+		regexp.MustCompile(`-->`),
+		regexp.MustCompile(`\?>`),
+		regexp.MustCompile(`>`),
+		regexp.MustCompile(`\]\]>`),
+	}
+)
+
+// mdBlockParser 块级解析器，按 CommonMark 规范逐行构建语法树
+type mdBlockParser struct {
+	doc    *mdNode
+	tip    *mdNode
+	oldtip *mdNode
+	refmap map[string]*mdLinkRef
+
+	line                 string
+	lineNumber           int
+	offset               int
+	column               int
+	nextNonspace         int
+	nextNonspaceColumn   int
+	indent               int
+	indented             bool
+	blank                bool
+	partiallyConsumedTab bool
+	allClosed            bool
+	lastMatchedContainer *mdNode
+}
+
+// parseMarkdown 解析 Markdown 文本，返回完成行内解析的语法树
+func parseMarkdown(text string) *mdNode {
+	p := &mdBlockParser{refmap: make(map[string]*mdLinkRef)}
+	p.doc = newMdNode(mdDocument)
+	p.tip = p.doc
+	p.oldtip = p.doc
+	p.lastMatchedContainer = p.doc
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	text = strings.ReplaceAll(text, "\x00", "�")
+	lines := strings.Split(text, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	for _, line := range lines {
+		p.incorporateLine(line)
+	}
+	for p.tip != nil {
+		p.finalize(p.tip, len(lines))
+	}
+
+	markTaskListItems(p.doc)
+	newMdInlineParser(p.refmap).processBlocks(p.doc)
+	return p.doc
+}
+
+func (p *mdBlockParser) peek(pos int) int {
+	if pos < len(p.line) {
+		return int(p.line[pos])
+	}
+	return -1
+}
+
+func isSpaceOrTab(c int) bool {
+	return c == ' ' || c == '\t'
+}
+
+func (p *mdBlockParser) findNextNonspace() {
+	i := p.offset
+	cols := p.column
+	for i < len(p.line) {
+		c := p.line[i]
+		if c == ' ' {
+			i++
+			cols++
+		} else if c == '\t' {
+			i++
+			cols += 4 - cols%4
+		} else {
+			break
+		}
+	}
+	p.blank = i >= len(p.line)
+	p.nextNonspace = i
+	p.nextNonspaceColumn = cols
+	p.indent = p.nextNonspaceColumn - p.column
+	p.indented = p.indent >= 4
+}
+
+func (p *mdBlockParser) advanceNextNonspace() {
+	p.offset = p.nextNonspace
+	p.column = p.nextNonspaceColumn
+	p.partiallyConsumedTab = false
+}
+
+// advanceOffset 前进 count 个字符（columns 为 true 时按列计算，制表符可被部分消费）
+func (p *mdBlockParser) advanceOffset(count int, columns bool) {
+	for count > 0 && p.offset < len(p.line) {
+		if p.line[p.offset] == '\t' {
+			charsToTab := 4 - p.column%4
+			if columns {
+				p.partiallyConsumedTab = charsToTab > count
+				charsToAdvance := min(charsToTab, count)
+				p.column += charsToAdvance
+				if !p.partiallyConsumedTab {
+					p.offset++
+				}
+				count -= charsToAdvance
+			} else {
+				p.partiallyConsumedTab = false
+				p.column += charsToTab
+				p.offset++
+				count--
+			}
+		} else {
+			p.partiallyConsumedTab = false
+			p.offset++
+			p.column++
+			count--
+		}
+	}
+}
+
+func (p *mdBlockParser) addLine() {
+	if p.partiallyConsumedTab {
+		p.offset++
+		charsToTab := 4 - p.column%4
+		p.tip.content = append(p.tip.content, strings.Repeat(" ", charsToTab)...)
+	}
+	if p.offset < len(p.line) {
+		p.tip.content = append(p.tip.content, p.line[p.offset:]...)
+	}
+	p.tip.content = append(p.tip.content, '\n')
+}
+
+func canContain(parent, child mdNodeType) bool {
+	switch parent {
+	case mdDocument, mdBlockquote, mdItem:
+		return child != mdItem
+	case mdList:
+		return child == mdItem
+	}
+	return false
+}
+
+func acceptsLines(t mdNodeType) bool {
+	return t == mdParagraph || t == mdCodeBlock || t == mdHTMLBlock || t == mdTable
+}
+
+func (p *mdBlockParser) addChild(typ mdNodeType) *mdNode {
+	for !canContain(p.tip.typ, typ) {
+		p.finalize(p.tip, p.lineNumber-1)
+	}
+	node := newMdNode(typ)
+	node.startLine = p.lineNumber
+	p.tip.appendChild(node)
+	p.tip = node
+	return node
+}
+
+func (p *mdBlockParser) closeUnmatchedBlocks() {
+	if p.allClosed {
+		return
+	}
+	for p.oldtip != p.lastMatchedContainer {
+		parent := p.oldtip.parent
+		p.finalize(p.oldtip, p.lineNumber-1)
+		p.oldtip = parent
+	}
+	p.allClosed = true
+}
+
+// continueBlock 判断当前行能否延续已打开的块：0 延续，1 不能延续，2 当前行已被完全消费
+func (p *mdBlockParser) continueBlock(container *mdNode) int {
+	switch container.typ {
+	case mdDocument, mdList:
+		return 0
+	case mdBlockquote:
+		if !p.indented && p.peek(p.nextNonspace) == '>' {
+			p.advanceNextNonspace()
+			p.advanceOffset(1, false)
+			if isSpaceOrTab(p.peek(p.offset)) {
+				p.advanceOffset(1, true)
+			}
+			return 0
+		}
+		return 1
+	case mdItem:
+		if p.blank {
+			if container.firstChild == nil {
+				// 空列表项后的空行结束该列表项
+				return 1
+			}
+			p.advanceNextNonspace()
+		} else if p.indent >= container.list.markerOffset+container.list.padding {
+			p.advanceOffset(container.list.markerOffset+container.list.padding, true)
+		} else {
+			return 1
+		}
+		return 0
+	case mdHeading, mdThematicBreak:
+		return 1
+	case mdCodeBlock:
+		if container.fenced {
+			rest := p.line[p.nextNonspace:]
+			if p.indent <= 3 && len(rest) > 0 && rest[0] == container.fenceChar {
+				if m := reClosingCodeFence.FindString(rest); m != "" && len(strings.TrimRight(m, " \t")) >= container.fenceLength {
+					p.finalize(container, p.lineNumber)
+					return 2
+				}
+			}
+			// 跳过与起始围栏相同的缩进
+			for i := container.fenceOffset; i > 0 && isSpaceOrTab(p.peek(p.offset)); i-- {
+				p.advanceOffset(1, true)
+			}
+		} else {
+			if p.indent >= 4 {
+				p.advanceOffset(4, true)
+			} else if p.blank {
+				p.advanceNextNonspace()
+			} else {
+				return 1
+			}
+		}
+		return 0
+	case mdHTMLBlock:
+		if p.blank && (container.htmlBlockType == 6 || container.htmlBlockType == 7) {
+			return 1
+		}
+		return 0
+	case mdParagraph:
+		if p.blank {
+			return 1
+		}
+		return 0
+	case mdTable:
+		// 表格在空行或其他块级结构开始处结束
+		if p.blank {
+			return 1
+		}
+		if !p.indented {
+			rest := p.line[p.nextNonspace:]
+			if strings.HasPrefix(rest, ">") || reATXHeadingMarker.MatchString(rest) ||
+				reCodeFence.MatchString(rest) || reThematicBreak.MatchString(rest) {
+				return 1
+			}
+		}
+		return 0
+	}
+	return 0
+}
+
+// tryBlockStart 尝试在当前位置开始新块：0 不匹配，1 开始了容器块，2 开始了叶子块
+func (p *mdBlockParser) tryBlockStart(container *mdNode) int {
+	rest := p.line[p.nextNonspace:]
+
+	// 引用
+	if !p.indented && p.peek(p.nextNonspace) == '>' {
+		p.advanceNextNonspace()
+		p.advanceOffset(1, false)
+		if isSpaceOrTab(p.peek(p.offset)) {
+			p.advanceOffset(1, true)
+		}
+		p.closeUnmatchedBlocks()
+		p.addChild(mdBlockquote)
+		return 1
+	}
+
+	// ATX 标题
+	if !p.indented {
+		if m := reATXHeadingMarker.FindString(rest); m != "" {
+			p.advanceNextNonspace()
+			p.advanceOffset(len(m), false)
+			p.closeUnmatchedBlocks()
+			heading := p.addChild(mdHeading)
+			heading.level = len(strings.TrimSpace(m))
+			text := p.line[p.offset:]
+			text = reATXClosingEmpty.ReplaceAllString(text, "")
+			text = reATXClosingSequence.ReplaceAllString(text, "")
+			heading.content = []byte(text)
+			p.advanceOffset(len(p.line)-p.offset, false)
+			return 2
+		}
+	}
+
+	// 围栏代码块
+	if !p.indented {
+		if m := reCodeFence.FindString(rest); m != "" && !(m[0] == '`' && strings.Contains(rest[len(m):], "`")) {
+			p.closeUnmatchedBlocks()
+			code := p.addChild(mdCodeBlock)
+			code.fenced = true
+			code.fenceLength = len(m)
+			code.fenceChar = m[0]
+			code.fenceOffset = p.indent
+			p.advanceNextNonspace()
+			p.advanceOffset(len(m), false)
+			return 2
+		}
+	}
+
+	// HTML 块
+	if !p.indented && p.peek(p.nextNonspace) == '<' {
+		for blockType := 1; blockType <= 7; blockType++ {
+			if reHTMLBlockOpen[blockType].MatchString(rest) &&
+				(blockType < 7 || (container.typ != mdParagraph && !(!p.allClosed && !p.blank && p.tip.typ == mdParagraph))) {
+				p.closeUnmatchedBlocks()
+				block := p.addChild(mdHTMLBlock)
+				block.htmlBlockType = blockType
+				return 2
+			}
+		}
+	}
+
+	// GFM 表格：段落最后一行为表头，当前行为分隔行
+	if !p.indented && container.typ == mdParagraph && strings.Contains(rest, "|") && reTableDelimiterRow.MatchString(rest) {
+		if p.startTable(container, rest) {
+			return 2
+		}
+	}
+
+	// Setext 标题
+	if !p.indented && container.typ == mdParagraph {
+		if m := reSetextHeadingLine.FindString(rest); m != "" {
+			p.closeUnmatchedBlocks()
+			content := p.consumeReferences(string(container.content))
+			if content != "" {
+				heading := newMdNode(mdHeading)
+				heading.startLine = container.startLine
+				if m[0] == '=' {
+					heading.level = 1
+				} else {
+					heading.level = 2
+				}
+				heading.content = []byte(content)
+				container.insertAfter(heading)
+				container.unlink()
+				p.tip = heading
+				p.advanceOffset(len(p.line)-p.offset, false)
+				return 2
+			}
+			container.content = nil
+		}
+	}
+
+	// 分隔线
+	if !p.indented && reThematicBreak.MatchString(rest) {
+		p.closeUnmatchedBlocks()
+		p.addChild(mdThematicBreak)
+		p.advanceOffset(len(p.line)-p.offset, false)
+		return 2
+	}
+
+	// 列表项
+	if !p.indented || container.typ == mdList {
+		if data := p.parseListMarker(container); data != nil {
+			p.closeUnmatchedBlocks()
+			if p.tip.typ != mdList || !listsMatch(container.list, data) {
+				list := p.addChild(mdList)
+				list.list = data
+			}
+			item := p.addChild(mdItem)
+			item.list = data
+			return 1
+		}
+	}
+
+	// 缩进代码块
+	if p.indented && p.tip.typ != mdParagraph && !p.blank {
+		p.advanceOffset(4, true)
+		p.closeUnmatchedBlocks()
+		p.addChild(mdCodeBlock)
+		return 2
+	}
+
+	return 0
+}
+
+// startTable 将段落最后一行转换为表头并开始表格，列数不一致时不构成表格
+func (p *mdBlockParser) startTable(paragraph *mdNode, delimiterRow string) bool {
+	lines := strings.Split(strings.TrimRight(string(paragraph.content), "\n"), "\n")
+	headerLine := lines[len(lines)-1]
+	header := splitTableRow(headerLine)
+	aligns := splitTableRow(delimiterRow)
+	if len(header) != len(aligns) {
+		return false
+	}
+
+	p.closeUnmatchedBlocks()
+	table := newMdNode(mdTable)
+	table.startLine = p.lineNumber - 1
+	for _, cell := range aligns {
+		left := strings.HasPrefix(cell, ":")
+		right := strings.HasSuffix(cell, ":")
+		switch {
+		case left && right:
+			table.align = append(table.align, "center")
+		case left:
+			table.align = append(table.align, "left")
+		case right:
+			table.align = append(table.align, "right")
+		default:
+			table.align = append(table.align, "")
+		}
+	}
+	table.rows = append(table.rows, header)
+
+	if len(lines) > 1 {
+		// 表头之前的行保留为段落
+		paragraph.content = []byte(strings.Join(lines[:len(lines)-1], "\n") + "\n")
+		paragraph.insertAfter(table)
+		p.finalize(paragraph, p.lineNumber-1)
+	} else {
+		paragraph.insertAfter(table)
+		paragraph.unlink()
+	}
+	p.tip = table
+	p.advanceOffset(len(p.line)-p.offset, false)
+	return true
+}
+
+// splitTableRow 拆分表格行，忽略首尾的竖线，支持 \| 转义
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if c == '\\' && i+1 < len(line) && line[i+1] == '|' {
+			cell.WriteByte('|')
+			i++
+			continue
+		}
+		if c == '|' {
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+			continue
+		}
+		cell.WriteByte(c)
+	}
+	cells = append(cells, strings.TrimSpace(cell.String()))
+	return cells
+}
+
+func (p *mdBlockParser) parseListMarker(container *mdNode) *mdListData {
+	if p.indent >= 4 {
+		return nil
+	}
+	rest := p.line[p.nextNonspace:]
+	data := &mdListData{markerOffset: p.indent}
+
+	var markerLen int
+	if m := reBulletListMarker.FindString(rest); m != "" {
+		data.bulletChar = m[0]
+		markerLen = len(m)
+	} else if m := reOrderedListMarker.FindStringSubmatch(rest); m != nil && (container.typ != mdParagraph || m[1] == "1") {
+		data.ordered = true
+		data.start, _ = strconv.Atoi(m[1])
+		data.delimiter = m[2][0]
+		markerLen = len(m[0])
+	} else {
+		return nil
+	}
+
+	// 标记后必须是空白或行尾
+	nextc := p.peek(p.nextNonspace + markerLen)
+	if !(nextc == -1 || nextc == '\t' || nextc == ' ') {
+		return nil
+	}
+	// 打断段落时列表项不能为空
+	if container.typ == mdParagraph && strings.TrimSpace(p.line[p.nextNonspace+markerLen:]) == "" {
+		return nil
+	}
+
+	p.advanceNextNonspace()
+	p.advanceOffset(markerLen, true)
+	spacesStartCol := p.column
+	spacesStartOffset := p.offset
+	for {
+		p.advanceOffset(1, true)
+		nextc = p.peek(p.offset)
+		if !(p.column-spacesStartCol < 5 && isSpaceOrTab(nextc)) {
+			break
+		}
+	}
+	blankItem := p.peek(p.offset) == -1
+	spacesAfterMarker := p.column - spacesStartCol
+	if spacesAfterMarker >= 5 || spacesAfterMarker < 1 || blankItem {
+		data.padding = markerLen + 1
+		p.column = spacesStartCol
+		p.offset = spacesStartOffset
+		if isSpaceOrTab(p.peek(p.offset)) {
+			p.advanceOffset(1, true)
+		}
+	} else {
+		data.padding = markerLen + spacesAfterMarker
+	}
+	return data
+}
+
+func listsMatch(a, b *mdListData) bool {
+	return a.ordered == b.ordered && a.delimiter == b.delimiter && a.bulletChar == b.bulletChar
+}
+
+// incorporateLine 处理一行输入
+func (p *mdBlockParser) incorporateLine(line string) {
+	container := p.doc
+	p.oldtip = p.tip
+	p.offset = 0
+	p.column = 0
+	p.blank = false
+	p.partiallyConsumedTab = false
+	p.lineNumber++
+	p.line = line
+
+	// 依次检查已打开的块能否延续
+	allMatched := true
+	for container.lastChild != nil && container.lastChild.open {
+		container = container.lastChild
+		p.findNextNonspace()
+		switch p.continueBlock(container) {
+		case 0:
+			continue
+		case 1:
+			allMatched = false
+		case 2:
+			return
+		}
+		break
+	}
+	if !allMatched {
+		container = container.parent
+	}
+
+	p.allClosed = container == p.oldtip
+	p.lastMatchedContainer = container
+
+	matchedLeaf := container.typ != mdParagraph && acceptsLines(container.typ)
+
+	// 尝试开始新块
+	for !matchedLeaf {
+		p.findNextNonspace()
+		if !p.indented && !strings.ContainsRune("#`~*+_=<>-0123456789|", rune(safeByte(p.line, p.nextNonspace))) {
+			p.advanceNextNonspace()
+			break
+		}
+		res := p.tryBlockStart(container)
+		if res == 0 {
+			p.advanceNextNonspace()
+			break
+		}
+		container = p.tip
+		if res == 2 {
+			matchedLeaf = true
+		}
+	}
+
+	// 段落的惰性延续行
+	if !p.allClosed && !p.blank && p.tip.typ == mdParagraph {
+		p.addLine()
+		return
+	}
+
+	p.closeUnmatchedBlocks()
+	if p.blank && container.lastChild != nil {
+		container.lastChild.lastLineBlank = true
+	}
+
+	t := container.typ
+	lastLineBlank := p.blank &&
+		!(t == mdBlockquote || (t == mdCodeBlock && container.fenced) ||
+			(t == mdItem && container.firstChild == nil && container.startLine == p.lineNumber))
+	for cont := container; cont != nil; cont = cont.parent {
+		cont.lastLineBlank = lastLineBlank
+	}
+
+	switch {
+	case t == mdTable:
+		// 分隔行在开始表格时已被消费
+		if !p.blank && p.offset < len(p.line) {
+			p.addTableRow(container)
+		}
+	case acceptsLines(t):
+		p.addLine()
+		if t == mdHTMLBlock && container.htmlBlockType >= 1 && container.htmlBlockType <= 5 &&
+			reHTMLBlockClose[container.htmlBlockType].MatchString(p.line[min(p.offset, len(p.line)):]) {
+			p.finalize(container, p.lineNumber)
+		}
+	case p.offset < len(p.line) && !p.blank:
+		p.addChild(mdParagraph)
+		p.advanceNextNonspace()
+		p.addLine()
+	}
+}
+
+// addTableRow 添加表格数据行，单元格数量按表头补齐或截断
+func (p *mdBlockParser) addTableRow(table *mdNode) {
+	cells := splitTableRow(p.line[p.offset:])
+	columns := len(table.align)
+	if len(cells) > columns {
+		cells = cells[:columns]
+	}
+	for len(cells) < columns {
+		cells = append(cells, "")
+	}
+	table.rows = append(table.rows, cells)
+}
+
+func safeByte(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return 0
+}
+
+// finalize 关闭块并做收尾处理
+func (p *mdBlockParser) finalize(block *mdNode, lineNumber int) {
+	above := block.parent
+	block.open = false
+
+	switch block.typ {
+	case mdParagraph:
+		content := p.consumeReferences(string(block.content))
+		block.content = []byte(content)
+		if strings.TrimSpace(content) == "" {
+			block.unlink()
+		}
+	case mdCodeBlock:
+		content := string(block.content)
+		if block.fenced {
+			firstLine, rest, _ := strings.Cut(content, "\n")
+			block.info = unescapeMarkdownString(strings.TrimSpace(firstLine))
+			block.literal = rest
+		} else {
+			lines := strings.Split(content, "\n")
+			end := len(lines)
+			for end > 0 && strings.TrimSpace(lines[end-1]) == "" {
+				end--
+			}
+			if end > 0 {
+				block.literal = strings.Join(lines[:end], "\n") + "\n"
+			}
+		}
+		block.content = nil
+	case mdHTMLBlock:
+		block.literal = strings.TrimRight(string(block.content), " \n")
+		block.content = nil
+	case mdList:
+		block.tight = true
+		for item := block.firstChild; item != nil; item = item.next {
+			if item.next != nil && endsWithBlankLine(item) {
+				block.tight = false
+				break
+			}
+			for sub := item.firstChild; sub != nil; sub = sub.next {
+				if endsWithBlankLine(sub) && (item.next != nil || sub.next != nil) {
+					block.tight = false
+					break
+				}
+			}
+			if !block.tight {
+				break
+			}
+		}
+	}
+
+	p.tip = above
+}
+
+// consumeReferences 解析并移除段落开头的链接引用定义
+func (p *mdBlockParser) consumeReferences(content string) string {
+	for strings.HasPrefix(content, "[") {
+		n := newMdInlineParser(p.refmap).parseReference(content)
+		if n == 0 {
+			break
+		}
+		content = content[n:]
+	}
+	return content
+}
+
+func endsWithBlankLine(block *mdNode) bool {
+	for block != nil {
+		if block.lastLineBlank {
+			return true
+		}
+		if block.typ == mdList || block.typ == mdItem {
+			block = block.lastChild
+			continue
+		}
+		break
+	}
+	return false
+}
+
+// markTaskListItems 识别 GFM 任务列表项（以 [ ] 或 [x] 开头的列表项）
+func markTaskListItems(node *mdNode) {
+	for child := node.firstChild; child != nil; child = child.next {
+		if child.typ == mdItem && child.firstChild != nil && child.firstChild.typ == mdParagraph {
+			para := child.firstChild
+			if m := reTaskListMarker.Find(para.content); m != nil {
+				checked := m[1] != ' '
+				child.checked = &checked
+				para.content = para.content[len(m):]
+			}
+		}
+		markTaskListItems(child)
+	}
+}
@@ -0,0 +1,219 @@
+package tools
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"01agent_server/internal/config"
+	"01agent_server/internal/repository"
+)
+
+// Markdown 渲染缓存的默认容量与有效期
+const (
+	defaultThemeConfigCacheEntries = 500
+	defaultThemeConfigCacheTTL     = 60 * time.Second
+	defaultRenderCacheEntries      = 1000
+	defaultRenderCacheMaxBytes     = 64 << 20
+	defaultRenderCacheTTL          = 30 * time.Minute
+	defaultRenderRedisTTL          = 24 * time.Hour
+)
+
+// MarkdownRenderRedisPrefix Redis 二级缓存中渲染结果的键前缀
+const MarkdownRenderRedisPrefix = "markdown:render:"
+
+// configCacheEntry 主题配置缓存条目
+// fingerprint 为模板数据的摘要，渲染结果的缓存键包含它，模板更新后旧的渲染结果自然失效
+type configCacheEntry struct {
+	config       map[string]interface{}
+	fingerprint  string
+	templateType string
+}
+
+// markdownRenderResult 渲染结果，公众号模式下附带降级报告
+type markdownRenderResult struct {
+	HTML   string              `json:"html"`
+	Report *WechatRenderReport `json:"report,omitempty"`
+}
+
+// size 估算结果占用的字节数
+func (r *markdownRenderResult) size() int64 {
+	size := int64(len(r.HTML))
+	if r.Report != nil {
+		for _, d := range r.Report.Downgrades {
+			size += int64(len(d.Construct) + len(d.Action) + 16)
+			for _, s := range d.Samples {
+				size += int64(len(s))
+			}
+		}
+	}
+	return size
+}
+
+// markdownCaches 主题配置与渲染结果的缓存
+type markdownCaches struct {
+	configs      *LRUCache
+	renders      *LRUCache
+	configFlight singleFlight
+	renderFlight singleFlight
+
+	redisEnabled bool
+	redisTTL     time.Duration
+	redisHits    uint64
+	redisMisses  uint64
+	redisErrors  uint64
+}
+
+var (
+	mdCaches     *markdownCaches
+	mdCachesOnce sync.Once
+)
+
+// getMarkdownCaches 首次使用时按配置创建缓存
+func getMarkdownCaches() *markdownCaches {
+	mdCachesOnce.Do(func() {
+		var cfg config.MarkdownCacheConfig
+		if config.AppConfig != nil {
+			cfg = config.AppConfig.MarkdownCache
+		}
+
+		configEntries := defaultThemeConfigCacheEntries
+		if cfg.ConfigEntries > 0 {
+			configEntries = cfg.ConfigEntries
+		}
+		configTTL := defaultThemeConfigCacheTTL
+		if cfg.ConfigTTL > 0 {
+			configTTL = time.Duration(cfg.ConfigTTL) * time.Second
+		}
+		renderEntries := defaultRenderCacheEntries
+		if cfg.RenderEntries > 0 {
+			renderEntries = cfg.RenderEntries
+		}
+		renderMaxBytes := int64(defaultRenderCacheMaxBytes)
+		if cfg.RenderMaxBytes > 0 {
+			renderMaxBytes = cfg.RenderMaxBytes
+		}
+		renderTTL := defaultRenderCacheTTL
+		if cfg.RenderTTL > 0 {
+			renderTTL = time.Duration(cfg.RenderTTL) * time.Second
+		}
+		redisTTL := defaultRenderRedisTTL
+		if cfg.RedisTTL > 0 {
+			redisTTL = time.Duration(cfg.RedisTTL) * time.Second
+		}
+
+		mdCaches = &markdownCaches{
+			configs:      NewLRUCache("theme_config", configEntries, 0, configTTL),
+			renders:      NewLRUCache("markdown_render", renderEntries, renderMaxBytes, renderTTL),
+			redisEnabled: cfg.RedisEnabled,
+			redisTTL:     redisTTL,
+		}
+	})
+	return mdCaches
+}
+
+// renderCacheKey 渲染结果的缓存键：主题、模式、配置指纹与内容摘要
+func renderCacheKey(configName, mode, fingerprint, markdownText string) string {
+	return fmt.Sprintf("%s:%s:%s:%x", configName, mode, fingerprint, md5.Sum([]byte(markdownText)))
+}
+
+// getRedis 从 Redis 二级缓存读取渲染结果，未启用或读取失败时返回 nil
+func (c *markdownCaches) getRedis(key string) *markdownRenderResult {
+	if !c.redisEnabled {
+		return nil
+	}
+	value, err := GetRedisInstance().Get(MarkdownRenderRedisPrefix+key, redisDB())
+	if err != nil {
+		atomic.AddUint64(&c.redisErrors, 1)
+		return nil
+	}
+	if value == "" {
+		atomic.AddUint64(&c.redisMisses, 1)
+		return nil
+	}
+
+	var result markdownRenderResult
+	if err := json.Unmarshal([]byte(value), &result); err != nil {
+		atomic.AddUint64(&c.redisErrors, 1)
+		return nil
+	}
+	atomic.AddUint64(&c.redisHits, 1)
+	return &result
+}
+
+// setRedis 写入 Redis 二级缓存，失败只记录日志
+func (c *markdownCaches) setRedis(key string, result *markdownRenderResult) {
+	if !c.redisEnabled {
+		return
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+	if err := GetRedisInstance().Set(MarkdownRenderRedisPrefix+key, string(data), int(c.redisTTL/time.Second), redisDB()); err != nil {
+		atomic.AddUint64(&c.redisErrors, 1)
+		repository.Warnf("写入Markdown渲染缓存失败: %v", err)
+	}
+}
+
+func redisDB() int {
+	if config.AppConfig == nil {
+		return 0
+	}
+	return config.AppConfig.Redis.DB
+}
+
+// InvalidateThemeCache 模板保存或删除后清除本实例中该主题的配置与渲染结果
+// 其他实例在主题配置缓存过期后读到新配置，Redis 中的渲染结果按配置指纹区分，不会读到旧结果
+func InvalidateThemeCache(configName string) {
+	caches := getMarkdownCaches()
+	caches.configs.Delete(configName)
+	caches.renders.DeletePrefix(configName + ":")
+}
+
+// ClearMarkdownCache 清空本实例的主题配置与渲染结果缓存，返回清除的条目数
+func ClearMarkdownCache() (configs int, renders int) {
+	caches := getMarkdownCaches()
+	return caches.configs.Purge(), caches.renders.Purge()
+}
+
+// MarkdownRedisCacheStats Redis 二级缓存统计
+type MarkdownRedisCacheStats struct {
+	Enabled    bool   `json:"enabled"`
+	KeyPrefix  string `json:"key_prefix"`
+	TTLSeconds int64  `json:"ttl_seconds"`
+	Hits       uint64 `json:"hits"`
+	Misses     uint64 `json:"misses"`
+	Errors     uint64 `json:"errors"`
+}
+
+// MarkdownCacheStats Markdown 处理器的缓存统计
+type MarkdownCacheStats struct {
+	Config        LRUCacheStats           `json:"config"`
+	Render        LRUCacheStats           `json:"render"`
+	Redis         MarkdownRedisCacheStats `json:"redis"`
+	SharedLoads   uint64                  `json:"shared_loads"`   // 并发加载同一主题时被合并的次数
+	SharedRenders uint64                  `json:"shared_renders"` // 并发渲染同一内容时被合并的次数
+}
+
+// GetMarkdownCacheStats 返回本实例的缓存统计
+func GetMarkdownCacheStats() *MarkdownCacheStats {
+	caches := getMarkdownCaches()
+	return &MarkdownCacheStats{
+		Config: caches.configs.Stats(),
+		Render: caches.renders.Stats(),
+		Redis: MarkdownRedisCacheStats{
+			Enabled:    caches.redisEnabled,
+			KeyPrefix:  MarkdownRenderRedisPrefix,
+			TTLSeconds: int64(caches.redisTTL / time.Second),
+			Hits:       atomic.LoadUint64(&caches.redisHits),
+			Misses:     atomic.LoadUint64(&caches.redisMisses),
+			Errors:     atomic.LoadUint64(&caches.redisErrors),
+		},
+		SharedLoads:   caches.configFlight.Shared(),
+		SharedRenders: caches.renderFlight.Shared(),
+	}
+}
@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"strconv"
+)
+
+// defaultReferencesTitle 参考资料区块的默认标题
+const defaultReferencesTitle = "参考资料"
+
+// mdLinkFootnoteRule 外部链接转脚注的配置，对应统一配置中的 rules.link：
+//
+//	"rules": {"link": {"footnote": true, "references_title": "参考资料"}}
+//
+// 开启后公众号文章链接保持可点击，其他链接改为文字加上标编号，并在文末附上参考资料
+type mdLinkFootnoteRule struct {
+	referencesTitle string
+}
+
+// parseLinkFootnoteRule 从统一配置中读取链接脚注规则，未开启时返回 nil
+func parseLinkFootnoteRule(unifiedConfig map[string]interface{}) *mdLinkFootnoteRule {
+	rules, _ := unifiedConfig["rules"].(map[string]interface{})
+	rule, _ := rules["link"].(map[string]interface{})
+	if enabled, _ := rule["footnote"].(bool); !enabled {
+		return nil
+	}
+
+	title, _ := rule["references_title"].(string)
+	if title == "" {
+		title = defaultReferencesTitle
+	}
+	return &mdLinkFootnoteRule{referencesTitle: title}
+}
+
+// mdFootnote 参考资料条目
+type mdFootnote struct {
+	text string
+	url  string
+}
+
+// mdFootnotes 渲染过程中收集的脚注，同一地址只编号一次
+type mdFootnotes struct {
+	rule  *mdLinkFootnoteRule
+	items []mdFootnote
+	index map[string]int
+}
+
+func newMdFootnotes(rule *mdLinkFootnoteRule) *mdFootnotes {
+	if rule == nil {
+		return nil
+	}
+	return &mdFootnotes{rule: rule, index: make(map[string]int)}
+}
+
+// number 返回链接的脚注编号
+func (f *mdFootnotes) number(text, url string) int {
+	if n, ok := f.index[url]; ok {
+		return n
+	}
+	f.items = append(f.items, mdFootnote{text: text, url: url})
+	n := len(f.items)
+	f.index[url] = n
+	return n
+}
+
+// renderFootnoteLink 将外部链接渲染为文字与上标编号
+// 链接文字本身就是地址时不再编号
+func (r *mdHTMLRenderer) renderFootnoteLink(node *mdNode, dest string) {
+	r.openTag("span", r.inlineStyle("link", "a"))
+	r.renderInlines(node)
+	r.buf.WriteString("</span>")
+
+	text := plainText(node)
+	if text == dest || "mailto:"+text == dest {
+		if r.wechat != nil {
+			r.wechat.add(WechatConstructExternalLink, "外部链接改写为文本", dest)
+		}
+		return
+	}
+	if r.wechat != nil {
+		r.wechat.add(WechatConstructExternalLink, "外部链接改写为脚注", dest)
+	}
+	n := r.footnotes.number(text, dest)
+	r.openTag("sup", r.inlineStyle("footnote", "sup"))
+	r.buf.WriteString("[" + strconv.Itoa(n) + "]")
+	r.buf.WriteString("</sup>")
+}
+
+// renderReferences 在文末输出参考资料区块
+func (r *mdHTMLRenderer) renderReferences() {
+	if r.footnotes == nil || len(r.footnotes.items) == 0 {
+		return
+	}
+
+	r.openTag("section", r.textStyle("footnotes"))
+	r.buf.WriteString("\n")
+	r.openTag("p", r.textStyle("footnotes_title", "h4"))
+	r.buf.WriteString(escapeHTML(r.footnotes.rule.referencesTitle))
+	r.buf.WriteString("</p>\n")
+	for i, item := range r.footnotes.items {
+		r.openTag("p", r.blockStyle("footnote_item"))
+		r.buf.WriteString("[" + strconv.Itoa(i+1) + "] ")
+		if item.text != "" {
+			r.buf.WriteString(escapeHTML(item.text) + ": ")
+		}
+		r.openTag("em", r.inlineStyle("footnote_url"))
+		r.buf.WriteString(escapeHTML(item.url))
+		r.buf.WriteString("</em></p>\n")
+	}
+	r.buf.WriteString("</section>\n")
+}
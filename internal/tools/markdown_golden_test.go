@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "重新生成 testdata 下的 golden 文件")
+
+// TestMarkdownGolden 渲染 testdata/markdown/*.md 并与同名 .html 对比
+// 存在同名 .json 时作为主题配置，否则使用空配置，只检查结构；
+// 修改渲染逻辑后使用 go test -run TestMarkdownGolden -update 重新生成
+func TestMarkdownGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "markdown", "*.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no golden cases found")
+	}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(file, ".md")
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			input, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config := map[string]interface{}{}
+			if raw, err := os.ReadFile(name + ".json"); err == nil {
+				if err := json.Unmarshal(raw, &config); err != nil {
+					t.Fatalf("invalid theme config: %v", err)
+				}
+			}
+
+			got := NewUnifiedMarkdownProcessor().processWithUnifiedConfig(string(input), config, nil) + "\n"
+			golden := name + ".html"
+			if *updateGolden {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("missing golden file, run with -update: %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("output mismatch for %s\n--- want\n%s\n--- got\n%s", file, want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,964 @@
+package tools
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	mdTagName        = `[A-Za-z][A-Za-z0-9-]*`
+	mdAttributeName  = `[a-zA-Z_:][a-zA-Z0-9:._-]*`
+	mdAttributeValue = `(?:[^"'=<>` + "`" + `\x00-\x20]+|'[^']*'|"[^"]*")`
+	mdAttribute      = `(?:\s+` + mdAttributeName + `(?:\s*=\s*` + mdAttributeValue + `)?)`
+	mdOpenTag        = `<` + mdTagName + mdAttribute + `*\s*/?>`
+	mdCloseTag       = `</` + mdTagName + `\s*>`
+	mdHTMLComment    = `<!-->|<!--->|<!--[\s\S]*?-->`
+	mdProcessing     = `<[?][\s\S]*?[?]>`
+	mdDeclaration    = `<![A-Za-z]+[^>]*>`
+	mdCDATA          = `<!\[CDATA\[[\s\S]*?\]\]>`
+	mdEscapable      = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+)
+
+var (
+	reHTMLTag          = regexp.MustCompile(`^(?:` + mdOpenTag + `|` + mdCloseTag + `|` + mdHTMLComment + `|` + mdProcessing + `|` + mdDeclaration + `|` + mdCDATA + `)`)
+	reEntity           = regexp.MustCompile(`^&(?:#[xX][a-fA-F0-9]{1,6}|#[0-9]{1,7}|[A-Za-z][A-Za-z0-9]{1,31});`)
+	reEntityOrEscape   = regexp.MustCompile(`\\[!-/:-@\[-` + "`" + `{-~]|&(?:#[xX][a-fA-F0-9]{1,6}|#[0-9]{1,7}|[A-Za-z][A-Za-z0-9]{1,31});`)
+	reMainText         = regexp.MustCompile("^[^\n`\\[\\]\\\\!<&*_~]+")
+	reTicks            = regexp.MustCompile("`+")
+	reLeadingTicks     = regexp.MustCompile("^`+")
+	reSpnl             = regexp.MustCompile(`^ *(?:\n *)?`)
+	reLineEnd          = regexp.MustCompile(`^[ \t]*(?:\n|$)`)
+	reLinkLabel        = regexp.MustCompile(`^\[(?:[^\\\[\]]|\\[\s\S]){0,999}\]`)
+	reLinkTitle        = regexp.MustCompile(`^(?:"(?:\\[\s\S]|[^"\\\x00])*"|'(?:\\[\s\S]|[^'\\\x00])*'|\((?:\\[\s\S]|[^()\\\x00])*\))`)
+	reLinkDestBraces   = regexp.MustCompile(`^<(?:[^<>\n\\\x00]|\\.)*>`)
+	reEmailAutolink    = regexp.MustCompile(`^<([a-zA-Z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*)>`)
+	reURIAutolink      = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9.+-]{1,31}:[^<>\x00-\x20]*)>`)
+	reWhitespaceRun    = regexp.MustCompile(`[ \t\r\n]+`)
+	reExtendedAutolink = regexp.MustCompile(`(?i)(?:(?:https?|ftp)://|www\.)[a-z0-9_-]+(?:\.[a-z0-9_-]+)*[^\s<]*`)
+	reExtendedEmail    = regexp.MustCompile(`[a-zA-Z0-9.+_-]+@[a-zA-Z0-9_-]+(?:\.[a-zA-Z0-9_-]+)+`)
+	reTrailingEntity   = regexp.MustCompile(`&[a-zA-Z0-9]+;$`)
+)
+
+// mdDelimiter 强调符号（* _ ~）栈中的元素
+type mdDelimiter struct {
+	cc         byte
+	numdelims  int
+	origdelims int
+	node       *mdNode
+	previous   *mdDelimiter
+	next       *mdDelimiter
+	canOpen    bool
+	canClose   bool
+}
+
+// mdBracket 链接与图片的左括号栈中的元素
+type mdBracket struct {
+	node              *mdNode
+	previous          *mdBracket
+	previousDelimiter *mdDelimiter
+	index             int
+	image             bool
+	active            bool
+	bracketAfter      bool
+}
+
+// mdInlineParser 行内解析器
+type mdInlineParser struct {
+	subject    string
+	pos        int
+	refmap     map[string]*mdLinkRef
+	delimiters *mdDelimiter
+	brackets   *mdBracket
+}
+
+func newMdInlineParser(refmap map[string]*mdLinkRef) *mdInlineParser {
+	return &mdInlineParser{refmap: refmap}
+}
+
+// processBlocks 对段落、标题与表格单元格做行内解析
+func (p *mdInlineParser) processBlocks(node *mdNode) {
+	for child := node.firstChild; child != nil; child = child.next {
+		switch child.typ {
+		case mdParagraph, mdHeading:
+			p.parse(child, string(child.content))
+			child.content = nil
+		case mdTable:
+			p.buildTable(child)
+		default:
+			p.processBlocks(child)
+		}
+	}
+}
+
+// buildTable 将表格原始单元格转换为行与单元格节点
+func (p *mdInlineParser) buildTable(table *mdNode) {
+	for i, cells := range table.rows {
+		row := newMdNode(mdTableRow)
+		row.header = i == 0
+		for j, text := range cells {
+			cell := newMdNode(mdTableCell)
+			cell.header = i == 0
+			if j < len(table.align) {
+				cell.literal = table.align[j]
+			}
+			p.parse(cell, text)
+			row.appendChild(cell)
+		}
+		table.appendChild(row)
+	}
+	table.rows = nil
+}
+
+// parse 解析 content 并把行内节点追加到 block 下
+func (p *mdInlineParser) parse(block *mdNode, content string) {
+	p.subject = strings.TrimSpace(content)
+	p.pos = 0
+	p.delimiters = nil
+	p.brackets = nil
+	for p.parseInline(block) {
+	}
+	p.processEmphasis(nil)
+	mergeTextNodes(block)
+	applyExtendedAutolinks(block)
+}
+
+func (p *mdInlineParser) peek() int {
+	if p.pos < len(p.subject) {
+		return int(p.subject[p.pos])
+	}
+	return -1
+}
+
+func (p *mdInlineParser) match(re *regexp.Regexp) string {
+	loc := re.FindStringIndex(p.subject[p.pos:])
+	if loc == nil {
+		return ""
+	}
+	m := p.subject[p.pos+loc[0] : p.pos+loc[1]]
+	p.pos += loc[1]
+	return m
+}
+
+func appendText(block *mdNode, s string) *mdNode {
+	node := newMdNode(mdText)
+	node.literal = s
+	block.appendChild(node)
+	return node
+}
+
+func (p *mdInlineParser) parseInline(block *mdNode) bool {
+	c := p.peek()
+	if c == -1 {
+		return false
+	}
+	var res bool
+	switch c {
+	case '\n':
+		res = p.parseNewline(block)
+	case '\\':
+		res = p.parseBackslash(block)
+	case '`':
+		res = p.parseBackticks(block)
+	case '*', '_', '~':
+		res = p.handleDelim(byte(c), block)
+	case '[':
+		res = p.parseOpenBracket(block)
+	case '!':
+		res = p.parseBang(block)
+	case ']':
+		res = p.parseCloseBracket(block)
+	case '<':
+		res = p.parseAutolink(block) || p.parseHTMLTag(block)
+	case '&':
+		res = p.parseEntity(block)
+	default:
+		res = p.parseString(block)
+	}
+	if !res {
+		p.pos++
+		appendText(block, string(rune(c)))
+	}
+	return true
+}
+
+func (p *mdInlineParser) parseString(block *mdNode) bool {
+	m := p.match(reMainText)
+	if m == "" {
+		return false
+	}
+	appendText(block, m)
+	return true
+}
+
+func (p *mdInlineParser) parseNewline(block *mdNode) bool {
+	p.pos++
+	last := block.lastChild
+	if last != nil && last.typ == mdText && strings.HasSuffix(last.literal, " ") {
+		hard := strings.HasSuffix(last.literal, "  ")
+		last.literal = strings.TrimRight(last.literal, " ")
+		if hard {
+			block.appendChild(newMdNode(mdHardBreak))
+		} else {
+			block.appendChild(newMdNode(mdSoftBreak))
+		}
+	} else {
+		block.appendChild(newMdNode(mdSoftBreak))
+	}
+	for p.peek() == ' ' {
+		p.pos++
+	}
+	return true
+}
+
+func (p *mdInlineParser) parseBackslash(block *mdNode) bool {
+	p.pos++
+	c := p.peek()
+	switch {
+	case c == '\n':
+		p.pos++
+		block.appendChild(newMdNode(mdHardBreak))
+	case c != -1 && strings.IndexByte(mdEscapable, byte(c)) >= 0:
+		appendText(block, string(rune(c)))
+		p.pos++
+	default:
+		appendText(block, "\\")
+	}
+	return true
+}
+
+func (p *mdInlineParser) parseBackticks(block *mdNode) bool {
+	ticks := p.match(reLeadingTicks)
+	if ticks == "" {
+		return false
+	}
+	afterOpen := p.pos
+	for {
+		m := p.match(reTicks)
+		if m == "" {
+			break
+		}
+		if m == ticks {
+			contents := strings.ReplaceAll(p.subject[afterOpen:p.pos-len(ticks)], "\n", " ")
+			if len(contents) > 2 && contents[0] == ' ' && contents[len(contents)-1] == ' ' && strings.Trim(contents, " ") != "" {
+				contents = contents[1 : len(contents)-1]
+			}
+			node := newMdNode(mdCodeSpan)
+			node.literal = contents
+			block.appendChild(node)
+			return true
+		}
+	}
+	p.pos = afterOpen
+	appendText(block, ticks)
+	return true
+}
+
+// scanDelims 计算强调符号的数量以及能否作为开始、结束符号
+func (p *mdInlineParser) scanDelims(cc byte) (int, bool, bool) {
+	startpos := p.pos
+	numdelims := 0
+	for p.peek() == int(cc) {
+		numdelims++
+		p.pos++
+	}
+	if numdelims == 0 {
+		return 0, false, false
+	}
+
+	before := '\n'
+	if startpos > 0 {
+		before, _ = utf8.DecodeLastRuneInString(p.subject[:startpos])
+	}
+	after := '\n'
+	if p.pos < len(p.subject) {
+		after, _ = utf8.DecodeRuneInString(p.subject[p.pos:])
+	}
+	p.pos = startpos
+
+	afterIsWhitespace := unicode.IsSpace(after)
+	afterIsPunct := isMarkdownPunct(after)
+	beforeIsWhitespace := unicode.IsSpace(before)
+	beforeIsPunct := isMarkdownPunct(before)
+
+	leftFlanking := !afterIsWhitespace && (!afterIsPunct || beforeIsWhitespace || beforeIsPunct)
+	rightFlanking := !beforeIsWhitespace && (!beforeIsPunct || afterIsWhitespace || afterIsPunct)
+
+	if cc == '_' {
+		return numdelims, leftFlanking && (!rightFlanking || beforeIsPunct), rightFlanking && (!leftFlanking || afterIsPunct)
+	}
+	return numdelims, leftFlanking, rightFlanking
+}
+
+func isMarkdownPunct(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+func (p *mdInlineParser) handleDelim(cc byte, block *mdNode) bool {
+	numdelims, canOpen, canClose := p.scanDelims(cc)
+	if numdelims == 0 {
+		return false
+	}
+	startpos := p.pos
+	p.pos += numdelims
+	node := appendText(block, p.subject[startpos:p.pos])
+
+	// GFM 删除线只接受一个或两个 ~
+	if cc == '~' && numdelims > 2 {
+		return true
+	}
+
+	p.delimiters = &mdDelimiter{
+		cc:         cc,
+		numdelims:  numdelims,
+		origdelims: numdelims,
+		node:       node,
+		previous:   p.delimiters,
+		canOpen:    canOpen,
+		canClose:   canClose,
+	}
+	if p.delimiters.previous != nil {
+		p.delimiters.previous.next = p.delimiters
+	}
+	return true
+}
+
+func (p *mdInlineParser) removeDelimiter(delim *mdDelimiter) {
+	if delim.previous != nil {
+		delim.previous.next = delim.next
+	}
+	if delim.next == nil {
+		p.delimiters = delim.previous
+	} else {
+		delim.next.previous = delim.previous
+	}
+}
+
+func removeDelimitersBetween(bottom, top *mdDelimiter) {
+	if bottom.next != top {
+		bottom.next = top
+		top.previous = bottom
+	}
+}
+
+type mdOpenersKey struct {
+	cc  byte
+	idx int
+}
+
+// processEmphasis 按 CommonMark 的分隔符栈算法生成强调、加粗与删除线节点
+func (p *mdInlineParser) processEmphasis(stackBottom *mdDelimiter) {
+	openersBottom := make(map[mdOpenersKey]*mdDelimiter)
+
+	closer := p.delimiters
+	for closer != nil && closer.previous != stackBottom {
+		closer = closer.previous
+	}
+
+	for closer != nil {
+		if !closer.canClose {
+			closer = closer.next
+			continue
+		}
+
+		key := mdOpenersKey{cc: closer.cc, idx: closer.origdelims % 3}
+		if closer.canOpen {
+			key.idx += 3
+		}
+		if closer.cc == '~' {
+			key.idx = closer.numdelims
+		}
+
+		opener := closer.previous
+		openerFound := false
+		for opener != nil && opener != stackBottom && opener != openersBottom[key] {
+			if opener.cc == closer.cc && opener.canOpen {
+				if closer.cc == '~' {
+					if opener.numdelims == closer.numdelims {
+						openerFound = true
+						break
+					}
+				} else {
+					oddMatch := (closer.canOpen || opener.canClose) && closer.origdelims%3 != 0 &&
+						(opener.origdelims+closer.origdelims)%3 == 0
+					if !oddMatch {
+						openerFound = true
+						break
+					}
+				}
+			}
+			opener = opener.previous
+		}
+
+		oldCloser := closer
+		if !openerFound {
+			closer = closer.next
+			openersBottom[key] = oldCloser.previous
+			if !oldCloser.canOpen {
+				p.removeDelimiter(oldCloser)
+			}
+			continue
+		}
+
+		var useDelims int
+		var wrapper *mdNode
+		switch {
+		case closer.cc == '~':
+			useDelims = closer.numdelims
+			wrapper = newMdNode(mdStrikethrough)
+		case closer.numdelims >= 2 && opener.numdelims >= 2:
+			useDelims = 2
+			wrapper = newMdNode(mdStrong)
+		default:
+			useDelims = 1
+			wrapper = newMdNode(mdEmph)
+		}
+
+		openerInl := opener.node
+		closerInl := closer.node
+		opener.numdelims -= useDelims
+		closer.numdelims -= useDelims
+		openerInl.literal = openerInl.literal[:len(openerInl.literal)-useDelims]
+		closerInl.literal = closerInl.literal[:len(closerInl.literal)-useDelims]
+
+		for tmp := openerInl.next; tmp != nil && tmp != closerInl; {
+			next := tmp.next
+			wrapper.appendChild(tmp)
+			tmp = next
+		}
+		openerInl.insertAfter(wrapper)
+		removeDelimitersBetween(opener, closer)
+
+		if opener.numdelims == 0 {
+			openerInl.unlink()
+			p.removeDelimiter(opener)
+		}
+		if closer.numdelims == 0 {
+			closerInl.unlink()
+			next := closer.next
+			p.removeDelimiter(closer)
+			closer = next
+		}
+	}
+
+	for p.delimiters != nil && p.delimiters != stackBottom {
+		p.removeDelimiter(p.delimiters)
+	}
+}
+
+func (p *mdInlineParser) addBracket(node *mdNode, index int, image bool) {
+	if p.brackets != nil {
+		p.brackets.bracketAfter = true
+	}
+	p.brackets = &mdBracket{
+		node:              node,
+		previous:          p.brackets,
+		previousDelimiter: p.delimiters,
+		index:             index,
+		image:             image,
+		active:            true,
+	}
+}
+
+func (p *mdInlineParser) removeBracket() {
+	p.brackets = p.brackets.previous
+}
+
+func (p *mdInlineParser) parseOpenBracket(block *mdNode) bool {
+	startpos := p.pos
+	p.pos++
+	node := appendText(block, "[")
+	p.addBracket(node, startpos, false)
+	return true
+}
+
+func (p *mdInlineParser) parseBang(block *mdNode) bool {
+	startpos := p.pos
+	p.pos++
+	if p.peek() == '[' {
+		p.pos++
+		node := appendText(block, "![")
+		p.addBracket(node, startpos+1, true)
+	} else {
+		appendText(block, "!")
+	}
+	return true
+}
+
+func (p *mdInlineParser) parseCloseBracket(block *mdNode) bool {
+	p.pos++
+	startpos := p.pos
+
+	opener := p.brackets
+	if opener == nil {
+		appendText(block, "]")
+		return true
+	}
+	if !opener.active {
+		appendText(block, "]")
+		p.removeBracket()
+		return true
+	}
+
+	var dest, title string
+	matched := false
+	savepos := p.pos
+
+	// 行内链接 [text](dest "title")
+	if p.peek() == '(' {
+		p.pos++
+		p.spnl()
+		if d, ok := p.parseLinkDestination(); ok {
+			dest = d
+			p.spnl()
+			if p.pos > 0 && strings.ContainsRune(" \t\n", rune(p.subject[p.pos-1])) {
+				if t, ok := p.parseLinkTitle(); ok {
+					title = t
+				}
+			}
+			p.spnl()
+			if p.peek() == ')' {
+				p.pos++
+				matched = true
+			}
+		}
+		if !matched {
+			p.pos = savepos
+		}
+	}
+
+	// 引用链接 [text][label]、[text][]、[text]
+	if !matched {
+		beforeLabel := p.pos
+		n := p.parseLinkLabel()
+		var reflabel string
+		if n > 2 {
+			reflabel = p.subject[beforeLabel : beforeLabel+n]
+		} else if !opener.bracketAfter {
+			reflabel = p.subject[opener.index:startpos]
+		}
+		if n == 0 {
+			p.pos = savepos
+		}
+		if reflabel != "" {
+			if ref, ok := p.refmap[normalizeReference(reflabel)]; ok {
+				dest = ref.destination
+				title = ref.title
+				matched = true
+			}
+		}
+	}
+
+	if !matched {
+		p.removeBracket()
+		p.pos = startpos
+		appendText(block, "]")
+		return true
+	}
+
+	typ := mdLink
+	if opener.image {
+		typ = mdImage
+	}
+	node := newMdNode(typ)
+	node.destination = dest
+	node.title = title
+	for tmp := opener.node.next; tmp != nil; {
+		next := tmp.next
+		node.appendChild(tmp)
+		tmp = next
+	}
+	block.appendChild(node)
+	p.processEmphasis(opener.previousDelimiter)
+	p.removeBracket()
+	opener.node.unlink()
+
+	// 链接不能嵌套，停用更早的链接左括号
+	if !opener.image {
+		for b := p.brackets; b != nil; b = b.previous {
+			if !b.image {
+				b.active = false
+			}
+		}
+	}
+	return true
+}
+
+func (p *mdInlineParser) spnl() {
+	p.match(reSpnl)
+}
+
+func (p *mdInlineParser) parseLinkLabel() int {
+	m := p.match(reLinkLabel)
+	return len(m)
+}
+
+func (p *mdInlineParser) parseLinkTitle() (string, bool) {
+	m := p.match(reLinkTitle)
+	if m == "" {
+		return "", false
+	}
+	return unescapeMarkdownString(m[1 : len(m)-1]), true
+}
+
+func (p *mdInlineParser) parseLinkDestination() (string, bool) {
+	if m := p.match(reLinkDestBraces); m != "" {
+		return normalizeURI(unescapeMarkdownString(m[1 : len(m)-1])), true
+	}
+	if p.peek() == '<' {
+		return "", false
+	}
+
+	savepos := p.pos
+	openParens := 0
+	c := p.peek()
+	for c != -1 {
+		if c == '\\' && p.pos+1 < len(p.subject) && strings.IndexByte(mdEscapable, p.subject[p.pos+1]) >= 0 {
+			p.pos += 2
+		} else if c == '(' {
+			p.pos++
+			openParens++
+		} else if c == ')' {
+			if openParens < 1 {
+				break
+			}
+			p.pos++
+			openParens--
+		} else if c <= 0x20 {
+			break
+		} else {
+			p.pos++
+		}
+		c = p.peek()
+	}
+	if p.pos == savepos && c != ')' {
+		return "", false
+	}
+	if openParens != 0 {
+		p.pos = savepos
+		return "", false
+	}
+	return normalizeURI(unescapeMarkdownString(p.subject[savepos:p.pos])), true
+}
+
+func (p *mdInlineParser) parseAutolink(block *mdNode) bool {
+	if m := p.match(reEmailAutolink); m != "" {
+		addr := m[1 : len(m)-1]
+		node := newMdNode(mdLink)
+		node.destination = normalizeURI("mailto:" + addr)
+		appendText(node, addr)
+		block.appendChild(node)
+		return true
+	}
+	if m := p.match(reURIAutolink); m != "" {
+		uri := m[1 : len(m)-1]
+		node := newMdNode(mdLink)
+		node.destination = normalizeURI(uri)
+		appendText(node, uri)
+		block.appendChild(node)
+		return true
+	}
+	return false
+}
+
+func (p *mdInlineParser) parseHTMLTag(block *mdNode) bool {
+	m := p.match(reHTMLTag)
+	if m == "" {
+		return false
+	}
+	node := newMdNode(mdHTMLInline)
+	node.literal = m
+	block.appendChild(node)
+	return true
+}
+
+func (p *mdInlineParser) parseEntity(block *mdNode) bool {
+	m := p.match(reEntity)
+	if m == "" {
+		return false
+	}
+	decoded := html.UnescapeString(m)
+	if decoded == m {
+		// 未知的命名实体按原文输出
+		p.pos -= len(m)
+		return false
+	}
+	appendText(block, decoded)
+	return true
+}
+
+// parseReference 解析链接引用定义 [label]: dest "title"，返回消费的字节数
+func (p *mdInlineParser) parseReference(s string) int {
+	p.subject = s
+	p.pos = 0
+
+	n := p.parseLinkLabel()
+	if n == 0 {
+		return 0
+	}
+	rawLabel := p.subject[:n]
+	if p.peek() != ':' {
+		return 0
+	}
+	p.pos++
+
+	p.spnl()
+	destStart := p.pos
+	dest, ok := p.parseLinkDestination()
+	if !ok || (dest == "" && !strings.HasPrefix(p.subject[destStart:], "<>")) {
+		return 0
+	}
+
+	beforeTitle := p.pos
+	p.spnl()
+	title := ""
+	if p.pos != beforeTitle {
+		if t, ok := p.parseLinkTitle(); ok {
+			title = t
+		} else {
+			p.pos = beforeTitle
+		}
+	}
+
+	if loc := reLineEnd.FindStringIndex(p.subject[p.pos:]); loc != nil {
+		p.pos += loc[1]
+	} else {
+		if title == "" {
+			return 0
+		}
+		// 标题后还有内容时，标题不属于引用定义
+		title = ""
+		p.pos = beforeTitle
+		loc := reLineEnd.FindStringIndex(p.subject[p.pos:])
+		if loc == nil {
+			return 0
+		}
+		p.pos += loc[1]
+	}
+
+	label := normalizeReference(rawLabel)
+	if label == "" {
+		return 0
+	}
+	if _, exists := p.refmap[label]; !exists {
+		p.refmap[label] = &mdLinkRef{destination: dest, title: title}
+	}
+	return p.pos
+}
+
+// normalizeReference 规范化链接标签：去掉方括号、合并空白并忽略大小写
+func normalizeReference(label string) string {
+	label = strings.TrimSpace(label[1 : len(label)-1])
+	label = reWhitespaceRun.ReplaceAllString(label, " ")
+	return strings.ToUpper(strings.ToLower(label))
+}
+
+// unescapeMarkdownString 处理反斜杠转义与 HTML 实体
+func unescapeMarkdownString(s string) string {
+	if !strings.ContainsAny(s, `\&`) {
+		return s
+	}
+	return reEntityOrEscape.ReplaceAllStringFunc(s, func(m string) string {
+		if m[0] == '\\' {
+			return m[1:]
+		}
+		return html.UnescapeString(m)
+	})
+}
+
+// normalizeURI 对链接地址做百分号编码，已编码的部分保持不变
+func normalizeURI(uri string) string {
+	const safe = ";/?:@&=+$,-_.!~*'()#"
+	var b strings.Builder
+	for i := 0; i < len(uri); i++ {
+		c := uri[i]
+		switch {
+		case c == '%' && i+2 < len(uri) && isHexDigit(uri[i+1]) && isHexDigit(uri[i+2]):
+			b.WriteByte(c)
+		case c < 0x80 && (c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte(safe, c) >= 0):
+			b.WriteByte(c)
+		default:
+			b.WriteString("%" + strings.ToUpper(string("0123456789abcdef"[c>>4])+string("0123456789abcdef"[c&0x0f])))
+		}
+	}
+	return b.String()
+}
+
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// mergeTextNodes 合并相邻的文本节点
+func mergeTextNodes(node *mdNode) {
+	for child := node.firstChild; child != nil; child = child.next {
+		if child.typ == mdText {
+			for child.next != nil && child.next.typ == mdText {
+				child.literal += child.next.literal
+				child.next.unlink()
+			}
+		} else if child.firstChild != nil {
+			mergeTextNodes(child)
+		}
+	}
+}
+
+// applyExtendedAutolinks 识别 GFM 扩展自动链接（www.、http(s)://、ftp:// 与邮箱）
+func applyExtendedAutolinks(node *mdNode) {
+	for child := node.firstChild; child != nil; {
+		next := child.next
+		switch child.typ {
+		case mdText:
+			splitAutolinks(child)
+		case mdLink, mdImage:
+			// 链接内部不再识别
+		default:
+			if child.firstChild != nil {
+				applyExtendedAutolinks(child)
+			}
+		}
+		child = next
+	}
+}
+
+type mdAutolinkMatch struct {
+	start, end int
+	dest       string
+}
+
+// splitAutolinks 把文本节点中的自动链接拆分为链接节点
+func splitAutolinks(text *mdNode) {
+	s := text.literal
+	if !strings.ContainsAny(s, ".@") {
+		return
+	}
+
+	var matches []mdAutolinkMatch
+	for _, loc := range reExtendedAutolink.FindAllStringIndex(s, -1) {
+		if loc[0] > 0 && !strings.ContainsRune(" \t\n*_~(", rune(s[loc[0]-1])) {
+			continue
+		}
+		end := loc[0] + trimAutolinkTail(s[loc[0]:loc[1]])
+		link := s[loc[0]:end]
+		if !validAutolinkDomain(link) {
+			continue
+		}
+		dest := link
+		if strings.HasPrefix(strings.ToLower(link), "www.") {
+			dest = "http://" + link
+		}
+		matches = append(matches, mdAutolinkMatch{start: loc[0], end: end, dest: dest})
+	}
+	for _, loc := range reExtendedEmail.FindAllStringIndex(s, -1) {
+		end := loc[1]
+		for end > loc[0] && s[end-1] == '.' {
+			end--
+		}
+		if c := s[end-1]; c == '-' || c == '_' {
+			continue
+		}
+		if overlapsAutolink(matches, loc[0], end) {
+			continue
+		}
+		matches = append(matches, mdAutolinkMatch{start: loc[0], end: end, dest: "mailto:" + s[loc[0]:end]})
+	}
+	if len(matches) == 0 {
+		return
+	}
+	sortAutolinkMatches(matches)
+
+	cursor := text
+	pos := 0
+	for _, m := range matches {
+		if m.start < pos {
+			continue
+		}
+		if m.start > pos {
+			before := newMdNode(mdText)
+			before.literal = s[pos:m.start]
+			cursor.insertAfter(before)
+			cursor = before
+		}
+		link := newMdNode(mdLink)
+		link.destination = normalizeURI(m.dest)
+		appendText(link, s[m.start:m.end])
+		cursor.insertAfter(link)
+		cursor = link
+		pos = m.end
+	}
+	if pos < len(s) {
+		after := newMdNode(mdText)
+		after.literal = s[pos:]
+		cursor.insertAfter(after)
+	}
+	text.unlink()
+}
+
+// trimAutolinkTail 去掉自动链接末尾的标点、不配对的右括号与实体引用，返回保留的长度
+func trimAutolinkTail(link string) int {
+	end := len(link)
+	for end > 0 {
+		c := link[end-1]
+		switch {
+		case strings.IndexByte("?!.,:*_~'\"", c) >= 0:
+			end--
+		case c == ')':
+			if strings.Count(link[:end], "(") < strings.Count(link[:end], ")") {
+				end--
+			} else {
+				return end
+			}
+		case c == ';':
+			if loc := reTrailingEntity.FindStringIndex(link[:end]); loc != nil {
+				end = loc[0]
+			} else {
+				return end
+			}
+		default:
+			return end
+		}
+	}
+	return end
+}
+
+// validAutolinkDomain 域名至少包含一个点，且最后两段不能含下划线
+func validAutolinkDomain(link string) bool {
+	host := link
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	if i := strings.IndexAny(host, "/?#"); i >= 0 {
+		host = host[:i]
+	}
+	if host == "" {
+		return false
+	}
+	parts := strings.Split(host, ".")
+	if len(parts) < 2 && strings.HasPrefix(strings.ToLower(link), "www.") {
+		return false
+	}
+	for i := max(0, len(parts)-2); i < len(parts); i++ {
+		if strings.Contains(parts[i], "_") {
+			return false
+		}
+	}
+	return true
+}
+
+func overlapsAutolink(matches []mdAutolinkMatch, start, end int) bool {
+	for _, m := range matches {
+		if start < m.end && m.start < end {
+			return true
+		}
+	}
+	return false
+}
+
+func sortAutolinkMatches(matches []mdAutolinkMatch) {
+	for i := 1; i < len(matches); i++ {
+		for j := i; j > 0 && matches[j].start < matches[j-1].start; j-- {
+			matches[j], matches[j-1] = matches[j-1], matches[j]
+		}
+	}
+}
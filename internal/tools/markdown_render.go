@@ -0,0 +1,496 @@
+package tools
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultSectionStyle 主题未配置 base 时外层容器的样式
+const defaultSectionStyle = "text-align: left; line-height: 1.75; color: #333;"
+
+var (
+	// reUnsafeURL 不允许出现在链接中的协议，图片允许常见格式的 data URI
+	reUnsafeURL     = regexp.MustCompile(`(?i)^\s*(?:javascript|vbscript|file|data):`)
+	reSafeDataImage = regexp.MustCompile(`(?i)^\s*data:image/(?:png|gif|jpeg|webp);`)
+	// reDisallowedTag GFM tagfilter：原样输出的 HTML 中这些标签会被转义
+	reDisallowedTag = regexp.MustCompile(`(?i)<(/?(?:title|textarea|style|xmp|iframe|noembed|noframes|script|plaintext)(?:[\s/>]|$))`)
+
+	// rawHTMLAllowedTags 普通模式下原始 HTML 允许保留的标签
+	rawHTMLAllowedTags = map[string]bool{
+		"section": true, "article": true, "div": true, "p": true, "span": true, "br": true, "hr": true,
+		"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+		"strong": true, "b": true, "em": true, "i": true, "u": true, "s": true, "del": true, "ins": true,
+		"mark": true, "small": true, "sub": true, "sup": true, "kbd": true, "abbr": true, "code": true, "pre": true,
+		"blockquote": true, "ul": true, "ol": true, "li": true, "dl": true, "dt": true, "dd": true,
+		"table": true, "caption": true, "colgroup": true, "col": true, "thead": true, "tbody": true, "tfoot": true,
+		"tr": true, "th": true, "td": true, "img": true, "a": true, "figure": true, "figcaption": true,
+		"details": true, "summary": true,
+	}
+	// rawHTMLAllowedAttributes 普通模式下各标签允许保留的属性（"*" 对所有标签生效），
+	// 事件属性（on*）与 style 不在白名单内，一律移除
+	rawHTMLAllowedAttributes = map[string]map[string]bool{
+		"*":   {"title": true, "class": true, "align": true},
+		"a":   {"href": true, "name": true},
+		"img": {"src": true, "alt": true, "width": true, "height": true},
+		"ol":  {"start": true},
+		"col": {"span": true},
+		"td":  {"colspan": true, "rowspan": true},
+		"th":  {"colspan": true, "rowspan": true},
+	}
+)
+
+// mdThemeStyles 主题中的基础、块级与行内样式表
+// 样式键兼容常见公众号主题的命名（如 blockquote_p、code_pre、listitem、codespan），
+// 找不到时回退到标签名
+type mdThemeStyles struct {
+	base   map[string]interface{}
+	block  map[string]interface{}
+	inline map[string]interface{}
+}
+
+func newMdThemeStyles(themeConfig map[string]interface{}) *mdThemeStyles {
+	styles := &mdThemeStyles{}
+	styles.base, _ = themeConfig["base"].(map[string]interface{})
+	styles.block, _ = themeConfig["block"].(map[string]interface{})
+	styles.inline, _ = themeConfig["inline"].(map[string]interface{})
+	return styles
+}
+
+// lookup 按顺序查找样式，inline 为 true 时优先查行内样式表，否则优先查块级样式表
+func (s *mdThemeStyles) lookup(inline bool, keys []string) map[string]interface{} {
+	tables := []map[string]interface{}{s.block, s.inline}
+	if inline {
+		tables = []map[string]interface{}{s.inline, s.block}
+	}
+	for _, styles := range tables {
+		for _, key := range keys {
+			if style, ok := styles[key].(map[string]interface{}); ok && len(style) > 0 {
+				return style
+			}
+		}
+	}
+	return nil
+}
+
+// cssText 将样式表转换为内联样式字符串，属性按名称排序以保证输出稳定
+func cssText(style map[string]interface{}) string {
+	keys := make([]string, 0, len(style))
+	for k := range style {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s: %v;", k, style[k]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// mdRenderOptions 渲染选项
+type mdRenderOptions struct {
+	wechat       *WechatRenderReport // 不为空时使用公众号兼容模式，改写的结构记录到报告中
+	linkFootnote *mdLinkFootnoteRule // 不为空时外部链接转为脚注
+}
+
+// mdHTMLRenderer 将语法树渲染为带内联样式的 HTML
+type mdHTMLRenderer struct {
+	styles    *mdThemeStyles
+	wechat    *WechatRenderReport
+	footnotes *mdFootnotes
+	buf       strings.Builder
+}
+
+// renderMarkdownHTML 将 Markdown 渲染为 HTML，外层包裹 section 容器
+func renderMarkdownHTML(markdownText string, themeConfig map[string]interface{}, opts mdRenderOptions) string {
+	r := &mdHTMLRenderer{
+		styles:    newMdThemeStyles(themeConfig),
+		wechat:    opts.wechat,
+		footnotes: newMdFootnotes(opts.linkFootnote),
+	}
+
+	sectionStyle := defaultSectionStyle
+	if len(r.styles.base) > 0 {
+		sectionStyle = cssText(r.styles.base)
+	}
+	r.buf.WriteString(`<section style="` + escapeHTML(sectionStyle) + "\">\n")
+	r.renderBlocks(parseMarkdown(markdownText), false)
+	r.renderReferences()
+	r.buf.WriteString("</section>")
+	return r.buf.String()
+}
+
+// style 计算元素的内联样式
+// 公众号模式下主题未配置的元素使用默认样式，inherit 为 true 时还会带上 base 中可继承的文字样式，
+// 避免编辑器丢弃外层容器后样式丢失
+func (r *mdHTMLRenderer) style(inline, inherit bool, keys ...string) string {
+	style := r.styles.lookup(inline, keys)
+	if r.wechat == nil {
+		return cssText(style)
+	}
+
+	merged := make(map[string]interface{})
+	if inherit {
+		for _, prop := range wechatInheritedProps {
+			if v, ok := r.styles.base[prop]; ok {
+				merged[prop] = v
+			}
+		}
+	}
+	if style == nil {
+		style = wechatDefaultStyles[keys[0]]
+	}
+	for k, v := range style {
+		merged[k] = v
+	}
+	return cssText(merged)
+}
+
+func (r *mdHTMLRenderer) blockStyle(keys ...string) string {
+	return r.style(false, false, keys...)
+}
+
+func (r *mdHTMLRenderer) textStyle(keys ...string) string {
+	return r.style(false, true, keys...)
+}
+
+func (r *mdHTMLRenderer) inlineStyle(keys ...string) string {
+	return r.style(true, false, keys...)
+}
+
+func (r *mdHTMLRenderer) openTag(tag, style string, attrs ...string) {
+	r.buf.WriteString("<" + tag)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		r.buf.WriteString(" " + attrs[i] + `="` + escapeHTML(attrs[i+1]) + `"`)
+	}
+	if style != "" {
+		r.buf.WriteString(` style="` + escapeHTML(style) + `"`)
+	}
+	r.buf.WriteString(">")
+}
+
+func (r *mdHTMLRenderer) ensureNewline() {
+	if r.buf.Len() > 0 && !strings.HasSuffix(r.buf.String(), "\n") {
+		r.buf.WriteString("\n")
+	}
+}
+
+// renderBlocks 渲染块级子节点，tight 表示处于紧凑列表中（段落不输出 p 标签）
+func (r *mdHTMLRenderer) renderBlocks(parent *mdNode, tight bool) {
+	for node := parent.firstChild; node != nil; node = node.next {
+		r.renderBlock(node, tight)
+	}
+}
+
+func (r *mdHTMLRenderer) renderBlock(node *mdNode, tight bool) {
+	switch node.typ {
+	case mdParagraph:
+		if tight {
+			r.renderInlines(node)
+			return
+		}
+		style := r.textStyle("p")
+		if node.parent != nil && node.parent.typ == mdBlockquote {
+			style = r.textStyle("blockquote_p", "p")
+		}
+		r.openTag("p", style)
+		r.renderInlines(node)
+		r.buf.WriteString("</p>\n")
+
+	case mdHeading:
+		tag := "h" + strconv.Itoa(node.level)
+		r.openTag(tag, r.textStyle(tag))
+		r.renderInlines(node)
+		r.buf.WriteString("</" + tag + ">\n")
+
+	case mdThematicBreak:
+		r.buf.WriteString("<hr")
+		if style := r.blockStyle("hr"); style != "" {
+			r.buf.WriteString(` style="` + escapeHTML(style) + `"`)
+		}
+		r.buf.WriteString(" />\n")
+
+	case mdBlockquote:
+		r.openTag("blockquote", r.textStyle("blockquote"))
+		r.buf.WriteString("\n")
+		r.renderBlocks(node, false)
+		r.buf.WriteString("</blockquote>\n")
+
+	case mdList:
+		if r.wechat != nil {
+			r.renderWechatList(node)
+			return
+		}
+		tag := "ul"
+		var attrs []string
+		if node.list.ordered {
+			tag = "ol"
+			if node.list.start != 1 {
+				attrs = append(attrs, "start", strconv.Itoa(node.list.start))
+			}
+		}
+		r.openTag(tag, r.blockStyle(tag), attrs...)
+		r.buf.WriteString("\n")
+		r.renderBlocks(node, node.tight)
+		r.buf.WriteString("</" + tag + ">\n")
+
+	case mdItem:
+		r.openTag("li", r.inlineStyle("listitem", "li"))
+		if node.checked != nil {
+			r.buf.WriteString(`<input type="checkbox" disabled=""`)
+			if *node.checked {
+				r.buf.WriteString(` checked=""`)
+			}
+			r.buf.WriteString(" /> ")
+		}
+		for child := node.firstChild; child != nil; child = child.next {
+			// 紧凑列表中的段落直接输出行内内容，其余块级元素另起一行
+			if !tight || child.typ != mdParagraph {
+				r.ensureNewline()
+			}
+			r.renderBlock(child, tight)
+		}
+		r.buf.WriteString("</li>\n")
+
+	case mdCodeBlock:
+		if r.wechat != nil {
+			r.renderWechatCodeBlock(node)
+			return
+		}
+		r.openTag("pre", r.blockStyle("code_pre", "pre"))
+		var attrs []string
+		if lang, _, _ := strings.Cut(node.info, " "); lang != "" {
+			attrs = append(attrs, "class", "language-"+lang)
+		}
+		r.openTag("code", r.blockStyle("code"), attrs...)
+		r.buf.WriteString(escapeHTML(node.literal))
+		r.buf.WriteString("</code></pre>\n")
+
+	case mdHTMLBlock:
+		r.buf.WriteString(r.rawHTML(node.literal))
+		r.buf.WriteString("\n")
+
+	case mdTable:
+		r.renderTable(node)
+	}
+}
+
+func (r *mdHTMLRenderer) renderTable(table *mdNode) {
+	if r.wechat != nil {
+		// 表格较宽时允许横向滚动
+		r.buf.WriteString(`<section style="overflow-x: auto;">` + "\n")
+	}
+	r.openTag("table", r.inlineStyle("table"))
+	r.buf.WriteString("\n")
+
+	inBody := false
+	for row := table.firstChild; row != nil; row = row.next {
+		if row.header {
+			r.openTag("thead", r.inlineStyle("thead"))
+			r.buf.WriteString("\n")
+		} else if !inBody {
+			r.buf.WriteString("<tbody>\n")
+			inBody = true
+		}
+
+		r.buf.WriteString("<tr>\n")
+		for cell := row.firstChild; cell != nil; cell = cell.next {
+			tag, style := "td", r.inlineStyle("td")
+			if cell.header {
+				tag, style = "th", r.inlineStyle("th", "td")
+			}
+			if cell.literal != "" {
+				style = strings.TrimSpace(style + " text-align: " + cell.literal + ";")
+			}
+			r.openTag(tag, style)
+			r.renderInlines(cell)
+			r.buf.WriteString("</" + tag + ">\n")
+		}
+		r.buf.WriteString("</tr>\n")
+
+		if row.header {
+			r.buf.WriteString("</thead>\n")
+		}
+	}
+	if inBody {
+		r.buf.WriteString("</tbody>\n")
+	}
+	r.buf.WriteString("</table>\n")
+	if r.wechat != nil {
+		r.buf.WriteString("</section>\n")
+	}
+}
+
+func (r *mdHTMLRenderer) renderInlines(parent *mdNode) {
+	for node := parent.firstChild; node != nil; node = node.next {
+		r.renderInline(node)
+	}
+}
+
+func (r *mdHTMLRenderer) renderInline(node *mdNode) {
+	switch node.typ {
+	case mdText:
+		r.buf.WriteString(escapeHTML(node.literal))
+	case mdSoftBreak:
+		r.buf.WriteString("\n")
+	case mdHardBreak:
+		r.buf.WriteString("<br />\n")
+	case mdCodeSpan:
+		r.openTag("code", r.inlineStyle("codespan", "code"))
+		r.buf.WriteString(escapeHTML(node.literal))
+		r.buf.WriteString("</code>")
+	case mdEmph:
+		r.openTag("em", r.inlineStyle("em"))
+		r.renderInlines(node)
+		r.buf.WriteString("</em>")
+	case mdStrong:
+		r.openTag("strong", r.inlineStyle("strong"))
+		r.renderInlines(node)
+		r.buf.WriteString("</strong>")
+	case mdStrikethrough:
+		if r.wechat != nil {
+			// 公众号编辑器会过滤 del 标签
+			r.wechat.add(WechatConstructStrikethrough, "改写为带删除线样式的 span", "")
+			r.openTag("span", r.inlineStyle("del", "s"))
+			r.renderInlines(node)
+			r.buf.WriteString("</span>")
+			return
+		}
+		r.openTag("del", r.inlineStyle("del", "s"))
+		r.renderInlines(node)
+		r.buf.WriteString("</del>")
+	case mdLink:
+		dest := safeURL(node.destination, false)
+		if !IsWechatArticleURL(dest) {
+			if r.footnotes != nil && dest != "" {
+				r.renderFootnoteLink(node, dest)
+				return
+			}
+			if r.wechat != nil {
+				// 公众号正文只允许链接到公众号文章，其他链接保留文字
+				r.wechat.add(WechatConstructExternalLink, "外部链接改写为文本", dest)
+				r.openTag("span", r.inlineStyle("link", "a"))
+				r.renderInlines(node)
+				r.buf.WriteString("</span>")
+				return
+			}
+		}
+		attrs := []string{"href", dest}
+		if node.title != "" {
+			attrs = append(attrs, "title", node.title)
+		}
+		r.openTag("a", r.inlineStyle("link", "a"), attrs...)
+		r.renderInlines(node)
+		r.buf.WriteString("</a>")
+	case mdImage:
+		r.buf.WriteString(`<img src="` + escapeHTML(safeURL(node.destination, true)) + `" alt="` + escapeHTML(plainText(node)) + `"`)
+		if node.title != "" {
+			r.buf.WriteString(` title="` + escapeHTML(node.title) + `"`)
+		}
+		if style := r.blockStyle("image", "img"); style != "" {
+			r.buf.WriteString(` style="` + escapeHTML(style) + `"`)
+		}
+		r.buf.WriteString(" />")
+	case mdHTMLInline:
+		r.buf.WriteString(r.rawHTML(node.literal))
+	}
+}
+
+// plainText 提取节点的纯文本（用于图片 alt）
+func plainText(node *mdNode) string {
+	var b strings.Builder
+	var walk func(n *mdNode)
+	walk = func(n *mdNode) {
+		for child := n.firstChild; child != nil; child = child.next {
+			switch child.typ {
+			case mdText, mdCodeSpan:
+				b.WriteString(child.literal)
+			case mdSoftBreak, mdHardBreak:
+				b.WriteString(" ")
+			default:
+				walk(child)
+			}
+		}
+	}
+	walk(node)
+	return b.String()
+}
+
+// safeURL 过滤可执行脚本的链接协议
+// 浏览器解析链接时会忽略其中的空白与控制字符，判断协议前先行移除
+func safeURL(url string, image bool) string {
+	scheme := strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, url)
+	if reUnsafeURL.MatchString(scheme) && !(image && reSafeDataImage.MatchString(scheme)) {
+		return ""
+	}
+	return url
+}
+
+// filterHTMLTags 按 GFM tagfilter 转义原始 HTML 中的危险标签
+func filterHTMLTags(raw string) string {
+	return reDisallowedTag.ReplaceAllString(raw, "&lt;$1")
+}
+
+// rawHTML 输出 Markdown 中的原始 HTML
+// 公众号模式下整体交给 sanitizeWechatHTML 处理，普通模式按白名单清理
+func (r *mdHTMLRenderer) rawHTML(raw string) string {
+	if r.wechat != nil {
+		return filterHTMLTags(raw)
+	}
+	return sanitizeRawHTML(raw)
+}
+
+// sanitizeRawHTML 按白名单清理原始 HTML：移除注释，危险标签按 tagfilter 转义，
+// 白名单外的标签移除但保留内容，属性只保留白名单内的并重新转义，href/src 经 safeURL 过滤；
+// 不构成完整标签的 "<" 一律转义，避免与后续输出拼接成新的标签
+func sanitizeRawHTML(raw string) string {
+	raw = filterHTMLTags(reWechatComment.ReplaceAllString(raw, ""))
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range reWechatTag.FindAllStringSubmatchIndex(raw, -1) {
+		b.WriteString(strings.ReplaceAll(raw[last:loc[0]], "<", "&lt;"))
+		last = loc[1]
+
+		closing, name, attrs, selfClosing := raw[loc[2]:loc[3]], strings.ToLower(raw[loc[4]:loc[5]]), raw[loc[6]:loc[7]], raw[loc[8]:loc[9]]
+		if !rawHTMLAllowedTags[name] {
+			continue
+		}
+		if closing != "" {
+			b.WriteString("</" + name + ">")
+			continue
+		}
+
+		b.WriteString("<" + name)
+		for _, attr := range reWechatAttribute.FindAllStringSubmatch(attrs, -1) {
+			attrName := strings.ToLower(attr[1])
+			if !rawHTMLAllowedAttributes["*"][attrName] && !rawHTMLAllowedAttributes[name][attrName] {
+				continue
+			}
+			value := attr[2]
+			if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+				value = value[1 : len(value)-1]
+			}
+			value = html.UnescapeString(value)
+			if (attrName == "href" || attrName == "src") && safeURL(value, attrName == "src") == "" {
+				continue
+			}
+			b.WriteString(" " + attrName + `="` + escapeHTML(value) + `"`)
+		}
+		if selfClosing != "" || name == "br" || name == "hr" || name == "img" || name == "col" {
+			b.WriteString(" /")
+		}
+		b.WriteString(">")
+	}
+	b.WriteString(strings.ReplaceAll(raw[last:], "<", "&lt;"))
+	return b.String()
+}
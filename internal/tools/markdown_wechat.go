@@ -0,0 +1,342 @@
+package tools
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// 公众号兼容模式下被改写或移除的结构
+const (
+	WechatConstructCodeBlock     = "code_block"
+	WechatConstructList          = "list"
+	WechatConstructTaskList      = "task_list"
+	WechatConstructStrikethrough = "strikethrough"
+	WechatConstructExternalLink  = "external_link"
+	WechatConstructHTMLTag       = "html_tag"
+	WechatConstructHTMLAttribute = "html_attribute"
+	WechatConstructHTMLComment   = "html_comment"
+)
+
+// wechatReportMaxSamples 每类降级保留的示例数量
+const wechatReportMaxSamples = 5
+
+// WechatDowngrade 一类被降级的结构
+type WechatDowngrade struct {
+	Construct string   `json:"construct"`         // 结构类型
+	Action    string   `json:"action"`            // 处理方式
+	Count     int      `json:"count"`             // 出现次数
+	Samples   []string `json:"samples,omitempty"` // 示例（链接地址、标签或属性名）
+}
+
+// WechatRenderReport 公众号兼容模式的校验报告，列出被改写或移除的结构
+type WechatRenderReport struct {
+	Compatible bool               `json:"compatible"` // 没有任何结构被降级
+	Downgrades []*WechatDowngrade `json:"downgrades"`
+}
+
+// NewWechatRenderReport 创建空的校验报告
+func NewWechatRenderReport() *WechatRenderReport {
+	return &WechatRenderReport{Compatible: true, Downgrades: []*WechatDowngrade{}}
+}
+
+// add 记录一次降级，相同结构与处理方式合并计数
+func (r *WechatRenderReport) add(construct, action, sample string) {
+	r.Compatible = false
+	var item *WechatDowngrade
+	for _, d := range r.Downgrades {
+		if d.Construct == construct && d.Action == action {
+			item = d
+			break
+		}
+	}
+	if item == nil {
+		item = &WechatDowngrade{Construct: construct, Action: action}
+		r.Downgrades = append(r.Downgrades, item)
+	}
+	item.Count++
+	if sample == "" || len(item.Samples) >= wechatReportMaxSamples {
+		return
+	}
+	for _, s := range item.Samples {
+		if s == sample {
+			return
+		}
+	}
+	item.Samples = append(item.Samples, sample)
+}
+
+// wechatInheritedProps 公众号模式下从 base 复制到文字元素上的可继承样式
+var wechatInheritedProps = []string{"color", "font-family", "font-size", "letter-spacing", "line-height"}
+
+// wechatDefaultStyles 主题未配置时公众号模式使用的默认样式
+var wechatDefaultStyles = map[string]map[string]interface{}{
+	"code_pre": {
+		"margin":        "1em 0",
+		"padding":       "1em",
+		"background":    "#f6f8fa",
+		"border-radius": "4px",
+		"overflow-x":    "auto",
+		"font-size":     "14px",
+		"line-height":   "1.6",
+	},
+	"code": {
+		"font-family": "Menlo, Consolas, Monaco, monospace",
+		"color":       "#333",
+	},
+	"codespan": {
+		"padding":       "0.1em 0.3em",
+		"background":    "#f6f8fa",
+		"border-radius": "3px",
+		"font-family":   "Menlo, Consolas, Monaco, monospace",
+		"font-size":     "90%",
+	},
+	"blockquote": {
+		"margin":      "1em 0",
+		"padding":     "0.5em 1em",
+		"border-left": "4px solid #ddd",
+		"color":       "#666",
+	},
+	"ul": {
+		"margin":       "0.5em 0",
+		"padding-left": "1em",
+	},
+	"ol": {
+		"margin":       "0.5em 0",
+		"padding-left": "1em",
+	},
+	"listitem": {
+		"margin": "0.2em 0",
+	},
+	"table": {
+		"border-collapse": "collapse",
+		"margin":          "1em 0",
+		"font-size":       "14px",
+	},
+	"th": {
+		"border":      "1px solid #dfdfdf",
+		"padding":     "0.25em 0.5em",
+		"background":  "#f6f8fa",
+		"font-weight": "bold",
+	},
+	"td": {
+		"border":  "1px solid #dfdfdf",
+		"padding": "0.25em 0.5em",
+	},
+	"hr": {
+		"border":     "none",
+		"border-top": "1px solid #ddd",
+		"margin":     "1.5em 0",
+	},
+	"image": {
+		"display":   "block",
+		"max-width": "100%",
+		"margin":    "0.5em auto",
+	},
+	"del": {
+		"text-decoration": "line-through",
+	},
+	"link": {
+		"color": "#576b95",
+	},
+	"footnote": {
+		"color":     "#576b95",
+		"font-size": "75%",
+	},
+	"footnotes": {
+		"margin":     "2em 0 1em",
+		"font-size":  "12px",
+		"color":      "#888",
+		"word-break": "break-all",
+	},
+	"footnotes_title": {
+		"margin":      "0 0 0.5em",
+		"font-size":   "14px",
+		"font-weight": "bold",
+	},
+	"footnote_item": {
+		"margin": "0.2em 0",
+	},
+}
+
+// wechatListBullets 各层级无序列表的符号
+var wechatListBullets = []string{"•", "◦", "▪"}
+
+// renderWechatList 将列表改写为 section 段落，列表符号以文本输出
+// 公众号编辑器会重排 ul/ol 的缩进和符号，嵌套列表尤其容易错乱
+func (r *mdHTMLRenderer) renderWechatList(list *mdNode) {
+	r.wechat.add(WechatConstructList, "列表改写为 section 段落", "")
+
+	depth := 0
+	for p := list.parent; p != nil; p = p.parent {
+		if p.typ == mdList {
+			depth++
+		}
+	}
+
+	tag := "ul"
+	if list.list.ordered {
+		tag = "ol"
+	}
+	r.ensureNewline()
+	r.openTag("section", r.blockStyle(tag))
+	r.buf.WriteString("\n")
+
+	number := list.list.start
+	for item := list.firstChild; item != nil; item = item.next {
+		var marker string
+		switch {
+		case item.checked != nil:
+			r.wechat.add(WechatConstructTaskList, "复选框改写为符号", "")
+			marker = "☐"
+			if *item.checked {
+				marker = "☑"
+			}
+		case list.list.ordered:
+			marker = fmt.Sprintf("%d%c", number, list.list.delimiter)
+			number++
+		default:
+			marker = wechatListBullets[min(depth, len(wechatListBullets)-1)]
+		}
+
+		r.openTag("section", r.textStyle("listitem", "li"))
+		r.buf.WriteString(`<span style="margin-right: 0.5em;">` + marker + "</span>")
+		child := item.firstChild
+		if child != nil && child.typ == mdParagraph {
+			// 首段与列表符号同行
+			r.renderInlines(child)
+			child = child.next
+		}
+		for ; child != nil; child = child.next {
+			r.ensureNewline()
+			r.renderBlock(child, list.tight)
+		}
+		r.buf.WriteString("</section>\n")
+	}
+	r.buf.WriteString("</section>\n")
+}
+
+// renderWechatCodeBlock 将代码块改写为带样式的 section
+// 公众号编辑器会合并 pre 中的空白，因此空格转为 &nbsp;、换行转为 <br />
+func (r *mdHTMLRenderer) renderWechatCodeBlock(node *mdNode) {
+	r.wechat.add(WechatConstructCodeBlock, "代码块改写为带样式的 section", "")
+
+	lines := strings.Split(strings.TrimSuffix(node.literal, "\n"), "\n")
+	for i, line := range lines {
+		line = strings.ReplaceAll(line, "\t", "    ")
+		lines[i] = strings.ReplaceAll(escapeHTML(line), " ", "&nbsp;")
+	}
+
+	r.openTag("section", r.blockStyle("code_pre", "pre"))
+	r.openTag("span", r.blockStyle("code"))
+	r.buf.WriteString(strings.Join(lines, "<br />"))
+	r.buf.WriteString("</span></section>\n")
+}
+
+// IsWechatArticleURL 是否为公众号文章链接（公众号正文中唯一允许的超链接）
+func IsWechatArticleURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	return strings.EqualFold(u.Hostname(), "mp.weixin.qq.com")
+}
+
+var (
+	reWechatTag       = regexp.MustCompile(`<(/?)([a-zA-Z][a-zA-Z0-9-]*)((?:[^>"']|"[^"]*"|'[^']*')*?)(/?)>`)
+	reWechatComment   = regexp.MustCompile(`(?s)<!--.*?-->`)
+	reWechatAttribute = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)(?:\s*=\s*("[^"]*"|'[^']*'|[^\s"'=<>` + "`" + `]+))?`)
+
+	// wechatStrippedContentTags 连同内容一起移除的标签
+	wechatStrippedContentTags = []string{"script", "style", "textarea", "title", "noscript", "xmp", "select", "button", "iframe", "object"}
+	wechatStrippedContentRes  = buildStrippedContentRes(wechatStrippedContentTags)
+
+	// wechatAllowedTags 公众号正文支持的标签
+	wechatAllowedTags = map[string]bool{
+		"section": true, "p": true, "span": true, "strong": true, "b": true, "em": true, "i": true, "u": true,
+		"br": true, "hr": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+		"blockquote": true, "table": true, "thead": true, "tbody": true, "tr": true, "th": true, "td": true,
+		"img": true, "a": true, "code": true, "sup": true, "sub": true, "figure": true, "figcaption": true,
+	}
+	// wechatRenamedTags 语义相近、直接改名的标签
+	wechatRenamedTags = map[string]string{"div": "section", "article": "section", "pre": "section"}
+
+	// wechatAllowedAttributes 各标签允许保留的属性（"*" 对所有标签生效）
+	wechatAllowedAttributes = map[string]map[string]bool{
+		"*":   {"style": true, "title": true},
+		"a":   {"href": true},
+		"img": {"src": true, "alt": true, "width": true, "height": true, "data-src": true},
+		"td":  {"colspan": true, "rowspan": true},
+		"th":  {"colspan": true, "rowspan": true},
+	}
+)
+
+func buildStrippedContentRes(tags []string) []*regexp.Regexp {
+	res := make([]*regexp.Regexp, 0, len(tags))
+	for _, tag := range tags {
+		res = append(res, regexp.MustCompile(`(?is)<`+tag+`\b[^>]*>.*?</`+tag+`\s*>`))
+	}
+	return res
+}
+
+// sanitizeWechatHTML 移除公众号不支持的标签与属性，被移除的内容记录到报告中
+func sanitizeWechatHTML(htmlText string, report *WechatRenderReport) string {
+	htmlText = reWechatComment.ReplaceAllStringFunc(htmlText, func(string) string {
+		report.add(WechatConstructHTMLComment, "移除 HTML 注释", "")
+		return ""
+	})
+	for i, re := range wechatStrippedContentRes {
+		tag := wechatStrippedContentTags[i]
+		htmlText = re.ReplaceAllStringFunc(htmlText, func(string) string {
+			report.add(WechatConstructHTMLTag, "移除标签及其内容", "<"+tag+">")
+			return ""
+		})
+	}
+
+	return reWechatTag.ReplaceAllStringFunc(htmlText, func(tag string) string {
+		m := reWechatTag.FindStringSubmatch(tag)
+		closing, name, attrs, selfClosing := m[1], strings.ToLower(m[2]), m[3], m[4]
+
+		if renamed, ok := wechatRenamedTags[name]; ok {
+			name = renamed
+		}
+		if !wechatAllowedTags[name] {
+			if closing == "" {
+				report.add(WechatConstructHTMLTag, "移除不支持的标签，保留内容", "<"+name+">")
+			}
+			return ""
+		}
+		if closing != "" {
+			return "</" + name + ">"
+		}
+
+		var b strings.Builder
+		b.WriteString("<" + name)
+		for _, attr := range reWechatAttribute.FindAllStringSubmatch(attrs, -1) {
+			attrName := strings.ToLower(attr[1])
+			if !wechatAllowedAttributes["*"][attrName] && !wechatAllowedAttributes[name][attrName] {
+				report.add(WechatConstructHTMLAttribute, "移除不支持的属性", attrName)
+				continue
+			}
+			value := attr[2]
+			if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+				value = value[1 : len(value)-1]
+			}
+			if attrName == "href" && !IsWechatArticleURL(value) {
+				report.add(WechatConstructExternalLink, "移除外部链接地址", value)
+				continue
+			}
+			if (attrName == "href" || attrName == "src") && safeURL(value, attrName == "src") == "" {
+				report.add(WechatConstructHTMLAttribute, "移除不安全的链接", attrName)
+				continue
+			}
+			b.WriteString(" " + attrName + `="` + strings.ReplaceAll(value, `"`, "&quot;") + `"`)
+		}
+		if selfClosing != "" || name == "br" || name == "hr" || name == "img" {
+			b.WriteString(" /")
+		}
+		b.WriteString(">")
+		return b.String()
+	})
+}
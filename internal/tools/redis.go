@@ -53,6 +53,9 @@ func (r *Redis) getClient(db int) (*redis.Client, error) {
 	}
 
 	// 创建新客户端
+	if config.AppConfig == nil {
+		return nil, fmt.Errorf("redis config not loaded")
+	}
 	cfg := config.AppConfig.Redis
 	client = redis.NewClient(&redis.Options{
 		Addr:       fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
@@ -120,6 +123,55 @@ func (r *Redis) Expire(key string, expiration int, db int) error {
 	return client.Persist(ctx, key).Err()
 }
 
+// SetNX 仅在键不存在时设置值，返回是否设置成功
+func (r *Redis) SetNX(key string, value string, expiration int, db int) (bool, error) {
+	client, err := r.getClient(db)
+	if err != nil {
+		return false, err
+	}
+
+	ctx := context.Background()
+	return client.SetNX(ctx, key, value, time.Duration(expiration)*time.Second).Result()
+}
+
+// Incr 自增计数，键不存在时从0开始；首次创建时设置过期时间
+func (r *Redis) Incr(key string, expiration int, db int) (int64, error) {
+	client, err := r.getClient(db)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx := context.Background()
+	count, err := client.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 && expiration > 0 {
+		if err := client.Expire(ctx, key, time.Duration(expiration)*time.Second).Err(); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// TTL 获取键的剩余过期时间（秒），-1表示永不过期，-2表示不存在
+func (r *Redis) TTL(key string, db int) (int64, error) {
+	client, err := r.getClient(db)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx := context.Background()
+	ttl, err := client.TTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return int64(ttl), nil
+	}
+	return int64(ttl.Seconds()), nil
+}
+
 // Delete 删除键
 func (r *Redis) Delete(key string, db int) error {
 	client, err := r.getClient(db)
@@ -131,6 +183,18 @@ func (r *Redis) Delete(key string, db int) error {
 	return client.Del(ctx, key).Err()
 }
 
+// DeleteExisting 删除键并返回删除前键是否存在，可用于一次性凭证的原子消费
+func (r *Redis) DeleteExisting(key string, db int) (bool, error) {
+	client, err := r.getClient(db)
+	if err != nil {
+		return false, err
+	}
+
+	ctx := context.Background()
+	count, err := client.Del(ctx, key).Result()
+	return count > 0, err
+}
+
 // DeleteKeys 批量删除键
 func (r *Redis) DeleteKeys(keys []string, db int) error {
 	if len(keys) == 0 {
@@ -0,0 +1,253 @@
+package tools
+
+import (
+	"strings"
+	"unicode"
+)
+
+// 差异片段类型
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// maxDiffEdits 单次比较允许的最大编辑距离，超过时退化为整段删除加整段插入，避免长文本比较占用过多内存
+const maxDiffEdits = 2000
+
+// DiffOp 差异片段，相邻的同类型片段已合并
+type DiffOp struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// TextDiff 文本比较结果，Insertions / Deletions 按比较单位（行或词）计数
+type TextDiff struct {
+	Ops        []DiffOp `json:"ops"`
+	Insertions int      `json:"insertions"`
+	Deletions  int      `json:"deletions"`
+}
+
+// DiffLines 按行比较两段文本
+func DiffLines(a, b string) *TextDiff {
+	diff := &TextDiff{}
+	diffTokens(splitLines(a), splitLines(b), diff, true)
+	return diff
+}
+
+// DiffWords 按词比较两段文本，中日韩文字按单字比较
+// 先按行定位修改的段落，再在段落内部逐词比较，未修改的行整行保留
+func DiffWords(a, b string) *TextDiff {
+	lines := &TextDiff{}
+	diffTokens(splitLines(a), splitLines(b), lines, false)
+
+	diff := &TextDiff{}
+	var deleted, inserted strings.Builder
+	flush := func() {
+		if deleted.Len() > 0 || inserted.Len() > 0 {
+			diffTokens(splitWords(deleted.String()), splitWords(inserted.String()), diff, true)
+			deleted.Reset()
+			inserted.Reset()
+		}
+	}
+	for _, op := range lines.Ops {
+		switch op.Type {
+		case DiffDelete:
+			deleted.WriteString(op.Text)
+		case DiffInsert:
+			inserted.WriteString(op.Text)
+		default:
+			flush()
+			appendDiffOp(diff, DiffEqual, op.Text)
+		}
+	}
+	flush()
+	return diff
+}
+
+// diffTokens 比较两组片段并追加到结果中，count 为 false 时不统计增删数量
+func diffTokens(a, b []string, diff *TextDiff, count bool) {
+	// 去掉相同的首尾，缩小比较范围
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	appendDiffOp(diff, DiffEqual, strings.Join(a[:prefix], ""))
+	// 两段相同内容之间的删除与插入分别合并，删除在前
+	var deleted, inserted strings.Builder
+	flush := func() {
+		appendDiffOp(diff, DiffDelete, deleted.String())
+		appendDiffOp(diff, DiffInsert, inserted.String())
+		deleted.Reset()
+		inserted.Reset()
+	}
+	for _, e := range myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		switch e.typ {
+		case DiffEqual:
+			flush()
+			appendDiffOp(diff, DiffEqual, e.text)
+			continue
+		case DiffInsert:
+			inserted.WriteString(e.text)
+			if count && strings.TrimSpace(e.text) != "" {
+				diff.Insertions++
+			}
+		case DiffDelete:
+			deleted.WriteString(e.text)
+			if count && strings.TrimSpace(e.text) != "" {
+				diff.Deletions++
+			}
+		}
+	}
+	flush()
+	appendDiffOp(diff, DiffEqual, strings.Join(a[len(a)-suffix:], ""))
+}
+
+func appendDiffOp(diff *TextDiff, typ, text string) {
+	if text == "" {
+		return
+	}
+	if n := len(diff.Ops); n > 0 && diff.Ops[n-1].Type == typ {
+		diff.Ops[n-1].Text += text
+		return
+	}
+	diff.Ops = append(diff.Ops, DiffOp{Type: typ, Text: text})
+}
+
+type diffEdit struct {
+	typ  string
+	text string
+}
+
+// myersDiff Myers O(ND) 差异算法，返回按顺序排列的逐片段编辑
+func myersDiff(a, b []string) []diffEdit {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil
+	}
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	// trace[d] 保存第 d 步开始前 k ∈ [-d-1, d+1] 范围内的 v，用于回溯路径
+	var trace [][]int
+
+	found := false
+	for d := 0; d <= max && d <= maxDiffEdits; d++ {
+		window := make([]int, 2*d+3)
+		copy(window, v[offset-d-1:offset+d+2])
+		trace = append(trace, window)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+		if found {
+			break
+		}
+	}
+
+	if !found {
+		var edits []diffEdit
+		for _, t := range a {
+			edits = append(edits, diffEdit{DiffDelete, t})
+		}
+		for _, t := range b {
+			edits = append(edits, diffEdit{DiffInsert, t})
+		}
+		return edits
+	}
+
+	var edits []diffEdit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		window := trace[d]
+		at := func(k int) int { return window[k+d+1] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			edits = append(edits, diffEdit{DiffEqual, a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, diffEdit{DiffInsert, b[y-1]})
+			} else {
+				edits = append(edits, diffEdit{DiffDelete, a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// splitLines 按行切分，每行保留换行符
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// splitWords 切分为词、空白与标点，中日韩文字逐字切分
+func splitWords(s string) []string {
+	var tokens []string
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		j := i + 1
+		switch {
+		case isCJK(r):
+		case unicode.IsSpace(r):
+			for j < len(runes) && unicode.IsSpace(runes[j]) {
+				j++
+			}
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') && !isCJK(runes[j]) {
+				j++
+			}
+		}
+		tokens = append(tokens, string(runes[i:j]))
+		i = j
+	}
+	return tokens
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
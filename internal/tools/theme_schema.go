@@ -0,0 +1,873 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 统一主题配置的 Schema 版本
+// v1 为早期格式：没有 meta.schema_version，样式值允许写成数字
+// v2 为当前格式：meta.schema_version 必填，base / block / inline 必须存在，样式值统一为字符串
+const (
+	ThemeSchemaV1             = 1
+	ThemeSchemaV2             = 2
+	CurrentThemeSchemaVersion = ThemeSchemaV2
+)
+
+// themeSchemaV1JSON 统一主题配置 v1 的 JSON Schema
+const themeSchemaV1JSON = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "theme-config/v1",
+  "title": "统一主题配置 v1",
+  "type": "object",
+  "properties": {
+    "meta": {
+      "type": "object",
+      "properties": {
+        "name": {"type": "string"},
+        "description": {"type": "string"},
+        "version": {"type": "string"},
+        "type": {"type": "string"},
+        "schema_version": {"enum": [1]}
+      }
+    },
+    "base": {"$ref": "#/definitions/style"},
+    "block": {"$ref": "#/definitions/styleSheet"},
+    "inline": {"$ref": "#/definitions/styleSheet"},
+    "components": {
+      "type": "object",
+      "additionalProperties": {"$ref": "#/definitions/component"}
+    },
+    "rules": {
+      "type": "object",
+      "properties": {
+        "link": {"$ref": "#/definitions/linkRule"}
+      },
+      "additionalProperties": {"$ref": "#/definitions/decorationRule"}
+    },
+    "layout": {"$ref": "#/definitions/layout"}
+  },
+  "definitions": {
+    "style": {
+      "type": "object",
+      "additionalProperties": {"type": ["string", "number"]}
+    },
+    "styleSheet": {
+      "type": "object",
+      "additionalProperties": {"$ref": "#/definitions/style"}
+    },
+    "component": {
+      "type": "object",
+      "properties": {
+        "enabled": {"type": "boolean"},
+        "template": {"type": "string"},
+        "style": {"$ref": "#/definitions/style"}
+      }
+    },
+    "decorationRule": {
+      "type": "object",
+      "properties": {
+        "decoration": {"type": "string"},
+        "replace_original": {"type": "boolean"},
+        "auto_number": {"type": "boolean"}
+      }
+    },
+    "linkRule": {
+      "type": "object",
+      "properties": {
+        "footnote": {"type": "boolean"},
+        "references_title": {"type": "string"}
+      }
+    },
+    "layout": {
+      "type": "object",
+      "properties": {
+        "max_width": {"type": "string"},
+        "margin": {"type": "string"},
+        "padding": {"type": "string"}
+      }
+    }
+  }
+}`
+
+// themeSchemaV2JSON 统一主题配置 v2 的 JSON Schema
+const themeSchemaV2JSON = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "theme-config/v2",
+  "title": "统一主题配置 v2",
+  "type": "object",
+  "required": ["meta", "base", "block", "inline"],
+  "properties": {
+    "meta": {
+      "type": "object",
+      "required": ["schema_version"],
+      "properties": {
+        "name": {"type": "string"},
+        "description": {"type": "string"},
+        "version": {"type": "string"},
+        "type": {"type": "string"},
+        "schema_version": {"enum": [2]}
+      }
+    },
+    "base": {"$ref": "#/definitions/style"},
+    "block": {"$ref": "#/definitions/styleSheet"},
+    "inline": {"$ref": "#/definitions/styleSheet"},
+    "components": {
+      "type": "object",
+      "additionalProperties": {"$ref": "#/definitions/component"}
+    },
+    "rules": {
+      "type": "object",
+      "properties": {
+        "link": {"$ref": "#/definitions/linkRule"}
+      },
+      "additionalProperties": {"$ref": "#/definitions/decorationRule"}
+    },
+    "layout": {"$ref": "#/definitions/layout"}
+  },
+  "definitions": {
+    "style": {
+      "type": "object",
+      "additionalProperties": {"type": "string"}
+    },
+    "styleSheet": {
+      "type": "object",
+      "additionalProperties": {"$ref": "#/definitions/style"}
+    },
+    "component": {
+      "type": "object",
+      "required": ["template"],
+      "properties": {
+        "enabled": {"type": "boolean"},
+        "template": {"type": "string", "minLength": 1},
+        "style": {"$ref": "#/definitions/style"}
+      }
+    },
+    "decorationRule": {
+      "type": "object",
+      "properties": {
+        "decoration": {"type": "string", "minLength": 1},
+        "replace_original": {"type": "boolean"},
+        "auto_number": {"type": "boolean"}
+      }
+    },
+    "linkRule": {
+      "type": "object",
+      "properties": {
+        "footnote": {"type": "boolean"},
+        "references_title": {"type": "string"}
+      },
+      "additionalProperties": false
+    },
+    "layout": {
+      "type": "object",
+      "properties": {
+        "max_width": {"type": "string"},
+        "margin": {"type": "string"},
+        "padding": {"type": "string"}
+      },
+      "additionalProperties": false
+    }
+  }
+}`
+
+// themeSchemas 各版本的 Schema 文档
+var themeSchemas = map[int]string{
+	ThemeSchemaV1: themeSchemaV1JSON,
+	ThemeSchemaV2: themeSchemaV2JSON,
+}
+
+// parsedThemeSchemas 解析后的 Schema，包初始化时解析，格式错误直接 panic
+var parsedThemeSchemas = func() map[int]map[string]interface{} {
+	parsed := make(map[int]map[string]interface{}, len(themeSchemas))
+	for version, doc := range themeSchemas {
+		var schema map[string]interface{}
+		if err := json.Unmarshal([]byte(doc), &schema); err != nil {
+			panic(fmt.Sprintf("theme schema v%d is invalid: %v", version, err))
+		}
+		parsed[version] = schema
+	}
+	return parsed
+}()
+
+// ThemeSchema 返回指定版本的 JSON Schema 文档
+func ThemeSchema(version int) (json.RawMessage, bool) {
+	doc, ok := themeSchemas[version]
+	if !ok {
+		return nil, false
+	}
+	return json.RawMessage(doc), true
+}
+
+// ThemeSchemaVersions 返回支持的 Schema 版本，按从旧到新排序
+func ThemeSchemaVersions() []int {
+	versions := make([]int, 0, len(themeSchemas))
+	for v := range themeSchemas {
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// ThemeIssue 配置校验问题，Path 为 JSON Pointer 格式的位置
+type ThemeIssue struct {
+	Path    string `json:"path"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ThemeValidationResult 配置校验结果
+// Errors 表示配置不符合 Schema，保存时会被拒绝；Warnings 只提示可能渲染异常的写法
+type ThemeValidationResult struct {
+	SchemaVersion int          `json:"schema_version"`
+	Valid         bool         `json:"valid"`
+	Errors        []ThemeIssue `json:"errors"`
+	Warnings      []ThemeIssue `json:"warnings"`
+}
+
+func (r *ThemeValidationResult) addError(path, code, format string, args ...interface{}) {
+	r.Errors = append(r.Errors, ThemeIssue{Path: issuePath(path), Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *ThemeValidationResult) addWarning(path, code, format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, ThemeIssue{Path: issuePath(path), Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// Summary 汇总前几条错误，用于接口的提示信息
+func (r *ThemeValidationResult) Summary() string {
+	const maxShown = 3
+	parts := make([]string, 0, maxShown)
+	for i, issue := range r.Errors {
+		if i == maxShown {
+			parts = append(parts, fmt.Sprintf("等 %d 个错误", len(r.Errors)))
+			break
+		}
+		parts = append(parts, issue.Path+" "+issue.Message)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func issuePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// joinPointer 拼接 JSON Pointer，按 RFC 6901 转义 ~ 与 /
+func joinPointer(path, key string) string {
+	key = strings.ReplaceAll(key, "~", "~0")
+	key = strings.ReplaceAll(key, "/", "~1")
+	return path + "/" + key
+}
+
+// ThemeSchemaVersionOf 读取配置声明的 Schema 版本，未声明时视为 v1
+func ThemeSchemaVersionOf(config map[string]interface{}) int {
+	meta, _ := config["meta"].(map[string]interface{})
+	if v, ok := toNumber(meta["schema_version"]); ok && v == float64(int(v)) {
+		return int(v)
+	}
+	return ThemeSchemaV1
+}
+
+// IsUnifiedThemeConfig 判断数据是否为统一主题配置（包含任意一个统一配置分区）
+func IsUnifiedThemeConfig(data map[string]interface{}) bool {
+	for _, key := range []string{"base", "block", "inline", "components", "rules", "layout"} {
+		if _, ok := data[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateThemeConfig 按配置声明的 Schema 版本校验统一主题配置，并附带样式检查的警告
+func ValidateThemeConfig(config map[string]interface{}) *ThemeValidationResult {
+	result := &ThemeValidationResult{
+		SchemaVersion: ThemeSchemaVersionOf(config),
+		Errors:        []ThemeIssue{},
+		Warnings:      []ThemeIssue{},
+	}
+
+	schema, ok := parsedThemeSchemas[result.SchemaVersion]
+	if !ok {
+		result.addError("/meta/schema_version", "unsupported_version", "不支持的 Schema 版本 %d，当前支持 %v", result.SchemaVersion, ThemeSchemaVersions())
+		result.Valid = false
+		return result
+	}
+
+	v := &schemaValidator{root: schema, result: result}
+	v.validate(schema, config, "")
+
+	lintThemeConfig(config, result)
+	if result.SchemaVersion < CurrentThemeSchemaVersion {
+		result.addWarning("/meta/schema_version", "outdated_version", "配置使用旧版 Schema v%d，建议迁移到 v%d", result.SchemaVersion, CurrentThemeSchemaVersion)
+	}
+
+	result.Valid = len(result.Errors) == 0
+	return result
+}
+
+// NormalizeThemeConfig 保存前的处理：按声明的版本校验，通过后迁移到当前版本
+// 校验不通过时返回的配置为 nil，调用方应拒绝保存并返回校验结果
+func NormalizeThemeConfig(config map[string]interface{}) (map[string]interface{}, *ThemeValidationResult) {
+	result := ValidateThemeConfig(config)
+	if !result.Valid {
+		return nil, result
+	}
+	if result.SchemaVersion == CurrentThemeSchemaVersion {
+		return config, result
+	}
+
+	migrated, err := MigrateThemeConfig(config, CurrentThemeSchemaVersion)
+	if err != nil {
+		result.addError("/meta/schema_version", "migration_failed", "%v", err)
+		result.Valid = false
+		return nil, result
+	}
+	return migrated, ValidateThemeConfig(migrated)
+}
+
+// ValidateEmbeddedThemeConfig 校验场景配置中内嵌的主题配置
+// 场景配置的 theme 通常是主题名称，只有写成对象时才按统一主题配置校验，否则返回 nil
+func ValidateEmbeddedThemeConfig(configData interface{}) *ThemeValidationResult {
+	data, _ := configData.(map[string]interface{})
+	theme, ok := data["theme"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	result := ValidateThemeConfig(theme)
+	for i := range result.Errors {
+		result.Errors[i].Path = "/theme" + strings.TrimSuffix(result.Errors[i].Path, "/")
+	}
+	for i := range result.Warnings {
+		result.Warnings[i].Path = "/theme" + strings.TrimSuffix(result.Warnings[i].Path, "/")
+	}
+	return result
+}
+
+// ========================= JSON Schema 校验 =========================
+
+// schemaValidator 支持主题 Schema 用到的 draft-07 子集：
+// type、enum、required、properties、additionalProperties、minLength 以及本文档内的 $ref
+type schemaValidator struct {
+	root   map[string]interface{}
+	result *ThemeValidationResult
+}
+
+func (v *schemaValidator) validate(schema map[string]interface{}, value interface{}, path string) {
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved := v.resolve(ref)
+		if resolved == nil {
+			v.result.addError(path, "schema_error", "无法解析 Schema 引用 %s", ref)
+			return
+		}
+		schema = resolved
+	}
+
+	if types, ok := schema["type"]; ok && !matchesSchemaType(types, value) {
+		v.result.addError(path, "type_mismatch", "类型应为 %s，实际为 %s", describeSchemaType(types), jsonTypeOf(value))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && !inEnum(enum, value) {
+		v.result.addError(path, "enum_mismatch", "取值应为 %v 之一", enum)
+		return
+	}
+
+	if minLength, ok := toNumber(schema["minLength"]); ok {
+		if s, isStr := value.(string); isStr && float64(len([]rune(s))) < minLength {
+			v.result.addError(path, "too_short", "长度不能少于 %d", int(minLength))
+		}
+	}
+
+	obj, isObj := value.(map[string]interface{})
+	if !isObj {
+		return
+	}
+
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, r := range required {
+			key, _ := r.(string)
+			if _, exists := obj[key]; !exists {
+				v.result.addError(joinPointer(path, key), "required", "缺少必填字段 %s", key)
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		childPath := joinPointer(path, key)
+		if propSchema, ok := properties[key].(map[string]interface{}); ok {
+			v.validate(propSchema, obj[key], childPath)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.result.addError(childPath, "unknown_property", "不允许的字段 %s", key)
+			}
+		case map[string]interface{}:
+			v.validate(additional, obj[key], childPath)
+		}
+	}
+}
+
+// resolve 解析形如 #/definitions/style 的本地引用
+func (v *schemaValidator) resolve(ref string) map[string]interface{} {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil
+	}
+	var node interface{} = v.root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		node = m[part]
+	}
+	schema, _ := node.(map[string]interface{})
+	return schema
+}
+
+func matchesSchemaType(types interface{}, value interface{}) bool {
+	actual := jsonTypeOf(value)
+	check := func(t string) bool {
+		if t == actual {
+			return true
+		}
+		if t == "number" && actual == "integer" {
+			return true
+		}
+		return false
+	}
+	switch t := types.(type) {
+	case string:
+		return check(t)
+	case []interface{}:
+		for _, item := range t {
+			if s, ok := item.(string); ok && check(s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func describeSchemaType(types interface{}) string {
+	if list, ok := types.([]interface{}); ok {
+		parts := make([]string, 0, len(list))
+		for _, item := range list {
+			parts = append(parts, fmt.Sprint(item))
+		}
+		return strings.Join(parts, " 或 ")
+	}
+	return fmt.Sprint(types)
+}
+
+// jsonTypeOf 返回值对应的 JSON 类型名
+func jsonTypeOf(value interface{}) string {
+	switch val := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		if n, ok := toNumber(val); ok {
+			if n == float64(int64(n)) {
+				return "integer"
+			}
+			return "number"
+		}
+		return "unknown"
+	}
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, item := range enum {
+		if a, ok := toNumber(item); ok {
+			if b, ok := toNumber(value); ok && a == b {
+				return true
+			}
+			continue
+		}
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// toNumber 将 JSON 解码或代码中构造的数字统一为 float64
+func toNumber(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// ========================= 样式检查 =========================
+
+// knownStyleKeys 渲染器会读取的 block / inline 样式键
+var knownStyleKeys = map[string]bool{
+	"p": true, "blockquote_p": true, "blockquote": true, "hr": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "listitem": true, "li": true,
+	"code_pre": true, "pre": true, "code": true, "codespan": true,
+	"table": true, "thead": true, "th": true, "td": true,
+	"em": true, "strong": true, "del": true, "s": true,
+	"link": true, "a": true, "image": true, "img": true,
+	"footnote": true, "sup": true, "footnote_url": true,
+	"footnotes": true, "footnotes_title": true, "footnote_item": true,
+}
+
+// knownRuleKeys 装饰与链接规则支持的键
+var knownRuleKeys = map[string]bool{
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"blockquote": true, "link": true,
+}
+
+// knownCSSProperties 常用的 CSS 属性，不在列表中的属性给出警告
+var knownCSSProperties = toSet(
+	"align-items", "align-self", "background", "background-attachment", "background-clip", "background-color",
+	"background-image", "background-origin", "background-position", "background-repeat", "background-size",
+	"border", "border-bottom", "border-bottom-color", "border-bottom-left-radius", "border-bottom-right-radius",
+	"border-bottom-style", "border-bottom-width", "border-collapse", "border-color", "border-image", "border-left",
+	"border-left-color", "border-left-style", "border-left-width", "border-radius", "border-right",
+	"border-right-color", "border-right-style", "border-right-width", "border-spacing", "border-style", "border-top",
+	"border-top-color", "border-top-left-radius", "border-top-right-radius", "border-top-style", "border-top-width",
+	"border-width", "bottom", "box-shadow", "box-sizing", "caption-side", "clear", "color", "column-count",
+	"column-gap", "content", "cursor", "direction", "display", "flex", "flex-basis", "flex-direction", "flex-grow",
+	"flex-shrink", "flex-wrap", "float", "font", "font-family", "font-size", "font-style", "font-variant",
+	"font-weight", "gap", "height", "hyphens", "justify-content", "left", "letter-spacing", "line-height",
+	"list-style", "list-style-image", "list-style-position", "list-style-type", "margin", "margin-bottom",
+	"margin-left", "margin-right", "margin-top", "max-height", "max-width", "min-height", "min-width",
+	"object-fit", "opacity", "outline", "outline-color", "overflow", "overflow-wrap", "overflow-x", "overflow-y",
+	"padding", "padding-bottom", "padding-left", "padding-right", "padding-top", "position", "right", "tab-size",
+	"table-layout", "text-align", "text-decoration", "text-decoration-color", "text-decoration-line",
+	"text-decoration-style", "text-indent", "text-overflow", "text-shadow", "text-transform", "text-underline-offset",
+	"top", "transform", "vertical-align", "visibility", "white-space", "width", "word-break", "word-spacing",
+	"word-wrap", "writing-mode", "z-index",
+)
+
+// colorProperties 取值为单个颜色的属性
+var colorProperties = toSet(
+	"color", "background-color", "border-color", "border-top-color", "border-right-color",
+	"border-bottom-color", "border-left-color", "outline-color", "text-decoration-color",
+)
+
+// colorShorthandProperties 取值中可能包含颜色的简写属性，只检查其中的十六进制颜色
+var colorShorthandProperties = toSet(
+	"background", "border", "border-top", "border-right", "border-bottom", "border-left",
+	"outline", "box-shadow", "text-shadow", "text-decoration",
+)
+
+// cssNamedColors CSS 颜色关键字
+var cssNamedColors = toSet(
+	"transparent", "currentcolor", "inherit", "initial", "unset", "revert",
+	"aliceblue", "antiquewhite", "aqua", "aquamarine", "azure", "beige", "bisque", "black", "blanchedalmond",
+	"blue", "blueviolet", "brown", "burlywood", "cadetblue", "chartreuse", "chocolate", "coral", "cornflowerblue",
+	"cornsilk", "crimson", "cyan", "darkblue", "darkcyan", "darkgoldenrod", "darkgray", "darkgreen", "darkgrey",
+	"darkkhaki", "darkmagenta", "darkolivegreen", "darkorange", "darkorchid", "darkred", "darksalmon",
+	"darkseagreen", "darkslateblue", "darkslategray", "darkslategrey", "darkturquoise", "darkviolet", "deeppink",
+	"deepskyblue", "dimgray", "dimgrey", "dodgerblue", "firebrick", "floralwhite", "forestgreen", "fuchsia",
+	"gainsboro", "ghostwhite", "gold", "goldenrod", "gray", "green", "greenyellow", "grey", "honeydew", "hotpink",
+	"indianred", "indigo", "ivory", "khaki", "lavender", "lavenderblush", "lawngreen", "lemonchiffon", "lightblue",
+	"lightcoral", "lightcyan", "lightgoldenrodyellow", "lightgray", "lightgreen", "lightgrey", "lightpink",
+	"lightsalmon", "lightseagreen", "lightskyblue", "lightslategray", "lightslategrey", "lightsteelblue",
+	"lightyellow", "lime", "limegreen", "linen", "magenta", "maroon", "mediumaquamarine", "mediumblue",
+	"mediumorchid", "mediumpurple", "mediumseagreen", "mediumslateblue", "mediumspringgreen", "mediumturquoise",
+	"mediumvioletred", "midnightblue", "mintcream", "mistyrose", "moccasin", "navajowhite", "navy", "oldlace",
+	"olive", "olivedrab", "orange", "orangered", "orchid", "palegoldenrod", "palegreen", "paleturquoise",
+	"palevioletred", "papayawhip", "peachpuff", "peru", "pink", "plum", "powderblue", "purple", "rebeccapurple",
+	"red", "rosybrown", "royalblue", "saddlebrown", "salmon", "sandybrown", "seagreen", "seashell", "sienna",
+	"silver", "skyblue", "slateblue", "slategray", "slategrey", "snow", "springgreen", "steelblue", "tan", "teal",
+	"thistle", "tomato", "turquoise", "violet", "wheat", "white", "whitesmoke", "yellow", "yellowgreen",
+)
+
+var (
+	hexColorRe  = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3,4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+	funcColorRe = regexp.MustCompile(`(?i)^(rgba?|hsla?)\(\s*[-+0-9.%a-z]+(?:\s*[,\s]\s*[-+0-9.%a-z]+){2}(?:\s*[,/]\s*[0-9.]+%?)?\s*\)$`)
+	hexTokenRe  = regexp.MustCompile(`#[0-9a-zA-Z]+`)
+)
+
+func toSet(items ...string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
+
+// isValidCSSColor 判断颜色值是否合法：十六进制、rgb/rgba/hsl/hsla 函数或颜色关键字
+func isValidCSSColor(value string) bool {
+	value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "!important"))
+	if value == "" {
+		return false
+	}
+	if strings.HasPrefix(value, "var(") {
+		return true
+	}
+	return hexColorRe.MatchString(value) || funcColorRe.MatchString(value) || cssNamedColors[strings.ToLower(value)]
+}
+
+// lintThemeConfig 检查样式属性、颜色、标题层级与装饰引用，结果只作为警告
+func lintThemeConfig(config map[string]interface{}, result *ThemeValidationResult) {
+	if base, ok := config["base"].(map[string]interface{}); ok {
+		lintStyle("/base", base, result)
+	}
+
+	for _, section := range []string{"block", "inline"} {
+		sheet, ok := config[section].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, key := range sortedKeys(sheet) {
+			path := joinPointer("/"+section, key)
+			if !knownStyleKeys[key] {
+				result.addWarning(path, "unknown_style_key", "渲染器不会使用样式键 %s", key)
+			}
+			if style, ok := sheet[key].(map[string]interface{}); ok {
+				lintStyle(path, style, result)
+			}
+		}
+	}
+
+	lintHeadingLevels(config, result)
+
+	components, _ := config["components"].(map[string]interface{})
+	for _, name := range sortedKeys(components) {
+		component, ok := components[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		path := joinPointer("/components", name)
+		if template, ok := component["template"].(string); ok && template != "" && !strings.Contains(template, "{{content}}") {
+			result.addWarning(joinPointer(path, "template"), "missing_placeholder", "模板中没有 {{content}}，装饰后原内容会丢失")
+		}
+		if style, ok := component["style"].(map[string]interface{}); ok {
+			lintStyle(joinPointer(path, "style"), style, result)
+		}
+	}
+
+	rules, _ := config["rules"].(map[string]interface{})
+	for _, key := range sortedKeys(rules) {
+		path := joinPointer("/rules", key)
+		if !knownRuleKeys[key] {
+			result.addWarning(path, "unknown_rule", "不支持的规则 %s", key)
+			continue
+		}
+		rule, ok := rules[key].(map[string]interface{})
+		if !ok || key == "link" {
+			continue
+		}
+		decoration, _ := rule["decoration"].(string)
+		if decoration == "" {
+			continue
+		}
+		component, exists := components[decoration].(map[string]interface{})
+		if !exists {
+			result.addWarning(joinPointer(path, "decoration"), "unknown_component", "引用的装饰组件 %s 不存在", decoration)
+			continue
+		}
+		if enabled, _ := component["enabled"].(bool); !enabled {
+			result.addWarning(joinPointer(path, "decoration"), "component_disabled", "装饰组件 %s 未启用，规则不会生效", decoration)
+		}
+		if replace, _ := rule["replace_original"].(bool); !replace {
+			result.addWarning(joinPointer(path, "replace_original"), "decoration_inactive", "replace_original 未开启，装饰组件 %s 不会生效", decoration)
+		}
+	}
+}
+
+// lintStyle 检查单个样式表中的属性名与颜色值
+func lintStyle(path string, style map[string]interface{}, result *ThemeValidationResult) {
+	for _, prop := range sortedKeys(style) {
+		propPath := joinPointer(path, prop)
+		name := strings.ToLower(prop)
+		if !knownCSSProperties[name] && !strings.HasPrefix(name, "-webkit-") && !strings.HasPrefix(name, "--") {
+			result.addWarning(propPath, "unknown_css_property", "未知的 CSS 属性 %s", prop)
+			continue
+		}
+
+		value, ok := style[prop].(string)
+		if !ok {
+			continue
+		}
+		if colorProperties[name] && !isValidCSSColor(value) {
+			result.addWarning(propPath, "invalid_color", "无效的颜色值 %q", value)
+			continue
+		}
+		if colorShorthandProperties[name] {
+			for _, token := range hexTokenRe.FindAllString(value, -1) {
+				if !hexColorRe.MatchString(token) {
+					result.addWarning(propPath, "invalid_color", "无效的颜色值 %q", token)
+				}
+			}
+		}
+	}
+}
+
+// lintHeadingLevels 检查标题样式是否缺少层级：未定义任何标题，或跳过了中间层级
+func lintHeadingLevels(config map[string]interface{}, result *ThemeValidationResult) {
+	block, ok := config["block"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	highest := 0
+	for level := 1; level <= 6; level++ {
+		if _, ok := block["h"+strconv.Itoa(level)]; ok {
+			highest = level
+		}
+	}
+	if highest == 0 {
+		result.addWarning("/block", "missing_heading_levels", "没有定义任何标题样式，标题将使用默认样式")
+		return
+	}
+	for level := 1; level < highest; level++ {
+		key := "h" + strconv.Itoa(level)
+		if _, ok := block[key]; !ok {
+			result.addWarning(joinPointer("/block", key), "missing_heading_level", "定义了 h%d 但缺少 %s 的样式", highest, key)
+		}
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ========================= 版本迁移 =========================
+
+// themeMigrations 按源版本注册的迁移步骤，每一步把配置升级一个版本
+var themeMigrations = map[int]func(config map[string]interface{}){
+	ThemeSchemaV1: migrateThemeV1ToV2,
+}
+
+// MigrateThemeConfig 将配置逐版本升级到目标版本，返回新的配置，不修改传入的数据
+func MigrateThemeConfig(config map[string]interface{}, targetVersion int) (map[string]interface{}, error) {
+	if _, ok := themeSchemas[targetVersion]; !ok {
+		return nil, fmt.Errorf("不支持的 Schema 版本 %d", targetVersion)
+	}
+
+	version := ThemeSchemaVersionOf(config)
+	if _, ok := themeSchemas[version]; !ok {
+		return nil, fmt.Errorf("不支持的 Schema 版本 %d", version)
+	}
+	if version > targetVersion {
+		return nil, fmt.Errorf("不支持从 v%d 降级到 v%d", version, targetVersion)
+	}
+
+	migrated, _ := deepCopyJSON(config).(map[string]interface{})
+	for ; version < targetVersion; version++ {
+		step, ok := themeMigrations[version]
+		if !ok {
+			return nil, fmt.Errorf("缺少 v%d 到 v%d 的迁移步骤", version, version+1)
+		}
+		step(migrated)
+	}
+	return migrated, nil
+}
+
+// unitlessCSSProperties 数值不需要单位的属性，迁移时保持原样
+var unitlessCSSProperties = toSet("line-height", "font-weight", "opacity", "z-index", "flex", "flex-grow", "flex-shrink", "column-count")
+
+// migrateThemeV1ToV2 v1 升级到 v2：
+// 补齐 meta.schema_version 与 base / block / inline 分区，并把数字样式值改写为字符串，长度属性补上 px
+func migrateThemeV1ToV2(config map[string]interface{}) {
+	meta, ok := config["meta"].(map[string]interface{})
+	if !ok {
+		meta = make(map[string]interface{})
+		config["meta"] = meta
+	}
+	meta["schema_version"] = ThemeSchemaV2
+
+	for _, section := range []string{"base", "block", "inline"} {
+		if _, ok := config[section].(map[string]interface{}); !ok {
+			config[section] = make(map[string]interface{})
+		}
+	}
+
+	stringifyStyle(config["base"].(map[string]interface{}))
+	for _, section := range []string{"block", "inline"} {
+		for _, style := range config[section].(map[string]interface{}) {
+			if s, ok := style.(map[string]interface{}); ok {
+				stringifyStyle(s)
+			}
+		}
+	}
+	if components, ok := config["components"].(map[string]interface{}); ok {
+		for _, component := range components {
+			if c, ok := component.(map[string]interface{}); ok {
+				if s, ok := c["style"].(map[string]interface{}); ok {
+					stringifyStyle(s)
+				}
+			}
+		}
+	}
+}
+
+// stringifyStyle 将样式中的数字值转为 CSS 字符串
+func stringifyStyle(style map[string]interface{}) {
+	for prop, value := range style {
+		n, ok := toNumber(value)
+		if !ok {
+			continue
+		}
+		text := strconv.FormatFloat(n, 'f', -1, 64)
+		if n != 0 && !unitlessCSSProperties[strings.ToLower(prop)] {
+			text += "px"
+		}
+		style[prop] = text
+	}
+}
+
+// deepCopyJSON 深拷贝 JSON 结构的数据
+func deepCopyJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			copied[k] = deepCopyJSON(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = deepCopyJSON(item)
+		}
+		return copied
+	default:
+		return v
+	}
+}
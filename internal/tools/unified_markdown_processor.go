@@ -22,21 +22,6 @@ type UnifiedMarkdownProcessor struct {
 	mu             sync.RWMutex
 }
 
-// configCache 配置缓存
-type configCacheEntry struct {
-	config       map[string]interface{}
-	updatedAt    *float64
-	templateType string
-}
-
-var (
-	configCache          = make(map[string]*configCacheEntry)
-	markdownProcessCache = make(map[string]string)
-	cacheMaxSize         = 500
-	processCacheMaxSize  = 1000
-	cacheMutex           sync.RWMutex
-)
-
 // NewUnifiedMarkdownProcessor 创建新的处理器
 func NewUnifiedMarkdownProcessor() *UnifiedMarkdownProcessor {
 	return &UnifiedMarkdownProcessor{
@@ -45,103 +30,119 @@ func NewUnifiedMarkdownProcessor() *UnifiedMarkdownProcessor {
 }
 
 // LoadUnifiedConfig 加载统一配置（从数据库查找用户模板或官方模板）
+// 返回的配置为缓存共享对象，调用方不应修改
 func (p *UnifiedMarkdownProcessor) LoadUnifiedConfig(configName string) (map[string]interface{}, error) {
-	// 检查缓存
-	cacheMutex.RLock()
-	if cached, ok := configCache[configName]; ok {
-		// 如果不是默认配置，检查模板是否已更新
-		if cached.templateType != "default" && cached.updatedAt != nil {
-			currentUpdatedAt := p.getTemplateUpdatedAt(configName, cached.templateType)
-			if currentUpdatedAt != nil && *currentUpdatedAt == *cached.updatedAt {
-				cacheMutex.RUnlock()
-				return cached.config, nil
-			}
-			// 缓存失效，删除
-			delete(configCache, configName)
-		} else {
-			cacheMutex.RUnlock()
-			return cached.config, nil
-		}
+	entry, err := p.loadConfigEntry(configName)
+	if err != nil {
+		return nil, err
 	}
-	cacheMutex.RUnlock()
-
-	// 从数据库加载
-	config, updatedAt, templateType, err := p.loadFromDatabase(configName)
-	if err == nil && config != nil {
-		cacheMutex.Lock()
-		if len(configCache) >= cacheMaxSize {
-			// 删除第一个
-			for k := range configCache {
-				delete(configCache, k)
-				break
-			}
-		}
-		configCache[configName] = &configCacheEntry{
-			config:       config,
-			updatedAt:    updatedAt,
-			templateType: templateType,
+	return entry.config, nil
+}
+
+// loadConfigEntry 从缓存读取主题配置，未命中时查库，同一主题的并发加载只查一次
+// 找不到模板时缓存默认配置，有效期与普通配置相同，新建的模板在过期或失效后生效
+func (p *UnifiedMarkdownProcessor) loadConfigEntry(configName string) (*configCacheEntry, error) {
+	caches := getMarkdownCaches()
+	if cached, ok := caches.configs.Get(configName); ok {
+		if entry, ok := cached.(*configCacheEntry); ok {
+			return entry, nil
 		}
-		cacheMutex.Unlock()
-		return config, nil
 	}
 
-	// 使用默认配置
-	defaultConfig := getDefaultConfig()
-	cacheMutex.Lock()
-	if len(configCache) >= cacheMaxSize {
-		for k := range configCache {
-			delete(configCache, k)
-			break
+	value, err, _ := caches.configFlight.Do(configName, func() (interface{}, error) {
+		entry := &configCacheEntry{templateType: "default", fingerprint: "default"}
+		config, rawData, templateType, err := p.loadFromDatabase(configName)
+		if err == nil && config != nil {
+			entry.config = config
+			entry.templateType = templateType
+			entry.fingerprint = fmt.Sprintf("%x", md5.Sum([]byte(rawData)))
+		} else {
+			entry.config = getDefaultConfig()
 		}
+		caches.configs.Set(configName, entry, int64(len(configName)+len(rawData)))
+		return entry, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("加载主题配置 %s 失败: %w", configName, err)
 	}
-	configCache[configName] = &configCacheEntry{
-		config:       defaultConfig,
-		updatedAt:    nil,
-		templateType: "default",
+	entry, ok := value.(*configCacheEntry)
+	if !ok {
+		return nil, fmt.Errorf("加载主题配置 %s 失败: 意外的结果类型 %T", configName, value)
 	}
-	cacheMutex.Unlock()
-	return defaultConfig, nil
+	return entry, nil
 }
 
 // ProcessMarkdown 使用统一配置处理 Markdown 文本（带缓存）
 func (p *UnifiedMarkdownProcessor) ProcessMarkdown(markdownText string, configName string) (string, error) {
-	// 生成缓存键
-	hash := md5.Sum([]byte(markdownText))
-	cacheKey := fmt.Sprintf("%s:%x", configName, hash)
-
-	// 检查缓存
-	cacheMutex.RLock()
-	if cached, ok := markdownProcessCache[cacheKey]; ok {
-		cacheMutex.RUnlock()
-		return cached, nil
+	result, err := p.render(markdownText, configName, false)
+	if err != nil {
+		return "", err
 	}
-	cacheMutex.RUnlock()
+	return result.HTML, nil
+}
 
-	// 加载统一配置
-	unifiedConfig, err := p.LoadUnifiedConfig(configName)
+// ProcessMarkdownForWechat 以公众号兼容模式处理 Markdown（带缓存）
+// 所有元素都带内联样式，公众号不支持的结构会被改写或移除，并返回降级报告；
+// 返回的报告为缓存共享对象，调用方不应修改
+func (p *UnifiedMarkdownProcessor) ProcessMarkdownForWechat(markdownText string, configName string) (string, *WechatRenderReport, error) {
+	result, err := p.render(markdownText, configName, true)
 	if err != nil {
-		unifiedConfig = getDefaultConfig()
+		return "", nil, err
 	}
+	return result.HTML, result.Report, nil
+}
 
-	// 执行处理
-	result := p.processWithUnifiedConfig(markdownText, unifiedConfig)
+// render 渲染并缓存结果，依次查找进程内缓存、Redis 二级缓存，都未命中时渲染
+// 同一个缓存键的并发渲染只执行一次，其余请求共享结果；渲染 panic 时返回错误
+func (p *UnifiedMarkdownProcessor) render(markdownText string, configName string, wechat bool) (*markdownRenderResult, error) {
+	entry, err := p.loadConfigEntry(configName)
+	if err != nil {
+		return nil, err
+	}
+	mode := "html"
+	if wechat {
+		mode = "wechat"
+	}
+	key := renderCacheKey(configName, mode, entry.fingerprint, markdownText)
 
-	// 存入缓存
-	cacheMutex.Lock()
-	if len(markdownProcessCache) >= processCacheMaxSize {
-		for k := range markdownProcessCache {
-			delete(markdownProcessCache, k)
-			break
+	caches := getMarkdownCaches()
+	if cached, ok := caches.renders.Get(key); ok {
+		if result, ok := cached.(*markdownRenderResult); ok {
+			return result, nil
 		}
 	}
-	markdownProcessCache[cacheKey] = result
-	cacheMutex.Unlock()
 
+	value, err, _ := caches.renderFlight.Do(key, func() (interface{}, error) {
+		if result := caches.getRedis(key); result != nil {
+			caches.renders.Set(key, result, result.size())
+			return result, nil
+		}
+
+		var report *WechatRenderReport
+		if wechat {
+			report = NewWechatRenderReport()
+		}
+		// 合并后的渲染可能服务于其他处理器的请求，使用独立的处理器避免标题计数互相干扰
+		html := NewUnifiedMarkdownProcessor().processWithUnifiedConfig(markdownText, entry.config, report)
+		result := &markdownRenderResult{HTML: html, Report: report}
+
+		caches.renders.Set(key, result, result.size())
+		caches.setRedis(key, result)
+		return result, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("渲染 Markdown 失败: %w", err)
+	}
+	result, ok := value.(*markdownRenderResult)
+	if !ok {
+		return nil, fmt.Errorf("渲染 Markdown 失败: 意外的结果类型 %T", value)
+	}
 	return result, nil
 }
 
 // processWithUnifiedConfig 使用统一配置处理 Markdown 文本
-func (p *UnifiedMarkdownProcessor) processWithUnifiedConfig(markdownText string, unifiedConfig map[string]interface{}) string {
+// report 不为空时使用公众号兼容模式，并在装饰完成后清理不支持的标签与属性
+func (p *UnifiedMarkdownProcessor) processWithUnifiedConfig(markdownText string, unifiedConfig map[string]interface{}, report *WechatRenderReport) string {
 	// 重置计数器
 	p.mu.Lock()
 	p.headingCounter = make(map[string]int)
@@ -149,11 +150,19 @@ func (p *UnifiedMarkdownProcessor) processWithUnifiedConfig(markdownText string,
 
 	// 第一步：基础 Markdown 转 HTML
 	themeConfig := p.extractThemeConfig(unifiedConfig)
-	baseHTML := convertMarkdownToHTML(markdownText, themeConfig)
+	baseHTML := convertMarkdownToHTML(markdownText, themeConfig, mdRenderOptions{
+		wechat:       report,
+		linkFootnote: parseLinkFootnoteRule(unifiedConfig),
+	})
 
 	// 第二步：应用装饰
 	decoratedHTML := p.applyUnifiedDecorations(baseHTML, unifiedConfig)
 
+	// 第三步：公众号模式下清理装饰模板与原始 HTML 中不支持的内容
+	if report != nil {
+		decoratedHTML = sanitizeWechatHTML(decoratedHTML, report)
+	}
+
 	return decoratedHTML
 }
 
@@ -263,20 +272,20 @@ func (p *UnifiedMarkdownProcessor) processBlockquoteDecoration(htmlText string,
 	}
 
 	pattern := `<blockquote[^>]*>(.*?)</blockquote>`
-	re := regexp.MustCompile(`(?i)` + pattern)
+	re := regexp.MustCompile(`(?is)` + pattern)
 
 	template, _ := component["template"].(string)
 	style, _ := component["style"].(map[string]interface{})
 
 	return re.ReplaceAllStringFunc(htmlText, func(match string) string {
-		contentRe := regexp.MustCompile(`<blockquote[^>]*>(.*?)</blockquote>`)
+		contentRe := regexp.MustCompile(`(?is)<blockquote[^>]*>(.*?)</blockquote>`)
 		matches := contentRe.FindStringSubmatch(match)
 		if len(matches) < 2 {
 			return match
 		}
 		content := matches[1]
 		// 清理内容，移除内层的p标签
-		content = regexp.MustCompile(`<p[^>]*>(.*?)</p>`).ReplaceAllString(content, "$1")
+		content = regexp.MustCompile(`(?s)<p[^>]*>(.*?)</p>`).ReplaceAllString(content, "$1")
 		content = strings.TrimSpace(content)
 
 		return p.renderTemplate(template, map[string]interface{}{
@@ -301,14 +310,15 @@ func (p *UnifiedMarkdownProcessor) applyLayoutUnified(htmlText string, layout ma
 		padding = "20px"
 	}
 
-	pattern := `(<section[^>]*style="[^"]*")`
-	re := regexp.MustCompile(pattern)
-
-	layoutStyles := fmt.Sprintf("max-width: %s; margin: %s; padding: %s;", maxWidth, margin, padding)
+	// 布局只作用于最外层容器，内层的 section（列表、代码块、装饰模板）保持不变
+	re := regexp.MustCompile(`<section[^>]*style="[^"]*`)
+	loc := re.FindStringIndex(htmlText)
+	if loc == nil {
+		return htmlText
+	}
 
-	return re.ReplaceAllStringFunc(htmlText, func(match string) string {
-		return match + layoutStyles
-	})
+	layoutStyles := fmt.Sprintf(" max-width: %s; margin: %s; padding: %s;", maxWidth, margin, padding)
+	return htmlText[:loc[1]] + layoutStyles + htmlText[loc[1]:]
 }
 
 // renderTemplate 渲染模板，将变量替换为实际值
@@ -332,21 +342,15 @@ func (p *UnifiedMarkdownProcessor) renderTemplate(template string, variables map
 	return result
 }
 
-// loadFromDatabase 从数据库中加载模板配置
-func (p *UnifiedMarkdownProcessor) loadFromDatabase(configName string) (map[string]interface{}, *float64, string, error) {
+// loadFromDatabase 从数据库中加载模板配置，同时返回原始的模板数据用于计算配置指纹
+func (p *UnifiedMarkdownProcessor) loadFromDatabase(configName string) (map[string]interface{}, string, string, error) {
 	// 优先从官方模板查找
 	var publicTemplate models.PublicTemplate
 	if err := repository.DB.Where("template_id = ?", configName).First(&publicTemplate).Error; err == nil {
 		if publicTemplate.TemplateData != nil {
 			var config map[string]interface{}
 			if err := json.Unmarshal([]byte(*publicTemplate.TemplateData), &config); err == nil {
-				config = p.ensureConfigStructure(config)
-				var updatedAt *float64
-				if !publicTemplate.UpdatedAt.IsZero() {
-					ts := float64(publicTemplate.UpdatedAt.Unix())
-					updatedAt = &ts
-				}
-				return config, updatedAt, "public", nil
+				return p.ensureConfigStructure(config), *publicTemplate.TemplateData, "public", nil
 			}
 		}
 	}
@@ -357,47 +361,28 @@ func (p *UnifiedMarkdownProcessor) loadFromDatabase(configName string) (map[stri
 		if userTemplate.TemplateData != nil {
 			var config map[string]interface{}
 			if err := json.Unmarshal([]byte(*userTemplate.TemplateData), &config); err == nil {
-				config = p.ensureConfigStructure(config)
-				var updatedAt *float64
-				if !userTemplate.UpdatedAt.IsZero() {
-					ts := float64(userTemplate.UpdatedAt.Unix())
-					updatedAt = &ts
-				}
-				return config, updatedAt, "user", nil
+				return p.ensureConfigStructure(config), *userTemplate.TemplateData, "user", nil
 			}
 		}
 	}
 
-	return nil, nil, "default", gorm.ErrRecordNotFound
+	return nil, "", "default", gorm.ErrRecordNotFound
 }
 
-// getTemplateUpdatedAt 获取模板的更新时间戳
-func (p *UnifiedMarkdownProcessor) getTemplateUpdatedAt(configName string, templateType string) *float64 {
-	// 优先从官方模板查找
-	var publicTemplate models.PublicTemplate
-	if err := repository.DB.Where("template_id = ?", configName).First(&publicTemplate).Error; err == nil {
-		if !publicTemplate.UpdatedAt.IsZero() {
-			ts := float64(publicTemplate.UpdatedAt.Unix())
-			return &ts
+// ensureConfigStructure 确保模板配置包含必要的结构
+// 旧版本的配置先迁移到当前 Schema 版本，校验不通过时记录日志后继续使用，避免已保存的模板无法渲染
+func (p *UnifiedMarkdownProcessor) ensureConfigStructure(templateData map[string]interface{}) map[string]interface{} {
+	if ThemeSchemaVersionOf(templateData) < CurrentThemeSchemaVersion {
+		if migrated, err := MigrateThemeConfig(templateData, CurrentThemeSchemaVersion); err == nil {
+			templateData = migrated
+		} else {
+			repository.Warnf("主题配置迁移失败: %v", err)
 		}
 	}
-
-	// 如果官方模板不存在，再查用户模板
-	if templateType == "user" {
-		var userTemplate models.UserTemplate
-		if err := repository.DB.Where("template_id = ?", configName).First(&userTemplate).Error; err == nil {
-			if !userTemplate.UpdatedAt.IsZero() {
-				ts := float64(userTemplate.UpdatedAt.Unix())
-				return &ts
-			}
-		}
+	if result := ValidateThemeConfig(templateData); !result.Valid {
+		repository.Warnf("主题配置校验未通过: %s", result.Summary())
 	}
 
-	return nil
-}
-
-// ensureConfigStructure 确保模板配置包含必要的结构
-func (p *UnifiedMarkdownProcessor) ensureConfigStructure(templateData map[string]interface{}) map[string]interface{} {
 	if _, ok := templateData["components"]; !ok {
 		templateData["components"] = make(map[string]interface{})
 	}
@@ -414,10 +399,11 @@ func (p *UnifiedMarkdownProcessor) ensureConfigStructure(templateData map[string
 func getDefaultConfig() map[string]interface{} {
 	return map[string]interface{}{
 		"meta": map[string]interface{}{
-			"name":        "默认统一配置",
-			"description": "默认的统一配置，包含基础样式和简单装饰",
-			"version":     "1.0.0",
-			"type":        "unified",
+			"name":           "默认统一配置",
+			"description":    "默认的统一配置，包含基础样式和简单装饰",
+			"version":        "1.0.0",
+			"type":           "unified",
+			"schema_version": CurrentThemeSchemaVersion,
 		},
 		"base": map[string]interface{}{
 			"text-align":  "left",
@@ -457,61 +443,13 @@ func getDefaultConfig() map[string]interface{} {
 	}
 }
 
-// convertMarkdownToHTML 将 Markdown 转换为 HTML（简化版）
-func convertMarkdownToHTML(markdownText string, themeConfig map[string]interface{}) string {
+// convertMarkdownToHTML 将 Markdown 转换为 HTML
+// 按 CommonMark 与 GFM（表格、删除线、任务列表、自动链接）解析，并应用主题的 block / inline 样式
+func convertMarkdownToHTML(markdownText string, themeConfig map[string]interface{}, opts mdRenderOptions) string {
 	if markdownText == "" {
 		return ""
 	}
-
-	// 简化版：基本的markdown转换
-	// 这里可以使用更完整的markdown库，但为了简化，先实现基本功能
-	lines := strings.Split(markdownText, "\n")
-	var result strings.Builder
-	result.WriteString("<section style=\"text-align: left; line-height: 1.75; color: #333;\">\n")
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-
-		// 处理标题
-		if strings.HasPrefix(line, "# ") {
-			content := strings.TrimPrefix(line, "# ")
-			result.WriteString(fmt.Sprintf("<h1>%s</h1>\n", escapeHTML(content)))
-		} else if strings.HasPrefix(line, "## ") {
-			content := strings.TrimPrefix(line, "## ")
-			result.WriteString(fmt.Sprintf("<h2>%s</h2>\n", escapeHTML(content)))
-		} else if strings.HasPrefix(line, "### ") {
-			content := strings.TrimPrefix(line, "### ")
-			result.WriteString(fmt.Sprintf("<h3>%s</h3>\n", escapeHTML(content)))
-		} else if strings.HasPrefix(line, "> ") {
-			// 引用
-			content := strings.TrimPrefix(line, "> ")
-			result.WriteString(fmt.Sprintf("<blockquote>%s</blockquote>\n", processInlineMarkdown(content)))
-		} else if strings.HasPrefix(line, "- ") {
-			// 列表
-			content := strings.TrimPrefix(line, "- ")
-			result.WriteString(fmt.Sprintf("<ul><li>%s</li></ul>\n", processInlineMarkdown(content)))
-		} else {
-			// 段落
-			result.WriteString(fmt.Sprintf("<p>%s</p>\n", processInlineMarkdown(line)))
-		}
-	}
-
-	result.WriteString("</section>")
-	return result.String()
-}
-
-// processInlineMarkdown 处理行内markdown（粗体、斜体、代码等）
-func processInlineMarkdown(text string) string {
-	// 处理粗体 **text**
-	text = regexp.MustCompile(`\*\*(.*?)\*\*`).ReplaceAllString(text, "<strong>$1</strong>")
-	// 处理斜体 *text*
-	text = regexp.MustCompile(`\*(.*?)\*`).ReplaceAllString(text, "<em>$1</em>")
-	// 处理代码 `code`
-	text = regexp.MustCompile("`([^`]+)`").ReplaceAllString(text, "<code>$1</code>")
-	return text
+	return renderMarkdownHTML(markdownText, themeConfig, opts)
 }
 
 // escapeHTML 转义HTML
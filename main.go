@@ -7,6 +7,12 @@ import (
 	"01agent_server/internal/config"
 	"01agent_server/internal/repository"
 	"01agent_server/internal/router"
+	"01agent_server/internal/service"
+	"01agent_server/internal/service/carousel"
+	"01agent_server/internal/service/exporter"
+	"01agent_server/internal/service/payment"
+	"01agent_server/internal/service/publisher"
+	"01agent_server/internal/service/scheduler"
 
 	"github.com/gin-gonic/gin"
 )
@@ -49,6 +55,31 @@ func main() {
 		repository.Warn("Running without Redis")
 	}
 
+	// 初始化短信发送器，未配置短信服务时不终止启动，仅短信验证码接口返回服务不可用
+	if err := service.InitSMSSender(); err != nil {
+		repository.Warnf("SMS sender unavailable, SMS verify code endpoints are disabled: %v", err)
+	}
+
+	// 启动积分预扣过期清理
+	service.NewCreditLedgerService().StartReservationSweeper()
+
+	// 启动支付事件投递与订单自动对账
+	payment.GetOutbox().Start()
+	payment.NewService().StartReconciler()
+
+	// 启动多平台文章发布调度与定时发布
+	publisher.GetDispatcher().Start()
+	scheduler.GetScheduler().Start()
+
+	// 启动短图文服务端导出
+	exporter.GetWorker().Start()
+
+	// 启动短图文批量生成
+	carousel.GetWorker().Start()
+
+	// 启动短图文工程历史版本清理
+	service.NewProjectVersionService().StartPruner()
+
 	// 设置Gin模式
 	gin.SetMode(cfg.Server.Mode)
 